@@ -47,6 +47,7 @@ setup(
         "aiofiles>=24.0.0",
         "cachetools>=5.5.0",
         "pyyaml>=6.0.0",
+        "prometheus-client>=0.20.0",
     ],
     entry_points={
         "console_scripts": [
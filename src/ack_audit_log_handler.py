@@ -260,6 +260,7 @@ class ACKAuditLogHandler:
         enable_execution_log_ctx.set(self.enable_execution_log)
         execution_log = ExecutionLog(
             tool_call_id=f"get_current_time_{int(time.time() * 1000)}",
+            tool_name="get_current_time",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         start_ms = int(time.time() * 1000)
@@ -423,6 +424,7 @@ class ACKAuditLogHandler:
         # Initialize execution log
         execution_log = ExecutionLog(
             tool_call_id=f"query_audit_log_{int(time.time() * 1000)}",
+            tool_name="query_audit_log",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         start_ms = int(time.time() * 1000)
@@ -118,6 +118,7 @@ class ACKAutoscalingHandler:
         namespace: str = Field(..., description="命名空间名称"),
         workload_type: str = Field(..., description="工作负载类型"),
         workload_name: str = Field(..., description="工作负载名称"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
     ) -> WorkloadAutoscalingAnalysisOutput:
         """评估工作负载的弹性伸缩特征，并给出 HPA 配置推荐"""
 
@@ -126,6 +127,7 @@ class ACKAutoscalingHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"analyze_workload_autoscaling_{cluster_id}_{namespace}_{workload_name}_{start_ms}",
+            tool_name="analyze_workload_autoscaling",
             start_time=datetime.utcnow().isoformat() + "Z",
         )
 
@@ -158,7 +160,7 @@ class ACKAutoscalingHandler:
             execution_log.messages.append("Stage 1: Prechecking workload stability for HPA eligibility")
 
             precheck_result = await self._precheck_workload_stability(
-                ctx, cluster_id, namespace, workload_type, workload_name, execution_log
+                ctx, cluster_id, namespace, workload_type, workload_name, execution_log, context
             )
 
             execution_log.messages.append(
@@ -166,7 +168,7 @@ class ACKAutoscalingHandler:
             )
 
             elasticity_result = await self._analyze_workload_elasticity(
-                ctx, endpoint, cluster_id, namespace, workload_type, workload_name, execution_log
+                ctx, endpoint, cluster_id, namespace, workload_type, workload_name, execution_log, context
             )
 
             if not precheck_result.stable_for_hpa:
@@ -182,7 +184,8 @@ class ACKAutoscalingHandler:
                     "Overall analysis indicates this workload is suitable for autoscaling; proceeding to HPA recommendation analysis"
                 )
                 hpa_recommendation = await self._analyze_hpa_recommendation(
-                    ctx, endpoint, cluster_id, namespace, workload_type, workload_name, elasticity_result.resource_analysis, execution_log
+                    ctx, endpoint, cluster_id, namespace, workload_type, workload_name, elasticity_result.resource_analysis, execution_log,
+                    context,
                 )
 
             execution_log.end_time = datetime.utcnow().isoformat() + "Z"
@@ -230,6 +233,7 @@ class ACKAutoscalingHandler:
         workload_type: str,
         workload_name: str,
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> WorkloadPrecheckResult:
         """Workload 前置稳定性检查：判断是否满足开启 HPA 的基础条件。"""
         try:
@@ -242,6 +246,8 @@ class ACKAutoscalingHandler:
                 self.settings.get("kubeconfig_mode"),
                 self.settings.get("kubeconfig_path"),
                 execution_log,
+                context,
+                kubeconfig_dir=self.settings.get("kubeconfig_dir"),
             )
 
             command = (
@@ -380,10 +386,11 @@ class ACKAutoscalingHandler:
         workload_type: str,
         workload_name: str,
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> ElasticityAnalysisResult:
         """综合分析工作负载的弹性相关信号，并给出是否建议开启弹性伸缩的判断。"""
         volatility_results = await self._analyze_resources_volatility(
-            ctx, endpoint, cluster_id, namespace, workload_type, workload_name, execution_log
+            ctx, endpoint, cluster_id, namespace, workload_type, workload_name, execution_log, context
         )
         if not volatility_results:
             execution_log.warnings.append(
@@ -410,6 +417,7 @@ class ACKAutoscalingHandler:
         workload_type: str,
         workload_name: str,
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> List[WorkloadResourceProfile]:
         """分析 CPU 和内存的资源使用特征，用于评估弹性适配性。"""
         results = []
@@ -422,6 +430,7 @@ class ACKAutoscalingHandler:
             start_sec=start_sec,
             end_sec=now_sec,
             execution_log=execution_log,
+            context=context,
         )
         if cpu_result:
             results.append(cpu_result)
@@ -432,6 +441,7 @@ class ACKAutoscalingHandler:
             start_sec=start_sec,
             end_sec=now_sec,
             execution_log=execution_log,
+            context=context,
         )
         if memory_result:
             results.append(memory_result)
@@ -450,6 +460,7 @@ class ACKAutoscalingHandler:
         start_sec: int,
         end_sec: int,
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> Optional[WorkloadResourceProfile]:
         """分析单个资源维度的特征。
 
@@ -479,7 +490,7 @@ class ACKAutoscalingHandler:
             p99_value = self._calculate_percentile_value(values, 0.99)
 
             original_pod_request = await self._get_pod_request(
-                ctx, cluster_id, namespace, workload_type, workload_name, resource_type, execution_log
+                ctx, cluster_id, namespace, workload_type, workload_name, resource_type, execution_log, context
             )
             
             # 用于波动性算法的 request 基准值
@@ -554,6 +565,7 @@ class ACKAutoscalingHandler:
         workload_name: str,
         volatility_results: List[WorkloadResourceProfile],
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> Optional[HPARecommendation]:
         """基于分位数算法推荐 HPA 配置。"""
         try:
@@ -597,11 +609,11 @@ class ACKAutoscalingHandler:
             # 获取 pod_request（如果波动性分析中没有）
             if not cpu_request:
                 cpu_request = await self._get_pod_request(
-                    ctx, cluster_id, namespace, workload_type, workload_name, "cpu", execution_log
+                    ctx, cluster_id, namespace, workload_type, workload_name, "cpu", execution_log, context
                 )
             if not memory_request:
                 memory_request = await self._get_pod_request(
-                    ctx, cluster_id, namespace, workload_type, workload_name, "memory", execution_log
+                    ctx, cluster_id, namespace, workload_type, workload_name, "memory", execution_log, context
                 )
 
             if not cpu_request or not memory_request:
@@ -846,6 +858,7 @@ class ACKAutoscalingHandler:
         workload_name: str,
         resource_type: str,
         execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> Optional[float]:
         """查询 workload 的 pod request 值（使用最小值作为基准容量）。"""
         try:
@@ -857,7 +870,9 @@ class ACKAutoscalingHandler:
                 cluster_id, 
                 self.settings.get("kubeconfig_mode"), 
                 self.settings.get("kubeconfig_path"), 
-                execution_log
+                execution_log,
+                context,
+                kubeconfig_dir=self.settings.get("kubeconfig_dir"),
             )
 
             command = f"kubectl --kubeconfig {kubeconfig_path} get {workload_type} {workload_name} -n {namespace} -o json"
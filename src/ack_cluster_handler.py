@@ -324,6 +324,7 @@ def _parse_cluster_info(d: Dict[str, Any]) -> Optional[ClusterInfo]:
             cluster_type=ctype,
             region_id=d.get("region_id"),
             current_version=d.get("current_version"),
+            node_count=d.get("size"),
             vpc_id=d.get("vpc_id"),
             vswitch_ids=d.get("vswitch_ids"),
             resource_group_id=d.get("resource_group_id"),
@@ -367,7 +368,7 @@ class ACKClusterHandler:
             return
         self.server = server
         # Register tools
-        self.server.tool(name="list_clusters", description="获取所有region下所有ACK集群列表，默认返回最多10个集群")(
+        self.server.tool(name="list_clusters", description="获取所有region（或指定 region_id）下的ACK集群列表，包含集群ID、名称、region、Kubernetes版本、状态与节点数，默认返回最多10个集群")(
             self.list_clusters
         )
         self.server.tool(
@@ -389,6 +390,7 @@ class ACKClusterHandler:
         ctx: Context,
         page_size: Annotated[int, Field(description="查询每个region集群列表的分页页码")] = 10,
         page_num: Annotated[int, Field(description="查询每个region集群列表的分页页码")] = 1,
+        region_id: Annotated[Optional[str], Field(description="仅查询指定 region 的集群，如 cn-hangzhou，为空表示全部 region")] = None,
     ) -> ListClustersOutput:
         """
         Retrieve all ACK clusters from all regions, returning up to 10 clusters by default.
@@ -397,6 +399,7 @@ class ACKClusterHandler:
             ctx: FastMCP context containing lifespan providers
             page_size: Number of clusters to return per page, default is 10
             page_num: Page number for pagination, default is 1
+            region_id: Optional region filter, all regions when empty
 
         Returns:
             ListClustersOutput: Contains cluster list and execution log
@@ -411,6 +414,7 @@ class ACKClusterHandler:
         start_ms = now.timestamp() * 1000
         execution_log = ExecutionLog(
             tool_call_id=f"list_clusters_{start_ms}",
+            tool_name="list_clusters",
             start_time=now.isoformat(),
         )
 
@@ -421,10 +425,15 @@ class ACKClusterHandler:
             actual_page_size = min(page_size or 10, 500)
             actual_page_num = page_num or 1
 
+            region_filter = region_id if isinstance(region_id, str) and region_id else None
             request = cs20151215_models.DescribeClustersV1Request(
                 page_size=actual_page_size,
                 page_number=actual_page_num,
+                region_id=region_filter,
             )
+            request_params = {"page_size": actual_page_size, "page_number": actual_page_num}
+            if region_filter:
+                request_params["region_id"] = region_filter
             runtime, headers = util_models.RuntimeOptions(), {}
 
             # 调用 API
@@ -439,7 +448,7 @@ class ACKClusterHandler:
                     {
                         "api": "DescribeClustersV1",
                         "region": "CENTER",
-                        "request_params": {"page_size": actual_page_size, "page_number": actual_page_num},
+                        "request_params": request_params,
                         "duration_ms": api_duration,
                         "status": "success",
                     }
@@ -451,7 +460,7 @@ class ACKClusterHandler:
                     {
                         "api": "DescribeClustersV1",
                         "region": "CENTER",
-                        "request_params": {"page_size": actual_page_size, "page_number": actual_page_num},
+                        "request_params": request_params,
                         "duration_ms": api_duration,
                         "status": "failed",
                         "error": str(api_error),
@@ -555,6 +564,7 @@ class ACKClusterHandler:
         start_ms = now.timestamp() * 1000
         execution_log = ExecutionLog(
             tool_call_id=f"list_cluster_nodepools_{start_ms}",
+            tool_name="list_cluster_nodepools",
             start_time=now.isoformat(),
         )
         try:
@@ -649,6 +659,7 @@ class ACKClusterHandler:
         start_ms = now.timestamp() * 1000
         execution_log = ExecutionLog(
             tool_call_id=f"list_cluster_nodes_{start_ms}",
+            tool_name="list_cluster_nodes",
             start_time=now.isoformat(),
         )
         try:
@@ -749,6 +760,7 @@ class ACKClusterHandler:
         start_ms = now.timestamp() * 1000
         execution_log = ExecutionLog(
             tool_call_id=f"list_cluster_tasks_{start_ms}",
+            tool_name="list_cluster_tasks",
             start_time=now.isoformat(),
         )
         try:
@@ -351,6 +351,7 @@ class ACKControlPlaneLogHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"query_controlplane_logs_{cluster_id}_{component_name}_{start_ms}",
+            tool_name="query_controlplane_logs",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
@@ -75,6 +75,7 @@ class ACKCostAnalysisHandler:
         namespace: str = Field(..., description="命名空间名称"),
         workload_type: str = Field(..., description="工作负载类型"),
         workload_name: str = Field(..., description="工作负载名称"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
     ) -> WorkloadCostOutput:
         """
         分析工作负载成本明细
@@ -100,6 +101,7 @@ class ACKCostAnalysisHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"analyze_workload_cost_{cluster_id}_{namespace}_{workload_name}_{start_ms}",
+            tool_name="analyze_workload_cost",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
 
@@ -108,14 +110,14 @@ class ACKCostAnalysisHandler:
                 "Step 1: Analyzing stability and efficiency based on instant metrics (kubectl top + request/limit)"
             )
             instant_analysis = await self._analyze_instant_metrics(
-                ctx, cluster_id, namespace, workload_type, workload_name, execution_log
+                ctx, cluster_id, namespace, workload_type, workload_name, execution_log, context
             )
 
             execution_log.messages.append(
                 "Step 2 [Optional]: Fetching resource recommendation from Recommendation CR"
             )
             recommendation = await self._get_resource_recommendation(
-                ctx, cluster_id, namespace, workload_type, workload_name, execution_log
+                ctx, cluster_id, namespace, workload_type, workload_name, execution_log, context
             )
 
             execution_log.end_time = datetime.utcnow().isoformat() + "Z"
@@ -178,7 +180,8 @@ class ACKCostAnalysisHandler:
         namespace: str,
         workload_type: str,
         workload_name: str,
-        execution_log: ExecutionLog
+        execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> Dict[str, Any]:
         """根据瞬时水位分析稳定性和效率：kubectl top + request/limit"""
         try:
@@ -194,7 +197,8 @@ class ACKCostAnalysisHandler:
                 cluster_id,
                 self.settings.get("kubeconfig_mode"),
                 self.settings.get("kubeconfig_path"),
-                execution_log
+                execution_log,
+                context,
             )
             
             # 获取 workload spec（request/limit）
@@ -387,7 +391,8 @@ class ACKCostAnalysisHandler:
         namespace: str,
         workload_type: str,
         workload_name: str,
-        execution_log: ExecutionLog
+        execution_log: ExecutionLog,
+        context: Optional[str] = None,
     ) -> Optional[Dict[str, Any]]:
         """获取资源画像推荐配置"""
         try:
@@ -403,7 +408,8 @@ class ACKCostAnalysisHandler:
                 cluster_id,
                 self.settings.get("kubeconfig_mode"),
                 self.settings.get("kubeconfig_path"),
-                execution_log
+                execution_log,
+                context,
             )
             
             # 构建 label selector（workload kind 使用驼峰命名）
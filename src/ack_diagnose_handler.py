@@ -106,6 +106,7 @@ class DiagnoseHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"diagnose_resource_{cluster_id}_{resource_type}_{start_ms}",
+            tool_name="diagnose_resource",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
@@ -235,6 +236,7 @@ class DiagnoseHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"get_diagnose_resource_result_{cluster_id}_{diagnose_task_id}_{start_ms}",
+            tool_name="get_diagnose_resource_result",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
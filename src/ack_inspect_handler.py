@@ -86,6 +86,7 @@ class InspectHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"query_inspect_report_{cluster_id}_{start_ms}",
+            tool_name="query_inspect_report",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
@@ -380,6 +381,7 @@ class InspectHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"get_inspect_report_detail_{report_id}_{start_ms}",
+            tool_name="get_inspect_report_detail",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
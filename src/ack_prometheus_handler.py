@@ -252,6 +252,7 @@ class PrometheusHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"query_prometheus_{cluster_id}_{start_ms}",
+            tool_name="query_prometheus",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
@@ -412,6 +413,7 @@ class PrometheusHandler:
         start_ms = int(time.time() * 1000)
         execution_log = ExecutionLog(
             tool_call_id=f"query_prometheus_metric_guidance_{resource_label}_{metric_category}_{start_ms}",
+            tool_name="query_prometheus_metric_guidance",
             start_time=datetime.utcnow().isoformat() + "Z"
         )
         
@@ -0,0 +1,90 @@
+"""API 发现结果缓存。
+
+解析内置注册表之外的资源类型（CRD 等）依赖 kubectl api-resources 的发现结果，每次调用都执行会拖慢工具调用，
+并给 API Server 的发现接口带来压力。DiscoveryCache 按 kubeconfig 缓存发现结果，进程内所有工具共享：
+
+- 缓存每隔 --discovery-refresh-interval 秒失效，新安装的 CRD 最迟在一个周期后可被解析
+- 资源在缓存中未找到时（可能是刚安装的 CRD），缓存早于 MIN_REFRESH_SECONDS 秒则立即重新发现一次
+- 同一 kubeconfig 的并发未命中只执行一次发现，其余调用等待其结果
+- 按请求传入的 kubeconfig（--allow-inline-kubeconfig）不缓存
+"""
+
+import asyncio
+import threading
+import time
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from cachetools import TTLCache
+
+DEFAULT_DISCOVERY_REFRESH_INTERVAL = 600
+
+# 未命中时重新发现的最小间隔（秒），避免拼写错误的资源名反复触发发现
+MIN_REFRESH_SECONDS = 30
+
+# 缓存的 kubeconfig 数量上限
+DISCOVERY_CACHE_MAX_SIZE = 64
+
+
+class DiscoveryCache:
+    """按 kubeconfig 缓存 API 发现结果（线程安全）"""
+
+    def __init__(self, refresh_interval: float = DEFAULT_DISCOVERY_REFRESH_INTERVAL,
+                 maxsize: int = DISCOVERY_CACHE_MAX_SIZE):
+        self.refresh_interval = refresh_interval
+        # kubeconfig -> (发现时间, 发现结果)，与发现时间使用同一时钟
+        self._entries: TTLCache = TTLCache(maxsize=maxsize, ttl=refresh_interval, timer=time.monotonic)
+        # 进行中的发现：kubeconfig -> 任务
+        self._pending: Dict[str, asyncio.Future] = {}
+        self._lock = threading.Lock()
+
+    async def get(
+        self,
+        key: str,
+        fetch: Callable[[], Awaitable[List[Dict[str, Any]]]],
+        max_age: Optional[float] = None,
+    ) -> List[Dict[str, Any]]:
+        """返回 key 对应的发现结果，缓存不存在或早于 max_age 秒时调用 fetch 重新发现（失败不缓存）"""
+        with self._lock:
+            cached: Optional[Tuple[float, List[Dict[str, Any]]]] = self._entries.get(key)
+            if cached is not None and (max_age is None or time.monotonic() - cached[0] <= max_age):
+                return cached[1]
+            task = self._pending.get(key)
+            if task is None:
+                task = self._pending[key] = asyncio.ensure_future(self._fetch(key, fetch))
+        # 调用方被取消时发现继续进行，结果供其他调用使用
+        return await asyncio.shield(task)
+
+    async def _fetch(self, key: str, fetch: Callable[[], Awaitable[List[Dict[str, Any]]]]) -> List[Dict[str, Any]]:
+        try:
+            entries = await fetch()
+            with self._lock:
+                self._entries[key] = (time.monotonic(), entries)
+            return entries
+        finally:
+            with self._lock:
+                self._pending.pop(key, None)
+
+    def invalidate(self, key: Optional[str] = None):
+        """清除 key 对应的缓存，key 为空时清除全部"""
+        with self._lock:
+            if key is None:
+                self._entries.clear()
+            else:
+                self._entries.pop(key, None)
+
+
+_caches: Dict[float, DiscoveryCache] = {}
+_caches_lock = threading.Lock()
+
+
+def get_discovery_cache(settings: Optional[Dict[str, Any]] = None) -> Optional[DiscoveryCache]:
+    """按配置获取进程内共享的发现缓存，discovery_refresh_interval <= 0 时不缓存返回 None"""
+    settings = settings or {}
+    interval = float(settings.get("discovery_refresh_interval", DEFAULT_DISCOVERY_REFRESH_INTERVAL) or 0)
+    if interval <= 0:
+        return None
+    with _caches_lock:
+        cache = _caches.get(interval)
+        if cache is None:
+            cache = _caches[interval] = DiscoveryCache(interval)
+        return cache
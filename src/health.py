@@ -0,0 +1,85 @@
+"""HTTP 健康检查端点。
+
+以 sse/http 传输方式部署为 Deployment 时，为 Kubernetes 提供存活（/healthz）与就绪（/readyz）探针：
+- /healthz 仅表示进程可响应请求
+- /readyz 校验 kubeconfig 可用且能访问 API Server（/version），ACK_PUBLIC/ACK_PRIVATE 模式下按集群动态获取
+  kubeconfig，此时仅校验阿里云凭据已配置
+"""
+
+import json
+from typing import Any, Dict, Optional, Tuple
+
+from fastmcp import FastMCP
+from loguru import logger
+from starlette.requests import Request
+from starlette.responses import PlainTextResponse
+
+from kubectl_handler import get_context_manager
+from kubectl_runner import KubectlRunner
+from models import ExecutionLog
+
+# 就绪检查访问 API Server 的超时（秒），需小于探针的 timeoutSeconds
+READINESS_TIMEOUT_SECONDS = 5
+
+# 不依赖具体集群的 kubeconfig 模式使用的缓存键
+HEALTH_CHECK_CLUSTER_ID = "_health"
+
+# 按集群ID动态获取 kubeconfig 的模式
+ACK_KUBECONFIG_MODES = ("ACK_PUBLIC", "ACK_PRIVATE")
+
+
+class HealthChecker:
+    """存活与就绪检查"""
+
+    def __init__(self, settings: Optional[Dict[str, Any]] = None):
+        self.settings = settings or {}
+        self.runner = KubectlRunner(self.settings)
+
+    async def check_ready(self) -> Tuple[bool, str]:
+        """返回 (是否就绪, 原因)"""
+        mode = self.settings.get("kubeconfig_mode") or "ACK_PUBLIC"
+        if mode in ACK_KUBECONFIG_MODES:
+            if not (self.settings.get("access_key_id") and self.settings.get("access_key_secret")):
+                return False, f"AlibabaCloud credentials are not configured (kubeconfig mode {mode})"
+            return True, f"ok (kubeconfig mode {mode})"
+
+        execution_log = ExecutionLog(tool_call_id="readyz", tool_name="readyz")
+        try:
+            kubeconfig_path = get_context_manager().get_kubeconfig_path(
+                HEALTH_CHECK_CLUSTER_ID,
+                mode,
+                self.settings.get("kubeconfig_path"),
+                execution_log,
+            )
+        except Exception as e:
+            return False, f"kubeconfig unavailable: {e}"
+
+        result = await self.runner.run(
+            kubeconfig_path, ["get", "--raw", "/version"], execution_log, timeout=READINESS_TIMEOUT_SECONDS,
+        )
+        if result["exit_code"] != 0:
+            reason = (result["stderr"] or f"kubectl exited with code {result['exit_code']}").splitlines()[0]
+            return False, f"API server unreachable: {reason}"
+        try:
+            version = json.loads(result["stdout"]).get("gitVersion")
+        except (ValueError, AttributeError):
+            version = None
+        return True, f"ok (API server {version or 'reachable'})"
+
+
+def register_health_routes(server: FastMCP, settings: Optional[Dict[str, Any]] = None) -> HealthChecker:
+    """在 MCP HTTP 服务上注册 /healthz 与 /readyz"""
+    checker = HealthChecker(settings)
+
+    @server.custom_route("/healthz", methods=["GET"])
+    async def healthz(request: Request) -> PlainTextResponse:
+        return PlainTextResponse("ok")
+
+    @server.custom_route("/readyz", methods=["GET"])
+    async def readyz(request: Request) -> PlainTextResponse:
+        ready, reason = await checker.check_ready()
+        if not ready:
+            logger.warning(f"Readiness check failed: {reason}")
+        return PlainTextResponse(reason, status_code=200 if ready else 503)
+
+    return checker
@@ -0,0 +1,61 @@
+"""HTTP 传输的 Bearer Token 认证。
+
+以 sse/http 传输方式对外提供服务时，通过 --auth-token 要求每个请求携带 Authorization: Bearer <token>，
+未携带或不匹配时返回 401。健康检查端点（/healthz、/readyz）不需要认证，便于 Kubernetes 探针访问。
+"""
+
+import hmac
+import json
+from typing import Any, Awaitable, Callable, Dict, Iterable, Optional
+
+from loguru import logger
+
+# 不需要认证的路径
+AUTH_EXEMPT_PATHS = ("/healthz", "/readyz")
+
+_BEARER_PREFIX = "bearer "
+
+
+def bearer_token_matches(authorization: Optional[str], token: str) -> bool:
+    """Authorization 请求头是否携带与 token 一致的 Bearer Token（常量时间比较）"""
+    if not authorization or authorization[:len(_BEARER_PREFIX)].lower() != _BEARER_PREFIX:
+        return False
+    provided = authorization[len(_BEARER_PREFIX):].strip()
+    return hmac.compare_digest(provided.encode(), token.encode())
+
+
+class BearerTokenAuthMiddleware:
+    """校验 Authorization: Bearer 请求头的 ASGI 中间件"""
+
+    def __init__(self, app: Callable[..., Awaitable[Any]], token: str,
+                 exempt_paths: Iterable[str] = AUTH_EXEMPT_PATHS):
+        if not token:
+            raise ValueError("auth token must not be empty")
+        self.app = app
+        self.token = token
+        self.exempt_paths = tuple(exempt_paths)
+
+    async def __call__(self, scope: Dict[str, Any], receive: Callable, send: Callable):
+        # 浏览器的 CORS 预检请求不携带 Authorization
+        if (scope.get("type") != "http" or scope.get("method") == "OPTIONS"
+                or scope.get("path") in self.exempt_paths):
+            await self.app(scope, receive, send)
+            return
+        headers = {key.decode("latin-1").lower(): value.decode("latin-1") for key, value in scope.get("headers") or []}
+        if bearer_token_matches(headers.get("authorization"), self.token):
+            await self.app(scope, receive, send)
+            return
+
+        client = (scope.get("client") or ("unknown",))[0]
+        logger.warning(f"Rejected unauthenticated request to {scope.get('path')} from {client}")
+        body = json.dumps({"error": "unauthorized", "error_description": "missing or invalid bearer token"}).encode()
+        await send({
+            "type": "http.response.start",
+            "status": 401,
+            "headers": [
+                (b"content-type", b"application/json"),
+                (b"content-length", str(len(body)).encode()),
+                (b"www-authenticate", b'Bearer realm="mcp"'),
+            ],
+        })
+        await send({"type": "http.response.body", "body": body})
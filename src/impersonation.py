@@ -0,0 +1,152 @@
+"""按调用方身份访问集群（impersonation）。
+
+多用户场景下需要以调用方而非服务自身的身份访问集群，使 RBAC 与审计日志按实际用户生效：启用 --allow-impersonation
+后，基于 kubectl 的工具额外接受 impersonate_user 与 impersonate_groups 参数。ImpersonationMiddleware 在调用期间
+记录该身份，所有 kubectl 调用附加 --as/--as-group，由 API Server 按该用户鉴权。
+
+服务自身使用的身份（kubeconfig 中的用户或 ServiceAccount）需具备 impersonate 权限，否则返回 Forbidden 及所需的授权说明。
+"""
+
+import shlex
+from contextvars import ContextVar
+from typing import Any, List, Optional, Sequence, Tuple
+
+import mcp.types as mt
+from fastmcp.exceptions import ToolError
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from loguru import logger
+
+IMPERSONATE_USER_ARG = "impersonate_user"
+IMPERSONATE_GROUPS_ARG = "impersonate_groups"
+
+IMPERSONATION_SCHEMAS = {
+    IMPERSONATE_USER_ARG: {
+        "type": "string",
+        "description": "以该用户身份访问集群（kubectl --as），RBAC 按该用户鉴权，如 alice@example.com、"
+                       "system:serviceaccount:dev:deployer",
+    },
+    IMPERSONATE_GROUPS_ARG: {
+        "type": "array",
+        "items": {"type": "string"},
+        "description": "以该用户身份访问时所属的用户组（kubectl --as-group），需同时指定 impersonate_user",
+    },
+}
+
+# 通过 kubectl 访问集群的工具：kubectl_ 前缀的工具及以下工具
+KUBECTL_TOOL_PREFIX = "kubectl_"
+KUBECTL_TOOLS = ("ack_kubectl", "list_namespaces", "list_crds", "cluster_summary")
+
+# ack_kubectl 命令中指定身份的参数，impersonation 时不允许自行指定
+_IDENTITY_FLAGS = ("--as", "--as-group", "--as-uid", "--user", "--token")
+
+# 当前调用的身份：(用户, 用户组)
+impersonation_ctx: ContextVar[Optional[Tuple[str, Tuple[str, ...]]]] = ContextVar("impersonation", default=None)
+
+
+def current_impersonation() -> Optional[Tuple[str, Tuple[str, ...]]]:
+    """返回当前调用的 (用户, 用户组)，未指定时返回 None"""
+    return impersonation_ctx.get()
+
+
+def impersonation_args() -> List[str]:
+    """当前调用需附加的 kubectl 身份参数"""
+    identity = impersonation_ctx.get()
+    if identity is None:
+        return []
+    user, groups = identity
+    return [f"--as={user}", *(f"--as-group={group}" for group in groups)]
+
+
+def impersonation_cache_key(key: str) -> str:
+    """按当前身份区分缓存键，避免不同用户共享缓存的结果"""
+    identity = impersonation_ctx.get()
+    if identity is None:
+        return key
+    user, groups = identity
+    return f"{key}#as={user}" + "".join(f"&group={group}" for group in sorted(groups))
+
+
+def is_kubectl_tool(tool: str) -> bool:
+    """工具是否通过 kubectl 访问集群（OpenAPI 类工具不支持 impersonation）"""
+    return tool.startswith(KUBECTL_TOOL_PREFIX) or tool in KUBECTL_TOOLS
+
+
+def parse_impersonation(user: Any, groups: Any) -> Tuple[str, Tuple[str, ...]]:
+    """校验 impersonation 参数，返回 (用户, 用户组)
+
+    Raises:
+        ValueError: 参数不合法
+    """
+    if not isinstance(user, str) or not user.strip():
+        if groups:
+            raise ValueError(f"{IMPERSONATE_GROUPS_ARG} requires {IMPERSONATE_USER_ARG}")
+        raise ValueError(f"{IMPERSONATE_USER_ARG} must be a non-empty string")
+    if groups is None:
+        groups = []
+    elif isinstance(groups, str):
+        groups = groups.split(",")
+    if not isinstance(groups, list) or not all(isinstance(group, str) for group in groups):
+        raise ValueError(f"{IMPERSONATE_GROUPS_ARG} must be a list of group names")
+    names: List[str] = []
+    for group in groups:
+        group = group.strip()
+        if group and group not in names:
+            names.append(group)
+    return user.strip(), tuple(names)
+
+
+def command_identity_flags(command: str) -> List[str]:
+    """提取 kubectl 命令中指定身份的参数（--as、--user、--token 等）"""
+    try:
+        tokens = shlex.split(command)
+    except ValueError:
+        tokens = command.split()
+    return [token.split("=", 1)[0] for token in tokens if token.split("=", 1)[0] in _IDENTITY_FLAGS]
+
+
+class ImpersonationMiddleware(Middleware):
+    """处理工具调用中的 impersonate_user/impersonate_groups 参数"""
+
+    async def on_list_tools(self, context: MiddlewareContext[mt.ListToolsRequest], call_next: CallNext) -> Sequence[Any]:
+        tools = await call_next(context)
+        return [self._with_impersonation_parameters(tool) for tool in tools]
+
+    @staticmethod
+    def _with_impersonation_parameters(tool: Any) -> Any:
+        """为基于 kubectl 的工具声明 impersonation 参数"""
+        parameters = getattr(tool, "parameters", None) or {}
+        properties = parameters.get("properties") or {}
+        if not is_kubectl_tool(getattr(tool, "name", "")) or IMPERSONATE_USER_ARG in properties:
+            return tool
+        parameters = {**parameters, "properties": {**properties, **IMPERSONATION_SCHEMAS}}
+        return tool.model_copy(update={"parameters": parameters})
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        tool = context.message.name
+        arguments = dict(context.message.arguments or {})
+        user = arguments.pop(IMPERSONATE_USER_ARG, None)
+        groups = arguments.pop(IMPERSONATE_GROUPS_ARG, None)
+        if user is None and not groups:
+            return await call_next(context)
+        if not is_kubectl_tool(tool):
+            raise ToolError(f"{tool} does not access the cluster through kubectl and does not support impersonation")
+        try:
+            identity = parse_impersonation(user, groups)
+        except ValueError as e:
+            raise ToolError(str(e))
+        if tool == "ack_kubectl":
+            flags = command_identity_flags(str(arguments.get("command") or ""))
+            if flags:
+                raise ToolError(f"{', '.join(flags)} cannot be used in the command together with {IMPERSONATE_USER_ARG}")
+
+        logger.info(f"Calling {tool} as user {identity[0]} (groups: {', '.join(identity[1]) or '<none>'})")
+        message = context.message.model_copy(update={"arguments": arguments})
+        token = impersonation_ctx.set(identity)
+        try:
+            return await call_next(context.copy(message=message))
+        finally:
+            impersonation_ctx.reset(token)
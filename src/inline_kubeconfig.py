@@ -0,0 +1,176 @@
+"""按请求传入的 kubeconfig。
+
+多租户网关场景下凭据由调用方持有：启用 --allow-inline-kubeconfig 后，带有 cluster_id 参数的工具额外接受
+kubeconfig_base64 参数。InlineKubeconfigMiddleware 在调用前解码并校验 kubeconfig、确认 API Server 可访问，
+将其写入仅存在于内存中的 memfd（不落盘），在本次调用期间替代按集群 ID 获取的 kubeconfig，调用结束即关闭释放，不做缓存。
+"""
+
+import base64
+import binascii
+import os
+import tempfile
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Any, Dict, Iterator, Optional, Sequence
+
+import mcp.types as mt
+import yaml
+from fastmcp.exceptions import ToolError
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from loguru import logger
+
+from models import ExecutionLog
+
+INLINE_KUBECONFIG_ARG = "kubeconfig_base64"
+
+INLINE_KUBECONFIG_SCHEMA = {
+    "type": "string",
+    "description": "Base64 编码的 kubeconfig，指定时本次调用使用该 kubeconfig 访问集群（不落盘、不缓存），"
+                   "cluster_id 仅作标识；证书与 token 需内联（*-data、token），不支持引用文件或 exec 插件",
+}
+
+# 引用服务端本地文件的字段，调用方传入的 kubeconfig 不允许使用
+_FILE_REFERENCE_KEYS = ("certificate-authority", "client-certificate", "client-key", "tokenFile")
+
+# memfd 不可用时存放 kubeconfig 的内存文件系统
+SHM_DIR = "/dev/shm"
+
+# 当前调用使用的内存 kubeconfig 路径
+inline_kubeconfig_ctx: ContextVar[Optional[str]] = ContextVar("inline_kubeconfig", default=None)
+
+
+def current_inline_kubeconfig() -> Optional[str]:
+    """返回当前调用传入的 kubeconfig 路径，未传入时返回 None"""
+    return inline_kubeconfig_ctx.get()
+
+
+def decode_kubeconfig(value: str, context: Optional[str] = None) -> Dict[str, Any]:
+    """解码并校验 base64 编码的 kubeconfig，指定 context 时将其设为 current-context
+
+    Raises:
+        ValueError: 编码或内容不合法
+    """
+    try:
+        raw = base64.b64decode("".join(value.split()), validate=True)
+        config = yaml.safe_load(raw.decode("utf-8"))
+    except (binascii.Error, UnicodeDecodeError) as e:
+        raise ValueError(f"{INLINE_KUBECONFIG_ARG} is not valid base64-encoded text: {e}")
+    except yaml.YAMLError as e:
+        raise ValueError(f"{INLINE_KUBECONFIG_ARG} is not a valid kubeconfig: {e}")
+    if not isinstance(config, dict):
+        raise ValueError(f"{INLINE_KUBECONFIG_ARG} is not a valid kubeconfig: expected a mapping")
+
+    sections = {}
+    for section in ("clusters", "contexts", "users"):
+        entries = config.get(section) or []
+        if not isinstance(entries, list) or not entries:
+            raise ValueError(f"{INLINE_KUBECONFIG_ARG} is not a valid kubeconfig: no {section} defined")
+        sections[section] = {e.get("name"): e.get(section[:-1]) or {} for e in entries if isinstance(e, dict)}
+
+    context = context or config.get("current-context")
+    if not context:
+        raise ValueError(f"{INLINE_KUBECONFIG_ARG} has no current-context, specify the context parameter")
+    if context not in sections["contexts"]:
+        available = ", ".join(sorted(str(name) for name in sections["contexts"] if name)) or "<none>"
+        raise ValueError(f"Context '{context}' not found in {INLINE_KUBECONFIG_ARG}, available contexts: {available}")
+    selected = sections["contexts"][context]
+    cluster = sections["clusters"].get(selected.get("cluster"))
+    if not cluster or not cluster.get("server"):
+        raise ValueError(f"Cluster '{selected.get('cluster')}' of context '{context}' has no server address")
+    if selected.get("user") not in sections["users"]:
+        raise ValueError(f"User '{selected.get('user')}' of context '{context}' not found in {INLINE_KUBECONFIG_ARG}")
+
+    for section in ("clusters", "users"):
+        for name, body in sections[section].items():
+            for key in _FILE_REFERENCE_KEYS:
+                if body.get(key):
+                    raise ValueError(f"{section[:-1]} '{name}' references local file via {key}, use {key}-data instead")
+            if section == "users" and (body.get("exec") or body.get("auth-provider")):
+                raise ValueError(f"user '{name}' uses an exec/auth-provider plugin, which is not supported")
+
+    config["current-context"] = context
+    return config
+
+
+@contextmanager
+def memory_kubeconfig(config: Dict[str, Any]) -> Iterator[str]:
+    """将 kubeconfig 写入 memfd，返回 kubectl 子进程可读取的路径，退出时关闭
+
+    不支持 memfd_create 时退回到 tmpfs（/dev/shm）中创建后立即删除的文件，同样只存在于内存且没有可见的文件名。
+    """
+    if hasattr(os, "memfd_create"):
+        fd = os.memfd_create("mcp-inline-kubeconfig", os.MFD_CLOEXEC)
+    elif os.path.isdir(SHM_DIR):
+        fd, path = tempfile.mkstemp(prefix="mcp-inline-kubeconfig-", dir=SHM_DIR)
+        os.unlink(path)
+    else:
+        raise ToolError(f"{INLINE_KUBECONFIG_ARG} requires memfd or {SHM_DIR} support, which is unavailable on this server")
+    try:
+        os.write(fd, yaml.safe_dump(config, sort_keys=False).encode("utf-8"))
+        # 子进程通过本进程的 fd 路径读取，无需继承文件描述符
+        yield f"/proc/{os.getpid()}/fd/{fd}"
+    finally:
+        os.close(fd)
+
+
+class InlineKubeconfigMiddleware(Middleware):
+    """处理工具调用中的 kubeconfig_base64 参数"""
+
+    def __init__(self, runner: Any, timeout: int = 10):
+        self.runner = runner
+        self.timeout = timeout
+
+    async def on_list_tools(self, context: MiddlewareContext[mt.ListToolsRequest], call_next: CallNext) -> Sequence[Any]:
+        tools = await call_next(context)
+        return [self._with_inline_parameter(tool) for tool in tools]
+
+    @staticmethod
+    def _with_inline_parameter(tool: Any) -> Any:
+        """为带有 cluster_id 参数的工具声明 kubeconfig_base64 参数"""
+        parameters = getattr(tool, "parameters", None) or {}
+        properties = parameters.get("properties") or {}
+        if "cluster_id" not in properties or INLINE_KUBECONFIG_ARG in properties:
+            return tool
+        parameters = {**parameters, "properties": {**properties, INLINE_KUBECONFIG_ARG: INLINE_KUBECONFIG_SCHEMA}}
+        return tool.model_copy(update={"parameters": parameters})
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        arguments = dict(context.message.arguments or {})
+        value = arguments.pop(INLINE_KUBECONFIG_ARG, None)
+        if value is None:
+            return await call_next(context)
+        if not isinstance(value, str) or not value.strip():
+            raise ToolError(f"{INLINE_KUBECONFIG_ARG} must be a non-empty base64 string")
+        requested_context = arguments.get("context")
+        try:
+            config = decode_kubeconfig(value, requested_context if isinstance(requested_context, str) else None)
+        except ValueError as e:
+            raise ToolError(str(e))
+
+        message = context.message.model_copy(update={"arguments": arguments})
+        with memory_kubeconfig(config) as path:
+            await self._check_reachable(path, config)
+            token = inline_kubeconfig_ctx.set(path)
+            try:
+                return await call_next(context.copy(message=message))
+            finally:
+                inline_kubeconfig_ctx.reset(token)
+
+    async def _check_reachable(self, path: str, config: Dict[str, Any]):
+        """访问 API Server /version，确认传入的 kubeconfig 可用"""
+        execution_log = ExecutionLog(tool_call_id="inline_kubeconfig", tool_name="inline_kubeconfig")
+        result = await self.runner.run(path, ["get", "--raw", "/version"], execution_log, timeout=self.timeout)
+        if result["exit_code"] != 0:
+            contexts = {c.get("name"): c.get("context") or {} for c in config.get("contexts") or []}
+            cluster_name = contexts[config["current-context"]].get("cluster")
+            server = next((c.get("cluster", {}).get("server") for c in config.get("clusters") or []
+                           if c.get("name") == cluster_name), None)
+            logger.warning(f"Inline kubeconfig API server {server} check failed: {result['stderr']}")
+            raise ToolError(
+                f"API server {server} from {INLINE_KUBECONFIG_ARG} is unreachable or rejected the credentials: "
+                f"{result['stderr'] or 'kubectl exited with code ' + str(result['exit_code'])}"
+            )
@@ -0,0 +1,2182 @@
+"""Kubectl Analysis Handler - 基于 kubectl 的集群诊断分析工具."""
+
+import asyncio
+import json
+import re
+import uuid
+import yaml
+from typing import Dict, Any, Optional, List, Tuple
+from fastmcp import FastMCP, Context
+from loguru import logger
+from pydantic import Field
+from datetime import datetime, timezone
+from ack_cluster_handler import _fetch_nodepools_list, _get_cs_client, _serialize_sdk_object
+from kubectl_helpers import (
+    binding_grants_service_account,
+    classify_admission_denial,
+    docker_config_auths,
+    endpoint_slice_entries,
+    endpoints_entries,
+    event_time,
+    extract_error_lines,
+    format_bytes,
+    format_cpu,
+    format_event,
+    hostname_matches,
+    inspect_tls_secret,
+    is_builtin_default_change,
+    is_exec_binary_missing,
+    json_diff,
+    label_selector_matches,
+    max_kubelet_minor_skew,
+    object_references,
+    parse_df_output,
+    parse_dig_output,
+    parse_image_reference,
+    parse_k8s_version,
+    parse_nslookup_output,
+    parse_quantity,
+    pod_ephemeral_storage,
+    pod_owner,
+    pod_problem,
+    pod_resource_requests,
+    pod_restart_count,
+    pod_template_spec,
+    rbac_rule_risks,
+    selector_matches,
+    strip_server_fields,
+    unhealthy_containers,
+    validate_dns_query,
+    validate_label_selector,
+    DNS_RECORD_TYPES,
+    SERVICE_NAME_LABEL,
+)
+from kubectl_resources import DEFAULT_NAMESPACE, namespace_args, normalize_namespace
+from kubectl_runner import KubectlRunner, KubectlCommandError, finish_execution_log, start_execution_log
+from registry_client import RegistryClient
+from models import (
+    AdmissionDenialGroup,
+    AdmissionDenialsOutput,
+    AddonStatus,
+    AddonStatusOutput,
+    AddonWorkloadStatus,
+    ContainerDiskUsageOutput,
+    ContainerFilesystemUsage,
+    CrossNamespaceReference,
+    CrossNamespaceReferencesOutput,
+    DnsCheckOutput,
+    DnsRecord,
+    EffectivePermission,
+    EphemeralStorageNode,
+    EphemeralStoragePodRisk,
+    EphemeralStorageRiskOutput,
+    ErrorModel,
+    ExecutionLog,
+    ImagePullCheck,
+    ImagePullabilityOutput,
+    IngressTLSInfo,
+    IngressTLSSecretStatus,
+    IngressTLSSummaryOutput,
+    LogRateEntry,
+    LogRateOutput,
+    NodeBalanceEntry,
+    NodeBalanceOutput,
+    NodeBalanceSummary,
+    NodePoolVersionSkew,
+    ObjectChange,
+    ServiceEndpoint,
+    ServiceEndpointsOutput,
+    ContainerProblem,
+    UnhealthyPod,
+    UnhealthyPodsOutput,
+    SpotNode,
+    SpotRiskOutput,
+    SpotWorkloadRisk,
+    VersionSkewOutput,
+    WebhookMutationOutput,
+    WorkloadPermissionsOutput,
+)
+
+
+# 常见的 ACK 托管组件及其工作负载名称前缀
+DEFAULT_ACK_ADDONS = [
+    "cluster-autoscaler",
+    "ack-node-problem-detector",
+    "metrics-server",
+    "coredns",
+    "storage-operator",
+    "csi-provisioner",
+    "csi-plugin",
+    "ack-cluster-agent",
+    "alicloud-monitor-controller",
+    "ack-koordinator",
+]
+
+
+# 未指定执行 Pod 时，kubectl_dns_check 创建临时 Pod 所用的镜像（需包含 dig）
+DEFAULT_DNS_CHECK_IMAGE = "registry.k8s.io/e2e-test-images/jessie-dnsutils:1.3"
+
+
+# ACK 节点上标识所属节点池的标签
+NODEPOOL_ID_LABEL = "alibabacloud.com/nodepool-id"
+
+# 标识抢占式（Spot）实例的节点标签/注解及取值
+SPOT_NODE_MARKERS = [
+    ("node.alibabacloud.com/spot-instance", "true"),
+    ("alibabacloud.com/spot-instance", "true"),
+    ("node.kubernetes.io/capacity-type", "spot"),
+    ("karpenter.sh/capacity-type", "spot"),
+]
+
+# 节点状况、污点或事件中表示实例即将被回收的关键字
+_RECLAIM_SIGNAL_RE = re.compile(r"preempt|interrupt|terminat|reclaim|release", re.IGNORECASE)
+
+# kubectl_log_rate 允许的最长采样时长（秒）
+MAX_LOG_SAMPLE_SECONDS = 60
+
+# kubectl_cross_namespace_refs 建立名称索引的被引用对象类型
+_NAME_INDEXED_KINDS = {"ConfigMap", "Secret", "PersistentVolumeClaim", "ServiceAccount", "Service"}
+
+# kubectl_unhealthy_pods 默认的重启次数阈值
+DEFAULT_RESTART_THRESHOLD = 5
+
+# 带 Pod 模板的工作负载类型
+_POD_TEMPLATE_KINDS = {"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob"}
+
+
+class KubectlAnalysisHandler:
+    """Handler for kubectl based diagnostic analysis."""
+
+    def __init__(self, server: FastMCP, settings: Optional[Dict[str, Any]] = None):
+        """Initialize the kubectl analysis handler.
+
+        Args:
+            server: FastMCP server instance
+            settings: Configuration settings
+        """
+        self.settings = settings or {}
+
+        # 是否可写变更配置
+        self.allow_write = self.settings.get("allow_write", False)
+
+        # Per-handler toggle
+        self.enable_execution_log = self.settings.get("enable_execution_log", False)
+
+        # 未指定命名空间时使用的命名空间，由 main_server 按 --default-namespace 解析
+        self.default_namespace = self.settings.get("default_namespace") or DEFAULT_NAMESPACE
+
+        # kubectl 执行器
+        self.runner = KubectlRunner(self.settings)
+
+        # 镜像仓库客户端
+        self.registry = RegistryClient()
+
+        if server is None:
+            return
+        self.server = server
+
+        self.server.tool(
+            name="kubectl_ingress_tls",
+            description="""汇总 Ingress 的 TLS 证书来源及状态。
+
+## 使用场景
+- 排查 HTTPS 访问异常：Ingress 引用的 TLS Secret 不存在、证书过期或即将过期
+- 核对证书 SAN 是否覆盖 Ingress 中声明的主机名
+
+## 注意事项
+- 仅读取 Secret 中的 tls.crt（公开证书），不会返回 tls.key
+- status 取值：OK、Expiring（剩余天数小于 expiring_days）、Expired、Missing（Secret 不存在）、Invalid（证书无法解析）、DefaultCertificate（未指定 secretName）、Unknown（Secret 读取失败）
+"""
+        )(self.kubectl_ingress_tls)
+
+        self.server.tool(
+            name="kubectl_node_balance",
+            description="""分析节点间的 Pod 分布与资源承诺（requests）是否均衡。
+
+## 使用场景
+- 大规模集群中排查热点节点：部分节点接近 maxPods 或 requests 接近满载，而其他节点几乎空闲
+- 评估是否需要通过调度策略调整或 descheduler 进行再平衡
+
+## 注意事项
+- 仅统计非 Succeeded/Failed 状态的 Pod；不可调度（cordon）的节点会列出但不参与均衡统计
+- flags 取值：NearMaxPods（Pod 数占比 >= 90%）、NearlyEmpty（Pod 数占比 <= 10%）、HighCommitment（CPU 或内存 requests 比例 >= 90%）、Unschedulable
+- 当 Pod 数占比或 requests 比例的最大最小差值超过 imbalance_threshold，或同时存在 NearMaxPods 与 NearlyEmpty 节点时，判定为不均衡
+"""
+        )(self.kubectl_node_balance)
+
+        self.server.tool(
+            name="kubectl_container_df",
+            description="""通过 exec 在容器内执行 `df -P -k`，返回容器内各文件系统的磁盘使用情况。
+
+## 使用场景
+- 排查容器磁盘写满、ephemeral-storage 超限导致的驱逐或写入失败
+- 查看容器根文件系统（overlay）、emptyDir、PVC 挂载点的使用率
+
+## 注意事项
+- 仅执行固定的只读命令 `df -P -k`，不受只读模式限制
+- 对于不包含 df 的镜像（如 distroless），返回 error_code 为 DfUnavailable，可改用 kubectl debug 临时容器或节点侧 kubelet 指标排查
+"""
+        )(self.kubectl_container_df)
+
+        self.server.tool(
+            name="kubectl_addon_status",
+            description="""查看 ACK 托管组件（如 cluster-autoscaler、ack-node-problem-detector）的运行与调谐状态。
+
+## 使用场景
+- 确认托管组件是否正常工作：工作负载就绪情况、未就绪 Pod 及原因、重启次数
+- 汇总组件相关的近期 Warning 事件与日志中的错误行，无需手动翻查日志
+
+## 注意事项
+- 默认检查 kube-system 命名空间下的常见 ACK 组件，可通过 addons 指定组件名称（按工作负载名称前缀匹配）
+- 每个工作负载仅读取一个 Pod（优先未就绪或重启次数最多的 Pod）最近 log_since 时间内的日志
+- status 取值：Healthy、Degraded（部分副本未就绪或存在异常 Pod）、Unavailable（无就绪副本）、NotInstalled
+"""
+        )(self.kubectl_addon_status)
+
+        self.server.tool(
+            name="kubectl_workload_permissions",
+            description="""汇总工作负载的有效 RBAC 权限（反向 can-i：这个 Pod 能做什么）。
+
+## 使用场景
+- 安全审计：解析工作负载使用的 ServiceAccount，以及绑定到它的 Role/ClusterRole，给出有效的 verbs × resources
+- 识别高风险权限：读取 Secret、exec 进入 Pod、创建 Pod、提权（escalate/bind）、模拟身份、通配符权限等
+
+## 注意事项
+- 会同时考虑直接绑定 ServiceAccount 的 subject，以及 system:serviceaccounts、system:serviceaccounts:<namespace>、system:authenticated 组
+- 结果基于 RBAC 对象静态解析，不包含 Webhook 等其他授权模块的决策
+- workload_type 支持 pod、deployment、statefulset、daemonset、replicaset、job、cronjob
+"""
+        )(self.kubectl_workload_permissions)
+
+        self.server.tool(
+            name="kubectl_dns_check",
+            description="""在集群内执行 DNS 解析测试，返回解析记录、响应的 DNS 服务器与查询耗时。
+
+## 使用场景
+- 排查集群内 DNS 问题：Service 域名解析失败（NXDOMAIN）、CoreDNS 超时、解析偶发失败导致的连接不稳定
+- 验证 Pod 所在网络环境下的 search 域解析行为（如短域名 my-svc、my-svc.my-ns）
+
+## 注意事项
+- 指定 pod 时通过 exec 在该 Pod 内执行 dig（不存在时回退 nslookup），不受只读模式限制
+- 未指定 pod 时会在 namespace 中创建临时 dnsutils Pod 执行查询并在结束后删除，需要开启 --allow-write
+- dig 使用 +search，会按 Pod 的 /etc/resolv.conf 的 search 域补全短域名
+"""
+        )(self.kubectl_dns_check)
+
+        self.server.tool(
+            name="kubectl_webhook_mutations",
+            description="""通过 server 端 dry-run 提交对象，对比提交内容与返回结果，展示 Mutating Webhook（如 sidecar 注入、默认值填充）所做的修改。
+
+## 使用场景
+- 回答"为什么我的 Pod 多了容器/环境变量/注解"：查看 sidecar 注入器等 webhook 的实际修改
+- 上线前预览某个 manifest 提交后会被 webhook 改成什么样
+
+## 注意事项
+- 提供 manifest（YAML/JSON）时执行 dry-run create；否则读取已有对象：
+  对带 Pod 模板的工作负载（Deployment 等），默认用其 Pod 模板构造 Pod 执行 dry-run create，以展示 Pod 创建时的注入；
+  其他对象执行 dry-run replace，展示更新时的修改
+- dry-run 不会持久化对象，不受只读模式限制，但需要对应资源的 create/update 权限；sideEffects 不为 None/NoneOnDryRun 的 webhook 不会在 dry-run 中调用
+- 默认忽略常见的内置默认值填充（如 imagePullPolicy、terminationMessagePath、kube-api-access token 卷），可通过 include_defaults 显示
+"""
+        )(self.kubectl_webhook_mutations)
+
+        self.server.tool(
+            name="kubectl_ephemeral_storage_risk",
+            description="""评估节点临时存储（ephemeral-storage）压力风险，找出可能因 emptyDir、容器日志或可写层增长触发 DiskPressure 驱逐的 Pod。
+
+## 使用场景
+- 稳定性巡检：提前发现接近 DiskPressure 的节点，以及这些节点上未设置 ephemeral-storage limit 的 Pod
+- 排查 Pod 被 "The node was low on resource: ephemeral-storage" 驱逐的原因
+
+## 注意事项
+- 节点用量通过 kubelet stats/summary（nodes/proxy）获取，无权限或获取失败时仅基于 requests 与 DiskPressure 状况判断
+- 风险节点判定：DiskPressure 为 True、根文件系统使用率 >= pressure_percent，或 ephemeral-storage requests 占可分配量 >= 90%
+- medium=Memory 的 emptyDir 计入内存，不计入磁盘风险
+"""
+        )(self.kubectl_ephemeral_storage_risk)
+
+        self.server.tool(
+            name="kubectl_cross_namespace_refs",
+            description="""检查 Kubernetes 不支持（或需要额外授权）的跨命名空间引用。
+
+## 使用场景
+- 部署审计：Ingress 后端 Service、TLS Secret，工作负载引用的 ConfigMap/Secret/PVC/ServiceAccount 只能位于同一命名空间，
+  引用其他命名空间中的同名对象会导致静默失败（503、Pod 卡在 ContainerCreating 等）
+- 一次性找出所有此类配置错误
+
+## 注意事项
+- 检查 Deployment、StatefulSet、DaemonSet、CronJob、Ingress、PVC
+- issue 取值：
+  - FoundInOtherNamespace：当前命名空间中不存在被引用对象，但其他命名空间存在同名对象
+  - NamespaceQualifiedName：名称中带命名空间（如 svc.other-ns、other-ns/svc），Kubernetes 会将其视为字面名称
+  - ExplicitCrossNamespace：显式指定了其他命名空间（如 PVC dataSourceRef.namespace），需要 CrossNamespaceVolumeDataSource 特性门控及 ReferenceGrant
+- 被引用对象在所有命名空间都不存在、或引用标记为 optional 时不在此报告
+"""
+        )(self.kubectl_cross_namespace_refs)
+
+        self.server.tool(
+            name="kubectl_version_skew",
+            description="""结合 CS 节点池信息与 Kubernetes 节点列表，检查各节点池 kubelet 版本与控制面版本的偏差。
+
+## 使用场景
+- 集群升级前后检查：哪些节点池仍停留在旧版本、是否超出 Kubernetes 支持的版本偏差
+- 排查节点池升级中断导致的节点版本不一致
+
+## 注意事项
+- 控制面版本取自 kube-apiserver /version；节点按标签 alibabacloud.com/nodepool-id 归属节点池，节点池名称与状态来自 CS DescribeClusterNodePools
+- kubelet 允许落后 kube-apiserver 的次版本数：1.28 及以上为 3，之前为 2；kubelet 不允许比 kube-apiserver 新
+- status 取值：OK、Behind（落后但在支持范围内，建议升级节点池）、UnsupportedSkew、NewerThanControlPlane
+"""
+        )(self.kubectl_version_skew)
+
+        self.server.tool(
+            name="kubectl_spot_risk",
+            description="""列出运行在抢占式（Spot）实例上的工作负载，评估实例回收带来的可用性风险。
+
+## 使用场景
+- 成本优化后的韧性检查：哪些关键工作负载完全运行在抢占式实例上、是否为单副本、是否缺少 PodDisruptionBudget
+- 发现已收到回收通知的节点以及其上的工作负载
+
+## 注意事项
+- 默认通过以下节点标签或注解识别抢占式实例：node.alibabacloud.com/spot-instance=true、alibabacloud.com/spot-instance=true、
+  node.kubernetes.io/capacity-type=spot、karpenter.sh/capacity-type=spot；也可通过 spot_selector 指定节点标签选择器
+- 回收通知来自节点状况（status=True）、污点以及近期的 Node 事件中包含 Preempt/Interrupt/Terminat/Reclaim/Release 等关键字的条目
+- DaemonSet Pod 不参与统计
+- risk：High（已收到回收通知，或单副本/全部副本位于抢占式实例且无 PDB）、Medium（全部副本位于抢占式实例或无 PDB）、Low
+"""
+        )(self.kubectl_spot_risk)
+
+        self.server.tool(
+            name="kubectl_log_rate",
+            description="""对 Pod 日志进行短时间采样（logs -f 持续 sample_seconds 秒），统计各容器的日志输出字节数与行数，估算日志产生速率。
+
+## 使用场景
+- 找出日志量过大的工作负载，评估其对 SLS 采集与存储成本的影响
+- 对比调整日志级别前后的日志输出速率
+
+## 注意事项
+- 指定 pod 或 label_selector 其一；按 label_selector 时所有 Pod 在同一时间窗口内并发采样，最多 max_pods 个
+- 仅统计采样窗口内新产生的日志（--tail=0），sample_seconds 最大 60 秒；每日估算按当前速率线性外推，仅供参考
+"""
+        )(self.kubectl_log_rate)
+
+        self.server.tool(
+            name="kubectl_image_pullability",
+            description="""在不调度 Pod 的情况下，通过镜像仓库 Registry v2 manifest 接口检查工作负载引用的镜像是否可拉取。
+
+## 使用场景
+- 发布前检查：发现镜像 tag 拼写错误、仓库不存在或 imagePullSecret 无权限，避免上线后出现 ImagePullBackOff
+- 排查 ImagePullBackOff：区分镜像不存在（NotFound）与认证失败（Unauthorized）
+
+## 注意事项
+- 检查命名空间内的 Deployment、StatefulSet、DaemonSet、CronJob（含 init 容器），或通过 workload_type/workload_name 指定单个工作负载
+- 认证使用工作负载及其 ServiceAccount 的 imagePullSecrets（dockerconfigjson），支持匿名、Basic 与 Token（Bearer）认证，适用于 ACR 个人版/企业版及标准 Docker Registry v2
+- 检查从 MCP Server 所在网络发起：ACR VPC 域名（-vpc.）在 VPC 外不可达；节点通过 ACR 免密插件等节点侧凭据拉取的镜像可能显示为 Unauthorized
+- Secret 内容仅用于认证，不会返回
+"""
+        )(self.kubectl_image_pullability)
+
+        self.server.tool(
+            name="kubectl_admission_denials",
+            description="""汇总 Warning 事件中的准入拒绝（Admission Webhook、ValidatingAdmissionPolicy、ResourceQuota、PodSecurity、LimitRange 等），按拒绝方分组。
+
+## 使用场景
+- 排查"Deployment 不创建 Pod"：控制器（ReplicaSet、Job、StatefulSet 等）创建 Pod 被拒绝时会记录 FailedCreate 事件
+- 快速定位阻塞发布的策略、配额或不可用的 webhook
+
+## 注意事项
+- 基于事件，仅覆盖事件保留期（默认 1 小时）内的拒绝；直接通过 kubectl apply 被拒绝的请求不会产生事件
+- category 取值：WebhookDenied（webhook 拒绝）、WebhookFailure（webhook 调用失败）、ValidatingAdmissionPolicy、ResourceQuota、PodSecurity、LimitRange、ServiceAccount、Forbidden（其他 forbidden 错误）
+"""
+        )(self.kubectl_admission_denials)
+
+        self.server.tool(
+            name="kubectl_service_endpoints",
+            description="""查看 Service 背后的端点：列出就绪与未就绪的地址、端口及对应的 Pod 和节点，没有就绪端点时说明可能的原因。
+
+## 使用场景
+- 排查 Service 返回 503、连接被拒绝：确认流量实际会被转发到哪些 Pod
+- 确认 Pod 未进入端点的原因：选择器不匹配、Pod 未就绪或 targetPort 配置错误
+
+## 注意事项
+- 基于 EndpointSlice（标签 kubernetes.io/service-name），集群不支持时回退为 Endpoints
+- 只有就绪（ready）的端点会接收流量；终止中的 Pod 可能仍为 serving
+- status 取值：Ready、NoReadyEndpoints（有端点但均未就绪）、NoEndpoints（没有任何端点）、ExternalName（无端点的 DNS 别名）
+"""
+        )(self.kubectl_service_endpoints)
+
+        self.server.tool(
+            name="kubectl_unhealthy_pods",
+            description=f"""扫描命名空间中的 Pod，找出容器异常的 Pod 并按严重程度排序：CrashLoopBackOff、OOMKilled、镜像拉取失败、容器创建失败、非零退出及重启次数过多。
+
+## 使用场景
+- 故障排查的第一步：一次调用代替逐个 kubectl_get/kubectl_describe，快速定位有问题的 Pod 与容器
+- 判断容器反复重启的原因：返回每个异常容器的 lastState.terminated.reason（如 OOMKilled、Error）、退出码及其常见含义
+
+## 注意事项
+- namespace=all 扫描全部命名空间；可通过 label_selector 限定范围，如 app=web
+- 重启次数达到 restart_threshold（默认 {DEFAULT_RESTART_THRESHOLD}）的容器即使当前正常运行也会列出，problem 为 HighRestarts
+- severity 取值：4 CrashLoopBackOff/OOMKilled，3 镜像拉取或容器创建失败，2 以非零退出码终止，1 仅重启次数过多
+- 已成功结束（Succeeded）的 Pod 不会列出；Pending 等调度问题请使用 kubectl_events 查看
+- 确定异常容器后，可调用 kubectl_logs（previous=true）查看崩溃前的日志
+"""
+        )(self.kubectl_unhealthy_pods)
+
+        logger.info("Kubectl Analysis Handler initialized")
+
+    @staticmethod
+    def _namespace_args(namespace: Optional[str]) -> List[str]:
+        return namespace_args(normalize_namespace(namespace))
+
+    async def kubectl_ingress_tls(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间"),
+        expiring_days: int = Field(30, description="剩余有效天数小于该值时标记为 Expiring"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> IngressTLSSummaryOutput:
+        """汇总 Ingress 引用的 TLS Secret 是否存在及证书有效期"""
+        execution_log, start_ms = start_execution_log("kubectl_ingress_tls", cluster_id, self.enable_execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            ingress_list = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "ingresses", *self._namespace_args(namespace), "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+
+            secret_cache: Dict[Tuple[str, str], Dict[str, Any]] = {}
+            ingresses: List[IngressTLSInfo] = []
+            for item in ingress_list.get("items", []):
+                metadata = item.get("metadata", {})
+                spec = item.get("spec", {})
+                tls_entries = spec.get("tls") or []
+                if not tls_entries:
+                    continue
+                ns = metadata.get("namespace") or namespace or self.default_namespace
+                statuses = []
+                for entry in tls_entries:
+                    statuses.append(
+                        await self._inspect_tls_entry(
+                            kubeconfig_path, ns, entry, expiring_days, timeout, secret_cache, execution_log
+                        )
+                    )
+                ingresses.append(IngressTLSInfo(
+                    namespace=ns,
+                    name=metadata.get("name", ""),
+                    ingress_class=spec.get("ingressClassName")
+                    or (metadata.get("annotations") or {}).get("kubernetes.io/ingress.class"),
+                    tls=statuses,
+                ))
+
+            finish_execution_log(execution_log, start_ms)
+            return IngressTLSSummaryOutput(
+                cluster_id=cluster_id,
+                namespace=namespace,
+                ingresses=ingresses,
+                count=len(ingresses),
+                execution_log=execution_log,
+            )
+        except Exception as e:
+            logger.error(f"Failed to summarize ingress TLS: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_ingress_tls")
+            return IngressTLSSummaryOutput(
+                cluster_id=cluster_id,
+                namespace=namespace,
+                error=ErrorModel(error_code="IngressTLSSummaryFailed", error_message=str(e)),
+                execution_log=execution_log,
+            )
+
+    async def _inspect_tls_entry(
+        self,
+        kubeconfig_path: str,
+        namespace: str,
+        entry: Dict[str, Any],
+        expiring_days: int,
+        timeout: int,
+        secret_cache: Dict[Tuple[str, str], Dict[str, Any]],
+        execution_log: ExecutionLog,
+    ) -> IngressTLSSecretStatus:
+        hosts = entry.get("hosts") or []
+        secret_name = entry.get("secretName")
+        if not secret_name:
+            return IngressTLSSecretStatus(
+                hosts=hosts,
+                status="DefaultCertificate",
+                message="secretName not set, the ingress controller default certificate is served",
+            )
+
+        key = (namespace, secret_name)
+        if key not in secret_cache:
+            secret_cache[key] = await self._inspect_secret(
+                kubeconfig_path, namespace, secret_name, expiring_days, timeout, execution_log
+            )
+        result = secret_cache[key]
+
+        dns_names = result.get("dns_names") or []
+        uncovered = [h for h in hosts if dns_names and not hostname_matches(h, dns_names)]
+        return IngressTLSSecretStatus(
+            secret_name=secret_name,
+            hosts=hosts,
+            exists=result.get("exists", False),
+            status=result["status"],
+            subject_cn=result.get("subject_cn"),
+            issuer_cn=result.get("issuer_cn"),
+            dns_names=dns_names,
+            not_before=result.get("not_before"),
+            not_after=result.get("not_after"),
+            days_until_expiry=result.get("days_until_expiry"),
+            uncovered_hosts=uncovered,
+            message=result.get("message"),
+        )
+
+    async def _inspect_secret(
+        self,
+        kubeconfig_path: str,
+        namespace: str,
+        secret_name: str,
+        expiring_days: int,
+        timeout: int,
+        execution_log: ExecutionLog,
+    ) -> Dict[str, Any]:
+        try:
+            secret = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "secret", secret_name, "-n", namespace, "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+        except KubectlCommandError as e:
+            if "NotFound" in e.stderr or "not found" in e.stderr:
+                return {"exists": False, "status": "Missing", "message": f"secret {namespace}/{secret_name} not found"}
+            return {"exists": True, "status": "Unknown", "message": str(e)}
+
+        result = inspect_tls_secret(secret, expiring_days=expiring_days)
+        result["exists"] = True
+        return result
+
+    async def kubectl_node_balance(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        node_selector: Optional[str] = Field(None, description="节点标签选择器，如 alibabacloud.com/nodepool-id=np-xxx，为空表示全部节点"),
+        imbalance_threshold: float = Field(0.5, description="Pod 数占比或 requests 比例的最大最小差值超过该值时判定为不均衡"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> NodeBalanceOutput:
+        """统计各节点 Pod 数与 requests 承诺，标记不均衡并给出再平衡建议"""
+        execution_log, start_ms = start_execution_log("kubectl_node_balance", cluster_id, self.enable_execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            node_args = ["get", "nodes", "-o", "json"]
+            if node_selector:
+                node_args[2:2] = ["-l", node_selector]
+            node_list = await self.runner.run_json(kubeconfig_path, node_args, execution_log, timeout=timeout)
+            pod_list = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "pods", "--all-namespaces",
+                 "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+
+            nodes, summary, suggestions = self._analyze_node_balance(
+                node_list.get("items", []), pod_list.get("items", []), imbalance_threshold
+            )
+            finish_execution_log(execution_log, start_ms)
+            return NodeBalanceOutput(
+                cluster_id=cluster_id,
+                nodes=nodes,
+                summary=summary,
+                suggestions=suggestions,
+                execution_log=execution_log,
+            )
+        except Exception as e:
+            logger.error(f"Failed to analyze node balance: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_node_balance")
+            return NodeBalanceOutput(
+                cluster_id=cluster_id,
+                error=ErrorModel(error_code="NodeBalanceAnalysisFailed", error_message=str(e)),
+                execution_log=execution_log,
+            )
+
+    @staticmethod
+    def _analyze_node_balance(
+        node_items: List[Dict[str, Any]],
+        pod_items: List[Dict[str, Any]],
+        imbalance_threshold: float,
+    ) -> Tuple[List[NodeBalanceEntry], NodeBalanceSummary, List[str]]:
+        usage: Dict[str, Dict[str, float]] = {}
+        for pod in pod_items:
+            node_name = (pod.get("spec") or {}).get("nodeName")
+            if not node_name:
+                continue
+            requests = pod_resource_requests(pod)
+            node_usage = usage.setdefault(node_name, {"pods": 0, "cpu": 0.0, "memory": 0.0})
+            node_usage["pods"] += 1
+            node_usage["cpu"] += requests.get("cpu", 0.0)
+            node_usage["memory"] += requests.get("memory", 0.0)
+
+        def ratio(used: float, total: float) -> float:
+            return round(used / total, 3) if total > 0 else 0.0
+
+        entries: List[NodeBalanceEntry] = []
+        for node in node_items:
+            name = node.get("metadata", {}).get("name", "")
+            allocatable = (node.get("status") or {}).get("allocatable") or {}
+            schedulable = not (node.get("spec") or {}).get("unschedulable", False)
+            node_usage = usage.get(name, {"pods": 0, "cpu": 0.0, "memory": 0.0})
+            max_pods = int(parse_quantity(allocatable.get("pods", 0)))
+            cpu_alloc = parse_quantity(allocatable.get("cpu", 0))
+            mem_alloc = parse_quantity(allocatable.get("memory", 0))
+            entry = NodeBalanceEntry(
+                name=name,
+                schedulable=schedulable,
+                pod_count=int(node_usage["pods"]),
+                max_pods=max_pods,
+                pod_utilization=ratio(node_usage["pods"], max_pods),
+                cpu_requests=format_cpu(node_usage["cpu"]),
+                cpu_allocatable=format_cpu(cpu_alloc),
+                cpu_request_ratio=ratio(node_usage["cpu"], cpu_alloc),
+                memory_requests=format_bytes(node_usage["memory"]),
+                memory_allocatable=format_bytes(mem_alloc),
+                memory_request_ratio=ratio(node_usage["memory"], mem_alloc),
+            )
+            if not schedulable:
+                entry.flags.append("Unschedulable")
+            if entry.pod_utilization >= 0.9:
+                entry.flags.append("NearMaxPods")
+            elif entry.pod_utilization <= 0.1:
+                entry.flags.append("NearlyEmpty")
+            if max(entry.cpu_request_ratio, entry.memory_request_ratio) >= 0.9:
+                entry.flags.append("HighCommitment")
+            entries.append(entry)
+        entries.sort(key=lambda e: e.pod_utilization, reverse=True)
+
+        candidates = [e for e in entries if e.schedulable]
+
+        def spread(values: List[float]) -> float:
+            return round(max(values) - min(values), 3) if values else 0.0
+
+        hot = [e.name for e in candidates if "NearMaxPods" in e.flags or "HighCommitment" in e.flags]
+        cold = [e.name for e in candidates if "NearlyEmpty" in e.flags]
+        summary = NodeBalanceSummary(
+            node_count=len(candidates),
+            total_pods=sum(e.pod_count for e in candidates),
+            avg_pod_utilization=round(sum(e.pod_utilization for e in candidates) / len(candidates), 3) if candidates else 0.0,
+            pod_utilization_spread=spread([e.pod_utilization for e in candidates]),
+            cpu_request_ratio_spread=spread([e.cpu_request_ratio for e in candidates]),
+            memory_request_ratio_spread=spread([e.memory_request_ratio for e in candidates]),
+        )
+        summary.imbalanced = len(candidates) > 1 and (
+            (bool(hot) and bool(cold))
+            or max(summary.pod_utilization_spread, summary.cpu_request_ratio_spread,
+                   summary.memory_request_ratio_spread) >= imbalance_threshold
+        )
+
+        suggestions: List[str] = []
+        if summary.imbalanced:
+            if hot and cold:
+                suggestions.append(
+                    f"节点 {', '.join(hot[:5])} 负载接近上限，而节点 {', '.join(cold[:5])} 几乎空闲，"
+                    "可使用 descheduler 的 LowNodeUtilization 策略或 ack-koordinator 重调度将 Pod 迁移到空闲节点"
+                )
+            if max(summary.cpu_request_ratio_spread, summary.memory_request_ratio_spread) >= imbalance_threshold:
+                suggestions.append(
+                    "requests 承诺在节点间差异较大，检查工作负载的 nodeSelector/亲和性是否将 Pod 集中到少数节点，"
+                    "并考虑为多副本工作负载配置 topologySpreadConstraints"
+                )
+            if summary.pod_utilization_spread >= imbalance_threshold:
+                suggestions.append(
+                    "Pod 数量在节点间差异较大，检查调度器打分策略（如 NodeResourcesFit 的 MostAllocated 会倾向于堆叠调度）"
+                )
+        unschedulable = [e.name for e in entries if not e.schedulable]
+        if unschedulable:
+            suggestions.append(f"节点 {', '.join(unschedulable[:5])} 处于不可调度状态，未参与均衡统计")
+        return entries, summary, suggestions
+
+    async def kubectl_container_df(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        pod: str = Field(..., description="Pod 名称"),
+        container: Optional[str] = Field(None, description="容器名称，为空表示 Pod 默认容器"),
+        warn_percent: float = Field(85, description="使用率超过该百分比的挂载点会加入 warnings"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="exec 超时（秒），默认 120 秒"),
+    ) -> ContainerDiskUsageOutput:
+        """在容器内执行 df 并解析为结构化的文件系统使用表"""
+        execution_log, start_ms = start_execution_log("kubectl_container_df", cluster_id, self.enable_execution_log)
+        output = ContainerDiskUsageOutput(
+            cluster_id=cluster_id,
+            namespace=namespace,
+            pod=pod,
+            container=container,
+            execution_log=execution_log,
+        )
+        try:
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            args = ["exec", pod, "-n", namespace]
+            if container:
+                args += ["-c", container]
+            args += ["--", "df", "-P", "-k"]
+            result = await self.runner.run(
+                kubeconfig_path, args, execution_log,
+                timeout=self.runner.resolve_timeout(timeout_seconds, "exec"),
+            )
+
+            rows = parse_df_output(result["stdout"])
+            if result["exit_code"] != 0 and not rows:
+                if is_exec_binary_missing(result["exit_code"], result["stderr"]):
+                    error = ErrorModel(
+                        error_code="DfUnavailable",
+                        error_message="df is not available in the container image (e.g. distroless); "
+                                      "use an ephemeral debug container or kubelet stats instead. "
+                                      f"kubectl: {result['stderr']}",
+                    )
+                else:
+                    error = ErrorModel(error_code="ExecFailed", error_message=result["stderr"])
+                finish_execution_log(execution_log, start_ms, RuntimeError(error.error_message), "exec")
+                output.error = error
+                return output
+
+            for row in rows:
+                output.filesystems.append(ContainerFilesystemUsage(
+                    filesystem=row["filesystem"],
+                    mount_point=row["mount_point"],
+                    size=format_bytes(row["size_bytes"]),
+                    used=format_bytes(row["used_bytes"]),
+                    available=format_bytes(row["available_bytes"]),
+                    use_percent=row["use_percent"],
+                    size_bytes=row["size_bytes"],
+                    used_bytes=row["used_bytes"],
+                    available_bytes=row["available_bytes"],
+                ))
+                if row["use_percent"] >= warn_percent:
+                    output.warnings.append(
+                        f"{row['mount_point']} ({row['filesystem']}) usage {row['use_percent']:.0f}% >= {warn_percent:.0f}%"
+                    )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"Failed to get container disk usage: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_container_df")
+            output.error = ErrorModel(error_code="ContainerDiskUsageFailed", error_message=str(e))
+            return output
+
+    async def kubectl_addon_status(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        addons: Optional[List[str]] = Field(None, description="组件名称列表，为空时检查常见 ACK 托管组件"),
+        namespace: str = Field("kube-system", description="组件所在命名空间"),
+        log_since: str = Field("1h", description="读取日志的时间范围，如 30m、1h"),
+        max_error_lines: int = Field(10, description="每个组件返回的最多错误日志行数"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> AddonStatusOutput:
+        """汇总 ACK 托管组件的工作负载健康度、Warning 事件与近期错误日志"""
+        execution_log, start_ms = start_execution_log("kubectl_addon_status", cluster_id, self.enable_execution_log)
+        addon_names = addons or DEFAULT_ACK_ADDONS
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            workloads = await self.runner.run_json(
+                kubeconfig_path, ["get", "deployments,daemonsets", "-n", namespace, "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            pods = await self.runner.run_json(
+                kubeconfig_path, ["get", "pods", "-n", namespace, "-o", "json"], execution_log, timeout=timeout,
+            )
+            events = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "events", "-n", namespace, "--field-selector=type=Warning", "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+
+            results: List[AddonStatus] = []
+            for addon in addon_names:
+                matched = [
+                    w for w in workloads.get("items", [])
+                    if w.get("metadata", {}).get("name", "") == addon
+                    or w.get("metadata", {}).get("name", "").startswith(addon + "-")
+                ]
+                results.append(await self._build_addon_status(
+                    kubeconfig_path, namespace, addon, matched, pods.get("items", []), events.get("items", []),
+                    log_since, max_error_lines, timeout, execution_log,
+                ))
+
+            finish_execution_log(execution_log, start_ms)
+            return AddonStatusOutput(
+                cluster_id=cluster_id, namespace=namespace, addons=results, execution_log=execution_log,
+            )
+        except Exception as e:
+            logger.error(f"Failed to get addon status: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_addon_status")
+            return AddonStatusOutput(
+                cluster_id=cluster_id,
+                namespace=namespace,
+                error=ErrorModel(error_code="AddonStatusFailed", error_message=str(e)),
+                execution_log=execution_log,
+            )
+
+    async def _build_addon_status(
+        self,
+        kubeconfig_path: str,
+        namespace: str,
+        addon: str,
+        workloads: List[Dict[str, Any]],
+        pods: List[Dict[str, Any]],
+        events: List[Dict[str, Any]],
+        log_since: str,
+        max_error_lines: int,
+        timeout: int,
+        execution_log: ExecutionLog,
+    ) -> AddonStatus:
+        if not workloads:
+            return AddonStatus(name=addon, status="NotInstalled")
+
+        status = AddonStatus(name=addon, status="Healthy")
+        related_names = set()
+        for workload in workloads:
+            kind = workload.get("kind", "")
+            name = workload.get("metadata", {}).get("name", "")
+            workload_status = workload.get("status") or {}
+            if kind == "DaemonSet":
+                entry = AddonWorkloadStatus(
+                    kind=kind, name=name,
+                    desired=workload_status.get("desiredNumberScheduled", 0),
+                    ready=workload_status.get("numberReady", 0),
+                    updated=workload_status.get("updatedNumberScheduled", 0),
+                )
+            else:
+                entry = AddonWorkloadStatus(
+                    kind=kind, name=name,
+                    desired=(workload.get("spec") or {}).get("replicas", 1),
+                    ready=workload_status.get("readyReplicas", 0),
+                    updated=workload_status.get("updatedReplicas", 0),
+                )
+            status.workloads.append(entry)
+            related_names.add(name)
+
+            selector = ((workload.get("spec") or {}).get("selector") or {}).get("matchLabels")
+            workload_pods = [p for p in pods if selector_matches(selector, p.get("metadata", {}).get("labels"))]
+            for pod in workload_pods:
+                pod_name = pod.get("metadata", {}).get("name", "")
+                related_names.add(pod_name)
+                status.restarts += pod_restart_count(pod)
+                problem = pod_problem(pod)
+                if problem:
+                    status.unhealthy_pods.append(f"{pod_name}: {problem}")
+
+            if workload_pods:
+                # 优先读取未就绪或重启次数最多的 Pod 日志
+                target = max(workload_pods, key=lambda p: (pod_problem(p) is not None, pod_restart_count(p)))
+                result = await self.runner.run(
+                    kubeconfig_path,
+                    ["logs", target["metadata"]["name"], "-n", namespace, "--all-containers=true",
+                     f"--since={log_since}", "--tail=500"],
+                    execution_log, timeout=timeout,
+                )
+                if result["exit_code"] == 0:
+                    status.recent_errors.extend(extract_error_lines(result["stdout"], max_error_lines))
+
+        related_events = [
+            e for e in events
+            if (e.get("involvedObject") or {}).get("name", "") in related_names
+            or any((e.get("involvedObject") or {}).get("name", "").startswith(n + "-") for n in related_names)
+        ]
+        related_events.sort(key=lambda e: event_time(e) or datetime.min.replace(tzinfo=timezone.utc), reverse=True)
+        status.warning_events = [format_event(e) for e in related_events[:max_error_lines]]
+        status.recent_errors = status.recent_errors[-max_error_lines:] if max_error_lines > 0 else []
+
+        if all(w.ready == 0 for w in status.workloads) and any(w.desired > 0 for w in status.workloads):
+            status.status = "Unavailable"
+        elif any(w.ready < w.desired for w in status.workloads) or status.unhealthy_pods:
+            status.status = "Degraded"
+        return status
+
+    async def kubectl_workload_permissions(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        workload_type: str = Field(..., description="工作负载类型，如 deployment、pod"),
+        workload_name: str = Field(..., description="工作负载名称"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> WorkloadPermissionsOutput:
+        """解析工作负载 ServiceAccount 的 RoleBinding/ClusterRoleBinding，汇总有效权限"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_workload_permissions", cluster_id, self.enable_execution_log
+        )
+        output = WorkloadPermissionsOutput(
+            cluster_id=cluster_id,
+            namespace=namespace,
+            workload=f"{workload_type}/{workload_name}",
+            execution_log=execution_log,
+        )
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            async def get_json(args: List[str]) -> Dict[str, Any]:
+                return await self.runner.run_json(kubeconfig_path, args + ["-o", "json"], execution_log, timeout=timeout)
+
+            workload = await get_json(["get", workload_type, workload_name, "-n", namespace])
+            pod_spec = pod_template_spec(workload)
+            service_account = pod_spec.get("serviceAccountName") or pod_spec.get("serviceAccount") or "default"
+            output.service_account = service_account
+
+            automount = pod_spec.get("automountServiceAccountToken")
+            if automount is None:
+                try:
+                    sa = await get_json(["get", "serviceaccount", service_account, "-n", namespace])
+                    automount = sa.get("automountServiceAccountToken")
+                except KubectlCommandError as e:
+                    output.risks.append(f"service account {namespace}/{service_account} not readable: {e}")
+            output.token_automount = automount is not False
+
+            role_bindings = await get_json(["get", "rolebindings", "-n", namespace])
+            cluster_role_bindings = await get_json(["get", "clusterrolebindings"])
+            roles = {r["metadata"]["name"]: r for r in (await get_json(["get", "roles", "-n", namespace])).get("items", [])}
+            cluster_roles = {r["metadata"]["name"]: r for r in (await get_json(["get", "clusterroles"])).get("items", [])}
+
+            bound = [("RoleBinding", b, namespace) for b in role_bindings.get("items", [])]
+            bound += [("ClusterRoleBinding", b, "cluster") for b in cluster_role_bindings.get("items", [])]
+            for binding_kind, binding, scope in bound:
+                if not binding_grants_service_account(binding, service_account, namespace):
+                    continue
+                role_ref = binding.get("roleRef") or {}
+                role_kind, role_name = role_ref.get("kind"), role_ref.get("name")
+                binding_name = binding["metadata"]["name"]
+                granted_by = f"{binding_kind} {binding_name} -> {role_kind} {role_name}"
+                output.bindings.append(granted_by)
+                role = (roles if role_kind == "Role" else cluster_roles).get(role_name)
+                if role is None:
+                    output.missing_roles.append(f"{role_kind} {role_name} (referenced by {binding_kind} {binding_name})")
+                    continue
+                for rule in role.get("rules") or []:
+                    output.permissions.append(EffectivePermission(
+                        scope=scope,
+                        api_groups=rule.get("apiGroups") or [],
+                        resources=rule.get("resources") or [],
+                        resource_names=rule.get("resourceNames") or [],
+                        non_resource_urls=rule.get("nonResourceURLs") or [],
+                        verbs=rule.get("verbs") or [],
+                        granted_by=granted_by,
+                    ))
+                    for risk in rbac_rule_risks(rule, scope):
+                        if risk not in output.risks:
+                            output.risks.append(risk)
+
+            summary: Dict[str, set] = {}
+            for perm in output.permissions:
+                names = f"[{','.join(perm.resource_names)}]" if perm.resource_names else ""
+                targets = [
+                    f"{perm.scope}:{group + '/' if group else ''}{res}{names}"
+                    for group in (perm.api_groups or [""]) for res in perm.resources
+                ] + [f"{perm.scope}:{url}" for url in perm.non_resource_urls]
+                for target in targets:
+                    summary.setdefault(target, set()).update(perm.verbs)
+            output.summary = {k: sorted(v) for k, v in sorted(summary.items())}
+
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"Failed to resolve workload permissions: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_workload_permissions")
+            output.error = ErrorModel(error_code="WorkloadPermissionsFailed", error_message=str(e))
+            return output
+
+    async def kubectl_dns_check(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        hostname: str = Field(..., description="要解析的主机名，如 my-svc.my-ns、kubernetes.default.svc.cluster.local"),
+        record_type: str = Field("A", description=f"记录类型：{'、'.join(DNS_RECORD_TYPES)}"),
+        namespace: Optional[str] = Field(None, description="执行查询的 Pod 所在命名空间，为空时使用服务的默认命名空间"),
+        pod: Optional[str] = Field(None, description="执行查询的已有 Pod，为空时创建临时 dnsutils Pod"),
+        container: Optional[str] = Field(None, description="执行查询的容器，为空表示 Pod 默认容器"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="exec 超时（秒），默认 120 秒"),
+    ) -> DnsCheckOutput:
+        """在集群内 Pod 中执行 dig/nslookup 并解析结果"""
+        execution_log, start_ms = start_execution_log("kubectl_dns_check", cluster_id, self.enable_execution_log)
+        output = DnsCheckOutput(
+            cluster_id=cluster_id, hostname=hostname, record_type=record_type, execution_log=execution_log,
+        )
+        namespace = namespace or self.default_namespace
+        try:
+            try:
+                record_type = output.record_type = validate_dns_query(hostname, record_type)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            if not pod and not self.allow_write:
+                error = PermissionError(
+                    "creating a temporary dnsutils pod requires --allow-write; specify an existing pod to exec into instead"
+                )
+                finish_execution_log(execution_log, start_ms, error, "dns_check")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds, "exec")
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            dig_command = ["dig", "+search", "+time=2", "+tries=2", hostname, record_type]
+
+            if pod:
+                output.source = f"{namespace}/{pod}"
+                exec_prefix = ["exec", pod, "-n", namespace] + (["-c", container] if container else []) + ["--"]
+                result = await self.runner.run(kubeconfig_path, exec_prefix + dig_command, execution_log, timeout=timeout)
+                output.tool = "dig"
+                if result["exit_code"] != 0 and is_exec_binary_missing(result["exit_code"], result["stderr"]):
+                    result = await self.runner.run(
+                        kubeconfig_path, exec_prefix + ["nslookup", f"-type={record_type}", hostname],
+                        execution_log, timeout=timeout,
+                    )
+                    output.tool = "nslookup"
+                    if result["exit_code"] != 0 and is_exec_binary_missing(result["exit_code"], result["stderr"]):
+                        error = RuntimeError(
+                            f"neither dig nor nslookup is available in {namespace}/{pod}; "
+                            "omit pod to use a temporary dnsutils pod"
+                        )
+                        finish_execution_log(execution_log, start_ms, error, "dns_check")
+                        output.error = ErrorModel(error_code="DnsToolUnavailable", error_message=str(error))
+                        return output
+            else:
+                temp_pod = f"mcp-dns-check-{uuid.uuid4().hex[:8]}"
+                output.source = f"{namespace}/{temp_pod}"
+                output.tool = "dig"
+                image = self.settings.get("dns_check_image") or DEFAULT_DNS_CHECK_IMAGE
+                result = await self.runner.run(
+                    kubeconfig_path,
+                    ["run", temp_pod, "-n", namespace, f"--image={image}", "--restart=Never", "--rm", "-i",
+                     "--quiet", "--labels=app.kubernetes.io/managed-by=ack-mcp-server", "--command", "--"]
+                    + dig_command,
+                    execution_log, timeout=timeout,
+                )
+
+            output.raw_output = (result["stdout"] or result["stderr"]).strip()
+            parsed = parse_dig_output(result["stdout"]) if output.tool == "dig" else parse_nslookup_output(result["stdout"])
+            if parsed["status"] is None and "timed out" in result["stdout"]:
+                parsed["status"] = "TIMEOUT"
+            if parsed["status"] is None and result["exit_code"] != 0:
+                error = RuntimeError(result["stderr"] or f"{output.tool} exited with code {result['exit_code']}")
+                finish_execution_log(execution_log, start_ms, error, "dns_check")
+                output.error = ErrorModel(error_code="DnsCheckFailed", error_message=str(error))
+                return output
+
+            output.status = parsed["status"]
+            output.server = parsed["server"]
+            output.latency_ms = parsed["latency_ms"]
+            output.records = [DnsRecord(**record) for record in parsed["records"]]
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_dns_check failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_dns_check")
+            output.error = ErrorModel(error_code="DnsCheckFailed", error_message=str(e))
+            return output
+
+    async def kubectl_webhook_mutations(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        manifest: Optional[str] = Field(None, description="要提交 dry-run 的对象 YAML 或 JSON（单个对象）"),
+        resource_type: Optional[str] = Field(None, description="未提供 manifest 时，已有对象的资源类型，如 deployment"),
+        name: Optional[str] = Field(None, description="未提供 manifest 时，已有对象的名称"),
+        namespace: Optional[str] = Field(None, description="命名空间"),
+        use_pod_template: bool = Field(True, description="对已有工作负载，是否基于 Pod 模板构造 Pod 进行 dry-run"),
+        include_defaults: bool = Field(False, description="是否包含内置默认值填充的差异"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> WebhookMutationOutput:
+        """server 端 dry-run 提交对象并对比 webhook 修改"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_webhook_mutations", cluster_id, self.enable_execution_log
+        )
+        output = WebhookMutationOutput(cluster_id=cluster_id, namespace=namespace, execution_log=execution_log)
+        try:
+            if not manifest and not (resource_type and name):
+                raise ValueError("either manifest or resource_type and name must be provided")
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            verb = "create"
+            if manifest:
+                submitted = yaml.safe_load(manifest)
+                if not isinstance(submitted, dict) or "kind" not in submitted:
+                    raise ValueError("manifest must be a single Kubernetes object")
+            else:
+                args = ["get", resource_type, name, "-o", "json"] + (["-n", namespace] if namespace else [])
+                existing = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+                if use_pod_template and existing.get("kind") in _POD_TEMPLATE_KINDS:
+                    submitted = self._pod_from_template(existing)
+                else:
+                    submitted = strip_server_fields(existing)
+                    verb = "replace"
+            metadata = submitted.setdefault("metadata", {})
+            if namespace and not metadata.get("namespace"):
+                metadata["namespace"] = namespace
+            output.kind = submitted.get("kind")
+            output.name = metadata.get("name") or metadata.get("generateName")
+            output.namespace = metadata.get("namespace")
+
+            returned = await self.runner.run_json(
+                kubeconfig_path, [verb, "--dry-run=server", "-o", "json", "-f", "-"], execution_log,
+                timeout=timeout, stdin=json.dumps(submitted),
+            )
+
+            before = strip_server_fields(submitted)
+            after = strip_server_fields(returned)
+            if metadata.get("generateName") and not metadata.get("name"):
+                after.get("metadata", {}).pop("name", None)
+            for change in json_diff(before, after):
+                if not include_defaults and is_builtin_default_change(change):
+                    output.ignored_default_changes += 1
+                    continue
+                output.changes.append(ObjectChange(**change))
+                if change["path"] == "metadata.annotations" and change["op"] == "added":
+                    output.annotations_added.update(change["after"] or {})
+                elif change["path"].startswith("metadata.annotations.") and change["op"] == "added":
+                    output.annotations_added[change["path"][len("metadata.annotations."):]] = change["after"]
+
+            try:
+                webhooks = await self.runner.run_json(
+                    kubeconfig_path, ["get", "mutatingwebhookconfigurations", "-o", "json"], execution_log,
+                    timeout=timeout,
+                )
+                output.mutating_webhooks = [w["metadata"]["name"] for w in webhooks.get("items", [])]
+            except KubectlCommandError as e:
+                logger.debug(f"Failed to list mutating webhook configurations: {e}")
+
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_webhook_mutations failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_webhook_mutations")
+            output.error = ErrorModel(error_code="WebhookMutationDiffFailed", error_message=str(e))
+            return output
+
+    @staticmethod
+    def _pod_from_template(workload: Dict[str, Any]) -> Dict[str, Any]:
+        """基于工作负载的 Pod 模板构造用于 dry-run 的 Pod"""
+        spec = workload.get("spec") or {}
+        if workload.get("kind") == "CronJob":
+            spec = (spec.get("jobTemplate") or {}).get("spec") or {}
+        template = spec.get("template") or {}
+        template_metadata = template.get("metadata") or {}
+        workload_metadata = workload.get("metadata") or {}
+        return {
+            "apiVersion": "v1",
+            "kind": "Pod",
+            "metadata": {
+                "generateName": f"{workload_metadata.get('name', 'workload')}-dryrun-",
+                "namespace": workload_metadata.get("namespace"),
+                "labels": template_metadata.get("labels") or {},
+                "annotations": template_metadata.get("annotations") or {},
+            },
+            "spec": template.get("spec") or {},
+        }
+
+    async def kubectl_ephemeral_storage_risk(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        node_selector: Optional[str] = Field(None, description="节点标签选择器，为空表示全部节点"),
+        pressure_percent: float = Field(80, description="节点根文件系统使用率达到该百分比时视为接近 DiskPressure"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> EphemeralStorageRiskOutput:
+        """对比 ephemeral-storage requests/limits 与节点容量及用量，标记风险节点上未限制临时存储的 Pod"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_ephemeral_storage_risk", cluster_id, self.enable_execution_log
+        )
+        output = EphemeralStorageRiskOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            node_args = ["get", "nodes", "-o", "json"]
+            if node_selector:
+                node_args[2:2] = ["-l", node_selector]
+            node_list = await self.runner.run_json(kubeconfig_path, node_args, execution_log, timeout=timeout)
+            pod_list = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "pods", "--all-namespaces",
+                 "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+            nodes = node_list.get("items", [])
+            semaphore = asyncio.Semaphore(5)
+
+            async def fetch_stats(node_name: str) -> Optional[Dict[str, Any]]:
+                async with semaphore:
+                    try:
+                        return await self.runner.run_json(
+                            kubeconfig_path, ["get", "--raw", f"/api/v1/nodes/{node_name}/proxy/stats/summary"],
+                            execution_log, timeout=timeout,
+                        )
+                    except KubectlCommandError as e:
+                        logger.debug(f"Failed to read stats summary of node {node_name}: {e}")
+                        return None
+
+            stats = await asyncio.gather(*(fetch_stats(n.get("metadata", {}).get("name", "")) for n in nodes))
+            output.nodes, output.risky_pods, output.total_pods_without_limit = self._analyze_ephemeral_storage(
+                nodes, stats, pod_list.get("items", []), pressure_percent
+            )
+
+            if output.risky_pods:
+                output.suggestions.append(
+                    "为风险节点上的 Pod 设置 resources.limits.ephemeral-storage，并为 emptyDir 设置 sizeLimit，"
+                    "使超量写入只驱逐该 Pod，而不是触发节点 DiskPressure 驱逐其他 Pod"
+                )
+            if any(n.at_risk for n in output.nodes):
+                output.suggestions.append(
+                    "清理风险节点上的无用镜像与容器日志，检查容器运行时日志轮转配置（containerLogMaxSize），"
+                    "必要时扩容节点系统盘或为节点池使用更大的数据盘"
+                )
+            if any(n.used is None for n in output.nodes):
+                output.suggestions.append("部分节点无法读取 kubelet stats/summary，用量仅基于 requests 与 DiskPressure 状况判断")
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_ephemeral_storage_risk failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_ephemeral_storage_risk")
+            output.error = ErrorModel(error_code="EphemeralStorageAnalysisFailed", error_message=str(e))
+            return output
+
+    @staticmethod
+    def _analyze_ephemeral_storage(
+        node_items: List[Dict[str, Any]],
+        node_stats: List[Optional[Dict[str, Any]]],
+        pod_items: List[Dict[str, Any]],
+        pressure_percent: float,
+    ) -> Tuple[List[EphemeralStorageNode], List[EphemeralStoragePodRisk], int]:
+        pods_by_node: Dict[str, List[Dict[str, Any]]] = {}
+        for pod in pod_items:
+            node_name = (pod.get("spec") or {}).get("nodeName")
+            if node_name:
+                pods_by_node.setdefault(node_name, []).append(pod)
+
+        entries: List[EphemeralStorageNode] = []
+        risky_pods: List[Tuple[float, EphemeralStoragePodRisk]] = []
+        total_without_limit = 0
+        for node, stats in zip(node_items, node_stats):
+            name = node.get("metadata", {}).get("name", "")
+            status = node.get("status") or {}
+            capacity = parse_quantity((status.get("capacity") or {}).get("ephemeral-storage", 0))
+            allocatable = parse_quantity((status.get("allocatable") or {}).get("ephemeral-storage", 0))
+            disk_pressure = any(
+                c.get("type") == "DiskPressure" and c.get("status") == "True" for c in status.get("conditions") or []
+            )
+
+            pod_usage: Dict[Tuple[str, str], float] = {}
+            used = fs_capacity = None
+            if stats:
+                fs = (stats.get("node") or {}).get("fs") or {}
+                used, fs_capacity = fs.get("usedBytes"), fs.get("capacityBytes")
+                for pod_stats in stats.get("pods") or []:
+                    ref = pod_stats.get("podRef") or {}
+                    usage = (pod_stats.get("ephemeral-storage") or {}).get("usedBytes")
+                    if usage is not None:
+                        pod_usage[(ref.get("namespace", ""), ref.get("name", ""))] = float(usage)
+
+            pods = pods_by_node.get(name, [])
+            storage = [pod_ephemeral_storage(pod) for pod in pods]
+            requests = sum(s["requests"] for s in storage)
+            unlimited = [(pod, s) for pod, s in zip(pods, storage) if s["limits"] is None]
+            total_without_limit += len(unlimited)
+
+            entry = EphemeralStorageNode(
+                name=name,
+                disk_pressure=disk_pressure,
+                capacity=format_bytes(capacity),
+                allocatable=format_bytes(allocatable),
+                requests=format_bytes(requests),
+                request_ratio=round(requests / allocatable, 3) if allocatable > 0 else 0.0,
+                pod_count=len(pods),
+                pods_without_limit=len(unlimited),
+            )
+            if used is not None:
+                entry.used = format_bytes(used)
+                total = fs_capacity or capacity
+                entry.usage_percent = round(used * 100 / total, 1) if total else None
+            if disk_pressure:
+                entry.reasons.append("DiskPressure")
+            if entry.usage_percent is not None and entry.usage_percent >= pressure_percent:
+                entry.reasons.append(f"根文件系统使用率 {entry.usage_percent}% >= {pressure_percent}%")
+            if entry.request_ratio >= 0.9:
+                entry.reasons.append(f"ephemeral-storage requests 占可分配量 {entry.request_ratio:.0%}")
+            entry.at_risk = bool(entry.reasons)
+            entries.append(entry)
+
+            if not entry.at_risk:
+                continue
+            for pod, s in unlimited:
+                metadata = pod.get("metadata") or {}
+                usage = pod_usage.get((metadata.get("namespace", ""), metadata.get("name", "")))
+                risky_pods.append((usage or 0.0, EphemeralStoragePodRisk(
+                    name=metadata.get("name", ""),
+                    namespace=metadata.get("namespace", ""),
+                    node=name,
+                    requests=format_bytes(s["requests"]),
+                    used=format_bytes(usage) if usage is not None else None,
+                    containers_without_limit=s["containers_without_limit"],
+                    unbounded_empty_dirs=s["unbounded_empty_dirs"],
+                )))
+
+        entries.sort(key=lambda e: (not e.at_risk, -(e.usage_percent or 0.0)))
+        risky_pods.sort(key=lambda item: item[0], reverse=True)
+        return entries, [pod for _, pod in risky_pods], total_without_limit
+
+    async def kubectl_cross_namespace_refs(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: Optional[str] = Field(None, description="检查的命名空间，为空或 all 表示全部命名空间"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> CrossNamespaceReferencesOutput:
+        """检查引用其他命名空间对象的 Ingress、工作负载与 PVC"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_cross_namespace_refs", cluster_id, self.enable_execution_log
+        )
+        output = CrossNamespaceReferencesOutput(cluster_id=cluster_id, namespace=namespace, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            sources = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "deployments,statefulsets,daemonsets,cronjobs,ingresses,persistentvolumeclaims",
+                 *self._namespace_args(namespace), "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+            # 被引用对象的名称索引需覆盖全部命名空间，才能发现"存在于其他命名空间"的情况
+            result = await self.runner.run(
+                kubeconfig_path,
+                ["get", "configmaps,secrets,persistentvolumeclaims,serviceaccounts,services", "--all-namespaces",
+                 "-o", "custom-columns=KIND:.kind,NAMESPACE:.metadata.namespace,NAME:.metadata.name", "--no-headers"],
+                execution_log,
+                timeout=timeout,
+            )
+            if result["exit_code"] != 0:
+                raise KubectlCommandError(result["stderr"], exit_code=result["exit_code"], stderr=result["stderr"])
+            index: Dict[Tuple[str, str], List[str]] = {}
+            for line in result["stdout"].splitlines():
+                fields = line.split()
+                if len(fields) == 3:
+                    index.setdefault((fields[0], fields[2]), []).append(fields[1])
+
+            items = sources.get("items", [])
+            output.scanned_objects = len(items)
+            for item in items:
+                output.references.extend(self._check_cross_namespace_refs(item, index))
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_cross_namespace_refs failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_cross_namespace_refs")
+            output.error = ErrorModel(error_code="CrossNamespaceCheckFailed", error_message=str(e))
+            return output
+
+    @staticmethod
+    def _check_cross_namespace_refs(
+        obj: Dict[str, Any], index: Dict[Tuple[str, str], List[str]]
+    ) -> List[CrossNamespaceReference]:
+        metadata = obj.get("metadata") or {}
+        own_namespace = metadata.get("namespace", "")
+        source = f"{obj.get('kind')}/{own_namespace}/{metadata.get('name')}"
+        findings: List[CrossNamespaceReference] = []
+        for ref in object_references(obj):
+            kind, name = ref["kind"], ref["name"] or ""
+            if ref["namespace"] and ref["namespace"] != own_namespace:
+                findings.append(CrossNamespaceReference(
+                    source=source, field=ref["field"], target_kind=kind, target_name=name,
+                    issue="ExplicitCrossNamespace", candidate_namespaces=[ref["namespace"]],
+                    message=f"引用命名空间 {ref['namespace']} 中的 {kind}/{name}，"
+                            "需要启用 CrossNamespaceVolumeDataSource 特性门控并在目标命名空间创建 ReferenceGrant",
+                ))
+                continue
+            namespaces = index.get((kind, name))
+            if kind not in _NAME_INDEXED_KINDS:
+                continue
+            if namespaces and own_namespace in namespaces:
+                continue
+
+            # 名称中携带命名空间：other-ns/name 或 name.other-ns[.svc.cluster.local]
+            qualified = None
+            if "/" in name:
+                ns_part, _, name_part = name.partition("/")
+                qualified = (ns_part, name_part)
+            elif "." in name:
+                name_part, ns_part = name.split(".")[:2]
+                qualified = (ns_part, name_part)
+            if qualified and qualified[0] in index.get((kind, qualified[1]), []):
+                findings.append(CrossNamespaceReference(
+                    source=source, field=ref["field"], target_kind=kind, target_name=name,
+                    issue="NamespaceQualifiedName", candidate_namespaces=[qualified[0]],
+                    message=f"名称 {name} 会被视为当前命名空间中的字面名称，无法引用命名空间 {qualified[0]} 中的 "
+                            f"{kind}/{qualified[1]}",
+                ))
+            elif namespaces:
+                findings.append(CrossNamespaceReference(
+                    source=source, field=ref["field"], target_kind=kind, target_name=name,
+                    issue="FoundInOtherNamespace", candidate_namespaces=sorted(namespaces),
+                    message=f"命名空间 {own_namespace} 中不存在 {kind}/{name}，同名对象位于 {', '.join(sorted(namespaces))}，"
+                            f"{kind} 只能在同一命名空间内引用",
+                ))
+        return findings
+
+    async def kubectl_version_skew(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> VersionSkewOutput:
+        """对比各节点池 kubelet 版本与控制面版本"""
+        execution_log, start_ms = start_execution_log("kubectl_version_skew", cluster_id, self.enable_execution_log)
+        output = VersionSkewOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            version = await self.runner.run_json(
+                kubeconfig_path, ["get", "--raw", "/version"], execution_log, timeout=timeout
+            )
+            node_list = await self.runner.run_json(
+                kubeconfig_path, ["get", "nodes", "-o", "json"], execution_log, timeout=timeout
+            )
+            output.control_plane_version = version.get("gitVersion")
+            control_plane = parse_k8s_version(output.control_plane_version)
+            if control_plane is None:
+                raise ValueError(f"unable to parse control plane version: {output.control_plane_version}")
+            output.max_supported_skew = max_kubelet_minor_skew(control_plane[1])
+
+            nodepools: Dict[str, Dict[str, Any]] = {}
+            try:
+                nodepools, output.cluster_version = await self._describe_nodepools(ctx, cluster_id, execution_log)
+            except Exception as e:
+                logger.warning(f"Failed to describe node pools of cluster {cluster_id}: {e}")
+                output.issues.append(f"无法通过 CS 获取节点池信息（{e}），仅按节点标签分组")
+
+            output.nodepools = self._analyze_version_skew(
+                node_list.get("items", []), nodepools, control_plane, output.max_supported_skew
+            )
+            for pool in output.nodepools:
+                label = pool.name or pool.nodepool_id or "未归属节点池的节点"
+                if pool.status == "UnsupportedSkew":
+                    output.issues.append(
+                        f"{label} 的 kubelet {pool.oldest_version} 落后控制面 {pool.minor_skew} 个次版本，"
+                        f"超出支持范围（{output.max_supported_skew}），请尽快升级节点池"
+                    )
+                elif pool.status == "NewerThanControlPlane":
+                    output.issues.append(f"{label} 的 kubelet 版本比控制面新，不符合版本偏差策略")
+                elif pool.status == "Behind":
+                    output.issues.append(f"{label} 的 kubelet {pool.oldest_version} 落后控制面，建议升级节点池")
+                if pool.mixed_versions:
+                    output.issues.append(
+                        f"{label} 内存在多个 kubelet 版本：{', '.join(sorted(pool.kubelet_versions))}，"
+                        "可能存在未完成的节点池升级"
+                    )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_version_skew failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_version_skew")
+            output.error = ErrorModel(error_code="VersionSkewCheckFailed", error_message=str(e))
+            return output
+
+    async def _describe_nodepools(
+        self, ctx: Context, cluster_id: str, execution_log: ExecutionLog
+    ) -> Tuple[Dict[str, Dict[str, Any]], Optional[str]]:
+        """通过 CS API 获取节点池 (nodepool_id -> {name, state}) 及集群版本"""
+        api_start = int(datetime.now(timezone.utc).timestamp() * 1000)
+        detail = await _get_cs_client(ctx, "CENTER").describe_cluster_detail_async(cluster_id)
+        region_id = getattr(detail.body, "region_id", "") or ""
+        if not region_id:
+            raise ValueError(f"Could not determine region for cluster {cluster_id}")
+        cluster_version = getattr(detail.body, "current_version", None)
+        raw = await _fetch_nodepools_list(_get_cs_client(ctx, region_id), cluster_id, _serialize_sdk_object)
+        execution_log.api_calls.append({
+            "api": "DescribeClusterNodePools",
+            "cluster_id": cluster_id,
+            "duration_ms": int(datetime.now(timezone.utc).timestamp() * 1000) - api_start,
+            "status": "success",
+        })
+        nodepools = {}
+        for item in raw:
+            info = item.get("nodepool_info") or {}
+            nodepool_id = info.get("nodepool_id")
+            if nodepool_id:
+                nodepools[nodepool_id] = {"name": info.get("name"), "state": (item.get("status") or {}).get("state")}
+        return nodepools, cluster_version if isinstance(cluster_version, str) else None
+
+    @staticmethod
+    def _analyze_version_skew(
+        node_items: List[Dict[str, Any]],
+        nodepools: Dict[str, Dict[str, Any]],
+        control_plane: Tuple[int, int, int],
+        max_skew: int,
+    ) -> List[NodePoolVersionSkew]:
+        pools: Dict[Optional[str], NodePoolVersionSkew] = {}
+        for nodepool_id, info in nodepools.items():
+            pools[nodepool_id] = NodePoolVersionSkew(nodepool_id=nodepool_id, name=info["name"], state=info["state"])
+        oldest: Dict[Optional[str], Tuple[int, int, int]] = {}
+        for node in node_items:
+            metadata = node.get("metadata") or {}
+            nodepool_id = (metadata.get("labels") or {}).get(NODEPOOL_ID_LABEL)
+            kubelet = ((node.get("status") or {}).get("nodeInfo") or {}).get("kubeletVersion")
+            parsed = parse_k8s_version(kubelet)
+            pool = pools.setdefault(nodepool_id, NodePoolVersionSkew(nodepool_id=nodepool_id))
+            pool.node_count += 1
+            pool.kubelet_versions[kubelet or "unknown"] = pool.kubelet_versions.get(kubelet or "unknown", 0) + 1
+            if parsed is None:
+                continue
+            if parsed[:2] < control_plane[:2]:
+                pool.lagging_nodes.append(metadata.get("name", ""))
+            if nodepool_id not in oldest or parsed < oldest[nodepool_id]:
+                oldest[nodepool_id] = parsed
+                pool.oldest_version = kubelet
+
+        for nodepool_id, pool in pools.items():
+            pool.mixed_versions = len(pool.kubelet_versions) > 1
+            if nodepool_id not in oldest:
+                continue
+            newest_minor = max(v[1] for v in (parse_k8s_version(k) for k in pool.kubelet_versions) if v)
+            pool.minor_skew = control_plane[1] - oldest[nodepool_id][1]
+            if newest_minor > control_plane[1]:
+                pool.status = "NewerThanControlPlane"
+            elif pool.minor_skew > max_skew:
+                pool.status = "UnsupportedSkew"
+            elif pool.minor_skew > 0:
+                pool.status = "Behind"
+        return sorted(
+            (p for p in pools.values() if p.node_count or p.nodepool_id),
+            key=lambda p: p.minor_skew, reverse=True,
+        )
+
+    async def kubectl_spot_risk(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        spot_selector: Optional[str] = Field(None, description="识别抢占式实例的节点标签选择器，为空时使用内置标签"),
+        namespace: Optional[str] = Field(None, description="仅检查该命名空间的工作负载，为空或 all 表示全部命名空间"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> SpotRiskOutput:
+        """找出运行在抢占式实例上的工作负载并评估回收风险"""
+        execution_log, start_ms = start_execution_log("kubectl_spot_risk", cluster_id, self.enable_execution_log)
+        output = SpotRiskOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            node_args = ["get", "nodes", "-o", "json"]
+            if spot_selector:
+                node_args[2:2] = ["-l", spot_selector]
+            node_list = await self.runner.run_json(kubeconfig_path, node_args, execution_log, timeout=timeout)
+            try:
+                node_events = await self.runner.run_json(
+                    kubeconfig_path,
+                    ["get", "events", "--all-namespaces", "--field-selector=involvedObject.kind=Node", "-o", "json"],
+                    execution_log, timeout=timeout,
+                )
+            except KubectlCommandError as e:
+                logger.debug(f"Failed to list node events: {e}")
+                node_events = {}
+
+            spot_nodes: Dict[str, SpotNode] = {}
+            for node in node_list.get("items", []):
+                spot_node = self._spot_node(node, bool(spot_selector))
+                if spot_node:
+                    spot_nodes[spot_node.name] = spot_node
+            for event in node_events.get("items", []):
+                involved = (event.get("involvedObject") or {}).get("name")
+                if involved in spot_nodes and _RECLAIM_SIGNAL_RE.search(event.get("reason") or ""):
+                    spot_nodes[involved].reclaim_pending = True
+                    spot_nodes[involved].reclaim_signals.append(f"event:{event.get('reason')}")
+            output.spot_nodes = sorted(spot_nodes.values(), key=lambda n: (not n.reclaim_pending, n.name))
+            if not spot_nodes:
+                finish_execution_log(execution_log, start_ms)
+                return output
+
+            pod_list = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "pods", *self._namespace_args(namespace),
+                 "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            pdb_list = await self.runner.run_json(
+                kubeconfig_path, ["get", "poddisruptionbudgets", *self._namespace_args(namespace), "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            output.workloads = self._analyze_spot_workloads(
+                pod_list.get("items", []), pdb_list.get("items", []), spot_nodes
+            )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_spot_risk failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_spot_risk")
+            output.error = ErrorModel(error_code="SpotRiskAnalysisFailed", error_message=str(e))
+            return output
+
+    @staticmethod
+    def _spot_node(node: Dict[str, Any], selected: bool) -> Optional[SpotNode]:
+        """识别抢占式实例节点及其回收通知；selected 表示节点已由 spot_selector 筛选"""
+        metadata = node.get("metadata") or {}
+        labels = metadata.get("labels") or {}
+        annotations = metadata.get("annotations") or {}
+        signal = "spot_selector" if selected else None
+        for key, value in SPOT_NODE_MARKERS:
+            if signal:
+                break
+            if str(labels.get(key, annotations.get(key, ""))).lower() == value:
+                signal = f"{key}={value}"
+        if not signal:
+            return None
+
+        spot_node = SpotNode(
+            name=metadata.get("name", ""),
+            instance_type=labels.get("node.kubernetes.io/instance-type"),
+            spot_signal=signal,
+        )
+        for condition in (node.get("status") or {}).get("conditions") or []:
+            if condition.get("status") == "True" and _RECLAIM_SIGNAL_RE.search(condition.get("type") or ""):
+                spot_node.reclaim_signals.append(f"condition:{condition['type']}")
+        for taint in (node.get("spec") or {}).get("taints") or []:
+            if _RECLAIM_SIGNAL_RE.search(taint.get("key") or ""):
+                spot_node.reclaim_signals.append(f"taint:{taint['key']}")
+        spot_node.reclaim_pending = bool(spot_node.reclaim_signals)
+        return spot_node
+
+    @staticmethod
+    def _analyze_spot_workloads(
+        pod_items: List[Dict[str, Any]],
+        pdb_items: List[Dict[str, Any]],
+        spot_nodes: Dict[str, SpotNode],
+    ) -> List[SpotWorkloadRisk]:
+        workloads: Dict[Tuple[str, str, str], SpotWorkloadRisk] = {}
+        workload_pods: Dict[Tuple[str, str, str], List[Dict[str, Any]]] = {}
+        for pod in pod_items:
+            kind, name = pod_owner(pod)
+            if kind == "DaemonSet":
+                continue
+            namespace = (pod.get("metadata") or {}).get("namespace", "")
+            key = (namespace, kind, name)
+            workload = workloads.setdefault(key, SpotWorkloadRisk(namespace=namespace, kind=kind, name=name))
+            workload_pods.setdefault(key, []).append(pod)
+            workload.replicas += 1
+            node_name = (pod.get("spec") or {}).get("nodeName")
+            if node_name in spot_nodes:
+                spot_nodes[node_name].pod_count += 1
+                workload.spot_replicas += 1
+                if node_name not in workload.spot_nodes:
+                    workload.spot_nodes.append(node_name)
+
+        results: List[SpotWorkloadRisk] = []
+        for key, workload in workloads.items():
+            if not workload.spot_replicas:
+                continue
+            workload.pdbs = sorted({
+                pdb["metadata"]["name"]
+                for pdb in pdb_items
+                if pdb.get("metadata", {}).get("namespace") == workload.namespace
+                and any(
+                    label_selector_matches((pdb.get("spec") or {}).get("selector"),
+                                           (pod.get("metadata") or {}).get("labels"))
+                    for pod in workload_pods[key]
+                )
+            })
+            if workload.spot_replicas == workload.replicas:
+                workload.flags.append("AllReplicasOnSpot")
+            if workload.replicas == 1:
+                workload.flags.append("SingleReplica")
+            if not workload.pdbs:
+                workload.flags.append("NoPDB")
+            if any(spot_nodes[n].reclaim_pending for n in workload.spot_nodes):
+                workload.flags.append("OnReclaimingNode")
+
+            exposed = "AllReplicasOnSpot" in workload.flags or "SingleReplica" in workload.flags
+            if "OnReclaimingNode" in workload.flags or (exposed and "NoPDB" in workload.flags):
+                workload.risk = "High"
+            elif exposed or "NoPDB" in workload.flags:
+                workload.risk = "Medium"
+            results.append(workload)
+
+        order = {"High": 0, "Medium": 1, "Low": 2}
+        results.sort(key=lambda w: (order[w.risk], w.namespace, w.name))
+        return results
+
+    async def kubectl_log_rate(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        pod: Optional[str] = Field(None, description="Pod 名称"),
+        label_selector: Optional[str] = Field(None, description="Pod 标签选择器，如 app=web"),
+        container: Optional[str] = Field(None, description="仅采样该容器，为空表示全部容器"),
+        sample_seconds: int = Field(10, description="采样时长（秒），最大 60"),
+        max_pods: int = Field(20, description="按标签选择器采样时最多采样的 Pod 数量"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="查询 Pod 的 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> LogRateOutput:
+        """采样容器日志输出速率"""
+        execution_log, start_ms = start_execution_log("kubectl_log_rate", cluster_id, self.enable_execution_log)
+        sample_seconds = max(1, min(int(sample_seconds), MAX_LOG_SAMPLE_SECONDS))
+        output = LogRateOutput(
+            cluster_id=cluster_id, namespace=namespace, sample_seconds=sample_seconds, execution_log=execution_log,
+        )
+        try:
+            if bool(pod) == bool(label_selector):
+                raise ValueError("exactly one of pod or label_selector must be provided")
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            if pod:
+                pods = [await self.runner.run_json(
+                    kubeconfig_path, ["get", "pod", pod, "-n", namespace, "-o", "json"], execution_log, timeout=timeout,
+                )]
+            else:
+                pod_list = await self.runner.run_json(
+                    kubeconfig_path, ["get", "pods", "-n", namespace, "-l", label_selector, "-o", "json"],
+                    execution_log, timeout=timeout,
+                )
+                pods = [p for p in pod_list.get("items", []) if (p.get("status") or {}).get("phase") == "Running"]
+                pods = pods[:max_pods]
+
+            targets = [
+                (p["metadata"]["name"], c["name"])
+                for p in pods
+                for c in (p.get("spec") or {}).get("containers") or []
+                if not container or c["name"] == container
+            ]
+            semaphore = asyncio.Semaphore(20)
+
+            async def sample(pod_name: str, container_name: str) -> Dict[str, Any]:
+                async with semaphore:
+                    return await self.runner.sample_stream(
+                        kubeconfig_path,
+                        ["logs", pod_name, "-n", namespace, "-c", container_name, "-f", "--tail=0"],
+                        execution_log,
+                        sample_seconds,
+                    )
+
+            results = await asyncio.gather(*(sample(p, c) for p, c in targets))
+            for (pod_name, container_name), result in zip(targets, results):
+                entry = LogRateEntry(
+                    pod=pod_name,
+                    container=container_name,
+                    bytes=result["bytes"],
+                    lines=result["lines"],
+                    bytes_per_second=round(result["bytes"] / sample_seconds, 1),
+                    lines_per_second=round(result["lines"] / sample_seconds, 2),
+                )
+                entry.estimated_daily = format_bytes(entry.bytes_per_second * 86400)
+                if result["exit_code"] != 0:
+                    entry.error = result["stderr"] or f"kubectl exited with code {result['exit_code']}"
+                output.entries.append(entry)
+            output.entries.sort(key=lambda e: e.bytes_per_second, reverse=True)
+            output.total_bytes_per_second = round(sum(e.bytes_per_second for e in output.entries), 1)
+            output.estimated_daily_total = format_bytes(output.total_bytes_per_second * 86400)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_log_rate failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_log_rate")
+            output.error = ErrorModel(error_code="LogRateSampleFailed", error_message=str(e))
+            return output
+
+    async def kubectl_image_pullability(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        workload_type: Optional[str] = Field(None, description="工作负载类型，如 deployment、statefulset、daemonset、cronjob"),
+        workload_name: Optional[str] = Field(None, description="工作负载名称，为空表示检查命名空间内全部工作负载"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ImagePullabilityOutput:
+        """检查工作负载引用的镜像是否可从镜像仓库拉取"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_image_pullability", cluster_id, self.enable_execution_log
+        )
+        output = ImagePullabilityOutput(cluster_id=cluster_id, namespace=namespace, execution_log=execution_log)
+        try:
+            if bool(workload_type) != bool(workload_name):
+                raise ValueError("workload_type and workload_name must be provided together")
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            if workload_name:
+                workloads = [await self.runner.run_json(
+                    kubeconfig_path, ["get", workload_type, workload_name, "-n", namespace, "-o", "json"],
+                    execution_log, timeout=timeout,
+                )]
+            else:
+                workloads = (await self.runner.run_json(
+                    kubeconfig_path,
+                    ["get", "deployments,statefulsets,daemonsets,cronjobs", "-n", namespace, "-o", "json"],
+                    execution_log, timeout=timeout,
+                )).get("items", [])
+
+            async def get_optional(kind: str, name: str) -> Dict[str, Any]:
+                try:
+                    return await self.runner.run_json(
+                        kubeconfig_path, ["get", kind, name, "-n", namespace, "-o", "json"],
+                        execution_log, timeout=timeout,
+                    )
+                except KubectlCommandError as e:
+                    logger.debug(f"Failed to get {kind} {namespace}/{name}: {e}")
+                    return {}
+
+            service_accounts: Dict[str, Dict[str, Any]] = {}
+            secret_auths: Dict[str, Dict[str, Tuple[str, str]]] = {}
+            # image -> (引用位置, 有序的 imagePullSecret 名称)
+            images: Dict[str, Tuple[List[str], List[str]]] = {}
+            for workload in workloads:
+                spec = pod_template_spec(workload)
+                sa_name = spec.get("serviceAccountName") or spec.get("serviceAccount") or "default"
+                if sa_name not in service_accounts:
+                    service_accounts[sa_name] = await get_optional("serviceaccount", sa_name)
+                pull_secrets = [s.get("name") for s in spec.get("imagePullSecrets") or [] if s.get("name")]
+                pull_secrets += [
+                    s.get("name") for s in service_accounts[sa_name].get("imagePullSecrets") or []
+                    if s.get("name") and s.get("name") not in pull_secrets
+                ]
+                for secret_name in pull_secrets:
+                    if secret_name not in secret_auths:
+                        secret_auths[secret_name] = docker_config_auths(await get_optional("secret", secret_name))
+                source = f"{workload.get('kind')}/{(workload.get('metadata') or {}).get('name')}"
+                for container in (spec.get("initContainers") or []) + (spec.get("containers") or []):
+                    image = container.get("image")
+                    if not image:
+                        continue
+                    used_by, secrets = images.setdefault(image, ([], []))
+                    used_by.append(f"{source}[{container.get('name')}]")
+                    secrets.extend(s for s in pull_secrets if s not in secrets)
+
+            for image, (used_by, secrets) in images.items():
+                output.images.append(await self._check_image_pullability(image, used_by, secrets, secret_auths))
+            order = {"Pullable": 1}
+            output.images.sort(key=lambda i: (order.get(i.status, 0), i.image))
+            for check in output.images:
+                output.summary[check.status] = output.summary.get(check.status, 0) + 1
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_image_pullability failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_image_pullability")
+            output.error = ErrorModel(error_code="ImagePullabilityCheckFailed", error_message=str(e))
+            return output
+
+    async def _check_image_pullability(
+        self,
+        image: str,
+        used_by: List[str],
+        secrets: List[str],
+        secret_auths: Dict[str, Dict[str, Tuple[str, str]]],
+    ) -> ImagePullCheck:
+        """依次尝试匹配仓库地址的 imagePullSecret（与 kubelet 行为一致），无匹配凭据时匿名访问"""
+        registry = parse_image_reference(image)[0]
+        candidates = [(name, secret_auths[name][registry]) for name in secrets if registry in secret_auths.get(name, {})]
+        result: Dict[str, Any] = {}
+        auth = "anonymous"
+        for auth, credentials in candidates or [("anonymous", None)]:
+            result = await self.registry.check_image(image, credentials)
+            if result["status"] == "Pullable":
+                break
+        return ImagePullCheck(
+            image=image,
+            registry=registry,
+            used_by=used_by,
+            status=result["status"],
+            http_status=result.get("http_status"),
+            digest=result.get("digest"),
+            auth=auth,
+            message=result.get("message") or None,
+        )
+
+    async def kubectl_admission_denials(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> AdmissionDenialsOutput:
+        """扫描 Warning 事件中的准入拒绝并按拒绝方分组"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_admission_denials", cluster_id, self.enable_execution_log
+        )
+        output = AdmissionDenialsOutput(cluster_id=cluster_id, namespace=namespace, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            events = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "events", *self._namespace_args(namespace), "--field-selector=type=Warning", "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+            groups: Dict[Tuple[str, str], AdmissionDenialGroup] = {}
+            latest: Dict[Tuple[str, str], datetime] = {}
+            for event in events.get("items", []):
+                denial = classify_admission_denial(event.get("message") or "")
+                if denial is None:
+                    continue
+                output.total_events += 1
+                key = (denial["category"], denial["policy"])
+                group = groups.setdefault(key, AdmissionDenialGroup(**denial))
+                group.count += event.get("count") or (event.get("series") or {}).get("count") or 1
+                involved = event.get("involvedObject") or {}
+                target = f"{involved.get('namespace', '')}/{involved.get('kind', '')}/{involved.get('name', '')}"
+                if target not in group.objects:
+                    group.objects.append(target)
+                seen = event_time(event)
+                if seen and (key not in latest or seen > latest[key]):
+                    latest[key] = seen
+                    group.last_seen = seen.isoformat().replace("+00:00", "Z")
+                    group.sample_message = (event.get("message") or "").strip()
+                elif not group.sample_message:
+                    group.sample_message = (event.get("message") or "").strip()
+            output.groups = sorted(groups.values(), key=lambda g: g.count, reverse=True)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_admission_denials failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_admission_denials")
+            output.error = ErrorModel(error_code="AdmissionDenialScanFailed", error_message=str(e))
+            return output
+
+    async def kubectl_service_endpoints(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="Service 所在命名空间"),
+        service: str = Field(..., description="Service 名称"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ServiceEndpointsOutput:
+        """列出 Service 的就绪与未就绪端点及其对应的 Pod 和节点"""
+        execution_log, start_ms = start_execution_log(
+            "kubectl_service_endpoints", cluster_id, self.enable_execution_log
+        )
+        output = ServiceEndpointsOutput(
+            cluster_id=cluster_id, namespace=namespace, service=service, execution_log=execution_log
+        )
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            svc = await self.runner.run_json(
+                kubeconfig_path, ["get", "service", service, "-n", namespace, "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            spec = svc.get("spec") or {}
+            output.service_type = spec.get("type") or "ClusterIP"
+            output.selector = spec.get("selector") or {}
+            output.ports = [
+                {"name": p.get("name"), "port": p.get("port"), "target_port": p.get("targetPort", p.get("port")),
+                 "protocol": p.get("protocol") or "TCP"}
+                for p in spec.get("ports") or []
+            ]
+            if output.service_type == "ExternalName":
+                output.status = "ExternalName"
+                output.message = (f"Service {namespace}/{service} is an ExternalName alias for "
+                                  f"{spec.get('externalName')} and has no endpoints")
+                finish_execution_log(execution_log, start_ms)
+                return output
+
+            entries, endpoint_ports = await self._service_endpoint_entries(
+                kubeconfig_path, namespace, service, execution_log, timeout
+            )
+            output.endpoint_ports = endpoint_ports
+
+            pods: List[Dict[str, Any]] = []
+            if output.selector:
+                selector = ",".join(f"{k}={v}" for k, v in sorted(output.selector.items()))
+                pods = (await self.runner.run_json(
+                    kubeconfig_path, ["get", "pods", "-n", namespace, "-l", selector, "-o", "json"],
+                    execution_log, timeout=timeout,
+                )).get("items", [])
+                output.matching_pods = len(pods)
+            self._correlate_endpoint_pods(entries, pods)
+
+            output.endpoints = [ServiceEndpoint(**entry) for entry in entries]
+            output.endpoints.sort(key=lambda e: (not e.ready, e.pod or "", e.address))
+            output.ready_count = sum(1 for e in output.endpoints if e.ready)
+            output.not_ready_count = len(output.endpoints) - output.ready_count
+            output.status, output.message = self._endpoint_status(output, pods)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_service_endpoints failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_service_endpoints")
+            output.error = ErrorModel(error_code="ServiceEndpointsFailed", error_message=str(e))
+            return output
+
+    async def _service_endpoint_entries(
+        self,
+        kubeconfig_path: str,
+        namespace: str,
+        service: str,
+        execution_log: ExecutionLog,
+        timeout: Optional[int],
+    ) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]]]:
+        """读取 Service 的 EndpointSlice，集群不支持时回退为 Endpoints，返回 (端点, 端点端口)"""
+        ports: List[Dict[str, Any]] = []
+
+        def add_ports(items: List[Dict[str, Any]]):
+            for port in items:
+                entry = {"name": port.get("name"), "port": port.get("port"), "protocol": port.get("protocol") or "TCP"}
+                if entry not in ports:
+                    ports.append(entry)
+
+        try:
+            slices = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "endpointslices.discovery.k8s.io", "-n", namespace,
+                 "-l", f"{SERVICE_NAME_LABEL}={service}", "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+        except KubectlCommandError as e:
+            if "the server doesn't have a resource type" not in str(e):
+                raise
+            logger.debug(f"EndpointSlice not supported, falling back to Endpoints: {e}")
+            endpoints = await self.runner.run_json(
+                kubeconfig_path, ["get", "endpoints", service, "-n", namespace, "--ignore-not-found", "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            for subset in endpoints.get("subsets") or []:
+                add_ports(subset.get("ports") or [])
+            return endpoints_entries(endpoints), ports
+
+        entries: List[Dict[str, Any]] = []
+        seen = set()
+        for endpoint_slice in slices.get("items", []):
+            add_ports(endpoint_slice.get("ports") or [])
+            for entry in endpoint_slice_entries(endpoint_slice):
+                # 双栈 Service 的 IPv4/IPv6 分属不同 EndpointSlice，同一地址只计一次
+                if entry["address"] not in seen:
+                    seen.add(entry["address"])
+                    entries.append(entry)
+        return entries, ports
+
+    @staticmethod
+    def _correlate_endpoint_pods(entries: List[Dict[str, Any]], pods: List[Dict[str, Any]]):
+        """按 Pod 名称或 IP 关联端点与 Pod，补全缺失的 Pod/节点并记录未就绪原因"""
+        by_name = {(p.get("metadata") or {}).get("name"): p for p in pods}
+        by_ip = {}
+        for pod in pods:
+            for ip in [(pod.get("status") or {}).get("podIP")] + [
+                i.get("ip") for i in (pod.get("status") or {}).get("podIPs") or []
+            ]:
+                if ip:
+                    by_ip[ip] = pod
+        for entry in entries:
+            pod = by_name.get(entry.get("pod")) or by_ip.get(entry["address"])
+            if pod is None:
+                continue
+            entry["pod"] = entry.get("pod") or (pod.get("metadata") or {}).get("name")
+            entry["node"] = entry.get("node") or (pod.get("spec") or {}).get("nodeName")
+            if not entry["ready"]:
+                entry["pod_problem"] = pod_problem(pod) or ("Terminating" if entry.get("terminating") else None)
+
+    @staticmethod
+    def _endpoint_status(output: ServiceEndpointsOutput, pods: List[Dict[str, Any]]) -> Tuple[str, Optional[str]]:
+        """根据端点与选择器匹配的 Pod 判断 Service 状态，没有就绪端点时给出可能的原因"""
+        if output.ready_count:
+            return "Ready", None
+        status = "NoReadyEndpoints" if output.endpoints else "NoEndpoints"
+        prefix = (f"Service {output.namespace}/{output.service} has no ready endpoints; "
+                  f"requests to it will fail (connection refused or 503 from ingress)")
+        if not output.selector:
+            reason = ("the Service has no selector, so endpoints must be managed manually "
+                      "(EndpointSlice labeled kubernetes.io/service-name) and none are ready")
+        elif not pods:
+            selector = ",".join(f"{k}={v}" for k, v in sorted(output.selector.items()))
+            reason = f"selector {selector} matches no pods in namespace {output.namespace}; check the pod labels"
+        else:
+            ready_pods = [p for p in pods if pod_problem(p) is None and (p.get("status") or {}).get("phase") == "Running"]
+            if not ready_pods:
+                problems: Dict[str, int] = {}
+                for pod in pods:
+                    problem = pod_problem(pod) or "NotReady"
+                    problems[problem] = problems.get(problem, 0) + 1
+                summary = ", ".join(f"{n} {p}" for p, n in sorted(problems.items(), key=lambda i: (-i[1], i[0])))
+                reason = f"{len(pods)} pod(s) match the selector but none are ready ({summary})"
+            else:
+                reason = (f"{len(ready_pods)} matching pod(s) are ready but not listed as ready endpoints; "
+                          f"check that the Service targetPort matches a container port")
+        return status, f"{prefix}: {reason}"
+
+    async def kubectl_unhealthy_pods(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间，all 表示全部命名空间"),
+        label_selector: Optional[str] = Field(None, description="标签选择器，如 app=web"),
+        restart_threshold: int = Field(DEFAULT_RESTART_THRESHOLD, description="重启次数达到该值的容器视为异常"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> UnhealthyPodsOutput:
+        """找出容器异常的 Pod，按严重程度排序"""
+        execution_log, start_ms = start_execution_log("kubectl_unhealthy_pods", cluster_id, self.enable_execution_log)
+        output = UnhealthyPodsOutput(
+            cluster_id=cluster_id, namespace=normalize_namespace(namespace), label_selector=label_selector,
+            restart_threshold=restart_threshold, execution_log=execution_log,
+        )
+        try:
+            try:
+                if restart_threshold < 1:
+                    raise ValueError("restart_threshold must be at least 1")
+                if label_selector:
+                    validate_label_selector(label_selector)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            args = ["get", "pods", *self._namespace_args(namespace)]
+            if label_selector:
+                args += ["-l", label_selector]
+            pods = (await self.runner.run_json(
+                kubeconfig_path, [*args, "-o", "json"], execution_log, timeout=timeout,
+            )).get("items", [])
+            output.scanned = len(pods)
+
+            for pod in pods:
+                containers = unhealthy_containers(pod, restart_threshold)
+                if not containers:
+                    continue
+                metadata = pod.get("metadata") or {}
+                owner_kind, owner_name = pod_owner(pod)
+                output.pods.append(UnhealthyPod(
+                    namespace=metadata.get("namespace") or "",
+                    name=metadata.get("name") or "",
+                    phase=(pod.get("status") or {}).get("phase"),
+                    node=(pod.get("spec") or {}).get("nodeName"),
+                    owner=f"{owner_kind}/{owner_name}" if owner_kind != "Pod" else None,
+                    problem=containers[0]["problem"],
+                    severity=containers[0]["severity"],
+                    restart_count=pod_restart_count(pod),
+                    containers=[ContainerProblem(**container) for container in containers],
+                ))
+            output.pods.sort(key=lambda p: (-p.severity, -p.restart_count, p.namespace, p.name))
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_unhealthy_pods failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_unhealthy_pods")
+            output.error = ErrorModel(error_code="UnhealthyPodsFailed", error_message=str(e))
+            return output
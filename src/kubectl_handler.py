@@ -1,16 +1,60 @@
 from typing import Any
 from fastmcp import FastMCP, Context
 from pydantic import Field
+import hashlib
 import os
-import subprocess
-from typing import Dict, Optional
+import re
+import shlex
+import threading
+import yaml
+from typing import Dict, List, Optional
 from cachetools import TTLCache
 from loguru import logger
 from ack_cluster_handler import parse_master_url
+from inline_kubeconfig import current_inline_kubeconfig
+from kubectl_helpers import LONG_RUNNING_TIMEOUTS, STREAMING_OPERATIONS, kubectl_operation, resolve_timeout
+from kubectl_resources import DEFAULT_NAMESPACE
 from models import KubectlOutput, ExecutionLog, enable_execution_log_ctx
 import time
 from datetime import datetime
 
+# Pod 内 ServiceAccount 凭证挂载目录
+INCLUSTER_SERVICEACCOUNT_DIR = "/var/run/secrets/kubernetes.io/serviceaccount"
+INCLUSTER_TOKEN_FILE = os.path.join(INCLUSTER_SERVICEACCOUNT_DIR, "token")
+
+# 命名空间名称须为 DNS-1123 label
+_NAMESPACE_NAME_RE = re.compile(r"^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$")
+
+
+def incluster_namespace() -> Optional[str]:
+    """运行在集群内 Pod 中时返回 Pod 自身所在的命名空间，否则返回 None"""
+    namespace_file = os.path.join(INCLUSTER_SERVICEACCOUNT_DIR, "namespace")
+    if not os.getenv("KUBERNETES_SERVICE_HOST") or not os.path.exists(namespace_file):
+        return None
+    try:
+        with open(namespace_file) as f:
+            return f.read().strip() or None
+    except OSError as e:
+        logger.warning(f"Failed to read {namespace_file}: {e}")
+        return None
+
+
+def resolve_default_namespace(configured: Optional[str] = None) -> str:
+    """工具未指定命名空间时使用的默认命名空间
+
+    优先级：--default-namespace / DEFAULT_NAMESPACE > 集群内运行时 Pod 所在命名空间 > default
+
+    Raises:
+        ValueError: 配置的命名空间不是合法的 DNS-1123 label
+    """
+    if configured and configured.strip():
+        namespace = configured.strip()
+        if not _NAMESPACE_NAME_RE.match(namespace):
+            raise ValueError(f"invalid namespace '{namespace}': must be a lowercase RFC 1123 label")
+        return namespace
+    return incluster_namespace() or DEFAULT_NAMESPACE
+
+
 class KubectlContextManager(TTLCache):
     """基于 TTL+LRU 缓存的 kubeconfig 文件管理器"""
 
@@ -25,6 +69,9 @@ class KubectlContextManager(TTLCache):
 
         self._cs_client = None  # CS客户端实例
         self.do_not_cleanup_file = None  # 本地kubeconfig文件路径，不需要清理
+        self._protected_files = set()  # 用户提供的 kubeconfig 文件（LOCAL 模式、kubeconfig 目录），不需要清理
+        self._source_mtimes: Dict[tuple, float] = {}  # context kubeconfig 缓存项 -> 生成时源文件的修改时间
+        self._lock = threading.RLock()  # 工具调用可能在线程中并发执行，TTLCache 本身不是线程安全的
 
         # 使用 .kube 目录存储 kubeconfig 文件
         self._kube_dir = os.path.expanduser("~/.kube")
@@ -76,18 +123,20 @@ class KubectlContextManager(TTLCache):
                         pass
 
             if removed_count > 0:
-                print(f"Cleaned up {removed_count} MCP kubeconfig files")
+                logger.info(f"Cleaned up {removed_count} MCP kubeconfig files")
         except Exception:
             pass
 
-    def _get_or_create_kubeconfig_file(self, cluster_id: str, kubeconfig_mode: str, kubeconfig_path: str, execution_log: ExecutionLog) -> str:
+    def _get_or_create_kubeconfig_file(self, cluster_id: str, kubeconfig_mode: str, kubeconfig_path: str, execution_log: ExecutionLog,
+                                       kubeconfig_dir: Optional[str] = None) -> str:
         """获取或创建集群的 kubeconfig 文件
 
         Args:
             cluster_id: 集群ID
-            kubeconfig_mode: 获取kubeconfig的模式，支持 "ACK_PUBLIC", "ACK_PRIVATE", "LOCAL"
-            kubeconfig_path: 本地kubeconfig文件路径（仅在模式为LOCAL时使用）
+            kubeconfig_mode: 获取kubeconfig的模式，支持 "ACK_PUBLIC", "ACK_PRIVATE", "INCLUSTER", "LOCAL", "AUTO"
+            kubeconfig_path: 本地kubeconfig文件路径（仅在模式为LOCAL或AUTO回退时使用）
             execution_log: 执行日志
+            kubeconfig_dir: 预置 kubeconfig 目录，存在以集群ID命名的文件时优先使用，否则按 kubeconfig_mode 获取
             
         Returns:
             kubeconfig 文件路径
@@ -103,6 +152,24 @@ class KubectlContextManager(TTLCache):
             })
             return self[cluster_id]
 
+        if kubeconfig_dir:
+            dir_kubeconfig = self._find_cluster_kubeconfig(kubeconfig_dir, cluster_id)
+            if dir_kubeconfig:
+                self._protected_files.add(dir_kubeconfig)
+                logger.debug(f"Using kubeconfig for cluster {cluster_id} from directory: {dir_kubeconfig}")
+                execution_log.api_calls.append({
+                    "api": "GetKubeconfig",
+                    "source": "kubeconfig_dir",
+                    "cluster_id": cluster_id,
+                    "path": dir_kubeconfig,
+                    "status": "success"
+                })
+                self[cluster_id] = dir_kubeconfig
+                return dir_kubeconfig
+
+        if kubeconfig_mode == "AUTO":
+            kubeconfig_mode = self._resolve_auto_mode()
+
         if kubeconfig_mode == "INCLUSTER":
             # 使用集群内配置
             logger.debug(f"Using in-cluster kubeconfig for cluster {cluster_id}")
@@ -125,6 +192,7 @@ class KubectlContextManager(TTLCache):
             if not os.path.exists(kubeconfig_path):
                 raise ValueError(f"File {kubeconfig_path} does not exist")
             self.do_not_cleanup_file = kubeconfig_path
+            self._protected_files.add(kubeconfig_path)
             logger.debug(f"Using local kubeconfig for cluster {cluster_id} from {kubeconfig_path}")
             execution_log.api_calls.append({
                 "api": "GetKubeconfig",
@@ -157,12 +225,100 @@ class KubectlContextManager(TTLCache):
         self[cluster_id] = kubeconfig_path
         return kubeconfig_path
 
+    @staticmethod
+    def _find_cluster_kubeconfig(kubeconfig_dir: str, cluster_id: str) -> Optional[str]:
+        """在 kubeconfig 目录中查找以集群ID命名的文件（<cluster_id>、<cluster_id>.yaml/.yml/.kubeconfig）"""
+        if not cluster_id or os.sep in cluster_id or (os.altsep and os.altsep in cluster_id) or cluster_id.startswith("."):
+            return None
+        base_dir = os.path.abspath(os.path.expanduser(kubeconfig_dir))
+        for suffix in ("", ".yaml", ".yml", ".kubeconfig"):
+            candidate = os.path.join(base_dir, cluster_id + suffix)
+            if os.path.isfile(candidate):
+                return candidate
+        return None
+
+    def _is_protected(self, path: str) -> bool:
+        """是否为用户提供、不应被清理的 kubeconfig 文件"""
+        return os.path.abspath(path) in {os.path.abspath(p) for p in self._protected_files}
+
+    def _get_or_create_context_kubeconfig(self, kubeconfig_path: str, context: str, execution_log: ExecutionLog) -> str:
+        """基于已有 kubeconfig 生成仅包含指定 context 的 kubeconfig 文件，按 (路径, context) 缓存
+
+        Args:
+            kubeconfig_path: 源 kubeconfig 文件路径
+            context: context 名称
+            execution_log: 执行日志
+
+        Returns:
+            kubeconfig 文件路径
+        """
+        cache_key = ("context", kubeconfig_path, context)
+        source_mtime = os.path.getmtime(kubeconfig_path)
+        if cache_key in self and self._source_mtimes.get(cache_key) != source_mtime:
+            # 源 kubeconfig 已被修改（如凭据轮转），重新生成
+            logger.debug(f"Kubeconfig {kubeconfig_path} changed, regenerating context {context}")
+            del self[cache_key]
+        if cache_key in self:
+            execution_log.api_calls.append({
+                "api": "GetKubeconfig",
+                "source": "cache",
+                "context": context,
+                "status": "success"
+            })
+            return self[cache_key]
+
+        with open(kubeconfig_path) as f:
+            config = yaml.safe_load(f) or {}
+        contexts = {c.get("name"): c.get("context") or {} for c in config.get("contexts") or []}
+        if context not in contexts:
+            available = ", ".join(sorted(name for name in contexts if name)) or "<none>"
+            raise ValueError(f"Context '{context}' not found in kubeconfig, available contexts: {available}")
+
+        selected = contexts[context]
+        base_dir = os.path.dirname(kubeconfig_path)
+
+        def pick(section: str, name: Optional[str]) -> list:
+            entries = [dict(e) for e in config.get(section) or [] if e.get("name") == name]
+            for entry in entries:
+                # 相对路径以源 kubeconfig 所在目录为基准，生成的文件位于 ~/.kube 下
+                body = dict(entry.get(section[:-1]) or {})
+                for key in ("certificate-authority", "client-certificate", "client-key", "tokenFile"):
+                    if body.get(key) and not os.path.isabs(body[key]):
+                        body[key] = os.path.join(base_dir, body[key])
+                entry[section[:-1]] = body
+            return entries
+
+        derived = {
+            "apiVersion": "v1",
+            "kind": "Config",
+            "clusters": pick("clusters", selected.get("cluster")),
+            "users": pick("users", selected.get("user")),
+            "contexts": [{"name": context, "context": selected}],
+            "current-context": context,
+        }
+        digest = hashlib.sha256(f"{kubeconfig_path}\0{context}".encode("utf-8")).hexdigest()[:12]
+        derived_path = os.path.join(self._kube_dir, f"mcp-kubeconfig-context-{digest}.yaml")
+        fd = os.open(derived_path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600)
+        with os.fdopen(fd, "w") as f:
+            yaml.safe_dump(derived, f, sort_keys=False)
+        logger.debug(f"Using context {context} from {kubeconfig_path}")
+        execution_log.api_calls.append({
+            "api": "GetKubeconfig",
+            "source": "context",
+            "context": context,
+            "status": "success"
+        })
+        self[cache_key] = derived_path
+        self._source_mtimes[cache_key] = source_mtime
+        return derived_path
+
     def popitem(self):
         """重写 popitem 方法，在驱逐缓存项时清理 kubeconfig 文件"""
         key, path = super().popitem()
+        self._source_mtimes.pop(key, None)
         # 删除 kubeconfig 文件
         if path and os.path.exists(path):
-            if self.do_not_cleanup_file and os.path.samefile(path, self.do_not_cleanup_file):
+            if self._is_protected(path):
                 logger.debug(f"Skipped removal of protected kubeconfig file: {path}")
                 return
             try:
@@ -178,8 +334,8 @@ class KubectlContextManager(TTLCache):
         removed_count = 0
         for key, path in list(self.items()):
             if path and os.path.exists(path):
-                # 只有当do_not_cleanup_file存在且路径不同时才清理
-                if self.do_not_cleanup_file and os.path.samefile(path, self.do_not_cleanup_file):
+                # 用户提供的 kubeconfig 文件不清理
+                if self._is_protected(path):
                     continue
                 try:
                     os.remove(path)
@@ -187,7 +343,8 @@ class KubectlContextManager(TTLCache):
                 except Exception:
                     pass
         self.clear()
-        print(f"Cleaned up {removed_count} kubeconfig files")
+        self._source_mtimes.clear()
+        logger.info(f"Cleaned up {removed_count} kubeconfig files")
 
     def set_cs_client(self, cs_client):
         """设置CS客户端
@@ -329,14 +486,28 @@ class KubectlContextManager(TTLCache):
             logger.error(f"Failed to fetch kubeconfig for cluster {cluster_id}: {e}")
             raise e
 
+    def _resolve_auto_mode(self) -> str:
+        """AUTO 模式：优先使用 Pod ServiceAccount 的集群内配置，不可用时回退到本地 kubeconfig 文件"""
+        host, port = os.getenv("KUBERNETES_SERVICE_HOST"), os.getenv("KUBERNETES_SERVICE_PORT")
+        if not host or not port:
+            logger.info("KUBECONFIG_MODE=AUTO: KUBERNETES_SERVICE_HOST/PORT not set, falling back to LOCAL kubeconfig")
+            return "LOCAL"
+        if not os.path.exists(INCLUSTER_TOKEN_FILE):
+            logger.info(f"KUBECONFIG_MODE=AUTO: {INCLUSTER_TOKEN_FILE} not found, falling back to LOCAL kubeconfig")
+            return "LOCAL"
+        logger.info("KUBECONFIG_MODE=AUTO: using in-cluster service account configuration")
+        return "INCLUSTER"
+
     def _construct_incluster_kubeconfig(self) -> str:
         """构造集群内 kubeconfig 文件路径
         
         Returns:
             kubeconfig 文件路径
         """
-        tokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
-        rootCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+        tokenFile = INCLUSTER_TOKEN_FILE
+        rootCAFile = os.path.join(INCLUSTER_SERVICEACCOUNT_DIR, "ca.crt")
+        # 默认命名空间使用 Pod 自身所在命名空间
+        namespace = incluster_namespace() or DEFAULT_NAMESPACE
         host, port = os.getenv("KUBERNETES_SERVICE_HOST"), os.getenv("KUBERNETES_SERVICE_PORT")
         if not host or not port:
             raise ValueError("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
@@ -352,6 +523,7 @@ clusters:
 contexts:
 - context:
     cluster: in-cluster
+    namespace: {namespace}
     user: in-cluster
   name: in-cluster
 current-context: in-cluster
@@ -363,7 +535,8 @@ users:
 """)
         return kubeconfig_path
 
-    def get_kubeconfig_path(self, cluster_id: str, kubeconfig_mode: str, kubeconfig_path: str, execution_log: ExecutionLog) -> str:
+    def get_kubeconfig_path(self, cluster_id: str, kubeconfig_mode: str, kubeconfig_path: str, execution_log: ExecutionLog,
+                            context: Optional[str] = None, kubeconfig_dir: Optional[str] = None) -> str:
         """获取集群的 kubeconfig 文件路径
 
         Args:
@@ -371,11 +544,29 @@ users:
             kubeconfig_mode: 获取kubeconfig的模式，支持 "ACK_PUBLIC", "ACK_PRIVATE", "LOCAL"
             kubeconfig_path: 本地kubeconfig文件路径（仅在模式为LOCAL时使用）
             execution_log: 执行日志
+            context: 可选的 kubeconfig context 名称，为空时使用 current-context
+            kubeconfig_dir: 预置 kubeconfig 目录（按集群ID查找），为空时不使用
             
         Returns:
             kubeconfig 文件路径
         """
-        return self._get_or_create_kubeconfig_file(cluster_id, kubeconfig_mode, kubeconfig_path, execution_log)
+        inline_path = current_inline_kubeconfig()
+        if inline_path:
+            # 调用方随请求传入的 kubeconfig（context 已在解码时选定），不落盘也不缓存
+            execution_log.api_calls.append({
+                "api": "GetKubeconfig",
+                "source": "inline",
+                "cluster_id": cluster_id,
+                "status": "success"
+            })
+            return inline_path
+        with self._lock:
+            path = self._get_or_create_kubeconfig_file(
+                cluster_id, kubeconfig_mode, kubeconfig_path, execution_log, kubeconfig_dir
+            )
+            if not isinstance(context, str) or not context:
+                return path
+            return self._get_or_create_context_kubeconfig(path, context, execution_log)
 
 
 # 全局上下文管理器实例
@@ -414,6 +605,7 @@ class KubectlHandler:
 
         # 超时配置
         self.kubectl_timeout = self.settings.get("kubectl_timeout", 30)
+        self.max_tool_timeout = self.settings.get("max_tool_timeout", 600)
 
         # 是否可写变更配置
         self.allow_write = self.settings.get("allow_write", False)
@@ -421,6 +613,10 @@ class KubectlHandler:
         # Per-handler toggle
         self.enable_execution_log = self.settings.get("enable_execution_log", False)
 
+        # 以参数列表方式执行 kubectl（kubectl_runner 依赖本模块的 get_context_manager，需延迟导入）
+        from kubectl_runner import KubectlRunner
+        self.runner = KubectlRunner(self.settings)
+
         if server is None:
             return
         self.server = server
@@ -517,8 +713,13 @@ class KubectlHandler:
 
         return False, None
 
+    def get_command_timeout(self, command: str, timeout_seconds: Optional[int] = None) -> int:
+        """获取命令超时：按命令类型取默认值，可被 timeout_seconds 覆盖，且不超过 max_tool_timeout"""
+        default = LONG_RUNNING_TIMEOUTS.get(kubectl_operation(command), self.kubectl_timeout)
+        return resolve_timeout(timeout_seconds, default, self.max_tool_timeout)
+
     def is_streaming_command(self, command: str) -> tuple[bool, Optional[str]]:
-        """检查是否为流式命令
+        """检查是否为流式命令（logs -f、get -w、attach），与服务退出时立即取消的调用一致
 
         Args:
             command: kubectl 命令字符串
@@ -526,175 +727,47 @@ class KubectlHandler:
         Returns:
             (是否为流式命令, 流式类型)
         """
-        is_watch = " get " in command and " -w" in command
-        is_logs = " logs " in command and " -f" in command
-        is_attach = " attach " in command
-
-        if is_watch:
-            return True, "watch"
-        if is_logs:
-            return True, "logs"
-        if is_attach:
-            return True, "attach"
+        operation = kubectl_operation(command)
+        if operation in STREAMING_OPERATIONS:
+            return True, operation
 
         return False, None
 
-    def run_streaming_command(self, command: str, kubeconfig_path: str, timeout: int, execution_log: ExecutionLog) -> Dict[str, Any]:
-        """运行流式命令，支持超时控制"""
+    @staticmethod
+    def parse_command(command: str) -> List[str]:
+        """将 command 按 shell 词法拆分为 kubectl 参数列表（不经过 shell 执行，;、&&、| 等仅作为普通参数）"""
         try:
-            full_command = f"kubectl --kubeconfig {kubeconfig_path} {command}"
-            
-            cmd_start = int(time.time() * 1000)
-            process = subprocess.Popen(
-                full_command,
-                shell=True,
-                stdout=subprocess.PIPE,
-                stderr=subprocess.PIPE,
-                text=True,
-                bufsize=1,
-                universal_newlines=True
-            )
-
-            stdout_lines = []
-            stderr_lines = []
-            process_terminated = False
-
-            def read_stdout():
-                try:
-                    for line in iter(process.stdout.readline, ''):
-                        if line and not process_terminated:
-                            stdout_lines.append(line)
-                except Exception:
-                    pass
-
-            def read_stderr():
-                try:
-                    for line in iter(process.stderr.readline, ''):
-                        if line and not process_terminated:
-                            stderr_lines.append(line)
-                except Exception:
-                    pass
-
-            import threading
-            stdout_thread = threading.Thread(target=read_stdout, daemon=True)
-            stderr_thread = threading.Thread(target=read_stderr, daemon=True)
-
-            stdout_thread.start()
-            stderr_thread.start()
-
-            try:
-                process.wait(timeout=timeout)
-            except subprocess.TimeoutExpired:
-                process_terminated = True
-                process.terminate()
-                try:
-                    process.wait(timeout=2)
-                except subprocess.TimeoutExpired:
-                    process.kill()
-                    process.wait(timeout=1)
-
-            stdout_thread.join(timeout=1)
-            stderr_thread.join(timeout=1)
-
-            cmd_duration = int(time.time() * 1000) - cmd_start
-            exit_code = process.returncode
-            if process_terminated and exit_code is None:
-                exit_code = 124
-            
-            # Log kubectl execution
-            execution_log.api_calls.append({
-                "api": "KubectlCommand",
-                "command": command,
-                "type": "streaming",
-                "duration_ms": cmd_duration,
-                "exit_code": exit_code or 0,
-                "status": "success" if exit_code == 0 else "failed",
-                "timeout": timeout
-            })
-
-            return {
-                "exit_code": exit_code or 0,
-                "stdout": "".join(stdout_lines),
-                "stderr": "".join(stderr_lines)
-            }
-
-        except Exception as e:
-            execution_log.api_calls.append({
-                "api": "KubectlCommand",
-                "command": command,
-                "type": "streaming",
-                "status": "failed",
-                "error": str(e)
-            })
-            return {
-                "exit_code": 1,
-                "stdout": "",
-                "stderr": str(e)
-            }
+            args = shlex.split(command)
+        except ValueError as e:
+            raise ValueError(f"invalid kubectl command: {e}")
+        if not args:
+            raise ValueError("Empty command not allowed")
+        return args
+
+    async def run_streaming_command(self, command: str, kubeconfig_path: str, timeout: int, execution_log: ExecutionLog) -> Dict[str, Any]:
+        """运行流式命令（logs -f、get -w 等），收集 timeout 秒内的输出后终止 kubectl 进程"""
+        stdout_lines: List[str] = []
+
+        async def collect(line: str):
+            stdout_lines.append(line)
+
+        result = await self.runner.stream_lines(
+            kubeconfig_path, self.parse_command(command), execution_log, timeout, collect
+        )
+        return {
+            "exit_code": result["exit_code"],
+            "stdout": "".join(stdout_lines),
+            "stderr": result["stderr"],
+        }
 
-    def run_command(self, command: str, kubeconfig_path: str, timeout: int, execution_log: ExecutionLog) -> Dict[str, Any]:
+    async def run_command(self, command: str, kubeconfig_path: str, timeout: int, execution_log: ExecutionLog) -> Dict[str, Any]:
         """Run a kubectl command and return structured result."""
-        try:
-            full_command = f"kubectl --kubeconfig {kubeconfig_path} {command}"
-            
-            cmd_start = int(time.time() * 1000)
-            result = subprocess.run(
-                full_command,
-                shell=True,
-                capture_output=True,
-                text=True,
-                check=True,
-                timeout=timeout
-            )
-            cmd_duration = int(time.time() * 1000) - cmd_start
-            
-            # Log kubectl execution
-            execution_log.api_calls.append({
-                "api": "KubectlCommand",
-                "command": command,
-                "type": "normal",
-                "duration_ms": cmd_duration,
-                "exit_code": result.returncode,
-                "status": "success",
-                "timeout": timeout
-            })
-            
-            return {
-                "exit_code": result.returncode,
-                "stdout": result.stdout.strip() if result.stdout else "",
-                "stderr": result.stderr.strip() if result.stderr else "",
-            }
-        except subprocess.TimeoutExpired:
-            cmd_duration = int(time.time() * 1000) - cmd_start
-            execution_log.api_calls.append({
-                "api": "KubectlCommand",
-                "command": command,
-                "type": "normal",
-                "duration_ms": cmd_duration,
-                "exit_code": 124,
-                "status": "timeout",
-                "timeout": timeout
-            })
-            return {
-                "exit_code": 124,
-                "stdout": "",
-                "stderr": f"Command timed out after {timeout} seconds",
-            }
-        except subprocess.CalledProcessError as e:
-            cmd_duration = int(time.time() * 1000) - cmd_start
-            execution_log.api_calls.append({
-                "api": "KubectlCommand",
-                "command": command,
-                "type": "normal",
-                "duration_ms": cmd_duration,
-                "exit_code": e.returncode,
-                "status": "failed"
-            })
-            return {
-                "exit_code": e.returncode,
-                "stdout": e.stdout.strip() if e.stdout else "",
-                "stderr": e.stderr.strip() if e.stderr else str(e),
-            }
+        result = await self.runner.run(kubeconfig_path, self.parse_command(command), execution_log, timeout=timeout)
+        return {
+            "exit_code": result["exit_code"],
+            "stdout": result["stdout"].strip(),
+            "stderr": result["stderr"],
+        }
 
     def _register_tools(self):
         """Register kubectl tool."""
@@ -709,6 +782,7 @@ class KubectlHandler:
                     ..., description="""IMPORTANT POLICY: When accessing ACK clusters, you MUST use this tool. Do NOT invoke kubectl via shell or any external mechanism.
 
 Arguments after 'kubectl', e.g. 'get pods -A', 'config get-contexts', 'config use-context <name>'. Don't include the kubectl prefix. 
+The command is split like a shell command line but is NOT run through a shell: pipes, redirections, '&&' and ';' are passed to kubectl as plain arguments. Use -o jsonpath / -l selectors instead of piping to grep.
 
 IMPORTANT: Do not use interactive commands. Instead:
 - Use 'kubectl get -o yaml', 'kubectl patch', or 'kubectl apply' instead of 'kubectl edit'
@@ -745,6 +819,15 @@ assistant: exec my-pod -- /bin/sh -c "your command here"""
                                      "and switch to appropriate context. If you are not sure of cluster id, "
                                      "please use the list_clusters tool to get it first."
                 ),
+                context: Optional[str] = Field(
+                    None, description="Optional kubeconfig context name to use instead of the current-context. "
+                                      "Mainly useful with KUBECONFIG_MODE=LOCAL and a multi-context kubeconfig."
+                ),
+                timeout_seconds: Optional[int] = Field(
+                    None, description="Optional timeout override in seconds. Defaults depend on the command: "
+                                      "exec 120s, logs -f / get -w / attach / port-forward 300s, others use the "
+                                      "server kubectl timeout. Capped by the server max tool timeout."
+                ),
         ) -> KubectlOutput:
 
             # Set per-request context from handler setting
@@ -754,6 +837,7 @@ assistant: exec my-pod -- /bin/sh -c "your command here"""
             start_ms = int(time.time() * 1000)
             execution_log = ExecutionLog(
                 tool_call_id=f"ack_kubectl_{cluster_id}_{start_ms}",
+                tool_name="ack_kubectl",
                 start_time=datetime.utcnow().isoformat() + "Z"
             )
 
@@ -801,15 +885,16 @@ assistant: exec my-pod -- /bin/sh -c "your command here"""
 
                 # 获取 kubeconfig 文件路径
                 context_manager = get_context_manager()
-                kubeconfig_path = context_manager.get_kubeconfig_path(cluster_id, self.settings.get("kubeconfig_mode"), self.settings.get("kubeconfig_path"), execution_log)
+                kubeconfig_path = context_manager.get_kubeconfig_path(cluster_id, self.settings.get("kubeconfig_mode"), self.settings.get("kubeconfig_path"), execution_log, context, self.settings.get("kubeconfig_dir"))
 
                 # 检查是否为流式命令
                 is_streaming, stream_type = self.is_streaming_command(command)
 
+                timeout = self.get_command_timeout(command, timeout_seconds)
                 if is_streaming:
-                    result = self.run_streaming_command(command, kubeconfig_path, self.kubectl_timeout, execution_log)
+                    result = await self.run_streaming_command(command, kubeconfig_path, timeout, execution_log)
                 else:
-                    result = self.run_command(command, kubeconfig_path, self.kubectl_timeout, execution_log)
+                    result = await self.run_command(command, kubeconfig_path, timeout, execution_log)
 
                 execution_log.end_time = datetime.utcnow().isoformat() + "Z"
                 execution_log.duration_ms = int(time.time() * 1000) - start_ms
@@ -0,0 +1,2198 @@
+"""kubectl 结构化工具使用的纯函数辅助逻辑（不依赖 fastmcp / kubectl，便于单元测试）。"""
+
+import base64
+import difflib
+import hashlib
+import ipaddress
+import json
+import re
+import yaml
+from datetime import datetime, timedelta, timezone
+from email.utils import parsedate_to_datetime
+from typing import Dict, Any, Optional, List, Tuple
+
+
+# ==================== 时间解析 ====================
+
+def parse_k8s_time(value: Optional[str]) -> Optional[datetime]:
+    """解析 Kubernetes RFC3339 时间字符串（如 2024-01-01T00:00:00Z）"""
+    if not value:
+        return None
+    try:
+        return datetime.fromisoformat(value.replace("Z", "+00:00"))
+    except ValueError:
+        return None
+
+
+# ==================== 标签与事件 ====================
+
+def selector_matches(match_labels: Optional[Dict[str, str]], labels: Optional[Dict[str, str]]) -> bool:
+    """判断 matchLabels 是否匹配对象标签（空选择器不匹配任何对象）"""
+    if not match_labels:
+        return False
+    labels = labels or {}
+    return all(labels.get(k) == v for k, v in match_labels.items())
+
+
+def label_selector_matches(selector: Optional[Dict[str, Any]], labels: Optional[Dict[str, str]]) -> bool:
+    """判断 LabelSelector（matchLabels + matchExpressions）是否匹配对象标签
+
+    与 policy/v1 PodDisruptionBudget 语义一致：空选择器 {} 匹配全部对象，None 不匹配任何对象。
+    """
+    if selector is None:
+        return False
+    labels = labels or {}
+    if any(labels.get(k) != v for k, v in (selector.get("matchLabels") or {}).items()):
+        return False
+    for expression in selector.get("matchExpressions") or []:
+        key, operator = expression.get("key"), expression.get("operator")
+        values = expression.get("values") or []
+        if operator == "In" and labels.get(key) not in values:
+            return False
+        if operator == "NotIn" and key in labels and labels[key] in values:
+            return False
+        if operator == "Exists" and key not in labels:
+            return False
+        if operator == "DoesNotExist" and key in labels:
+            return False
+    return True
+
+
+_LABEL_NAME_RE = re.compile(r"^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$")
+_DNS_SUBDOMAIN_RE = re.compile(r"^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$")
+_SET_REQUIREMENT_RE = re.compile(r"^(\S+)\s+(in|notin)\s*\((.*)\)$")
+_EQUALITY_REQUIREMENT_RE = re.compile(r"^([^=!\s]+)\s*(==|!=|=)\s*(\S*)$")
+
+
+def _validate_label_key(key: str, kind: str = "label") -> None:
+    prefix, _, name = key.rpartition("/")
+    if prefix and (len(prefix) > 253 or not _DNS_SUBDOMAIN_RE.match(prefix)):
+        raise ValueError(f"invalid {kind} key '{key}': prefix must be a DNS subdomain")
+    if not _LABEL_NAME_RE.match(name):
+        raise ValueError(
+            f"invalid {kind} key '{key}': name must be at most 63 alphanumeric characters, '-', '_' or '.'"
+        )
+
+
+def _validate_label_value(value: str) -> None:
+    if value and not _LABEL_NAME_RE.match(value):
+        raise ValueError(
+            f"invalid label value '{value}': must be at most 63 alphanumeric characters, '-', '_' or '.'"
+        )
+
+
+def _split_requirements(selector: str) -> List[str]:
+    """按逗号拆分选择器，忽略 in/notin 括号内的逗号"""
+    parts, depth, current = [], 0, ""
+    for char in selector:
+        if char == "(":
+            depth += 1
+        elif char == ")":
+            depth -= 1
+        if char == "," and depth == 0:
+            parts.append(current)
+            current = ""
+        else:
+            current += char
+    parts.append(current)
+    return [part.strip() for part in parts]
+
+
+def validate_label_selector(selector: str) -> None:
+    """校验 kubectl -l 标签选择器语法，不合法时抛出 ValueError
+
+    支持 key、!key、key=value、key==value、key!=value、key in (a,b)、key notin (a,b)。
+    """
+    if not selector or not selector.strip():
+        raise ValueError("empty label selector")
+    for requirement in _split_requirements(selector):
+        if not requirement:
+            raise ValueError(f"invalid label selector '{selector}': empty requirement")
+        set_match = _SET_REQUIREMENT_RE.match(requirement)
+        equality_match = _EQUALITY_REQUIREMENT_RE.match(requirement)
+        if set_match:
+            _validate_label_key(set_match.group(1))
+            values = [v.strip() for v in set_match.group(3).split(",")]
+            if values == [""]:
+                raise ValueError(f"invalid label selector '{selector}': {set_match.group(2)} requires values")
+            for value in values:
+                _validate_label_value(value)
+        elif equality_match:
+            _validate_label_key(equality_match.group(1))
+            _validate_label_value(equality_match.group(3))
+        elif requirement.startswith("!"):
+            _validate_label_key(requirement[1:].strip())
+        else:
+            _validate_label_key(requirement)
+
+
+_FIELD_REQUIREMENT_RE = re.compile(r"^([A-Za-z0-9_.\-]+)\s*(==|!=|=)(.*)$")
+
+
+def parse_field_selector(selector: str) -> List[Tuple[str, str, str]]:
+    """解析 kubectl --field-selector 字段选择器，返回 (field, operator, value) 列表，不合法时抛出 ValueError
+
+    支持 field=value、field==value、field!=value，多个条件以逗号分隔；值中的逗号、等号需以反斜杠转义。
+    """
+    if not selector or not selector.strip():
+        raise ValueError("empty field selector")
+    requirements, current, escaped = [], "", False
+    for char in selector:
+        if escaped:
+            current += "\\" + char
+            escaped = False
+        elif char == "\\":
+            escaped = True
+        elif char == ",":
+            requirements.append(current)
+            current = ""
+        else:
+            current += char
+    requirements.append(current)
+    parsed = []
+    for requirement in requirements:
+        match = _FIELD_REQUIREMENT_RE.match(requirement.strip())
+        if not match:
+            raise ValueError(
+                f"invalid field selector '{selector}': expected field=value, field==value or field!=value"
+            )
+        parsed.append((match.group(1), "=" if match.group(2) == "==" else match.group(2), match.group(3).strip()))
+    return parsed
+
+
+def format_event(event: Dict[str, Any]) -> str:
+    """将 Event 对象格式化为单行文本：Kind/name Reason: message (xN)"""
+    involved = event.get("involvedObject") or {}
+    count = event.get("count") or (event.get("series") or {}).get("count") or 1
+    text = f"{involved.get('kind', '')}/{involved.get('name', '')} {event.get('reason', '')}: " \
+           f"{(event.get('message') or '').strip()}"
+    return f"{text} (x{count})" if count > 1 else text
+
+
+def event_time(event: Dict[str, Any]) -> Optional[datetime]:
+    """获取事件最近一次发生时间"""
+    return parse_k8s_time(
+        event.get("lastTimestamp") or event.get("eventTime") or (event.get("metadata") or {}).get("creationTimestamp")
+    )
+
+
+def pod_restart_count(pod: Dict[str, Any]) -> int:
+    """Pod 所有容器的重启次数之和"""
+    statuses = (pod.get("status") or {}).get("containerStatuses") or []
+    return sum(int(cs.get("restartCount") or 0) for cs in statuses)
+
+
+def pod_problem(pod: Dict[str, Any]) -> Optional[str]:
+    """返回 Pod 未就绪的原因（如 CrashLoopBackOff、ImagePullBackOff、Pending），就绪时返回 None"""
+    status = pod.get("status") or {}
+    phase = status.get("phase")
+    if phase == "Succeeded":
+        return None
+    for cs in (status.get("initContainerStatuses") or []) + (status.get("containerStatuses") or []):
+        state = cs.get("state") or {}
+        waiting = state.get("waiting")
+        if waiting and waiting.get("reason") not in (None, "PodInitializing", "ContainerCreating"):
+            return waiting["reason"]
+        terminated = state.get("terminated")
+        if terminated and terminated.get("exitCode", 0) != 0:
+            return terminated.get("reason") or "Error"
+    if phase != "Running":
+        conditions = {c.get("type"): c for c in status.get("conditions") or []}
+        scheduled = conditions.get("PodScheduled") or {}
+        if scheduled.get("status") == "False":
+            return scheduled.get("reason") or "Unschedulable"
+        return phase or "Unknown"
+    ready = next((c for c in status.get("conditions") or [] if c.get("type") == "Ready"), None)
+    if ready and ready.get("status") != "True":
+        return "NotReady"
+    return None
+
+
+# 容器异常原因的严重程度，数值越大越严重；未列出的非零退出按 CONTAINER_ERROR_SEVERITY 计
+CONTAINER_PROBLEM_SEVERITY = {
+    "CrashLoopBackOff": 4,
+    "OOMKilled": 4,
+    "ImagePullBackOff": 3,
+    "ErrImagePull": 3,
+    "InvalidImageName": 3,
+    "CreateContainerConfigError": 3,
+    "CreateContainerError": 3,
+    "RunContainerError": 3,
+    "ContainerCannotRun": 3,
+    "StartError": 3,
+}
+CONTAINER_ERROR_SEVERITY = 2
+# 仅重启次数超过阈值时的严重程度
+HIGH_RESTARTS_SEVERITY = 1
+
+# 常见退出码的含义
+_EXIT_CODE_HINTS = {
+    1: "application error",
+    126: "command found but not executable",
+    127: "command not found in the image",
+    128: "invalid exit argument or container runtime failure",
+    134: "aborted (SIGABRT)",
+    137: "killed (SIGKILL): out of memory or failed liveness probe",
+    139: "segmentation fault (SIGSEGV)",
+    143: "terminated (SIGTERM): stopped by kubelet or did not handle graceful shutdown",
+}
+
+
+def exit_code_hint(exit_code: Optional[int]) -> Optional[str]:
+    """常见容器退出码的含义，未知时返回 None"""
+    if exit_code is None:
+        return None
+    if exit_code in _EXIT_CODE_HINTS:
+        return _EXIT_CODE_HINTS[exit_code]
+    if exit_code > 128:
+        return f"killed by signal {exit_code - 128}"
+    return None
+
+
+def unhealthy_containers(pod: Dict[str, Any], restart_threshold: int) -> List[Dict[str, Any]]:
+    """找出 Pod 中异常的容器：处于 CrashLoopBackOff、镜像拉取失败等等待状态、以非零退出码终止、
+    上次因 OOMKilled 终止，或重启次数达到 restart_threshold
+
+    Returns:
+        每个异常容器的 problem、状态、上次终止原因与退出码及 severity，按严重程度排序
+    """
+    status = pod.get("status") or {}
+    if status.get("phase") == "Succeeded":
+        return []
+    result = []
+    statuses = ([(cs, True) for cs in status.get("initContainerStatuses") or []]
+                + [(cs, False) for cs in status.get("containerStatuses") or []])
+    for cs, init in statuses:
+        state = cs.get("state") or {}
+        waiting = state.get("waiting") or {}
+        terminated = state.get("terminated") or {}
+        last = (cs.get("lastState") or {}).get("terminated") or {}
+        restarts = int(cs.get("restartCount") or 0)
+
+        # problem 优先取当前状态的异常原因，其次为上次 OOMKilled，仅重启过多时为 HighRestarts
+        severity, problem = 0, None
+        if waiting.get("reason") in CONTAINER_PROBLEM_SEVERITY:
+            problem = waiting["reason"]
+            severity = CONTAINER_PROBLEM_SEVERITY[problem]
+        elif terminated and terminated.get("exitCode", 0) != 0:
+            problem = terminated.get("reason") or "Error"
+            severity = CONTAINER_PROBLEM_SEVERITY.get(problem, CONTAINER_ERROR_SEVERITY)
+        if last.get("reason") == "OOMKilled" and severity < CONTAINER_PROBLEM_SEVERITY["OOMKilled"]:
+            problem, severity = "OOMKilled", CONTAINER_PROBLEM_SEVERITY["OOMKilled"]
+        if not severity and restarts >= restart_threshold > 0:
+            problem, severity = "HighRestarts", HIGH_RESTARTS_SEVERITY
+        if not severity:
+            continue
+
+        termination = terminated or last
+        exit_code = termination.get("exitCode")
+        result.append({
+            "name": cs.get("name"),
+            "init_container": init,
+            "problem": problem,
+            "state": next(iter(state), None),
+            "reason": waiting.get("reason") or terminated.get("reason"),
+            "message": waiting.get("message") or terminated.get("message"),
+            "ready": bool(cs.get("ready")),
+            "restart_count": restarts,
+            "last_termination_reason": last.get("reason"),
+            "last_exit_code": last.get("exitCode"),
+            "last_finished_at": last.get("finishedAt"),
+            "exit_code_hint": exit_code_hint(exit_code),
+            "severity": severity,
+        })
+    result.sort(key=lambda c: (-c["severity"], -c["restart_count"], c["name"] or ""))
+    return result
+
+
+# 日志中常见的错误行特征：klog 错误级别前缀（E0102）、结构化日志 level=error、常见错误关键字
+_ERROR_LOG_RE = re.compile(
+    r"(^E\d{4}\s)|(\blevel\W{0,3}(error|fatal)\b)|(\b(error|failed|failure|panic|fatal)\b)",
+    re.IGNORECASE,
+)
+
+
+def extract_error_lines(log_text: str, limit: int = 10) -> List[str]:
+    """提取日志中的错误行，返回最后 limit 行"""
+    lines = [line.strip() for line in (log_text or "").splitlines() if _ERROR_LOG_RE.search(line)]
+    return lines[-limit:] if limit > 0 else []
+
+
+def pod_owner(pod: Dict[str, Any]) -> Tuple[str, str]:
+    """获取 Pod 所属的顶层工作负载 (kind, name)，ReplicaSet 按 pod-template-hash 还原为 Deployment"""
+    metadata = pod.get("metadata") or {}
+    owner = next((o for o in metadata.get("ownerReferences") or [] if o.get("controller")), None)
+    if owner is None:
+        return "Pod", metadata.get("name", "")
+    kind, name = owner.get("kind", ""), owner.get("name", "")
+    template_hash = (metadata.get("labels") or {}).get("pod-template-hash")
+    if kind == "ReplicaSet" and template_hash and name.endswith(f"-{template_hash}"):
+        return "Deployment", name[: -len(template_hash) - 1]
+    return kind, name
+
+
+
+# ==================== 元数据编辑 ====================
+
+# 对象注解键值总大小上限（与 API Server 校验一致）
+MAX_ANNOTATIONS_BYTES = 256 * 1024
+
+
+def parse_metadata_changes(changes: List[str], kind: str = "label") -> Dict[str, Optional[str]]:
+    """解析 kubectl label/annotate 风格的变更：key=value 设置，key- 删除
+
+    kind 为 label 或 annotation，标签值需满足标签语法，注解值不限制。
+
+    Returns:
+        键到新值的映射，删除的键对应 None
+
+    Raises:
+        ValueError: 语法不合法或键重复
+    """
+    if not changes:
+        raise ValueError(f"at least one {kind} change is required: key=value to set, key- to remove")
+    parsed: Dict[str, Optional[str]] = {}
+    for change in changes:
+        change = (change or "").strip()
+        if "=" in change:
+            key, value = change.split("=", 1)
+        elif change.endswith("-"):
+            key, value = change[:-1], None
+        else:
+            raise ValueError(f"invalid {kind} change '{change}': use key=value to set or key- to remove")
+        _validate_label_key(key, kind)
+        if kind == "label" and value is not None:
+            _validate_label_value(value)
+        if key in parsed:
+            raise ValueError(f"{kind} '{key}' is specified more than once")
+        parsed[key] = value
+    return parsed
+
+
+def metadata_merge_patch(
+    current: Optional[Dict[str, str]],
+    changes: Dict[str, Optional[str]],
+    overwrite: bool = False,
+    kind: str = "label",
+) -> Tuple[Dict[str, Optional[str]], List[str]]:
+    """按当前值计算 merge patch（值为 None 表示删除），与 kubectl 一致：未指定 overwrite 时不允许修改已有的不同值
+
+    Returns:
+        (patch, warnings)，删除不存在的键记为 warning
+
+    Raises:
+        ValueError: 需要 overwrite 或注解超出大小上限
+    """
+    current = current or {}
+    patch: Dict[str, Optional[str]] = {}
+    warnings: List[str] = []
+    for key, value in changes.items():
+        if value is None:
+            if key not in current:
+                warnings.append(f"{kind} '{key}' not found, nothing to remove")
+                continue
+        elif current.get(key) == value:
+            continue
+        elif key in current and not overwrite:
+            raise ValueError(f"{kind} '{key}' already has a value ({current[key]}), set overwrite=true to replace it")
+        patch[key] = value
+    if kind == "annotation":
+        merged = {**current, **patch}
+        size = sum(len(k) + len(v) for k, v in merged.items() if v is not None)
+        if size > MAX_ANNOTATIONS_BYTES:
+            raise ValueError(f"annotations would total {size} bytes, exceeding the {MAX_ANNOTATIONS_BYTES} bytes limit")
+    return patch, warnings
+
+
+# patch 类型，与 kubectl patch --type 取值一致
+PATCH_TYPES = ("json", "merge", "strategic")
+
+# JSON Patch (RFC 6902) 支持的操作及其必需字段
+_JSON_PATCH_OPS = {
+    "add": ("path", "value"),
+    "remove": ("path",),
+    "replace": ("path", "value"),
+    "move": ("from", "path"),
+    "copy": ("from", "path"),
+    "test": ("path", "value"),
+}
+
+
+def parse_patch(patch_type: str, patch: str) -> Any:
+    """校验 patch 内容：json 类型须为 JSON Patch 操作数组，merge/strategic 类型须为 JSON 对象
+
+    Returns:
+        解析后的 patch
+
+    Raises:
+        ValueError: patch 类型未知、内容不是合法 JSON 或结构与类型不符
+    """
+    if patch_type not in PATCH_TYPES:
+        raise ValueError(f"invalid patch_type '{patch_type}', must be one of: {', '.join(PATCH_TYPES)}")
+    if not isinstance(patch, str) or not patch.strip():
+        raise ValueError("patch is required")
+    try:
+        parsed = json.loads(patch)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"patch is not valid JSON: {e}")
+
+    if patch_type != "json":
+        if not isinstance(parsed, dict) or not parsed:
+            raise ValueError(f"{patch_type} patch must be a non-empty JSON object")
+        return parsed
+    if not isinstance(parsed, list) or not parsed:
+        raise ValueError("json patch must be a non-empty array of operations, "
+                         "e.g. [{\"op\": \"replace\", \"path\": \"/spec/replicas\", \"value\": 3}]")
+    for index, operation in enumerate(parsed):
+        op = operation.get("op") if isinstance(operation, dict) else None
+        if op not in _JSON_PATCH_OPS:
+            raise ValueError(f"json patch operation {index} must have op set to one of: {', '.join(_JSON_PATCH_OPS)}")
+        for key in _JSON_PATCH_OPS[op]:
+            if key not in operation:
+                raise ValueError(f"json patch operation {index} ({op}) is missing '{key}'")
+            if key in ("path", "from") and not (isinstance(operation[key], str)
+                                                and (operation[key] == "" or operation[key].startswith("/"))):
+                raise ValueError(f"json patch operation {index} ({op}) has invalid {key} '{operation[key]}', "
+                                 f"must be a JSON Pointer starting with /")
+    return parsed
+
+# ==================== 准入拒绝 ====================
+
+# (类别, 匹配模式)，按顺序匹配，命名分组 policy 为拒绝方名称
+_ADMISSION_DENIAL_PATTERNS = [
+    ("WebhookDenied", re.compile(r'admission webhook "(?P<policy>[^"]+)" denied the request')),
+    ("WebhookFailure", re.compile(r'failed calling webhook "(?P<policy>[^"]+)"')),
+    ("ValidatingAdmissionPolicy", re.compile(r"ValidatingAdmissionPolicy '(?P<policy>[^']+)'")),
+    ("ResourceQuota", re.compile(r"exceeded quota: (?P<policy>[^,\s]+)")),
+    ("ResourceQuota", re.compile(r"failed quota: (?P<policy>[^:,\s]+)")),
+    ("PodSecurity", re.compile(r'violates PodSecurity "(?P<policy>[^"]+)"')),
+    ("LimitRange", re.compile(r"(?P<policy>(?:minimum|maximum) (?:cpu|memory|ephemeral-storage) usage per \w+)")),
+    ("ServiceAccount", re.compile(r'serviceaccount "(?P<policy>[^"]+)" not found')),
+]
+
+
+def classify_admission_denial(message: str) -> Optional[Dict[str, str]]:
+    """识别事件消息中的准入拒绝，返回 {"category", "policy"}，非准入拒绝时返回 None"""
+    for category, pattern in _ADMISSION_DENIAL_PATTERNS:
+        match = pattern.search(message or "")
+        if match:
+            return {"category": category, "policy": match.group("policy")}
+    if " is forbidden: " in (message or ""):
+        return {"category": "Forbidden", "policy": "unknown"}
+    return None
+
+
+# ==================== kubectl 错误识别 ====================
+
+_FORBIDDEN_RE = re.compile(
+    r'User "(?P<user>[^"]*)" cannot (?P<verb>\S+) resource "(?P<resource>[^"]+)" in API group "(?P<group>[^"]*)"'
+    r'(?: in the namespace "(?P<namespace>[^"]+)")?'
+)
+_NOT_FOUND_RE = re.compile(r'(?P<resource>[\w.\-/]+) "(?P<name>[^"]+)" not found')
+
+
+def classify_kubectl_error(stderr: str) -> Optional[Dict[str, str]]:
+    """识别 kubectl 输出中的 Forbidden/NotFound/Unauthorized 错误，返回 {"error_code", "error_message"}，无法识别时返回 None"""
+    message = (stderr or "").strip()
+    if "(Forbidden)" in message or " is forbidden: " in message:
+        match = _FORBIDDEN_RE.search(message)
+        if not match:
+            return {"error_code": "Forbidden", "error_message": message}
+        verb, resource, group, namespace = match.group("verb", "resource", "group", "namespace")
+        if verb == "impersonate":
+            return {
+                "error_code": "Forbidden",
+                "error_message": (
+                    f'The server identity "{match.group("user")}" is not allowed to impersonate {resource}, so the call '
+                    f"cannot run as the requested user; grant the server identity a ClusterRole with verbs=[impersonate] "
+                    f"resources=[users, groups, serviceaccounts] via a ClusterRoleBinding, or call without impersonate_user"
+                ),
+            }
+        if namespace:
+            scope = f'in namespace "{namespace}"'
+            hint = f"grant it with a Role in namespace {namespace} bound to the user via a RoleBinding"
+        else:
+            scope = "at the cluster scope"
+            hint = "grant it with a ClusterRole bound to the user via a ClusterRoleBinding"
+        return {
+            "error_code": "Forbidden",
+            "error_message": (
+                f'User "{match.group("user")}" is not allowed to {verb} {resource} (API group "{group}") {scope}; '
+                f'missing permission: verbs=[{verb}] resources=[{resource}] apiGroups=["{group}"], {hint}'
+            ),
+        }
+    if "(Unauthorized)" in message:
+        return {
+            "error_code": "Unauthorized",
+            "error_message": (
+                "API server rejected the credentials (Unauthorized); the kubeconfig token or client certificate "
+                "may be expired or invalid, check the kubeconfig mode and credentials configured for this cluster"
+            ),
+        }
+    if "(NotFound)" in message:
+        match = _NOT_FOUND_RE.search(message)
+        return {
+            "error_code": "NotFound",
+            "error_message": f'{match.group("resource")} "{match.group("name")}" not found' if match else message,
+        }
+    return None
+
+
+# API Server 的临时性错误（限流、超时、暂不可用、etcd 超时、网络抖动），只读请求可重试
+_RETRIABLE_ERROR_MARKERS = (
+    "(TooManyRequests)",
+    "(ServerTimeout)",
+    "(Timeout)",
+    "(ServiceUnavailable)",
+    "(Conflict)",
+    "the server is currently unable to handle the request",
+    "etcdserver: request timed out",
+    "context deadline exceeded",
+    "Client.Timeout exceeded",
+    "TLS handshake timeout",
+    "i/o timeout",
+    "connection reset by peer",
+    "http2: client connection lost",
+)
+
+
+def is_retriable_kubectl_error(stderr: str) -> bool:
+    """是否为可重试的临时性错误；Forbidden/NotFound/Unauthorized 等确定性错误不重试"""
+    message = stderr or ""
+    if classify_kubectl_error(message):
+        return False
+    return any(marker in message for marker in _RETRIABLE_ERROR_MARKERS)
+
+
+# ==================== 发布状态 ====================
+
+# 触发滚动重启时写入 Pod 模板的注解（与 kubectl rollout restart 一致）
+RESTARTED_AT_ANNOTATION = "kubectl.kubernetes.io/restartedAt"
+
+ROLLOUT_CONDITION_TYPES = ("Progressing", "Available")
+
+
+def rollout_blocking_condition(deployment: Dict[str, Any]) -> Optional[str]:
+    """找出阻塞 Deployment 发布的条件（创建 Pod 失败、超过发布期限、可用副本不足）"""
+    by_type = {c.get("type"): c for c in (deployment.get("status") or {}).get("conditions") or []}
+    for condition_type, status in (("ReplicaFailure", "True"), ("Progressing", "False"), ("Available", "False")):
+        condition = by_type.get(condition_type)
+        if condition and condition.get("status") == status:
+            text = f"{condition_type}={status}"
+            if condition.get("reason"):
+                text += f" ({condition['reason']})"
+            return f"{text}: {condition['message']}" if condition.get("message") else text
+    return None
+
+
+def deployment_rollout_status(deployment: Dict[str, Any]) -> Dict[str, Any]:
+    """按 kubectl rollout status 的判定逻辑计算 Deployment 的发布状态
+
+    Returns:
+        state 为 complete（发布完成）、progressing（发布中）或 stuck（超过 progressDeadlineSeconds 未完成），
+        以及副本数与 Progressing/Available 条件
+    """
+    metadata = deployment.get("metadata") or {}
+    spec = deployment.get("spec") or {}
+    status = deployment.get("status") or {}
+    desired = spec.get("replicas", 1)
+    replicas = status.get("replicas") or 0
+    updated = status.get("updatedReplicas") or 0
+    available = status.get("availableReplicas") or 0
+    conditions = [
+        {
+            "type": c.get("type"),
+            "status": c.get("status"),
+            "reason": c.get("reason"),
+            "message": c.get("message"),
+            "last_update_time": c.get("lastUpdateTime") or c.get("lastTransitionTime"),
+        }
+        for c in status.get("conditions") or [] if c.get("type") in ROLLOUT_CONDITION_TYPES
+    ]
+    progressing = next((c for c in conditions if c["type"] == "Progressing"), None)
+
+    name = metadata.get("name")
+    if (metadata.get("generation") or 0) > (status.get("observedGeneration") or 0):
+        state, message = "progressing", f'waiting for deployment "{name}" spec update to be observed'
+    elif progressing and progressing["reason"] == "ProgressDeadlineExceeded":
+        state, message = "stuck", f'deployment "{name}" exceeded its progress deadline: {progressing["message"]}'
+    elif updated < desired:
+        state, message = "progressing", f"{updated} out of {desired} new replicas have been updated"
+    elif replicas > updated:
+        state, message = "progressing", f"{replicas - updated} old replicas are pending termination"
+    elif available < updated:
+        state, message = "progressing", f"{available} of {updated} updated replicas are available"
+    else:
+        state, message = "complete", f'deployment "{name}" successfully rolled out'
+    if spec.get("paused") and state != "complete":
+        message += " (rollout is paused)"
+
+    return {
+        "state": state,
+        "message": message,
+        "paused": bool(spec.get("paused")),
+        "desired_replicas": desired,
+        "updated_replicas": updated,
+        "ready_replicas": status.get("readyReplicas") or 0,
+        "available_replicas": available,
+        "conditions": conditions,
+    }
+
+
+# ==================== 集群概览 ====================
+
+POD_PHASES = ("Running", "Pending", "Succeeded", "Failed", "Unknown")
+
+
+def summarize_cluster_counts(
+    nodes: List[Dict[str, Any]],
+    pods: List[Dict[str, Any]],
+    deployments: List[Dict[str, Any]],
+    namespaces: List[Dict[str, Any]],
+) -> Dict[str, Any]:
+    """汇总节点就绪状态、Pod 阶段、Deployment 可用性与命名空间数量"""
+    ready_nodes = sum(
+        1 for node in nodes
+        if any(c.get("type") == "Ready" and c.get("status") == "True"
+               for c in (node.get("status") or {}).get("conditions") or [])
+    )
+    unschedulable = sum(1 for node in nodes if (node.get("spec") or {}).get("unschedulable"))
+
+    phases = {phase: 0 for phase in POD_PHASES}
+    for pod in pods:
+        phase = (pod.get("status") or {}).get("phase") or "Unknown"
+        phases[phase if phase in phases else "Unknown"] += 1
+
+    # 可用副本数达到期望副本数的 Deployment 视为可用（与 kubectl get deploy 的 AVAILABLE 列一致）
+    available = sum(
+        1 for d in deployments
+        if ((d.get("status") or {}).get("availableReplicas") or 0) >= (d.get("spec") or {}).get("replicas", 1)
+    )
+    return {
+        "nodes": {"total": len(nodes), "ready": ready_nodes, "not_ready": len(nodes) - ready_nodes,
+                  "unschedulable": unschedulable},
+        "pods": {"total": len(pods), **phases},
+        "deployments": {"total": len(deployments), "available": available, "unavailable": len(deployments) - available},
+        "namespaces": len(namespaces),
+    }
+
+
+# ==================== 属主关系 ====================
+
+# 常见属主 (group, Kind) 对应的资源名与是否为命名空间级，其余 Kind（如 CRD 控制器）通过 API 发现解析
+WELL_KNOWN_OWNER_RESOURCES = {
+    ("apps", "Deployment"): ("deployments", True),
+    ("apps", "ReplicaSet"): ("replicasets", True),
+    ("apps", "StatefulSet"): ("statefulsets", True),
+    ("apps", "DaemonSet"): ("daemonsets", True),
+    ("batch", "Job"): ("jobs", True),
+    ("batch", "CronJob"): ("cronjobs", True),
+    ("", "ReplicationController"): ("replicationcontrollers", True),
+    ("", "Node"): ("nodes", False),
+}
+
+# 向下展开属主关系树时，各 Kind 所拥有的子对象资源类型
+OWNED_RESOURCES = {
+    "Deployment": ("replicasets",),
+    "ReplicaSet": ("pods",),
+    "StatefulSet": ("pods",),
+    "DaemonSet": ("pods",),
+    "Job": ("pods",),
+    "CronJob": ("jobs",),
+    "ReplicationController": ("pods",),
+}
+
+
+def controller_owner(obj: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """返回对象的控制者 ownerReference（controller=true），没有时取第一个 ownerReference"""
+    refs = (obj.get("metadata") or {}).get("ownerReferences") or []
+    return next((ref for ref in refs if ref.get("controller")), refs[0] if refs else None)
+
+
+def is_owned_by(obj: Dict[str, Any], owner_uid: str) -> bool:
+    return any(ref.get("uid") == owner_uid for ref in (obj.get("metadata") or {}).get("ownerReferences") or [])
+
+
+def ownership_status(obj: Dict[str, Any]) -> Optional[str]:
+    """对象状态的简要描述（就绪副本数、Pod 阶段等），用于属主关系树"""
+    kind = obj.get("kind")
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    if kind == "Pod":
+        statuses = status.get("containerStatuses") or []
+        ready = sum(1 for cs in statuses if cs.get("ready"))
+        return f"{pod_problem(obj) or status.get('phase')}, {ready}/{len(spec.get('containers') or [])} ready"
+    if kind in ("Deployment", "ReplicaSet", "StatefulSet", "ReplicationController"):
+        return f"{status.get('readyReplicas') or 0}/{spec.get('replicas', 1)} ready"
+    if kind == "DaemonSet":
+        return f"{status.get('numberReady') or 0}/{status.get('desiredNumberScheduled') or 0} ready"
+    if kind == "Job":
+        text = f"{status.get('succeeded') or 0}/{spec.get('completions') or 1} succeeded"
+        return text + (f", {status['failed']} failed" if status.get("failed") else "")
+    if kind == "CronJob":
+        text = f"{len(status.get('active') or [])} active"
+        return text + (f", last scheduled {status['lastScheduleTime']}" if status.get("lastScheduleTime") else "")
+    return None
+
+
+def ownership_node(obj: Dict[str, Any]) -> Dict[str, Any]:
+    """属主关系树中的节点：kind、name、namespace 与简要状态"""
+    metadata = obj.get("metadata") or {}
+    node = {"kind": obj.get("kind"), "name": metadata.get("name")}
+    if metadata.get("namespace"):
+        node["namespace"] = metadata["namespace"]
+    status = ownership_status(obj)
+    if status:
+        node["status"] = status
+    return node
+
+
+# ==================== 节点维护 ====================
+
+# 静态 Pod 在 API Server 中的镜像 Pod 带有该注解，无法通过 API 驱逐
+MIRROR_POD_ANNOTATION = "kubernetes.io/config.mirror"
+
+
+def drain_skip_reason(pod: Dict[str, Any], force: bool = False, delete_emptydir_data: bool = False) -> Optional[str]:
+    """按 kubectl drain 的规则判断 Pod 是否跳过驱逐，返回跳过原因，需要驱逐时返回 None"""
+    metadata = pod.get("metadata") or {}
+    if MIRROR_POD_ANNOTATION in (metadata.get("annotations") or {}):
+        return "mirror pod (static pod managed by kubelet)"
+    if (pod.get("status") or {}).get("phase") in ("Succeeded", "Failed"):
+        return None
+    owner = next((o for o in metadata.get("ownerReferences") or [] if o.get("controller")), None)
+    if owner and owner.get("kind") == "DaemonSet":
+        return f"managed by DaemonSet {owner.get('name')}"
+    if owner is None and not force:
+        return "not managed by a controller, set force=true to evict it (it will not be recreated)"
+    if not delete_emptydir_data and any("emptyDir" in v for v in (pod.get("spec") or {}).get("volumes") or []):
+        return "uses emptyDir local storage, set delete_emptydir_data=true to evict it (the data will be lost)"
+    return None
+
+
+# ==================== RBAC ====================
+
+def pod_template_spec(workload: Dict[str, Any]) -> Dict[str, Any]:
+    """获取工作负载的 Pod spec（支持 Pod、Deployment 等、CronJob）"""
+    kind = workload.get("kind")
+    spec = workload.get("spec") or {}
+    if kind == "Pod":
+        return spec
+    if kind == "CronJob":
+        spec = ((spec.get("jobTemplate") or {}).get("spec")) or {}
+    return ((spec.get("template") or {}).get("spec")) or {}
+
+
+def binding_grants_service_account(binding: Dict[str, Any], service_account: str, namespace: str) -> bool:
+    """判断 RoleBinding/ClusterRoleBinding 是否授予指定 ServiceAccount（含其所属的内置组）"""
+    binding_ns = (binding.get("metadata") or {}).get("namespace")
+    groups = {"system:serviceaccounts", f"system:serviceaccounts:{namespace}", "system:authenticated"}
+    for subject in binding.get("subjects") or []:
+        kind = subject.get("kind")
+        name = subject.get("name")
+        if kind == "ServiceAccount":
+            if name == service_account and (subject.get("namespace") or binding_ns) == namespace:
+                return True
+        elif kind == "User" and name == f"system:serviceaccount:{namespace}:{service_account}":
+            return True
+        elif kind == "Group" and name in groups:
+            return True
+    return False
+
+
+# 高风险权限：可读取凭据、在容器内执行命令、提权或模拟身份
+_SENSITIVE_PERMISSIONS = [
+    ({"get", "list", "watch"}, "secrets", "can read secrets"),
+    ({"create"}, "pods/exec", "can exec into pods"),
+    ({"create"}, "pods/attach", "can attach to pods"),
+    ({"create", "update", "patch"}, "pods", "can create or modify pods (possible privilege escalation via pod spec)"),
+    ({"escalate", "bind"}, "roles", "can escalate or bind roles"),
+    ({"escalate", "bind"}, "clusterroles", "can escalate or bind cluster roles"),
+    ({"create", "update", "patch"}, "rolebindings", "can grant roles"),
+    ({"create", "update", "patch"}, "clusterrolebindings", "can grant cluster roles"),
+    ({"impersonate"}, "users", "can impersonate users"),
+    ({"impersonate"}, "serviceaccounts", "can impersonate service accounts"),
+    ({"create"}, "serviceaccounts/token", "can mint service account tokens"),
+    ({"update", "patch"}, "nodes", "can modify nodes"),
+]
+
+
+def rbac_rule_risks(rule: Dict[str, Any], scope: str) -> List[str]:
+    """识别单条 PolicyRule 中的高风险权限"""
+    verbs = set(rule.get("verbs") or [])
+    resources = set(rule.get("resources") or [])
+    risks = []
+    if "*" in verbs and "*" in resources:
+        risks.append(f"full access to all resources ({scope})")
+        return risks
+    if "*" in resources:
+        risks.append(f"wildcard resources with verbs {sorted(verbs)} ({scope})")
+    for risky_verbs, resource, message in _SENSITIVE_PERMISSIONS:
+        if (resource in resources or "*" in resources) and ("*" in verbs or verbs & risky_verbs):
+            if rule.get("resourceNames"):
+                message += f" (limited to {', '.join(rule['resourceNames'])})"
+            risks.append(f"{message} ({scope})")
+    return risks
+
+
+# ==================== 对象引用 ====================
+
+def _reference(kind: str, name: Optional[str], field: str, namespace: Optional[str] = None) -> Dict[str, Any]:
+    return {"kind": kind, "name": name, "field": field, "namespace": namespace}
+
+
+def pod_spec_references(spec: Dict[str, Any], prefix: str = "spec") -> List[Dict[str, Any]]:
+    """提取 Pod spec 中按名称引用的同命名空间对象（忽略 optional 引用）"""
+    refs: List[Dict[str, Any]] = []
+    if spec.get("serviceAccountName"):
+        refs.append(_reference("ServiceAccount", spec["serviceAccountName"], f"{prefix}.serviceAccountName"))
+    for secret in spec.get("imagePullSecrets") or []:
+        refs.append(_reference("Secret", secret.get("name"), f"{prefix}.imagePullSecrets"))
+    for volume in spec.get("volumes") or []:
+        field = f"{prefix}.volumes[{volume.get('name')}]"
+        if volume.get("configMap") and not volume["configMap"].get("optional"):
+            refs.append(_reference("ConfigMap", volume["configMap"].get("name"), f"{field}.configMap"))
+        if volume.get("secret") and not volume["secret"].get("optional"):
+            refs.append(_reference("Secret", volume["secret"].get("secretName"), f"{field}.secret"))
+        if volume.get("persistentVolumeClaim"):
+            refs.append(_reference(
+                "PersistentVolumeClaim", volume["persistentVolumeClaim"].get("claimName"),
+                f"{field}.persistentVolumeClaim",
+            ))
+        for source in (volume.get("projected") or {}).get("sources") or []:
+            for key, kind in (("configMap", "ConfigMap"), ("secret", "Secret")):
+                if source.get(key) and not source[key].get("optional"):
+                    refs.append(_reference(kind, source[key].get("name"), f"{field}.projected.{key}"))
+    for container in (spec.get("initContainers") or []) + (spec.get("containers") or []):
+        field = f"{prefix}.containers[{container.get('name')}]"
+        for env_from in container.get("envFrom") or []:
+            for key, kind in (("configMapRef", "ConfigMap"), ("secretRef", "Secret")):
+                if env_from.get(key) and not env_from[key].get("optional"):
+                    refs.append(_reference(kind, env_from[key].get("name"), f"{field}.envFrom.{key}"))
+        for env in container.get("env") or []:
+            value_from = env.get("valueFrom") or {}
+            for key, kind in (("configMapKeyRef", "ConfigMap"), ("secretKeyRef", "Secret")):
+                if value_from.get(key) and not value_from[key].get("optional"):
+                    refs.append(_reference(kind, value_from[key].get("name"), f"{field}.env[{env.get('name')}].{key}"))
+    return refs
+
+
+def object_references(obj: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """提取对象按名称引用的其他对象
+
+    Returns:
+        [{"kind", "name", "field", "namespace"}]，namespace 仅在引用中显式指定时非空
+    """
+    kind = obj.get("kind")
+    spec = obj.get("spec") or {}
+    if kind == "Pod":
+        return pod_spec_references(spec)
+    if kind in ("Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job"):
+        return pod_spec_references(pod_template_spec(obj), "spec.template.spec")
+    if kind == "CronJob":
+        return pod_spec_references(pod_template_spec(obj), "spec.jobTemplate.spec.template.spec")
+    if kind == "Ingress":
+        refs: List[Dict[str, Any]] = []
+        default_service = ((spec.get("defaultBackend") or {}).get("service") or {}).get("name")
+        if default_service:
+            refs.append(_reference("Service", default_service, "spec.defaultBackend.service"))
+        for i, rule in enumerate(spec.get("rules") or []):
+            for path in (rule.get("http") or {}).get("paths") or []:
+                service = ((path.get("backend") or {}).get("service") or {}).get("name")
+                if service:
+                    refs.append(_reference(
+                        "Service", service, f"spec.rules[{rule.get('host') or i}].paths[{path.get('path', '/')}]"
+                    ))
+        for tls in spec.get("tls") or []:
+            if tls.get("secretName"):
+                refs.append(_reference("Secret", tls["secretName"], "spec.tls.secretName"))
+        return refs
+    if kind == "PersistentVolumeClaim":
+        refs = []
+        for key in ("dataSource", "dataSourceRef"):
+            source = spec.get(key) or {}
+            if source.get("name"):
+                refs.append(_reference(source.get("kind", ""), source["name"], f"spec.{key}", source.get("namespace")))
+        return refs
+    return []
+
+
+
+# ==================== 服务端点 ====================
+
+# EndpointSlice 上标识所属 Service 的标签
+SERVICE_NAME_LABEL = "kubernetes.io/service-name"
+
+
+def _endpoint_entry(address: str, ready: bool, target: Optional[Dict[str, Any]], node: Optional[str],
+                    source: str, **conditions: Any) -> Dict[str, Any]:
+    target = target or {}
+    return {
+        "address": address,
+        "ready": ready,
+        "pod": target.get("name") if target.get("kind") == "Pod" else None,
+        "node": node,
+        "source": source,
+        **conditions,
+    }
+
+
+def endpoint_slice_entries(endpoint_slice: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """展开 EndpointSlice 中的端点，每个地址一项：address、ready、serving、terminating、pod、node、zone、source"""
+    name = (endpoint_slice.get("metadata") or {}).get("name")
+    entries = []
+    for endpoint in endpoint_slice.get("endpoints") or []:
+        conditions = endpoint.get("conditions") or {}
+        for address in endpoint.get("addresses") or []:
+            entries.append(_endpoint_entry(
+                address,
+                # ready 未设置时视为就绪（API 约定）
+                conditions.get("ready") is not False,
+                endpoint.get("targetRef"),
+                endpoint.get("nodeName"),
+                f"EndpointSlice/{name}",
+                serving=conditions.get("serving"),
+                terminating=conditions.get("terminating"),
+                zone=endpoint.get("zone"),
+            ))
+    return entries
+
+
+def endpoints_entries(endpoints: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """展开 core/v1 Endpoints 中的就绪与未就绪地址（用于不支持 EndpointSlice 的集群）"""
+    name = (endpoints.get("metadata") or {}).get("name")
+    entries = []
+    for subset in endpoints.get("subsets") or []:
+        for key, ready in (("addresses", True), ("notReadyAddresses", False)):
+            for address in subset.get(key) or []:
+                entries.append(_endpoint_entry(
+                    address.get("ip") or address.get("hostname") or "",
+                    ready,
+                    address.get("targetRef"),
+                    address.get("nodeName"),
+                    f"Endpoints/{name}",
+                ))
+    return entries
+
+# ==================== 对象对比 ====================
+
+# API Server 写入的元数据字段，对比时忽略
+SERVER_MANAGED_METADATA = ("uid", "resourceVersion", "creationTimestamp", "generation", "managedFields", "selfLink")
+
+# 常见的内置默认值字段（叶子字段名），对比时可选择忽略以突出 webhook 变更
+BUILTIN_DEFAULT_FIELDS = {
+    "terminationMessagePath", "terminationMessagePolicy", "imagePullPolicy", "dnsPolicy", "restartPolicy",
+    "schedulerName", "securityContext", "terminationGracePeriodSeconds", "enableServiceLinks", "priority",
+    "preemptionPolicy", "serviceAccount", "serviceAccountName", "protocol", "defaultMode", "revisionHistoryLimit",
+    "progressDeadlineSeconds", "strategy", "sessionAffinity", "ipFamilies", "ipFamilyPolicy",
+    "internalTrafficPolicy", "clusterIP", "clusterIPs",
+}
+
+# 内置准入插件添加的条目：ServiceAccount 插件的 token 卷、DefaultTolerationSeconds 插件的容忍
+_BUILTIN_ADMISSION_VOLUME_PREFIX = "kube-api-access-"
+_BUILTIN_TOLERATION_KEYS = {"node.kubernetes.io/not-ready", "node.kubernetes.io/unreachable"}
+
+# 按 name 字段匹配元素的列表
+_NAMED_LIST_KEYS = {"containers", "initContainers", "ephemeralContainers", "volumes", "volumeMounts", "env", "ports"}
+
+
+def strip_server_fields(obj: Dict[str, Any]) -> Dict[str, Any]:
+    """去除 status 及服务端维护的 metadata 字段"""
+    result = {k: v for k, v in obj.items() if k != "status"}
+    metadata = dict(result.get("metadata") or {})
+    for key in SERVER_MANAGED_METADATA:
+        metadata.pop(key, None)
+    result["metadata"] = metadata
+    return result
+
+
+# trim_object 去除的 metadata 字段与注解
+TRIMMED_METADATA = ("managedFields", "generateName")
+TRIMMED_ANNOTATIONS = ("kubectl.kubernetes.io/last-applied-configuration",)
+
+
+def trim_object(obj: Dict[str, Any]) -> Dict[str, Any]:
+    """去除 managedFields、generateName 及 last-applied-configuration 注解等噪声字段，List 对象逐条处理"""
+    if isinstance(obj.get("items"), list):
+        return {**obj, "items": [trim_object(item) if isinstance(item, dict) else item for item in obj["items"]]}
+    metadata = obj.get("metadata")
+    if not isinstance(metadata, dict):
+        return obj
+    metadata = {k: v for k, v in metadata.items() if k not in TRIMMED_METADATA}
+    annotations = metadata.get("annotations")
+    if isinstance(annotations, dict):
+        annotations = {k: v for k, v in annotations.items() if k not in TRIMMED_ANNOTATIONS}
+        if annotations:
+            metadata["annotations"] = annotations
+        else:
+            metadata.pop("annotations")
+    return {**obj, "metadata": metadata}
+
+
+def _secret_value_size(value: Any, encoded: bool) -> int:
+    if not isinstance(value, str):
+        return 0
+    if encoded:
+        try:
+            return len(base64.b64decode(value, validate=True))
+        except ValueError:
+            pass
+    return len(value.encode("utf-8"))
+
+
+def redact_secret_values(obj: Dict[str, Any]) -> Dict[str, Any]:
+    """将 Secret 的 data/stringData 值替换为 "<redacted, N bytes>"，并去除可能包含明文的 last-applied-configuration 注解
+
+    List 接口返回的条目不带 kind，是否为 Secret 由调用方判断。
+    """
+    result = dict(obj)
+    for key, encoded in (("data", True), ("stringData", False)):
+        if isinstance(obj.get(key), dict):
+            result[key] = {k: f"<redacted, {_secret_value_size(v, encoded)} bytes>" for k, v in obj[key].items()}
+    metadata = dict(result.get("metadata") or {})
+    annotations = {
+        k: v for k, v in (metadata.get("annotations") or {}).items()
+        if k != "kubectl.kubernetes.io/last-applied-configuration"
+    }
+    if annotations:
+        metadata["annotations"] = annotations
+    else:
+        metadata.pop("annotations", None)
+    result["metadata"] = metadata
+    return result
+
+
+# 导出时去除的集群相关注解（前缀匹配）
+_EXPORT_DROPPED_ANNOTATION_PREFIXES = (
+    "kubectl.kubernetes.io/last-applied-configuration",
+    "deployment.kubernetes.io/",
+    "pv.kubernetes.io/",
+    "volume.kubernetes.io/",
+    "volume.beta.kubernetes.io/",
+    "control-plane.alpha.kubernetes.io/",
+)
+
+
+def clean_for_export(obj: Dict[str, Any]) -> Dict[str, Any]:
+    """去除服务端字段及集群相关的分配结果（ClusterIP、绑定的 PV、调度到的节点等），得到可在其他集群重新 apply 的对象"""
+    result = strip_server_fields(obj)
+    metadata = result["metadata"]
+    metadata.pop("ownerReferences", None)
+    if metadata.get("name"):
+        metadata.pop("generateName", None)
+    annotations = {
+        k: v for k, v in (metadata.get("annotations") or {}).items()
+        if not k.startswith(_EXPORT_DROPPED_ANNOTATION_PREFIXES)
+    }
+    if annotations:
+        metadata["annotations"] = annotations
+    else:
+        metadata.pop("annotations", None)
+
+    kind = result.get("kind")
+    spec = dict(result.get("spec") or {})
+    if kind == "Service":
+        for key in ("clusterIP", "clusterIPs", "healthCheckNodePort"):
+            spec.pop(key, None)
+        if spec.get("ports"):
+            spec["ports"] = [{k: v for k, v in port.items() if k != "nodePort"} for port in spec["ports"]]
+    elif kind == "PersistentVolumeClaim":
+        spec.pop("volumeName", None)
+    elif kind == "Pod":
+        spec.pop("nodeName", None)
+    elif kind == "ServiceAccount":
+        result.pop("secrets", None)
+    if "spec" in result:
+        result["spec"] = spec
+    return result
+
+
+def json_diff(before: Any, after: Any, path: str = "", parent_key: str = "") -> List[Dict[str, Any]]:
+    """递归对比两个 JSON 对象，返回 added/removed/changed 变更列表（容器、卷、环境变量等按 name 匹配）"""
+    changes: List[Dict[str, Any]] = []
+    if isinstance(before, dict) and isinstance(after, dict):
+        for key in list(before.keys()) + [k for k in after.keys() if k not in before]:
+            child = f"{path}.{key}" if path else key
+            if key not in after:
+                changes.append({"path": child, "op": "removed", "before": before[key]})
+            elif key not in before:
+                changes.append({"path": child, "op": "added", "after": after[key]})
+            else:
+                changes.extend(json_diff(before[key], after[key], child, key))
+        return changes
+    if isinstance(before, list) and isinstance(after, list):
+        named = parent_key in _NAMED_LIST_KEYS and all(
+            isinstance(i, dict) and "name" in i for i in before + after
+        )
+        if named:
+            before_map = {i["name"]: i for i in before}
+            after_map = {i["name"]: i for i in after}
+            for name, item in before_map.items():
+                child = f"{path}[{name}]"
+                if name not in after_map:
+                    changes.append({"path": child, "op": "removed", "before": item})
+                else:
+                    changes.extend(json_diff(item, after_map[name], child))
+            for name, item in after_map.items():
+                if name not in before_map:
+                    changes.append({"path": f"{path}[{name}]", "op": "added", "after": item})
+            return changes
+        for index in range(max(len(before), len(after))):
+            child = f"{path}[{index}]"
+            if index >= len(after):
+                changes.append({"path": child, "op": "removed", "before": before[index]})
+            elif index >= len(before):
+                changes.append({"path": child, "op": "added", "after": after[index]})
+            else:
+                changes.extend(json_diff(before[index], after[index], child))
+        return changes
+    if before != after:
+        changes.append({"path": path, "op": "changed", "before": before, "after": after})
+    return changes
+
+
+def is_builtin_default_change(change: Dict[str, Any]) -> bool:
+    """判断变更是否为内置默认值填充（仅限新增字段，且字段名在常见默认值列表中）"""
+    if change["op"] != "added":
+        return False
+    path = change["path"]
+    after = change.get("after")
+
+    def builtin_item(item: Any) -> bool:
+        return isinstance(item, dict) and (
+            str(item.get("name", "")).startswith(_BUILTIN_ADMISSION_VOLUME_PREFIX)
+            or item.get("key") in _BUILTIN_TOLERATION_KEYS
+        )
+
+    if builtin_item(after) or (isinstance(after, list) and after and all(builtin_item(i) for i in after)):
+        return True
+    leaf = re.sub(r"\[[^\]]*\]$", "", path).rsplit(".", 1)[-1]
+    return leaf in BUILTIN_DEFAULT_FIELDS
+
+
+
+def prune_to_desired(live: Any, desired: Any, parent_key: str = "") -> Any:
+    """按期望对象的结构裁剪实际对象，只保留期望对象中出现的字段（容器、环境变量等按 name 匹配）
+
+    用于对比清单与实际状态时忽略 API Server 填充的默认值及其他管理者维护的字段。
+    """
+    if isinstance(live, dict) and isinstance(desired, dict):
+        return {key: prune_to_desired(live[key], value, key) for key, value in desired.items() if key in live}
+    if isinstance(live, list) and isinstance(desired, list):
+        if parent_key in _NAMED_LIST_KEYS and all(isinstance(i, dict) and "name" in i for i in live + desired):
+            live_map = {item["name"]: item for item in live}
+            return [prune_to_desired(live_map[item["name"]], item) for item in desired if item["name"] in live_map]
+        if len(live) == len(desired):
+            return [prune_to_desired(l, d) for l, d in zip(live, desired)]
+    return live
+
+
+def _secret_string_data_as_data(secret: Dict[str, Any]) -> Dict[str, Any]:
+    """将 Secret 的 stringData 合并为 base64 编码的 data（与 API Server 持久化的形式一致）"""
+    if not isinstance(secret.get("stringData"), dict):
+        return secret
+    data = dict(secret.get("data") or {})
+    for key, value in secret["stringData"].items():
+        data[key] = base64.b64encode(str(value).encode("utf-8")).decode("ascii")
+    return {**{k: v for k, v in secret.items() if k != "stringData"}, "data": data}
+
+
+def _digest_secret_data(secret: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+    """将 Secret 的值替换为摘要，只体现值是否变化而不输出内容"""
+    if not secret or not isinstance(secret.get("data"), dict):
+        return secret
+    digests = {
+        key: f"<redacted, sha256:{hashlib.sha256(str(value).encode('utf-8')).hexdigest()[:12]}>"
+        for key, value in secret["data"].items()
+    }
+    return {**secret, "data": digests}
+
+
+def manifest_diff(live: Optional[Dict[str, Any]], desired: Dict[str, Any]) -> Tuple[str, str]:
+    """对比实际对象与清单中的期望对象，返回 (状态, unified diff)
+
+    状态为 create（对象不存在）、update 或 unchanged。两侧均去除 status 与服务端维护的元数据，实际对象按期望对象的
+    字段裁剪，Secret 的值仅以摘要呈现。
+    """
+    metadata = desired.get("metadata") or {}
+    label = f"{desired.get('kind')}/{metadata.get('name')}"
+    desired = trim_object(strip_server_fields(desired))
+    secret = desired.get("kind") == "Secret"
+    if secret:
+        desired = _secret_string_data_as_data(desired)
+    if live:
+        live = trim_object(strip_server_fields(live))
+        live_namespace = (live.get("metadata") or {}).get("namespace")
+        if live_namespace:
+            desired["metadata"] = {**desired["metadata"], "namespace": live_namespace}
+        live = prune_to_desired(live, desired)
+    if secret:
+        live, desired = _digest_secret_data(live), _digest_secret_data(desired)
+
+    def render(obj: Optional[Dict[str, Any]]) -> List[str]:
+        return yaml.safe_dump(obj, sort_keys=False, allow_unicode=True).splitlines(keepends=True) if obj else []
+
+    before, after = render(live), render(desired)
+    if before == after:
+        return "unchanged", ""
+    status = "update" if live else "create"
+    diff = difflib.unified_diff(before, after, fromfile=f"live/{label}", tofile=f"desired/{label}")
+    return status, "".join(diff)
+
+# ==================== 清单解析 ====================
+
+def parse_manifest(manifest: str) -> List[Dict[str, Any]]:
+    """解析 YAML/JSON 清单（支持以 --- 分隔的多文档及 List 类型），返回待 apply 的对象列表"""
+    try:
+        documents = [doc for doc in yaml.safe_load_all(manifest or "") if doc is not None]
+    except yaml.YAMLError as e:
+        raise ValueError(f"invalid manifest: {e}")
+    objects: List[Dict[str, Any]] = []
+    for index, doc in enumerate(documents, 1):
+        if not isinstance(doc, dict):
+            raise ValueError(f"document {index} is not an object")
+        if str(doc.get("kind") or "").endswith("List") and isinstance(doc.get("items"), list):
+            objects.extend(doc.get("items") or [])
+        else:
+            objects.append(doc)
+    if not objects:
+        raise ValueError("manifest contains no objects")
+    for index, obj in enumerate(objects, 1):
+        if not isinstance(obj, dict):
+            raise ValueError(f"object {index} is not an object")
+        missing = [field for field, value in (
+            ("apiVersion", obj.get("apiVersion")),
+            ("kind", obj.get("kind")),
+            ("metadata.name", (obj.get("metadata") or {}).get("name")),
+        ) if not value]
+        if missing:
+            raise ValueError(f"object {index} is missing required fields: {', '.join(missing)}")
+    return objects
+
+
+# ==================== 资源量解析 ====================
+
+_QUANTITY_RE = re.compile(r"^([+-]?[0-9.]+(?:[eE][+-]?[0-9]+)?)([a-zA-Z]*)$")
+
+_QUANTITY_SUFFIXES = {
+    "": 1,
+    "n": 1e-9, "u": 1e-6, "m": 1e-3,
+    "k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+    "Ki": 2 ** 10, "Mi": 2 ** 20, "Gi": 2 ** 30, "Ti": 2 ** 40, "Pi": 2 ** 50, "Ei": 2 ** 60,
+}
+
+
+def parse_quantity(value: Any) -> float:
+    """解析 Kubernetes resource.Quantity 为基本单位数值（CPU 为核，内存/存储为字节）
+
+    Examples:
+        "100m" -> 0.1, "2" -> 2.0, "1Gi" -> 1073741824.0, "1G" -> 1e9
+    """
+    if value is None or value == "":
+        return 0.0
+    if isinstance(value, (int, float)):
+        return float(value)
+    match = _QUANTITY_RE.match(str(value).strip())
+    if not match or match.group(2) not in _QUANTITY_SUFFIXES:
+        raise ValueError(f"invalid quantity: {value}")
+    return float(match.group(1)) * _QUANTITY_SUFFIXES[match.group(2)]
+
+
+def pod_resource_requests(pod: Dict[str, Any]) -> Dict[str, float]:
+    """计算 Pod 的有效 requests：max(业务容器之和, 最大 init 容器) + overhead"""
+    spec = pod.get("spec") or {}
+    totals: Dict[str, float] = {}
+    for container in spec.get("containers") or []:
+        for name, quantity in ((container.get("resources") or {}).get("requests") or {}).items():
+            totals[name] = totals.get(name, 0.0) + parse_quantity(quantity)
+    for container in spec.get("initContainers") or []:
+        for name, quantity in ((container.get("resources") or {}).get("requests") or {}).items():
+            totals[name] = max(totals.get(name, 0.0), parse_quantity(quantity))
+    for name, quantity in (spec.get("overhead") or {}).items():
+        totals[name] = totals.get(name, 0.0) + parse_quantity(quantity)
+    return totals
+
+
+def pod_ephemeral_storage(pod: Dict[str, Any]) -> Dict[str, Any]:
+    """统计 Pod 的 ephemeral-storage requests/limits 及无上限的写入来源
+
+    Returns:
+        {"requests": 字节, "limits": 字节（存在未设置 limit 的业务容器时为 None）,
+         "containers_without_limit": [...], "unbounded_empty_dirs": [...]}
+    """
+    spec = pod.get("spec") or {}
+    requests = pod_resource_requests(pod).get("ephemeral-storage", 0.0)
+    limits = 0.0
+    without_limit: List[str] = []
+    for container in spec.get("containers") or []:
+        limit = ((container.get("resources") or {}).get("limits") or {}).get("ephemeral-storage")
+        if limit is None:
+            without_limit.append(container.get("name", ""))
+        else:
+            limits += parse_quantity(limit)
+    # medium=Memory 的 emptyDir 计入内存而非节点磁盘
+    unbounded_empty_dirs = [
+        volume.get("name", "")
+        for volume in spec.get("volumes") or []
+        if "emptyDir" in volume
+        and (volume.get("emptyDir") or {}).get("medium") != "Memory"
+        and not (volume.get("emptyDir") or {}).get("sizeLimit")
+    ]
+    return {
+        "requests": requests,
+        "limits": None if without_limit else limits,
+        "containers_without_limit": without_limit,
+        "unbounded_empty_dirs": unbounded_empty_dirs,
+    }
+
+
+def summarize_usage_metrics(metrics: Dict[str, Any]) -> Dict[str, Any]:
+    """汇总 NodeMetrics/PodMetrics 的 CPU 与内存用量，PodMetrics 按容器累加"""
+    usages = [metrics.get("usage") or {}] if "usage" in metrics else [
+        container.get("usage") or {} for container in metrics.get("containers") or []
+    ]
+    cpu = sum(parse_quantity(usage.get("cpu", "0")) for usage in usages)
+    memory = sum(parse_quantity(usage.get("memory", "0")) for usage in usages)
+    metadata = metrics.get("metadata") or {}
+    summary = {"name": metadata.get("name")}
+    if metadata.get("namespace"):
+        summary["namespace"] = metadata["namespace"]
+    summary.update({
+        "cpu": format_cpu(cpu),
+        "memory": format_bytes(memory),
+        "cpu_cores": round(cpu, 3),
+        "memory_bytes": int(memory),
+        "timestamp": metrics.get("timestamp"),
+        "window": metrics.get("window"),
+    })
+    if "containers" in metrics:
+        summary["containers"] = len(metrics.get("containers") or [])
+    return summary
+
+
+def format_cpu(cores: float) -> str:
+    """将核数格式化为 millicore 字符串，如 0.25 -> 250m"""
+    return f"{int(round(cores * 1000))}m"
+
+
+def format_bytes(value: float) -> str:
+    """将字节数格式化为二进制单位字符串，如 1073741824 -> 1.0Gi"""
+    for unit, size in (("Ti", 2 ** 40), ("Gi", 2 ** 30), ("Mi", 2 ** 20), ("Ki", 2 ** 10)):
+        if abs(value) >= size:
+            return f"{value / size:.1f}{unit}"
+    return f"{int(value)}"
+
+
+# ==================== 版本偏差 ====================
+
+_K8S_VERSION_RE = re.compile(r"^v?(\d+)\.(\d+)(?:\.(\d+))?")
+
+
+def parse_k8s_version(version: Optional[str]) -> Optional[Tuple[int, int, int]]:
+    """解析 Kubernetes 版本号，如 v1.30.1-aliyun.1 -> (1, 30, 1)，无法解析时返回 None"""
+    match = _K8S_VERSION_RE.match((version or "").strip())
+    if not match:
+        return None
+    return int(match.group(1)), int(match.group(2)), int(match.group(3) or 0)
+
+
+def max_kubelet_minor_skew(control_plane_minor: int) -> int:
+    """kubelet 相对 kube-apiserver 允许落后的最大次版本数（1.28 起为 3，之前为 2）"""
+    return 3 if control_plane_minor >= 28 else 2
+
+
+# ==================== 容器内命令输出解析 ====================
+
+def parse_df_output(output: str) -> List[Dict[str, Any]]:
+    """解析 POSIX 格式 `df -P -k` 输出（1024 字节块），挂载点中可能包含空格
+
+    Example:
+        Filesystem     1024-blocks    Used Available Capacity Mounted on
+        overlay           41152736 9876543  29163444      26% /
+    """
+    rows: List[Dict[str, Any]] = []
+    for line in (output or "").splitlines()[1:]:
+        parts = line.split()
+        if len(parts) < 6 or not parts[4].endswith("%"):
+            continue
+        try:
+            size_kb, used_kb, avail_kb = int(parts[1]), int(parts[2]), int(parts[3])
+            use_percent = float(parts[4].rstrip("%"))
+        except ValueError:
+            # 非数字列（如 '-'）说明该行无容量信息，跳过
+            continue
+        rows.append({
+            "filesystem": parts[0],
+            "mount_point": " ".join(parts[5:]),
+            "size_bytes": size_kb * 1024,
+            "used_bytes": used_kb * 1024,
+            "available_bytes": avail_kb * 1024,
+            "use_percent": use_percent,
+        })
+    return rows
+
+
+DNS_RECORD_TYPES = ("A", "AAAA", "CAA", "CNAME", "MX", "NS", "PTR", "SOA", "SRV", "TXT")
+
+# 主机名各段由字母、数字、- 与 _（如 SRV 的 _http._tcp）组成，不能以 - 开头，避免被 dig/nslookup 当作选项
+_DNS_HOSTNAME_RE = re.compile(r"^(?=.{1,253}\.?$)[A-Za-z0-9_]([-A-Za-z0-9_]{0,62})(\.[A-Za-z0-9_]([-A-Za-z0-9_]{0,62}))*\.?$")
+
+
+def validate_dns_query(hostname: str, record_type: str) -> str:
+    """校验 DNS 查询的主机名与记录类型，返回大写的记录类型
+
+    Raises:
+        ValueError: 主机名不是合法的 DNS 名称或记录类型不受支持
+    """
+    if not _DNS_HOSTNAME_RE.match(hostname or ""):
+        raise ValueError(f"invalid hostname '{hostname}': must be a DNS name such as my-svc.my-ns")
+    normalized = (record_type or "").strip().upper()
+    if normalized not in DNS_RECORD_TYPES:
+        raise ValueError(f"unsupported record type '{record_type}', supported: {', '.join(DNS_RECORD_TYPES)}")
+    return normalized
+
+
+_DIG_STATUS_RE = re.compile(r"status: ([A-Z]+)")
+_DIG_QUERY_TIME_RE = re.compile(r";; Query time: (\d+) msec")
+_DIG_SERVER_RE = re.compile(r";; SERVER: ([^#\s]+)")
+
+
+def parse_dig_output(output: str) -> Dict[str, Any]:
+    """解析 dig 输出，返回状态、DNS 服务器、查询耗时及 ANSWER 记录"""
+    text = output or ""
+    status = _DIG_STATUS_RE.search(text)
+    query_time = _DIG_QUERY_TIME_RE.search(text)
+    server = _DIG_SERVER_RE.search(text)
+    records = []
+    in_answer = False
+    for line in text.splitlines():
+        if line.startswith(";; ANSWER SECTION"):
+            in_answer = True
+            continue
+        if in_answer:
+            if not line.strip() or line.startswith(";"):
+                in_answer = False
+                continue
+            parts = line.split(None, 4)
+            if len(parts) == 5:
+                records.append({"name": parts[0], "ttl": int(parts[1]), "type": parts[3], "data": parts[4].strip()})
+    return {
+        "status": status.group(1) if status else None,
+        "server": server.group(1) if server else None,
+        "latency_ms": int(query_time.group(1)) if query_time else None,
+        "records": records,
+    }
+
+
+def parse_nslookup_output(output: str) -> Dict[str, Any]:
+    """解析 nslookup 输出（busybox/bind 两种格式），返回状态、DNS 服务器及解析记录"""
+    text = output or ""
+    server = None
+    records = []
+    current_name = None
+    for line in text.splitlines():
+        line = line.strip()
+        if line.startswith("Server:"):
+            server = line.split(":", 1)[1].strip()
+        elif line.startswith("Name:"):
+            current_name = line.split(":", 1)[1].strip()
+        elif line.startswith("Address") and current_name:
+            address = line.split(":", 1)[1].strip().split()[0]
+            records.append({
+                "name": current_name,
+                "ttl": None,
+                "type": "AAAA" if ":" in address else "A",
+                "data": address,
+            })
+    if "NXDOMAIN" in text:
+        status = "NXDOMAIN"
+    elif "SERVFAIL" in text:
+        status = "SERVFAIL"
+    elif "timed out" in text or "no servers could be reached" in text:
+        status = "TIMEOUT"
+    else:
+        status = "NOERROR" if records else None
+    return {"status": status, "server": server, "latency_ms": None, "records": records}
+
+
+# 容器内缺少可执行文件时 kubectl exec 的典型报错
+_EXEC_NOT_FOUND_MARKERS = (
+    "executable file not found",
+    "no such file or directory",
+    "exit code 126",
+    "exit code 127",
+)
+
+
+def is_exec_binary_missing(exit_code: int, stderr: str) -> bool:
+    """判断 kubectl exec 失败是否由容器内缺少命令（如 distroless 镜像）导致"""
+    text = (stderr or "").lower()
+    return exit_code in (126, 127) or any(marker in text for marker in _EXEC_NOT_FOUND_MARKERS)
+
+
+
+# kubectl exec 在容器内命令以非零状态退出时输出的提示
+_EXEC_EXIT_CODE_RE = re.compile(r"^command terminated with exit code (\d+)\s*$", re.MULTILINE)
+
+
+def split_exec_exit_code(stderr: str) -> Tuple[Optional[int], str]:
+    """从 kubectl exec 的 stderr 中提取容器内命令的退出码，返回 (退出码, 去除该提示后的 stderr)
+
+    退出码为 None 表示失败发生在 kubectl 或 API Server 一侧（如 Pod 不存在），而非容器内命令
+    """
+    match = _EXEC_EXIT_CODE_RE.search(stderr or "")
+    if not match:
+        return None, stderr or ""
+    remaining = (stderr[:match.start()] + stderr[match.end():]).strip()
+    return int(match.group(1)), remaining
+
+# ==================== 镜像仓库 ====================
+
+DOCKER_HUB_REGISTRY = "registry-1.docker.io"
+_DOCKER_HUB_ALIASES = {"docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com"}
+
+
+def parse_image_reference(image: str) -> Tuple[str, str, str]:
+    """解析镜像地址为 (registry, repository, tag 或 digest)
+
+    Examples:
+        "nginx" -> ("registry-1.docker.io", "library/nginx", "latest")
+        "registry.cn-hangzhou.aliyuncs.com/ns/app@sha256:ab" -> ("registry.cn-hangzhou.aliyuncs.com", "ns/app", "sha256:ab")
+    """
+    name, reference = image.strip(), "latest"
+    if "@" in name:
+        name, reference = name.split("@", 1)
+    else:
+        last = name.rsplit("/", 1)[-1]
+        if ":" in last:
+            name, reference = name.rsplit(":", 1)
+    first, _, rest = name.partition("/")
+    if rest and ("." in first or ":" in first or first == "localhost"):
+        registry, repository = first, rest
+    else:
+        registry, repository = DOCKER_HUB_REGISTRY, name
+    if registry in _DOCKER_HUB_ALIASES:
+        registry = DOCKER_HUB_REGISTRY
+        if "/" not in repository:
+            repository = f"library/{repository}"
+    return registry, repository, reference
+
+
+def parse_www_authenticate(header: str) -> Tuple[str, Dict[str, str]]:
+    """解析 WWW-Authenticate 响应头，如 Bearer realm="...",service="..." -> ("bearer", {...})"""
+    scheme, _, params = (header or "").strip().partition(" ")
+    return scheme.lower(), {k.lower(): v for k, v in re.findall(r'(\w+)="([^"]*)"', params)}
+
+
+def _registry_host(server: str) -> str:
+    host = re.sub(r"^https?://", "", server.strip()).split("/", 1)[0]
+    return DOCKER_HUB_REGISTRY if host in _DOCKER_HUB_ALIASES else host
+
+
+def docker_config_auths(secret: Dict[str, Any]) -> Dict[str, Tuple[str, str]]:
+    """从 kubernetes.io/dockerconfigjson 或 dockercfg 类型的 Secret 中提取 registry -> (username, password)"""
+    data = secret.get("data") or {}
+    raw = data.get(".dockerconfigjson") or data.get(".dockercfg")
+    if not raw:
+        return {}
+    try:
+        config = json.loads(base64.b64decode(raw))
+    except (ValueError, TypeError):
+        return {}
+    auths = config.get("auths", config) if isinstance(config, dict) else {}
+    result: Dict[str, Tuple[str, str]] = {}
+    for server, entry in auths.items():
+        if not isinstance(entry, dict):
+            continue
+        username, password = entry.get("username"), entry.get("password")
+        if (not username or password is None) and entry.get("auth"):
+            try:
+                username, _, password = base64.b64decode(entry["auth"]).decode("utf-8").partition(":")
+            except (ValueError, UnicodeDecodeError):
+                continue
+        if username:
+            result[_registry_host(server)] = (username, password or "")
+    return result
+
+
+# ==================== API 资源发现 ====================
+
+_API_RESOURCES_COLUMNS = ("NAME", "SHORTNAMES", "APIVERSION", "NAMESPACED", "KIND")
+
+
+def parse_api_resources(output: str) -> List[Dict[str, Any]]:
+    """解析 kubectl api-resources 的表格输出（按表头列位置切分，SHORTNAMES 可能为空）
+
+    Returns:
+        [{"name", "short_names", "group", "version", "namespaced", "kind"}]
+    """
+    lines = [line for line in (output or "").splitlines() if line.strip()]
+    if not lines or not lines[0].startswith("NAME"):
+        return []
+    header = lines[0]
+    starts = [header.find(column) for column in _API_RESOURCES_COLUMNS]
+    if any(start < 0 for start in starts):
+        return []
+    bounds = list(zip(starts, starts[1:] + [None]))
+    resources = []
+    for line in lines[1:]:
+        name, short_names, api_version, namespaced, kind = (line[start:end].strip() for start, end in bounds)
+        if not name or not api_version:
+            continue
+        group, _, version = api_version.rpartition("/")
+        resources.append({
+            "name": name,
+            "short_names": [n for n in short_names.split(",") if n],
+            "group": group,
+            "version": version,
+            "namespaced": namespaced.lower() == "true",
+            "kind": kind,
+        })
+    return resources
+
+
+def summarize_crd(crd: Dict[str, Any]) -> Dict[str, Any]:
+    """提取 CustomResourceDefinition 的组、版本、Kind、作用域及 Established 状态"""
+    spec = crd.get("spec") or {}
+    names = spec.get("names") or {}
+    versions = [
+        {"name": v.get("name"), "served": bool(v.get("served")), "storage": bool(v.get("storage"))}
+        for v in spec.get("versions") or []
+    ]
+    conditions = (crd.get("status") or {}).get("conditions") or []
+    return {
+        "name": (crd.get("metadata") or {}).get("name"),
+        "group": spec.get("group"),
+        "kind": names.get("kind"),
+        "plural": names.get("plural"),
+        "short_names": names.get("shortNames") or [],
+        "scope": spec.get("scope"),
+        "versions": versions,
+        "storage_version": next((v["name"] for v in versions if v["storage"]), None),
+        "established": any(c.get("type") == "Established" and c.get("status") == "True" for c in conditions),
+    }
+
+
+# ==================== OpenAPI 字段说明 ====================
+
+_OPENAPI_REF_PREFIX = "#/components/schemas/"
+
+# 以字符串表示的内置类型，说明时显示类型名而非 Object
+_OPENAPI_SCALAR_FORMATS = {"int-or-string": "IntOrString", "date-time": "Time"}
+
+
+def openapi_path(group: str, version: str) -> str:
+    """group/version 对应的 OpenAPI v3 文档路径，如 /openapi/v3/apis/apps/v1"""
+    return f"/openapi/v3/apis/{group}/{version}" if group else f"/openapi/v3/api/{version}"
+
+
+def _openapi_ref(schema: Dict[str, Any]) -> Optional[str]:
+    """schema 引用的组件名，兼容 $ref 与仅含一个引用的 allOf"""
+    ref = schema.get("$ref")
+    if not ref and len(schema.get("allOf") or []) == 1:
+        ref = (schema["allOf"][0] or {}).get("$ref")
+    if isinstance(ref, str) and ref.startswith(_OPENAPI_REF_PREFIX):
+        return ref[len(_OPENAPI_REF_PREFIX):]
+    return None
+
+
+def _openapi_resolve(document: Dict[str, Any], schema: Dict[str, Any]) -> Dict[str, Any]:
+    """展开 schema 的引用，引用处的 description 优先（字段说明通常写在引用处）"""
+    schemas = (document.get("components") or {}).get("schemas") or {}
+    seen = set()
+    resolved = schema
+    while True:
+        ref = _openapi_ref(resolved)
+        if not ref or ref in seen or ref not in schemas:
+            break
+        seen.add(ref)
+        resolved = {**schemas[ref], **{k: v for k, v in resolved.items() if k == "description"}}
+    return resolved
+
+
+def openapi_kind_schema(document: Dict[str, Any], group: str, version: str, kind: str) -> Optional[Dict[str, Any]]:
+    """在 OpenAPI v3 文档中查找 x-kubernetes-group-version-kind 与之匹配的对象 schema"""
+    for schema in ((document.get("components") or {}).get("schemas") or {}).values():
+        for gvk in schema.get("x-kubernetes-group-version-kind") or []:
+            if (gvk.get("group") or "") == group and gvk.get("version") == version and gvk.get("kind") == kind:
+                return schema
+    return None
+
+
+def openapi_type_name(document: Dict[str, Any], schema: Dict[str, Any]) -> str:
+    """kubectl explain 风格的类型名，如 string、[]Container、map[string]string、PodSpec、Object"""
+    ref = _openapi_ref(schema)
+    resolved = _openapi_resolve(document, schema)
+    schema_type = resolved.get("type")
+    if resolved.get("x-kubernetes-int-or-string") or resolved.get("format") == "int-or-string":
+        return "IntOrString"
+    if schema_type == "array":
+        return "[]" + openapi_type_name(document, resolved.get("items") or {})
+    if ref:
+        return ref.rsplit(".", 1)[-1]
+    if schema_type == "object" and isinstance(resolved.get("additionalProperties"), dict) and not resolved.get("properties"):
+        return "map[string]" + openapi_type_name(document, resolved["additionalProperties"])
+    if schema_type in ("string", "integer", "number", "boolean"):
+        return _OPENAPI_SCALAR_FORMATS.get(resolved.get("format"), schema_type)
+    return "Object"
+
+
+def _openapi_element(document: Dict[str, Any], schema: Dict[str, Any]) -> Dict[str, Any]:
+    """列表取元素 schema、无固定字段的 map 取值 schema，以便继续按字段名向下查找"""
+    resolved = _openapi_resolve(document, schema)
+    while True:
+        if resolved.get("type") == "array" and isinstance(resolved.get("items"), dict):
+            resolved = _openapi_resolve(document, resolved["items"])
+        elif (resolved.get("type") == "object" and not resolved.get("properties")
+              and isinstance(resolved.get("additionalProperties"), dict)):
+            resolved = _openapi_resolve(document, resolved["additionalProperties"])
+        else:
+            return resolved
+
+
+def explain_openapi_field(
+    document: Dict[str, Any], root: Dict[str, Any], segments: List[str],
+) -> Dict[str, Any]:
+    """按字段路径（如 ["spec", "containers"]）在 schema 中查找字段，列表与 map 自动进入其元素
+
+    Returns:
+        {"type", "description", "required", "fields"}，fields 为该字段（列表/map 为其元素）的子字段
+
+    Raises:
+        ValueError: 字段不存在
+    """
+    schema, required = root, None
+    for index, segment in enumerate(segments):
+        parent = _openapi_element(document, schema)
+        properties = parent.get("properties") or {}
+        if segment not in properties:
+            location = ".".join(segments[:index]) or "the resource"
+            available = ", ".join(sorted(properties)) or "<none>"
+            raise ValueError(f"field '{segment}' does not exist in {location}; available fields: {available}")
+        schema, required = properties[segment], segment in (parent.get("required") or [])
+
+    resolved = _openapi_resolve(document, schema)
+    element = _openapi_element(document, schema)
+    required_fields = set(element.get("required") or [])
+    fields = [
+        {
+            "name": name,
+            "type": openapi_type_name(document, child),
+            "description": _openapi_resolve(document, child).get("description"),
+            "required": name in required_fields,
+        }
+        for name, child in sorted((element.get("properties") or {}).items())
+    ]
+    return {
+        "type": openapi_type_name(document, schema),
+        "description": resolved.get("description"),
+        "required": required,
+        "fields": fields,
+    }
+
+
+# ==================== 超时 ====================
+
+# 长耗时 kubectl 操作的默认超时（秒），未列出的操作使用 kubectl_timeout
+LONG_RUNNING_TIMEOUTS = {
+    "exec": 120,
+    "attach": 300,
+    "port-forward": 300,
+    "logs": 300,
+    "watch": 300,
+    "drain": 300,
+    "rollout": 600,
+}
+
+
+# 不会自行结束的流式 kubectl 操作：ack_kubectl 收集输出直到超时，服务退出时立即取消
+STREAMING_OPERATIONS = ("logs", "watch", "attach")
+
+
+def kubectl_operation(command: str) -> str:
+    """识别 kubectl 命令的操作类型，用于选择默认超时（logs -f 识别为 logs，get -w 识别为 watch）"""
+    tokens = command.split()
+    if not tokens:
+        return ""
+    verb = tokens[0]
+    if verb == "logs":
+        return "logs" if {"-f", "--follow", "--follow=true"} & set(tokens) else "logs-once"
+    if verb == "get" and {"-w", "--watch", "--watch=true", "--watch-only"} & set(tokens):
+        return "watch"
+    return verb
+
+
+def resolve_timeout(requested: Any, default: int, max_timeout: int) -> int:
+    """计算实际超时：优先使用请求值，未指定或非法时使用默认值，并受服务端最大值约束"""
+    if isinstance(requested, bool) or not isinstance(requested, (int, float)) or requested <= 0:
+        requested = default
+    return int(min(requested, max_timeout)) if max_timeout and max_timeout > 0 else int(requested)
+
+
+# ==================== 时长与存活时间 ====================
+
+_DURATION_RE = re.compile(r"(\d+(?:\.\d+)?)(w|d|h|m|s)")
+_DURATION_UNITS = {"w": 604800, "d": 86400, "h": 3600, "m": 60, "s": 1}
+
+
+def parse_duration(value: str) -> timedelta:
+    """解析时长字符串，支持 w/d/h/m/s 组合，如 10m、1h30m、7d"""
+    text = (value or "").strip().lower()
+    if not text:
+        raise ValueError("empty duration")
+    pos, seconds = 0, 0.0
+    for match in _DURATION_RE.finditer(text):
+        if match.start() != pos:
+            break
+        seconds += float(match.group(1)) * _DURATION_UNITS[match.group(2)]
+        pos = match.end()
+    if pos != len(text):
+        raise ValueError(f"invalid duration: {value} (expected e.g. 10m, 1h30m, 7d)")
+    return timedelta(seconds=seconds)
+
+
+def format_age(delta: timedelta) -> str:
+    """按 kubectl 风格格式化存活时间，如 45s、12m、3h20m、5d、400d"""
+    seconds = max(int(delta.total_seconds()), 0)
+    if seconds < 120:
+        return f"{seconds}s"
+    minutes = seconds // 60
+    if minutes < 10:
+        return f"{minutes}m{seconds % 60}s" if seconds % 60 else f"{minutes}m"
+    if minutes < 180:
+        return f"{minutes}m"
+    hours = minutes // 60
+    if hours < 8:
+        return f"{hours}h{minutes % 60}m" if minutes % 60 else f"{hours}h"
+    if hours < 48:
+        return f"{hours}h"
+    days = hours // 24
+    if days < 8:
+        return f"{days}d{hours % 24}h" if hours % 24 else f"{days}d"
+    return f"{days}d"
+
+
+def parse_rfc3339(value: str, name: str = "time") -> datetime:
+    """解析带时区的 RFC3339 时间，如 2024-01-01T00:00:00Z、2024-01-01T08:00:00+08:00"""
+    parsed = parse_k8s_time((value or "").strip())
+    if parsed is None or parsed.tzinfo is None:
+        raise ValueError(f"invalid {name} '{value}' (expected RFC3339 time with time zone, e.g. 2024-01-01T00:00:00Z)")
+    return parsed
+
+
+def filter_by_age(
+    items: List[Dict[str, Any]],
+    now: datetime,
+    min_age: Optional[timedelta] = None,
+    max_age: Optional[timedelta] = None,
+    created_after: Optional[datetime] = None,
+    created_before: Optional[datetime] = None,
+) -> List[Dict[str, Any]]:
+    """按 metadata.creationTimestamp 过滤：存活时间 >= min_age 且 <= max_age，创建时间 >= created_after 且 < created_before"""
+    result = []
+    for item in items:
+        created = parse_k8s_time((item.get("metadata") or {}).get("creationTimestamp"))
+        if created is None:
+            continue
+        age = now - created
+        if min_age is not None and age < min_age:
+            continue
+        if max_age is not None and age > max_age:
+            continue
+        if created_after is not None and created < created_after:
+            continue
+        if created_before is not None and created >= created_before:
+            continue
+        result.append(item)
+    return result
+
+
+# kubectl -v=8 输出的 HTTP 响应头中的 Date 字段
+_HTTP_DATE_RE = re.compile(r"Date: ([A-Z][a-z]{2}, \d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} GMT)")
+
+
+def parse_server_date(verbose_output: str) -> Optional[datetime]:
+    """从 kubectl 详细日志中的响应头解析 API Server 当前时间"""
+    match = _HTTP_DATE_RE.search(verbose_output or "")
+    if not match:
+        return None
+    try:
+        return parsedate_to_datetime(match.group(1)).astimezone(timezone.utc)
+    except (TypeError, ValueError):
+        return None
+
+
+# ==================== 表格输出 ====================
+
+def _parse_jsonpath(expression: str) -> List[Tuple[str, Any]]:
+    """解析 JSONPath 表达式为 [(op, arg)]，op 为 field、index 或 wildcard
+
+    支持 kubectl custom-columns 常用的子集：.a.b、转义的点（.metadata.labels.app\\.kubernetes\\.io/name）、
+    [n]（支持负数）、[*]、['key'] / ["key"]，表达式可带 {} 包裹
+    """
+    text = expression.strip()
+    if text.startswith("{") and text.endswith("}"):
+        text = text[1:-1].strip()
+    if not text.startswith((".", "[")):
+        text = "." + text
+    segments: List[Tuple[str, Any]] = []
+    i = 0
+    while i < len(text):
+        char = text[i]
+        if char == ".":
+            if text.startswith("..", i):
+                raise ValueError(f"recursive descent is not supported in JSONPath '{expression}'")
+            i += 1
+            name = []
+            while i < len(text) and text[i] not in ".[":
+                if text[i] == "\\" and i + 1 < len(text):
+                    i += 1
+                name.append(text[i])
+                i += 1
+            if not name:
+                raise ValueError(f"empty field name in JSONPath '{expression}'")
+            segments.append(("field", "".join(name)))
+        elif char == "[":
+            end = text.find("]", i)
+            if end < 0:
+                raise ValueError(f"unclosed bracket in JSONPath '{expression}'")
+            inner = text[i + 1:end].strip()
+            if inner == "*":
+                segments.append(("wildcard", None))
+            elif len(inner) >= 2 and inner[0] == inner[-1] and inner[0] in "'\"":
+                segments.append(("field", inner[1:-1]))
+            else:
+                try:
+                    segments.append(("index", int(inner)))
+                except ValueError:
+                    raise ValueError(f"unsupported JSONPath expression '[{inner}]' in '{expression}'")
+            i = end + 1
+        else:
+            raise ValueError(f"unexpected '{char}' in JSONPath '{expression}'")
+    return segments
+
+
+def jsonpath_values(obj: Any, expression: str) -> List[Any]:
+    """按 JSONPath 取值，返回所有匹配的值（字段不存在时为空列表）"""
+    values = [obj]
+    for op, arg in _parse_jsonpath(expression):
+        matched = []
+        for value in values:
+            if op == "field" and isinstance(value, dict) and arg in value:
+                matched.append(value[arg])
+            elif op == "index" and isinstance(value, list) and -len(value) <= arg < len(value):
+                matched.append(value[arg])
+            elif op == "wildcard" and isinstance(value, (list, dict)):
+                matched.extend(value if isinstance(value, list) else value.values())
+        values = matched
+    return values
+
+
+def parse_custom_columns(spec: str) -> List[Tuple[str, str]]:
+    """解析 custom-columns 规格（如 NAME:.metadata.name,NODE:.spec.nodeName），返回 [(表头, JSONPath)]"""
+    columns = []
+    for part in (spec or "").split(","):
+        header, sep, expression = part.strip().partition(":")
+        if not part.strip() or not sep or not header.strip() or not expression.strip():
+            raise ValueError(f"invalid custom-columns spec '{spec}', expected e.g. NAME:.metadata.name,NODE:.spec.nodeName")
+        _parse_jsonpath(expression)
+        columns.append((header.strip(), expression.strip()))
+    return columns
+
+
+def format_cell(value: Any) -> str:
+    """格式化表格单元格：空值显示为 <none>，列表以逗号连接，对象输出为紧凑 JSON"""
+    if value is None or value == "" or value == [] or value == {}:
+        return "<none>"
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    if isinstance(value, list):
+        return ",".join(format_cell(v) for v in value)
+    if isinstance(value, dict):
+        return json.dumps(value, separators=(",", ":"), ensure_ascii=False, sort_keys=True)
+    return str(value)
+
+
+def format_table(headers: List[str], rows: List[List[str]]) -> str:
+    """按 kubectl 风格输出左对齐、列间距 3 个空格的表格"""
+    widths = [len(header) for header in headers]
+    for row in rows:
+        widths = [max(width, len(cell)) for width, cell in zip(widths, row)]
+    lines = []
+    for row in [headers, *rows]:
+        lines.append("   ".join(cell.ljust(width) for cell, width in zip(row, widths)).rstrip())
+    return "\n".join(lines)
+
+
+# ==================== 证书解析 ====================
+
+_PEM_CERT_RE = re.compile(
+    r"-----BEGIN CERTIFICATE-----\s*(.+?)\s*-----END CERTIFICATE-----", re.DOTALL
+)
+
+_OID_COMMON_NAME = bytes([0x55, 0x04, 0x03])
+_OID_SUBJECT_ALT_NAME = bytes([0x55, 0x1D, 0x11])
+
+
+def _read_tlv(data: bytes, offset: int) -> Tuple[int, int, int]:
+    """读取一个 DER TLV，返回 (tag, value_start, value_end)"""
+    tag = data[offset]
+    length = data[offset + 1]
+    offset += 2
+    if length & 0x80:
+        num_bytes = length & 0x7F
+        if num_bytes == 0 or num_bytes > 4:
+            raise ValueError("unsupported DER length encoding")
+        length = int.from_bytes(data[offset:offset + num_bytes], "big")
+        offset += num_bytes
+    end = offset + length
+    if end > len(data):
+        raise ValueError("truncated DER data")
+    return tag, offset, end
+
+
+def _children(data: bytes, start: int, end: int) -> List[Tuple[int, int, int]]:
+    """列出构造类型内的所有子 TLV"""
+    items = []
+    offset = start
+    while offset < end:
+        tag, value_start, value_end = _read_tlv(data, offset)
+        items.append((tag, value_start, value_end))
+        offset = value_end
+    return items
+
+
+def _parse_der_time(tag: int, raw: bytes) -> datetime:
+    text = raw.decode("ascii")
+    if tag == 0x17:  # UTCTime: YYMMDDHHMMSSZ
+        parsed = datetime.strptime(text, "%y%m%d%H%M%SZ")
+    elif tag == 0x18:  # GeneralizedTime: YYYYMMDDHHMMSSZ
+        parsed = datetime.strptime(text, "%Y%m%d%H%M%SZ")
+    else:
+        raise ValueError(f"unexpected time tag 0x{tag:02x}")
+    return parsed.replace(tzinfo=timezone.utc)
+
+
+def _find_common_name(data: bytes, start: int, end: int) -> Optional[str]:
+    """在 Name (RDNSequence) 中查找 CN"""
+    for _, set_start, set_end in _children(data, start, end):
+        for _, atv_start, atv_end in _children(data, set_start, set_end):
+            parts = _children(data, atv_start, atv_end)
+            if len(parts) == 2 and data[parts[0][1]:parts[0][2]] == _OID_COMMON_NAME:
+                return data[parts[1][1]:parts[1][2]].decode("utf-8", errors="replace")
+    return None
+
+
+def _find_subject_alt_names(data: bytes, start: int) -> List[str]:
+    """在 extensions ([3]) 中查找 SAN 的 DNS 与 IP 条目"""
+    names: List[str] = []
+    _, seq_start, seq_end = _read_tlv(data, start)
+    for _, ext_start, ext_end in _children(data, seq_start, seq_end):
+        parts = _children(data, ext_start, ext_end)
+        if not parts or data[parts[0][1]:parts[0][2]] != _OID_SUBJECT_ALT_NAME:
+            continue
+        # extnID, [critical], extnValue(OCTET STRING)
+        _, octet_start, octet_end = parts[-1]
+        _, gn_start, gn_end = _read_tlv(data, octet_start)
+        for tag, value_start, value_end in _children(data, gn_start, gn_end):
+            raw = data[value_start:value_end]
+            if tag == 0x82:  # dNSName
+                names.append(raw.decode("ascii", errors="replace"))
+            elif tag == 0x87 and len(raw) in (4, 16):  # iPAddress
+                names.append(str(ipaddress.ip_address(raw)))
+    return names
+
+
+def parse_certificate_der(der: bytes) -> Dict[str, Any]:
+    """从 DER 编码的 X.509 证书中提取 subject CN、SAN、有效期"""
+    _, cert_start, cert_end = _read_tlv(der, 0)
+    _, tbs_start, tbs_end = _read_tlv(der, cert_start)
+    fields = _children(der, tbs_start, tbs_end)
+    # 可选的 [0] version 字段
+    if fields and fields[0][0] == 0xA0:
+        fields = fields[1:]
+    # serialNumber, signature, issuer, validity, subject, subjectPublicKeyInfo, ...extensions
+    if len(fields) < 6:
+        raise ValueError("malformed TBSCertificate")
+    _, issuer_start, issuer_end = fields[2]
+    _, validity_start, validity_end = fields[3]
+    _, subject_start, subject_end = fields[4]
+    validity = _children(der, validity_start, validity_end)
+    not_before = _parse_der_time(validity[0][0], der[validity[0][1]:validity[0][2]])
+    not_after = _parse_der_time(validity[1][0], der[validity[1][1]:validity[1][2]])
+
+    dns_names: List[str] = []
+    for tag, value_start, _ in fields[6:]:
+        if tag == 0xA3:
+            dns_names = _find_subject_alt_names(der, value_start)
+    return {
+        "subject_cn": _find_common_name(der, subject_start, subject_end),
+        "issuer_cn": _find_common_name(der, issuer_start, issuer_end),
+        "not_before": not_before,
+        "not_after": not_after,
+        "dns_names": dns_names,
+    }
+
+
+def parse_pem_certificates(pem: str) -> List[Dict[str, Any]]:
+    """解析 PEM 文本中的全部证书（通常 tls.crt 中第一个为叶子证书，其余为中间证书）"""
+    certs = []
+    for block in _PEM_CERT_RE.findall(pem or ""):
+        der = base64.b64decode("".join(block.split()))
+        certs.append(parse_certificate_der(der))
+    return certs
+
+
+def hostname_matches(host: str, patterns: List[str]) -> bool:
+    """判断主机名是否被证书 SAN（支持单级通配符 *.example.com）覆盖"""
+    host = host.lower().rstrip(".")
+    for pattern in patterns:
+        pattern = pattern.lower().rstrip(".")
+        if pattern == host:
+            return True
+        if pattern.startswith("*.") and "." in host:
+            if host.split(".", 1)[1] == pattern[2:]:
+                return True
+    return False
+
+
+def inspect_tls_secret(
+    secret: Dict[str, Any],
+    now: Optional[datetime] = None,
+    expiring_days: int = 30,
+) -> Dict[str, Any]:
+    """检查 TLS Secret 中的证书，返回状态 OK/Expiring/Expired/Invalid 及证书摘要
+
+    只读取 tls.crt（公开证书），不会读取或返回 tls.key。
+    """
+    now = now or datetime.now(timezone.utc)
+    data = secret.get("data") or {}
+    encoded = data.get("tls.crt")
+    if not encoded:
+        return {"status": "Invalid", "message": "secret has no tls.crt"}
+    try:
+        certs = parse_pem_certificates(base64.b64decode(encoded).decode("utf-8", errors="replace"))
+    except Exception as e:
+        return {"status": "Invalid", "message": f"failed to parse tls.crt: {e}"}
+    if not certs:
+        return {"status": "Invalid", "message": "no PEM certificate found in tls.crt"}
+
+    leaf = certs[0]
+    remaining = leaf["not_after"] - now
+    days_until_expiry = int(remaining.total_seconds() // 86400)
+    if remaining.total_seconds() <= 0:
+        status = "Expired"
+    elif days_until_expiry < expiring_days:
+        status = "Expiring"
+    else:
+        status = "OK"
+    return {
+        "status": status,
+        "subject_cn": leaf["subject_cn"],
+        "issuer_cn": leaf["issuer_cn"],
+        "dns_names": leaf["dns_names"],
+        "not_before": leaf["not_before"].isoformat().replace("+00:00", "Z"),
+        "not_after": leaf["not_after"].isoformat().replace("+00:00", "Z"),
+        "days_until_expiry": days_until_expiry,
+        "chain_length": len(certs),
+    }
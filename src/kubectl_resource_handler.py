@@ -0,0 +1,2636 @@
+"""Kubectl Resource Handler - 结构化的 Kubernetes 资源查询工具."""
+
+import asyncio
+import fnmatch
+import io
+import json
+import math
+import tarfile
+import time
+import uuid
+import yaml
+from typing import Dict, Any, Optional, List, Tuple
+from cachetools import TTLCache
+from fastmcp import FastMCP, Context
+from loguru import logger
+from pydantic import Field
+from datetime import datetime, timedelta, timezone
+from discovery_cache import MIN_REFRESH_SECONDS, get_discovery_cache
+from impersonation import impersonation_cache_key
+from inline_kubeconfig import current_inline_kubeconfig
+from kubectl_helpers import (
+    LONG_RUNNING_TIMEOUTS,
+    RESTARTED_AT_ANNOTATION,
+    OWNED_RESOURCES,
+    WELL_KNOWN_OWNER_RESOURCES,
+    classify_kubectl_error,
+    clean_for_export,
+    controller_owner,
+    deployment_rollout_status,
+    drain_skip_reason,
+    explain_openapi_field,
+    event_time,
+    filter_by_age,
+    is_owned_by,
+    manifest_diff,
+    metadata_merge_patch,
+    object_references,
+    openapi_kind_schema,
+    openapi_path,
+    ownership_node,
+    parse_api_resources,
+    parse_custom_columns,
+    parse_duration,
+    parse_rfc3339,
+    parse_manifest,
+    parse_metadata_changes,
+    parse_patch,
+    redact_secret_values,
+    resolve_timeout,
+    rollout_blocking_condition,
+    selector_matches,
+    split_exec_exit_code,
+    summarize_cluster_counts,
+    summarize_crd,
+    summarize_usage_metrics,
+    trim_object,
+    validate_label_selector,
+)
+from kubectl_resources import (
+    DEFAULT_NAMESPACE,
+    OBJECT_URI_RESOURCES,
+    OBJECT_URI_TEMPLATE,
+    RESOURCE_SPECS,
+    ResourceSpec,
+    find_resource_spec,
+    format_custom_columns,
+    format_describe,
+    format_wide_table,
+    list_api_path,
+    namespace_args,
+    normalize_namespace,
+    resolve_discovered_spec,
+    resolve_namespace,
+    summarize_object,
+    validate_field_selector,
+)
+from kubectl_runner import (
+    KubectlRunner,
+    KubectlCommandError,
+    command_error_model,
+    finish_execution_log,
+    start_execution_log,
+)
+from models import (
+    ClusterSummaryOutput,
+    ErrorModel,
+    ExecutionLog,
+    ExplainField,
+    ExportBundleOutput,
+    KubectlDescribeOutput,
+    KubectlExplainOutput,
+    KubectlDiffOutput,
+    KubectlEventsOutput,
+    KubectlExecOutput,
+    KubectlMetadataOutput,
+    KubectlPatchOutput,
+    KubectlApplyOutput,
+    KubectlDeleteOutput,
+    KubectlGetOutput,
+    KubectlLogsOutput,
+    KubectlMultiGetOutput,
+    KubectlNodeOutput,
+    KubectlOwnerTreeOutput,
+    KubectlRolloutOutput,
+    KubectlTopOutput,
+    KubectlWatchOutput,
+    ListCRDsOutput,
+    ListNamespacesOutput,
+    LogArchiveOutput,
+    MultiGetItem,
+)
+
+# 日志归档 resource URI 模板及保留策略
+LOG_ARCHIVE_URI_TEMPLATE = "ack-logs://archives/{archive_id}"
+LOG_ARCHIVE_TTL_SECONDS = 1800
+LOG_ARCHIVE_MAX_COUNT = 20
+# kubectl_logs_archive 单次收集的 Pod 数量上限
+MAX_LOG_ARCHIVE_PODS = 100
+
+# kubectl_get 列表查询默认每页对象数量
+DEFAULT_LIST_LIMIT = 100
+
+# 无权跨命名空间列出时，逐个查询的命名空间数量上限及并发查询数
+MAX_FALLBACK_NAMESPACES = 100
+FALLBACK_NAMESPACE_CONCURRENCY = 10
+
+# kubectl_multi_get 单次调用的查询数量上限及并发查询数
+MAX_MULTI_GET_ITEMS = 20
+MULTI_GET_CONCURRENCY = 5
+
+# kubectl_get 支持的输出格式
+OUTPUT_FORMATS = ("json", "yaml", "wide", "custom-columns")
+
+# kubectl_logs 默认/最大读取行数及返回字节上限
+DEFAULT_LOG_TAIL_LINES = 1000
+MAX_LOG_TAIL_LINES = 10000
+MAX_LOG_BYTES = 256 * 1024
+
+# kubectl_owner_tree 向上/向下遍历的最大层数及每个节点最多列出的子对象数
+MAX_OWNER_DEPTH = 10
+MAX_OWNER_TREE_CHILDREN = 50
+
+# kubectl_exec 返回输出的字节上限
+MAX_EXEC_OUTPUT_BYTES = 64 * 1024
+
+# kubectl_top 支持的资源类型（含别名）与排序字段
+TOP_RESOURCES = {"nodes": "nodes", "node": "nodes", "no": "nodes", "pods": "pods", "pod": "pods", "po": "pods"}
+TOP_SORT_FIELDS = {"cpu": "cpu_cores", "memory": "memory_bytes"}
+METRICS_API_PATH = "/apis/metrics.k8s.io/v1beta1"
+
+# metrics.k8s.io 未注册或 metrics-server 不可用时 kubectl 的报错
+METRICS_UNAVAILABLE_MARKERS = (
+    "the server could not find the requested resource",
+    "the server is currently unable to handle the request",
+    "(ServiceUnavailable)",
+)
+
+# kubectl_events 支持的事件类型及默认返回数量
+EVENT_TYPES = ("Normal", "Warning")
+DEFAULT_EVENT_LIMIT = 100
+
+# kubectl_watch 默认监听时长（秒）及最多返回的事件数
+DEFAULT_WATCH_SECONDS = 30
+MAX_WATCH_EVENTS = 500
+
+# 资源不支持 watch 时 API Server 的报错
+WATCH_UNSUPPORTED_MARKERS = ("(MethodNotAllowed)", "the server does not allow this method")
+
+# kubectl_apply 进行 server-side apply 时使用的 field manager
+APPLY_FIELD_MANAGER = "ack-mcp-server"
+
+# kubectl_rollout 支持的操作，及 wait 的默认等待时长（秒）
+ROLLOUT_ACTIONS = ("status", "restart", "wait")
+DEFAULT_ROLLOUT_WAIT_SECONDS = 300
+
+# kubectl_node 支持的操作，及 drain 时被 PodDisruptionBudget 阻止的驱逐的重试间隔（秒）
+NODE_ACTIONS = ("cordon", "uncordon", "drain")
+DRAIN_RETRY_INTERVAL = 5
+
+# 导出包中各类对象的 apply 顺序
+EXPORT_KIND_ORDER = [
+    "ServiceAccount", "ConfigMap", "Secret", "PersistentVolumeClaim",
+    "Deployment", "StatefulSet", "DaemonSet", "Service", "HorizontalPodAutoscaler",
+]
+
+
+def _unsupported_resource_error(resource: str, api_version: Optional[str]) -> ValueError:
+    """内置注册表与 API 发现均无法解析资源类型时的错误"""
+    return ValueError(
+        f"unsupported resource '{resource}'{' (' + api_version + ')' if api_version else ''}: "
+        f"not a built-in type ({', '.join(s.resource for s in RESOURCE_SPECS)}) "
+        f"and not found in cluster API discovery"
+    )
+
+
+def _select_container(
+    pod: Dict[str, Any], name: str, container: Optional[str]
+) -> Tuple[Optional[str], Optional[ErrorModel]]:
+    """确定 Pod 中要操作的容器：未指定时要求 Pod 只有一个容器，指定时校验容器存在"""
+    spec = pod.get("spec") or {}
+    containers = [c.get("name") for c in spec.get("containers") or []]
+    all_containers = containers + [
+        c.get("name") for c in (spec.get("initContainers") or []) + (spec.get("ephemeralContainers") or [])
+    ]
+    if not container:
+        if len(containers) != 1:
+            return None, ErrorModel(
+                error_code="InvalidParameter",
+                error_message=f"pod {name} has {len(containers)} containers, container is required: "
+                              f"{', '.join(all_containers)}",
+            )
+        return containers[0], None
+    if container not in all_containers:
+        return None, ErrorModel(
+            error_code="ContainerNotFound",
+            error_message=f"container {container} not found in pod {name}, available: {', '.join(all_containers)}",
+        )
+    return container, None
+
+
+def _is_forbidden(error: KubectlCommandError) -> bool:
+    """kubectl 错误是否为 RBAC 拒绝（Forbidden）"""
+    classified = classify_kubectl_error(error.stderr or str(error))
+    return bool(classified) and classified["error_code"] == "Forbidden"
+
+
+def _read_only_error(tool: str) -> PermissionError:
+    """只读模式下调用写入类工具时的错误"""
+    return PermissionError(
+        f"{tool} modifies cluster resources and is disabled in read-only mode; "
+        f"start the server with --allow-write to enable it"
+    )
+
+
+class KubectlResourceHandler:
+    """Handler for structured Kubernetes resource queries."""
+
+    def __init__(self, server: FastMCP, settings: Optional[Dict[str, Any]] = None):
+        """Initialize the kubectl resource handler.
+
+        Args:
+            server: FastMCP server instance
+            settings: Configuration settings
+        """
+        self.settings = settings or {}
+
+        # Per-handler toggle
+        self.enable_execution_log = self.settings.get("enable_execution_log", False)
+        self.allow_write = self.settings.get("allow_write", False)
+
+        # 未指定命名空间时单个对象查询使用的命名空间，由 main_server 按 --default-namespace 解析
+        self.default_namespace = self.settings.get("default_namespace") or DEFAULT_NAMESPACE
+
+        # kubectl 执行器
+        self.runner = KubectlRunner(self.settings)
+
+        # API 发现结果缓存，进程内共享
+        self.discovery_cache = get_discovery_cache(self.settings)
+
+        # 日志归档缓存：archive_id -> gzip 压缩的 tar 字节
+        self._log_archives: TTLCache = TTLCache(maxsize=LOG_ARCHIVE_MAX_COUNT, ttl=LOG_ARCHIVE_TTL_SECONDS)
+
+        if server is None:
+            return
+        self.server = server
+
+        supported = ", ".join(spec.resource for spec in RESOURCE_SPECS)
+        self.server.tool(
+            name="kubectl_get",
+            description=f"""查询 Kubernetes 资源并返回结构化摘要。
+
+## 使用场景
+- 列出或查看指定资源，返回名称、命名空间、创建时间、存活时间及类型相关的关键字段
+- 按创建时间过滤：max_age=10m 查看最近 10 分钟内创建的 Pod（排查异常发布），min_age=30d 查看存在超过 30 天的对象（清理）
+- 按创建时间窗口过滤（审计）：created_after/created_before 指定 RFC3339 时间，如查询某次变更窗口内创建的对象
+- 排查 exceeded quota 等准入失败：resource=quota 返回各维度的已用量与上限（used/hard/ratio），at_limit/near_limit 列出已达到或接近（≥90%）上限的维度；resource=limits 返回 LimitRange 的 default/default_request/min/max
+- 排查自动伸缩：resource=hpa 返回扩缩目标（reference）、当前与期望副本数、各指标的目标值与当前值，以及 AbleToScale、ScalingActive、ScalingLimited 等伸缩状况
+
+## 注意事项
+- 内置支持的资源类型：{supported}（也支持短名称、单数形式与 Kind，大小写不敏感，如 po、svc、deploy、ns、cm、pvc），返回类型相关的摘要字段
+- 其他资源（如 CRD：VirtualService、ApplicationSet）通过集群 API 发现解析（支持其短名称，如 vs），仅返回通用字段，可配合 output=yaml 查看完整对象；同名资源存在于多个 API 组时需指定 api_version
+- min_age/max_age 支持 w/d/h/m/s 组合，如 10m、1h30m、7d；存活时间基于 API Server 时间计算
+- 创建时间过滤在 API 返回后执行，与 label_selector/field_selector 同时指定时需同时满足；filtered_out 为被过滤掉的对象数量
+- created_after 包含该时刻，created_before 不包含该时刻，需带时区，如 2024-01-31T12:00:00Z、2024-01-31T20:00:00+08:00
+- 列表查询默认每页返回 limit=100 个对象，has_more=true 时将 continue_token 传回以获取下一页；创建时间过滤在每页内进行
+- 仅有部分命名空间权限时（跨命名空间列出返回 Forbidden），默认改为逐个命名空间查询（最多 {MAX_FALLBACK_NAMESPACES} 个，{FALLBACK_NAMESPACE_CONCURRENCY} 个并发），返回有权限的结果，partial=true 且 namespace_errors 列出失败的命名空间及原因；此时不分页，最多返回 limit 个对象。查询的命名空间取 namespaces 参数，未指定时需要能够列出命名空间；per_namespace_fallback=false 时直接返回 Forbidden
+- output=yaml 默认去除 managedFields、generateName 与 last-applied-configuration 注解以减少输出，trim=false 时返回原始对象
+- output=wide 额外返回 kubectl 风格的表格（如 Pod 为 NAME、READY、STATUS、RESTARTS、AGE、IP、NODE）；output=custom-columns=NAME:.metadata.name,NODE:.spec.nodeName 按 JSONPath 自定义列（支持 .a.b、[0]、[*]、['key']）
+- export=true 返回适合 GitOps 的清单（yaml 字段）：去除 status、服务端维护的 metadata（uid、resourceVersion、creationTimestamp、generation、managedFields、selfLink、ownerReferences）、last-applied-configuration 等集群相关注解，以及 Service 的 clusterIP/nodePort、PVC 绑定的 volumeName、Pod 的 nodeName 等分配结果
+- Secret 摘要仅返回类型与键名；output=yaml 时 data/stringData 的值默认替换为 <redacted, N bytes>，仅在 reveal_secrets=true 时返回真实内容
+"""
+        )(self.kubectl_get)
+
+        self.server.tool(
+            name="kubectl_multi_get",
+            description=f"""在一次调用中批量查询多个资源，结果按调用方指定的 label 返回。
+
+## 使用场景
+- 排查时需要同时查看多类资源，如某个应用的 Deployment、Service、Ingress、ConfigMap 与 Pod，减少工具调用次数
+- 对比多个命名空间中的同类资源
+
+## 注意事项
+- items 中每一项包含 label（结果的键，不能重复）、resource、namespace、name、label_selector 与 api_version，含义与 kubectl_get 相同
+- 每项返回与 kubectl_get（output=json）相同的结构化摘要，列表查询最多返回 {DEFAULT_LIST_LIMIT} 个对象，需要分页或按时间过滤时使用 kubectl_get
+- 单次最多 {MAX_MULTI_GET_ITEMS} 项，最多 {MULTI_GET_CONCURRENCY} 项并发查询
+- 单项失败（如资源不存在、无权限）不影响其他项：错误在该项结果的 error 字段中返回，succeeded/failed 为成功与失败的数量
+"""
+        )(self.kubectl_multi_get)
+
+        self.server.tool(
+            name="list_namespaces",
+            description="""列出集群中的命名空间及其状态。
+
+## 使用场景
+- 查询其他资源前确认可用的命名空间
+- 排查命名空间卡在 Terminating 的问题
+
+## 注意事项
+- 返回名称、状态（Active/Terminating）、创建时间、存活时间与标签；查看单个命名空间可使用 kubectl_get（resource=namespaces）
+"""
+        )(self.list_namespaces)
+
+        self.server.tool(
+            name="list_crds",
+            description="""列出集群中已安装的 CustomResourceDefinition（CRD）。
+
+## 使用场景
+- 操作自定义资源前确认集群安装了哪些 CRD 及其 API 组、版本与 Kind
+- 按 API 组过滤，如 group=*.aliyun.com 查看阿里云组件提供的全部 CRD
+
+## 注意事项
+- 返回 group、kind、plural、short_names、scope（Namespaced/Cluster）、各版本的 served/storage 状态及是否 Established
+- 查询具体的自定义资源可使用 kubectl_get，resource 传入 plural 或 short_names（同名资源存在于多个 API 组时需指定 api_version）
+"""
+        )(self.list_crds)
+
+        self.server.tool(
+            name="cluster_summary",
+            description="""一次调用获取集群整体健康概览，替代多次 kubectl_get 查询。
+
+## 使用场景
+- 排查问题前快速了解集群规模与整体状态
+- 确认是否存在未就绪节点、Pending/Failed Pod 或不可用的 Deployment
+
+## 注意事项
+- 返回 Kubernetes 版本、节点数量（ready/not_ready/unschedulable）、按阶段统计的 Pod 数量、Deployment 数量（available/unavailable）及命名空间数量
+- 仅返回统计数字，定位具体对象可使用 kubectl_get（如 field_selector=status.phase=Pending）
+"""
+        )(self.cluster_summary)
+
+        self.server.tool(
+            name="kubectl_owner_tree",
+            description=f"""沿 ownerReferences 查询对象的属主链与属主关系树，用于根因分析。
+
+## 使用场景
+- 从异常 Pod 向上定位所属的 ReplicaSet、Deployment（或 StatefulSet、DaemonSet、Job、CronJob、自定义控制器）
+- 从 Deployment、CronJob 等向下查看其 ReplicaSet/Job 与 Pod 及各自状态，如新旧 ReplicaSet 的就绪情况
+
+## 注意事项
+- owners 为由近及远的属主链；tree 以最顶层属主为根向下展开（Deployment→ReplicaSet→Pod、CronJob→Job→Pod、StatefulSet/DaemonSet/Job→Pod），查询对象标记 target=true
+- 自定义资源等非内置属主通过 API 发现解析；属主已被删除或无法解析时在 warnings 中说明
+- 最多遍历 {MAX_OWNER_DEPTH} 层，每个节点最多列出 {MAX_OWNER_TREE_CHILDREN} 个子对象
+"""
+        )(self.kubectl_owner_tree)
+
+        self.server.tool(
+            name="kubectl_events",
+            description=f"""查询事件，按最近发生时间倒序返回精简格式（时间、类型、原因、对象、消息）。
+
+## 使用场景
+- 故障排查的第一步：warnings_only=true 仅查看 Warning 事件
+- 查看某个对象相关的事件：指定 object_name（可配合 object_kind）
+
+## 注意事项
+- namespace 为空或 all 表示全部命名空间
+- 默认返回最近 {DEFAULT_EVENT_LIMIT} 条，total 为过滤后的事件总数
+"""
+        )(self.kubectl_events)
+
+        self.server.tool(
+            name="kubectl_describe",
+            description=f"""查看单个资源的详情及相关事件，输出类似 kubectl describe 的可读文本。
+
+## 使用场景
+- 排查 Pod 启动失败、调度失败等问题：一次调用同时获取对象状态与其 Events
+- 排查 HPA 不扩缩或频繁扩缩：resource=hpa 同时返回指标、伸缩状况与 SuccessfulRescale、FailedGetResourceMetric 等事件
+
+## 注意事项
+- 内置支持的资源类型：{supported}（支持短名称、单数形式与 Kind，大小写不敏感）；其他资源（如 CRD）通过集群 API 发现解析，可使用其短名称
+- name 必填；对象没有相关事件时输出 "No events found"
+"""
+        )(self.kubectl_describe)
+
+        self.server.tool(
+            name="kubectl_explain",
+            description="""查看资源支持的字段及其类型与说明，类似 kubectl explain <resource>[.<field>]，基于集群的 OpenAPI v3 schema。
+
+## 使用场景
+- 编写或修改清单前确认字段名、类型与是否必填，如 resource=pods、field_path=spec.containers.resources
+- 查看 CRD 的字段定义，字段以集群中实际安装的版本为准
+
+## 注意事项
+- field_path 以 . 分隔，可带资源名前缀（pod.spec.containers 与 spec.containers 等价）；列表（[]Container）与 map（map[string]...）字段自动进入其元素继续查找
+- 返回该字段的类型、说明、是否必填及其子字段；字段不存在时返回错误并列出可用的字段
+- 需要集群提供 OpenAPI v3（/openapi/v3，Kubernetes 1.27 起默认开启）；同名资源存在于多个 API 组时需指定 api_version
+"""
+        )(self.kubectl_explain)
+
+        self.server.tool(
+            name="kubectl_top",
+            description="""查询节点或 Pod 的实时 CPU/内存用量（metrics.k8s.io），类似 kubectl top。
+
+## 使用场景
+- 找出 CPU 或内存消耗最高的 Pod/节点：sort_by=cpu 或 sort_by=memory，按用量从高到低排序
+
+## 注意事项
+- resource 取值 nodes 或 pods；查询 Pod 时 namespace 为空表示全部命名空间
+- 依赖集群安装 metrics-server，未安装或不可用时返回 MetricsServerUnavailable
+- 用量为 metrics-server 最近一次采集窗口（window）内的值，cpu 以 millicore、memory 以二进制单位表示
+"""
+        )(self.kubectl_top)
+
+        self.server.tool(
+            name="kubectl_watch",
+            description=f"""在限定时长内监听资源变更（watch），返回期间收到的 ADDED/MODIFIED/DELETED 事件。
+
+## 使用场景
+- 等待 Deployment 滚动发布完成、观察 Pod 的创建/重建/删除过程，替代反复轮询 kubectl_get
+
+## 注意事项
+- 监听开始时会先收到现有对象的 ADDED 事件，随后为实时变更
+- timeout_seconds 为监听时长，默认 {DEFAULT_WATCH_SECONDS} 秒，最长 {LONG_RUNNING_TIMEOUTS["watch"]} 秒；到时或客户端断开时关闭监听
+- 每个事件同时以 MCP 日志通知的形式实时推送；最终结果最多返回 {MAX_WATCH_EVENTS} 个事件
+- 资源类型不支持 watch 时返回 WatchNotSupported
+"""
+        )(self.kubectl_watch)
+
+        self.server.tool(
+            name="kubectl_logs",
+            description=f"""读取单个 Pod 容器的日志。
+
+## 使用场景
+- 排查容器报错：查看最近的日志输出
+- 排查容器重启：previous=true 查看重启前（上一次运行）容器的日志
+
+## 注意事项
+- Pod 包含多个容器时必须指定 container，否则返回可用容器列表
+- 默认读取最后 {DEFAULT_LOG_TAIL_LINES} 行，最多 {MAX_LOG_TAIL_LINES} 行；返回内容超过 {MAX_LOG_BYTES // 1024}KiB 时仅保留末尾部分并标记 truncated
+- 需要收集多个 Pod 的日志时使用 kubectl_logs_archive
+"""
+        )(self.kubectl_logs)
+
+        self.server.tool(
+            name="kubectl_logs_archive",
+            description=f"""按标签选择器收集一组 Pod 的日志，打包为 gzip 压缩的 tar 归档，并通过 MCP resource 返回引用。
+
+## 使用场景
+- 事故现场留存：一次性抓取某个服务所有 Pod、所有容器的日志
+- 避免多次调用日志工具以及在响应中返回大量日志文本
+
+## 注意事项
+- 归档结构：<pod>/<container>.log，以及 manifest.json（收集参数与失败列表）
+- 通过返回的 resource_uri（ack-logs://archives/{{archive_id}}）读取归档内容，归档保留 30 分钟
+- 每个容器最多读取 tail_lines 行（1 至 {MAX_LOG_TAIL_LINES}），最多收集 max_pods 个 Pod（1 至 {MAX_LOG_ARCHIVE_PODS}），超出范围时返回 InvalidParameter
+"""
+        )(self.kubectl_logs_archive)
+
+        self.server.tool(
+            name="kubectl_export_bundle",
+            description="""导出工作负载及其全部依赖对象为可重新 apply 的多文档 YAML。
+
+## 使用场景
+- 将工作负载迁移或复制到其他命名空间/集群
+- 一次性获取工作负载完整的依赖关系：引用的 ConfigMap、Secret、ServiceAccount、PVC，选中其 Pod 的 Service，以及指向它的 HPA
+
+## 注意事项
+- 导出内容已去除 status、uid、resourceVersion、managedFields、ownerReferences、namespace 及 ClusterIP、nodePort、PVC 绑定的 volumeName 等集群相关字段，可通过 kubectl apply -n <目标命名空间> -f 重新创建
+- 默认 Secret 的值会被置空（保留键名），仅在 include_secret_data=true 时导出实际内容；ServiceAccount token 类型的 Secret 与 default ServiceAccount 不导出
+- PVC 仅导出声明，不包含数据
+"""
+        )(self.kubectl_export_bundle)
+
+        self.server.tool(
+            name="kubectl_apply",
+            description=f"""以 server-side apply 创建或更新资源，类似 kubectl apply --server-side。
+
+## 使用场景
+- 创建或更新 YAML/JSON 清单中的资源，如部署工作负载、修改 ConfigMap、重新 apply kubectl_export_bundle 导出的清单
+- dry_run=true 由 API Server 校验清单（含准入 Webhook）但不实际持久化
+
+## 注意事项
+- 仅在服务以 --allow-write 启动时可用，只读模式（默认或 --read-only）下返回 WriteNotAllowed
+- 支持以 --- 分隔的多文档及 List 类型，按顺序逐个 apply，单个对象失败不影响其余对象，results 中返回各对象结果
+- field manager 为 {APPLY_FIELD_MANAGER}；字段已被其他管理者（如 kubectl、控制器）管理时冲突失败，确认后可指定 force_conflicts=true 强制接管
+- namespace 仅作用于清单中未指定命名空间的对象
+"""
+        )(self.kubectl_apply)
+
+        self.server.tool(
+            name="kubectl_diff",
+            description="""对比清单与集群中的实际状态，返回 unified diff，类似 kubectl diff，不做任何修改。
+
+## 使用场景
+- 在 kubectl_apply 之前审阅清单将带来的变更
+- 确认集群中的对象是否与期望的清单一致（配置漂移）
+
+## 注意事项
+- 支持以 --- 分隔的多文档及 List 类型，逐个对象对比；对象不存在时 status=create，diff 为整个对象
+- 两侧均去除 status、managedFields、resourceVersion 等服务端维护的字段；实际对象只保留清单中出现的字段，API Server 填充的默认值及其他管理者维护的字段不参与对比
+- Secret 的值以 sha256 摘要呈现，仅体现是否变化；stringData 按编码后的 data 对比
+- namespace 仅作用于清单中未指定命名空间的对象
+"""
+        )(self.kubectl_diff)
+
+        self.server.tool(
+            name="kubectl_rollout",
+            description=f"""查询 Deployment 的发布状态，或触发滚动重启。
+
+## 使用场景
+- action=status：发布后确认是否完成，返回 complete（完成）、progressing（进行中）或 stuck（超过 progressDeadlineSeconds 仍未完成），以及副本数与 Progressing/Available 条件信息
+- action=restart：滚动重启 Deployment 的全部 Pod（如重新加载挂载的 ConfigMap/Secret），等同于 kubectl rollout restart
+- action=wait：阻塞等待发布完成（updated/available 副本数达到期望且最新 generation 已被观察到），等同于 kubectl rollout status，无需反复轮询
+
+## 注意事项
+- restart 通过在 Pod 模板写入 kubectl.kubernetes.io/restartedAt 注解触发，需服务以 --allow-write 启动，只读模式下返回 WriteNotAllowed
+- restart 后可调用 action=wait 等待发布完成
+- wait 通过 watch 监听 Deployment，发布完成或卡住（stuck）时立即返回；timeout_seconds 为最长等待时间，默认 {DEFAULT_ROLLOUT_WAIT_SECONDS} 秒，最长 {LONG_RUNNING_TIMEOUTS["rollout"]} 秒
+- wait 超时时 timed_out=true，返回当前状态及阻塞发布的条件（blocking_condition，如 ReplicaFailure、Available=False）
+"""
+        )(self.kubectl_rollout)
+
+        self.server.tool(
+            name="kubectl_node",
+            description=f"""节点维护：标记节点不可调度（cordon）、恢复调度（uncordon），或排空节点（drain）。
+
+## 使用场景
+- 节点维护、升级或下线前，action=drain 标记节点不可调度并通过 Eviction API 驱逐其上的 Pod
+- 维护完成后 action=uncordon 恢复调度
+
+## 注意事项
+- 三个操作均需服务以 --allow-write 启动，只读模式下返回 WriteNotAllowed
+- drain 遵循 PodDisruptionBudget：被 PDB 阻止的驱逐每 {DRAIN_RETRY_INTERVAL} 秒重试，直至超时（默认 {LONG_RUNNING_TIMEOUTS["drain"]} 秒，可通过 timeout_seconds 调整），仍未驱逐的 Pod 列入 failed
+- drain 跳过 DaemonSet 管理的 Pod 与静态 Pod 的镜像 Pod；未受控制器管理的 Pod 需 force=true，使用 emptyDir 的 Pod 需 delete_emptydir_data=true，否则跳过并在 skipped 中说明原因
+- evicted 表示驱逐请求已被接受，Pod 会按 terminationGracePeriodSeconds 优雅退出
+"""
+        )(self.kubectl_node)
+
+        self.server.tool(
+            name="kubectl_delete",
+            description="""删除单个资源，支持内置资源与 CRD，类似 kubectl delete <resource> <name>。
+
+## 使用场景
+- 清理卡住或不再需要的资源，如异常的 Pod、遗留的 Job、自定义资源
+- dry_run=true 由 API Server 执行删除校验（含准入 Webhook）但不实际删除，用于预览
+
+## 注意事项
+- 仅在服务以 --allow-write 启动时可用，只读模式（默认或 --read-only）下返回 WriteNotAllowed
+- 为防止误删，confirm 必须与 name 完全一致
+- 删除不等待对象被移除：返回 status=deleted 及删除时间；对象仍有 finalizer 时返回 status=terminating、deletionTimestamp 与待处理的 finalizers
+- 同名资源存在于多个 API 组时需指定 api_version
+"""
+        )(self.kubectl_delete)
+
+        self.server.tool(
+            name="kubectl_metadata",
+            description="""添加、修改或删除资源的标签与注解，支持内置资源与 CRD，类似 kubectl label / kubectl annotate。
+
+## 使用场景
+- action=label 修改 metadata.labels，如为节点打标签 changes=["disktype=ssd"]
+- action=annotate 修改 metadata.annotations，如记录变更原因
+- 删除键使用 kubectl 的 key- 语法，如 changes=["tier-"]
+
+## 注意事项
+- 仅在服务以 --allow-write 启动时可用，只读模式（默认或 --read-only）下返回 WriteNotAllowed
+- 修改前校验键（及标签值）语法；键已有不同的值时需 overwrite=true，否则返回错误且不做任何修改
+- 以 merge patch 提交并携带对象的 resourceVersion，对象在读取后被他人修改时返回冲突错误，可重试
+- 返回修改后的完整 labels 与 annotations；删除不存在的键不报错，在 warnings 中说明
+"""
+        )(self.kubectl_metadata)
+
+        self.server.tool(
+            name="kubectl_patch",
+            description="""以 patch 方式修改单个资源，支持内置资源与 CRD，类似 kubectl patch <resource> <name> --type <patch_type> -p <patch>。
+
+## 使用场景
+- 专用工具（kubectl_rollout、kubectl_metadata 等）未覆盖的精细修改，如调整某个容器的镜像或资源限制、修改 Service 端口
+- patch_type=strategic（默认）：strategic merge patch，列表按 name 等键合并，如 {"spec":{"template":{"spec":{"containers":[{"name":"app","image":"app:v2"}]}}}}
+- patch_type=merge：JSON merge patch (RFC 7386)，列表整体替换，字段值为 null 表示删除
+- patch_type=json：JSON Patch (RFC 6902) 操作数组，如 [{"op":"replace","path":"/spec/replicas","value":3}]
+- dry_run=true 由 API Server 执行校验（含准入 Webhook）但不实际修改，用于预览 patch 结果
+
+## 注意事项
+- 仅在服务以 --allow-write 启动时可用，只读模式（默认或 --read-only）下返回 WriteNotAllowed
+- patch 为 JSON 字符串，提交前校验其语法及与 patch_type 是否匹配；自定义资源不支持 strategic，需使用 merge 或 json
+- 返回 patch 后的完整对象（Secret 的 data 已脱敏）；changed 表示对象是否实际发生变化
+- 同名资源存在于多个 API 组时需指定 api_version
+"""
+        )(self.kubectl_patch)
+
+        self.server.tool(
+            name="kubectl_exec",
+            description=f"""在容器内执行命令并返回输出，类似 kubectl exec <pod> -c <container> -- <command>（非交互、无 TTY）。
+
+## 使用场景
+- 排查时查看容器内的配置文件、环境变量、进程、网络连通性等，如 command=["cat", "/etc/resolv.conf"]
+
+## 注意事项
+- 执行的命令可能修改容器状态，仅在服务以 --allow-write 启动时可用，只读模式（默认或 --read-only）下返回 WriteNotAllowed
+- command 为参数数组，不经过 shell 解释；需要管道、重定向时显式使用 ["sh", "-c", "..."]（镜像中需包含 sh）
+- 默认超时 {LONG_RUNNING_TIMEOUTS["exec"]} 秒；输出为 stdout 后接 stderr，超过 {MAX_EXEC_OUTPUT_BYTES // 1024}KiB 时仅保留开头部分并标记 truncated
+- 命令以非零状态退出时返回 exit_code 与输出，不视为工具错误；Pod 或容器不存在、容器未运行时返回错误
+"""
+        )(self.kubectl_exec)
+
+        self.server.resource(
+            LOG_ARCHIVE_URI_TEMPLATE,
+            name="log_archive",
+            description="kubectl_logs_archive 生成的 gzip 压缩 tar 日志归档",
+            mime_type="application/gzip",
+        )(self.read_log_archive)
+
+        # 集群对象以资源模板形式暴露，如 k8s://{cluster_id}/{namespace}/pods/{name}，可通过 ?context= 指定 kubeconfig context
+        for resource in OBJECT_URI_RESOURCES:
+            spec = find_resource_spec(resource)
+            self.server.resource(
+                OBJECT_URI_TEMPLATE.replace("{resource}", resource) + "{?context}",
+                name=f"cluster_{spec.kind.lower()}",
+                description=f"集群中 {spec.kind} 对象的当前状态（JSON，已去除 managedFields 等噪声字段），每次读取时实时查询",
+                mime_type="application/json",
+            )(self._object_reader(spec))
+
+        logger.info("Kubectl Resource Handler initialized")
+
+    async def kubectl_get(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、deployments、svc"),
+        name: Optional[str] = Field(None, description="资源名称，为空表示列出全部"),
+        namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间，指定 name 时为空表示服务的默认命名空间（集群级资源忽略该参数）"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，如 networking.istio.io/v1beta1，用于区分不同 API 组下的同名资源（如 CRD）"),
+        label_selector: Optional[str] = Field(None, description="标签选择器，如 app=nginx,tier in (web,api)；与 name 同时指定时以 name 为准"),
+        field_selector: Optional[str] = Field(None, description="字段选择器，如 status.phase=Running、involvedObject.name=web-1，支持的字段因资源类型而异"),
+        min_age: Optional[str] = Field(None, description="最小存活时间，仅返回创建时间早于该时长的对象，如 30d"),
+        max_age: Optional[str] = Field(None, description="最大存活时间，仅返回在该时长内创建的对象，如 10m"),
+        created_after: Optional[str] = Field(None, description="仅返回在该时间（含）之后创建的对象，RFC3339 格式，如 2024-01-31T12:00:00Z"),
+        created_before: Optional[str] = Field(None, description="仅返回在该时间之前创建的对象，RFC3339 格式，如 2024-01-31T13:00:00Z"),
+        limit: int = Field(DEFAULT_LIST_LIMIT, description="列表查询每页最多返回的对象数量，0 表示不分页返回全部"),
+        continue_token: Optional[str] = Field(None, description="上一页返回的 continue_token，用于获取下一页"),
+        output: str = Field("json", description="输出格式：json（结构化摘要）、yaml（额外返回完整对象的 YAML）、wide（额外返回 kubectl 风格表格）或 custom-columns=<规格>（按 JSONPath 自定义列的表格，如 custom-columns=NAME:.metadata.name,NODE:.spec.nodeName）"),
+        reveal_secrets: bool = Field(False, description="output=yaml 时是否返回 Secret 的真实内容，默认替换为 <redacted, N bytes>"),
+        trim: bool = Field(True, description="output=yaml 时是否去除 managedFields、generateName、last-applied-configuration 注解等噪声字段，false 返回原始对象"),
+        export: bool = Field(False, description="以 YAML 返回可直接提交到 Git 并 kubectl apply 的清单：去除 status、uid、resourceVersion、creationTimestamp、managedFields 等服务端字段及集群相关的注解与分配结果（隐含 output=yaml）"),
+        per_namespace_fallback: bool = Field(True, description="namespace 为空或 all 且无权跨命名空间列出时，是否逐个命名空间查询并返回有权限的部分结果"),
+        namespaces: Optional[List[str]] = Field(None, description="逐个命名空间查询时查询的命名空间，如 [\"team-a\", \"team-b\"]；为空时列出集群的全部命名空间"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlGetOutput:
+        """查询资源并按创建时间过滤"""
+        execution_log, start_ms = start_execution_log("kubectl_get", cluster_id, self.enable_execution_log)
+        namespace = normalize_namespace(namespace)
+        result = KubectlGetOutput(
+            cluster_id=cluster_id, resource=resource, namespace=namespace, execution_log=execution_log,
+        )
+        try:
+            output_format, _, columns_spec = (output or "json").strip().partition("=")
+            output_format = output_format.strip().lower()
+            try:
+                if output_format not in OUTPUT_FORMATS:
+                    raise ValueError(f"unsupported output format '{output}', supported: {', '.join(OUTPUT_FORMATS)}")
+                custom_columns = parse_custom_columns(columns_spec) if output_format == "custom-columns" else None
+                if export and output_format not in ("json", "yaml"):
+                    raise ValueError(f"export cannot be combined with output={output_format}")
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                result.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return result
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                result.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return result
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                result.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return result
+            # 按名称查询单个对象时不能跨命名空间，未指定命名空间时使用默认命名空间
+            namespace = result.namespace = resolve_namespace(
+                spec, namespace, result.warnings, default=self.default_namespace if name else None
+            )
+            if name and label_selector:
+                result.warnings.append(f"name 与 label_selector 同时指定，已忽略 label_selector '{label_selector}'")
+                label_selector = None
+            if name and field_selector:
+                result.warnings.append(f"name 与 field_selector 同时指定，已忽略 field_selector '{field_selector}'")
+                field_selector = None
+            execution_log.warnings.extend(result.warnings)
+            if continue_token and not (limit and limit > 0):
+                error = ValueError("continue_token requires limit > 0")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                result.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return result
+            try:
+                if label_selector:
+                    validate_label_selector(label_selector)
+                if field_selector:
+                    validate_field_selector(spec, field_selector)
+                created_after_time = parse_rfc3339(created_after, "created_after") if created_after else None
+                created_before_time = parse_rfc3339(created_before, "created_before") if created_before else None
+                if created_after_time and created_before_time and created_after_time >= created_before_time:
+                    raise ValueError(f"created_after '{created_after}' must be earlier than created_before '{created_before}'")
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                result.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return result
+            result.resource = spec.resource
+
+            min_age_delta = parse_duration(min_age) if min_age else None
+            max_age_delta = parse_duration(max_age) if max_age else None
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            selector_args = (["-l", label_selector] if label_selector else []) + (
+                [f"--field-selector={field_selector}"] if field_selector else []
+            )
+            try:
+                if not name and limit and limit > 0:
+                    # kubectl get 的 --chunk-size 会自动取完所有分页，单页查询需直接请求 list API
+                    path = list_api_path(spec, namespace, {
+                        "limit": limit,
+                        "continue": continue_token,
+                        "labelSelector": label_selector,
+                        "fieldSelector": field_selector,
+                    })
+                    data = await self.runner.run_json(kubeconfig_path, ["get", "--raw", path], execution_log, timeout=timeout)
+                    list_meta = data.get("metadata") or {}
+                    result.continue_token = list_meta.get("continue") or None
+                    result.remaining_item_count = list_meta.get("remainingItemCount")
+                    result.has_more = result.continue_token is not None
+                else:
+                    args = ["get", spec.kubectl_name]
+                    if name:
+                        args.append(name)
+                    if spec.namespaced and (namespace or not name):
+                        args += namespace_args(namespace)
+                    args += [*selector_args, "-o", "json"]
+                    data = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+            except KubectlCommandError as e:
+                # 仅有部分命名空间权限的用户无法跨命名空间列出，改为逐个命名空间查询
+                if not (per_namespace_fallback and namespace is None and spec.namespaced and not name
+                        and not continue_token and _is_forbidden(e)):
+                    raise
+                data = await self._get_per_namespace(
+                    kubeconfig_path, spec, selector_args, limit, namespaces, result, execution_log, timeout, e
+                )
+            items = (data.get("items") or []) if "items" in data else ([data] if data else [])
+
+            if min_age_delta is not None or max_age_delta is not None:
+                now, source = await self.runner.server_time(kubeconfig_path, execution_log, timeout=timeout)
+            else:
+                now, source = datetime.now(timezone.utc), "local"
+            if any(v is not None for v in (min_age_delta, max_age_delta, created_after_time, created_before_time)):
+                matched = filter_by_age(
+                    items, now, min_age_delta, max_age_delta, created_after_time, created_before_time,
+                )
+                result.filtered_out = len(items) - len(matched)
+                items = matched
+
+            result.items = [summarize_object(spec, item, now) for item in items]
+            if export:
+                documents = [clean_for_export(item) for item in items]
+                if spec.kind == "Secret" and not reveal_secrets and documents:
+                    documents = [redact_secret_values(item) for item in documents]
+                    result.warnings.append("Secret 的值已脱敏，提交或 apply 前需要补充内容（或使用 reveal_secrets=true）")
+                result.yaml = yaml.safe_dump_all(documents, sort_keys=False, allow_unicode=True)
+            elif output_format == "yaml":
+                documents = [trim_object(item) for item in items] if trim else list(items)
+                if spec.kind == "Secret" and not reveal_secrets:
+                    documents = [redact_secret_values(item) for item in documents]
+                result.yaml = yaml.safe_dump_all(documents, sort_keys=False, allow_unicode=True)
+            elif output_format == "wide":
+                result.table = format_wide_table(spec, result.items, include_namespace=not namespace and not name)
+            elif custom_columns:
+                result.table = format_custom_columns(custom_columns, items)
+            result.count = len(result.items)
+            result.reference_time = now.isoformat().replace("+00:00", "Z")
+            result.reference_time_source = source
+            finish_execution_log(execution_log, start_ms)
+            return result
+        except Exception as e:
+            logger.error(f"kubectl_get failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_get")
+            result.error = command_error_model(e, "GetResourceFailed")
+            return result
+
+    async def kubectl_multi_get(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        items: List[MultiGetItem] = Field(..., description="查询列表，每项包含 label（结果的键）、resource，以及可选的 namespace、name、label_selector、api_version"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="每项 kubectl 调用的超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlMultiGetOutput:
+        """并发执行多个资源查询，单项失败时在该项结果中返回错误"""
+        execution_log, start_ms = start_execution_log("kubectl_multi_get", cluster_id, self.enable_execution_log)
+        result = KubectlMultiGetOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            items = [MultiGetItem.model_validate(item) for item in items or []]
+            labels = [item.label.strip() for item in items]
+            if not items:
+                raise ValueError("items must contain at least one query")
+            if len(items) > MAX_MULTI_GET_ITEMS:
+                raise ValueError(f"at most {MAX_MULTI_GET_ITEMS} items are allowed, got {len(items)}")
+            if not all(labels):
+                raise ValueError("every item requires a non-empty label")
+            duplicates = sorted({label for label in labels if labels.count(label) > 1})
+            if duplicates:
+                raise ValueError(f"duplicate labels: {', '.join(duplicates)}")
+        except ValueError as error:
+            finish_execution_log(execution_log, start_ms, error, "validate_params")
+            result.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+            return result
+
+        semaphore = asyncio.Semaphore(MULTI_GET_CONCURRENCY)
+
+        async def get(item: MultiGetItem) -> KubectlGetOutput:
+            async with semaphore:
+                # kubectl_get 自身不抛出异常，错误记录在返回结果的 error 字段中
+                return await self.kubectl_get(
+                    ctx, cluster_id=cluster_id, resource=item.resource, name=item.name, namespace=item.namespace,
+                    api_version=item.api_version, label_selector=item.label_selector, field_selector=None,
+                    min_age=None, max_age=None, created_after=None, created_before=None,
+                    limit=DEFAULT_LIST_LIMIT, continue_token=None, output="json", reveal_secrets=False,
+                    trim=True, export=False, per_namespace_fallback=True, namespaces=None, context=context,
+                    timeout_seconds=timeout_seconds,
+                )
+
+        outputs = await asyncio.gather(*(get(item) for item in items))
+        for label, output in zip(labels, outputs):
+            result.results[label] = output
+            if output.error is None:
+                result.succeeded += 1
+            else:
+                result.failed += 1
+        if result.failed:
+            execution_log.warnings.append(
+                f"{result.failed} of {len(items)} queries failed: "
+                + ", ".join(label for label, output in result.results.items() if output.error is not None)
+            )
+        finish_execution_log(execution_log, start_ms)
+        return result
+
+    async def kubectl_events(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间"),
+        type: Optional[str] = Field(None, description="事件类型：Normal 或 Warning，为空表示全部"),
+        warnings_only: bool = Field(False, description="仅返回 Warning 事件，等价于 type=Warning"),
+        object_name: Optional[str] = Field(None, description="仅返回与该名称对象相关的事件"),
+        object_kind: Optional[str] = Field(None, description="仅返回与该类型对象相关的事件，如 Pod、Deployment"),
+        limit: int = Field(DEFAULT_EVENT_LIMIT, description="最多返回的事件数量"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlEventsOutput:
+        """查询事件并按最近发生时间倒序排列"""
+        execution_log, start_ms = start_execution_log("kubectl_events", cluster_id, self.enable_execution_log)
+        namespace = normalize_namespace(namespace)
+        event_type = "Warning" if warnings_only else type
+        output = KubectlEventsOutput(
+            cluster_id=cluster_id, namespace=namespace, type=event_type, execution_log=execution_log,
+        )
+        try:
+            if event_type:
+                matched = next((t for t in EVENT_TYPES if t.lower() == event_type.lower()), None)
+                if matched is None:
+                    error = ValueError(f"unsupported event type '{event_type}', supported: {', '.join(EVENT_TYPES)}")
+                    finish_execution_log(execution_log, start_ms, error, "validate_params")
+                    output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                    return output
+                output.type = event_type = matched
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            selectors = []
+            if event_type:
+                selectors.append(f"type={event_type}")
+            if object_name:
+                selectors.append(f"involvedObject.name={object_name}")
+            if object_kind:
+                selectors.append(f"involvedObject.kind={object_kind}")
+            args = ["get", "events", *namespace_args(namespace)]
+            if selectors:
+                args.append(f"--field-selector={','.join(selectors)}")
+            data = await self.runner.run_json(kubeconfig_path, [*args, "-o", "json"], execution_log, timeout=timeout)
+
+            epoch = datetime.min.replace(tzinfo=timezone.utc)
+            items = sorted(data.get("items") or [], key=lambda e: event_time(e) or epoch, reverse=True)
+            output.total = len(items)
+            limit = limit if limit and limit > 0 else DEFAULT_EVENT_LIMIT
+            for event in items[:limit]:
+                involved = event.get("involvedObject") or {}
+                seen = event_time(event)
+                output.events.append({
+                    "time": seen.isoformat().replace("+00:00", "Z") if seen else None,
+                    "type": event.get("type"),
+                    "reason": event.get("reason"),
+                    "object": f"{involved.get('kind')}/{involved.get('name')}",
+                    "namespace": (event.get("metadata") or {}).get("namespace"),
+                    "message": (event.get("message") or "").strip(),
+                    "count": event.get("count") or (event.get("series") or {}).get("count") or 1,
+                })
+            output.count = len(output.events)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_events failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_events")
+            output.error = command_error_model(e, "GetEventsFailed")
+            return output
+
+    async def list_namespaces(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        label_selector: Optional[str] = Field(None, description="标签选择器，如 team=payments"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ListNamespacesOutput:
+        """列出命名空间及其状态"""
+        execution_log, start_ms = start_execution_log("list_namespaces", cluster_id, self.enable_execution_log)
+        output = ListNamespacesOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            if label_selector:
+                try:
+                    validate_label_selector(label_selector)
+                except ValueError as error:
+                    finish_execution_log(execution_log, start_ms, error, "validate_params")
+                    output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                    return output
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            args = ["get", "namespaces"]
+            if label_selector:
+                args += ["-l", label_selector]
+            data = await self.runner.run_json(kubeconfig_path, [*args, "-o", "json"], execution_log, timeout=timeout)
+            spec = find_resource_spec("namespaces")
+            now = datetime.now(timezone.utc)
+            output.namespaces = [
+                {**summarize_object(spec, item, now), "labels": (item.get("metadata") or {}).get("labels") or {}}
+                for item in data.get("items") or []
+            ]
+            output.count = len(output.namespaces)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"list_namespaces failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "list_namespaces")
+            output.error = command_error_model(e, "ListNamespacesFailed")
+            return output
+
+    async def list_crds(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        group: Optional[str] = Field(None, description="API 组过滤，支持通配符，如 *.aliyun.com、argoproj.io"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ListCRDsOutput:
+        """列出已安装的 CRD"""
+        execution_log, start_ms = start_execution_log("list_crds", cluster_id, self.enable_execution_log)
+        group = group if isinstance(group, str) and group.strip() else None
+        output = ListCRDsOutput(cluster_id=cluster_id, group=group, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            data = await self.runner.run_json(
+                kubeconfig_path, ["get", "customresourcedefinitions", "-o", "json"], execution_log, timeout=timeout,
+            )
+            crds = [summarize_crd(item) for item in data.get("items") or []]
+            if group:
+                crds = [crd for crd in crds if fnmatch.fnmatchcase(crd["group"] or "", group.strip())]
+            output.crds = sorted(crds, key=lambda crd: (crd["group"] or "", crd["kind"] or ""))
+            output.count = len(output.crds)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"list_crds failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "list_crds")
+            output.error = command_error_model(e, "ListCRDsFailed")
+            return output
+
+    async def cluster_summary(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ClusterSummaryOutput:
+        """汇总集群节点、Pod、Deployment 与命名空间的数量"""
+        execution_log, start_ms = start_execution_log("cluster_summary", cluster_id, self.enable_execution_log)
+        output = ClusterSummaryOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            async def items(args: List[str]) -> List[Dict[str, Any]]:
+                data = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+                return data.get("items") or []
+
+            version, nodes, pods, deployments, namespaces = await asyncio.gather(
+                self.runner.run_json(kubeconfig_path, ["get", "--raw", "/version"], execution_log, timeout=timeout),
+                items(["get", "nodes", "-o", "json"]),
+                items(["get", "pods", "--all-namespaces", "-o", "json"]),
+                items(["get", "deployments", "--all-namespaces", "-o", "json"]),
+                items(["get", "namespaces", "-o", "json"]),
+            )
+            output.server_version = version.get("gitVersion")
+            counts = summarize_cluster_counts(nodes, pods, deployments, namespaces)
+            output.nodes = counts["nodes"]
+            output.pods = counts["pods"]
+            output.deployments = counts["deployments"]
+            output.namespaces = counts["namespaces"]
+            execution_log.messages.append(
+                f"{output.nodes['total']} nodes, {output.pods['total']} pods, "
+                f"{output.deployments['total']} deployments, {output.namespaces} namespaces"
+            )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"cluster_summary failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "cluster_summary")
+            output.error = command_error_model(e, "ClusterSummaryFailed")
+            return output
+
+    async def kubectl_owner_tree(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、replicasets、deployments、jobs"),
+        name: str = Field(..., description="资源名称"),
+        namespace: Optional[str] = Field(None, description="命名空间（集群级资源忽略该参数），为空时使用服务的默认命名空间"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlOwnerTreeOutput:
+        """向上追溯属主链，并从最顶层属主向下展开属主关系树"""
+        execution_log, start_ms = start_execution_log("kubectl_owner_tree", cluster_id, self.enable_execution_log)
+        output = KubectlOwnerTreeOutput(
+            cluster_id=cluster_id, resource=resource, name=name, namespace=namespace, execution_log=execution_log,
+        )
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings, default=self.default_namespace)
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            scope = ["-n", output.namespace] if spec.namespaced else []
+            target = await self.runner.run_json(
+                kubeconfig_path, ["get", spec.kubectl_name, name, *scope, "-o", "json"], execution_log, timeout=timeout,
+            )
+
+            # 向上：沿控制者 ownerReference 追溯到最顶层属主
+            chain = [target]
+            owner_resources: Dict[Tuple[str, str], Optional[Tuple[str, bool]]] = {}
+            while len(chain) <= MAX_OWNER_DEPTH:
+                ref = controller_owner(chain[-1])
+                if ref is None:
+                    break
+                label = f"{ref.get('kind')}/{ref.get('name')}"
+                key = (ref.get("apiVersion") or "", ref.get("kind") or "")
+                if key not in owner_resources:
+                    owner_resources[key] = await self._owner_resource(kubeconfig_path, *key, execution_log, timeout)
+                if owner_resources[key] is None:
+                    output.warnings.append(f"owner {label} ({key[0]}) is not a known resource type, stopped there")
+                    break
+                owner_resource, namespaced = owner_resources[key]
+                owner_namespace = (chain[-1].get("metadata") or {}).get("namespace")
+                owner = await self.runner.run_json(
+                    kubeconfig_path,
+                    ["get", owner_resource, ref.get("name"), *(["-n", owner_namespace] if namespaced else []),
+                     "--ignore-not-found", "-o", "json"],
+                    execution_log, timeout=timeout,
+                )
+                if not owner or (owner.get("metadata") or {}).get("uid") != ref.get("uid"):
+                    output.warnings.append(f"owner {label} no longer exists")
+                    break
+                chain.append(owner)
+            output.owners = [ownership_node(owner) for owner in chain[1:]]
+
+            # 向下：从最顶层属主展开子对象，属主链上的对象即使不在可展开的类型中也保留
+            listings: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+            target_uid = (target.get("metadata") or {}).get("uid")
+
+            async def expand(obj: Dict[str, Any], depth: int) -> Dict[str, Any]:
+                node = ownership_node(obj)
+                metadata = obj.get("metadata") or {}
+                if metadata.get("uid") == target_uid:
+                    node["target"] = True
+                children: List[Dict[str, Any]] = []
+                if depth < MAX_OWNER_DEPTH and metadata.get("namespace"):
+                    for child_resource in OWNED_RESOURCES.get(obj.get("kind"), ()):
+                        key = (child_resource, metadata["namespace"])
+                        if key not in listings:
+                            data = await self.runner.run_json(
+                                kubeconfig_path, ["get", child_resource, "-n", metadata["namespace"], "-o", "json"],
+                                execution_log, timeout=timeout,
+                            )
+                            listings[key] = data.get("items") or []
+                        children.extend(item for item in listings[key] if is_owned_by(item, metadata.get("uid")))
+                index = next((i for i, o in enumerate(chain) if o is obj), None)
+                if index:
+                    path_child = chain[index - 1]
+                    path_uid = (path_child.get("metadata") or {}).get("uid")
+                    children = [c for c in children if (c.get("metadata") or {}).get("uid") != path_uid]
+                    children.insert(0, path_child)
+                if children:
+                    node["children"] = [
+                        await expand(child, depth + 1) for child in children[:MAX_OWNER_TREE_CHILDREN]
+                    ]
+                    if len(children) > MAX_OWNER_TREE_CHILDREN:
+                        node["more_children"] = len(children) - MAX_OWNER_TREE_CHILDREN
+                return node
+
+            output.tree = await expand(chain[-1], 0)
+            execution_log.warnings.extend(output.warnings)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_owner_tree failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_owner_tree")
+            output.error = command_error_model(e, "OwnerTreeFailed")
+            return output
+
+    async def _owner_resource(
+        self, kubeconfig_path: str, api_version: str, kind: str, execution_log: ExecutionLog, timeout: int,
+    ) -> Optional[Tuple[str, bool]]:
+        """将 ownerReference 的 apiVersion/kind 解析为 (kubectl 资源名, 是否命名空间级)"""
+        known = WELL_KNOWN_OWNER_RESOURCES.get((api_version.rpartition("/")[0], kind))
+        if known:
+            return known
+        try:
+            spec = await self._discover_resource_spec(kubeconfig_path, kind, api_version, execution_log, timeout)
+        except (ValueError, KubectlCommandError) as e:
+            logger.warning(f"Failed to resolve owner kind {kind} ({api_version}): {e}")
+            return None
+        return (spec.kubectl_name, spec.namespaced) if spec else None
+
+    async def _get_per_namespace(
+        self,
+        kubeconfig_path: str,
+        spec: ResourceSpec,
+        selector_args: List[str],
+        limit: int,
+        namespaces: Optional[List[str]],
+        result: KubectlGetOutput,
+        execution_log: ExecutionLog,
+        timeout: int,
+        error: KubectlCommandError,
+    ) -> Dict[str, Any]:
+        """逐个命名空间列出对象并合并，失败的命名空间记入 result.namespace_errors；全部失败时抛出原错误
+
+        查询的命名空间取 namespaces 参数，为空时列出集群的命名空间
+        """
+        namespaces = list(dict.fromkeys(ns.strip() for ns in namespaces or [] if ns and ns.strip()))
+        if not namespaces:
+            try:
+                namespace_list = await self.runner.run_json(
+                    kubeconfig_path, ["get", "namespaces", "-o", "json"], execution_log, timeout=timeout,
+                )
+            except KubectlCommandError as e:
+                # 无权列出命名空间时无法逐个查询，返回原错误
+                logger.debug(f"Cannot list namespaces for per-namespace fallback: {e}")
+                raise error
+            namespaces = sorted(
+                (item.get("metadata") or {}).get("name") for item in namespace_list.get("items") or []
+                if (item.get("metadata") or {}).get("name")
+            )
+        warnings: List[str] = []
+        if len(namespaces) > MAX_FALLBACK_NAMESPACES:
+            warnings.append(
+                f"共有 {len(namespaces)} 个命名空间，逐个查询时仅查询前 {MAX_FALLBACK_NAMESPACES} 个，请指定 namespace 查询其余命名空间"
+            )
+            namespaces = namespaces[:MAX_FALLBACK_NAMESPACES]
+
+        semaphore = asyncio.Semaphore(FALLBACK_NAMESPACE_CONCURRENCY)
+
+        async def fetch(ns: str) -> Tuple[str, Optional[Dict[str, Any]], Optional[ErrorModel]]:
+            async with semaphore:
+                try:
+                    data = await self.runner.run_json(
+                        kubeconfig_path, ["get", spec.kubectl_name, "-n", ns, *selector_args, "-o", "json"],
+                        execution_log, timeout=timeout,
+                    )
+                    return ns, data, None
+                except KubectlCommandError as e:
+                    return ns, None, command_error_model(e, "GetFailed")
+
+        items: List[Dict[str, Any]] = []
+        succeeded = 0
+        for ns, data, error_model in await asyncio.gather(*(fetch(ns) for ns in namespaces)):
+            if error_model is not None:
+                result.namespace_errors[ns] = error_model.error_message
+                continue
+            succeeded += 1
+            items.extend(data.get("items") or [])
+        if not succeeded:
+            raise error
+
+        result.partial = bool(result.namespace_errors)
+        warnings.insert(0, (
+            f"无权跨命名空间列出 {spec.resource}，已逐个命名空间查询：{succeeded} 个成功，"
+            f"{len(result.namespace_errors)} 个失败（见 namespace_errors）"
+        ))
+        if limit and limit > 0 and len(items) > limit:
+            warnings.append(
+                f"逐个命名空间查询时不支持分页，共 {len(items)} 个对象，仅返回前 {limit} 个，请指定 namespace 或增大 limit"
+            )
+            items = items[:limit]
+        result.warnings.extend(warnings)
+        execution_log.warnings.extend(warnings)
+        return {"items": items}
+
+    async def _resolve_resource_spec(
+        self,
+        ctx: Context,
+        cluster_id: str,
+        resource: str,
+        api_version: Optional[str],
+        context: Optional[str],
+        execution_log: ExecutionLog,
+        timeout: int,
+    ) -> Tuple[Optional[ResourceSpec], Optional[str]]:
+        """解析资源类型，返回 (spec, kubeconfig_path)；触发 API 发现时 kubeconfig_path 已解析，否则为 None"""
+        # 内置注册表之外的资源（如 CRD）或指定了 api_version 时，通过 API 发现解析资源类型
+        api_version = api_version if isinstance(api_version, str) and api_version else None
+        spec = None if api_version else find_resource_spec(resource)
+        if spec is not None:
+            return spec, None
+        kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+        spec = await self._discover_resource_spec(kubeconfig_path, resource, api_version, execution_log, timeout)
+        return spec, kubeconfig_path
+
+    async def _discover_resource_spec(
+        self,
+        kubeconfig_path: str,
+        resource: str,
+        api_version: Optional[str],
+        execution_log: ExecutionLog,
+        timeout: int,
+    ) -> Optional[ResourceSpec]:
+        """通过 kubectl api-resources 发现资源类型"""
+        entries = await self._api_resources(kubeconfig_path, execution_log, timeout)
+        spec = resolve_discovered_spec(entries, resource, api_version)
+        if spec is None and self.discovery_cache is not None:
+            # 可能是缓存之后新安装的 CRD，缓存不够新时重新发现一次
+            entries = await self._api_resources(kubeconfig_path, execution_log, timeout, MIN_REFRESH_SECONDS)
+            spec = resolve_discovered_spec(entries, resource, api_version)
+        return spec
+
+    async def resolve_namespaced(
+        self,
+        ctx: Optional[Context],
+        cluster_id: str,
+        resource: str,
+        api_version: Optional[str] = None,
+        context: Optional[str] = None,
+    ) -> Optional[bool]:
+        """解析资源类型（复数名、短名称或 Kind）是否为命名空间级，供命名空间白名单校验；无法解析时返回 None"""
+        execution_log = ExecutionLog(tool_call_id=f"resolve_scope_{cluster_id}_{int(time.time() * 1000)}")
+        try:
+            spec, _ = await self._resolve_resource_spec(
+                ctx, cluster_id, resource, api_version, context, execution_log, self.runner.resolve_timeout()
+            )
+        except Exception as e:
+            logger.warning(f"Failed to resolve the scope of {resource} ({api_version or 'any version'}): {e}")
+            return None
+        return None if spec is None else spec.namespaced
+
+    async def _api_resources(
+        self, kubeconfig_path: str, execution_log: ExecutionLog, timeout: int, max_age: Optional[float] = None,
+    ) -> List[Dict[str, Any]]:
+        """获取集群的 API 发现结果，优先使用缓存（按 impersonation 身份区分，按请求传入的 kubeconfig 不缓存）"""
+
+        async def fetch() -> List[Dict[str, Any]]:
+            result = await self.runner.run(kubeconfig_path, ["api-resources"], execution_log, timeout=timeout)
+            if result["exit_code"] != 0:
+                raise KubectlCommandError(
+                    result["stderr"] or f"kubectl exited with code {result['exit_code']}",
+                    exit_code=result["exit_code"],
+                    stderr=result["stderr"],
+                )
+            return parse_api_resources(result["stdout"])
+
+        if self.discovery_cache is None or kubeconfig_path == current_inline_kubeconfig():
+            return await fetch()
+        return await self.discovery_cache.get(impersonation_cache_key(kubeconfig_path), fetch, max_age)
+
+    async def kubectl_explain(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、deployments、或 CRD 的复数名"),
+        field_path: Optional[str] = Field(None, description="字段路径，如 spec.containers 或 pod.spec.containers，为空表示资源本身"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlExplainOutput:
+        """查看资源字段的类型与说明"""
+        execution_log, start_ms = start_execution_log("kubectl_explain", cluster_id, self.enable_execution_log)
+        output = KubectlExplainOutput(cluster_id=cluster_id, resource=resource, execution_log=execution_log)
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource, output.kind, output.api_version = spec.resource, spec.kind, spec.api_version
+
+            segments = [segment for segment in (field_path or "").strip().split(".") if segment]
+            # 与 kubectl explain 一致，允许以资源名开头
+            if segments and segments[0].lower() in (spec.resource, spec.kind.lower(), *spec.short_names):
+                segments = segments[1:]
+            output.field_path = ".".join(segments) or None
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            document = await self.runner.run_json(
+                kubeconfig_path, ["get", "--raw", openapi_path(spec.group, spec.version)], execution_log, timeout=timeout,
+            )
+            root = openapi_kind_schema(document, spec.group, spec.version, spec.kind)
+            if root is None:
+                error = ValueError(f"no OpenAPI schema found for {spec.kind} ({spec.api_version})")
+                finish_execution_log(execution_log, start_ms, error, "explain")
+                output.error = ErrorModel(error_code="SchemaNotFound", error_message=str(error))
+                return output
+            try:
+                explained = explain_openapi_field(document, root, segments)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            output.type = explained["type"]
+            output.description = explained["description"]
+            output.required = explained["required"]
+            output.fields = [ExplainField(**field) for field in explained["fields"]]
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_explain failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_explain")
+            output.error = command_error_model(e, "ExplainFailed")
+            return output
+
+    async def kubectl_describe(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、deployments、svc"),
+        name: str = Field(..., description="资源名称"),
+        namespace: Optional[str] = Field(None, description="命名空间（集群级资源忽略该参数），为空时使用服务的默认命名空间"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlDescribeOutput:
+        """获取对象及其相关事件，合并为可读文本"""
+        execution_log, start_ms = start_execution_log("kubectl_describe", cluster_id, self.enable_execution_log)
+        output = KubectlDescribeOutput(
+            cluster_id=cluster_id, resource=resource, name=name, namespace=namespace, execution_log=execution_log,
+        )
+        try:
+            if not name:
+                error = ValueError("name is required for kubectl_describe")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings, default=self.default_namespace)
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            scope = ["-n", output.namespace] if spec.namespaced else []
+            obj = await self.runner.run_json(
+                kubeconfig_path, ["get", spec.kubectl_name, name, *scope, "-o", "json"], execution_log, timeout=timeout,
+            )
+            # 集群级资源（如 Node）的事件不在固定命名空间中
+            event_scope = scope if spec.namespaced else ["--all-namespaces"]
+            events = await self.runner.run_json(
+                kubeconfig_path,
+                ["get", "events", *event_scope,
+                 f"--field-selector=involvedObject.name={name},involvedObject.kind={spec.kind}", "-o", "json"],
+                execution_log,
+                timeout=timeout,
+            )
+            items = events.get("items", [])
+            output.event_count = len(items)
+            output.text = format_describe(spec, obj, items, datetime.now(timezone.utc))
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_describe failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_describe")
+            output.error = command_error_model(e, "DescribeResourceFailed")
+            return output
+
+    async def kubectl_top(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field("pods", description="资源类型：nodes 或 pods"),
+        namespace: Optional[str] = Field(None, description="Pod 所在命名空间，为空或 all 表示全部命名空间；查询节点时忽略"),
+        sort_by: Optional[str] = Field(None, description="排序字段：cpu 或 memory，按用量从高到低排序；为空时按名称排序"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlTopOutput:
+        """通过 metrics.k8s.io 查询节点/Pod 用量，排序后返回"""
+        execution_log, start_ms = start_execution_log("kubectl_top", cluster_id, self.enable_execution_log)
+        namespace = normalize_namespace(namespace)
+        output = KubectlTopOutput(
+            cluster_id=cluster_id, resource=resource, namespace=namespace, sort_by=sort_by,
+            execution_log=execution_log,
+        )
+        try:
+            top_resource = TOP_RESOURCES.get((resource or "").lower())
+            if top_resource is None:
+                error = ValueError(f"unsupported resource '{resource}', supported: nodes, pods")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if sort_by and sort_by not in TOP_SORT_FIELDS:
+                error = ValueError(f"unsupported sort_by '{sort_by}', supported: {', '.join(TOP_SORT_FIELDS)}")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            output.resource = top_resource
+            output.namespace = resolve_namespace(find_resource_spec(top_resource), namespace, execution_log.warnings)
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            path = METRICS_API_PATH
+            if output.namespace:
+                path += f"/namespaces/{output.namespace}"
+            try:
+                data = await self.runner.run_json(
+                    kubeconfig_path, ["get", "--raw", f"{path}/{top_resource}"], execution_log, timeout=timeout,
+                )
+            except KubectlCommandError as e:
+                if any(marker in (e.stderr or str(e)) for marker in METRICS_UNAVAILABLE_MARKERS):
+                    finish_execution_log(execution_log, start_ms, e, "kubectl_top")
+                    output.error = ErrorModel(
+                        error_code="MetricsServerUnavailable",
+                        error_message=(
+                            "metrics API (metrics.k8s.io) is not available in this cluster, "
+                            f"install metrics-server or check that it is running: {e}"
+                        ),
+                    )
+                    return output
+                raise
+
+            items = [summarize_usage_metrics(item) for item in data.get("items") or []]
+            if sort_by:
+                items.sort(key=lambda item: item[TOP_SORT_FIELDS[sort_by]], reverse=True)
+            else:
+                items.sort(key=lambda item: (item.get("namespace") or "", item.get("name") or ""))
+            output.items = items
+            output.count = len(items)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_top failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_top")
+            output.error = command_error_model(e, "GetMetricsFailed")
+            return output
+
+    async def kubectl_watch(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、deployments、svc"),
+        name: Optional[str] = Field(None, description="资源名称，为空表示监听全部对象"),
+        namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间（集群级资源忽略该参数）"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        label_selector: Optional[str] = Field(None, description="标签选择器，如 app=nginx；与 name 同时指定时以 name 为准"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(DEFAULT_WATCH_SECONDS, description="监听时长（秒），默认 30 秒"),
+    ) -> KubectlWatchOutput:
+        """在限定时长内监听资源变更，逐个推送事件并汇总返回"""
+        execution_log, start_ms = start_execution_log("kubectl_watch", cluster_id, self.enable_execution_log)
+        namespace = normalize_namespace(namespace)
+        duration = resolve_timeout(timeout_seconds, DEFAULT_WATCH_SECONDS, LONG_RUNNING_TIMEOUTS["watch"])
+        output = KubectlWatchOutput(
+            cluster_id=cluster_id, resource=resource, namespace=namespace, name=name, timeout_seconds=duration,
+            execution_log=execution_log,
+        )
+        try:
+            timeout = self.runner.resolve_timeout()
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+                if label_selector and not name:
+                    validate_label_selector(label_selector)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings)
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            # --raw watch 每行输出一个 {"type", "object"} 事件，timeoutSeconds 让 API Server 同时结束监听
+            path = list_api_path(spec, output.namespace, {
+                "watch": "true",
+                "timeoutSeconds": duration,
+                "fieldSelector": f"metadata.name={name}" if name else None,
+                "labelSelector": None if name else label_selector,
+            })
+
+            async def on_line(line: str):
+                if not line.strip():
+                    return
+                event = json.loads(line)
+                event_type = event.get("type")
+                obj = event.get("object") or {}
+                if event_type == "BOOKMARK":
+                    return
+                if event_type == "ERROR":
+                    raise KubectlCommandError(
+                        f"watch error: {obj.get('message') or obj}", stderr=f"({obj.get('reason')}): {obj.get('message')}"
+                    )
+                entry = {"type": event_type, **summarize_object(spec, obj, datetime.now(timezone.utc))}
+                entry["resource_version"] = (obj.get("metadata") or {}).get("resourceVersion")
+                output.event_count += 1
+                if len(output.events) < MAX_WATCH_EVENTS:
+                    output.events.append(entry)
+                else:
+                    output.truncated = True
+                await self._notify(ctx, f"{event_type} {spec.kind}/{entry.get('name')}")
+
+            stream = await self.runner.stream_lines(
+                kubeconfig_path, ["get", "--raw", path], execution_log, duration, on_line,
+            )
+            if stream["exit_code"] != 0:
+                if any(marker in stream["stderr"] for marker in WATCH_UNSUPPORTED_MARKERS):
+                    error = ValueError(f"resource {spec.resource} does not support watch: {stream['stderr']}")
+                    finish_execution_log(execution_log, start_ms, error, "kubectl_watch")
+                    output.error = ErrorModel(error_code="WatchNotSupported", error_message=str(error))
+                    return output
+                raise KubectlCommandError(
+                    stream["stderr"] or f"kubectl exited with code {stream['exit_code']}",
+                    exit_code=stream["exit_code"],
+                    stderr=stream["stderr"],
+                )
+            output.closed_reason = stream["reason"]
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_watch failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_watch")
+            output.error = command_error_model(e, "WatchFailed")
+            return output
+
+    @staticmethod
+    async def _notify(ctx: Context, message: str):
+        """向客户端推送 MCP 日志通知，客户端不支持时忽略"""
+        try:
+            await ctx.info(message)
+        except Exception as e:
+            logger.debug(f"Failed to send notification: {e}")
+
+    async def kubectl_logs(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        name: str = Field(..., description="Pod 名称"),
+        container: Optional[str] = Field(None, description="容器名称，Pod 只有一个容器时可为空"),
+        tail_lines: int = Field(DEFAULT_LOG_TAIL_LINES, description="读取最后多少行日志"),
+        previous: bool = Field(False, description="是否读取上一次运行（重启前）容器的日志"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlLogsOutput:
+        """读取单个容器日志，多容器 Pod 未指定容器时返回容器列表"""
+        execution_log, start_ms = start_execution_log("kubectl_logs", cluster_id, self.enable_execution_log)
+        tail_lines = min(tail_lines, MAX_LOG_TAIL_LINES) if tail_lines and tail_lines > 0 else DEFAULT_LOG_TAIL_LINES
+        output = KubectlLogsOutput(
+            cluster_id=cluster_id, namespace=namespace, pod=name, container=container, previous=previous,
+            tail_lines=tail_lines, execution_log=execution_log,
+        )
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            pod = await self.runner.run_json(
+                kubeconfig_path, ["get", "pods", name, "-n", namespace, "-o", "json"], execution_log, timeout=timeout,
+            )
+            container, error_model = _select_container(pod, name, container)
+            if error_model:
+                finish_execution_log(execution_log, start_ms, ValueError(error_model.error_message), "validate_params")
+                output.error = error_model
+                return output
+            output.container = container
+
+            args = ["logs", name, "-n", namespace, "-c", container, f"--tail={tail_lines}"]
+            if previous:
+                args.append("--previous")
+            result = await self.runner.run(kubeconfig_path, args, execution_log, timeout=timeout)
+            if result["exit_code"] != 0:
+                raise KubectlCommandError(
+                    result["stderr"] or f"kubectl exited with code {result['exit_code']}",
+                    exit_code=result["exit_code"],
+                    stderr=result["stderr"],
+                )
+            logs = result["stdout"]
+            encoded = logs.encode("utf-8")
+            if len(encoded) > MAX_LOG_BYTES:
+                # 保留末尾部分，并从第一个完整行开始
+                logs = encoded[-MAX_LOG_BYTES:].decode("utf-8", errors="ignore")
+                logs = logs[logs.find("\n") + 1:]
+                output.truncated = True
+            output.logs = logs
+            output.line_count = len(logs.splitlines())
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_logs failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_logs")
+            output.error = command_error_model(e, "GetLogsFailed")
+            return output
+
+    async def kubectl_logs_archive(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        label_selector: str = Field(..., description="Pod 标签选择器，如 app=web"),
+        since: Optional[str] = Field(None, description="仅收集该时长内的日志，如 30m、1h"),
+        tail_lines: int = Field(1000, description="每个容器最多收集的日志行数"),
+        max_pods: int = Field(20, description=f"最多收集的 Pod 数量，不超过 {MAX_LOG_ARCHIVE_PODS}"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> LogArchiveOutput:
+        """收集匹配 Pod 的所有容器日志并打包为 tar.gz，通过 MCP resource 暴露"""
+        execution_log, start_ms = start_execution_log("kubectl_logs_archive", cluster_id, self.enable_execution_log)
+        output = LogArchiveOutput(
+            cluster_id=cluster_id, namespace=namespace, label_selector=label_selector, execution_log=execution_log,
+        )
+        try:
+            # 归档在内存中构建，Pod 数量与每个容器的行数均需有上限
+            error = None
+            if not 0 < max_pods <= MAX_LOG_ARCHIVE_PODS:
+                error = ValueError(f"max_pods must be between 1 and {MAX_LOG_ARCHIVE_PODS}, got {max_pods}")
+            elif not 0 < tail_lines <= MAX_LOG_TAIL_LINES:
+                error = ValueError(f"tail_lines must be between 1 and {MAX_LOG_TAIL_LINES}, got {tail_lines}")
+            if error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            pod_list = await self.runner.run_json(
+                kubeconfig_path, ["get", "pods", "-n", namespace, "-l", label_selector, "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            pods = pod_list.get("items", [])[:max_pods]
+
+            targets = [
+                (pod["metadata"]["name"], container["name"])
+                for pod in pods
+                for container in (pod.get("spec") or {}).get("initContainers", []) + (pod.get("spec") or {}).get("containers", [])
+            ]
+            semaphore = asyncio.Semaphore(5)
+
+            async def fetch(pod_name: str, container: str) -> Dict[str, Any]:
+                args = ["logs", pod_name, "-n", namespace, "-c", container, "--timestamps", f"--tail={tail_lines}"]
+                if since:
+                    args.append(f"--since={since}")
+                async with semaphore:
+                    return await self.runner.run(kubeconfig_path, args, execution_log, timeout=timeout)
+
+            results = await asyncio.gather(*(fetch(pod, container) for pod, container in targets))
+
+            buffer = io.BytesIO()
+            with tarfile.open(fileobj=buffer, mode="w:gz") as tar:
+                for (pod_name, container), result in zip(targets, results):
+                    if result["exit_code"] != 0:
+                        output.failed.append(f"{pod_name}/{container}: {result['stderr']}")
+                        continue
+                    path = f"{pod_name}/{container}.log"
+                    self._add_tar_file(tar, path, result["stdout"].encode("utf-8"))
+                    output.files.append(path)
+                manifest = {
+                    "cluster_id": cluster_id,
+                    "namespace": namespace,
+                    "label_selector": label_selector,
+                    "since": since,
+                    "tail_lines": tail_lines,
+                    "collected_at": datetime.now(timezone.utc).isoformat().replace("+00:00", "Z"),
+                    "files": output.files,
+                    "failed": output.failed,
+                }
+                self._add_tar_file(tar, "manifest.json", json.dumps(manifest, indent=2).encode("utf-8"))
+
+            archive_id = uuid.uuid4().hex
+            data = buffer.getvalue()
+            self._log_archives[archive_id] = data
+            output.archive_id = archive_id
+            output.resource_uri = LOG_ARCHIVE_URI_TEMPLATE.format(archive_id=archive_id)
+            output.size_bytes = len(data)
+            output.expires_at = (
+                datetime.now(timezone.utc) + timedelta(seconds=LOG_ARCHIVE_TTL_SECONDS)
+            ).isoformat().replace("+00:00", "Z")
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_logs_archive failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_logs_archive")
+            output.error = ErrorModel(error_code="LogArchiveFailed", error_message=str(e))
+            return output
+
+    async def kubectl_export_bundle(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        workload_name: str = Field(..., description="工作负载名称"),
+        workload_type: str = Field("deployment", description="工作负载类型：deployment、statefulset、daemonset"),
+        include_secret_data: bool = Field(False, description="是否导出 Secret 的实际内容，默认仅保留键名"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单次 kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> ExportBundleOutput:
+        """导出工作负载及其引用的对象、Service 与 HPA"""
+        execution_log, start_ms = start_execution_log("kubectl_export_bundle", cluster_id, self.enable_execution_log)
+        output = ExportBundleOutput(
+            cluster_id=cluster_id, namespace=namespace, workload=f"{workload_type}/{workload_name}",
+            execution_log=execution_log,
+        )
+        try:
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+
+            async def get_json(*args: str) -> Dict[str, Any]:
+                return await self.runner.run_json(
+                    kubeconfig_path, ["get", *args, "-n", namespace, "-o", "json"], execution_log, timeout=timeout
+                )
+
+            workload = await get_json(workload_type, workload_name)
+            output.workload = f"{workload.get('kind')}/{workload_name}"
+            bundle: List[Dict[str, Any]] = [workload]
+
+            seen = set()
+            for ref in object_references(workload):
+                key = (ref["kind"], ref["name"])
+                if key in seen or (ref["kind"] == "ServiceAccount" and ref["name"] == "default"):
+                    continue
+                seen.add(key)
+                try:
+                    obj = await get_json(ref["kind"].lower(), ref["name"])
+                except KubectlCommandError as e:
+                    if "NotFound" in e.stderr or "not found" in e.stderr:
+                        output.missing.append(f"{ref['kind']}/{ref['name']}")
+                        continue
+                    raise
+                if obj.get("kind") == "Secret" and obj.get("type") == "kubernetes.io/service-account-token":
+                    continue
+                bundle.append(obj)
+
+            template = (workload.get("spec") or {}).get("template") or {}
+            template_labels = (template.get("metadata") or {}).get("labels") or {}
+            for service in (await get_json("services")).get("items", []):
+                if selector_matches((service.get("spec") or {}).get("selector"), template_labels):
+                    bundle.append(service)
+            try:
+                hpas = (await get_json("horizontalpodautoscalers")).get("items", [])
+            except KubectlCommandError as e:
+                logger.debug(f"Failed to list HPAs in {namespace}: {e}")
+                hpas = []
+            for hpa in hpas:
+                target = (hpa.get("spec") or {}).get("scaleTargetRef") or {}
+                if target.get("kind") == workload.get("kind") and target.get("name") == workload_name:
+                    bundle.append(hpa)
+
+            documents = []
+            for obj in sorted(bundle, key=lambda o: EXPORT_KIND_ORDER.index(o.get("kind"))
+                              if o.get("kind") in EXPORT_KIND_ORDER else len(EXPORT_KIND_ORDER)):
+                cleaned = clean_for_export(obj)
+                cleaned["metadata"].pop("namespace", None)
+                if cleaned.get("kind") == "Secret" and not include_secret_data:
+                    cleaned["data"] = {k: "" for k in (cleaned.get("data") or {})}
+                    cleaned.pop("stringData", None)
+                    output.warnings.append(
+                        f"Secret/{cleaned['metadata'].get('name')} 的值已置空，apply 前需要补充内容"
+                    )
+                documents.append(cleaned)
+                output.objects.append(f"{cleaned.get('kind')}/{cleaned['metadata'].get('name')}")
+            output.manifest = yaml.safe_dump_all(documents, sort_keys=False, allow_unicode=True)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_export_bundle failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_export_bundle")
+            output.error = ErrorModel(error_code="ExportBundleFailed", error_message=str(e))
+            return output
+
+    async def kubectl_apply(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        manifest: str = Field(..., description="YAML 或 JSON 格式的资源清单，支持以 --- 分隔的多文档"),
+        namespace: Optional[str] = Field(None, description="清单中未指定命名空间的对象所使用的命名空间，为空时使用 kubeconfig 默认命名空间"),
+        dry_run: bool = Field(False, description="是否仅执行服务端 dry-run（校验但不持久化）"),
+        force_conflicts: bool = Field(False, description="字段由其他 field manager 管理时是否强制接管，默认冲突时失败"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单个对象 apply 的超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlApplyOutput:
+        """以 server-side apply 逐个应用清单中的对象"""
+        execution_log, start_ms = start_execution_log("kubectl_apply", cluster_id, self.enable_execution_log)
+        output = KubectlApplyOutput(cluster_id=cluster_id, dry_run=dry_run, execution_log=execution_log)
+        try:
+            if not self.allow_write:
+                error = _read_only_error("kubectl_apply")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+            try:
+                objects = parse_manifest(manifest)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            args = ["apply", "--server-side", f"--field-manager={APPLY_FIELD_MANAGER}", "-f", "-", "-o", "json"]
+            if namespace:
+                args += ["-n", namespace]
+            if dry_run:
+                args.append("--dry-run=server")
+            if force_conflicts:
+                args.append("--force-conflicts")
+
+            first_error: Optional[ErrorModel] = None
+            for obj in objects:
+                metadata = obj.get("metadata") or {}
+                result = {
+                    "kind": obj.get("kind"),
+                    "name": metadata.get("name"),
+                    "namespace": metadata.get("namespace") or namespace,
+                }
+                try:
+                    applied = await self.runner.run_json(
+                        kubeconfig_path, args, execution_log, timeout=timeout, stdin=json.dumps(obj),
+                    )
+                    result["namespace"] = (applied.get("metadata") or {}).get("namespace")
+                    result["status"] = "applied"
+                    if obj.get("kind") == "CustomResourceDefinition" and not dry_run and self.discovery_cache:
+                        # 新的资源类型需立即可被解析
+                        self.discovery_cache.invalidate(kubeconfig_path)
+                    output.applied += 1
+                except KubectlCommandError as e:
+                    error = command_error_model(e, "ApplyFailed")
+                    first_error = first_error or error
+                    result["status"] = "failed"
+                    result["error"] = error.error_message
+                    output.failed += 1
+                output.results.append(result)
+
+            if first_error:
+                output.error = ErrorModel(
+                    error_code=first_error.error_code,
+                    error_message=f"{output.failed} of {len(objects)} objects failed to apply: {first_error.error_message}",
+                )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_apply failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_apply")
+            output.error = command_error_model(e, "ApplyFailed")
+            return output
+
+    async def kubectl_diff(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        manifest: str = Field(..., description="YAML 或 JSON 格式的资源清单，支持以 --- 分隔的多文档"),
+        namespace: Optional[str] = Field(None, description="清单中未指定命名空间的对象所使用的命名空间，为空时使用 kubeconfig 默认命名空间"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="单个对象查询的超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlDiffOutput:
+        """逐个对象对比清单与实际状态"""
+        execution_log, start_ms = start_execution_log("kubectl_diff", cluster_id, self.enable_execution_log)
+        output = KubectlDiffOutput(cluster_id=cluster_id, execution_log=execution_log)
+        try:
+            try:
+                objects = parse_manifest(manifest)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            args = ["get", "-f", "-", "--ignore-not-found", "-o", "json"]
+            if namespace:
+                args += ["-n", namespace]
+
+            first_error: Optional[ErrorModel] = None
+            diffs: List[str] = []
+            for obj in objects:
+                metadata = obj.get("metadata") or {}
+                result = {
+                    "kind": obj.get("kind"),
+                    "name": metadata.get("name"),
+                    "namespace": metadata.get("namespace") or namespace,
+                }
+                try:
+                    live = await self.runner.run_json(
+                        kubeconfig_path, args, execution_log, timeout=timeout, stdin=json.dumps(obj),
+                    )
+                    if live.get("kind") == "List":
+                        live = next(iter(live.get("items") or []), {})
+                    if live:
+                        result["namespace"] = (live.get("metadata") or {}).get("namespace")
+                    result["status"], result["diff"] = manifest_diff(live or None, obj)
+                    if result["status"] != "unchanged":
+                        output.changed += 1
+                        diffs.append(result["diff"])
+                except KubectlCommandError as e:
+                    error = command_error_model(e, "DiffFailed")
+                    first_error = first_error or error
+                    result["status"] = "failed"
+                    result["error"] = error.error_message
+                output.results.append(result)
+            output.diff = "".join(diffs)
+
+            if first_error:
+                failed = sum(1 for r in output.results if r["status"] == "failed")
+                output.error = ErrorModel(
+                    error_code=first_error.error_code,
+                    error_message=f"{failed} of {len(objects)} objects could not be compared: {first_error.error_message}",
+                )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_diff failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_diff")
+            output.error = command_error_model(e, "DiffFailed")
+            return output
+
+    async def kubectl_rollout(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        name: str = Field(..., description="Deployment 名称"),
+        namespace: str = Field(..., description="命名空间"),
+        action: str = Field("status", description="操作：status（查询发布状态）、restart（滚动重启）或 wait（等待发布完成）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时；action=wait 时为最长等待时间"),
+    ) -> KubectlRolloutOutput:
+        """查询 Deployment 发布状态、触发滚动重启或等待发布完成"""
+        execution_log, start_ms = start_execution_log("kubectl_rollout", cluster_id, self.enable_execution_log)
+        output = KubectlRolloutOutput(
+            cluster_id=cluster_id, action=action, name=name, namespace=namespace, execution_log=execution_log,
+        )
+        try:
+            if action not in ROLLOUT_ACTIONS:
+                error = ValueError(f"action must be one of {', '.join(ROLLOUT_ACTIONS)}")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if action == "restart" and not self.allow_write:
+                error = _read_only_error("kubectl_rollout restart")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(None if action == "wait" else timeout_seconds)
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            if action == "wait":
+                duration = resolve_timeout(timeout_seconds, DEFAULT_ROLLOUT_WAIT_SECONDS, LONG_RUNNING_TIMEOUTS["rollout"])
+                deployment, output.timed_out, output.waited_seconds = await self._wait_for_rollout(
+                    ctx, kubeconfig_path, name, namespace, duration, execution_log, timeout
+                )
+            elif action == "restart":
+                output.restarted_at = datetime.now(timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ")
+                patch = {"spec": {"template": {"metadata": {"annotations": {
+                    RESTARTED_AT_ANNOTATION: output.restarted_at,
+                }}}}}
+                deployment = await self.runner.run_json(
+                    kubeconfig_path,
+                    ["patch", "deployment", name, "-n", namespace, "--type=merge", "-p", json.dumps(patch), "-o", "json"],
+                    execution_log, timeout=timeout,
+                )
+            else:
+                deployment = await self.runner.run_json(
+                    kubeconfig_path, ["get", "deployment", name, "-n", namespace, "-o", "json"], execution_log,
+                    timeout=timeout,
+                )
+            for key, value in deployment_rollout_status(deployment).items():
+                setattr(output, key, value)
+            if action == "wait" and output.state != "complete":
+                output.blocking_condition = rollout_blocking_condition(deployment) or output.message
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_rollout failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_rollout")
+            output.error = command_error_model(e, "RolloutFailed")
+            return output
+
+    async def _wait_for_rollout(
+        self,
+        ctx: Context,
+        kubeconfig_path: str,
+        name: str,
+        namespace: str,
+        duration: int,
+        execution_log: ExecutionLog,
+        timeout: Optional[int],
+    ) -> Tuple[Dict[str, Any], bool, float]:
+        """监听 Deployment 直至发布完成、卡住或超过 duration 秒，返回 (最新的 Deployment, 是否超时, 等待秒数)"""
+        start = time.monotonic()
+        spec = find_resource_spec("deployments")
+        args = ["get", "deployment", name, "-n", namespace, "-o", "json"]
+        deployment = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+        while True:
+            state = deployment_rollout_status(deployment)
+            remaining = duration - (time.monotonic() - start)
+            if state["state"] != "progressing" or remaining <= 0:
+                return deployment, state["state"] == "progressing", round(time.monotonic() - start, 3)
+
+            latest = {"deployment": deployment, "message": state["message"], "expired": False}
+
+            async def on_line(line: str) -> bool:
+                if not line.strip():
+                    return False
+                event = json.loads(line)
+                obj = event.get("object") or {}
+                if event.get("type") == "ERROR":
+                    # resourceVersion 过期（410 Gone）时重新读取后继续监听
+                    if obj.get("code") == 410:
+                        latest["expired"] = True
+                        return True
+                    raise KubectlCommandError(
+                        f"watch error: {obj.get('message') or obj}", stderr=f"({obj.get('reason')}): {obj.get('message')}"
+                    )
+                if event.get("type") == "DELETED":
+                    raise KubectlCommandError(
+                        f'deployment "{name}" was deleted while waiting for the rollout', stderr="(NotFound)"
+                    )
+                if event.get("type") not in ("ADDED", "MODIFIED"):
+                    return False
+                latest["deployment"] = obj
+                status = deployment_rollout_status(obj)
+                if status["message"] != latest["message"]:
+                    latest["message"] = status["message"]
+                    await self._notify(ctx, f"Deployment {namespace}/{name}: {status['message']}")
+                return status["state"] != "progressing"
+
+            path = list_api_path(spec, namespace, {
+                "watch": "true",
+                "fieldSelector": f"metadata.name={name}",
+                "resourceVersion": (deployment.get("metadata") or {}).get("resourceVersion"),
+                "timeoutSeconds": max(math.ceil(remaining), 1),
+            })
+            stream = await self.runner.stream_lines(
+                kubeconfig_path, ["get", "--raw", path], execution_log, remaining, on_line,
+            )
+            if stream["exit_code"] != 0:
+                raise KubectlCommandError(
+                    stream["stderr"] or f"kubectl exited with code {stream['exit_code']}",
+                    exit_code=stream["exit_code"],
+                    stderr=stream["stderr"],
+                )
+            deployment = latest["deployment"]
+            if stream["reason"] == "timeout":
+                return deployment, True, round(time.monotonic() - start, 3)
+            if stream["reason"] == "closed" or latest["expired"]:
+                # API Server 关闭了监听或 resourceVersion 已过期，重新读取当前状态后继续
+                deployment = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+
+    async def kubectl_node(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        node: str = Field(..., description="节点名称"),
+        action: str = Field(..., description="操作：cordon（标记不可调度）、uncordon（恢复调度）或 drain（标记不可调度并驱逐 Pod）"),
+        force: bool = Field(False, description="drain 时是否驱逐未受控制器管理的 Pod（驱逐后不会被重建）"),
+        delete_emptydir_data: bool = Field(False, description="drain 时是否驱逐使用 emptyDir 的 Pod（emptyDir 中的数据会丢失）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="整体超时（秒），drain 默认 300 秒，其他操作默认使用服务端 kubectl 超时"),
+    ) -> KubectlNodeOutput:
+        """cordon/uncordon/drain 节点"""
+        execution_log, start_ms = start_execution_log("kubectl_node", cluster_id, self.enable_execution_log)
+        output = KubectlNodeOutput(cluster_id=cluster_id, node=node, action=action, execution_log=execution_log)
+        try:
+            if action not in NODE_ACTIONS:
+                error = ValueError(f"action must be one of {', '.join(NODE_ACTIONS)}")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if not self.allow_write:
+                error = _read_only_error(f"kubectl_node {action}")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds, "drain" if action == "drain" else None)
+            deadline = time.monotonic() + timeout
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            patch = {"spec": {"unschedulable": action != "uncordon"}}
+            patched = await self.runner.run_json(
+                kubeconfig_path, ["patch", "node", node, "--type=merge", "-p", json.dumps(patch), "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            output.unschedulable = bool((patched.get("spec") or {}).get("unschedulable"))
+            if action == "drain":
+                await self._drain_node(
+                    kubeconfig_path, node, force, delete_emptydir_data, deadline, execution_log, output,
+                )
+                if output.failed:
+                    output.error = ErrorModel(
+                        error_code="DrainIncomplete",
+                        error_message=f"{len(output.failed)} pods on node {node} could not be evicted: "
+                                      f"{output.failed[0]['namespace']}/{output.failed[0]['name']}: "
+                                      f"{output.failed[0]['reason']}",
+                    )
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_node failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_node")
+            output.error = command_error_model(e, "NodeMaintenanceFailed")
+            return output
+
+    async def _drain_node(
+        self,
+        kubeconfig_path: str,
+        node: str,
+        force: bool,
+        delete_emptydir_data: bool,
+        deadline: float,
+        execution_log: ExecutionLog,
+        output: KubectlNodeOutput,
+    ):
+        """驱逐节点上的 Pod，被 PodDisruptionBudget 阻止的驱逐在超时前重试"""
+        remaining = max(int(deadline - time.monotonic()), 1)
+        pods = await self.runner.run_json(
+            kubeconfig_path,
+            ["get", "pods", "--all-namespaces", f"--field-selector=spec.nodeName={node}", "-o", "json"],
+            execution_log, timeout=remaining,
+        )
+        pending = []
+        for pod in pods.get("items") or []:
+            metadata = pod.get("metadata") or {}
+            entry = {"namespace": metadata.get("namespace"), "name": metadata.get("name")}
+            reason = drain_skip_reason(pod, force, delete_emptydir_data)
+            if reason:
+                output.skipped.append({**entry, "reason": reason})
+            else:
+                pending.append(entry)
+
+        while pending:
+            blocked = []
+            for entry in pending:
+                remaining = int(deadline - time.monotonic())
+                if remaining <= 0:
+                    blocked.append({**entry, "reason": "timed out before the pod could be evicted"})
+                    continue
+                eviction = {
+                    "apiVersion": "policy/v1",
+                    "kind": "Eviction",
+                    "metadata": {"name": entry["name"], "namespace": entry["namespace"]},
+                }
+                result = await self.runner.run(
+                    kubeconfig_path,
+                    ["create", "--raw", f"/api/v1/namespaces/{entry['namespace']}/pods/{entry['name']}/eviction",
+                     "-f", "-"],
+                    execution_log, timeout=min(self.runner.resolve_timeout(), remaining), stdin=json.dumps(eviction),
+                )
+                stderr = result["stderr"]
+                if result["exit_code"] == 0 or "(NotFound)" in stderr:
+                    output.evicted.append(entry)
+                elif "(TooManyRequests)" in stderr:
+                    # 驱逐会违反 PodDisruptionBudget，稍后重试
+                    blocked.append({**entry, "reason": stderr.splitlines()[0]})
+                else:
+                    output.failed.append({**entry, "reason": (stderr or f"exit code {result['exit_code']}").splitlines()[0]})
+            if not blocked:
+                return
+            if time.monotonic() + DRAIN_RETRY_INTERVAL >= deadline:
+                output.failed.extend(blocked)
+                return
+            await asyncio.sleep(DRAIN_RETRY_INTERVAL)
+            pending = [{"namespace": e["namespace"], "name": e["name"]} for e in blocked]
+
+    async def kubectl_delete(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、jobs、或 CRD 的复数名"),
+        name: str = Field(..., description="资源名称"),
+        confirm: str = Field(..., description="确认删除，必须与 name 完全一致"),
+        namespace: Optional[str] = Field(None, description="命名空间（集群级资源忽略该参数），为空时使用服务的默认命名空间"),
+        dry_run: bool = Field(False, description="是否仅执行服务端 dry-run（预览删除，不实际删除）"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlDeleteOutput:
+        """删除单个资源"""
+        execution_log, start_ms = start_execution_log("kubectl_delete", cluster_id, self.enable_execution_log)
+        output = KubectlDeleteOutput(
+            cluster_id=cluster_id, resource=resource, name=name, namespace=namespace, dry_run=dry_run,
+            execution_log=execution_log,
+        )
+        try:
+            if not self.allow_write:
+                error = _read_only_error("kubectl_delete")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+            if not name or confirm != name:
+                error = ValueError(f"confirm must equal the resource name '{name}' to delete it")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings, default=self.default_namespace)
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            scope = ["-n", output.namespace] if spec.namespaced else []
+            args = ["delete", spec.kubectl_name, name, *scope, "--wait=false"]
+            if dry_run:
+                args.append("--dry-run=server")
+            result = await self.runner.run(kubeconfig_path, args, execution_log, timeout=timeout)
+            if result["exit_code"] != 0:
+                raise KubectlCommandError(
+                    result["stderr"] or f"kubectl exited with code {result['exit_code']}",
+                    exit_code=result["exit_code"],
+                    stderr=result["stderr"],
+                )
+            target = f"{spec.kind}/{name}" + (f" in namespace {output.namespace}" if spec.namespaced else "")
+            if dry_run:
+                output.status = "dry_run"
+                output.message = f"would delete {target}"
+                finish_execution_log(execution_log, start_ms)
+                return output
+
+            # 不等待删除完成，对象仍存在时说明 finalizer 尚未处理完毕
+            remaining = await self.runner.run_json(
+                kubeconfig_path, ["get", spec.kubectl_name, name, *scope, "--ignore-not-found", "-o", "json"],
+                execution_log, timeout=timeout,
+            )
+            metadata = remaining.get("metadata") or {}
+            if metadata.get("deletionTimestamp"):
+                output.status = "terminating"
+                output.deletion_timestamp = metadata["deletionTimestamp"]
+                output.finalizers = list(metadata.get("finalizers") or [])
+                output.message = f"{target} is terminating, waiting for finalizers: {', '.join(output.finalizers)}"
+            else:
+                output.status = "deleted"
+                output.deletion_timestamp = datetime.now(timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ")
+                output.message = f"deleted {target}"
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_delete failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_delete")
+            output.error = command_error_model(e, "DeleteFailed")
+            return output
+
+    async def kubectl_metadata(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 pods、nodes、deployments、或 CRD 的复数名"),
+        name: str = Field(..., description="资源名称"),
+        action: str = Field(..., description="操作类型：label（修改标签）或 annotate（修改注解）"),
+        changes: List[str] = Field(..., description="变更列表：key=value 设置，key- 删除，如 [\"env=prod\", \"tier-\"]"),
+        overwrite: bool = Field(False, description="是否允许覆盖已有的不同值，默认 false"),
+        namespace: Optional[str] = Field(None, description="命名空间（集群级资源忽略该参数），为空时使用服务的默认命名空间"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlMetadataOutput:
+        """修改资源的标签或注解"""
+        execution_log, start_ms = start_execution_log("kubectl_metadata", cluster_id, self.enable_execution_log)
+        output = KubectlMetadataOutput(
+            cluster_id=cluster_id, resource=resource, name=name, namespace=namespace, action=action,
+            execution_log=execution_log,
+        )
+        try:
+            if not self.allow_write:
+                error = _read_only_error("kubectl_metadata")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+            fields = {"label": ("labels", "label"), "annotate": ("annotations", "annotation")}
+            try:
+                if action not in fields:
+                    raise ValueError(f"invalid action '{action}', must be one of: label, annotate")
+                if not name:
+                    raise ValueError("name is required")
+                field, kind = fields[action]
+                parsed = parse_metadata_changes(changes, kind)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings, default=self.default_namespace)
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            scope = ["-n", output.namespace] if spec.namespaced else []
+            obj = await self.runner.run_json(
+                kubeconfig_path, ["get", spec.kubectl_name, name, *scope, "-o", "json"], execution_log, timeout=timeout,
+            )
+            metadata = obj.get("metadata") or {}
+            try:
+                patch, output.warnings = metadata_merge_patch(metadata.get(field), parsed, overwrite, kind)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            if patch:
+                # 携带 resourceVersion，读取后对象被修改时由 API Server 返回冲突，避免覆盖并发修改
+                body = {"metadata": {"resourceVersion": metadata.get("resourceVersion"), field: patch}}
+                obj = await self.runner.run_json(
+                    kubeconfig_path,
+                    ["patch", spec.kubectl_name, name, *scope, "--type=merge", "-p", json.dumps(body), "-o", "json"],
+                    execution_log, timeout=timeout,
+                )
+                metadata = obj.get("metadata") or {}
+            output.changed = list(patch)
+            output.labels = dict(metadata.get("labels") or {})
+            output.annotations = dict(metadata.get("annotations") or {})
+            execution_log.warnings.extend(output.warnings)
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_metadata failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_metadata")
+            output.error = command_error_model(e, "MetadataUpdateFailed")
+            return output
+
+    async def kubectl_patch(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        resource: str = Field(..., description="资源类型，如 deployments、services、或 CRD 的复数名"),
+        name: str = Field(..., description="资源名称"),
+        patch: str = Field(..., description="patch 内容（JSON 字符串），格式取决于 patch_type"),
+        patch_type: str = Field("strategic", description="patch 类型：strategic（默认）、merge 或 json"),
+        namespace: Optional[str] = Field(None, description="命名空间（集群级资源忽略该参数），为空时使用服务的默认命名空间"),
+        dry_run: bool = Field(False, description="是否仅执行服务端 dry-run（校验但不实际修改）"),
+        api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源（如 CRD）"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description="kubectl 调用超时（秒），默认使用服务端 kubectl 超时"),
+    ) -> KubectlPatchOutput:
+        """以 patch 方式修改单个资源"""
+        execution_log, start_ms = start_execution_log("kubectl_patch", cluster_id, self.enable_execution_log)
+        output = KubectlPatchOutput(
+            cluster_id=cluster_id, resource=resource, name=name, namespace=namespace, patch_type=patch_type,
+            dry_run=dry_run, execution_log=execution_log,
+        )
+        try:
+            if not self.allow_write:
+                error = _read_only_error("kubectl_patch")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+            try:
+                if not name:
+                    raise ValueError("name is required")
+                parsed = parse_patch(patch_type, patch)
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds)
+            try:
+                spec, kubeconfig_path = await self._resolve_resource_spec(
+                    ctx, cluster_id, resource, api_version, context, execution_log, timeout
+                )
+            except ValueError as error:
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+            if spec is None:
+                error = _unsupported_resource_error(resource, api_version)
+                finish_execution_log(execution_log, start_ms, error, "resolve_resource")
+                output.error = ErrorModel(error_code="UnsupportedResource", error_message=str(error))
+                return output
+            output.resource = spec.resource
+            output.namespace = resolve_namespace(spec, namespace, execution_log.warnings, default=self.default_namespace)
+            # API Server 仅对内置类型支持 strategic merge patch，自定义资源直接返回 415
+            if patch_type == "strategic" and spec.discovered:
+                error = ValueError(
+                    f"strategic merge patch is not supported for custom resource {spec.resource}, "
+                    f"use patch_type=merge or json"
+                )
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            kubeconfig_path = kubeconfig_path or self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            scope = ["-n", output.namespace] if spec.namespaced else []
+            current = None
+            if not dry_run:
+                current = await self.runner.run_json(
+                    kubeconfig_path, ["get", spec.kubectl_name, name, *scope, "-o", "json"], execution_log,
+                    timeout=timeout,
+                )
+            args = ["patch", spec.kubectl_name, name, *scope, f"--type={patch_type}", "-p", json.dumps(parsed),
+                    "-o", "json"]
+            if dry_run:
+                args.append("--dry-run=server")
+            obj = await self.runner.run_json(kubeconfig_path, args, execution_log, timeout=timeout)
+            if current is not None:
+                output.changed = (
+                    (obj.get("metadata") or {}).get("resourceVersion")
+                    != (current.get("metadata") or {}).get("resourceVersion")
+                )
+            output.object = redact_secret_values(obj) if spec.kind == "Secret" else obj
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_patch failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_patch")
+            output.error = command_error_model(e, "PatchFailed")
+            return output
+
+    async def kubectl_exec(
+        self,
+        ctx: Context,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="命名空间"),
+        name: str = Field(..., description="Pod 名称"),
+        command: List[str] = Field(..., description="要执行的命令及参数，如 [\"ls\", \"-l\", \"/data\"]"),
+        container: Optional[str] = Field(None, description="容器名称，Pod 只有一个容器时可为空"),
+        context: Optional[str] = Field(None, description="kubeconfig context 名称，为空时使用 current-context"),
+        timeout_seconds: Optional[int] = Field(None, description=f"命令执行超时（秒），默认 {LONG_RUNNING_TIMEOUTS['exec']} 秒"),
+    ) -> KubectlExecOutput:
+        """在容器内执行非交互命令"""
+        execution_log, start_ms = start_execution_log("kubectl_exec", cluster_id, self.enable_execution_log)
+        output = KubectlExecOutput(
+            cluster_id=cluster_id, namespace=namespace, pod=name, container=container,
+            command=list(command or []), execution_log=execution_log,
+        )
+        try:
+            if not self.allow_write:
+                error = _read_only_error("kubectl_exec")
+                finish_execution_log(execution_log, start_ms, error, "read_only")
+                output.error = ErrorModel(error_code="WriteNotAllowed", error_message=str(error))
+                return output
+            if not command or not all(isinstance(arg, str) for arg in command) or not command[0]:
+                error = ValueError("command must be a non-empty array of strings")
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="InvalidParameter", error_message=str(error))
+                return output
+
+            timeout = self.runner.resolve_timeout(timeout_seconds, "exec")
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            pod = await self.runner.run_json(
+                kubeconfig_path, ["get", "pods", name, "-n", namespace, "-o", "json"], execution_log,
+                timeout=self.runner.resolve_timeout(),
+            )
+            container, error_model = _select_container(pod, name, container)
+            if error_model:
+                finish_execution_log(execution_log, start_ms, ValueError(error_model.error_message), "validate_params")
+                output.error = error_model
+                return output
+            output.container = container
+            status = pod.get("status") or {}
+            state = next(
+                (cs.get("state") or {} for cs in (status.get("containerStatuses") or [])
+                 + (status.get("initContainerStatuses") or []) + (status.get("ephemeralContainerStatuses") or [])
+                 if cs.get("name") == container),
+                {},
+            )
+            if "running" not in state:
+                error = ValueError(
+                    f"container {container} in pod {name} is not running "
+                    f"(pod phase {status.get('phase') or 'Unknown'}, state {', '.join(state) or 'unknown'})"
+                )
+                finish_execution_log(execution_log, start_ms, error, "validate_params")
+                output.error = ErrorModel(error_code="ContainerNotRunning", error_message=str(error))
+                return output
+
+            result = await self.runner.run(
+                kubeconfig_path, ["exec", name, "-n", namespace, "-c", container, "--", *command], execution_log,
+                timeout=timeout,
+            )
+            exit_code, stderr = split_exec_exit_code(result["stderr"])
+            if result["exit_code"] != 0 and exit_code is None:
+                # 失败发生在 kubectl/API Server 一侧（或超时），而非容器内命令
+                raise KubectlCommandError(
+                    result["stderr"] or f"kubectl exited with code {result['exit_code']}",
+                    exit_code=result["exit_code"],
+                    stderr=result["stderr"],
+                )
+            output.exit_code = exit_code if exit_code is not None else 0
+            combined = result["stdout"]
+            if stderr:
+                combined += ("" if not combined or combined.endswith("\n") else "\n") + stderr
+            encoded = combined.encode("utf-8")
+            if len(encoded) > MAX_EXEC_OUTPUT_BYTES:
+                combined = encoded[:MAX_EXEC_OUTPUT_BYTES].decode("utf-8", errors="ignore")
+                output.truncated = True
+            output.output = combined
+            execution_log.messages.append(f"command exited with code {output.exit_code}")
+            finish_execution_log(execution_log, start_ms)
+            return output
+        except Exception as e:
+            logger.error(f"kubectl_exec failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "kubectl_exec")
+            output.error = command_error_model(e, "ExecFailed")
+            return output
+
+    @staticmethod
+    def _add_tar_file(tar: tarfile.TarFile, path: str, content: bytes):
+        info = tarfile.TarInfo(name=path)
+        info.size = len(content)
+        info.mtime = int(datetime.now(timezone.utc).timestamp())
+        tar.addfile(info, io.BytesIO(content))
+
+    def _object_reader(self, spec: ResourceSpec):
+        """构造指定资源类型的 MCP 资源读取函数"""
+
+        async def read(cluster_id: str, namespace: str, name: str, ctx: Context, context: Optional[str] = None) -> str:
+            return await self.read_object(ctx, spec, cluster_id, namespace, name, context)
+
+        return read
+
+    async def read_object(
+        self, ctx: Context, spec: ResourceSpec, cluster_id: str, namespace: str, name: str, context: Optional[str] = None,
+    ) -> str:
+        """读取集群对象的当前状态（JSON）"""
+        execution_log, start_ms = start_execution_log("read_resource", cluster_id, self.enable_execution_log)
+        try:
+            kubeconfig_path = self.runner.resolve_kubeconfig(ctx, cluster_id, execution_log, context)
+            obj = await self.runner.run_json(
+                kubeconfig_path, ["get", spec.kubectl_name, name, "-n", namespace, "-o", "json"], execution_log,
+                timeout=self.runner.resolve_timeout(),
+            )
+            finish_execution_log(execution_log, start_ms)
+            return json.dumps(trim_object(obj), ensure_ascii=False, indent=2)
+        except Exception as e:
+            logger.error(f"read {spec.resource} {namespace}/{name} failed: {e}")
+            finish_execution_log(execution_log, start_ms, e, "read_resource")
+            raise
+
+    def read_log_archive(self, archive_id: str) -> bytes:
+        """读取日志归档内容（gzip 压缩的 tar）"""
+        data = self._log_archives.get(archive_id)
+        if data is None:
+            raise ValueError(f"log archive {archive_id} not found or expired")
+        return data
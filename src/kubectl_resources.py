@@ -0,0 +1,696 @@
+"""kubectl_get 支持的资源类型注册表及各类型的摘要字段提取（纯函数，便于单元测试）。"""
+
+from dataclasses import dataclass, field
+from urllib.parse import quote, urlencode
+from datetime import datetime
+from typing import Dict, Any, Optional, List, Callable, Tuple, Union
+
+from kubectl_helpers import (
+    event_time,
+    format_age,
+    format_cell,
+    format_table,
+    jsonpath_values,
+    parse_field_selector,
+    parse_k8s_time,
+    parse_quantity,
+    pod_problem,
+    pod_restart_count,
+)
+
+
+def _summarize_pod(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    statuses = status.get("containerStatuses") or []
+    ready = sum(1 for cs in statuses if cs.get("ready"))
+    return {
+        "ready": f"{ready}/{len(spec.get('containers') or [])}",
+        "status": pod_problem(obj) or status.get("phase"),
+        "restarts": pod_restart_count(obj),
+        "node": spec.get("nodeName"),
+        "pod_ip": status.get("podIP"),
+    }
+
+
+def _summarize_service(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    ingress = ((obj.get("status") or {}).get("loadBalancer") or {}).get("ingress") or []
+    external = [i.get("ip") or i.get("hostname") for i in ingress] or spec.get("externalIPs") or []
+    ports = []
+    for port in spec.get("ports") or []:
+        text = f"{port.get('port')}"
+        if port.get("nodePort"):
+            text += f":{port['nodePort']}"
+        ports.append(f"{text}/{port.get('protocol', 'TCP')}")
+    return {
+        "type": spec.get("type"),
+        "cluster_ip": spec.get("clusterIP"),
+        "external_ip": external,
+        "ports": ports,
+    }
+
+
+def _summarize_deployment(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    return {
+        "ready": f"{status.get('readyReplicas', 0)}/{spec.get('replicas', 1)}",
+        "up_to_date": status.get("updatedReplicas", 0),
+        "available": status.get("availableReplicas", 0),
+    }
+
+
+def _summarize_statefulset(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    return {
+        "ready": f"{status.get('readyReplicas', 0)}/{spec.get('replicas', 1)}",
+        "service_name": spec.get("serviceName"),
+    }
+
+
+def _summarize_daemonset(obj: Dict[str, Any]) -> Dict[str, Any]:
+    status = obj.get("status") or {}
+    template_spec = (((obj.get("spec") or {}).get("template") or {}).get("spec")) or {}
+    return {
+        "desired": status.get("desiredNumberScheduled", 0),
+        "current": status.get("currentNumberScheduled", 0),
+        "ready": status.get("numberReady", 0),
+        "up_to_date": status.get("updatedNumberScheduled", 0),
+        "available": status.get("numberAvailable", 0),
+        "node_selector": template_spec.get("nodeSelector") or {},
+    }
+
+
+def _summarize_replicaset(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    owner = next((o for o in (obj.get("metadata") or {}).get("ownerReferences") or [] if o.get("controller")), None)
+    return {
+        "desired": spec.get("replicas", 1),
+        "current": status.get("replicas", 0),
+        "ready": status.get("readyReplicas", 0),
+        "owner": f"{owner.get('kind')}/{owner.get('name')}" if owner else None,
+    }
+
+
+def _job_status(obj: Dict[str, Any]) -> str:
+    """Job 状态：Complete、Failed、Suspended 或 Running（与 kubectl 1.28+ 的 STATUS 列一致）"""
+    conditions = {c.get("type"): c.get("status") for c in (obj.get("status") or {}).get("conditions") or []}
+    if conditions.get("Complete") == "True":
+        return "Complete"
+    if conditions.get("Failed") == "True":
+        return "Failed"
+    if conditions.get("Suspended") == "True" or (obj.get("spec") or {}).get("suspend"):
+        return "Suspended"
+    return "Running"
+
+
+def _summarize_job(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    started = parse_k8s_time(status.get("startTime"))
+    completed = parse_k8s_time(status.get("completionTime"))
+    failed_condition = next(
+        (c for c in status.get("conditions") or [] if c.get("type") == "Failed" and c.get("status") == "True"), {},
+    )
+    return {
+        "status": _job_status(obj),
+        "completions": f"{status.get('succeeded', 0)}/{spec.get('completions', 1)}",
+        "active": status.get("active", 0),
+        "failed": status.get("failed", 0),
+        "duration": format_age(completed - started) if started and completed else None,
+        "reason": failed_condition.get("reason"),
+    }
+
+
+def _summarize_cronjob(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    return {
+        "schedule": spec.get("schedule"),
+        "timezone": spec.get("timeZone"),
+        "suspend": bool(spec.get("suspend")),
+        "active": len(status.get("active") or []),
+        "last_schedule": status.get("lastScheduleTime"),
+        "last_successful": status.get("lastSuccessfulTime"),
+    }
+
+
+def _summarize_ingress(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    ingress = ((obj.get("status") or {}).get("loadBalancer") or {}).get("ingress") or []
+    return {
+        "class": spec.get("ingressClassName"),
+        "hosts": [rule.get("host") or "*" for rule in spec.get("rules") or []],
+        "address": [i.get("ip") or i.get("hostname") for i in ingress],
+        "tls": bool(spec.get("tls")),
+    }
+
+
+def _hpa_metric_value(value: Dict[str, Any]) -> Optional[str]:
+    """HPA 指标的目标值或当前值：利用率显示为百分比，其余为数量"""
+    if value.get("averageUtilization") is not None:
+        return f"{value['averageUtilization']}%"
+    for key in ("averageValue", "value"):
+        if value.get(key) is not None:
+            return str(value[key])
+    return None
+
+
+def _hpa_metric_source(metric: Dict[str, Any]) -> Dict[str, Any]:
+    """HPA 指标中与类型同名的字段，如 type=Resource 对应 resource"""
+    metric_type = metric.get("type") or ""
+    return metric.get(metric_type[:1].lower() + metric_type[1:]) or {}
+
+
+def _hpa_metric_key(metric: Dict[str, Any]) -> Tuple[str, Optional[str], Optional[str]]:
+    """HPA 指标的 (类型, 名称, 容器或对象)，用于匹配 spec.metrics 与 status.currentMetrics"""
+    metric_type = metric.get("type") or ""
+    source = _hpa_metric_source(metric)
+    if metric_type in ("Resource", "ContainerResource"):
+        return metric_type, source.get("name"), source.get("container")
+    described = source.get("describedObject") or {}
+    target = f"{described.get('kind')}/{described.get('name')}" if described else None
+    return metric_type, (source.get("metric") or {}).get("name"), target
+
+
+def _hpa_metrics(obj: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """HPA 各指标的目标值与当前值，兼容 autoscaling/v1 的 CPU 利用率字段"""
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    if "targetCPUUtilizationPercentage" in spec:
+        current = status.get("currentCPUUtilizationPercentage")
+        return [{"type": "Resource", "name": "cpu", "target": f"{spec['targetCPUUtilizationPercentage']}%",
+                 "current": f"{current}%" if current is not None else None}]
+    current_values = {
+        _hpa_metric_key(metric): _hpa_metric_value(_hpa_metric_source(metric).get("current") or {})
+        for metric in status.get("currentMetrics") or []
+    }
+    metrics = []
+    for metric in spec.get("metrics") or []:
+        key = _hpa_metric_key(metric)
+        target = _hpa_metric_source(metric).get("target") or {}
+        entry = {"type": key[0], "name": key[1], "target": _hpa_metric_value(target),
+                 "current": current_values.get(key)}
+        if key[2]:
+            entry["container" if key[0] == "ContainerResource" else "object"] = key[2]
+        metrics.append(entry)
+    return metrics
+
+
+def _summarize_hpa(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    target_ref = spec.get("scaleTargetRef") or {}
+    return {
+        "reference": f"{target_ref.get('kind')}/{target_ref.get('name')}" if target_ref else None,
+        "scale_target_api_version": target_ref.get("apiVersion"),
+        "min_replicas": spec.get("minReplicas", 1),
+        "max_replicas": spec.get("maxReplicas"),
+        "current_replicas": status.get("currentReplicas", 0),
+        "desired_replicas": status.get("desiredReplicas", 0),
+        "metrics": _hpa_metrics(obj),
+        "scaling_conditions": [
+            {"type": c.get("type"), "status": c.get("status"), "reason": c.get("reason"), "message": c.get("message")}
+            for c in status.get("conditions") or []
+        ],
+        "last_scale_time": status.get("lastScaleTime"),
+    }
+
+
+def _hpa_targets(summary: Dict[str, Any]) -> str:
+    """kubectl get hpa 风格的 TARGETS 列，如 cpu: 95%/80%"""
+    return ", ".join(
+        f"{m.get('name')}: {m.get('current') or '<unknown>'}/{m.get('target')}" for m in summary.get("metrics") or []
+    ) or "<none>"
+
+
+# 资源配额用量达到该比例时视为接近上限
+QUOTA_NEAR_LIMIT_RATIO = 0.9
+
+
+def _summarize_resourcequota(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    hard = status.get("hard") or spec.get("hard") or {}
+    used = status.get("used") or {}
+    usage = []
+    for resource in sorted(hard):
+        entry = {"resource": resource, "used": used.get(resource, "0"), "hard": hard[resource], "ratio": None}
+        try:
+            limit = parse_quantity(hard[resource])
+            current = parse_quantity(used.get(resource))
+        except ValueError:
+            usage.append(entry)
+            continue
+        if limit > 0:
+            entry["ratio"] = round(current / limit, 3)
+        # hard 为 0 表示禁止创建该类资源，同样视为已达上限
+        if current >= limit:
+            entry["state"] = "AtLimit"
+        elif entry["ratio"] is not None and entry["ratio"] >= QUOTA_NEAR_LIMIT_RATIO:
+            entry["state"] = "NearLimit"
+        usage.append(entry)
+    return {
+        "scopes": spec.get("scopes") or [],
+        "usage": usage,
+        "at_limit": [u["resource"] for u in usage if u.get("state") == "AtLimit"],
+        "near_limit": [u["resource"] for u in usage if u.get("state") == "NearLimit"],
+    }
+
+
+def _quota_usage(summary: Dict[str, Any]) -> str:
+    """kubectl describe quota 风格的用量，如 requests.cpu: 1500m/2"""
+    return ", ".join(f"{u['resource']}: {u['used']}/{u['hard']}" for u in summary.get("usage") or []) or "<none>"
+
+
+# LimitRange 各项取值在摘要中的字段名
+_LIMIT_RANGE_FIELDS = (
+    ("default", "default"),
+    ("defaultRequest", "default_request"),
+    ("min", "min"),
+    ("max", "max"),
+    ("maxLimitRequestRatio", "max_limit_request_ratio"),
+)
+
+
+def _summarize_limitrange(obj: Dict[str, Any]) -> Dict[str, Any]:
+    limits = []
+    for item in (obj.get("spec") or {}).get("limits") or []:
+        resources = sorted({name for key, _ in _LIMIT_RANGE_FIELDS for name in (item.get(key) or {})})
+        for resource in resources:
+            entry = {"type": item.get("type"), "resource": resource}
+            for key, field_name in _LIMIT_RANGE_FIELDS:
+                entry[field_name] = (item.get(key) or {}).get(resource)
+            limits.append(entry)
+    return {"limits": limits}
+
+
+def _limit_range_types(summary: Dict[str, Any]) -> List[str]:
+    return sorted({limit["type"] for limit in summary.get("limits") or [] if limit.get("type")})
+
+
+def _summarize_node(obj: Dict[str, Any]) -> Dict[str, Any]:
+    metadata = obj.get("metadata") or {}
+    status = obj.get("status") or {}
+    ready = next((c for c in status.get("conditions") or [] if c.get("type") == "Ready"), {})
+    node_status = "Ready" if ready.get("status") == "True" else "NotReady"
+    if (obj.get("spec") or {}).get("unschedulable"):
+        node_status += ",SchedulingDisabled"
+    roles = sorted(
+        key.split("/", 1)[1] for key in (metadata.get("labels") or {})
+        if key.startswith("node-role.kubernetes.io/")
+    )
+    addresses = {a.get("type"): a.get("address") for a in status.get("addresses") or []}
+    return {
+        "status": node_status,
+        "roles": roles,
+        "version": (status.get("nodeInfo") or {}).get("kubeletVersion"),
+        "internal_ip": addresses.get("InternalIP"),
+    }
+
+
+def _summarize_pvc(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    return {
+        "status": status.get("phase"),
+        "volume": spec.get("volumeName"),
+        "capacity": (status.get("capacity") or {}).get("storage"),
+        "requested": ((spec.get("resources") or {}).get("requests") or {}).get("storage"),
+        "access_modes": status.get("accessModes") or spec.get("accessModes") or [],
+        "storage_class": spec.get("storageClassName"),
+    }
+
+
+def _summarize_pv(obj: Dict[str, Any]) -> Dict[str, Any]:
+    spec = obj.get("spec") or {}
+    status = obj.get("status") or {}
+    claim_ref = spec.get("claimRef") or {}
+    return {
+        "status": status.get("phase"),
+        "capacity": (spec.get("capacity") or {}).get("storage"),
+        "access_modes": spec.get("accessModes") or [],
+        "reclaim_policy": spec.get("persistentVolumeReclaimPolicy"),
+        "claim": f"{claim_ref.get('namespace')}/{claim_ref.get('name')}" if claim_ref else None,
+        "storage_class": spec.get("storageClassName"),
+        "reason": status.get("reason"),
+    }
+
+
+def _summarize_namespace(obj: Dict[str, Any]) -> Dict[str, Any]:
+    return {"status": (obj.get("status") or {}).get("phase")}
+
+
+def _summarize_configmap(obj: Dict[str, Any]) -> Dict[str, Any]:
+    keys = list((obj.get("data") or {}).keys()) + list((obj.get("binaryData") or {}).keys())
+    return {"data_keys": keys}
+
+
+def _summarize_secret(obj: Dict[str, Any]) -> Dict[str, Any]:
+    # 仅返回键名，不返回任何 Secret 内容
+    return {"type": obj.get("type"), "data_keys": list((obj.get("data") or {}).keys())}
+
+
+def _summarize_event(obj: Dict[str, Any]) -> Dict[str, Any]:
+    involved = obj.get("involvedObject") or {}
+    last_seen = event_time(obj)
+    return {
+        "type": obj.get("type"),
+        "reason": obj.get("reason"),
+        "object": f"{involved.get('kind')}/{involved.get('name')}",
+        "message": obj.get("message"),
+        "count": obj.get("count") or 1,
+        "last_seen": last_seen.isoformat().replace("+00:00", "Z") if last_seen else None,
+    }
+
+
+def _data_count(summary: Dict[str, Any]) -> int:
+    return len(summary.get("data_keys") or [])
+
+
+# 表示全部命名空间的 namespace 取值
+ALL_NAMESPACES = ("all",)
+
+# 未通过 --default-namespace 配置且不在集群内运行时，单个对象查询使用的命名空间
+DEFAULT_NAMESPACE = "default"
+
+# 所有资源类型均支持的字段选择器
+COMMON_FIELD_SELECTORS = ("metadata.name", "metadata.namespace")
+
+# 以 MCP 资源形式暴露的集群对象：URI 模板及支持的资源类型
+OBJECT_URI_SCHEME = "k8s://"
+OBJECT_URI_TEMPLATE = OBJECT_URI_SCHEME + "{cluster_id}/{namespace}/{resource}/{name}"
+OBJECT_URI_RESOURCES = ("pods", "deployments")
+
+
+@dataclass(frozen=True)
+class ResourceSpec:
+    """kubectl_get 支持的资源类型描述"""
+    resource: str
+    kind: str
+    group: str = ""
+    version: str = "v1"
+    namespaced: bool = True
+    short_names: List[str] = field(default_factory=list)
+    summarize: Optional[Callable[[Dict[str, Any]], Dict[str, Any]]] = None
+    # 除 COMMON_FIELD_SELECTORS 外，API Server 对该类型支持的字段选择器
+    field_selectors: Tuple[str, ...] = ()
+    # 通过 API 发现得到的类型（如 CRD），查询时使用完全限定名
+    discovered: bool = False
+    # output=wide 表格在 NAME（及 NAMESPACE）之后的列：(表头, 摘要字段名或由摘要计算单元格的函数)
+    columns: Tuple[Tuple[str, Union[str, Callable[[Dict[str, Any]], Any]]], ...] = (("AGE", "age"),)
+
+    @property
+    def api_version(self) -> str:
+        return f"{self.group}/{self.version}" if self.group else self.version
+
+    @property
+    def kubectl_name(self) -> str:
+        """kubectl 命令中使用的资源名，发现的类型使用 resource.version.group 避免与同名资源冲突"""
+        if self.discovered and self.group:
+            return f"{self.resource}.{self.version}.{self.group}"
+        return self.resource
+
+
+RESOURCE_SPECS: List[ResourceSpec] = [
+    ResourceSpec("pods", "Pod", short_names=["po", "pod"], summarize=_summarize_pod,
+                 columns=(("READY", "ready"), ("STATUS", "status"), ("RESTARTS", "restarts"), ("AGE", "age"),
+                          ("IP", "pod_ip"), ("NODE", "node")),
+                 field_selectors=("spec.nodeName", "spec.restartPolicy", "spec.schedulerName",
+                                  "spec.serviceAccountName", "spec.hostNetwork", "status.phase",
+                                  "status.podIP", "status.nominatedNodeName")),
+    ResourceSpec("services", "Service", short_names=["svc", "service"], summarize=_summarize_service,
+                 columns=(("TYPE", "type"), ("CLUSTER-IP", "cluster_ip"), ("EXTERNAL-IP", "external_ip"),
+                          ("PORT(S)", "ports"), ("AGE", "age"))),
+    ResourceSpec("deployments", "Deployment", group="apps", short_names=["deploy", "deployment"],
+                 summarize=_summarize_deployment,
+                 columns=(("READY", "ready"), ("UP-TO-DATE", "up_to_date"), ("AVAILABLE", "available"),
+                          ("AGE", "age"))),
+    ResourceSpec("statefulsets", "StatefulSet", group="apps", short_names=["sts", "statefulset"],
+                 summarize=_summarize_statefulset, columns=(("READY", "ready"), ("AGE", "age"))),
+    ResourceSpec("daemonsets", "DaemonSet", group="apps", short_names=["ds", "daemonset"],
+                 summarize=_summarize_daemonset,
+                 columns=(("DESIRED", "desired"), ("CURRENT", "current"), ("READY", "ready"),
+                          ("UP-TO-DATE", "up_to_date"), ("AVAILABLE", "available"), ("AGE", "age"))),
+    ResourceSpec("replicasets", "ReplicaSet", group="apps", short_names=["rs", "replicaset"],
+                 summarize=_summarize_replicaset, field_selectors=("status.replicas",),
+                 columns=(("DESIRED", "desired"), ("CURRENT", "current"), ("READY", "ready"), ("AGE", "age"),
+                          ("OWNER", "owner"))),
+    ResourceSpec("jobs", "Job", group="batch", short_names=["job"], summarize=_summarize_job,
+                 field_selectors=("status.successful",),
+                 columns=(("STATUS", "status"), ("COMPLETIONS", "completions"), ("DURATION", "duration"),
+                          ("AGE", "age"))),
+    ResourceSpec("cronjobs", "CronJob", group="batch", short_names=["cj", "cronjob"], summarize=_summarize_cronjob,
+                 columns=(("SCHEDULE", "schedule"), ("TIMEZONE", "timezone"), ("SUSPEND", "suspend"),
+                          ("ACTIVE", "active"), ("LAST SCHEDULE", "last_schedule"), ("AGE", "age"))),
+    ResourceSpec("ingresses", "Ingress", group="networking.k8s.io", short_names=["ing", "ingress"],
+                 summarize=_summarize_ingress,
+                 columns=(("CLASS", "class"), ("HOSTS", "hosts"), ("ADDRESS", "address"), ("AGE", "age"))),
+    ResourceSpec("horizontalpodautoscalers", "HorizontalPodAutoscaler", group="autoscaling", version="v2",
+                 short_names=["hpa", "horizontalpodautoscaler"], summarize=_summarize_hpa,
+                 columns=(("REFERENCE", "reference"), ("TARGETS", _hpa_targets), ("MINPODS", "min_replicas"),
+                          ("MAXPODS", "max_replicas"), ("REPLICAS", "current_replicas"), ("AGE", "age"))),
+    ResourceSpec("resourcequotas", "ResourceQuota", short_names=["quota", "resourcequota"],
+                 summarize=_summarize_resourcequota,
+                 columns=(("AGE", "age"), ("USED/HARD", _quota_usage), ("AT LIMIT", "at_limit"),
+                          ("NEAR LIMIT", "near_limit"))),
+    ResourceSpec("limitranges", "LimitRange", short_names=["limits", "limitrange"], summarize=_summarize_limitrange,
+                 columns=(("TYPES", _limit_range_types), ("AGE", "age"))),
+    ResourceSpec("nodes", "Node", namespaced=False, short_names=["no", "node"], summarize=_summarize_node,
+                 field_selectors=("spec.unschedulable",),
+                 columns=(("STATUS", "status"), ("ROLES", "roles"), ("AGE", "age"), ("VERSION", "version"),
+                          ("INTERNAL-IP", "internal_ip"))),
+    ResourceSpec("namespaces", "Namespace", namespaced=False, short_names=["ns", "namespace"],
+                 summarize=_summarize_namespace, field_selectors=("status.phase",),
+                 columns=(("STATUS", "status"), ("AGE", "age"))),
+    ResourceSpec("persistentvolumeclaims", "PersistentVolumeClaim", short_names=["pvc"], summarize=_summarize_pvc,
+                 columns=(("STATUS", "status"), ("VOLUME", "volume"), ("CAPACITY", "capacity"),
+                          ("ACCESS MODES", "access_modes"), ("STORAGECLASS", "storage_class"), ("AGE", "age"))),
+    ResourceSpec("persistentvolumes", "PersistentVolume", namespaced=False, short_names=["pv"],
+                 summarize=_summarize_pv,
+                 columns=(("CAPACITY", "capacity"), ("ACCESS MODES", "access_modes"),
+                          ("RECLAIM POLICY", "reclaim_policy"), ("STATUS", "status"), ("CLAIM", "claim"),
+                          ("STORAGECLASS", "storage_class"), ("AGE", "age"))),
+    ResourceSpec("configmaps", "ConfigMap", short_names=["cm", "configmap"], summarize=_summarize_configmap,
+                 columns=(("DATA", _data_count), ("AGE", "age"))),
+    ResourceSpec("secrets", "Secret", short_names=["secret"], summarize=_summarize_secret,
+                 field_selectors=("type",), columns=(("TYPE", "type"), ("DATA", _data_count), ("AGE", "age"))),
+    ResourceSpec("events", "Event", short_names=["ev", "event"], summarize=_summarize_event,
+                 field_selectors=("involvedObject.kind", "involvedObject.namespace", "involvedObject.name",
+                                  "involvedObject.uid", "involvedObject.apiVersion",
+                                  "involvedObject.resourceVersion", "involvedObject.fieldPath",
+                                  "reason", "reportingComponent", "source", "type"),
+                 columns=(("LAST SEEN", "last_seen"), ("TYPE", "type"), ("REASON", "reason"),
+                          ("OBJECT", "object"), ("MESSAGE", "message"))),
+]
+
+
+def normalize_namespace(namespace: Optional[str]) -> Optional[str]:
+    """namespace=all 表示全部命名空间，统一转换为 None"""
+    if isinstance(namespace, str) and namespace.strip().lower() in ALL_NAMESPACES:
+        return None
+    return namespace or None
+
+
+def resolve_namespace(
+    spec: Optional["ResourceSpec"],
+    namespace: Optional[str],
+    warnings: Optional[List[str]] = None,
+    default: Optional[str] = None,
+) -> Optional[str]:
+    """解析查询使用的命名空间，所有按命名空间查询的工具统一经此处理
+
+    namespace 为空或 all 时返回 default（列表查询为 None 即全部命名空间，单个对象为服务的默认命名空间）；
+    集群级资源（如 nodes）始终返回 None，指定了命名空间时向 warnings 追加说明。
+    """
+    resolved = normalize_namespace(namespace)
+    if spec is not None and not spec.namespaced:
+        if resolved and warnings is not None:
+            warnings.append(f"{spec.resource} 是集群级资源，已忽略 namespace '{resolved}'")
+        return None
+    return resolved or default
+
+
+def namespace_args(namespace: Optional[str]) -> List[str]:
+    """kubectl 的命名空间参数，namespace 为 None 时查询全部命名空间"""
+    return ["-n", namespace] if namespace else ["--all-namespaces"]
+
+
+def find_resource_spec(resource: str) -> Optional[ResourceSpec]:
+    """按复数名、短名称或 Kind（大小写不敏感）查找资源类型"""
+    key = (resource or "").strip().lower()
+    for spec in RESOURCE_SPECS:
+        if key in (spec.resource, spec.kind.lower(), *spec.short_names):
+            return spec
+    return None
+
+
+def resolve_discovered_spec(
+    api_resources: List[Dict[str, Any]], resource: str, api_version: Optional[str] = None
+) -> Optional[ResourceSpec]:
+    """从 kubectl api-resources 的发现结果中解析资源类型（用于 CRD 等内置注册表之外的资源）
+
+    resource 可以是复数名、短名称、Kind 或 <复数名>.<group>；api_version（如 networking.istio.io/v1beta1）用于区分
+    不同 group 下的同名资源。未找到时返回 None，存在多个候选时抛出 ValueError。
+    """
+    key = (resource or "").strip().lower()
+    candidates = [
+        entry for entry in api_resources
+        if key in (entry["name"], entry["kind"].lower(), *entry["short_names"])
+        or (entry["group"] and key == f"{entry['name']}.{entry['group']}")
+    ]
+    if api_version:
+        candidates = [
+            entry for entry in candidates
+            if (f"{entry['group']}/{entry['version']}" if entry["group"] else entry["version"]) == api_version
+        ]
+    if not candidates:
+        return None
+    if len(candidates) > 1:
+        choices = ", ".join(
+            f"{entry['name']} ({entry['group'] + '/' if entry['group'] else ''}{entry['version']})" for entry in candidates
+        )
+        raise ValueError(f"resource '{resource}' is ambiguous, specify api_version: {choices}")
+    entry = candidates[0]
+    return ResourceSpec(
+        entry["name"], entry["kind"], group=entry["group"], version=entry["version"],
+        namespaced=entry["namespaced"], short_names=entry["short_names"], discovered=True,
+    )
+
+
+def object_uri(cluster_id: str, namespace: str, resource: str, name: str) -> str:
+    """集群对象的 MCP 资源 URI，如 k8s://c123/prod/pods/web-1"""
+    return OBJECT_URI_TEMPLATE.format(cluster_id=cluster_id, namespace=namespace, resource=resource, name=name)
+
+
+def parse_object_uri(uri: str) -> Optional[Dict[str, str]]:
+    """解析集群对象的 MCP 资源 URI，返回 cluster_id/namespace/resource/name（忽略 ?context= 等查询参数），非该格式时返回 None"""
+    if not uri.startswith(OBJECT_URI_SCHEME):
+        return None
+    parts = uri[len(OBJECT_URI_SCHEME):].split("?", 1)[0].split("/")
+    if len(parts) != 4 or not all(parts):
+        return None
+    return dict(zip(("cluster_id", "namespace", "resource", "name"), parts))
+
+
+def list_api_path(spec: ResourceSpec, namespace: Optional[str], query: Dict[str, Any]) -> str:
+    """构造 list 请求的 API 路径（用于 kubectl get --raw 分页查询），query 中的空值会被忽略"""
+    path = f"/apis/{spec.group}/{spec.version}" if spec.group else f"/api/{spec.version}"
+    if spec.namespaced and namespace:
+        path += f"/namespaces/{quote(namespace, safe='')}"
+    path += f"/{spec.resource}"
+    params = {k: v for k, v in query.items() if v not in (None, "", 0)}
+    return f"{path}?{urlencode(params)}" if params else path
+
+
+def validate_field_selector(spec: ResourceSpec, selector: str) -> None:
+    """校验字段选择器语法及该资源类型是否支持所用字段，不合法时抛出 ValueError"""
+    supported = COMMON_FIELD_SELECTORS + spec.field_selectors
+    for field_name, _, _ in parse_field_selector(selector):
+        if field_name not in supported:
+            raise ValueError(
+                f"field selector '{field_name}' is not supported for {spec.resource}, "
+                f"supported: {', '.join(supported)}"
+            )
+
+
+def summarize_object(spec: ResourceSpec, obj: Dict[str, Any], now: datetime) -> Dict[str, Any]:
+    """提取对象的通用字段（名称、命名空间、创建时间、存活时间）及类型相关摘要"""
+    metadata = obj.get("metadata") or {}
+    created = parse_k8s_time(metadata.get("creationTimestamp"))
+    summary: Dict[str, Any] = {"name": metadata.get("name")}
+    if spec.namespaced:
+        summary["namespace"] = metadata.get("namespace")
+    summary["created"] = metadata.get("creationTimestamp")
+    summary["age"] = format_age(now - created) if created else None
+    if spec.summarize:
+        summary.update(spec.summarize(obj))
+    return summary
+
+
+def format_wide_table(spec: ResourceSpec, summaries: List[Dict[str, Any]], include_namespace: bool) -> str:
+    """将摘要渲染为 kubectl get -o wide 风格的表格，跨命名空间查询时首列为 NAMESPACE"""
+    columns: List[Tuple[str, Any]] = [("NAME", "name"), *spec.columns]
+    if include_namespace and spec.namespaced:
+        columns.insert(0, ("NAMESPACE", "namespace"))
+    rows = [
+        [format_cell(key(summary) if callable(key) else summary.get(key)) for _, key in columns]
+        for summary in summaries
+    ]
+    return format_table([header for header, _ in columns], rows)
+
+
+def format_custom_columns(columns: List[Tuple[str, str]], items: List[Dict[str, Any]]) -> str:
+    """按 custom-columns 规格（[(表头, JSONPath)]）渲染对象表格，多个匹配值以逗号连接"""
+    rows = [[format_cell(jsonpath_values(item, expression)) for _, expression in columns] for item in items]
+    return format_table([header for header, _ in columns], rows)
+
+
+def _format_mapping(title: str, mapping: Optional[Dict[str, Any]]) -> List[str]:
+    items = sorted((mapping or {}).items())
+    if not items:
+        return [f"{title + ':':<14}<none>"]
+    lines = [f"{title + ':':<14}{items[0][0]}={items[0][1]}"]
+    lines += [f"{' ' * 14}{k}={v}" for k, v in items[1:]]
+    return lines
+
+
+def format_describe(spec: ResourceSpec, obj: Dict[str, Any], events: List[Dict[str, Any]], now: datetime) -> str:
+    """将对象摘要与相关事件合并为类似 kubectl describe 的可读文本"""
+    metadata = obj.get("metadata") or {}
+    summary = summarize_object(spec, obj, now)
+    lines = [f"Name:         {metadata.get('name')}"]
+    if spec.namespaced:
+        lines.append(f"Namespace:    {metadata.get('namespace')}")
+    lines.append(f"Kind:         {spec.kind}")
+    lines.append(f"Created:      {summary.get('created')} ({summary.get('age')} ago)")
+    lines += _format_mapping("Labels", metadata.get("labels"))
+    annotations = {
+        k: v for k, v in (metadata.get("annotations") or {}).items()
+        if k != "kubectl.kubernetes.io/last-applied-configuration"
+    }
+    lines += _format_mapping("Annotations", annotations)
+    for key, value in summary.items():
+        # 状况在下方 Conditions 中输出
+        if key in ("name", "namespace", "created", "age") or key.endswith("conditions"):
+            continue
+        title = key.replace("_", " ").title()
+        if isinstance(value, list) and any(isinstance(v, dict) for v in value):
+            lines.append(f"{title}:")
+            lines += [
+                "  " + ", ".join(f"{k}={v}" for k, v in item.items() if v is not None)
+                for item in value if isinstance(item, dict)
+            ]
+            continue
+        if isinstance(value, list):
+            value = ", ".join(str(v) for v in value) or "<none>"
+        lines.append(f"{title + ':':<14}{value if value is not None else '<none>'}")
+    conditions = (obj.get("status") or {}).get("conditions") or []
+    if conditions:
+        lines.append("Conditions:")
+        lines += [
+            f"  {c.get('type')}={c.get('status')}" + (f" ({c.get('reason')})" if c.get("reason") else "")
+            + (f": {c.get('message')}" if c.get("message") else "")
+            for c in conditions
+        ]
+
+    if not events:
+        lines.append("Events:       No events found")
+        return "\n".join(lines)
+    lines.append("Events:")
+    lines.append("  Type     Reason               Age      From                 Message")
+    for event in sorted(events, key=lambda e: event_time(e) or now):
+        seen = event_time(event)
+        age = format_age(now - seen) if seen else "<unknown>"
+        count = event.get("count") or (event.get("series") or {}).get("count") or 1
+        if count > 1:
+            age = f"{age} (x{count})"
+        source = (event.get("source") or {}).get("component") or event.get("reportingComponent") or ""
+        lines.append(
+            f"  {event.get('type', ''):<8} {event.get('reason', ''):<20} {age:<8} {source:<20} "
+            f"{(event.get('message') or '').strip()}"
+        )
+    return "\n".join(lines)
@@ -0,0 +1,370 @@
+"""kubectl 命令执行器。
+
+为 ack_kubectl 与基于 kubectl 的结构化工具提供统一的 kubeconfig 解析、命令执行与 ExecutionLog 记录。
+这里以参数列表方式异步调用 kubectl（不经过 shell），避免用户输入的资源名、选择器等被 shell 解释，
+调用被取消时终止 kubectl 进程。
+"""
+
+import asyncio
+import json
+import time
+from datetime import datetime, timezone
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from fastmcp import Context
+from loguru import logger
+
+from impersonation import impersonation_args
+from kubectl_handler import get_context_manager
+from kubectl_helpers import (
+    LONG_RUNNING_TIMEOUTS,
+    classify_kubectl_error,
+    is_retriable_kubectl_error,
+    parse_server_date,
+    resolve_timeout,
+)
+from models import ErrorModel, ExecutionLog, enable_execution_log_ctx
+from rate_limit import get_rate_limiter
+
+# 流式输出单行最大长度（watch 事件中的完整对象可能较大）
+STREAM_LINE_LIMIT = 16 * 1024 * 1024
+
+# 遇到临时性错误时可安全重试的只读子命令
+RETRIABLE_VERBS = ("get", "api-resources", "api-versions", "describe", "top", "explain", "version")
+
+# 只读请求单次访问 API Server 的默认超时（秒，kubectl --request-timeout）及临时性错误的默认重试次数
+DEFAULT_REQUEST_TIMEOUT = 15
+DEFAULT_MAX_RETRIES = 2
+
+# 重试的初始退避时间（秒），每次重试翻倍
+RETRY_BACKOFF_SECONDS = 0.5
+
+
+def start_execution_log(tool_name: str, cluster_id: str, enable_execution_log: bool) -> Tuple[ExecutionLog, int]:
+    """初始化工具调用的 ExecutionLog，返回 (execution_log, start_ms)"""
+    enable_execution_log_ctx.set(enable_execution_log)
+    start_ms = int(time.time() * 1000)
+    execution_log = ExecutionLog(
+        tool_call_id=f"{tool_name}_{cluster_id}_{start_ms}",
+        tool_name=tool_name,
+        start_time=datetime.utcnow().isoformat() + "Z"
+    )
+    return execution_log, start_ms
+
+
+def finish_execution_log(
+    execution_log: ExecutionLog,
+    start_ms: int,
+    error: Optional[Exception] = None,
+    failure_stage: Optional[str] = None,
+):
+    """补全 ExecutionLog 的结束时间与耗时，出错时记录错误信息"""
+    execution_log.end_time = datetime.utcnow().isoformat() + "Z"
+    execution_log.duration_ms = int(time.time() * 1000) - start_ms
+    if error is not None:
+        execution_log.error = str(error)
+        execution_log.metadata = {
+            "error_type": type(error).__name__,
+            "failure_stage": failure_stage,
+        }
+
+
+class KubectlCommandError(Exception):
+    """kubectl 命令执行失败"""
+
+    def __init__(self, message: str, exit_code: int = 1, stderr: str = ""):
+        super().__init__(message)
+        self.exit_code = exit_code
+        self.stderr = stderr
+
+
+def command_error_model(error: Exception, default_code: str) -> ErrorModel:
+    """将工具异常转换为 ErrorModel，kubectl 的 Forbidden/NotFound/Unauthorized 错误映射为对应错误码与可操作的提示"""
+    if isinstance(error, KubectlCommandError):
+        classified = classify_kubectl_error(error.stderr or str(error))
+        if classified:
+            return ErrorModel(**classified)
+    return ErrorModel(error_code=default_code, error_message=str(error))
+
+
+class KubectlRunner:
+    """以参数列表方式执行 kubectl 并记录 ExecutionLog。"""
+
+    def __init__(self, settings: Optional[Dict[str, Any]] = None):
+        self.settings = settings or {}
+        self.timeout = self.settings.get("kubectl_timeout", 30)
+        self.max_timeout = self.settings.get("max_tool_timeout", 600)
+        self.request_timeout = self.settings.get("request_timeout", DEFAULT_REQUEST_TIMEOUT)
+        self.max_retries = self.settings.get("kubectl_max_retries", DEFAULT_MAX_RETRIES)
+        self.rate_limiter = get_rate_limiter(self.settings)
+
+    def resolve_timeout(self, requested: Any = None, operation: Optional[str] = None) -> int:
+        """按操作类型取默认超时，可被工具参数 timeout_seconds 覆盖，且不超过 max_tool_timeout"""
+        return resolve_timeout(requested, LONG_RUNNING_TIMEOUTS.get(operation, self.timeout), self.max_timeout)
+
+    def _setup_cs_client(self, ctx: Context):
+        """从 lifespan providers 设置 CS 客户端（仅在需要时）"""
+        context_manager = get_context_manager()
+        if getattr(context_manager, "_cs_client", None):
+            return
+        try:
+            lifespan_context = getattr(ctx.request_context, "lifespan_context", {}) or {}
+            providers = lifespan_context.get("providers", {}) if isinstance(lifespan_context, dict) else {}
+            config = lifespan_context.get("config", {}) if isinstance(lifespan_context, dict) else {}
+            cs_client_factory = providers.get("cs_client_factory") if isinstance(providers, dict) else None
+            if cs_client_factory:
+                context_manager.set_cs_client(cs_client_factory("CENTER", config))
+            else:
+                logger.warning("cs_client_factory not available in lifespan context")
+        except Exception as e:
+            logger.error(f"Failed to setup CS client: {e}")
+
+    def resolve_kubeconfig(
+        self, ctx: Context, cluster_id: str, execution_log: ExecutionLog, context: Optional[str] = None
+    ) -> str:
+        """获取集群对应的 kubeconfig 文件路径，指定 context 时返回仅包含该 context 的 kubeconfig"""
+        self._setup_cs_client(ctx)
+        return get_context_manager().get_kubeconfig_path(
+            cluster_id,
+            self.settings.get("kubeconfig_mode"),
+            self.settings.get("kubeconfig_path"),
+            execution_log,
+            context,
+            self.settings.get("kubeconfig_dir"),
+        )
+
+    async def _throttle(self, deadline: Optional[float] = None):
+        """按 kubectl QPS/Burst 限流，等待时间不超过 deadline"""
+        if self.rate_limiter is not None:
+            await self.rate_limiter.acquire(None if deadline is None else deadline - time.monotonic())
+
+    @staticmethod
+    def _command(kubeconfig_path: str, args: List[str]) -> List[str]:
+        """构造 kubectl 命令，调用指定了 impersonation 身份时附加 --as/--as-group"""
+        return ["kubectl", "--kubeconfig", kubeconfig_path, *impersonation_args(), *args]
+
+    async def _exec(self, cmd: List[str], timeout: int, stdin: Optional[str]) -> Dict[str, Any]:
+        """执行命令并返回 exit_code/stdout/stderr
+
+        超时或调用被取消（客户端断开、取消请求或超过调用方的截止时间）时终止 kubectl 进程，
+        取消时 CancelledError 继续向上传播。
+        """
+        try:
+            process = await asyncio.create_subprocess_exec(
+                *cmd,
+                stdin=asyncio.subprocess.PIPE if stdin is not None else asyncio.subprocess.DEVNULL,
+                stdout=asyncio.subprocess.PIPE,
+                stderr=asyncio.subprocess.PIPE,
+            )
+        except FileNotFoundError as e:
+            return {"exit_code": 127, "stdout": "", "stderr": str(e)}
+        try:
+            stdout, stderr = await asyncio.wait_for(
+                process.communicate(stdin.encode() if stdin is not None else None), timeout=timeout
+            )
+        except asyncio.TimeoutError:
+            return {"exit_code": 124, "stdout": "", "stderr": f"Command timed out after {timeout} seconds"}
+        finally:
+            if process.returncode is None:
+                process.kill()
+                await process.wait()
+        return {
+            "exit_code": process.returncode,
+            "stdout": stdout.decode("utf-8", errors="replace"),
+            "stderr": stderr.decode("utf-8", errors="replace").strip(),
+        }
+
+    async def run(
+        self,
+        kubeconfig_path: str,
+        args: List[str],
+        execution_log: ExecutionLog,
+        timeout: Optional[int] = None,
+        stdin: Optional[str] = None,
+    ) -> Dict[str, Any]:
+        """执行 kubectl 子命令，返回 exit_code/stdout/stderr
+
+        只读子命令（get、describe 等）的单次请求以 --request-timeout 限时，遇到限流、超时、API Server 暂不可用等
+        临时性错误时按指数退避重试，所有重试共享 timeout；Forbidden/NotFound 等确定性错误不重试。
+        调用被取消时立即终止进行中的 kubectl 进程并停止重试。
+        """
+        timeout = timeout or self.resolve_timeout()
+        retriable = bool(args) and args[0] in RETRIABLE_VERBS and not {"-w", "--watch"} & set(args)
+        kubectl_args = list(args)
+        if retriable and self.request_timeout:
+            kubectl_args.insert(0, f"--request-timeout={min(self.request_timeout, timeout)}s")
+        deadline = time.monotonic() + timeout
+        attempt = 0
+        while True:
+            attempt += 1
+            cmd = self._command(kubeconfig_path, kubectl_args)
+            await self._throttle(deadline)
+            cmd_start = int(time.time() * 1000)
+            result = await self._exec(cmd, max(int(deadline - time.monotonic()), 1), stdin)
+            exit_code = result["exit_code"]
+            execution_log.api_calls.append({
+                "api": "KubectlCommand",
+                "command": " ".join(args),
+                "duration_ms": int(time.time() * 1000) - cmd_start,
+                "exit_code": exit_code,
+                "status": "success" if exit_code == 0 else ("timeout" if exit_code == 124 else "failed"),
+                "timeout": timeout,
+                "attempt": attempt,
+            })
+            if (exit_code in (0, 124) or not retriable or attempt > self.max_retries
+                    or not is_retriable_kubectl_error(result["stderr"])):
+                return result
+            delay = RETRY_BACKOFF_SECONDS * 2 ** (attempt - 1)
+            if time.monotonic() + delay >= deadline:
+                return result
+            logger.warning(
+                f"kubectl {' '.join(args)} failed with a transient error, retrying in {delay}s "
+                f"(attempt {attempt}/{self.max_retries + 1}): {result['stderr'].splitlines()[0]}"
+            )
+            await asyncio.sleep(delay)
+
+    async def sample_stream(
+        self,
+        kubeconfig_path: str,
+        args: List[str],
+        execution_log: ExecutionLog,
+        duration: float,
+    ) -> Dict[str, Any]:
+        """执行流式 kubectl 子命令（如 logs -f）duration 秒后终止，仅统计输出的字节数与行数
+
+        Returns:
+            {"exit_code", "bytes", "lines", "elapsed", "stderr"}；达到采样时长后主动终止时 exit_code 为 0
+        """
+        cmd = self._command(kubeconfig_path, args)
+        await self._throttle()
+        cmd_start = time.monotonic()
+        counts = {"bytes": 0, "lines": 0}
+        try:
+            process = await asyncio.create_subprocess_exec(
+                *cmd, stdout=asyncio.subprocess.PIPE, stderr=asyncio.subprocess.PIPE
+            )
+        except FileNotFoundError as e:
+            return {"exit_code": 127, "bytes": 0, "lines": 0, "elapsed": 0.0, "stderr": str(e)}
+
+        async def consume():
+            while chunk := await process.stdout.read(65536):
+                counts["bytes"] += len(chunk)
+                counts["lines"] += chunk.count(b"\n")
+
+        try:
+            await asyncio.wait_for(consume(), timeout=duration)
+            exit_code = await process.wait()
+        except asyncio.TimeoutError:
+            exit_code = 0
+        finally:
+            # 达到采样时长或调用被取消（如服务退出）时确保 kubectl 进程退出
+            if process.returncode is None:
+                process.kill()
+                await process.wait()
+        stderr = (await process.stderr.read()).decode("utf-8", errors="replace").strip()
+        elapsed = round(min(time.monotonic() - cmd_start, duration), 3)
+        execution_log.api_calls.append({
+            "api": "KubectlCommand",
+            "command": " ".join(args),
+            "duration_ms": int(elapsed * 1000),
+            "exit_code": exit_code,
+            "status": "success" if exit_code == 0 else "failed",
+            "sample_seconds": duration,
+        })
+        return {"exit_code": exit_code, "elapsed": elapsed, "stderr": stderr, **counts}
+
+    async def stream_lines(
+        self,
+        kubeconfig_path: str,
+        args: List[str],
+        execution_log: ExecutionLog,
+        duration: float,
+        on_line: Callable[[str], Awaitable[Optional[bool]]],
+    ) -> Dict[str, Any]:
+        """执行流式 kubectl 子命令（如 watch），逐行回调 on_line，duration 秒后、on_line 返回 True 或调用被取消时终止进程
+
+        Returns:
+            {"exit_code", "elapsed", "stderr", "reason"}；reason 为 timeout（达到时长后主动终止，exit_code 为 0）、
+            stopped（on_line 要求停止，exit_code 为 0）或 closed（进程自行退出）
+        """
+        cmd = self._command(kubeconfig_path, args)
+        await self._throttle()
+        cmd_start = time.monotonic()
+        try:
+            process = await asyncio.create_subprocess_exec(
+                *cmd, stdout=asyncio.subprocess.PIPE, stderr=asyncio.subprocess.PIPE, limit=STREAM_LINE_LIMIT
+            )
+        except FileNotFoundError as e:
+            return {"exit_code": 127, "elapsed": 0.0, "stderr": str(e), "reason": "closed"}
+
+        async def consume() -> bool:
+            while line := await process.stdout.readline():
+                if await on_line(line.decode("utf-8", errors="replace")):
+                    return True
+            return False
+
+        try:
+            if await asyncio.wait_for(consume(), timeout=duration):
+                exit_code = 0
+                reason = "stopped"
+            else:
+                exit_code = await process.wait()
+                reason = "closed"
+        except asyncio.TimeoutError:
+            exit_code = 0
+            reason = "timeout"
+        finally:
+            # 超时、回调异常或客户端断开（任务取消）时确保 kubectl 进程退出
+            if process.returncode is None:
+                process.kill()
+                await process.wait()
+        stderr = (await process.stderr.read()).decode("utf-8", errors="replace").strip()
+        elapsed = round(min(time.monotonic() - cmd_start, duration), 3)
+        execution_log.api_calls.append({
+            "api": "KubectlCommand",
+            "command": " ".join(args),
+            "duration_ms": int(elapsed * 1000),
+            "exit_code": exit_code,
+            "status": "success" if exit_code == 0 else "failed",
+            "stream_seconds": duration,
+        })
+        return {"exit_code": exit_code, "elapsed": elapsed, "stderr": stderr, "reason": reason}
+
+    async def run_json(
+        self,
+        kubeconfig_path: str,
+        args: List[str],
+        execution_log: ExecutionLog,
+        timeout: Optional[int] = None,
+        stdin: Optional[str] = None,
+    ) -> Any:
+        """执行 kubectl 子命令并解析 JSON 输出，失败时抛出 KubectlCommandError"""
+        result = await self.run(kubeconfig_path, args, execution_log, timeout=timeout, stdin=stdin)
+        if result["exit_code"] != 0:
+            raise KubectlCommandError(
+                result["stderr"] or f"kubectl exited with code {result['exit_code']}",
+                exit_code=result["exit_code"],
+                stderr=result["stderr"],
+            )
+        try:
+            return json.loads(result["stdout"]) if result["stdout"].strip() else {}
+        except json.JSONDecodeError as e:
+            raise KubectlCommandError(f"Invalid JSON response from kubectl {' '.join(args)}: {e}")
+
+    async def server_time(
+        self,
+        kubeconfig_path: str,
+        execution_log: ExecutionLog,
+        timeout: Optional[int] = None,
+    ) -> Tuple[datetime, str]:
+        """获取 API Server 当前时间（解析响应头 Date），失败时回退为本地时间
+
+        Returns:
+            (当前时间, 时间来源 "server" 或 "local")
+        """
+        result = await self.run(kubeconfig_path, ["get", "--raw", "/version", "-v=8"], execution_log, timeout=timeout)
+        server_now = parse_server_date(result["stderr"]) if result["exit_code"] == 0 else None
+        if server_now is None:
+            logger.debug("Failed to read API server Date header, falling back to local time")
+            return datetime.now(timezone.utc), "local"
+        return server_now, "server"
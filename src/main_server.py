@@ -22,15 +22,37 @@ to connect various sub-MCP servers.
 import argparse
 import os
 import sys
+from contextlib import asynccontextmanager
 from typing import Dict, Any, Optional, Literal
 from loguru import logger
 from fastmcp import FastMCP
+from starlette.middleware import Middleware
 
 from ack_audit_log_handler import ACKAuditLogHandler
 from ack_controlplane_log_handler import ACKControlPlaneLogHandler
 from ack_cost_analysis_handler import ACKCostAnalysisHandler
 from transport_security import TransportSecurityMiddleware, TransportSecuritySettings
 from ack_autoscaling_handler import ACKAutoscalingHandler
+from kubectl_analysis_handler import KubectlAnalysisHandler
+from kubectl_resource_handler import KubectlResourceHandler
+from prompt_handler import PromptHandler
+from health import register_health_routes
+from http_auth import BearerTokenAuthMiddleware
+from metrics import register_metrics
+from request_logging import LOG_FORMATS, RequestContextMiddleware, configure_logging
+from impersonation import ImpersonationMiddleware
+from inline_kubeconfig import InlineKubeconfigMiddleware
+from kubectl_runner import KubectlRunner
+from discovery_cache import DEFAULT_DISCOVERY_REFRESH_INTERVAL
+from rate_limit import (
+    DEFAULT_KUBECTL_BURST,
+    DEFAULT_KUBECTL_QPS,
+    DEFAULT_MAX_CONCURRENT_CALLS,
+    ConcurrencyLimitMiddleware,
+)
+from response_limit import DEFAULT_MAX_RESPONSE_BYTES, ResponseSizeLimitMiddleware
+from shutdown import DEFAULT_SHUTDOWN_TIMEOUT, InFlightCallsMiddleware
+from namespace_policy import NamespaceAllowlistMiddleware, parse_allowed_namespaces
 
 # 尝试导入python-dotenv
 try:
@@ -44,7 +66,7 @@ except ImportError:
 from config import Configs
 from runtime_provider import ACKClusterRuntimeProvider
 from ack_cluster_handler import ACKClusterHandler
-from kubectl_handler import KubectlHandler
+from kubectl_handler import KubectlHandler, resolve_default_namespace
 from ack_prometheus_handler import PrometheusHandler
 from ack_diagnose_handler import DiagnoseHandler
 from ack_inspect_handler import InspectHandler
@@ -109,6 +131,9 @@ a unified interface for comprehensive Kubernetes operations.
 Use this server to streamline your Kubernetes operations and monitoring workflows.
 """
 
+# 排空工具调用后等待剩余 HTTP 连接关闭的时间（秒）
+SHUTDOWN_CONNECTION_TIMEOUT = 5
+
 MAIN_SERVER_DEPENDENCIES = [
     "fastmcp",
     "pydantic", 
@@ -145,11 +170,20 @@ def create_main_server(
     # Create runtime provider for main server (reuse ACK cluster runtime)
     runtime_provider = ACKClusterRuntimeProvider()
 
+    # Track in-flight tool calls so that shutdown waits for them
+    in_flight_calls = InFlightCallsMiddleware(settings.get("shutdown_timeout", DEFAULT_SHUTDOWN_TIMEOUT))
+
+    @asynccontextmanager
+    async def lifespan(app: FastMCP):
+        in_flight_calls.install_signal_handlers()
+        async with runtime_provider.init_runtime(app) as context:
+            yield context
+
     # Create main MCP server
     main_mcp = FastMCP(
         name=MAIN_SERVER_NAME,
         instructions=MAIN_SERVER_INSTRUCTIONS,
-        lifespan=runtime_provider.init_runtime,
+        lifespan=lifespan,
     )
 
     # Attach config for lifespan provider access
@@ -173,6 +207,40 @@ def create_main_server(
     ACKCostAnalysisHandler(main_mcp, settings)
     # Register autoscaling tools
     ACKAutoscalingHandler(main_mcp, settings)
+    # Register kubectl analysis tools
+    KubectlAnalysisHandler(main_mcp, settings)
+    # Register kubectl resource query tools
+    kubectl_resource_handler = KubectlResourceHandler(main_mcp, settings)
+    # Register diagnostic workflow prompts
+    PromptHandler(main_mcp, settings)
+    # Register /healthz and /readyz for sse/http deployments
+    register_health_routes(main_mcp, settings)
+    # Attach a request ID and structured fields to the logs of every tool call
+    main_mcp.add_middleware(RequestContextMiddleware())
+    # Register tool call metrics and /metrics for sse/http deployments
+    register_metrics(main_mcp)
+    # Drain in-flight tool calls on SIGTERM/SIGINT before the server shuts down
+    main_mcp.add_middleware(in_flight_calls)
+    # Reject tool calls beyond the concurrency limit instead of queuing them
+    if settings.get("max_concurrent_calls", 0) > 0:
+        main_mcp.add_middleware(ConcurrencyLimitMiddleware(settings["max_concurrent_calls"]))
+    # Truncate oversized tool results at object boundaries so clients do not drop them
+    if settings.get("max_response_bytes", 0) > 0:
+        main_mcp.add_middleware(ResponseSizeLimitMiddleware(settings["max_response_bytes"]))
+    # Run kubectl calls as the user given by impersonate_user/impersonate_groups
+    if settings.get("allow_impersonation"):
+        main_mcp.add_middleware(ImpersonationMiddleware())
+    # Accept a per-request kubeconfig_base64 parameter on cluster tools
+    if settings.get("allow_inline_kubeconfig"):
+        main_mcp.add_middleware(InlineKubeconfigMiddleware(KubectlRunner(settings)))
+    # Restrict all tools to the allowed namespaces; added last so resource scope discovery
+    # uses the caller's identity and inline kubeconfig
+    if settings.get("allowed_namespaces"):
+        main_mcp.add_middleware(NamespaceAllowlistMiddleware(
+            settings["allowed_namespaces"],
+            settings.get("default_namespace"),
+            kubectl_resource_handler.resolve_namespaced,
+        ))
 
     return main_mcp
 
@@ -193,26 +261,32 @@ def main():
         default=False,
         help="Enable write access mode (allow mutating operations)",
     )
+    parser.add_argument(
+        "--read-only",
+        action="store_true",
+        default=os.getenv("READ_ONLY", "").lower() in ("1", "true", "yes"),
+        help="Reject all mutating tools even if --allow-write is set (default: from env READ_ONLY or false)",
+    )
     parser.add_argument(
         "--transport",
         "-t",
         type=str,
         choices=["stdio", "sse", "http"],
-        default="stdio",
-        help="Transport method (default: stdio)"
+        default=os.getenv("MCP_TRANSPORT", "stdio"),
+        help="Transport method (default: from env MCP_TRANSPORT or stdio)"
     )
     parser.add_argument(
         "--host",
         type=str,
         default="localhost",
-        help="Host for SSE transport (default: localhost)"
+        help="Listen host for sse/http transport (default: localhost)"
     )
     parser.add_argument(
         "--port",
         "-p",
         type=int,
         default=8000,
-        help="Port for SSE transport (default: 8000)"
+        help="Listen port for sse/http transport (default: 8000)"
     )
     parser.add_argument(
         "--region",
@@ -233,13 +307,20 @@ def main():
     parser.add_argument(
         "--kubeconfig-mode",
         type=str,
-        choices=["ACK_PUBLIC", "ACK_PRIVATE", "INCLUSTER", "LOCAL"],
-        help="Mode to obtain kubeconfig for ACK clusters (default: from env KUBECONFIG_MODE)"
+        choices=["ACK_PUBLIC", "ACK_PRIVATE", "INCLUSTER", "LOCAL", "AUTO"],
+        help="Mode to obtain kubeconfig for ACK clusters; AUTO tries in-cluster service account config first and "
+             "falls back to the local kubeconfig file (default: from env KUBECONFIG_MODE)"
     )
     parser.add_argument(
         "--kubeconfig-path",
         type=str,
-        help="Path to local kubeconfig file when KUBECONFIG_MODE is LOCAL (default: from env KUBECONFIG_PATH)"
+        help="Path to local kubeconfig file when KUBECONFIG_MODE is LOCAL (default: from env KUBECONFIG_PATH, then KUBECONFIG, then ~/.kube/config)"
+    )
+    parser.add_argument(
+        "--kubeconfig-dir",
+        type=str,
+        help="Directory of preconfigured kubeconfig files named by cluster ID (<cluster_id>, <cluster_id>.yaml/.yml/.kubeconfig); "
+             "clusters without a file fall back to KUBECONFIG_MODE (default: from env KUBECONFIG_DIR)"
     )
     parser.add_argument(
         "--prometheus-endpoint-mode",
@@ -265,6 +346,124 @@ def main():
         default=os.environ.get("ALLOWED_ORIGINS", ""),
         help="Comma-separated list of allowed origins for Origin header validation (env: ALLOWED_ORIGINS)"
     )
+    parser.add_argument(
+        "--request-timeout",
+        type=int,
+        default=int(os.getenv("KUBECTL_REQUEST_TIMEOUT", "15")),
+        help="Timeout in seconds for a single read request to the API server; transient failures (throttling, "
+             "server timeouts, unavailable API server) are retried within the tool timeout "
+             "(env: KUBECTL_REQUEST_TIMEOUT, default: 15, 0 disables)"
+    )
+    parser.add_argument(
+        "--kubectl-qps",
+        type=float,
+        default=float(os.getenv("KUBECTL_QPS", str(DEFAULT_KUBECTL_QPS))),
+        help="Maximum kubectl requests per second to the API server, shared by all tools "
+             f"(env: KUBECTL_QPS, default: {DEFAULT_KUBECTL_QPS:g}, 0 disables)"
+    )
+    parser.add_argument(
+        "--kubectl-burst",
+        type=int,
+        default=int(os.getenv("KUBECTL_BURST", str(DEFAULT_KUBECTL_BURST))),
+        help=f"Maximum burst of kubectl requests above --kubectl-qps (env: KUBECTL_BURST, default: {DEFAULT_KUBECTL_BURST})"
+    )
+    parser.add_argument(
+        "--discovery-refresh-interval",
+        type=int,
+        default=int(os.getenv("DISCOVERY_REFRESH_INTERVAL", str(DEFAULT_DISCOVERY_REFRESH_INTERVAL))),
+        help="Seconds to cache API discovery results (kubectl api-resources) used to resolve CRDs and other "
+             "non-built-in resources, shared by all tools (env: DISCOVERY_REFRESH_INTERVAL, default: "
+             f"{DEFAULT_DISCOVERY_REFRESH_INTERVAL}, 0 disables)"
+    )
+    parser.add_argument(
+        "--max-concurrent-calls",
+        type=int,
+        default=int(os.getenv("MAX_CONCURRENT_CALLS", str(DEFAULT_MAX_CONCURRENT_CALLS))),
+        help="Maximum number of tool calls executing at the same time; further calls are rejected with a "
+             f"'server busy' error (env: MAX_CONCURRENT_CALLS, default: {DEFAULT_MAX_CONCURRENT_CALLS}, 0 disables)"
+    )
+    parser.add_argument(
+        "--max-response-bytes",
+        type=int,
+        default=int(os.getenv("MAX_RESPONSE_BYTES", str(DEFAULT_MAX_RESPONSE_BYTES))),
+        help="Maximum size of a serialized tool result; larger results are truncated at item boundaries with "
+             "metadata on the omitted items (env: MAX_RESPONSE_BYTES, default: "
+             f"{DEFAULT_MAX_RESPONSE_BYTES}, 0 disables)"
+    )
+    parser.add_argument(
+        "--shutdown-timeout",
+        type=int,
+        default=int(os.getenv("SHUTDOWN_TIMEOUT", str(DEFAULT_SHUTDOWN_TIMEOUT))),
+        help="Seconds to wait for in-flight tool calls on SIGTERM/SIGINT before cancelling them; streaming calls "
+             f"(watch, logs -f) are cancelled immediately (env: SHUTDOWN_TIMEOUT, default: {DEFAULT_SHUTDOWN_TIMEOUT})"
+    )
+    parser.add_argument(
+        "--default-namespace",
+        type=str,
+        default=os.environ.get("DEFAULT_NAMESPACE"),
+        help="Namespace used by tools when a single object is requested without a namespace (env: DEFAULT_NAMESPACE, "
+             "default: the pod's own namespace when running in-cluster, otherwise 'default')"
+    )
+    parser.add_argument(
+        "--allowed-namespaces",
+        type=str,
+        default=os.environ.get("ALLOWED_NAMESPACES", ""),
+        help="Comma-separated list of namespaces the tools may access; requests for other namespaces are rejected "
+             "and all-namespaces queries are limited to these namespaces (env: ALLOWED_NAMESPACES, default: no restriction)"
+    )
+    parser.add_argument(
+        "--allow-inline-kubeconfig",
+        action="store_true",
+        default=os.environ.get("ALLOW_INLINE_KUBECONFIG", "false").lower() == "true",
+        help="Accept a base64-encoded kubeconfig_base64 parameter on cluster tools and use it for that request only, "
+             "kept in memory and never cached, for multi-tenant gateways where the caller holds the credentials "
+             "(env: ALLOW_INLINE_KUBECONFIG, default: false)"
+    )
+    parser.add_argument(
+        "--allow-impersonation",
+        action="store_true",
+        default=os.environ.get("ALLOW_IMPERSONATION", "false").lower() == "true",
+        help="Accept impersonate_user/impersonate_groups parameters on kubectl-based tools and run kubectl with "
+             "--as/--as-group so RBAC is evaluated as that user; the server identity needs the impersonate "
+             "permission (env: ALLOW_IMPERSONATION, default: false)"
+    )
+    parser.add_argument(
+        "--log-format",
+        type=str,
+        choices=list(LOG_FORMATS),
+        default=os.environ.get("LOG_FORMAT", "text"),
+        help="Log output format; json writes one JSON object per line with request_id, tool, cluster_id, "
+             "namespace and latency_ms fields for log aggregators (env: LOG_FORMAT, default: text)"
+    )
+    parser.add_argument(
+        "--stateless-http",
+        action=argparse.BooleanOptionalAction,
+        default=os.environ.get("STATELESS_HTTP", "false").lower() == "true",
+        help="Run Streamable HTTP transport in stateless mode (no server-side MCP session), "
+             "required when running multiple replicas behind a load balancer without sticky sessions. "
+             "Only applies to --transport http (env: STATELESS_HTTP, default: false)"
+    )
+    parser.add_argument(
+        "--tls-cert",
+        type=str,
+        default=os.environ.get("TLS_CERT_FILE"),
+        help="TLS certificate file (PEM) to serve sse/http transport over HTTPS, requires --tls-key "
+             "(env: TLS_CERT_FILE, default: plain HTTP)"
+    )
+    parser.add_argument(
+        "--tls-key",
+        type=str,
+        default=os.environ.get("TLS_KEY_FILE"),
+        help="TLS private key file (PEM) matching --tls-cert (env: TLS_KEY_FILE)"
+    )
+    parser.add_argument(
+        "--auth-token",
+        type=str,
+        default=os.environ.get("MCP_AUTH_TOKEN"),
+        help="Require 'Authorization: Bearer <token>' on every sse/http request, otherwise respond 401; "
+             "/healthz and /readyz stay open. Prefer the env variable over the flag to keep the token out of "
+             "the process list (env: MCP_AUTH_TOKEN, default: no authentication)"
+    )
     parser.add_argument(
         "--version",
         "-v",
@@ -273,18 +472,38 @@ def main():
     )
     
     args = parser.parse_args()
+    # 默认值来自 MCP_TRANSPORT 环境变量时 argparse 不会校验 choices
+    if args.transport not in ("stdio", "sse", "http"):
+        parser.error(f"invalid MCP_TRANSPORT '{args.transport}' (choose from 'stdio', 'sse', 'http')")
+    if args.log_format not in LOG_FORMATS:
+        parser.error(f"invalid LOG_FORMAT '{args.log_format}' (choose from {', '.join(LOG_FORMATS)})")
+    if bool(args.tls_cert) != bool(args.tls_key):
+        parser.error("--tls-cert and --tls-key must be provided together")
+    for tls_file in (args.tls_cert, args.tls_key):
+        if tls_file and not os.path.isfile(tls_file):
+            parser.error(f"TLS file not found: {tls_file}")
+    try:
+        default_namespace = resolve_default_namespace(args.default_namespace)
+    except ValueError as e:
+        parser.error(f"--default-namespace: {e}")
     
-    # Configure logging
-    logger.remove()
-    logger.add(sys.stderr, level=os.getenv('FASTMCP_LOG_LEVEL', 'INFO'))
+    # Configure logging（日志统一输出到 stderr，避免 stdio 传输模式下污染 stdout 协议流）
+    configure_logging(os.getenv('FASTMCP_LOG_LEVEL', 'INFO'), args.log_format)
     
     # 构建完整的配置字典，优先级：命令行参数 > 环境变量 > 默认值
     settings_dict = {
         # 基本配置
-        "allow_write": args.allow_write,
+        "allow_write": args.allow_write and not args.read_only,
+        "default_namespace": default_namespace,
+        "allowed_namespaces": parse_allowed_namespaces(args.allowed_namespaces),
+        "allow_inline_kubeconfig": args.allow_inline_kubeconfig,
+        "allow_impersonation": args.allow_impersonation,
         "transport": args.transport,
         "host": args.host,
         "port": args.port,
+        "stateless_http": args.stateless_http,
+        "tls_enabled": bool(args.tls_cert),
+        "auth_enabled": bool(args.auth_token),
         
         # ExecutionLog 配置
         "enable_execution_log": args.enable_execution_log or os.getenv("ENABLE_EXECUTION_LOG", "false").lower() == "true",
@@ -308,6 +527,18 @@ def main():
         "diagnose_timeout": int(os.getenv("DIAGNOSE_TIMEOUT", "600")),  # 诊断超时时间（秒）
         "diagnose_poll_interval": int(os.getenv("DIAGNOSE_POLL_INTERVAL", "15")),  # 诊断轮询间隔（秒）
         "kubectl_timeout": int(os.getenv("KUBECTL_TIMEOUT", "30")),  # kubectl命令超时（秒）
+        "max_tool_timeout": int(os.getenv("MAX_TOOL_TIMEOUT", "600")),  # 工具 timeout_seconds 参数上限（秒）
+        "request_timeout": args.request_timeout,  # 只读请求单次访问 API Server 的超时（秒）
+        "kubectl_max_retries": int(os.getenv("KUBECTL_MAX_RETRIES", "2")),  # 临时性错误的重试次数
+        "kubectl_qps": args.kubectl_qps,  # kubectl 访问 API Server 的速率上限（次/秒）
+        "kubectl_burst": args.kubectl_burst,  # 允许的突发请求数
+        "discovery_refresh_interval": args.discovery_refresh_interval,  # API 发现结果的缓存时间（秒）
+        "max_concurrent_calls": args.max_concurrent_calls,  # 同时执行的工具调用数上限
+        "max_response_bytes": args.max_response_bytes,  # 单次工具结果序列化后的字节数上限
+        "shutdown_timeout": args.shutdown_timeout,  # 退出时等待进行中工具调用的时间（秒）
+
+        # kubectl_dns_check 临时 Pod 镜像（需包含 dig），为空时使用默认镜像
+        "dns_check_image": os.getenv("DNS_CHECK_IMAGE"),
         "api_timeout": int(os.getenv("API_TIMEOUT", "60")),  # API调用超时（秒）
         
         # 兼容性配置
@@ -316,7 +547,15 @@ def main():
 
         # ACK kubectl 配置
         "kubeconfig_mode": args.kubeconfig_mode or os.getenv("KUBECONFIG_MODE", "ACK_PUBLIC"),
-        "kubeconfig_path": args.kubeconfig_path or os.getenv("KUBECONFIG_PATH", "~/.kube/config"),
+        # KUBECONFIG 可能为多个路径，取第一个
+        "kubeconfig_path": (
+            args.kubeconfig_path
+            or os.getenv("KUBECONFIG_PATH")
+            or (os.getenv("KUBECONFIG") or "").split(os.pathsep)[0]
+            or "~/.kube/config"
+        ),
+        # 按集群ID预置的 kubeconfig 目录，未找到对应文件的集群按 kubeconfig_mode 获取
+        "kubeconfig_dir": args.kubeconfig_dir or os.getenv("KUBECONFIG_DIR"),
         
         # Prometheus 配置
         "prometheus_endpoint_mode": args.prometheus_endpoint_mode or os.getenv("PROMETHEUS_ENDPOINT_MODE", "ARMS_PUBLIC"),
@@ -330,14 +569,25 @@ def main():
 
     # Log startup info with configuration
     mode_info = []
-    if not args.allow_write:
+    if not settings_dict["allow_write"]:
         mode_info.append("read-only mode")
     if args.audit_config:
         mode_info.append("audit log enabled")
+    if settings_dict["allow_inline_kubeconfig"]:
+        mode_info.append("inline kubeconfig enabled")
+    if settings_dict["allow_impersonation"]:
+        mode_info.append("impersonation enabled")
+    if settings_dict["tls_enabled"]:
+        mode_info.append("TLS enabled")
+    if settings_dict["auth_enabled"]:
+        mode_info.append("bearer token auth enabled")
+    if settings_dict["allowed_namespaces"]:
+        mode_info.append(f"namespaces restricted to {', '.join(settings_dict['allowed_namespaces'])}")
 
     mode_str = " in " + ", ".join(mode_info) if mode_info else ""
     logger.info(f"Starting AlibabaCloud Container Service Main MCP Server{mode_str}")
     logger.info(f"Region: {settings_dict['region_id']}")
+    logger.info(f"Default namespace: {settings_dict['default_namespace']}")
 
     # 记录敏感信息（隐藏部分内容）
     if settings_dict.get('access_key_id'):
@@ -369,11 +619,32 @@ def main():
                     enable_dns_rebinding_protection=True,
                     allowed_origins=allowed_origins,
                 )))
-            logger.info(f"Server will be available at http://{args.host}:{args.port}")
+            run_kwargs: Dict[str, Any] = {}
+            if args.auth_token:
+                run_kwargs["middleware"] = [Middleware(BearerTokenAuthMiddleware, token=args.auth_token)]
+                logger.info("Bearer token authentication enabled")
+            else:
+                logger.warning("No --auth-token configured: the HTTP endpoint accepts unauthenticated requests, "
+                               "do not expose it beyond localhost")
+            if args.transport == "http":
+                # 无状态模式下每个请求独立处理，不依赖服务端会话，可多副本部署在负载均衡之后
+                run_kwargs["stateless_http"] = args.stateless_http
+                logger.info(f"Streamable HTTP session mode: {'stateless' if args.stateless_http else 'stateful'}")
+            elif args.stateless_http:
+                logger.warning("--stateless-http only applies to http transport, ignored for sse")
+            scheme = "https" if args.tls_cert else "http"
+            logger.info(f"Server will be available at {scheme}://{args.host}:{args.port}")
+            logger.info(f"Health probes: {scheme}://{args.host}:{args.port}/healthz, "
+                        f"{scheme}://{args.host}:{args.port}/readyz, metrics: {scheme}://{args.host}:{args.port}/metrics")
+            # 进行中的工具调用已在收到信号时排空，此处仅限制等待剩余连接（如 SSE 长连接）关闭的时间
+            run_kwargs["uvicorn_config"] = {"timeout_graceful_shutdown": SHUTDOWN_CONNECTION_TIMEOUT}
+            if args.tls_cert:
+                run_kwargs["uvicorn_config"].update(ssl_certfile=args.tls_cert, ssl_keyfile=args.tls_key)
             main_server.run(
                 transport=args.transport,
                 host=args.host,
                 port=args.port,
+                **run_kwargs,
             )
 
     except KeyboardInterrupt:
@@ -0,0 +1,71 @@
+"""工具调用的 Prometheus 指标。
+
+基于 prometheus_client，指标注册在其默认注册表 REGISTRY 中（同时包含进程与 Python 运行时指标），通过 /metrics 暴露。
+内置按工具名与结果统计的调用次数及耗时直方图，由 ToolMetricsMiddleware 统一采集；新工具可直接创建
+prometheus_client 的 Counter、Histogram 等指标，默认注册到同一注册表。
+"""
+
+import time
+from typing import Any, Optional
+
+import mcp.types as mt
+from fastmcp import FastMCP
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from prometheus_client import CONTENT_TYPE_LATEST, REGISTRY, CollectorRegistry, Counter, Histogram, generate_latest
+from starlette.requests import Request
+from starlette.responses import Response
+
+# 工具耗时直方图的默认分桶（秒），覆盖 kubectl 调用与长耗时诊断
+DEFAULT_BUCKETS = (0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0, 120.0, 300.0, 600.0)
+
+TOOL_CALLS = Counter(
+    "ack_mcp_tool_calls_total", "Total number of MCP tool calls by tool name and result", ("tool", "result"),
+)
+TOOL_DURATION = Histogram(
+    "ack_mcp_tool_duration_seconds", "MCP tool call duration in seconds", ("tool",), buckets=DEFAULT_BUCKETS,
+)
+
+
+def record_tool_call(tool: str, success: bool, duration_seconds: float):
+    """记录一次工具调用的结果与耗时"""
+    TOOL_CALLS.labels(tool=tool, result="success" if success else "error").inc()
+    TOOL_DURATION.labels(tool=tool).observe(duration_seconds)
+
+
+def tool_result_failed(result: Any) -> bool:
+    """工具以返回值中的 error 字段表示业务失败（而非抛出异常），据此判断调用结果"""
+    if getattr(result, "is_error", False) or getattr(result, "isError", False):
+        return True
+    structured = getattr(result, "structured_content", None)
+    if isinstance(structured, dict):
+        return bool(structured.get("error"))
+    return False
+
+
+class ToolMetricsMiddleware(Middleware):
+    """统计每次工具调用的次数、结果与耗时"""
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        tool = getattr(context.message, "name", None) or "unknown"
+        start = time.perf_counter()
+        try:
+            result = await call_next(context)
+        except Exception:
+            record_tool_call(tool, False, time.perf_counter() - start)
+            raise
+        record_tool_call(tool, not tool_result_failed(result), time.perf_counter() - start)
+        return result
+
+
+def register_metrics(server: FastMCP, registry: Optional[CollectorRegistry] = None):
+    """注册工具调用统计中间件及 /metrics 端点"""
+    registry = registry or REGISTRY
+    server.add_middleware(ToolMetricsMiddleware())
+
+    @server.custom_route("/metrics", methods=["GET"])
+    async def metrics(request: Request) -> Response:
+        return Response(generate_latest(registry), media_type=CONTENT_TYPE_LATEST)
@@ -15,6 +15,7 @@ class ExecutionLog(BaseModel):
     Provides comprehensive tracking of request flow, timing, and operational details.
     """
     tool_call_id: Optional[str] = Field(None, description="Unique identifier for tracking this tool call execution")
+    tool_name: Optional[str] = Field(None, description="Name of the invoked tool")
     start_time: Optional[str] = Field(None, description="Execution start time in ISO 8601 format")
     end_time: Optional[str] = Field(None, description="Execution end time in ISO 8601 format")
     duration_ms: Optional[int] = Field(None, description="Total execution duration in milliseconds")
@@ -29,6 +30,7 @@ class ExecutionLog(BaseModel):
         # Build complete log data with all fields
         log_data = {
             "tool_call_id": self.tool_call_id,
+            "tool_name": self.tool_name,
             "start_time": self.start_time,
             "end_time": self.end_time,
             "duration_ms": self.duration_ms,
@@ -210,6 +212,7 @@ class ClusterInfo(BaseModel):
     region_id: str = Field(..., description="集群所在的region")
     cluster_type: str = Field(..., description="集群的类型，ManagedKubernetes（托管集群）、Kubernetes（专有版集群）")
     current_version: Optional[str] = Field(None, description="集群k8s版本")
+    node_count: Optional[int] = Field(None, description="集群节点数")
     vpc_id: Optional[str] = Field(None, description="集群专有网络 ID")
     vswitch_ids: List[str] = Field(default_factory=list, description="控制面虚拟交换机")
     resource_group_id: Optional[str] = Field(None, description="资源组id")
@@ -493,3 +496,772 @@ class WorkloadAutoscalingAnalysisOutput(BaseOutputModel):
     resource_analysis: List[WorkloadResourceProfile] = Field(default_factory=list, description="各资源维度的特征分析结果列表，包含基础属性、百分位统计、波动性判定")
     hpa_recommendation: Optional[HPARecommendation] = Field(None, description="HPA 配置推荐，如果为 null 则不建议开启 HPA")
     error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== Ingress TLS 相关模型 ====================
+
+class IngressTLSSecretStatus(BaseModel):
+    """Ingress 引用的单个 TLS Secret 检查结果"""
+    secret_name: Optional[str] = Field(None, description="TLS Secret 名称，为空表示使用 Ingress Controller 默认证书")
+    hosts: List[str] = Field(default_factory=list, description="该 TLS 条目声明的主机名")
+    exists: bool = Field(False, description="Secret 是否存在")
+    status: str = Field(..., description="证书状态：OK、Expiring、Expired、Missing、Invalid、DefaultCertificate")
+    subject_cn: Optional[str] = Field(None, description="叶子证书 Subject CN")
+    issuer_cn: Optional[str] = Field(None, description="叶子证书 Issuer CN")
+    dns_names: List[str] = Field(default_factory=list, description="叶子证书 SAN 列表")
+    not_before: Optional[str] = Field(None, description="证书生效时间")
+    not_after: Optional[str] = Field(None, description="证书过期时间")
+    days_until_expiry: Optional[int] = Field(None, description="距离过期剩余天数，已过期为负数")
+    uncovered_hosts: List[str] = Field(default_factory=list, description="未被证书 SAN 覆盖的主机名")
+    message: Optional[str] = Field(None, description="补充说明")
+
+
+class IngressTLSInfo(BaseModel):
+    """单个 Ingress 的 TLS 配置摘要"""
+    namespace: str = Field(..., description="命名空间")
+    name: str = Field(..., description="Ingress 名称")
+    ingress_class: Optional[str] = Field(None, description="IngressClass 名称")
+    tls: List[IngressTLSSecretStatus] = Field(default_factory=list, description="TLS 条目检查结果")
+
+
+class IngressTLSSummaryOutput(BaseOutputModel):
+    """Ingress TLS 证书来源汇总输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: Optional[str] = Field(None, description="查询的命名空间，为空表示全部命名空间")
+    ingresses: List[IngressTLSInfo] = Field(default_factory=list, description="配置了 TLS 的 Ingress 列表")
+    count: int = Field(0, description="配置了 TLS 的 Ingress 数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 节点负载均衡相关模型 ====================
+
+class NodeBalanceEntry(BaseModel):
+    """单个节点的 Pod 分布与资源承诺情况"""
+    name: str = Field(..., description="节点名称")
+    schedulable: bool = Field(True, description="节点是否可调度（未被 cordon）")
+    pod_count: int = Field(0, description="节点上运行中（非 Succeeded/Failed）的 Pod 数量")
+    max_pods: int = Field(0, description="节点可分配的最大 Pod 数（status.allocatable.pods）")
+    pod_utilization: float = Field(0.0, description="Pod 数占比：pod_count / max_pods")
+    cpu_requests: str = Field("0m", description="节点上 Pod 的 CPU requests 总和")
+    cpu_allocatable: str = Field("0m", description="节点可分配 CPU")
+    cpu_request_ratio: float = Field(0.0, description="CPU requests 占可分配 CPU 的比例")
+    memory_requests: str = Field("0", description="节点上 Pod 的内存 requests 总和")
+    memory_allocatable: str = Field("0", description="节点可分配内存")
+    memory_request_ratio: float = Field(0.0, description="内存 requests 占可分配内存的比例")
+    flags: List[str] = Field(default_factory=list, description="节点标记：NearMaxPods、NearlyEmpty、HighCommitment、Unschedulable")
+
+
+class NodeBalanceSummary(BaseModel):
+    """节点负载均衡汇总"""
+    node_count: int = Field(0, description="参与统计的可调度节点数")
+    total_pods: int = Field(0, description="参与统计节点上的 Pod 总数")
+    avg_pod_utilization: float = Field(0.0, description="平均 Pod 数占比")
+    pod_utilization_spread: float = Field(0.0, description="Pod 数占比最大值与最小值之差")
+    cpu_request_ratio_spread: float = Field(0.0, description="CPU requests 比例最大值与最小值之差")
+    memory_request_ratio_spread: float = Field(0.0, description="内存 requests 比例最大值与最小值之差")
+    imbalanced: bool = Field(False, description="是否存在明显的不均衡")
+
+
+class NodeBalanceOutput(BaseOutputModel):
+    """节点 Pod 分布均衡分析输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    nodes: List[NodeBalanceEntry] = Field(default_factory=list, description="各节点分布情况，按 Pod 数占比降序")
+    summary: Optional[NodeBalanceSummary] = Field(None, description="汇总统计")
+    suggestions: List[str] = Field(default_factory=list, description="再平衡建议")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 临时存储风险相关模型 ====================
+
+class EphemeralStorageNode(BaseModel):
+    """单个节点的临时存储（ephemeral-storage）使用与承诺情况"""
+    name: str = Field(..., description="节点名称")
+    disk_pressure: bool = Field(False, description="节点 DiskPressure 状况是否为 True")
+    capacity: str = Field("0", description="节点 ephemeral-storage 容量")
+    allocatable: str = Field("0", description="节点可分配 ephemeral-storage")
+    used: Optional[str] = Field(None, description="节点根文件系统已用空间（来自 kubelet stats/summary），无法获取时为空")
+    usage_percent: Optional[float] = Field(None, description="节点根文件系统使用率（%）")
+    requests: str = Field("0", description="节点上 Pod 的 ephemeral-storage requests 总和")
+    request_ratio: float = Field(0.0, description="ephemeral-storage requests 占可分配量的比例")
+    pod_count: int = Field(0, description="节点上运行中的 Pod 数量")
+    pods_without_limit: int = Field(0, description="未设置 ephemeral-storage limit 的 Pod 数量")
+    at_risk: bool = Field(False, description="节点是否接近或处于 DiskPressure")
+    reasons: List[str] = Field(default_factory=list, description="判定为风险节点的原因")
+
+
+class EphemeralStoragePodRisk(BaseModel):
+    """风险节点上未限制临时存储的 Pod"""
+    name: str = Field(..., description="Pod 名称")
+    namespace: str = Field(..., description="命名空间")
+    node: str = Field(..., description="所在节点")
+    requests: str = Field("0", description="ephemeral-storage requests")
+    used: Optional[str] = Field(None, description="Pod 当前临时存储用量（来自 kubelet stats/summary）")
+    containers_without_limit: List[str] = Field(default_factory=list, description="未设置 ephemeral-storage limit 的容器")
+    unbounded_empty_dirs: List[str] = Field(default_factory=list, description="未设置 sizeLimit 的磁盘型 emptyDir 卷")
+
+
+class EphemeralStorageRiskOutput(BaseOutputModel):
+    """临时存储压力风险分析输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    nodes: List[EphemeralStorageNode] = Field(default_factory=list, description="各节点临时存储情况，风险节点在前")
+    risky_pods: List[EphemeralStoragePodRisk] = Field(default_factory=list, description="风险节点上未限制临时存储的 Pod")
+    total_pods_without_limit: int = Field(0, description="全部节点上未设置 ephemeral-storage limit 的 Pod 数量")
+    suggestions: List[str] = Field(default_factory=list, description="优化建议")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 容器磁盘使用相关模型 ====================
+
+class ContainerFilesystemUsage(BaseModel):
+    """容器内单个文件系统的使用情况"""
+    filesystem: str = Field(..., description="文件系统/设备名")
+    mount_point: str = Field(..., description="挂载点")
+    size: str = Field(..., description="总容量")
+    used: str = Field(..., description="已使用")
+    available: str = Field(..., description="可用")
+    use_percent: float = Field(..., description="使用率（%）")
+    size_bytes: int = Field(0, description="总容量（字节）")
+    used_bytes: int = Field(0, description="已使用（字节）")
+    available_bytes: int = Field(0, description="可用（字节）")
+
+
+class ContainerDiskUsageOutput(BaseOutputModel):
+    """容器文件系统磁盘使用输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    pod: str = Field(..., description="Pod 名称")
+    container: Optional[str] = Field(None, description="容器名称，为空表示 Pod 默认容器")
+    filesystems: List[ContainerFilesystemUsage] = Field(default_factory=list, description="文件系统使用情况")
+    warnings: List[str] = Field(default_factory=list, description="使用率超过阈值的挂载点提示")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== ACK 组件状态相关模型 ====================
+
+class AddonWorkloadStatus(BaseModel):
+    """组件工作负载状态"""
+    kind: str = Field(..., description="工作负载类型：Deployment 或 DaemonSet")
+    name: str = Field(..., description="工作负载名称")
+    desired: int = Field(0, description="期望副本数")
+    ready: int = Field(0, description="就绪副本数")
+    updated: int = Field(0, description="已更新到最新版本的副本数")
+
+
+class AddonStatus(BaseModel):
+    """单个 ACK 托管组件的运行状态"""
+    name: str = Field(..., description="组件名称")
+    status: str = Field(..., description="组件状态：Healthy、Degraded、Unavailable、NotInstalled")
+    workloads: List[AddonWorkloadStatus] = Field(default_factory=list, description="组件对应的工作负载")
+    unhealthy_pods: List[str] = Field(default_factory=list, description="未就绪 Pod 及原因")
+    restarts: int = Field(0, description="组件 Pod 容器重启次数总和")
+    warning_events: List[str] = Field(default_factory=list, description="近期 Warning 事件")
+    recent_errors: List[str] = Field(default_factory=list, description="近期日志中的错误行")
+
+
+class AddonStatusOutput(BaseOutputModel):
+    """ACK 托管组件运行状态输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="组件所在命名空间")
+    addons: List[AddonStatus] = Field(default_factory=list, description="组件状态列表")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 资源查询相关模型 ====================
+
+class KubectlGetOutput(BaseOutputModel):
+    """资源查询输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型（复数形式）")
+    namespace: Optional[str] = Field(None, description="查询的命名空间，为空表示全部命名空间或集群级资源")
+    items: List[Dict[str, Any]] = Field(default_factory=list, description="资源摘要列表")
+    yaml: Optional[str] = Field(None, description="output=yaml 时返回的完整对象 YAML（已去除 managedFields），export=true 时为可重新 apply 的清单")
+    table: Optional[str] = Field(None, description="output=wide 或 custom-columns 时返回的表格文本")
+    count: int = Field(0, description="返回的资源数量")
+    filtered_out: int = Field(0, description="被 min_age/max_age/created_after/created_before 过滤掉的对象数量")
+    has_more: bool = Field(False, description="是否还有下一页，为 true 时使用 continue_token 继续查询")
+    continue_token: Optional[str] = Field(None, description="下一页的 continue token")
+    remaining_item_count: Optional[int] = Field(None, description="剩余对象数量（API Server 估算值，可能为空）")
+    reference_time: Optional[str] = Field(None, description="计算存活时间所用的参考时间")
+    reference_time_source: Optional[str] = Field(None, description="参考时间来源：server（API Server 时间）或 local（本地时间）")
+    warnings: List[str] = Field(default_factory=list, description="查询提示，如被忽略的参数")
+    partial: bool = Field(False, description="是否为部分结果：逐个命名空间查询时部分命名空间无权访问或查询失败")
+    namespace_errors: Dict[str, str] = Field(default_factory=dict, description="逐个命名空间查询时失败的命名空间及原因")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class MultiGetItem(BaseModel):
+    """批量查询中的单个查询"""
+    label: str = Field(..., description="调用方指定的标识，作为结果的键，批量内不能重复")
+    resource: str = Field(..., description="资源类型，如 pods、deployments、svc")
+    namespace: Optional[str] = Field(None, description="命名空间，为空或 all 表示全部命名空间，指定 name 时为空表示服务的默认命名空间")
+    name: Optional[str] = Field(None, description="资源名称，为空表示列出全部")
+    label_selector: Optional[str] = Field(None, description="标签选择器，如 app=nginx")
+    api_version: Optional[str] = Field(None, description="资源的 apiVersion，用于区分不同 API 组下的同名资源")
+
+
+class KubectlMultiGetOutput(BaseOutputModel):
+    """批量资源查询输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    results: Dict[str, KubectlGetOutput] = Field(default_factory=dict, description="各查询的结果，键为查询的 label；单个查询失败时其 error 字段非空")
+    succeeded: int = Field(0, description="成功的查询数量")
+    failed: int = Field(0, description="失败的查询数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class ListNamespacesOutput(BaseOutputModel):
+    """命名空间列表输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespaces: List[Dict[str, Any]] = Field(default_factory=list, description="命名空间列表：名称、状态（Active/Terminating）、创建时间、存活时间、标签")
+    count: int = Field(0, description="命名空间数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+class ListCRDsOutput(BaseOutputModel):
+    """CustomResourceDefinition 列表输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    group: Optional[str] = Field(None, description="API 组过滤条件，支持通配符（如 *.aliyun.com）")
+    crds: List[Dict[str, Any]] = Field(default_factory=list, description="CRD 列表：name、group、kind、plural、short_names、scope、versions（served/storage）、storage_version、established")
+    count: int = Field(0, description="返回的 CRD 数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+class ClusterSummaryOutput(BaseOutputModel):
+    """集群概览输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    server_version: Optional[str] = Field(None, description="Kubernetes 版本（kube-apiserver /version 的 gitVersion）")
+    nodes: Dict[str, int] = Field(default_factory=dict, description="节点数量：total、ready、not_ready、unschedulable")
+    pods: Dict[str, int] = Field(default_factory=dict, description="Pod 数量：total 及按阶段统计的 Running、Pending、Succeeded、Failed、Unknown")
+    deployments: Dict[str, int] = Field(default_factory=dict, description="Deployment 数量：total、available（可用副本数达到期望值）、unavailable")
+    namespaces: int = Field(0, description="命名空间数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+class KubectlEventsOutput(BaseOutputModel):
+    """事件列表输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: Optional[str] = Field(None, description="查询的命名空间，为空表示全部命名空间")
+    type: Optional[str] = Field(None, description="事件类型过滤：Normal 或 Warning")
+    events: List[Dict[str, Any]] = Field(default_factory=list, description="按最近发生时间倒序排列的事件：time、type、reason、object、message、count")
+    count: int = Field(0, description="返回的事件数量")
+    total: int = Field(0, description="过滤后的事件总数（超出 limit 的部分未返回）")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+class KubectlDescribeOutput(BaseOutputModel):
+    """资源详情（describe）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型（复数形式）")
+    name: Optional[str] = Field(None, description="资源名称")
+    namespace: Optional[str] = Field(None, description="命名空间，集群级资源为空")
+    text: str = Field("", description="对象摘要与相关事件合并后的可读文本")
+    event_count: int = Field(0, description="相关事件数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class ExplainField(BaseModel):
+    """资源字段说明"""
+    name: str = Field(..., description="字段名")
+    type: str = Field(..., description="类型，如 string、[]Container、map[string]string、PodSpec、Object")
+    description: Optional[str] = Field(None, description="字段说明")
+    required: bool = Field(False, description="是否为必填字段")
+
+
+class KubectlExplainOutput(BaseOutputModel):
+    """资源字段说明（explain）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型（复数形式）")
+    kind: Optional[str] = Field(None, description="资源 Kind")
+    api_version: Optional[str] = Field(None, description="资源的 apiVersion")
+    field_path: Optional[str] = Field(None, description="字段路径，如 spec.containers，为空表示资源本身")
+    type: Optional[str] = Field(None, description="字段类型")
+    description: Optional[str] = Field(None, description="字段说明")
+    required: Optional[bool] = Field(None, description="字段在其所属对象中是否必填，资源本身为空")
+    fields: List[ExplainField] = Field(default_factory=list, description="子字段，列表与 map 类型为其元素的字段")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlOwnerTreeOutput(BaseOutputModel):
+    """属主关系树输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="查询对象的资源类型（复数形式）")
+    name: str = Field(..., description="查询对象的名称")
+    namespace: Optional[str] = Field(None, description="命名空间，集群级资源为空")
+    owners: List[Dict[str, Any]] = Field(default_factory=list, description="沿 ownerReferences 向上的属主链，由近及远：kind、name、namespace、status")
+    tree: Optional[Dict[str, Any]] = Field(None, description="以最顶层属主为根的属主关系树：kind、name、namespace、status、children，查询对象标记 target=true，子对象过多时以 more_children 表示未列出的数量")
+    warnings: List[str] = Field(default_factory=list, description="无法解析或已不存在的属主等提示")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+
+class KubectlTopOutput(BaseOutputModel):
+    """节点/Pod 资源用量（metrics.k8s.io）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型：nodes 或 pods")
+    namespace: Optional[str] = Field(None, description="查询的命名空间，为空表示全部命名空间或节点")
+    sort_by: Optional[str] = Field(None, description="排序字段：cpu 或 memory")
+    items: List[Dict[str, Any]] = Field(default_factory=list, description="资源用量列表")
+    count: int = Field(0, description="返回的对象数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlWatchOutput(BaseOutputModel):
+    """资源变更监听（watch）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型（复数形式）")
+    namespace: Optional[str] = Field(None, description="监听的命名空间，为空表示全部命名空间或集群级资源")
+    name: Optional[str] = Field(None, description="监听的资源名称，为空表示全部对象")
+    timeout_seconds: int = Field(0, description="实际监听时长（秒）")
+    events: List[Dict[str, Any]] = Field(default_factory=list, description="按接收顺序排列的变更事件（type 为 ADDED/MODIFIED/DELETED）及对象摘要")
+    event_count: int = Field(0, description="接收到的事件总数（包含超出返回上限未返回的事件）")
+    truncated: bool = Field(False, description="事件数超过返回上限，仅返回前面的部分")
+    closed_reason: Optional[str] = Field(None, description="监听结束原因：timeout（达到监听时长）或 closed（服务端关闭）")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+# ==================== 资源变更相关模型 ====================
+
+class KubectlApplyOutput(BaseOutputModel):
+    """清单 apply（server-side apply）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    dry_run: bool = Field(False, description="是否为服务端 dry-run，为 true 时未实际持久化")
+    results: List[Dict[str, Any]] = Field(default_factory=list, description="按清单顺序排列的各对象 apply 结果：kind、name、namespace、status（applied/failed）、error")
+    applied: int = Field(0, description="apply 成功的对象数量")
+    failed: int = Field(0, description="apply 失败的对象数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlDiffOutput(BaseOutputModel):
+    """清单与实际状态对比输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    results: List[Dict[str, Any]] = Field(default_factory=list, description="按清单顺序排列的各对象对比结果：kind、name、namespace、status（create/update/unchanged/failed）、diff、error")
+    diff: str = Field("", description="所有对象的 unified diff（实际状态 -> 清单）")
+    changed: int = Field(0, description="将被创建或修改的对象数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+class KubectlRolloutOutput(BaseOutputModel):
+    """Deployment 发布状态查询、滚动重启与等待发布完成输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    action: str = Field(..., description="操作：status、restart 或 wait")
+    name: str = Field(..., description="Deployment 名称")
+    namespace: Optional[str] = Field(None, description="命名空间")
+    state: Optional[str] = Field(None, description="发布状态：complete（完成）、progressing（进行中）或 stuck（超过 progressDeadlineSeconds 未完成）")
+    message: Optional[str] = Field(None, description="发布状态说明")
+    paused: bool = Field(False, description="发布是否已暂停")
+    desired_replicas: int = Field(0, description="期望副本数")
+    updated_replicas: int = Field(0, description="已更新到最新模板的副本数")
+    ready_replicas: int = Field(0, description="就绪副本数")
+    available_replicas: int = Field(0, description="可用副本数")
+    conditions: List[Dict[str, Any]] = Field(default_factory=list, description="Progressing/Available 条件：type、status、reason、message、last_update_time")
+    restarted_at: Optional[str] = Field(None, description="restart 时写入 kubectl.kubernetes.io/restartedAt 注解的时间")
+    timed_out: Optional[bool] = Field(None, description="wait 时是否在发布完成前超时")
+    waited_seconds: Optional[float] = Field(None, description="wait 实际等待的时长（秒）")
+    blocking_condition: Optional[str] = Field(None, description="wait 结束时发布仍未完成的原因，如 ReplicaFailure=True (FailedCreate): ...")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlNodeOutput(BaseOutputModel):
+    """节点维护（cordon/uncordon/drain）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    node: str = Field(..., description="节点名称")
+    action: str = Field(..., description="操作：cordon、uncordon 或 drain")
+    unschedulable: Optional[bool] = Field(None, description="操作后节点是否不可调度")
+    evicted: List[Dict[str, Any]] = Field(default_factory=list, description="drain 时已驱逐的 Pod：namespace、name")
+    skipped: List[Dict[str, Any]] = Field(default_factory=list, description="drain 时跳过的 Pod 及原因（DaemonSet、镜像 Pod、未受控制器管理、使用 emptyDir 等）")
+    failed: List[Dict[str, Any]] = Field(default_factory=list, description="drain 时驱逐失败的 Pod 及原因（如超时前仍被 PodDisruptionBudget 阻止）")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+
+class KubectlDeleteOutput(BaseOutputModel):
+    """资源删除输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型")
+    name: str = Field(..., description="资源名称")
+    namespace: Optional[str] = Field(None, description="命名空间，集群级资源为空")
+    dry_run: bool = Field(False, description="是否为服务端 dry-run，为 true 时未实际删除")
+    status: Optional[str] = Field(None, description="删除结果：deleted（已删除）、terminating（等待 finalizer 处理）或 dry_run（预览）")
+    deletion_timestamp: Optional[str] = Field(None, description="删除时间（对象仍在终止中时为其 metadata.deletionTimestamp）")
+    finalizers: List[str] = Field(default_factory=list, description="阻止对象被移除的 finalizer（status=terminating 时）")
+    message: Optional[str] = Field(None, description="结果说明，dry-run 时为将要删除的对象")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlMetadataOutput(BaseOutputModel):
+    """标签/注解修改输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型")
+    name: str = Field(..., description="资源名称")
+    namespace: Optional[str] = Field(None, description="命名空间，集群级资源为空")
+    action: str = Field(..., description="操作类型：label 或 annotate")
+    changed: List[str] = Field(default_factory=list, description="实际设置或删除的键，值未变化的键不计入")
+    labels: Dict[str, str] = Field(default_factory=dict, description="修改后的 metadata.labels")
+    annotations: Dict[str, str] = Field(default_factory=dict, description="修改后的 metadata.annotations")
+    warnings: List[str] = Field(default_factory=list, description="提示，如要删除的键不存在")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlPatchOutput(BaseOutputModel):
+    """资源 patch 输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    resource: str = Field(..., description="资源类型")
+    name: str = Field(..., description="资源名称")
+    namespace: Optional[str] = Field(None, description="命名空间，集群级资源为空")
+    patch_type: str = Field(..., description="patch 类型：json、merge 或 strategic")
+    dry_run: bool = Field(False, description="是否为服务端 dry-run，为 true 时未实际修改")
+    changed: Optional[bool] = Field(None, description="对象是否被修改（resourceVersion 是否变化），dry-run 时为空")
+    object: Optional[Dict[str, Any]] = Field(None, description="patch 后的完整对象，Secret 的 data 已脱敏")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class KubectlExecOutput(BaseOutputModel):
+    """容器内命令执行输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    pod: str = Field(..., description="Pod 名称")
+    container: Optional[str] = Field(None, description="执行命令的容器")
+    command: List[str] = Field(default_factory=list, description="执行的命令及参数")
+    exit_code: Optional[int] = Field(None, description="容器内命令的退出码")
+    output: str = Field("", description="命令输出（stdout 在前，stderr 在后）")
+    truncated: bool = Field(False, description="输出超过上限时为 true，仅保留开头部分")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+# ==================== 工作负载导出相关模型 ====================
+
+class ExportBundleOutput(BaseOutputModel):
+    """工作负载及其依赖对象的导出结果"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    workload: str = Field(..., description="工作负载，格式为 kind/name")
+    objects: List[str] = Field(default_factory=list, description="导出的对象，格式为 kind/name，按 apply 顺序排列")
+    missing: List[str] = Field(default_factory=list, description="被引用但不存在的对象")
+    warnings: List[str] = Field(default_factory=list, description="导出提示，如 Secret 内容已脱敏")
+    manifest: str = Field("", description="多文档 YAML，可直接 kubectl apply -f")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 工作负载权限相关模型 ====================
+
+class EffectivePermission(BaseModel):
+    """工作负载通过某个绑定获得的一条权限规则"""
+    scope: str = Field(..., description="生效范围：命名空间名称，或 cluster 表示集群级")
+    api_groups: List[str] = Field(default_factory=list, description="API 组，空字符串表示 core 组")
+    resources: List[str] = Field(default_factory=list, description="资源类型")
+    resource_names: List[str] = Field(default_factory=list, description="限定的资源名称，为空表示不限")
+    non_resource_urls: List[str] = Field(default_factory=list, description="非资源 URL")
+    verbs: List[str] = Field(default_factory=list, description="允许的操作")
+    granted_by: str = Field(..., description="授权来源：绑定 -> 角色")
+
+
+class WorkloadPermissionsOutput(BaseOutputModel):
+    """工作负载有效 RBAC 权限输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    workload: str = Field(..., description="工作负载，格式为 kind/name")
+    service_account: Optional[str] = Field(None, description="工作负载使用的 ServiceAccount")
+    token_automount: bool = Field(True, description="是否自动挂载 ServiceAccount token")
+    bindings: List[str] = Field(default_factory=list, description="授予该 ServiceAccount 的绑定")
+    permissions: List[EffectivePermission] = Field(default_factory=list, description="有效权限规则")
+    summary: Dict[str, List[str]] = Field(default_factory=dict, description="按 范围:组/资源 汇总的允许操作")
+    risks: List[str] = Field(default_factory=list, description="高风险权限提示")
+    missing_roles: List[str] = Field(default_factory=list, description="绑定引用但不存在的角色")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 跨命名空间引用相关模型 ====================
+
+class CrossNamespaceReference(BaseModel):
+    """一条无法按预期生效的跨命名空间引用"""
+    source: str = Field(..., description="引用方，格式为 kind/namespace/name")
+    field: str = Field(..., description="引用所在字段")
+    target_kind: str = Field(..., description="被引用对象类型")
+    target_name: str = Field(..., description="被引用对象名称")
+    issue: str = Field(..., description="问题类型：ExplicitCrossNamespace、FoundInOtherNamespace、NamespaceQualifiedName")
+    candidate_namespaces: List[str] = Field(default_factory=list, description="同名对象实际所在的命名空间")
+    message: str = Field(..., description="问题说明")
+
+
+class CrossNamespaceReferencesOutput(BaseOutputModel):
+    """跨命名空间引用检查输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: Optional[str] = Field(None, description="检查的命名空间，为空表示全部命名空间")
+    scanned_objects: int = Field(0, description="检查的对象数量")
+    references: List[CrossNamespaceReference] = Field(default_factory=list, description="发现的问题引用")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 节点版本偏差相关模型 ====================
+
+class NodePoolVersionSkew(BaseModel):
+    """单个节点池的 kubelet 版本与控制面版本偏差"""
+    nodepool_id: Optional[str] = Field(None, description="节点池 ID，为空表示不属于任何节点池的节点")
+    name: Optional[str] = Field(None, description="节点池名称（来自 CS DescribeClusterNodePools）")
+    state: Optional[str] = Field(None, description="节点池状态（来自 CS DescribeClusterNodePools）")
+    node_count: int = Field(0, description="Kubernetes 中观察到的节点数")
+    kubelet_versions: Dict[str, int] = Field(default_factory=dict, description="kubelet 版本 -> 节点数")
+    oldest_version: Optional[str] = Field(None, description="节点池中最旧的 kubelet 版本")
+    minor_skew: int = Field(0, description="最旧 kubelet 落后控制面的次版本数（负数表示比控制面新）")
+    status: str = Field("OK", description="OK、Behind（落后但在支持范围内）、UnsupportedSkew（超出支持的偏差）、NewerThanControlPlane")
+    lagging_nodes: List[str] = Field(default_factory=list, description="版本落后于控制面的节点")
+    mixed_versions: bool = Field(False, description="节点池内是否存在多个 kubelet 版本（升级中或升级未完成）")
+
+
+class VersionSkewOutput(BaseOutputModel):
+    """节点池与控制面版本偏差输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    control_plane_version: Optional[str] = Field(None, description="kube-apiserver 版本（来自 /version）")
+    cluster_version: Optional[str] = Field(None, description="CS DescribeClusterDetail 返回的集群版本")
+    max_supported_skew: int = Field(0, description="kubelet 允许落后控制面的最大次版本数")
+    nodepools: List[NodePoolVersionSkew] = Field(default_factory=list, description="各节点池版本情况，偏差大的在前")
+    issues: List[str] = Field(default_factory=list, description="发现的问题及建议")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 抢占式实例风险相关模型 ====================
+
+class SpotNode(BaseModel):
+    """抢占式（Spot）实例节点"""
+    name: str = Field(..., description="节点名称")
+    instance_type: Optional[str] = Field(None, description="实例规格（node.kubernetes.io/instance-type）")
+    spot_signal: str = Field(..., description="判定为抢占式实例的标签")
+    reclaim_pending: bool = Field(False, description="是否已收到回收/中断通知")
+    reclaim_signals: List[str] = Field(default_factory=list, description="回收通知来源（节点状况、污点或事件）")
+    pod_count: int = Field(0, description="节点上运行的非 DaemonSet Pod 数量")
+
+
+class SpotWorkloadRisk(BaseModel):
+    """运行在抢占式实例上的工作负载"""
+    namespace: str = Field(..., description="命名空间")
+    kind: str = Field(..., description="工作负载类型")
+    name: str = Field(..., description="工作负载名称")
+    replicas: int = Field(0, description="运行中的副本数（全部节点）")
+    spot_replicas: int = Field(0, description="运行在抢占式实例上的副本数")
+    spot_nodes: List[str] = Field(default_factory=list, description="所在的抢占式实例节点")
+    pdbs: List[str] = Field(default_factory=list, description="覆盖该工作负载的 PodDisruptionBudget")
+    flags: List[str] = Field(default_factory=list, description="风险标记：AllReplicasOnSpot、SingleReplica、NoPDB、OnReclaimingNode")
+    risk: str = Field("Low", description="风险等级：High、Medium、Low")
+
+
+class SpotRiskOutput(BaseOutputModel):
+    """抢占式实例回收风险输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    spot_nodes: List[SpotNode] = Field(default_factory=list, description="抢占式实例节点")
+    workloads: List[SpotWorkloadRisk] = Field(default_factory=list, description="运行在抢占式实例上的工作负载，风险高的在前")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 日志量相关模型 ====================
+
+class LogRateEntry(BaseModel):
+    """单个容器在采样窗口内的日志输出量"""
+    pod: str = Field(..., description="Pod 名称")
+    container: str = Field(..., description="容器名称")
+    bytes: int = Field(0, description="采样窗口内输出的字节数")
+    lines: int = Field(0, description="采样窗口内输出的行数")
+    bytes_per_second: float = Field(0.0, description="每秒字节数")
+    lines_per_second: float = Field(0.0, description="每秒行数")
+    estimated_daily: str = Field("0", description="按当前速率估算的每日日志量")
+    error: Optional[str] = Field(None, description="采样失败原因")
+
+
+class LogRateOutput(BaseOutputModel):
+    """容器日志输出速率采样结果"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    sample_seconds: int = Field(0, description="采样时长（秒）")
+    entries: List[LogRateEntry] = Field(default_factory=list, description="各容器日志速率，按字节速率降序")
+    total_bytes_per_second: float = Field(0.0, description="所有容器合计每秒字节数")
+    estimated_daily_total: str = Field("0", description="按当前速率估算的每日日志总量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 镜像可拉取性相关模型 ====================
+
+class ImagePullCheck(BaseModel):
+    """单个镜像的可拉取性检查结果"""
+    image: str = Field(..., description="镜像地址")
+    registry: str = Field(..., description="镜像仓库地址")
+    used_by: List[str] = Field(default_factory=list, description="引用该镜像的位置，格式为 kind/name[container]")
+    status: str = Field(..., description="Pullable、NotFound、Unauthorized、RateLimited、Unreachable、Error")
+    http_status: Optional[int] = Field(None, description="manifest 接口返回的 HTTP 状态码")
+    digest: Optional[str] = Field(None, description="镜像 manifest digest")
+    auth: str = Field("anonymous", description="使用的凭据：imagePullSecret 名称或 anonymous")
+    message: Optional[str] = Field(None, description="说明")
+
+
+class ImagePullabilityOutput(BaseOutputModel):
+    """镜像可拉取性检查输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    images: List[ImagePullCheck] = Field(default_factory=list, description="各镜像检查结果，不可拉取的在前")
+    summary: Dict[str, int] = Field(default_factory=dict, description="按状态统计的镜像数量")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 准入拒绝相关模型 ====================
+
+class AdmissionDenialGroup(BaseModel):
+    """同一拒绝方（webhook/策略/配额）造成的准入拒绝汇总"""
+    category: str = Field(..., description="类别：WebhookDenied、WebhookFailure、ValidatingAdmissionPolicy、ResourceQuota、PodSecurity、LimitRange、ServiceAccount、Forbidden")
+    policy: str = Field(..., description="拒绝方名称：webhook 名称、策略名称、ResourceQuota 名称等")
+    count: int = Field(0, description="拒绝次数（累加事件 count）")
+    objects: List[str] = Field(default_factory=list, description="受影响的对象，格式为 namespace/kind/name")
+    last_seen: Optional[str] = Field(None, description="最近一次发生时间")
+    sample_message: str = Field("", description="示例事件消息")
+
+
+class AdmissionDenialsOutput(BaseOutputModel):
+    """准入拒绝事件汇总输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: Optional[str] = Field(None, description="命名空间，为空表示全部命名空间")
+    total_events: int = Field(0, description="识别为准入拒绝的事件数")
+    groups: List[AdmissionDenialGroup] = Field(default_factory=list, description="按拒绝方分组的汇总，次数多的在前")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 日志归档相关模型 ====================
+
+class KubectlLogsOutput(BaseOutputModel):
+    """单个容器日志输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    pod: str = Field(..., description="Pod 名称")
+    container: Optional[str] = Field(None, description="容器名称")
+    previous: bool = Field(False, description="是否为上一次运行（重启前）容器的日志")
+    tail_lines: int = Field(0, description="实际请求的日志行数")
+    logs: str = Field("", description="日志内容")
+    line_count: int = Field(0, description="返回的日志行数")
+    truncated: bool = Field(False, description="日志超过字节上限，仅保留末尾部分")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+class LogArchiveOutput(BaseOutputModel):
+    """多 Pod 日志归档输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    label_selector: str = Field(..., description="Pod 标签选择器")
+    archive_id: Optional[str] = Field(None, description="归档 ID")
+    resource_uri: Optional[str] = Field(None, description="通过 MCP resource 读取 gzip 压缩 tar 归档的 URI")
+    size_bytes: int = Field(0, description="归档大小（字节）")
+    files: List[str] = Field(default_factory=list, description="归档中的日志文件，格式为 <pod>/<container>.log")
+    failed: List[str] = Field(default_factory=list, description="日志读取失败的容器及原因")
+    expires_at: Optional[str] = Field(None, description="归档过期时间，过期后 resource 不可读取")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== DNS 检查相关模型 ====================
+
+class DnsRecord(BaseModel):
+    """DNS 解析记录"""
+    name: str = Field(..., description="记录名称")
+    ttl: Optional[int] = Field(None, description="TTL（秒），nslookup 结果中不可用")
+    type: str = Field(..., description="记录类型，如 A、AAAA、CNAME、SRV")
+    data: str = Field(..., description="记录值")
+
+
+class DnsCheckOutput(BaseOutputModel):
+    """集群内 DNS 解析检查输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    hostname: str = Field(..., description="查询的主机名")
+    record_type: str = Field("A", description="查询的记录类型")
+    source: Optional[str] = Field(None, description="执行查询的 Pod，格式为 namespace/name")
+    tool: Optional[str] = Field(None, description="使用的查询工具：dig 或 nslookup")
+    status: Optional[str] = Field(None, description="查询状态，如 NOERROR、NXDOMAIN、SERVFAIL、TIMEOUT")
+    server: Optional[str] = Field(None, description="响应的 DNS 服务器")
+    latency_ms: Optional[int] = Field(None, description="DNS 查询耗时（毫秒），仅 dig 提供")
+    records: List[DnsRecord] = Field(default_factory=list, description="解析结果")
+    raw_output: Optional[str] = Field(None, description="查询工具的原始输出")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 服务端点相关模型 ====================
+
+class ServiceEndpoint(BaseModel):
+    """Service 的后端端点"""
+    address: str = Field(..., description="端点地址（Pod IP）")
+    ready: bool = Field(..., description="是否就绪（就绪的端点才会接收流量）")
+    serving: Optional[bool] = Field(None, description="是否可以提供服务（终止中的 Pod 可能仍为 true）")
+    terminating: Optional[bool] = Field(None, description="Pod 是否正在终止")
+    pod: Optional[str] = Field(None, description="端点对应的 Pod 名称")
+    node: Optional[str] = Field(None, description="Pod 所在节点")
+    zone: Optional[str] = Field(None, description="所在可用区")
+    pod_problem: Optional[str] = Field(None, description="未就绪 Pod 的原因，如 CrashLoopBackOff、NotReady")
+    source: Optional[str] = Field(None, description="来源对象，如 EndpointSlice/web-abc12")
+
+
+class ServiceEndpointsOutput(BaseOutputModel):
+    """Service 端点查询输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: str = Field(..., description="命名空间")
+    service: str = Field(..., description="Service 名称")
+    service_type: Optional[str] = Field(None, description="Service 类型，如 ClusterIP、LoadBalancer、ExternalName")
+    selector: Dict[str, str] = Field(default_factory=dict, description="Service 的 Pod 选择器，为空表示端点由外部维护")
+    ports: List[Dict[str, Any]] = Field(default_factory=list, description="Service 端口：name、port、target_port、protocol")
+    endpoint_ports: List[Dict[str, Any]] = Field(default_factory=list, description="端点端口（targetPort 解析后的容器端口）：name、port、protocol")
+    endpoints: List[ServiceEndpoint] = Field(default_factory=list, description="端点列表，就绪的在前")
+    ready_count: int = Field(0, description="就绪端点数量")
+    not_ready_count: int = Field(0, description="未就绪端点数量")
+    matching_pods: Optional[int] = Field(None, description="选择器匹配的 Pod 数量（无选择器时为空）")
+    status: Optional[str] = Field(None, description="Ready、NoReadyEndpoints、NoEndpoints 或 ExternalName")
+    message: Optional[str] = Field(None, description="端点状态说明，没有就绪端点时给出可能的原因")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== 异常 Pod 诊断相关模型 ====================
+
+class ContainerProblem(BaseModel):
+    """异常容器"""
+    name: str = Field(..., description="容器名称")
+    init_container: bool = Field(False, description="是否为 init 容器")
+    problem: str = Field(..., description="问题概括：当前异常原因（如 CrashLoopBackOff、ImagePullBackOff），其次为上次终止的 OOMKilled，仅重启次数过多时为 HighRestarts")
+    state: Optional[str] = Field(None, description="当前状态：waiting、running 或 terminated")
+    reason: Optional[str] = Field(None, description="当前状态的原因，如 CrashLoopBackOff、ImagePullBackOff、Error")
+    message: Optional[str] = Field(None, description="当前状态的说明，如镜像拉取失败的详细信息")
+    ready: bool = Field(False, description="容器是否就绪")
+    restart_count: int = Field(0, description="重启次数")
+    last_termination_reason: Optional[str] = Field(None, description="上次终止的原因（lastState.terminated.reason），如 OOMKilled、Error")
+    last_exit_code: Optional[int] = Field(None, description="上次终止的退出码")
+    last_finished_at: Optional[str] = Field(None, description="上次终止的时间")
+    exit_code_hint: Optional[str] = Field(None, description="退出码的常见含义，如 137 表示被 SIGKILL（内存超限或存活探针失败）")
+    severity: int = Field(0, description="严重程度：4 CrashLoopBackOff/OOMKilled，3 镜像或容器创建失败，2 非零退出，1 重启次数过多")
+
+
+class UnhealthyPod(BaseModel):
+    """存在异常容器的 Pod"""
+    namespace: str = Field(..., description="命名空间")
+    name: str = Field(..., description="Pod 名称")
+    phase: Optional[str] = Field(None, description="Pod 阶段")
+    node: Optional[str] = Field(None, description="所在节点")
+    owner: Optional[str] = Field(None, description="所属工作负载，如 Deployment/web")
+    problem: Optional[str] = Field(None, description="最严重的容器问题，如 CrashLoopBackOff、OOMKilled、HighRestarts")
+    severity: int = Field(0, description="容器中最高的严重程度")
+    restart_count: int = Field(0, description="所有容器的重启次数之和")
+    containers: List[ContainerProblem] = Field(default_factory=list, description="异常容器，最严重的在前")
+
+
+class UnhealthyPodsOutput(BaseOutputModel):
+    """异常 Pod 诊断输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    namespace: Optional[str] = Field(None, description="命名空间，为空表示全部命名空间")
+    label_selector: Optional[str] = Field(None, description="标签选择器")
+    restart_threshold: int = Field(..., description="视为重启过多的重启次数阈值")
+    scanned: int = Field(0, description="检查的 Pod 数量")
+    pods: List[UnhealthyPod] = Field(default_factory=list, description="异常 Pod，最严重的在前")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
+
+
+# ==================== Webhook 变更相关模型 ====================
+
+class ObjectChange(BaseModel):
+    """对象字段变更"""
+    path: str = Field(..., description="字段路径，列表元素按 name 或下标标识，如 spec.containers[istio-proxy]")
+    op: str = Field(..., description="变更类型：added、removed、changed")
+    before: Optional[Any] = Field(None, description="变更前的值")
+    after: Optional[Any] = Field(None, description="变更后的值")
+
+
+class WebhookMutationOutput(BaseOutputModel):
+    """Webhook 变更（dry-run 对比）输出"""
+    cluster_id: str = Field(..., description="集群 ID")
+    kind: Optional[str] = Field(None, description="提交 dry-run 的对象类型")
+    name: Optional[str] = Field(None, description="对象名称")
+    namespace: Optional[str] = Field(None, description="命名空间")
+    mutating_webhooks: List[str] = Field(default_factory=list, description="集群中的 MutatingWebhookConfiguration 列表")
+    changes: List[ObjectChange] = Field(default_factory=list, description="提交对象与 dry-run 返回对象之间的差异")
+    ignored_default_changes: int = Field(0, description="被忽略的内置默认值填充数量")
+    annotations_added: Dict[str, Any] = Field(default_factory=dict, description="新增的注解（常见于 sidecar 注入器的状态标记）")
+    error: Optional[ErrorModel] = Field(None, description="错误信息")
@@ -0,0 +1,463 @@
+"""命名空间白名单。
+
+通过 --allowed-namespaces 将服务限制在指定命名空间内：NamespaceAllowlistMiddleware 在工具调用前统一解析每次调用
+实际访问的命名空间并校验，白名单之外的命名空间直接拒绝，无法确定命名空间的调用默认拒绝：
+- namespace 参数为空时按工具的实际行为解析：使用服务默认命名空间（--default-namespace）的工具校验默认命名空间，
+  支持全部命名空间查询的工具展开为白名单内的命名空间并发查询后合并结果，其余跨命名空间的调用直接拒绝
+- kubectl_delete、kubectl_patch、kubectl_metadata 仅允许修改命名空间级资源（白名单内的 Namespace 对象除外）
+- kubectl_apply、kubectl_diff 等提交清单的工具校验清单中每个对象的命名空间，不允许集群级对象
+- 内置资源注册表之外的类型（如 CRD）通过 API 发现解析作用域，无法解析的类型不允许修改或提交
+- ack_kubectl 命令须通过 -n/--namespace 指定白名单内的命名空间，不允许 -A/--all-namespaces 与 --raw
+- kubectl_multi_get 逐项校验 namespace，读取集群对象资源 URI 时校验其中的命名空间
+"""
+
+import asyncio
+import json
+import shlex
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Sequence, Tuple, Type
+
+import mcp.types as mt
+import yaml
+from fastmcp.exceptions import ResourceError, ToolError
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from fastmcp.tools.tool import ToolResult
+from loguru import logger
+
+from impersonation import is_kubectl_tool
+from kubectl_resource_handler import DEFAULT_EVENT_LIMIT, DEFAULT_LIST_LIMIT, MAX_WATCH_EVENTS, TOP_SORT_FIELDS
+from kubectl_resources import ALL_NAMESPACES, DEFAULT_NAMESPACE, find_resource_spec, parse_object_uri
+
+# 解析资源类型是否为命名空间级：(ctx, cluster_id, 资源类型或 Kind, api_version, kubeconfig context) -> 无法解析时为 None
+NamespacedResolver = Callable[[Any, Optional[str], str, Optional[str], Optional[str]], Awaitable[Optional[bool]]]
+
+# namespace 为空或 all 时查询全部命名空间的工具，白名单模式下展开为白名单内的命名空间
+ALL_NAMESPACE_TOOLS = {
+    "kubectl_get",
+    "kubectl_events",
+    "kubectl_top",
+    "kubectl_watch",
+    "kubectl_ingress_tls",
+    "kubectl_cross_namespace_refs",
+    "kubectl_spot_risk",
+    "kubectl_admission_denials",
+    "kubectl_unhealthy_pods",
+}
+
+# namespace 为空时使用服务默认命名空间（--default-namespace）的工具
+DEFAULT_NAMESPACE_TOOLS = {
+    "kubectl_describe",
+    "kubectl_owner_tree",
+    "kubectl_delete",
+    "kubectl_metadata",
+    "kubectl_patch",
+    "kubectl_dns_check",
+}
+
+# namespace 为空时使用固定命名空间的工具
+TOOL_DEFAULT_NAMESPACES = {"kubectl_addon_status": "kube-system"}
+
+# 修改或删除单个对象的工具，仅允许命名空间级资源
+MUTATING_TOOLS = {"kubectl_delete", "kubectl_patch", "kubectl_metadata"}
+
+# 提交清单的工具，按清单中各对象的命名空间校验
+MANIFEST_TOOLS = {"kubectl_apply", "kubectl_diff", "kubectl_webhook_mutations"}
+
+# 不读取命名空间内对象的集群级工具
+CLUSTER_TOOLS = {"list_namespaces", "list_crds", "kubectl_explain", "kubectl_version_skew", "kubectl_node"}
+
+# 不通过 kubectl 访问集群、但 namespace 为空时查询全部命名空间的工具
+NAMESPACE_REQUIRED_TOOLS = {"query_audit_log"}
+
+# 常见的集群级对象类型，清单中出现时直接拒绝，无需 API 发现（内置资源注册表之外的类型）
+CLUSTER_SCOPED_KINDS = {
+    "APIService",
+    "CertificateSigningRequest",
+    "ClusterRole",
+    "ClusterRoleBinding",
+    "CSIDriver",
+    "CustomResourceDefinition",
+    "IngressClass",
+    "MutatingWebhookConfiguration",
+    "PriorityClass",
+    "RuntimeClass",
+    "StorageClass",
+    "ValidatingAdmissionPolicy",
+    "ValidatingAdmissionPolicyBinding",
+    "ValidatingWebhookConfiguration",
+    "VolumeAttachment",
+}
+
+# 合并展开查询的结果时不相加的数值字段（回显的调用参数），取第一个命名空间的值
+NON_ADDITIVE_FIELDS = {"timeout_seconds", "restart_threshold"}
+
+
+def parse_allowed_namespaces(value: Optional[str]) -> List[str]:
+    """解析逗号分隔的命名空间列表，去除空白与重复项"""
+    namespaces: List[str] = []
+    for item in (value or "").split(","):
+        item = item.strip()
+        if item and item not in namespaces:
+            namespaces.append(item)
+    return namespaces
+
+
+def _command_tokens(command: str) -> List[str]:
+    try:
+        return shlex.split(command)
+    except ValueError:
+        return command.split()
+
+
+def command_namespaces(command: str) -> Tuple[List[str], bool]:
+    """提取 kubectl 命令中指定的命名空间，返回 (命名空间列表, 是否查询全部命名空间)"""
+    tokens = _command_tokens(command)
+    namespaces: List[str] = []
+    all_namespaces = False
+    for i, token in enumerate(tokens):
+        if token in ("-A", "--all-namespaces") or token.startswith("--all-namespaces="):
+            all_namespaces = all_namespaces or token.split("=", 1)[-1].lower() != "false"
+        elif token in ("-n", "--namespace") and i + 1 < len(tokens):
+            namespaces.append(tokens[i + 1])
+        elif token.startswith("--namespace="):
+            namespaces.append(token.split("=", 1)[1])
+        elif token.startswith("-n") and len(token) > 2 and not token.startswith("--"):
+            namespaces.append(token[2:].lstrip("="))
+    return namespaces, all_namespaces
+
+
+def command_uses_raw(command: str) -> bool:
+    """kubectl 命令是否通过 --raw 直接访问 API 路径（路径中的命名空间无法可靠校验）"""
+    return any(token == "--raw" or token.startswith("--raw=") for token in _command_tokens(command))
+
+
+def manifest_objects(manifest: str) -> List[Dict[str, Any]]:
+    """解析 YAML/JSON 清单中的对象（支持多文档及 List 类型）
+
+    Raises:
+        ValueError: 清单不合法
+    """
+    try:
+        documents = [doc for doc in yaml.safe_load_all(manifest or "") if doc is not None]
+    except yaml.YAMLError as e:
+        raise ValueError(f"invalid manifest: {e}")
+    objects: List[Any] = []
+    for doc in documents:
+        if isinstance(doc, dict) and str(doc.get("kind") or "").endswith("List") and isinstance(doc.get("items"), list):
+            objects.extend(doc["items"])
+        else:
+            objects.append(doc)
+    if not all(isinstance(obj, dict) for obj in objects):
+        raise ValueError("invalid manifest: every document must be an object")
+    return objects
+
+
+def merge_structured_results(results: Sequence[Dict[str, Any]]) -> Dict[str, Any]:
+    """合并各命名空间的结构化结果：列表拼接、计数相加、布尔值取或，其余字段取第一个非空值
+
+    error 仅在所有命名空间均失败时保留（如按名称查询的对象只存在于其中一个命名空间）
+    """
+    merged: Dict[str, Any] = {}
+    for result in results:
+        for key, value in result.items():
+            if key == "error":
+                continue
+            current = merged.get(key)
+            if key not in merged or current is None:
+                merged[key] = list(value) if isinstance(value, list) else value
+            elif isinstance(current, list) and isinstance(value, list):
+                current.extend(value)
+            elif isinstance(current, bool) and isinstance(value, bool):
+                merged[key] = current or value
+            elif isinstance(current, (int, float)) and isinstance(value, (int, float)) and key not in NON_ADDITIVE_FIELDS:
+                merged[key] = current + value
+    if any("error" in result for result in results):
+        errors = [result.get("error") for result in results]
+        merged["error"] = errors[0] if all(errors) else None
+    return merged
+
+
+def finalize_expanded_result(tool: str, arguments: Dict[str, Any], merged: Dict[str, Any]) -> Dict[str, Any]:
+    """恢复合并结果的排序与数量上限：各命名空间的结果分别排序、截断，合并后按工具重新排序并截断"""
+    if tool == "kubectl_get" and isinstance(merged.get("items"), list):
+        items = sorted(merged["items"], key=lambda item: (item.get("namespace") or "", item.get("name") or ""))
+        limit = arguments.get("limit", DEFAULT_LIST_LIMIT)
+        if isinstance(limit, int) and limit > 0 and len(items) > limit:
+            items = items[:limit]
+            merged["has_more"] = True
+        merged["items"] = items
+        merged["count"] = len(items)
+        # 各命名空间的 continue_token 无法合并为一个
+        if merged.get("has_more"):
+            merged["warnings"] = [*(merged.get("warnings") or []), (
+                "已合并白名单内各命名空间的结果，无法使用 continue_token 翻页，请指定 namespace 逐个命名空间查询其余对象"
+            )]
+        merged["continue_token"] = None
+        merged["remaining_item_count"] = None
+    elif tool == "kubectl_events" and isinstance(merged.get("events"), list):
+        limit = arguments.get("limit")
+        limit = limit if isinstance(limit, int) and limit > 0 else DEFAULT_EVENT_LIMIT
+        events = sorted(merged["events"], key=lambda event: event.get("time") or "", reverse=True)
+        merged["events"] = events[:limit]
+        merged["count"] = len(merged["events"])
+    elif tool == "kubectl_top" and isinstance(merged.get("items"), list):
+        sort_field = TOP_SORT_FIELDS.get(str(arguments.get("sort_by") or ""))
+        if sort_field:
+            merged["items"].sort(key=lambda item: item.get(sort_field) or 0, reverse=True)
+        else:
+            merged["items"].sort(key=lambda item: (item.get("namespace") or "", item.get("name") or ""))
+    elif tool == "kubectl_unhealthy_pods" and isinstance(merged.get("pods"), list):
+        merged["pods"].sort(key=lambda pod: (
+            -(pod.get("severity") or 0), -(pod.get("restart_count") or 0), pod.get("namespace") or "", pod.get("name") or "",
+        ))
+    elif tool == "kubectl_watch" and isinstance(merged.get("events"), list) and len(merged["events"]) > MAX_WATCH_EVENTS:
+        merged["events"] = merged["events"][:MAX_WATCH_EVENTS]
+        merged["truncated"] = True
+    return merged
+
+
+class NamespaceAllowlistMiddleware(Middleware):
+    """将工具调用限制在白名单命名空间内"""
+
+    def __init__(
+        self,
+        allowed_namespaces: Sequence[str],
+        default_namespace: Optional[str] = None,
+        resolve_namespaced: Optional[NamespacedResolver] = None,
+    ):
+        self.allowed_namespaces = list(allowed_namespaces)
+        # 工具未指定命名空间时使用的服务默认命名空间，与 --default-namespace 一致
+        self.default_namespace = default_namespace or DEFAULT_NAMESPACE
+        # 通过 API 发现解析内置注册表之外的资源类型（如 CRD）的作用域，未提供时这些类型视为无法解析
+        self.resolve_namespaced = resolve_namespaced
+
+    def check(self, namespace: str, error_type: Type[Exception] = ToolError):
+        """命名空间不在白名单内时抛出 ToolError（读取资源时为 ResourceError）"""
+        if namespace not in self.allowed_namespaces:
+            raise error_type(
+                f"namespace {namespace} is not permitted; allowed namespaces: {', '.join(self.allowed_namespaces)}"
+            )
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        tool = context.message.name
+        arguments = dict(context.message.arguments or {})
+
+        if tool == "ack_kubectl":
+            self._check_command(str(arguments.get("command") or ""))
+            return await call_next(context)
+
+        if tool == "kubectl_multi_get":
+            # 批量查询的命名空间在各项中指定，逐项校验；跨命名空间查询需逐项指定白名单内的命名空间
+            for item in arguments.get("items") or []:
+                item = item if isinstance(item, dict) else {}
+                namespace = _normalize(item.get("namespace"))
+                if namespace and namespace.lower() not in ALL_NAMESPACES:
+                    self.check(namespace)
+                elif not await self._cluster_scoped(context, arguments, item):
+                    raise ToolError(
+                        f"item {item.get('label')!r} must specify a namespace; "
+                        f"allowed namespaces: {', '.join(self.allowed_namespaces)}"
+                    )
+                self._check_namespace_object(item)
+            return await call_next(context)
+
+        if tool in MANIFEST_TOOLS and arguments.get("manifest"):
+            await self._check_manifest(context, arguments)
+            return await call_next(context)
+
+        if tool == "kubectl_node" and str(arguments.get("action") or "").strip().lower() == "drain":
+            raise ToolError(
+                f"draining a node evicts pods in all namespaces and is not permitted; "
+                f"allowed namespaces: {', '.join(self.allowed_namespaces)}"
+            )
+
+        if tool == "diagnose_resource":
+            self._check_diagnose_target(arguments.get("resource_target"))
+        if tool in MUTATING_TOOLS:
+            await self._check_mutation_target(context, arguments)
+        # kubectl_get 逐个命名空间查询时指定的命名空间
+        for namespace in arguments.get("namespaces") or []:
+            self.check(str(namespace).strip())
+
+        self._check_namespace_object(arguments)
+        namespace = _normalize(arguments.get("namespace"))
+        if namespace and namespace.lower() not in ALL_NAMESPACES:
+            self.check(namespace)
+            return await call_next(context)
+        if not is_kubectl_tool(tool) and tool not in NAMESPACE_REQUIRED_TOOLS:
+            # 基于阿里云 OpenAPI 的工具不通过 kubectl 读取命名空间内的对象
+            return await call_next(context)
+        if tool in CLUSTER_TOOLS or await self._cluster_scoped(context, arguments, arguments):
+            return await call_next(context)
+        if not namespace:
+            # kubectl_get 按名称查询时同样使用服务默认命名空间
+            uses_default = tool in DEFAULT_NAMESPACE_TOOLS or (tool == "kubectl_get" and arguments.get("name"))
+            default = self.default_namespace if uses_default else TOOL_DEFAULT_NAMESPACES.get(tool)
+            if default:
+                self.check(default)
+                return await call_next(context)
+        if tool not in ALL_NAMESPACE_TOOLS:
+            raise ToolError(
+                f"{tool} requires a namespace; calls without a namespace or across all namespaces are not permitted. "
+                f"Allowed namespaces: {', '.join(self.allowed_namespaces)}"
+            )
+
+        if tool == "kubectl_get" and arguments.get("continue_token") and len(self.allowed_namespaces) > 1:
+            raise ToolError(
+                "continue_token cannot be used when the query is expanded to the allowed namespaces; "
+                f"specify one of the allowed namespaces to page through its objects: {', '.join(self.allowed_namespaces)}"
+            )
+
+        # 全部命名空间的查询展开为白名单内的命名空间并发查询，kubectl_watch 各命名空间同时开始、同时结束监听
+        logger.debug(f"Expanding all-namespaces call of {tool} to {self.allowed_namespaces}")
+        calls = [
+            asyncio.ensure_future(call_next(context.copy(
+                message=context.message.model_copy(update={"arguments": {**arguments, "namespace": allowed}})
+            )))
+            for allowed in self.allowed_namespaces
+        ]
+        try:
+            results = await asyncio.gather(*calls)
+        finally:
+            # 任一命名空间失败或调用被取消时停止其余查询
+            for pending in calls:
+                pending.cancel()
+        if len(results) == 1:
+            return results[0]
+        structured = [getattr(r, "structured_content", None) for r in results]
+        if not all(isinstance(s, dict) for s in structured):
+            return ToolResult(content=[c for r in results for c in (r.content or [])])
+        merged = finalize_expanded_result(tool, arguments, merge_structured_results(structured))
+        if "namespace" in merged:
+            merged["namespace"] = ",".join(self.allowed_namespaces)
+        return ToolResult(
+            content=json.dumps(merged, ensure_ascii=False, default=str),
+            structured_content=merged,
+        )
+
+    async def on_read_resource(
+        self,
+        context: MiddlewareContext[mt.ReadResourceRequestParams],
+        call_next: CallNext[mt.ReadResourceRequestParams, Any],
+    ) -> Any:
+        target = parse_object_uri(str(getattr(context.message, "uri", "")))
+        if target:
+            self.check(target["namespace"], ResourceError)
+        return await call_next(context)
+
+    def _check_command(self, command: str):
+        """ack_kubectl 命令须显式指定白名单内的命名空间"""
+        namespaces, all_namespaces = command_namespaces(command)
+        allowed = ", ".join(self.allowed_namespaces)
+        if all_namespaces:
+            raise ToolError(
+                f"--all-namespaces is not permitted; allowed namespaces: {allowed}. "
+                f"Run the command with -n <namespace> for each namespace"
+            )
+        if command_uses_raw(command):
+            raise ToolError(f"--raw is not permitted; allowed namespaces: {allowed}")
+        if not namespaces:
+            raise ToolError(f"the command must specify the namespace with -n <namespace>; allowed namespaces: {allowed}")
+        for namespace in namespaces:
+            self.check(namespace)
+
+    async def _check_manifest(self, context: MiddlewareContext, arguments: Dict[str, Any]):
+        """清单中的每个对象须位于白名单内的命名空间（metadata.namespace 或 namespace 参数），不允许集群级对象及无法解析的类型"""
+        allowed = ", ".join(self.allowed_namespaces)
+        namespace = _normalize(arguments.get("namespace"))
+        try:
+            objects = manifest_objects(str(arguments["manifest"]))
+        except ValueError as e:
+            raise ToolError(str(e))
+        if namespace:
+            self.check(namespace)
+        scopes: Dict[Tuple[str, str], Optional[bool]] = {}
+        for obj in objects:
+            kind = str(obj.get("kind") or "")
+            api_version = str(obj.get("apiVersion") or "")
+            metadata = obj.get("metadata") if isinstance(obj.get("metadata"), dict) else {}
+            label = f"{kind}/{metadata.get('name') or metadata.get('generateName') or ''}"
+            if kind not in CLUSTER_SCOPED_KINDS and (kind, api_version) not in scopes:
+                scopes[(kind, api_version)] = await self._namespaced(context, arguments, kind, api_version)
+            namespaced = False if kind in CLUSTER_SCOPED_KINDS else scopes[(kind, api_version)]
+            if namespaced is None:
+                raise ToolError(
+                    f"cannot determine whether {label} ({api_version or 'no apiVersion'}) is namespaced, "
+                    f"the kind is not served by the cluster; allowed namespaces: {allowed}"
+                )
+            if not namespaced:
+                raise ToolError(f"cluster-scoped object {label} is not permitted; allowed namespaces: {allowed}")
+            target = _normalize(metadata.get("namespace")) or namespace
+            if not target:
+                raise ToolError(
+                    f"{label} must specify metadata.namespace or the namespace argument; allowed namespaces: {allowed}"
+                )
+            self.check(target)
+
+    async def _check_mutation_target(self, context: MiddlewareContext, arguments: Dict[str, Any]):
+        """修改、删除的对象须为命名空间级资源；Namespace 对象由 _check_namespace_object 按名称校验"""
+        resource = str(arguments.get("resource") or "")
+        spec = find_resource_spec(resource)
+        if spec is not None and spec.kind == "Namespace" and not arguments.get("api_version"):
+            return
+        namespaced = await self._namespaced(context, arguments, resource, arguments.get("api_version"))
+        allowed = ", ".join(self.allowed_namespaces)
+        if namespaced is None:
+            raise ToolError(
+                f"cannot determine whether {resource} is namespaced, resources the cluster does not serve "
+                f"cannot be modified; allowed namespaces: {allowed}"
+            )
+        if not namespaced:
+            raise ToolError(f"cluster-scoped resource {resource} is not permitted; allowed namespaces: {allowed}")
+
+    def _check_diagnose_target(self, target: Any):
+        """诊断 Pod、Service 时校验 resource_target 中的命名空间"""
+        if isinstance(target, str):
+            try:
+                target = json.loads(target)
+            except ValueError:
+                return
+        namespace = _normalize(target.get("namespace")) if isinstance(target, dict) else None
+        if namespace:
+            self.check(namespace)
+
+    def _check_namespace_object(self, arguments: Dict[str, Any]):
+        """按名称访问 Namespace 对象（如删除、修改标签）时校验该命名空间"""
+        spec = find_resource_spec(str(arguments.get("resource") or arguments.get("resource_type") or ""))
+        name = _normalize(arguments.get("name"))
+        if spec is not None and spec.kind == "Namespace" and name:
+            self.check(name)
+
+    async def _cluster_scoped(
+        self, context: MiddlewareContext, arguments: Dict[str, Any], target: Dict[str, Any]
+    ) -> bool:
+        """集群级资源（如 nodes、clusterroles）的查询与命名空间无关，无需校验或展开"""
+        resource = str(target.get("resource") or target.get("resource_type") or "")
+        if not resource:
+            return False
+        return await self._namespaced(context, arguments, resource, target.get("api_version")) is False
+
+    async def _namespaced(
+        self, context: MiddlewareContext, arguments: Dict[str, Any], resource: str, api_version: Any = None
+    ) -> Optional[bool]:
+        """资源类型是否为命名空间级：内置注册表中的类型直接判断，其余类型通过 API 发现解析，无法解析时返回 None"""
+        api_version = api_version if isinstance(api_version, str) and api_version else None
+        spec = find_resource_spec(resource)
+        if spec is not None and api_version in (None, spec.api_version):
+            return spec.namespaced
+        if self.resolve_namespaced is None or not resource:
+            return None
+        return await self.resolve_namespaced(
+            getattr(context, "fastmcp_context", None),
+            _normalize(arguments.get("cluster_id")),
+            resource,
+            api_version,
+            _normalize(arguments.get("context")),
+        )
+
+
+def _normalize(namespace: Any) -> Optional[str]:
+    return namespace.strip() or None if isinstance(namespace, str) else None
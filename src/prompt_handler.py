@@ -0,0 +1,79 @@
+"""Prompt Handler - 常见诊断流程的 MCP 提示词模板."""
+
+from typing import Any, Dict, Optional
+
+from fastmcp import FastMCP
+from loguru import logger
+from pydantic import Field
+
+CRASHLOOPING_POD_PROMPT = """\
+请诊断集群 {cluster_id} 中命名空间 {namespace} 下反复重启（CrashLoopBackOff）的 Pod {pod}，按以下步骤调用工具：
+
+1. 调用 kubectl_get（cluster_id={cluster_id}, resource=pods, name={pod}, namespace={namespace}, output=yaml），查看各容器的 \
+restartCount，以及 state/lastState 中的 reason（如 Error、OOMKilled、ContainerCannotRun）与 exitCode
+2. 调用 kubectl_logs（cluster_id={cluster_id}, namespace={namespace}, name={pod}, previous=true），读取上一次崩溃前的日志，\
+定位退出前的错误；多容器 Pod 需通过 container 参数指定重启的容器
+3. 调用 kubectl_describe（cluster_id={cluster_id}, resource=pods, name={pod}, namespace={namespace}），查看 Pod 事件，\
+如存活探针失败、镜像拉取失败、卷挂载失败
+4. 若 reason 为 OOMKilled，调用 kubectl_top（cluster_id={cluster_id}, resource=pods, namespace={namespace}）对比实际内存用量与 limits
+
+最后给出结论：直接原因（附日志或事件原文）、根因判断及修复建议。不要在未确认根因前执行重启、删除等写操作。
+"""
+
+DEPLOYMENT_NOT_READY_PROMPT = """\
+请排查集群 {cluster_id} 中命名空间 {namespace} 下的 Deployment {deployment} 为什么没有就绪，按以下步骤调用工具：
+
+1. 调用 kubectl_rollout（cluster_id={cluster_id}, name={deployment}, namespace={namespace}, action=status），\
+查看发布进度、updated/available 副本数及 Progressing/Available 条件（如 ProgressDeadlineExceeded）
+2. 调用 kubectl_events（cluster_id={cluster_id}, namespace={namespace}, warnings_only=true），关注 ReplicaSet 的 \
+FailedCreate（如资源配额不足、准入 Webhook 拒绝）及 Pod 的调度失败（FailedScheduling）、探针失败等事件
+3. 调用 kubectl_owner_tree（cluster_id={cluster_id}, resource=deployments, name={deployment}, namespace={namespace}），\
+列出新旧 ReplicaSet 及其 Pod，找出未就绪的 Pod
+4. 对未就绪的 Pod 调用 kubectl_describe（resource=pods）查看事件与条件；容器反复重启时调用 kubectl_logs（previous=true）读取崩溃前的日志
+
+最后给出结论：未就绪的直接原因（附事件或日志原文）、受影响的副本数及修复建议。不要在未确认根因前执行回滚、重启等写操作。
+"""
+
+
+class PromptHandler:
+    """Handler for built-in diagnostic prompts."""
+
+    def __init__(self, server: FastMCP, settings: Optional[Dict[str, Any]] = None):
+        """Initialize the prompt handler.
+
+        Args:
+            server: FastMCP server instance
+            settings: Configuration settings
+        """
+        self.settings = settings or {}
+        self.server = server
+
+        self.server.prompt(
+            name="diagnose-crashlooping-pod",
+            description="诊断反复重启（CrashLoopBackOff）的 Pod：依次查看容器状态、崩溃前日志与事件，给出根因与修复建议",
+        )(self.diagnose_crashlooping_pod)
+
+        self.server.prompt(
+            name="why-is-deployment-not-ready",
+            description="排查 Deployment 未就绪的原因：依次查看发布状态、告警事件、ReplicaSet 与 Pod，给出根因与修复建议",
+        )(self.why_is_deployment_not_ready)
+
+        logger.info("Prompt Handler initialized")
+
+    def diagnose_crashlooping_pod(
+        self,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="Pod 所在命名空间"),
+        pod: str = Field(..., description="Pod 名称"),
+    ) -> str:
+        """生成诊断反复重启 Pod 的提示词"""
+        return CRASHLOOPING_POD_PROMPT.format(cluster_id=cluster_id, namespace=namespace, pod=pod)
+
+    def why_is_deployment_not_ready(
+        self,
+        cluster_id: str = Field(..., description="集群 ID"),
+        namespace: str = Field(..., description="Deployment 所在命名空间"),
+        deployment: str = Field(..., description="Deployment 名称"),
+    ) -> str:
+        """生成排查 Deployment 未就绪的提示词"""
+        return DEPLOYMENT_NOT_READY_PROMPT.format(cluster_id=cluster_id, namespace=namespace, deployment=deployment)
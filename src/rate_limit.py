@@ -0,0 +1,104 @@
+"""API Server 访问限流与工具调用并发控制。
+
+- TokenBucket：按 --kubectl-qps / --kubectl-burst 限制向 API Server 发起 kubectl 调用的速率（类似 client-go 的
+  QPS/Burst），进程内所有工具共享同一个令牌桶，超出速率的调用排队等待令牌
+- ConcurrencyLimitMiddleware：限制同时执行的工具调用数（--max-concurrent-calls），已满时立即返回 server busy
+  错误由客户端稍后重试，而不是无限排队
+"""
+
+import asyncio
+import threading
+import time
+from typing import Any, Dict, Optional, Tuple
+
+import mcp.types as mt
+from fastmcp.exceptions import ToolError
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from loguru import logger
+
+# 默认值：QPS/Burst 高于 client-go 的 5/10（单次工具调用常需多次 kubectl 调用），
+# 同时避免对大规模集群的 API Server 造成压力
+DEFAULT_KUBECTL_QPS = 20.0
+DEFAULT_KUBECTL_BURST = 40
+DEFAULT_MAX_CONCURRENT_CALLS = 16
+
+
+class TokenBucket:
+    """令牌桶限流器，令牌不足时按预约顺序等待（线程安全，不绑定事件循环）"""
+
+    def __init__(self, qps: float, burst: int):
+        self.qps = float(qps)
+        self.burst = max(int(burst), 1)
+        self._tokens = float(self.burst)
+        self._updated = time.monotonic()
+        self._lock = threading.Lock()
+
+    def reserve(self) -> float:
+        """预约一个令牌，返回需要等待的秒数"""
+        with self._lock:
+            now = time.monotonic()
+            self._tokens = min(self.burst, self._tokens + (now - self._updated) * self.qps)
+            self._updated = now
+            self._tokens -= 1
+            return 0.0 if self._tokens >= 0 else -self._tokens / self.qps
+
+    async def acquire(self, max_wait: Optional[float] = None):
+        """等待获取令牌，max_wait 限制最长等待时间（超过时不再等待，直接放行）"""
+        delay = self.reserve()
+        if max_wait is not None:
+            delay = min(delay, max(max_wait, 0.0))
+        if delay > 0:
+            logger.debug(f"Rate limited by kubectl QPS {self.qps}/burst {self.burst}, waiting {delay:.2f}s")
+            await asyncio.sleep(delay)
+
+
+_limiters: Dict[Tuple[float, int], TokenBucket] = {}
+_limiters_lock = threading.Lock()
+
+
+def get_rate_limiter(settings: Optional[Dict[str, Any]] = None) -> Optional[TokenBucket]:
+    """按配置获取进程内共享的令牌桶，kubectl_qps <= 0 时不限流返回 None"""
+    settings = settings or {}
+    qps = float(settings.get("kubectl_qps", DEFAULT_KUBECTL_QPS) or 0)
+    if qps <= 0:
+        return None
+    burst = int(settings.get("kubectl_burst", DEFAULT_KUBECTL_BURST) or 1)
+    with _limiters_lock:
+        limiter = _limiters.get((qps, burst))
+        if limiter is None:
+            limiter = _limiters[(qps, burst)] = TokenBucket(qps, burst)
+        return limiter
+
+
+class ConcurrencyLimitMiddleware(Middleware):
+    """限制同时执行的工具调用数，已满时拒绝新的调用"""
+
+    def __init__(self, max_concurrent: int):
+        self.max_concurrent = max_concurrent
+        self._active = 0
+        self._lock = threading.Lock()
+
+    @property
+    def active(self) -> int:
+        return self._active
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        with self._lock:
+            busy = self._active >= self.max_concurrent
+            if not busy:
+                self._active += 1
+        if busy:
+            tool = getattr(context.message, "name", None) or "unknown"
+            logger.warning(f"Rejected {tool}: {self.max_concurrent} tool calls already in progress")
+            raise ToolError(
+                f"server busy: {self.max_concurrent} tool calls are already in progress, retry in a few seconds"
+            )
+        try:
+            return await call_next(context)
+        finally:
+            with self._lock:
+                self._active -= 1
@@ -0,0 +1,111 @@
+"""镜像仓库 Registry v2 客户端。
+
+通过 manifest 接口检查镜像是否可拉取，支持匿名访问、Basic 认证以及 Docker Registry v2 Token（Bearer）认证流程，
+阿里云 ACR（个人版/企业版）使用相同的 Token 认证流程。
+"""
+
+import base64
+from typing import Dict, Any, Optional, Tuple
+
+import httpx
+from loguru import logger
+
+from kubectl_helpers import parse_image_reference, parse_www_authenticate
+
+# 同时接受 Docker 与 OCI 的单架构/多架构 manifest
+MANIFEST_ACCEPT = ", ".join([
+    "application/vnd.docker.distribution.manifest.v2+json",
+    "application/vnd.docker.distribution.manifest.list.v2+json",
+    "application/vnd.oci.image.manifest.v1+json",
+    "application/vnd.oci.image.index.v1+json",
+])
+
+
+def _basic_auth_header(credentials: Tuple[str, str]) -> str:
+    token = base64.b64encode(f"{credentials[0]}:{credentials[1]}".encode("utf-8")).decode("ascii")
+    return f"Basic {token}"
+
+
+class RegistryClient:
+    """检查镜像 manifest 是否可访问。"""
+
+    def __init__(self, timeout: float = 15.0):
+        self.timeout = timeout
+
+    async def check_image(self, image: str, credentials: Optional[Tuple[str, str]] = None) -> Dict[str, Any]:
+        """检查镜像是否可拉取
+
+        Args:
+            image: 镜像地址
+            credentials: (username, password)，为空时匿名访问
+
+        Returns:
+            {"status": Pullable|NotFound|Unauthorized|RateLimited|Unreachable|Error,
+             "http_status": int|None, "digest": str|None, "message": str}
+        """
+        registry, repository, reference = parse_image_reference(image)
+        url = f"https://{registry}/v2/{repository}/manifests/{reference}"
+        headers = {"Accept": MANIFEST_ACCEPT}
+        try:
+            async with httpx.AsyncClient(timeout=self.timeout, follow_redirects=True) as client:
+                response = await client.head(url, headers=headers)
+                if response.status_code == 401:
+                    auth_header = await self._authorize(
+                        client, response.headers.get("www-authenticate", ""), repository, credentials
+                    )
+                    if auth_header:
+                        headers["Authorization"] = auth_header
+                        response = await client.head(url, headers=headers)
+                return self._classify(response, credentials)
+        except httpx.HTTPError as e:
+            logger.debug(f"Registry request for {image} failed: {e}")
+            message = f"registry {registry} unreachable: {e}"
+            if "-vpc." in registry:
+                message += "（VPC 域名仅能在对应 VPC 内访问）"
+            return {"status": "Unreachable", "http_status": None, "digest": None, "message": message}
+
+    async def _authorize(
+        self,
+        client: httpx.AsyncClient,
+        challenge: str,
+        repository: str,
+        credentials: Optional[Tuple[str, str]],
+    ) -> Optional[str]:
+        """根据 WWW-Authenticate 质询获取 Authorization 头"""
+        scheme, params = parse_www_authenticate(challenge)
+        if scheme == "basic":
+            return _basic_auth_header(credentials) if credentials else None
+        if scheme != "bearer" or not params.get("realm"):
+            return None
+        query = {"scope": params.get("scope") or f"repository:{repository}:pull"}
+        if params.get("service"):
+            query["service"] = params["service"]
+        response = await client.get(
+            params["realm"], params=query, auth=httpx.BasicAuth(*credentials) if credentials else None
+        )
+        if response.status_code != 200:
+            logger.debug(f"Token request to {params['realm']} failed with HTTP {response.status_code}")
+            return None
+        body = response.json()
+        token = body.get("token") or body.get("access_token")
+        return f"Bearer {token}" if token else None
+
+    @staticmethod
+    def _classify(response: httpx.Response, credentials: Optional[Tuple[str, str]]) -> Dict[str, Any]:
+        status = response.status_code
+        result = {"http_status": status, "digest": response.headers.get("docker-content-digest"), "message": ""}
+        if status == 200:
+            result["status"] = "Pullable"
+        elif status == 404:
+            result["status"] = "NotFound"
+            result["message"] = "manifest not found (repository or tag does not exist)"
+        elif status in (401, 403):
+            result["status"] = "Unauthorized"
+            result["message"] = "credentials rejected" if credentials else "registry requires authentication"
+        elif status == 429:
+            result["status"] = "RateLimited"
+            result["message"] = "registry rate limit exceeded"
+        else:
+            result["status"] = "Error"
+            result["message"] = f"unexpected HTTP status {status}"
+        return result
@@ -0,0 +1,137 @@
+"""结构化日志与请求关联 ID。
+
+configure_logging 按 --log-format 将 loguru 日志输出为文本或单行 JSON（便于日志平台解析）；
+RequestContextMiddleware 为每次工具调用生成请求 ID，通过 logger.contextualize 附加到该调用期间的所有日志，
+并记录工具名、集群/context、命名空间及耗时等结构化字段，请求 ID 同时写入工具结果的 meta 返回给客户端。
+"""
+
+import json
+import sys
+import time
+import traceback
+import uuid
+from contextvars import ContextVar
+from typing import Any, Dict, Optional
+
+import mcp.types as mt
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from loguru import logger
+
+from metrics import tool_result_failed
+
+LOG_FORMATS = ("text", "json")
+
+# 工具结果 meta 中请求 ID 的键名
+REQUEST_ID_META_KEY = "request_id"
+
+# 当前工具调用的请求 ID，供需要在结果中引用的代码读取
+request_id_ctx: ContextVar[Optional[str]] = ContextVar("request_id", default=None)
+
+# 从工具参数中提取的上下文字段（参数名 -> 日志字段名）
+_ARGUMENT_FIELDS = {"cluster_id": "cluster_id", "context": "context", "namespace": "namespace"}
+
+# 文本格式：存在请求 ID 时附加在级别之后
+_TEXT_FORMAT = (
+    "<green>{time:YYYY-MM-DD HH:mm:ss.SSS}</green> | <level>{level: <8}</level> | "
+    "{extra[request_prefix]}<cyan>{name}</cyan>:<cyan>{function}</cyan>:<cyan>{line}</cyan> - <level>{message}</level>"
+)
+
+
+def current_request_id() -> Optional[str]:
+    """返回当前工具调用的请求 ID，不在工具调用中时返回 None"""
+    return request_id_ctx.get()
+
+
+def new_request_id() -> str:
+    return uuid.uuid4().hex[:16]
+
+
+def _json_sink(message):
+    """将日志记录输出为单行 JSON，contextualize/bind 附加的字段作为顶层字段"""
+    record = message.record
+    entry: Dict[str, Any] = {
+        "time": record["time"].isoformat(),
+        "level": record["level"].name,
+        "message": record["message"],
+        "logger": f"{record['name']}:{record['function']}:{record['line']}",
+    }
+    for key, value in record["extra"].items():
+        if key != "request_prefix" and value is not None:
+            entry[key] = value
+    if record["exception"] is not None:
+        exc_type, exc_value, exc_traceback = record["exception"]
+        entry["exception"] = "".join(traceback.format_exception(exc_type, exc_value, exc_traceback)).rstrip()
+    sys.stderr.write(json.dumps(entry, ensure_ascii=False, default=str) + "\n")
+    sys.stderr.flush()
+
+
+def _patch_request_prefix(record):
+    request_id = record["extra"].get("request_id")
+    record["extra"]["request_prefix"] = f"[{request_id}] " if request_id else ""
+
+
+def configure_logging(level: str = "INFO", log_format: str = "text"):
+    """配置日志输出（统一输出到 stderr，避免 stdio 传输模式下污染 stdout 协议流）"""
+    if log_format not in LOG_FORMATS:
+        raise ValueError(f"invalid log format '{log_format}' (choose from {', '.join(LOG_FORMATS)})")
+    logger.remove()
+    logger.configure(patcher=_patch_request_prefix)
+    if log_format == "json":
+        logger.add(_json_sink, level=level, format="{message}")
+    else:
+        logger.add(sys.stderr, level=level, format=_TEXT_FORMAT)
+
+
+def request_fields(tool: str, arguments: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+    """从工具调用参数中提取日志上下文字段"""
+    fields: Dict[str, Any] = {"tool": tool}
+    for argument, field in _ARGUMENT_FIELDS.items():
+        value = (arguments or {}).get(argument)
+        if isinstance(value, str) and value.strip():
+            fields[field] = value.strip()
+    return fields
+
+
+class RequestContextMiddleware(Middleware):
+    """为每次工具调用生成请求 ID 并记录结构化的调用日志"""
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        tool = getattr(context.message, "name", None) or "unknown"
+        request_id = new_request_id()
+        fields = request_fields(tool, getattr(context.message, "arguments", None))
+        token = request_id_ctx.set(request_id)
+        start = time.perf_counter()
+        try:
+            with logger.contextualize(request_id=request_id, **fields):
+                logger.debug(f"Tool call {tool} started")
+                try:
+                    result = await call_next(context)
+                except Exception as e:
+                    latency_ms = int((time.perf_counter() - start) * 1000)
+                    logger.bind(latency_ms=latency_ms, result="exception").error(f"Tool call {tool} raised: {e}")
+                    raise
+                latency_ms = int((time.perf_counter() - start) * 1000)
+                outcome = "error" if tool_result_failed(result) else "success"
+                logger.bind(latency_ms=latency_ms, result=outcome).info(
+                    f"Tool call {tool} finished with {outcome} in {latency_ms}ms"
+                )
+        finally:
+            request_id_ctx.reset(token)
+        attach_request_id(result, request_id)
+        return result
+
+
+def attach_request_id(result: Any, request_id: str):
+    """将请求 ID 写入工具结果的 meta，客户端可据此在服务端日志中检索该次调用"""
+    if result is None or not hasattr(result, "meta"):
+        return
+    meta = dict(getattr(result, "meta", None) or {})
+    meta[REQUEST_ID_META_KEY] = request_id
+    try:
+        result.meta = meta
+    except (AttributeError, TypeError, ValueError):
+        logger.debug("Tool result does not accept meta, request id not attached")
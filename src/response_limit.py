@@ -0,0 +1,158 @@
+"""工具响应大小上限。
+
+过大的工具结果可能超出 MCP 客户端的限制而被直接丢弃。--max-response-bytes 限制单次工具结果序列化后的字节数：
+ResponseSizeLimitMiddleware 在超出上限时按对象边界截断结果中最大的字段——列表（如 items）按条目截断，多文档 YAML
+（如 output=yaml 的结果）按文档截断，其余文本按行截断——截断后结果仍是合法的 JSON/YAML，并附加 truncated 元数据，
+说明省略的条目数并提示使用 limit 或 label_selector 缩小查询范围。
+"""
+
+import json
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+import mcp.types as mt
+import yaml
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from fastmcp.tools.tool import ToolResult
+from loguru import logger
+
+DEFAULT_MAX_RESPONSE_BYTES = 1024 * 1024
+
+# 截断元数据在结果中的字段名
+TRUNCATION_KEY = "truncated"
+
+# 内容为 YAML 的文本字段，截断后需保持可解析
+YAML_FIELDS = ("yaml", "manifest")
+
+TRUNCATION_HINT = (
+    "Response exceeded the server limit of {max_bytes} bytes and was truncated. Narrow the query with limit "
+    "(and continue_token for the next page), label_selector, field_selector or namespace"
+)
+
+
+def response_size(data: Any) -> int:
+    """结果按 JSON 序列化后的字节数"""
+    return len(json.dumps(data, ensure_ascii=False, default=str).encode("utf-8"))
+
+
+def _largest_fitting(total: int, fits: Callable[[int], bool]) -> int:
+    """二分查找满足 fits 的最大保留数量（0..total），都不满足时返回 0"""
+    low, high = 0, total
+    while low < high:
+        middle = (low + high + 1) // 2
+        if fits(middle):
+            low = middle
+        else:
+            high = middle - 1
+    return low
+
+
+def _split_text(key: str, text: str) -> Tuple[List[str], str, str]:
+    """将文本拆分为可截断的单元，返回 (单元列表, 连接符, 单元名称)"""
+    if key in YAML_FIELDS:
+        documents = text.split("\n---\n")
+        if len(documents) > 1:
+            return documents, "\n---\n", "documents"
+    return text.splitlines(keepends=True), "", "lines"
+
+
+def _valid_yaml(text: str) -> bool:
+    try:
+        list(yaml.safe_load_all(text))
+        return True
+    except yaml.YAMLError:
+        return False
+
+
+def truncate_response(data: Dict[str, Any], max_bytes: int) -> Optional[Dict[str, Any]]:
+    """结果超出 max_bytes 时返回截断后的副本，未超出时返回 None
+
+    从最大的字段开始依次截断，直到满足上限；截断信息写入 truncated 字段。
+    """
+    original_bytes = response_size(data)
+    if original_bytes <= max_bytes:
+        return None
+
+    result = dict(data)
+    fields: Dict[str, Dict[str, Any]] = {}
+    result[TRUNCATION_KEY] = {
+        "original_bytes": original_bytes,
+        "max_bytes": max_bytes,
+        "fields": fields,
+        "hint": TRUNCATION_HINT.format(max_bytes=max_bytes),
+    }
+    candidates = sorted(
+        (key for key, value in data.items() if key != TRUNCATION_KEY and isinstance(value, (list, str)) and value),
+        key=lambda key: response_size(data[key]),
+        reverse=True,
+    )
+    for key in candidates:
+        if response_size(result) <= max_bytes:
+            break
+        value = data[key]
+        if isinstance(value, list):
+            units, joiner, unit_name = value, None, "items"
+        else:
+            units, joiner, unit_name = _split_text(key, value)
+
+        def build(kept: int) -> Any:
+            return units[:kept] if joiner is None else joiner.join(units[:kept])
+
+        def fits(kept: int) -> bool:
+            result[key] = build(kept)
+            fields[key] = {"returned": kept, "omitted": len(units) - kept, "unit": unit_name}
+            return response_size(result) <= max_bytes
+
+        kept = _largest_fitting(len(units), fits)
+        # 按行截断的 YAML 需保持可解析
+        while key in YAML_FIELDS and kept > 0 and not _valid_yaml(build(kept)):
+            kept -= 1
+        fits(kept)
+
+    if response_size(result) > max_bytes:
+        logger.warning(f"Response still exceeds {max_bytes} bytes after truncating {list(fields)}")
+    return result
+
+
+def _structured_content(result: Any) -> Optional[Dict[str, Any]]:
+    """取工具结果的结构化内容，没有时尝试将文本内容解析为 JSON 对象"""
+    structured = getattr(result, "structured_content", None)
+    if isinstance(structured, dict):
+        return structured
+    content = getattr(result, "content", None) or []
+    if len(content) == 1 and isinstance(getattr(content[0], "text", None), str):
+        try:
+            parsed = json.loads(content[0].text)
+        except ValueError:
+            return None
+        return parsed if isinstance(parsed, dict) else None
+    return None
+
+
+class ResponseSizeLimitMiddleware(Middleware):
+    """工具结果超出大小上限时按对象边界截断"""
+
+    def __init__(self, max_bytes: int):
+        self.max_bytes = max_bytes
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        result = await call_next(context)
+        structured = _structured_content(result)
+        if structured is None:
+            return result
+        truncated = truncate_response(structured, self.max_bytes)
+        if truncated is None:
+            return result
+        tool = getattr(context.message, "name", None) or "unknown"
+        logger.warning(
+            f"Truncated {tool} response from {truncated[TRUNCATION_KEY]['original_bytes']} bytes "
+            f"to the {self.max_bytes} bytes limit: {truncated[TRUNCATION_KEY]['fields']}"
+        )
+        return ToolResult(
+            content=json.dumps(truncated, ensure_ascii=False, default=str),
+            structured_content=truncated,
+            meta=getattr(result, "meta", None),
+        )
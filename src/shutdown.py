@@ -0,0 +1,132 @@
+"""优雅退出。
+
+InFlightCallsMiddleware 记录正在执行的工具调用。进程收到 SIGTERM/SIGINT 时先进入排空状态：拒绝新的工具调用，
+立即取消 watch、logs -f 等流式调用（kubectl 子进程随之终止），其余调用最多等待 --shutdown-timeout 秒后取消剩余调用，
+之后再交给原有的信号处理（sse/http 下为 uvicorn 的优雅退出，stdio 下为进程默认行为）关闭服务。
+"""
+
+import asyncio
+import signal
+from typing import Any, Callable, Dict, Optional, Tuple
+
+import mcp.types as mt
+from fastmcp.exceptions import ToolError
+from fastmcp.server.middleware import CallNext, Middleware, MiddlewareContext
+from loguru import logger
+
+from kubectl_helpers import STREAMING_OPERATIONS, kubectl_operation
+
+# 默认排空超时（秒），需小于 Pod 的 terminationGracePeriodSeconds（默认 30 秒）
+DEFAULT_SHUTDOWN_TIMEOUT = 25
+
+# 超时取消后等待调用清理（如终止 kubectl 子进程）的时间（秒）
+CANCEL_GRACE_SECONDS = 2
+
+SHUTDOWN_SIGNALS = (signal.SIGTERM, signal.SIGINT)
+
+
+def is_streaming_call(tool: str, arguments: Optional[Dict[str, Any]]) -> bool:
+    """是否为不会自行结束的流式调用，退出时无需等待"""
+    if tool == "kubectl_watch":
+        return True
+    if tool == "ack_kubectl":
+        return kubectl_operation(str((arguments or {}).get("command") or "")) in STREAMING_OPERATIONS
+    return False
+
+
+class InFlightCallsMiddleware(Middleware):
+    """跟踪进行中的工具调用，退出时等待其完成"""
+
+    def __init__(self, timeout: float = DEFAULT_SHUTDOWN_TIMEOUT):
+        self.timeout = timeout
+        self.draining = False
+        # 进行中的调用：任务 -> (工具名, 是否流式)
+        self._calls: Dict[asyncio.Task, Tuple[str, bool]] = {}
+
+    @property
+    def in_flight(self) -> int:
+        return len(self._calls)
+
+    async def on_call_tool(
+        self,
+        context: MiddlewareContext[mt.CallToolRequestParams],
+        call_next: CallNext[mt.CallToolRequestParams, Any],
+    ) -> Any:
+        tool = getattr(context.message, "name", None) or "unknown"
+        if self.draining:
+            raise ToolError("server is shutting down, retry the call in a few seconds")
+        # 在独立任务中执行，退出时可单独取消；调用方被取消时 await 会一并取消该任务
+        task = asyncio.ensure_future(call_next(context))
+        self._calls[task] = (tool, is_streaming_call(tool, getattr(context.message, "arguments", None)))
+        try:
+            return await task
+        except asyncio.CancelledError:
+            current = asyncio.current_task()
+            if self.draining and task.cancelled() and not (current and current.cancelling()):
+                raise ToolError(f"{tool} was cancelled because the server is shutting down")
+            raise
+        finally:
+            self._calls.pop(task, None)
+
+    async def drain(self) -> Tuple[int, int]:
+        """拒绝新调用并等待进行中的调用完成，超时后取消剩余调用
+
+        Returns:
+            (完成的调用数, 被取消的调用数)
+        """
+        self.draining = True
+        calls = dict(self._calls)
+        streaming = [task for task, (_, is_streaming) in calls.items() if is_streaming]
+        logger.info(
+            f"Shutting down with {len(calls)} tool calls in flight ({len(streaming)} streaming), "
+            f"waiting up to {self.timeout}s"
+        )
+        if not calls:
+            return 0, 0
+        for task in streaming:
+            task.cancel()
+        done, pending = await asyncio.wait(calls, timeout=self.timeout)
+        for task in pending:
+            task.cancel()
+        if pending:
+            logger.warning(
+                f"Cancelled {len(pending)} tool calls still running after {self.timeout}s: "
+                f"{', '.join(sorted(calls[task][0] for task in pending))}"
+            )
+            await asyncio.wait(pending, timeout=CANCEL_GRACE_SECONDS)
+        cancelled = len(pending) + sum(1 for task in done if task in streaming)
+        logger.info(f"Drained in-flight tool calls: {len(calls) - cancelled} completed, {cancelled} cancelled")
+        return len(calls) - cancelled, cancelled
+
+    def install_signal_handlers(self):
+        """在当前事件循环中接管退出信号：先排空调用，再交给原有的处理（须在事件循环运行后、服务启动时调用）"""
+        loop = asyncio.get_running_loop()
+        for sig in SHUTDOWN_SIGNALS:
+            previous = signal.getsignal(sig) or signal.SIG_DFL
+            try:
+                loop.add_signal_handler(sig, self._on_signal, sig, previous)
+            except (NotImplementedError, RuntimeError, ValueError) as e:
+                logger.debug(f"Graceful drain on {sig.name} not available: {e}")
+
+    def _on_signal(self, sig: signal.Signals, previous: Callable):
+        loop = asyncio.get_running_loop()
+        if self.draining:
+            # 排空期间再次收到信号时立即退出
+            self._forward_signal(loop, sig, previous)
+            return
+        logger.info(f"Received {sig.name}, draining in-flight tool calls")
+
+        async def shutdown():
+            try:
+                await self.drain()
+            finally:
+                self._forward_signal(loop, sig, previous)
+
+        loop.create_task(shutdown())
+
+    @staticmethod
+    def _forward_signal(loop: asyncio.AbstractEventLoop, sig: signal.Signals, previous: Any):
+        """恢复原有的信号处理并重新发出信号（只转发一次）"""
+        if loop.remove_signal_handler(sig):
+            signal.signal(sig, previous)
+            signal.raise_signal(sig)
@@ -228,6 +228,49 @@ async def test_list_clusters_with_pagination():
     assert result.error is None
 
 
+@pytest.mark.asyncio
+async def test_list_clusters_with_region_filter_and_node_count():
+    """测试 region_id 过滤参数透传及节点数解析"""
+    fake_clusters = [
+        {
+            "name": "c1",
+            "cluster_id": "cls-1",
+            "state": "Running",
+            "region_id": "cn-beijing",
+            "cluster_type": "ManagedKubernetes",
+            "current_version": "1.30.1-aliyun.1",
+            "size": 12,
+            "tags": [],
+            "vswitch_ids": [],
+            "master_url": '{}'
+        }
+    ]
+    requests = []
+
+    class RecordingCSClient(FakeCSClient):
+        async def describe_clusters_v1with_options_async(self, request, headers, runtime):
+            requests.append(request)
+            return await super().describe_clusters_v1with_options_async(request, headers, runtime)
+
+    tool = make_handler_and_tool({"access_key_id": "ak", "access_key_secret": "sk"})
+
+    def cs_client_factory(region: str, config=None):
+        return RecordingCSClient(fake_clusters)
+
+    ctx = FakeContext({
+        "config": {"access_key_id": "ak", "access_key_secret": "sk"},
+        "providers": {"cs_client_factory": cs_client_factory}
+    })
+
+    result = await tool(ctx, page_size=10, page_num=1, region_id="cn-beijing")
+
+    assert result.error is None
+    assert requests[0].region_id == "cn-beijing"
+    assert result.clusters[0].region_id == "cn-beijing"
+    assert result.clusters[0].node_count == 12
+    assert result.clusters[0].current_version == "1.30.1-aliyun.1"
+
+
 @pytest.mark.asyncio
 async def test_list_clusters_invalid_cluster_data():
     """测试无效的集群数据"""
@@ -0,0 +1,83 @@
+import asyncio
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import discovery_cache as module_under_test
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 100.0
+
+    def monotonic(self):
+        return self.now
+
+
+class FakeDiscovery:
+    def __init__(self, delay=0.0):
+        self.delay = delay
+        self.calls = 0
+        self.fail = False
+
+    async def __call__(self):
+        self.calls += 1
+        await asyncio.sleep(self.delay)
+        if self.fail:
+            raise RuntimeError("discovery failed")
+        return [{"name": f"resources-{self.calls}"}]
+
+
+@pytest.mark.asyncio
+async def test_concurrent_misses_share_one_discovery():
+    cache = module_under_test.DiscoveryCache(600)
+    fetch = FakeDiscovery(delay=0.01)
+
+    results = await asyncio.gather(*(cache.get("/kube/c1", fetch) for _ in range(5)))
+
+    assert fetch.calls == 1
+    assert all(result == [{"name": "resources-1"}] for result in results)
+    assert await cache.get("/kube/c1", fetch) == [{"name": "resources-1"}]
+    assert await cache.get("/kube/c2", fetch) == [{"name": "resources-2"}]
+    assert fetch.calls == 2
+
+
+@pytest.mark.asyncio
+async def test_entries_expire_after_refresh_interval_and_on_max_age(monkeypatch):
+    clock = FakeClock()
+    monkeypatch.setattr(module_under_test, "time", clock)
+    cache = module_under_test.DiscoveryCache(600)
+    fetch = FakeDiscovery()
+
+    await cache.get("/kube/c1", fetch)
+    clock.now += 20
+    assert await cache.get("/kube/c1", fetch, max_age=30) == [{"name": "resources-1"}]
+    clock.now += 20
+    assert await cache.get("/kube/c1", fetch, max_age=30) == [{"name": "resources-2"}]
+    clock.now += 601
+    assert await cache.get("/kube/c1", fetch) == [{"name": "resources-3"}]
+
+    cache.invalidate("/kube/c1")
+    assert await cache.get("/kube/c1", fetch) == [{"name": "resources-4"}]
+
+
+@pytest.mark.asyncio
+async def test_failed_discovery_is_not_cached():
+    cache = module_under_test.DiscoveryCache(600)
+    fetch = FakeDiscovery()
+    fetch.fail = True
+
+    with pytest.raises(RuntimeError):
+        await cache.get("/kube/c1", fetch)
+    fetch.fail = False
+    assert await cache.get("/kube/c1", fetch) == [{"name": "resources-2"}]
+
+
+def test_get_discovery_cache_is_shared_and_can_be_disabled():
+    cache = module_under_test.get_discovery_cache({"discovery_refresh_interval": 300})
+    assert cache is module_under_test.get_discovery_cache({"discovery_refresh_interval": 300})
+    assert cache.refresh_interval == 300
+    assert module_under_test.get_discovery_cache({"discovery_refresh_interval": 0}) is None
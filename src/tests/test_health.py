@@ -0,0 +1,83 @@
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import health as module_under_test
+
+
+class FakeRunner:
+    def __init__(self, result):
+        self.result = result
+        self.calls = []
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append((kubeconfig_path, list(args), timeout))
+        return self.result
+
+
+class FakeContextManager:
+    def __init__(self, path="/tmp/kubeconfig", error=None):
+        self.path = path
+        self.error = error
+
+    def get_kubeconfig_path(self, cluster_id, mode, kubeconfig_path, execution_log, context=None, kubeconfig_dir=None):
+        if self.error:
+            raise self.error
+        return self.path
+
+
+def make_checker(monkeypatch, settings, result=None, context_manager=None):
+    monkeypatch.setattr(module_under_test, "get_context_manager", lambda: context_manager or FakeContextManager())
+    checker = module_under_test.HealthChecker(settings)
+    checker.runner = FakeRunner(result or {"exit_code": 0, "stdout": '{"gitVersion": "v1.30.1"}', "stderr": ""})
+    return checker
+
+
+@pytest.mark.asyncio
+async def test_readiness_checks_api_server_version(monkeypatch):
+    checker = make_checker(monkeypatch, {"kubeconfig_mode": "LOCAL", "kubeconfig_path": "~/.kube/config"})
+
+    ready, reason = await checker.check_ready()
+
+    assert ready
+    assert "v1.30.1" in reason
+    assert checker.runner.calls == [("/tmp/kubeconfig", ["get", "--raw", "/version"], 5)]
+
+
+@pytest.mark.asyncio
+async def test_readiness_fails_when_api_server_unreachable(monkeypatch):
+    checker = make_checker(monkeypatch, {"kubeconfig_mode": "INCLUSTER"}, result={
+        "exit_code": 1, "stdout": "",
+        "stderr": "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\nmore",
+    })
+
+    ready, reason = await checker.check_ready()
+
+    assert not ready
+    assert reason == "API server unreachable: Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout"
+
+
+@pytest.mark.asyncio
+async def test_readiness_fails_without_kubeconfig_or_credentials(monkeypatch):
+    checker = make_checker(
+        monkeypatch, {"kubeconfig_mode": "LOCAL"},
+        context_manager=FakeContextManager(error=ValueError("File /missing does not exist")),
+    )
+    ready, reason = await checker.check_ready()
+    assert not ready
+    assert "kubeconfig unavailable" in reason
+    assert checker.runner.calls == []
+
+    checker = make_checker(monkeypatch, {"kubeconfig_mode": "ACK_PUBLIC", "access_key_id": "ak"})
+    ready, reason = await checker.check_ready()
+    assert not ready
+    assert "credentials" in reason
+
+    checker = make_checker(monkeypatch, {"kubeconfig_mode": "ACK_PRIVATE", "access_key_id": "ak",
+                                         "access_key_secret": "sk"})
+    ready, _ = await checker.check_ready()
+    assert ready
+    assert checker.runner.calls == []
@@ -0,0 +1,64 @@
+import json
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import http_auth as module_under_test
+
+
+class FakeApp:
+    def __init__(self):
+        self.calls = []
+
+    async def __call__(self, scope, receive, send):
+        self.calls.append(scope["path"])
+
+
+def _scope(path="/mcp", authorization=None, method="POST"):
+    headers = [(b"authorization", authorization.encode())] if authorization else []
+    return {"type": "http", "method": method, "path": path, "headers": headers, "client": ("10.0.0.9", 5000)}
+
+
+async def _call(middleware, scope):
+    sent = []
+
+    async def send(message):
+        sent.append(message)
+
+    await middleware(scope, None, send)
+    return sent
+
+
+def test_bearer_token_matches():
+    assert module_under_test.bearer_token_matches("Bearer s3cret", "s3cret")
+    assert module_under_test.bearer_token_matches("bearer  s3cret ", "s3cret")
+    assert not module_under_test.bearer_token_matches("Bearer wrong", "s3cret")
+    assert not module_under_test.bearer_token_matches("Basic s3cret", "s3cret")
+    assert not module_under_test.bearer_token_matches(None, "s3cret")
+
+
+@pytest.mark.asyncio
+async def test_requests_without_valid_token_get_401():
+    app = FakeApp()
+    middleware = module_under_test.BearerTokenAuthMiddleware(app, token="s3cret")
+
+    for authorization in (None, "Bearer wrong"):
+        sent = await _call(middleware, _scope(authorization=authorization))
+        assert sent[0]["status"] == 401
+        assert (b"www-authenticate", b'Bearer realm="mcp"') in sent[0]["headers"]
+        assert json.loads(sent[1]["body"])["error"] == "unauthorized"
+    assert app.calls == []
+
+    assert await _call(middleware, _scope(authorization="Bearer s3cret")) == []
+    # 健康检查与 CORS 预检不需要认证
+    await _call(middleware, _scope(path="/healthz"))
+    await _call(middleware, _scope(path="/mcp", method="OPTIONS"))
+    assert app.calls == ["/mcp", "/healthz", "/mcp"]
+
+
+def test_empty_token_is_rejected():
+    with pytest.raises(ValueError):
+        module_under_test.BearerTokenAuthMiddleware(FakeApp(), token="")
@@ -0,0 +1,143 @@
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import impersonation as module_under_test
+import kubectl_handler
+import kubectl_runner
+from fastmcp.exceptions import ToolError
+from models import ExecutionLog
+
+
+class FakeMessage:
+    def __init__(self, name, arguments):
+        self.name = name
+        self.arguments = arguments
+
+    def model_copy(self, update):
+        return FakeMessage(update.get("name", self.name), update.get("arguments", self.arguments))
+
+
+class FakeMiddlewareContext:
+    def __init__(self, message):
+        self.message = message
+
+    def copy(self, message):
+        return FakeMiddlewareContext(message)
+
+
+class FakeTool:
+    def __init__(self, name, parameters):
+        self.name = name
+        self.parameters = parameters
+
+    def model_copy(self, update):
+        return FakeTool(self.name, update.get("parameters", self.parameters))
+
+
+def test_parse_impersonation_and_cache_key():
+    assert module_under_test.parse_impersonation(" alice ", ["dev", "ops", "dev", " "]) == ("alice", ("dev", "ops"))
+    assert module_under_test.parse_impersonation("alice", "dev,ops") == ("alice", ("dev", "ops"))
+    with pytest.raises(ValueError, match="requires impersonate_user"):
+        module_under_test.parse_impersonation(None, ["dev"])
+    with pytest.raises(ValueError, match="list of group names"):
+        module_under_test.parse_impersonation("alice", [1])
+
+    assert module_under_test.impersonation_cache_key("/kube/c1") == "/kube/c1"
+    token = module_under_test.impersonation_ctx.set(("alice", ("ops", "dev")))
+    try:
+        assert module_under_test.impersonation_cache_key("/kube/c1") == "/kube/c1#as=alice&group=dev&group=ops"
+        assert module_under_test.impersonation_args() == ["--as=alice", "--as-group=ops", "--as-group=dev"]
+    finally:
+        module_under_test.impersonation_ctx.reset(token)
+
+
+@pytest.mark.asyncio
+async def test_middleware_runs_kubectl_as_requested_user(monkeypatch):
+    middleware = module_under_test.ImpersonationMiddleware()
+    runner = kubectl_runner.KubectlRunner({"request_timeout": 0})
+    commands = []
+
+    async def fake_exec(cmd, timeout, stdin):
+        commands.append(cmd)
+        return {"exit_code": 0, "stdout": "{}", "stderr": ""}
+
+    monkeypatch.setattr(runner, "_exec", fake_exec)
+
+    async def call_next(context):
+        await runner.run_json("/tmp/kubeconfig", ["get", "pods"], ExecutionLog(tool_call_id="t"))
+        return context.message.arguments
+
+    arguments = await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("kubectl_get", {
+        "resource": "pods", "impersonate_user": "alice", "impersonate_groups": ["dev"],
+    })), call_next)
+    await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("kubectl_get", {"resource": "pods"})), call_next)
+
+    assert arguments == {"resource": "pods"}
+    assert commands == [
+        ["kubectl", "--kubeconfig", "/tmp/kubeconfig", "--as=alice", "--as-group=dev", "get", "pods"],
+        ["kubectl", "--kubeconfig", "/tmp/kubeconfig", "get", "pods"],
+    ]
+
+
+@pytest.mark.asyncio
+async def test_ack_kubectl_chained_commands_cannot_drop_impersonation(monkeypatch):
+    middleware = module_under_test.ImpersonationMiddleware()
+    handler = kubectl_handler.KubectlHandler(None, {"request_timeout": 0})
+    commands = []
+
+    async def fake_exec(cmd, timeout, stdin):
+        commands.append(cmd)
+        return {"exit_code": 0, "stdout": "", "stderr": ""}
+
+    monkeypatch.setattr(handler.runner, "_exec", fake_exec)
+
+    async def call_next(context):
+        command = context.message.arguments["command"]
+        return await handler.run_command(command, "/tmp/kubeconfig", 30, ExecutionLog(tool_call_id="t"))
+
+    await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("ack_kubectl", {
+        "command": "get pods -n dev && kubectl get secrets -n dev; kubectl get nodes",
+        "impersonate_user": "alice",
+    })), call_next)
+
+    # 命令不经过 shell，&& 与 ; 之后的部分只是同一个 kubectl 进程的参数，仍以 alice 的身份执行
+    assert commands == [[
+        "kubectl", "--kubeconfig", "/tmp/kubeconfig", "--as=alice",
+        "get", "pods", "-n", "dev", "&&", "kubectl", "get", "secrets", "-n", "dev;", "kubectl", "get", "nodes",
+    ]]
+
+
+@pytest.mark.asyncio
+async def test_middleware_rejects_unsupported_tools_and_identity_flags():
+    middleware = module_under_test.ImpersonationMiddleware()
+
+    async def call_next(context):
+        raise AssertionError("should not be called")
+
+    with pytest.raises(ToolError, match="does not support impersonation"):
+        await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("query_prometheus", {
+            "impersonate_user": "alice",
+        })), call_next)
+    with pytest.raises(ToolError, match="--as cannot be used"):
+        await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("ack_kubectl", {
+            "command": "get secrets --as=admin", "impersonate_user": "alice",
+        })), call_next)
+
+
+@pytest.mark.asyncio
+async def test_list_tools_declares_parameters_on_kubectl_tools_only():
+    middleware = module_under_test.ImpersonationMiddleware()
+    params = {"properties": {"cluster_id": {"type": "string"}}}
+
+    async def call_next(context):
+        return [FakeTool("kubectl_get", params), FakeTool("ack_kubectl", params), FakeTool("query_prometheus", params)]
+
+    tools = await middleware.on_list_tools(None, call_next)
+
+    assert "impersonate_user" in tools[0].parameters["properties"]
+    assert "impersonate_groups" in tools[1].parameters["properties"]
+    assert "impersonate_user" not in tools[2].parameters["properties"]
@@ -0,0 +1,176 @@
+import base64
+import os
+import sys
+
+import pytest
+import yaml
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import inline_kubeconfig as module_under_test
+from fastmcp.exceptions import ToolError
+from kubectl_handler import get_context_manager
+from models import ExecutionLog
+
+
+def kubeconfig(**overrides):
+    config = {
+        "apiVersion": "v1",
+        "kind": "Config",
+        "clusters": [
+            {"name": "prod", "cluster": {"server": "https://10.0.0.1:6443", "certificate-authority-data": "Y2E="}},
+            {"name": "staging", "cluster": {"server": "https://10.0.0.2:6443"}},
+        ],
+        "contexts": [
+            {"name": "prod", "context": {"cluster": "prod", "user": "tenant"}},
+            {"name": "staging", "context": {"cluster": "staging", "user": "tenant"}},
+        ],
+        "users": [{"name": "tenant", "user": {"token": "secret-token"}}],
+        "current-context": "prod",
+    }
+    config.update(overrides)
+    return config
+
+
+def encode(config):
+    return base64.b64encode(yaml.safe_dump(config).encode()).decode()
+
+
+class FakeMessage:
+    def __init__(self, name, arguments):
+        self.name = name
+        self.arguments = arguments
+
+    def model_copy(self, update):
+        return FakeMessage(update.get("name", self.name), update.get("arguments", self.arguments))
+
+
+class FakeMiddlewareContext:
+    def __init__(self, message):
+        self.message = message
+
+    def copy(self, message):
+        return FakeMiddlewareContext(message)
+
+
+class FakeTool:
+    def __init__(self, parameters):
+        self.parameters = parameters
+
+    def model_copy(self, update):
+        return FakeTool(update.get("parameters", self.parameters))
+
+
+class FakeRunner:
+    def __init__(self, result):
+        self.result = result
+        self.calls = []
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        with open(kubeconfig_path) as f:
+            self.calls.append((args, yaml.safe_load(f)))
+        return self.result
+
+
+OK = {"exit_code": 0, "stdout": '{"gitVersion": "v1.31.1"}', "stderr": ""}
+
+
+def test_decode_kubeconfig_validates_content():
+    config = module_under_test.decode_kubeconfig(encode(kubeconfig()), "staging")
+    assert config["current-context"] == "staging"
+
+    with pytest.raises(ValueError, match="not valid base64"):
+        module_under_test.decode_kubeconfig("not base64!")
+    with pytest.raises(ValueError, match="no users defined"):
+        module_under_test.decode_kubeconfig(encode(kubeconfig(users=[])))
+    with pytest.raises(ValueError, match="Context 'dev' not found .* available contexts: prod, staging"):
+        module_under_test.decode_kubeconfig(encode(kubeconfig()), "dev")
+    with pytest.raises(ValueError, match="has no current-context"):
+        module_under_test.decode_kubeconfig(encode(kubeconfig(**{"current-context": ""})))
+    # 不允许引用服务端本地文件或执行插件
+    with pytest.raises(ValueError, match="references local file via client-key"):
+        module_under_test.decode_kubeconfig(encode(kubeconfig(users=[
+            {"name": "tenant", "user": {"client-certificate-data": "Y2VydA==", "client-key": "/etc/keys/admin.key"}},
+        ])))
+    with pytest.raises(ValueError, match="exec/auth-provider plugin"):
+        module_under_test.decode_kubeconfig(encode(kubeconfig(users=[
+            {"name": "tenant", "user": {"exec": {"command": "sh", "args": ["-c", "id"]}}},
+        ])))
+
+
+@pytest.mark.asyncio
+async def test_middleware_uses_inline_kubeconfig_for_the_request_only():
+    runner = FakeRunner(OK)
+    middleware = module_under_test.InlineKubeconfigMiddleware(runner)
+    seen = {}
+
+    async def call_next(context):
+        seen["arguments"] = context.message.arguments
+        execution_log = ExecutionLog(tool_call_id="t")
+        path = get_context_manager().get_kubeconfig_path("tenant-a", "ACK_PUBLIC", None, execution_log, "staging")
+        seen["path"] = path
+        with open(path) as f:
+            seen["config"] = yaml.safe_load(f)
+        seen["source"] = execution_log.api_calls[-1]["source"]
+        return "result"
+
+    arguments = {"cluster_id": "tenant-a", "context": "staging", "kubeconfig_base64": encode(kubeconfig())}
+    result = await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("kubectl_get", arguments)), call_next)
+
+    assert result == "result"
+    assert seen["arguments"] == {"cluster_id": "tenant-a", "context": "staging"}
+    assert seen["path"].startswith(f"/proc/{os.getpid()}/fd/")
+    assert seen["config"]["current-context"] == "staging"
+    assert seen["config"]["users"][0]["user"]["token"] == "secret-token"
+    assert seen["source"] == "inline"
+    assert runner.calls[0][0] == ["get", "--raw", "/version"]
+    # 调用结束后 memfd 已关闭，不再使用传入的 kubeconfig
+    assert module_under_test.current_inline_kubeconfig() is None
+    assert not os.path.exists(seen["path"])
+
+
+@pytest.mark.asyncio
+async def test_middleware_rejects_malformed_or_unreachable_kubeconfig():
+    calls = []
+
+    async def call_next(context):
+        calls.append(context.message.arguments)
+        return "result"
+
+    middleware = module_under_test.InlineKubeconfigMiddleware(FakeRunner(OK))
+    with pytest.raises(ToolError, match="not valid base64"):
+        await middleware.on_call_tool(
+            FakeMiddlewareContext(FakeMessage("kubectl_get", {"cluster_id": "c1", "kubeconfig_base64": "%%%"})),
+            call_next,
+        )
+
+    unreachable = FakeRunner({"exit_code": 1, "stdout": "", "stderr": "dial tcp 10.0.0.1:6443: i/o timeout"})
+    middleware = module_under_test.InlineKubeconfigMiddleware(unreachable)
+    with pytest.raises(ToolError, match="API server https://10.0.0.1:6443 .* unreachable .*i/o timeout"):
+        await middleware.on_call_tool(
+            FakeMiddlewareContext(FakeMessage("kubectl_get", {"cluster_id": "c1",
+                                                              "kubeconfig_base64": encode(kubeconfig())})),
+            call_next,
+        )
+    assert calls == []
+
+    # 未传入时不做处理
+    await middleware.on_call_tool(FakeMiddlewareContext(FakeMessage("list_clusters", {})), call_next)
+    assert calls == [{}]
+
+
+@pytest.mark.asyncio
+async def test_list_tools_declares_parameter_on_cluster_tools():
+    middleware = module_under_test.InlineKubeconfigMiddleware(FakeRunner(OK))
+
+    async def call_next(context):
+        return [
+            FakeTool({"type": "object", "properties": {"cluster_id": {"type": "string"}}, "required": ["cluster_id"]}),
+            FakeTool({"type": "object", "properties": {"region_id": {"type": "string"}}}),
+        ]
+
+    tools = await middleware.on_list_tools(None, call_next)
+
+    assert tools[0].parameters["properties"]["kubeconfig_base64"] == module_under_test.INLINE_KUBECONFIG_SCHEMA
+    assert tools[0].parameters["required"] == ["cluster_id"]
+    assert "kubeconfig_base64" not in tools[1].parameters["properties"]
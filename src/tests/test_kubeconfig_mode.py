@@ -2,7 +2,7 @@ import os
 import sys
 import tempfile
 import pytest
-from unittest.mock import patch, MagicMock, mock_open
+from unittest.mock import patch, AsyncMock, MagicMock, mock_open
 
 # 添加父目录到路径以导入模块
 sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
@@ -413,9 +413,9 @@ async def test_kubectl_tool_with_local_kubeconfig_mode():
         )
         tool = server.tools["ack_kubectl"]
         
-        # Mock subprocess.run
-        with patch('kubectl_handler.subprocess.run') as mock_run:
-            mock_run.return_value = MagicMock(returncode=0, stdout="pods found", stderr="")
+        # Mock kubectl 执行
+        with patch.object(handler.runner, "_exec", new_callable=AsyncMock) as mock_exec:
+            mock_exec.return_value = {"exit_code": 0, "stdout": "pods found", "stderr": ""}
             
             # 创建上下文
             ctx = FakeContext(FakeLifespanContext())
@@ -427,10 +427,10 @@ async def test_kubectl_tool_with_local_kubeconfig_mode():
             assert result.exit_code == 0
             assert result.stdout == "pods found"
             
-            # 验证 subprocess.run 被调用
-            mock_run.assert_called_once()
-            call_args = mock_run.call_args[0][0]  # 获取第一个位置参数
-            assert f"--kubeconfig {temp_kubeconfig_path}" in call_args
+            # 验证 kubectl 以参数列表方式执行
+            mock_exec.assert_called_once()
+            call_args = mock_exec.call_args[0][0]  # 获取第一个位置参数
+            assert call_args[1:3] == ["--kubeconfig", temp_kubeconfig_path]
     finally:
         # 清理临时文件
         if os.path.exists(temp_kubeconfig_path):
@@ -455,9 +455,9 @@ async def test_kubectl_tool_with_ack_public_kubeconfig_mode():
     )
     tool = server.tools["ack_kubectl"]
     
-    # Mock subprocess.run
-    with patch('kubectl_handler.subprocess.run') as mock_run:
-        mock_run.return_value = MagicMock(returncode=0, stdout="pods found", stderr="")
+    # Mock kubectl 执行
+    with patch.object(handler.runner, "_exec", new_callable=AsyncMock) as mock_exec:
+        mock_exec.return_value = {"exit_code": 0, "stdout": "pods found", "stderr": ""}
         
         # Mock CS 客户端
         with patch('kubectl_handler.get_context_manager') as mock_get_context_manager:
@@ -476,10 +476,10 @@ async def test_kubectl_tool_with_ack_public_kubeconfig_mode():
             assert result.exit_code == 0
             assert result.stdout == "pods found"
             
-            # 验证 subprocess.run 被调用
-            mock_run.assert_called_once()
-            call_args = mock_run.call_args[0][0]  # 获取第一个位置参数
-            assert "--kubeconfig /tmp/test-kubeconfig.yaml" in call_args
+            # 验证 kubectl 以参数列表方式执行
+            mock_exec.assert_called_once()
+            call_args = mock_exec.call_args[0][0]  # 获取第一个位置参数
+            assert call_args[1:3] == ["--kubeconfig", "/tmp/test-kubeconfig.yaml"]
 
 
 @pytest.mark.asyncio
@@ -497,9 +497,9 @@ async def test_kubectl_tool_with_ack_private_kubeconfig_mode():
     )
     tool = server.tools["ack_kubectl"]
     
-    # Mock subprocess.run
-    with patch('kubectl_handler.subprocess.run') as mock_run:
-        mock_run.return_value = MagicMock(returncode=0, stdout="pods found", stderr="")
+    # Mock kubectl 执行
+    with patch.object(handler.runner, "_exec", new_callable=AsyncMock) as mock_exec:
+        mock_exec.return_value = {"exit_code": 0, "stdout": "pods found", "stderr": ""}
         
         # Mock CS 客户端
         with patch('kubectl_handler.get_context_manager') as mock_get_context_manager:
@@ -518,10 +518,10 @@ async def test_kubectl_tool_with_ack_private_kubeconfig_mode():
             assert result.exit_code == 0
             assert result.stdout == "pods found"
             
-            # 验证 subprocess.run 被调用
-            mock_run.assert_called_once()
-            call_args = mock_run.call_args[0][0]  # 获取第一个位置参数
-            assert "--kubeconfig /tmp/test-kubeconfig.yaml" in call_args
+            # 验证 kubectl 以参数列表方式执行
+            mock_exec.assert_called_once()
+            call_args = mock_exec.call_args[0][0]  # 获取第一个位置参数
+            assert call_args[1:3] == ["--kubeconfig", "/tmp/test-kubeconfig.yaml"]
 
 
 @pytest.mark.asyncio
@@ -539,9 +539,9 @@ async def test_kubectl_tool_with_incluster_kubeconfig_mode():
     )
     tool = server.tools["ack_kubectl"]
     
-    # Mock subprocess.run
-    with patch('kubectl_handler.subprocess.run') as mock_run:
-        mock_run.return_value = MagicMock(returncode=0, stdout="pods found", stderr="")
+    # Mock kubectl 执行
+    with patch.object(handler.runner, "_exec", new_callable=AsyncMock) as mock_exec:
+        mock_exec.return_value = {"exit_code": 0, "stdout": "pods found", "stderr": ""}
         
         # Mock CS 客户端
         with patch('kubectl_handler.get_context_manager') as mock_get_context_manager:
@@ -560,10 +560,234 @@ async def test_kubectl_tool_with_incluster_kubeconfig_mode():
             assert result.exit_code == 0
             assert result.stdout == "pods found"
             
-            # 验证 subprocess.run 被调用
-            mock_run.assert_called_once()
-            call_args = mock_run.call_args[0][0]  # 获取第一个位置参数
-            assert "--kubeconfig /tmp/.kube/config.incluster" in call_args
+            # 验证 kubectl 以参数列表方式执行
+            mock_exec.assert_called_once()
+            call_args = mock_exec.call_args[0][0]  # 获取第一个位置参数
+            assert call_args[1:3] == ["--kubeconfig", "/tmp/.kube/config.incluster"]
+
+
+MULTI_CONTEXT_KUBECONFIG = """apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+    certificate-authority: certs/prod-ca.crt
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+users:
+- name: prod-admin
+  user:
+    token: prod-token
+- name: staging-admin
+  user:
+    token: staging-token
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: prod-admin
+- name: staging
+  context:
+    cluster: staging
+    user: staging-admin
+current-context: staging
+"""
+
+
+def test_local_kubeconfig_with_context(context_manager):
+    """测试指定 context 时生成仅包含该 context 的 kubeconfig，并按 (路径, context) 缓存"""
+    import yaml
+    from models import ExecutionLog
+
+    with tempfile.TemporaryDirectory() as tmp_dir:
+        source = os.path.join(tmp_dir, "config")
+        with open(source, "w") as f:
+            f.write(MULTI_CONTEXT_KUBECONFIG)
+
+        path = context_manager.get_kubeconfig_path("c1", "LOCAL", source, ExecutionLog(), context="prod")
+        assert path != source
+        with open(path) as f:
+            derived = yaml.safe_load(f)
+        assert derived["current-context"] == "prod"
+        assert [c["name"] for c in derived["clusters"]] == ["prod"]
+        assert [u["name"] for u in derived["users"]] == ["prod-admin"]
+        assert derived["clusters"][0]["cluster"]["certificate-authority"] == os.path.join(tmp_dir, "certs/prod-ca.crt")
+
+        log = ExecutionLog()
+        assert context_manager.get_kubeconfig_path("c1", "LOCAL", source, log, context="prod") == path
+        assert log.api_calls[-1]["source"] == "cache"
+        assert context_manager.get_kubeconfig_path("c1", "LOCAL", source, ExecutionLog()) == os.path.abspath(source)
+
+        with pytest.raises(ValueError, match="available contexts: prod, staging"):
+            context_manager.get_kubeconfig_path("c1", "LOCAL", source, ExecutionLog(), context="dev")
+
+        # 生成的 kubeconfig 随缓存清理删除，源文件保留
+        context_manager.cleanup()
+        assert not os.path.exists(path)
+        assert os.path.exists(source)
+
+
+def test_context_kubeconfig_regenerated_when_source_changes(context_manager):
+    """测试源 kubeconfig 修改后重新生成 context kubeconfig，未修改时复用缓存"""
+    import yaml
+    from models import ExecutionLog
+
+    with tempfile.TemporaryDirectory() as tmp_dir:
+        source = os.path.join(tmp_dir, "config")
+        with open(source, "w") as f:
+            f.write(MULTI_CONTEXT_KUBECONFIG)
+
+        path = context_manager.get_kubeconfig_path("c1", "LOCAL", source, ExecutionLog(), context="prod")
+        log = ExecutionLog()
+        assert context_manager.get_kubeconfig_path("c1", "LOCAL", source, log, context="prod") == path
+        assert log.api_calls[-1]["source"] == "cache"
+
+        with open(source, "w") as f:
+            f.write(MULTI_CONTEXT_KUBECONFIG.replace("prod-token", "rotated-token"))
+        stat = os.stat(source)
+        os.utime(source, (stat.st_atime, stat.st_mtime + 10))
+
+        log = ExecutionLog()
+        assert context_manager.get_kubeconfig_path("c1", "LOCAL", source, log, context="prod") == path
+        assert log.api_calls[-1]["source"] == "context"
+        with open(path) as f:
+            assert yaml.safe_load(f)["users"][0]["user"]["token"] == "rotated-token"
+
+        context_manager.cleanup()
+
+
+@pytest.mark.benchmark
+@pytest.mark.skipif(os.environ.get("RUN_BENCHMARKS") != "1", reason="benchmark, set RUN_BENCHMARKS=1 to run")
+def test_benchmark_context_kubeconfig_cache_hit_vs_regeneration(context_manager):
+    """基准测试：重复获取 context kubeconfig 时均命中缓存，内存分配低于每次重新生成"""
+    import tracemalloc
+    from models import ExecutionLog
+
+    iterations = 50
+
+    def measure(before_each):
+        sources = []
+        tracemalloc.start()
+        for _ in range(iterations):
+            before_each()
+            log = ExecutionLog()
+            context_manager.get_kubeconfig_path("c1", "LOCAL", source, log, context="prod")
+            sources.append(log.api_calls[-1]["source"])
+        _, peak = tracemalloc.get_traced_memory()
+        tracemalloc.stop()
+        return sources, peak
+
+    with tempfile.TemporaryDirectory() as tmp_dir:
+        source = os.path.join(tmp_dir, "config")
+        with open(source, "w") as f:
+            f.write(MULTI_CONTEXT_KUBECONFIG)
+        cache_key = ("context", os.path.abspath(source), "prod")
+        context_manager.get_kubeconfig_path("c1", "LOCAL", source, ExecutionLog(), context="prod")
+        assert cache_key in context_manager
+
+        # 每次删除缓存项，模拟未缓存时每次调用都重新读取、解析并写出 kubeconfig
+        regenerate_sources, regenerate_peak = measure(lambda: context_manager.pop(cache_key, None))
+        cached_sources, cached_peak = measure(lambda: None)
+
+        assert set(regenerate_sources) == {"context"}
+        assert set(cached_sources) == {"cache"}
+        assert cached_peak < regenerate_peak
+
+        context_manager.cleanup()
+
+
+def test_concurrent_kubeconfig_lookups_share_cache(context_manager, temp_kubeconfig_file):
+    """测试并发获取同一集群 kubeconfig 时复用同一缓存项"""
+    from concurrent.futures import ThreadPoolExecutor
+    from models import ExecutionLog
+
+    def lookup(_):
+        return context_manager.get_kubeconfig_path("c1", "LOCAL", temp_kubeconfig_file, ExecutionLog())
+
+    with ThreadPoolExecutor(max_workers=8) as pool:
+        paths = set(pool.map(lookup, range(32)))
+    assert paths == {os.path.abspath(temp_kubeconfig_file)}
+
+
+def test_auto_kubeconfig_mode_prefers_incluster(context_manager):
+    """测试 AUTO 模式在 Pod 内使用 ServiceAccount 配置，并默认使用 Pod 所在命名空间"""
+    import yaml
+    from models import ExecutionLog
+
+    with tempfile.TemporaryDirectory() as sa_dir:
+        for name, content in (("token", "sa-token"), ("ca.crt", "ca"), ("namespace", "ack-mcp\n")):
+            with open(os.path.join(sa_dir, name), "w") as f:
+                f.write(content)
+        with patch.dict(os.environ, {"KUBERNETES_SERVICE_HOST": "10.0.0.1", "KUBERNETES_SERVICE_PORT": "443"}), \
+             patch.object(module_under_test, "INCLUSTER_SERVICEACCOUNT_DIR", sa_dir), \
+             patch.object(module_under_test, "INCLUSTER_TOKEN_FILE", os.path.join(sa_dir, "token")):
+            log = ExecutionLog()
+            path = context_manager.get_kubeconfig_path("c1", "AUTO", "", log)
+
+        assert log.api_calls[-1]["source"] == "incluster"
+        with open(path) as f:
+            config = yaml.safe_load(f)
+        context = config["contexts"][0]["context"]
+        assert context["namespace"] == "ack-mcp"
+        assert config["users"][0]["user"]["tokenFile"] == os.path.join(sa_dir, "token")
+        assert config["clusters"][0]["cluster"]["server"] == "https://10.0.0.1:443"
+
+
+def test_resolve_default_namespace_precedence():
+    """测试默认命名空间：显式配置 > 集群内 Pod 所在命名空间 > default"""
+    with tempfile.TemporaryDirectory() as sa_dir:
+        with open(os.path.join(sa_dir, "namespace"), "w") as f:
+            f.write("ack-mcp\n")
+        with patch.object(module_under_test, "INCLUSTER_SERVICEACCOUNT_DIR", sa_dir):
+            with patch.dict(os.environ, {"KUBERNETES_SERVICE_HOST": "10.0.0.1"}):
+                assert module_under_test.resolve_default_namespace(" team-a ") == "team-a"
+                assert module_under_test.resolve_default_namespace(None) == "ack-mcp"
+            with patch.dict(os.environ, {}, clear=True):
+                assert module_under_test.resolve_default_namespace("") == "default"
+
+    with pytest.raises(ValueError, match="RFC 1123"):
+        module_under_test.resolve_default_namespace("Team_A")
+
+
+def test_auto_kubeconfig_mode_falls_back_to_local(context_manager, temp_kubeconfig_file):
+    """测试 AUTO 模式不在 Pod 内运行时回退到本地 kubeconfig"""
+    from models import ExecutionLog
+
+    env = {k: v for k, v in os.environ.items() if k not in ("KUBERNETES_SERVICE_HOST", "KUBERNETES_SERVICE_PORT")}
+    with patch.dict(os.environ, env, clear=True):
+        log = ExecutionLog()
+        path = context_manager.get_kubeconfig_path("c1", "AUTO", temp_kubeconfig_file, log)
+
+    assert path == os.path.abspath(temp_kubeconfig_file)
+    assert log.api_calls[-1]["source"] == "local_file"
+
+
+def test_kubeconfig_dir_per_cluster(context_manager):
+    """测试 kubeconfig 目录按集群ID选择文件，未找到时回退到 kubeconfig_mode，且目录中的文件不会被清理"""
+    from models import ExecutionLog
+
+    with tempfile.TemporaryDirectory() as kube_dir:
+        prod = os.path.join(kube_dir, "c-prod.yaml")
+        staging = os.path.join(kube_dir, "c-staging")
+        local = os.path.join(kube_dir, "local-config")
+        for path in (prod, staging, local):
+            with open(path, "w") as f:
+                f.write("apiVersion: v1\nkind: Config\n")
+
+        log = ExecutionLog()
+        assert context_manager.get_kubeconfig_path("c-prod", "LOCAL", local, log, kubeconfig_dir=kube_dir) == prod
+        assert log.api_calls[-1]["source"] == "kubeconfig_dir"
+        assert context_manager.get_kubeconfig_path("c-staging", "LOCAL", local, ExecutionLog(),
+                                                   kubeconfig_dir=kube_dir) == staging
+        assert context_manager.get_kubeconfig_path("c-other", "LOCAL", local, ExecutionLog(),
+                                                   kubeconfig_dir=kube_dir) == local
+        assert context_manager.get_kubeconfig_path("../c-prod", "LOCAL", local, ExecutionLog(),
+                                                   kubeconfig_dir=kube_dir) == local
+
+        context_manager.cleanup()
+        assert all(os.path.exists(path) for path in (prod, staging, local))
 
 
 if __name__ == "__main__":
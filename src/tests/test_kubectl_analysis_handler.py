@@ -0,0 +1,1094 @@
+import base64
+import json
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import kubectl_analysis_handler as module_under_test
+from kubectl_runner import KubectlCommandError
+
+
+# 自签名证书：CN=example.com，SAN=example.com,*.example.com,IP:10.0.0.1，有效期 2026-10-15 ~ 2027-10-15
+TEST_CERT_PEM = """-----BEGIN CERTIFICATE-----
+MIIBrjCCAVWgAwIBAgIUUCA6YtOTE1531Mm3X7NmQBOXIzIwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLZXhhbXBsZS5jb20wHhcNMjYxMDE1MDcxMDU4WhcNMjcxMDE1
+MDcxMDU4WjAWMRQwEgYDVQQDDAtleGFtcGxlLmNvbTBZMBMGByqGSM49AgEGCCqG
+SM49AwEHA0IABMa5t+RTqAPujxUmbo6E6sRKWL0np20KmhQPCLGgcnHbvfrjfy8T
+eoc4a66QStzhNzHNB9PzezjVlPuW/WLnK6+jgYAwfjAdBgNVHQ4EFgQUg8NI6IF+
+TCztuB7Vphor1g0iQNAwHwYDVR0jBBgwFoAUg8NI6IF+TCztuB7Vphor1g0iQNAw
+DwYDVR0TAQH/BAUwAwEB/zArBgNVHREEJDAiggtleGFtcGxlLmNvbYINKi5leGFt
+cGxlLmNvbYcECgAAATAKBggqhkjOPQQDAgNHADBEAiAlSwRF6rW6aozdg37rYPyb
+BH4xHwfNBpBBSv02EVkcBgIgHCNJC7cqAWS6ReFITLy7xHFxnv4iF9hGL9fPCORu
+ALs=
+-----END CERTIFICATE-----
+"""
+
+
+class FakeServer:
+    def __init__(self):
+        self.tools = {}
+
+    def tool(self, name: str = None, description: str = None):
+        def decorator(func):
+            key = name or getattr(func, "__name__", "unnamed")
+            self.tools[key] = func
+            return func
+        return decorator
+
+
+class FakeRequestContext:
+    def __init__(self, lifespan_context=None):
+        self.lifespan_context = lifespan_context or {}
+
+
+class FakeContext:
+    def __init__(self, lifespan_context=None):
+        self.request_context = FakeRequestContext(lifespan_context)
+
+
+class FakeRunner:
+    """按 kubectl 参数返回预置结果的执行器"""
+
+    def __init__(self, responses=None):
+        self.responses = responses or {}
+        self.calls = []
+        self.contexts = []
+
+    def resolve_kubeconfig(self, ctx, cluster_id, execution_log, context=None):
+        self.contexts.append(context)
+        return "/tmp/fake-kubeconfig"
+
+    def resolve_timeout(self, requested=None, operation=None):
+        return requested or 30
+
+    async def run_json(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        self.stdin = stdin
+        response = self.responses.get(tuple(args))
+        if isinstance(response, Exception):
+            raise response
+        if response is None:
+            raise KubectlCommandError(f"unexpected command: {args}", stderr="unexpected")
+        return response
+
+
+def make_handler(responses, settings=None):
+    server = FakeServer()
+    handler = module_under_test.KubectlAnalysisHandler(server, settings or {})
+    handler.runner = FakeRunner(responses)
+    return handler, server
+
+
+def _ingress(name, tls, namespace="default"):
+    return {
+        "metadata": {"name": name, "namespace": namespace},
+        "spec": {"ingressClassName": "nginx", "tls": tls},
+    }
+
+
+@pytest.mark.asyncio
+async def test_ingress_tls_reports_secret_state():
+    secret = {"data": {"tls.crt": base64.b64encode(TEST_CERT_PEM.encode()).decode()}}
+    responses = {
+        ("get", "ingresses", "-n", "default", "-o", "json"): {"items": [
+            _ingress("web", [
+                {"hosts": ["www.example.com", "www.other.io"], "secretName": "web-tls"},
+                {"hosts": ["api.example.com"], "secretName": "missing-tls"},
+                {"hosts": ["default.example.com"]},
+            ]),
+            _ingress("plain", []),
+            _ingress("web2", [{"hosts": ["example.com"], "secretName": "web-tls"}]),
+        ]},
+        ("get", "secret", "web-tls", "-n", "default", "-o", "json"): secret,
+        ("get", "secret", "missing-tls", "-n", "default", "-o", "json"): KubectlCommandError(
+            "not found", stderr='Error from server (NotFound): secrets "missing-tls" not found'
+        ),
+    }
+    handler, server = make_handler(responses)
+    tool = server.tools["kubectl_ingress_tls"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="default", expiring_days=30)
+
+    assert result.error is None
+    assert result.count == 2
+    web = result.ingresses[0]
+    assert web.name == "web" and web.ingress_class == "nginx"
+    ok, missing, default = web.tls
+    assert ok.exists and ok.subject_cn == "example.com"
+    assert ok.not_after == "2027-10-15T07:10:58Z"
+    assert ok.uncovered_hosts == ["www.other.io"]
+    assert missing.status == "Missing" and not missing.exists
+    assert default.status == "DefaultCertificate"
+    # 同一 Secret 只读取一次
+    secret_calls = [c for c in handler.runner.calls if c[:2] == ["get", "secret"]]
+    assert len(secret_calls) == 2
+
+
+@pytest.mark.asyncio
+async def test_ingress_tls_list_failure_returns_error():
+    handler, server = make_handler({
+        ("get", "ingresses", "--all-namespaces", "-o", "json"): KubectlCommandError("forbidden", stderr="Forbidden"),
+    })
+    tool = server.tools["kubectl_ingress_tls"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace=None, expiring_days=30)
+
+    assert result.error.error_code == "IngressTLSSummaryFailed"
+    assert result.ingresses == []
+
+
+def _node(name, max_pods="110", cpu="4", memory="16Gi", unschedulable=False):
+    return {
+        "metadata": {"name": name},
+        "spec": {"unschedulable": unschedulable},
+        "status": {"allocatable": {"pods": max_pods, "cpu": cpu, "memory": memory}},
+    }
+
+
+def _pod(node, cpu="100m", memory="128Mi"):
+    return {
+        "spec": {
+            "nodeName": node,
+            "containers": [{"resources": {"requests": {"cpu": cpu, "memory": memory}}}],
+        }
+    }
+
+
+NODE_ARGS = ("get", "nodes", "-o", "json")
+POD_ARGS = ("get", "pods", "--all-namespaces",
+            "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json")
+
+
+@pytest.mark.asyncio
+async def test_node_balance_flags_hot_and_empty_nodes():
+    pods = [_pod("hot") for _ in range(10)] + [_pod("warm") for _ in range(5)]
+    handler, server = make_handler({
+        NODE_ARGS: {"items": [
+            _node("hot", max_pods="10"),
+            _node("warm", max_pods="10"),
+            _node("cold", max_pods="10"),
+            _node("cordoned", max_pods="10", unschedulable=True),
+        ]},
+        POD_ARGS: {"items": pods},
+    })
+    tool = server.tools["kubectl_node_balance"]
+
+    result = await tool(FakeContext(), cluster_id="c1", node_selector=None, imbalance_threshold=0.5)
+
+    assert result.error is None
+    by_name = {n.name: n for n in result.nodes}
+    assert result.nodes[0].name == "hot"
+    assert by_name["hot"].pod_count == 10
+    assert by_name["hot"].cpu_requests == "1000m"
+    assert by_name["hot"].cpu_request_ratio == 0.25
+    assert "NearMaxPods" in by_name["hot"].flags
+    assert "NearlyEmpty" in by_name["cold"].flags
+    assert "Unschedulable" in by_name["cordoned"].flags
+    assert result.summary.node_count == 3
+    assert result.summary.pod_utilization_spread == 1.0
+    assert result.summary.imbalanced is True
+    assert any("descheduler" in s for s in result.suggestions)
+
+
+@pytest.mark.asyncio
+async def test_node_balance_balanced_cluster_with_selector():
+    handler, server = make_handler({
+        ("get", "nodes", "-l", "pool=a", "-o", "json"): {"items": [_node("a1"), _node("a2")]},
+        POD_ARGS: {"items": [_pod("a1"), _pod("a2"), _pod("other-pool-node")]},
+    })
+    tool = server.tools["kubectl_node_balance"]
+
+    result = await tool(FakeContext(), cluster_id="c1", node_selector="pool=a", imbalance_threshold=0.5)
+
+    assert result.error is None
+    assert result.summary.total_pods == 2
+    assert result.summary.imbalanced is False
+    assert result.suggestions == []
+
+
+DF_OUTPUT = """Filesystem     1024-blocks    Used Available Capacity Mounted on
+overlay           41152736 37037462   4115274      90% /
+tmpfs                65536        0     65536       0% /dev
+/dev/vdb          10485760  1048576   9437184      10% /data dir
+"""
+
+
+class FakeExecRunner(FakeRunner):
+    def __init__(self, result):
+        super().__init__()
+        self.result = result
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        self.timeout = timeout
+        return self.result
+
+
+@pytest.mark.asyncio
+async def test_container_df_parses_usage_and_warns():
+    handler, server = make_handler({})
+    handler.runner = FakeExecRunner({"exit_code": 0, "stdout": DF_OUTPUT, "stderr": ""})
+    tool = server.tools["kubectl_container_df"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="default", pod="web-0",
+                        container="app", warn_percent=85, context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert handler.runner.calls[0] == ["exec", "web-0", "-n", "default", "-c", "app", "--", "df", "-P", "-k"]
+    assert [f.mount_point for f in result.filesystems] == ["/", "/dev", "/data dir"]
+    root = result.filesystems[0]
+    assert root.use_percent == 90.0
+    assert root.size_bytes == 41152736 * 1024
+    assert len(result.warnings) == 1 and result.warnings[0].startswith("/ (overlay)")
+
+
+@pytest.mark.asyncio
+async def test_container_df_without_df_binary():
+    handler, server = make_handler({})
+    handler.runner = FakeExecRunner({
+        "exit_code": 1,
+        "stdout": "",
+        "stderr": 'OCI runtime exec failed: exec failed: unable to start container process: exec: "df": '
+                  'executable file not found in $PATH: unknown',
+    })
+    tool = server.tools["kubectl_container_df"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="default", pod="distroless",
+                        container=None, warn_percent=85, context=None, timeout_seconds=None)
+
+    assert result.error.error_code == "DfUnavailable"
+    assert result.filesystems == []
+    assert "-c" not in handler.runner.calls[0]
+
+
+class FakeLogRunner(FakeRunner):
+    def __init__(self, responses, logs):
+        super().__init__(responses)
+        self.logs = logs
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        return {"exit_code": 0, "stdout": self.logs.get(args[1], ""), "stderr": ""}
+
+
+def _addon_pod(name, labels, ready=True, restarts=0, waiting=None):
+    container_state = {"waiting": {"reason": waiting}} if waiting else {"running": {}}
+    return {
+        "metadata": {"name": name, "labels": labels},
+        "status": {
+            "phase": "Running",
+            "conditions": [{"type": "Ready", "status": "True" if ready else "False"}],
+            "containerStatuses": [{"restartCount": restarts, "state": container_state}],
+        },
+    }
+
+
+@pytest.mark.asyncio
+async def test_addon_status_reports_health_events_and_errors():
+    workloads = {"items": [
+        {"kind": "Deployment", "metadata": {"name": "cluster-autoscaler"},
+         "spec": {"replicas": 1, "selector": {"matchLabels": {"app": "cluster-autoscaler"}}},
+         "status": {"readyReplicas": 0, "updatedReplicas": 1}},
+        {"kind": "DaemonSet", "metadata": {"name": "ack-node-problem-detector-daemonset"},
+         "spec": {"selector": {"matchLabels": {"app": "npd"}}},
+         "status": {"desiredNumberScheduled": 2, "numberReady": 2, "updatedNumberScheduled": 2}},
+    ]}
+    pods = {"items": [
+        _addon_pod("cluster-autoscaler-abc-1", {"app": "cluster-autoscaler"}, ready=False, restarts=7,
+                   waiting="CrashLoopBackOff"),
+        _addon_pod("npd-1", {"app": "npd"}),
+        _addon_pod("npd-2", {"app": "npd"}),
+    ]}
+    events = {"items": [
+        {"involvedObject": {"kind": "Pod", "name": "cluster-autoscaler-abc-1"}, "reason": "BackOff",
+         "message": "Back-off restarting failed container", "count": 12, "lastTimestamp": "2024-01-01T00:00:00Z"},
+        {"involvedObject": {"kind": "Pod", "name": "unrelated"}, "reason": "Failed", "message": "x"},
+    ]}
+    handler, server = make_handler({})
+    handler.runner = FakeLogRunner({
+        ("get", "deployments,daemonsets", "-n", "kube-system", "-o", "json"): workloads,
+        ("get", "pods", "-n", "kube-system", "-o", "json"): pods,
+        ("get", "events", "-n", "kube-system", "--field-selector=type=Warning", "-o", "json"): events,
+    }, logs={
+        "cluster-autoscaler-abc-1": "I0101 starting\nE0101 00:00:01 static_autoscaler.go:1] failed to scale up\n",
+        "npd-1": "I0101 all good\n",
+    })
+    tool = server.tools["kubectl_addon_status"]
+
+    result = await tool(FakeContext(), cluster_id="c1",
+                        addons=["cluster-autoscaler", "ack-node-problem-detector", "metrics-server"],
+                        namespace="kube-system", log_since="1h", max_error_lines=10, context=None, timeout_seconds=None)
+
+    assert result.error is None
+    ca, npd, ms = result.addons
+    assert ca.status == "Unavailable"
+    assert ca.restarts == 7
+    assert ca.unhealthy_pods == ["cluster-autoscaler-abc-1: CrashLoopBackOff"]
+    assert ca.warning_events == ["Pod/cluster-autoscaler-abc-1 BackOff: Back-off restarting failed container (x12)"]
+    assert ca.recent_errors == ["E0101 00:00:01 static_autoscaler.go:1] failed to scale up"]
+    assert npd.status == "Healthy"
+    assert npd.workloads[0].kind == "DaemonSet" and npd.workloads[0].ready == 2
+    assert npd.recent_errors == []
+    assert ms.status == "NotInstalled"
+
+
+def _rbac_responses(pod_spec_extra=None):
+    deployment = {
+        "kind": "Deployment",
+        "metadata": {"name": "api", "namespace": "prod"},
+        "spec": {"template": {"spec": {"serviceAccountName": "api-sa", **(pod_spec_extra or {})}}},
+    }
+    return {
+        ("get", "deployment", "api", "-n", "prod", "-o", "json"): deployment,
+        ("get", "serviceaccount", "api-sa", "-n", "prod", "-o", "json"): {"metadata": {"name": "api-sa"}},
+        ("get", "rolebindings", "-n", "prod", "-o", "json"): {"items": [
+            {"metadata": {"name": "api-config", "namespace": "prod"},
+             "subjects": [{"kind": "ServiceAccount", "name": "api-sa"}],
+             "roleRef": {"kind": "Role", "name": "config-reader"}},
+            {"metadata": {"name": "other", "namespace": "prod"},
+             "subjects": [{"kind": "ServiceAccount", "name": "other-sa"}],
+             "roleRef": {"kind": "Role", "name": "config-reader"}},
+            {"metadata": {"name": "ghost", "namespace": "prod"},
+             "subjects": [{"kind": "ServiceAccount", "name": "api-sa", "namespace": "prod"}],
+             "roleRef": {"kind": "Role", "name": "deleted-role"}},
+        ]},
+        ("get", "clusterrolebindings", "-o", "json"): {"items": [
+            {"metadata": {"name": "all-sa-secrets"},
+             "subjects": [{"kind": "Group", "name": "system:serviceaccounts"}],
+             "roleRef": {"kind": "ClusterRole", "name": "secret-reader"}},
+            {"metadata": {"name": "other-ns"},
+             "subjects": [{"kind": "ServiceAccount", "name": "api-sa", "namespace": "dev"}],
+             "roleRef": {"kind": "ClusterRole", "name": "cluster-admin"}},
+        ]},
+        ("get", "roles", "-n", "prod", "-o", "json"): {"items": [
+            {"metadata": {"name": "config-reader"},
+             "rules": [{"apiGroups": [""], "resources": ["configmaps"], "verbs": ["get", "list"]}]},
+        ]},
+        ("get", "clusterroles", "-o", "json"): {"items": [
+            {"metadata": {"name": "secret-reader"},
+             "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"]}]},
+            {"metadata": {"name": "cluster-admin"},
+             "rules": [{"apiGroups": ["*"], "resources": ["*"], "verbs": ["*"]}]},
+        ]},
+    }
+
+
+@pytest.mark.asyncio
+async def test_workload_permissions_resolves_bindings():
+    handler, server = make_handler(_rbac_responses())
+    tool = server.tools["kubectl_workload_permissions"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", workload_type="deployment",
+                        workload_name="api", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.service_account == "api-sa"
+    assert result.token_automount is True
+    assert result.bindings == [
+        "RoleBinding api-config -> Role config-reader",
+        "RoleBinding ghost -> Role deleted-role",
+        "ClusterRoleBinding all-sa-secrets -> ClusterRole secret-reader",
+    ]
+    assert result.summary == {"prod:configmaps": ["get", "list"], "cluster:secrets": ["get"]}
+    assert result.risks == ["can read secrets (cluster)"]
+    assert result.missing_roles == ["Role deleted-role (referenced by RoleBinding ghost)"]
+
+
+@pytest.mark.asyncio
+async def test_workload_permissions_respects_pod_automount_setting():
+    handler, server = make_handler(_rbac_responses({"automountServiceAccountToken": False}))
+    tool = server.tools["kubectl_workload_permissions"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", workload_type="deployment",
+                        workload_name="api", context=None, timeout_seconds=None)
+
+    assert result.token_automount is False
+    assert not any(c[:2] == ["get", "serviceaccount"] for c in handler.runner.calls)
+
+
+DIG_OUTPUT = """; <<>> DiG 9.16 <<>> +search kubernetes.default A
+;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 1234
+
+;; ANSWER SECTION:
+kubernetes.default.svc.cluster.local. 5 IN A	192.168.0.1
+
+;; Query time: 3 msec
+;; SERVER: 172.16.0.10#53(172.16.0.10)
+"""
+
+
+class ScriptedRunner(FakeRunner):
+    """按调用顺序返回预置 run 结果的执行器"""
+
+    def __init__(self, results):
+        super().__init__()
+        self.results = list(results)
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        return self.results.pop(0)
+
+
+def _dns_kwargs(**overrides):
+    kwargs = dict(cluster_id="c1", hostname="kubernetes.default", record_type="A", namespace="default",
+                  pod=None, container=None, context=None, timeout_seconds=None)
+    kwargs.update(overrides)
+    return kwargs
+
+
+@pytest.mark.asyncio
+async def test_dns_check_exec_with_dig():
+    handler, server = make_handler({})
+    handler.runner = ScriptedRunner([{"exit_code": 0, "stdout": DIG_OUTPUT, "stderr": ""}])
+    tool = server.tools["kubectl_dns_check"]
+
+    result = await tool(FakeContext(), **_dns_kwargs(pod="web-0"))
+
+    assert result.error is None
+    assert handler.runner.calls[0][:5] == ["exec", "web-0", "-n", "default", "--"]
+    assert result.tool == "dig" and result.status == "NOERROR"
+    assert result.server == "172.16.0.10"
+    assert result.latency_ms == 3
+    assert result.records[0].data == "192.168.0.1" and result.records[0].ttl == 5
+
+
+@pytest.mark.asyncio
+async def test_dns_check_falls_back_to_nslookup():
+    handler, server = make_handler({})
+    handler.runner = ScriptedRunner([
+        {"exit_code": 126, "stdout": "", "stderr": 'exec: "dig": executable file not found in $PATH'},
+        {"exit_code": 1, "stdout": "Server:\t\t172.16.0.10\nAddress:\t172.16.0.10:53\n\n"
+                                   "** server can't find nope.default.svc.cluster.local: NXDOMAIN\n", "stderr": ""},
+    ])
+    tool = server.tools["kubectl_dns_check"]
+
+    result = await tool(FakeContext(), **_dns_kwargs(hostname="nope", pod="web-0"))
+
+    assert result.error is None
+    assert result.tool == "nslookup"
+    assert result.status == "NXDOMAIN"
+    assert result.records == []
+
+
+@pytest.mark.asyncio
+async def test_dns_check_temporary_pod_requires_write():
+    handler, server = make_handler({})
+    handler.runner = ScriptedRunner([])
+    tool = server.tools["kubectl_dns_check"]
+
+    result = await tool(FakeContext(), **_dns_kwargs())
+    assert result.error.error_code == "WriteNotAllowed"
+    assert handler.runner.calls == []
+
+    handler, server = make_handler({}, settings={"allow_write": True})
+    handler.runner = ScriptedRunner([{"exit_code": 0, "stdout": DIG_OUTPUT, "stderr": ""}])
+    result = await server.tools["kubectl_dns_check"](FakeContext(), **_dns_kwargs())
+    assert result.status == "NOERROR"
+    args = handler.runner.calls[0]
+    assert args[0] == "run" and "--rm" in args and args[-2:] == ["kubernetes.default", "A"]
+    assert result.source.startswith("default/mcp-dns-check-")
+
+
+@pytest.mark.asyncio
+async def test_dns_check_rejects_option_like_queries():
+    handler, server = make_handler({})
+    handler.runner = ScriptedRunner([])
+    tool = server.tools["kubectl_dns_check"]
+
+    for overrides in ({"hostname": "-f/etc/hosts"}, {"hostname": "web -f /etc/passwd"},
+                      {"hostname": "web", "record_type": "-fpath"}, {"hostname": "web", "record_type": "AXFR"}):
+        result = await tool(FakeContext(), **_dns_kwargs(pod="web-0", **overrides))
+        assert result.error.error_code == "InvalidParameter"
+    assert handler.runner.calls == []
+
+
+def _webhook_kwargs(**overrides):
+    kwargs = dict(
+        cluster_id="c1", manifest=None, resource_type=None, name=None, namespace=None,
+        use_pod_template=True, include_defaults=False, context=None, timeout_seconds=None,
+    )
+    kwargs.update(overrides)
+    return kwargs
+
+
+@pytest.mark.asyncio
+async def test_webhook_mutations_diffs_pod_template_dry_run():
+    deployment = {
+        "apiVersion": "apps/v1", "kind": "Deployment",
+        "metadata": {"name": "web", "namespace": "prod", "uid": "u1", "resourceVersion": "10"},
+        "spec": {"template": {
+            "metadata": {"labels": {"app": "web"}},
+            "spec": {"containers": [{"name": "app", "image": "nginx"}]},
+        }},
+        "status": {"replicas": 1},
+    }
+    mutated = {
+        "apiVersion": "v1", "kind": "Pod",
+        "metadata": {
+            "name": "web-dryrun-abcde", "generateName": "web-dryrun-", "namespace": "prod",
+            "uid": "u2", "creationTimestamp": "2024-01-01T00:00:00Z",
+            "labels": {"app": "web"},
+            "annotations": {"sidecar.istio.io/status": "injected"},
+        },
+        "spec": {
+            "containers": [
+                {"name": "app", "image": "nginx", "imagePullPolicy": "Always",
+                 "terminationMessagePath": "/dev/termination-log"},
+                {"name": "istio-proxy", "image": "proxyv2"},
+            ],
+            "volumes": [{"name": "kube-api-access-x1", "projected": {}}],
+            "dnsPolicy": "ClusterFirst",
+        },
+        "status": {"phase": "Pending"},
+    }
+    dry_run_args = ("create", "--dry-run=server", "-o", "json", "-f", "-")
+    handler, server = make_handler({
+        ("get", "deployment", "web", "-o", "json", "-n", "prod"): deployment,
+        dry_run_args: mutated,
+        ("get", "mutatingwebhookconfigurations", "-o", "json"): {
+            "items": [{"metadata": {"name": "istio-sidecar-injector"}}],
+        },
+    })
+    tool = server.tools["kubectl_webhook_mutations"]
+
+    result = await tool(FakeContext(), **_webhook_kwargs(resource_type="deployment", name="web", namespace="prod"))
+
+    assert result.error is None
+    assert result.kind == "Pod"
+    assert result.mutating_webhooks == ["istio-sidecar-injector"]
+    paths = {change.path: change for change in result.changes}
+    assert paths["spec.containers[istio-proxy]"].op == "added"
+    assert "metadata.name" not in paths
+    assert result.annotations_added == {"sidecar.istio.io/status": "injected"}
+    assert result.ignored_default_changes >= 3
+    assert all("imagePullPolicy" not in path for path in paths)
+
+
+@pytest.mark.asyncio
+async def test_webhook_mutations_requires_manifest_or_object():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_webhook_mutations"]
+
+    result = await tool(FakeContext(), **_webhook_kwargs())
+    assert result.error.error_code == "WebhookMutationDiffFailed"
+    assert handler.runner.calls == []
+
+
+def _storage_node(name, pressure=False):
+    return {
+        "metadata": {"name": name},
+        "status": {
+            "capacity": {"ephemeral-storage": "100Gi"},
+            "allocatable": {"ephemeral-storage": "90Gi"},
+            "conditions": [{"type": "DiskPressure", "status": "True" if pressure else "False"}],
+        },
+    }
+
+
+def _storage_pod(name, node, limit=None):
+    resources = {"limits": {"ephemeral-storage": limit}} if limit else {}
+    return {
+        "metadata": {"name": name, "namespace": "default"},
+        "spec": {"nodeName": node, "containers": [{"name": "app", "resources": resources}],
+                 "volumes": [{"name": "data", "emptyDir": {}}]},
+    }
+
+
+@pytest.mark.asyncio
+async def test_ephemeral_storage_risk_flags_unlimited_pods_on_full_nodes():
+    gi = 2 ** 30
+    handler, server = make_handler({
+        ("get", "nodes", "-o", "json"): {"items": [_storage_node("n1"), _storage_node("n2")]},
+        ("get", "pods", "--all-namespaces",
+         "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json"): {"items": [
+            _storage_pod("hog", "n1"),
+            _storage_pod("bounded", "n1", limit="1Gi"),
+            _storage_pod("idle", "n2"),
+        ]},
+        ("get", "--raw", "/api/v1/nodes/n1/proxy/stats/summary"): {
+            "node": {"fs": {"usedBytes": 85 * gi, "capacityBytes": 100 * gi}},
+            "pods": [{"podRef": {"name": "hog", "namespace": "default"},
+                      "ephemeral-storage": {"usedBytes": 40 * gi}}],
+        },
+        ("get", "--raw", "/api/v1/nodes/n2/proxy/stats/summary"): KubectlCommandError("forbidden"),
+    })
+    tool = server.tools["kubectl_ephemeral_storage_risk"]
+
+    result = await tool(FakeContext(), cluster_id="c1", node_selector=None, pressure_percent=80,
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [n.name for n in result.nodes] == ["n1", "n2"]
+    assert result.nodes[0].at_risk and result.nodes[0].usage_percent == 85.0
+    assert not result.nodes[1].at_risk and result.nodes[1].used is None
+    assert [p.name for p in result.risky_pods] == ["hog"]
+    assert result.risky_pods[0].used == "40.0Gi"
+    assert result.risky_pods[0].unbounded_empty_dirs == ["data"]
+    assert result.total_pods_without_limit == 2
+    assert any("stats/summary" in s for s in result.suggestions)
+
+
+@pytest.mark.asyncio
+async def test_cross_namespace_refs_flags_references_to_other_namespaces():
+    deployment = {
+        "kind": "Deployment",
+        "metadata": {"name": "web", "namespace": "app"},
+        "spec": {"template": {"spec": {
+            "containers": [{"name": "web", "envFrom": [{"secretRef": {"name": "db-creds"}}],
+                            "env": [{"name": "FLAG", "valueFrom": {"configMapKeyRef": {"name": "flags", "key": "x",
+                                                                                        "optional": True}}}]}],
+            "volumes": [{"name": "conf", "configMap": {"name": "web-config"}}],
+        }}},
+    }
+    ingress = {
+        "kind": "Ingress",
+        "metadata": {"name": "web", "namespace": "app"},
+        "spec": {"rules": [{"host": "a.example.com", "http": {"paths": [
+            {"path": "/", "backend": {"service": {"name": "api.backend", "port": {"number": 80}}}},
+        ]}}]},
+    }
+    pvc = {
+        "kind": "PersistentVolumeClaim",
+        "metadata": {"name": "restore", "namespace": "app"},
+        "spec": {"dataSourceRef": {"kind": "VolumeSnapshot", "name": "snap", "namespace": "backup"}},
+    }
+    handler, server = make_handler({})
+    handler.runner = ScriptedRunner([{"exit_code": 0, "stderr": "", "stdout": (
+        "Secret      shared    db-creds\n"
+        "ConfigMap   app       web-config\n"
+        "Service     backend   api\n"
+    )}])
+    handler.runner.responses = {
+        ("get", "deployments,statefulsets,daemonsets,cronjobs,ingresses,persistentvolumeclaims",
+         "-n", "app", "-o", "json"): {"items": [deployment, ingress, pvc]},
+    }
+    tool = server.tools["kubectl_cross_namespace_refs"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="app", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.scanned_objects == 3
+    issues = {(r.target_kind, r.issue): r for r in result.references}
+    assert set(issues) == {
+        ("Secret", "FoundInOtherNamespace"),
+        ("Service", "NamespaceQualifiedName"),
+        ("VolumeSnapshot", "ExplicitCrossNamespace"),
+    }
+    assert issues[("Secret", "FoundInOtherNamespace")].candidate_namespaces == ["shared"]
+    assert issues[("Secret", "FoundInOtherNamespace")].field == "spec.template.spec.containers[web].envFrom.secretRef"
+    assert issues[("Service", "NamespaceQualifiedName")].candidate_namespaces == ["backend"]
+
+
+class FakeClusterDetail:
+    def __init__(self):
+        self.body = type("Body", (), {"region_id": "cn-hangzhou", "current_version": "1.30.1-aliyun.1"})()
+
+
+class FakeCSClient:
+    async def describe_cluster_detail_async(self, cluster_id):
+        return FakeClusterDetail()
+
+
+def _versioned_node(name, version, nodepool_id=None):
+    labels = {module_under_test.NODEPOOL_ID_LABEL: nodepool_id} if nodepool_id else {}
+    return {"metadata": {"name": name, "labels": labels},
+            "status": {"nodeInfo": {"kubeletVersion": version}}}
+
+
+@pytest.mark.asyncio
+async def test_version_skew_groups_nodes_by_nodepool(monkeypatch):
+    handler, server = make_handler({
+        ("get", "--raw", "/version"): {"gitVersion": "v1.30.1-aliyun.1"},
+        ("get", "nodes", "-o", "json"): {"items": [
+            _versioned_node("a1", "v1.30.1-aliyun.1", "np-a"),
+            _versioned_node("b1", "v1.26.3-aliyun.1", "np-b"),
+            _versioned_node("b2", "v1.28.3-aliyun.1", "np-b"),
+            _versioned_node("edge", "v1.29.0"),
+        ]},
+    })
+    nodepools = [
+        {"nodepool_info": {"nodepool_id": "np-a", "name": "default"}, "status": {"state": "active"}},
+        {"nodepool_info": {"nodepool_id": "np-b", "name": "legacy"}, "status": {"state": "upgrading"}},
+    ]
+
+    async def fake_fetch_nodepools(client, cluster_id, serialize):
+        return nodepools
+
+    monkeypatch.setattr(module_under_test, "_fetch_nodepools_list", fake_fetch_nodepools)
+    ctx = FakeContext({"providers": {"cs_client_factory": lambda region, config: FakeCSClient()}, "config": {}})
+    tool = server.tools["kubectl_version_skew"]
+
+    result = await tool(ctx, cluster_id="c1", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.cluster_version == "1.30.1-aliyun.1"
+    assert result.max_supported_skew == 3
+    pools = {p.nodepool_id: p for p in result.nodepools}
+    assert pools["np-b"].name == "legacy" and pools["np-b"].state == "upgrading"
+    assert pools["np-b"].status == "UnsupportedSkew" and pools["np-b"].minor_skew == 4
+    assert pools["np-b"].mixed_versions and pools["np-b"].lagging_nodes == ["b1", "b2"]
+    assert pools["np-a"].status == "OK"
+    assert pools[None].status == "Behind" and pools[None].node_count == 1
+    assert result.nodepools[0].nodepool_id == "np-b"
+    assert any("legacy" in issue and "超出支持范围" in issue for issue in result.issues)
+
+
+@pytest.mark.asyncio
+async def test_version_skew_without_cs_access_uses_node_labels():
+    handler, server = make_handler({
+        ("get", "--raw", "/version"): {"gitVersion": "v1.27.6"},
+        ("get", "nodes", "-o", "json"): {"items": [_versioned_node("n1", "v1.28.0", "np-a")]},
+    })
+    tool = server.tools["kubectl_version_skew"]
+
+    result = await tool(FakeContext(), cluster_id="c1", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.max_supported_skew == 2
+    assert result.nodepools[0].status == "NewerThanControlPlane"
+    assert any("CS" in issue for issue in result.issues)
+
+
+def _spot_pod(name, node, owner=None, labels=None):
+    metadata = {"name": name, "namespace": "default", "labels": labels or {}}
+    if owner:
+        metadata["ownerReferences"] = [{"kind": owner[0], "name": owner[1], "controller": True}]
+    return {"metadata": metadata, "spec": {"nodeName": node}}
+
+
+@pytest.mark.asyncio
+async def test_spot_risk_flags_workloads_on_spot_nodes():
+    nodes = {"items": [
+        {"metadata": {"name": "spot-1", "labels": {"node.alibabacloud.com/spot-instance": "true",
+                                                   "node.kubernetes.io/instance-type": "ecs.g7.large"}},
+         "spec": {"taints": [{"key": "spot", "effect": "NoSchedule"}]}},
+        {"metadata": {"name": "spot-2", "labels": {"karpenter.sh/capacity-type": "spot"}}},
+        {"metadata": {"name": "ondemand-1", "labels": {}}},
+    ]}
+    pods = {"items": [
+        _spot_pod("web-abc12-x", "spot-1", ("ReplicaSet", "web-abc12"), {"app": "web", "pod-template-hash": "abc12"}),
+        _spot_pod("web-abc12-y", "ondemand-1", ("ReplicaSet", "web-abc12"), {"app": "web", "pod-template-hash": "abc12"}),
+        _spot_pod("cache-0", "spot-2", ("StatefulSet", "cache"), {"app": "cache"}),
+        _spot_pod("agent-x", "spot-2", ("DaemonSet", "agent")),
+    ]}
+    pdbs = {"items": [{"metadata": {"name": "web-pdb", "namespace": "default"},
+                       "spec": {"selector": {"matchExpressions": [
+                           {"key": "app", "operator": "In", "values": ["web"]}]}}}]}
+    events = {"items": [{"involvedObject": {"kind": "Node", "name": "spot-2"},
+                         "reason": "PreemptibleInstanceInterruption"}]}
+    handler, server = make_handler({
+        ("get", "nodes", "-o", "json"): nodes,
+        ("get", "events", "--all-namespaces", "--field-selector=involvedObject.kind=Node", "-o", "json"): events,
+        ("get", "pods", "--all-namespaces",
+         "--field-selector=status.phase!=Succeeded,status.phase!=Failed", "-o", "json"): pods,
+        ("get", "poddisruptionbudgets", "--all-namespaces", "-o", "json"): pdbs,
+    })
+    tool = server.tools["kubectl_spot_risk"]
+
+    result = await tool(FakeContext(), cluster_id="c1", spot_selector=None, namespace=None,
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [n.name for n in result.spot_nodes] == ["spot-2", "spot-1"]
+    assert result.spot_nodes[0].reclaim_pending
+    assert not result.spot_nodes[1].reclaim_pending
+    workloads = {w.name: w for w in result.workloads}
+    assert set(workloads) == {"web", "cache"}
+    assert workloads["cache"].risk == "High"
+    assert set(workloads["cache"].flags) == {"AllReplicasOnSpot", "SingleReplica", "NoPDB", "OnReclaimingNode"}
+    assert workloads["web"].kind == "Deployment"
+    assert workloads["web"].pdbs == ["web-pdb"] and workloads["web"].risk == "Low"
+    assert workloads["web"].replicas == 2 and workloads["web"].spot_replicas == 1
+
+
+class FakeStreamRunner(FakeRunner):
+    """按 Pod/容器返回预置流式采样结果的执行器"""
+
+    def __init__(self, responses, samples):
+        super().__init__(responses)
+        self.samples = samples
+
+    async def sample_stream(self, kubeconfig_path, args, execution_log, duration):
+        self.calls.append(list(args))
+        return self.samples[(args[1], args[5])]
+
+
+def _running_pod(name, containers):
+    return {"metadata": {"name": name}, "spec": {"containers": [{"name": c} for c in containers]},
+            "status": {"phase": "Running"}}
+
+
+@pytest.mark.asyncio
+async def test_log_rate_samples_all_containers_of_selected_pods():
+    handler, server = make_handler({})
+    handler.runner = FakeStreamRunner(
+        {("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json"): {"items": [
+            _running_pod("web-1", ["app", "sidecar"]),
+            {"metadata": {"name": "web-2"}, "spec": {"containers": [{"name": "app"}]}, "status": {"phase": "Pending"}},
+        ]}},
+        {
+            ("web-1", "app"): {"exit_code": 0, "bytes": 10240, "lines": 100, "elapsed": 10.0, "stderr": ""},
+            ("web-1", "sidecar"): {"exit_code": 1, "bytes": 0, "lines": 0, "elapsed": 0.2,
+                                   "stderr": "container not found"},
+        },
+    )
+    tool = server.tools["kubectl_log_rate"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", pod=None, label_selector="app=web",
+                        container=None, sample_seconds=10, max_pods=20, context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [(e.pod, e.container) for e in result.entries] == [("web-1", "app"), ("web-1", "sidecar")]
+    assert result.entries[0].bytes_per_second == 1024.0 and result.entries[0].lines_per_second == 10.0
+    assert result.entries[0].estimated_daily == "84.4Mi"
+    assert result.entries[1].error == "container not found"
+    assert ["logs", "web-1", "-n", "prod", "-c", "app", "-f", "--tail=0"] in handler.runner.calls
+
+
+@pytest.mark.asyncio
+async def test_log_rate_requires_single_target_and_caps_window():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_log_rate"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", pod="a", label_selector="app=web",
+                        container=None, sample_seconds=600, max_pods=20, context=None, timeout_seconds=None)
+
+    assert result.error.error_code == "LogRateSampleFailed"
+    assert result.sample_seconds == module_under_test.MAX_LOG_SAMPLE_SECONDS
+
+
+class FakeRegistry:
+    def __init__(self, results):
+        self.results = results
+        self.calls = []
+
+    async def check_image(self, image, credentials=None):
+        self.calls.append((image, credentials))
+        return self.results[(image, credentials)]
+
+
+def _docker_secret(server, username, password):
+    config = {"auths": {server: {"username": username, "password": password}}}
+    return {"data": {".dockerconfigjson": base64.b64encode(json.dumps(config).encode()).decode()}}
+
+
+@pytest.mark.asyncio
+async def test_image_pullability_uses_pull_secrets_per_registry():
+    acr_image = "registry.cn-hangzhou.aliyuncs.com/team/api:v2"
+    deployment = {"kind": "Deployment", "metadata": {"name": "api"}, "spec": {"template": {"spec": {
+        "serviceAccountName": "api",
+        "imagePullSecrets": [{"name": "stale"}],
+        "initContainers": [{"name": "init", "image": "busybox:1.36"}],
+        "containers": [{"name": "api", "image": acr_image}, {"name": "typo", "image": "nginx:1.255"}],
+    }}}}
+    handler, server = make_handler({
+        ("get", "deployments,statefulsets,daemonsets,cronjobs", "-n", "prod", "-o", "json"): {"items": [deployment]},
+        ("get", "serviceaccount", "api", "-n", "prod", "-o", "json"): {"imagePullSecrets": [{"name": "acr"}]},
+        ("get", "secret", "stale", "-n", "prod", "-o", "json"):
+            _docker_secret("registry.cn-hangzhou.aliyuncs.com", "old", "expired"),
+        ("get", "secret", "acr", "-n", "prod", "-o", "json"):
+            _docker_secret("registry.cn-hangzhou.aliyuncs.com", "ci", "secret"),
+    })
+    denied = {"status": "Unauthorized", "http_status": 401, "digest": None, "message": "credentials rejected"}
+    handler.registry = FakeRegistry({
+        (acr_image, ("old", "expired")): denied,
+        (acr_image, ("ci", "secret")): {"status": "Pullable", "http_status": 200, "digest": "sha256:1", "message": ""},
+        ("busybox:1.36", None): {"status": "Pullable", "http_status": 200, "digest": "sha256:2", "message": ""},
+        ("nginx:1.255", None): {"status": "NotFound", "http_status": 404, "digest": None, "message": "manifest not found"},
+    })
+    tool = server.tools["kubectl_image_pullability"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", workload_type=None, workload_name=None,
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.images[0].image == "nginx:1.255" and result.images[0].status == "NotFound"
+    checks = {c.image: c for c in result.images}
+    assert checks[acr_image].status == "Pullable" and checks[acr_image].auth == "acr"
+    assert checks[acr_image].used_by == ["Deployment/api[api]"]
+    assert checks["busybox:1.36"].auth == "anonymous"
+    assert result.summary == {"NotFound": 1, "Pullable": 2}
+    assert "expired" not in result.model_dump_json()
+
+
+@pytest.mark.asyncio
+async def test_admission_denials_grouped_by_policy():
+    webhook_message = 'Error creating: admission webhook "policy.kyverno.svc" denied the request: require-labels'
+    events = {"items": [
+        {"involvedObject": {"kind": "ReplicaSet", "name": "web-5d8f", "namespace": "prod"}, "reason": "FailedCreate",
+         "message": webhook_message, "count": 12, "lastTimestamp": "2024-01-31T11:00:00Z"},
+        {"involvedObject": {"kind": "Job", "name": "backup", "namespace": "prod"}, "reason": "FailedCreate",
+         "message": webhook_message, "count": 3, "lastTimestamp": "2024-01-31T11:30:00Z"},
+        {"involvedObject": {"kind": "ReplicaSet", "name": "api-7c9d", "namespace": "prod"}, "reason": "FailedCreate",
+         "message": 'Error creating: pods "api-7c9d-x" is forbidden: exceeded quota: compute, requested: cpu=1',
+         "count": 1, "lastTimestamp": "2024-01-31T10:00:00Z"},
+        {"involvedObject": {"kind": "Pod", "name": "web-0", "namespace": "prod"}, "reason": "BackOff",
+         "message": "Back-off restarting failed container", "count": 5},
+    ]}
+    handler, server = make_handler({
+        ("get", "events", "-n", "prod", "--field-selector=type=Warning", "-o", "json"): events,
+    })
+    tool = server.tools["kubectl_admission_denials"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.total_events == 3
+    assert [(g.category, g.policy, g.count) for g in result.groups] == [
+        ("WebhookDenied", "policy.kyverno.svc", 15), ("ResourceQuota", "compute", 1),
+    ]
+    assert result.groups[0].objects == ["prod/ReplicaSet/web-5d8f", "prod/Job/backup"]
+    assert result.groups[0].last_seen == "2024-01-31T11:30:00Z"
+
+
+def _service(selector=None, **spec):
+    return {"metadata": {"name": "web", "namespace": "prod"},
+            "spec": {"selector": selector, "ports": [{"name": "http", "port": 80, "targetPort": 8080}], **spec}}
+
+
+def _endpoint_pod(name, ip, ready=True, waiting=None):
+    state = {"waiting": {"reason": waiting}} if waiting else {"running": {}}
+    return {"metadata": {"name": name}, "spec": {"nodeName": "node-1"},
+            "status": {"phase": "Running", "podIP": ip,
+                       "conditions": [{"type": "Ready", "status": "True" if ready else "False"}],
+                       "containerStatuses": [{"name": "web", "state": state}]}}
+
+
+@pytest.mark.asyncio
+async def test_service_endpoints_correlates_pods_and_readiness():
+    slices = {"items": [{
+        "metadata": {"name": "web-abc12"},
+        "ports": [{"name": "http", "port": 8080, "protocol": "TCP"}],
+        "endpoints": [
+            {"addresses": ["10.0.0.2"], "conditions": {"ready": False},
+             "targetRef": {"kind": "Pod", "name": "web-2"}, "nodeName": "node-2"},
+            {"addresses": ["10.0.0.1"], "conditions": {"ready": True},
+             "targetRef": {"kind": "Pod", "name": "web-1"}, "nodeName": "node-1", "zone": "cn-hangzhou-k"},
+        ],
+    }]}
+    handler, server = make_handler({
+        ("get", "service", "web", "-n", "prod", "-o", "json"): _service({"app": "web"}),
+        ("get", "endpointslices.discovery.k8s.io", "-n", "prod", "-l", "kubernetes.io/service-name=web",
+         "-o", "json"): slices,
+        ("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json"): {"items": [
+            _endpoint_pod("web-1", "10.0.0.1"), _endpoint_pod("web-2", "10.0.0.2", waiting="CrashLoopBackOff"),
+        ]},
+    })
+    tool = server.tools["kubectl_service_endpoints"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", service="web",
+                        context="staging", timeout_seconds=None)
+
+    assert result.error is None
+    assert handler.runner.contexts == ["staging"]
+    assert result.status == "Ready" and result.message is None
+    assert (result.ready_count, result.not_ready_count, result.matching_pods) == (1, 1, 2)
+    assert [(e.pod, e.ready, e.node) for e in result.endpoints] == [("web-1", True, "node-1"), ("web-2", False, "node-2")]
+    assert result.endpoints[1].pod_problem == "CrashLoopBackOff"
+    assert result.endpoint_ports == [{"name": "http", "port": 8080, "protocol": "TCP"}]
+    assert result.ports[0]["target_port"] == 8080
+
+
+@pytest.mark.asyncio
+async def test_service_endpoints_explains_missing_ready_endpoints():
+    no_slices = KubectlCommandError(
+        "error: the server doesn't have a resource type \"endpointslices\"", stderr="doesn't have a resource type")
+    handler, server = make_handler({
+        ("get", "service", "web", "-n", "prod", "-o", "json"): _service({"app": "web"}),
+        ("get", "endpointslices.discovery.k8s.io", "-n", "prod", "-l", "kubernetes.io/service-name=web",
+         "-o", "json"): no_slices,
+        ("get", "endpoints", "web", "-n", "prod", "--ignore-not-found", "-o", "json"): {
+            "metadata": {"name": "web"},
+            "subsets": [{"notReadyAddresses": [{"ip": "10.0.0.3"}], "ports": [{"port": 8080}]}],
+        },
+        ("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json"): {"items": [
+            _endpoint_pod("web-3", "10.0.0.3", ready=False),
+        ]},
+    })
+    tool = server.tools["kubectl_service_endpoints"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", service="web",
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.status == "NoReadyEndpoints"
+    assert result.endpoints[0].pod == "web-3" and result.endpoints[0].source == "Endpoints/web"
+    assert "has no ready endpoints" in result.message
+    assert "1 pod(s) match the selector but none are ready (1 NotReady)" in result.message
+
+    handler.runner.responses[("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json")] = {"items": []}
+    handler.runner.responses[("get", "endpoints", "web", "-n", "prod", "--ignore-not-found", "-o", "json")] = {}
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", service="web",
+                        context=None, timeout_seconds=None)
+    assert result.status == "NoEndpoints"
+    assert "selector app=web matches no pods" in result.message
+
+
+def _triage_pod(name, statuses, owner=None, phase="Running"):
+    metadata = {"name": name, "namespace": "prod"}
+    if owner:
+        metadata["ownerReferences"] = [{"kind": "ReplicaSet", "name": owner, "controller": True}]
+        metadata["labels"] = {"pod-template-hash": owner.rsplit("-", 1)[1]}
+    return {"metadata": metadata, "spec": {"nodeName": "node-1"},
+            "status": {"phase": phase, "containerStatuses": statuses}}
+
+
+@pytest.mark.asyncio
+async def test_unhealthy_pods_sorts_worst_first():
+    oom = {"name": "app", "restartCount": 3, "ready": False,
+           "state": {"running": {}},
+           "lastState": {"terminated": {"reason": "OOMKilled", "exitCode": 137, "finishedAt": "2024-01-31T11:58:00Z"}}}
+    crashloop = {"name": "app", "restartCount": 12, "ready": False,
+                 "state": {"waiting": {"reason": "CrashLoopBackOff", "message": "back-off 5m0s restarting"}},
+                 "lastState": {"terminated": {"reason": "Error", "exitCode": 1}}}
+    image = {"name": "sidecar", "restartCount": 0, "state": {"waiting": {"reason": "ImagePullBackOff"}}}
+    flaky = {"name": "app", "restartCount": 6, "ready": True, "state": {"running": {}},
+             "lastState": {"terminated": {"reason": "Error", "exitCode": 143}}}
+    healthy = {"name": "app", "restartCount": 0, "ready": True, "state": {"running": {}}}
+    handler, server = make_handler({
+        ("get", "pods", "-n", "prod", "-l", "tier=backend", "-o", "json"): {"items": [
+            _triage_pod("flaky", [flaky]),
+            _triage_pod("healthy", [healthy]),
+            _triage_pod("pulling", [healthy, image]),
+            _triage_pod("web-abc12-x", [oom], owner="web-abc12"),
+            _triage_pod("worker", [crashloop]),
+            _triage_pod("done", [{"name": "job", "restartCount": 9,
+                                  "state": {"terminated": {"reason": "Completed", "exitCode": 0}}}], phase="Succeeded"),
+        ]},
+    })
+    tool = server.tools["kubectl_unhealthy_pods"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", label_selector="tier=backend",
+                        restart_threshold=5, context="staging", timeout_seconds=None)
+
+    assert result.error is None
+    assert handler.runner.contexts == ["staging"]
+    assert result.scanned == 6
+    assert [(p.name, p.problem, p.severity) for p in result.pods] == [
+        ("worker", "CrashLoopBackOff", 4), ("web-abc12-x", "OOMKilled", 4), ("pulling", "ImagePullBackOff", 3),
+        ("flaky", "HighRestarts", 1),
+    ]
+    worker = result.pods[0].containers[0]
+    assert (worker.last_termination_reason, worker.last_exit_code, worker.restart_count) == ("Error", 1, 12)
+    assert worker.message == "back-off 5m0s restarting"
+    assert result.pods[1].owner == "Deployment/web"
+    assert "SIGKILL" in result.pods[1].containers[0].exit_code_hint
+    assert [c.name for c in result.pods[2].containers] == ["sidecar"]
+    assert "SIGTERM" in result.pods[3].containers[0].exit_code_hint
+
+
+@pytest.mark.asyncio
+async def test_unhealthy_pods_validates_parameters():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_unhealthy_pods"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="all", label_selector="app in (web",
+                        restart_threshold=5, context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+    result = await tool(FakeContext(), cluster_id="c1", namespace="all", label_selector=None,
+                        restart_threshold=0, context=None, timeout_seconds=None)
+    assert "restart_threshold" in result.error.error_message
+    assert handler.runner.calls == []
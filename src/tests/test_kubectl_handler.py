@@ -1,3 +1,5 @@
+import asyncio
+import time
 import types
 import pytest
 import tempfile
@@ -8,6 +10,7 @@ import sys
 sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
 
 import kubectl_handler as module_under_test
+from kubectl_runner import KubectlRunner
 
 
 class FakeServer:
@@ -39,25 +42,17 @@ def make_handler_and_tool():
     return handler, tool
 
 
-class DummyCompleted:
-    def __init__(self, returncode=0, stdout="", stderr=""):
-        self.returncode = returncode
-        self.stdout = stdout
-        self.stderr = stderr
+def fake_exec(exit_code=0, stdout="", stderr=""):
+    """替换 KubectlRunner._exec，按参数列表校验 kubectl 命令并返回预置结果"""
+    async def _exec(cmd, timeout, stdin):
+        assert cmd[0] == "kubectl"
+        return {"exit_code": exit_code, "stdout": stdout, "stderr": stderr}
+    return staticmethod(_exec)
 
 
 @pytest.mark.asyncio
 async def test_kubectl_tool_success(monkeypatch):
-    def fake_run(*args, **kwargs):
-        # 获取命令参数，可能是字符串或列表
-        cmd = args[0] if args else None
-        if isinstance(cmd, str):
-            assert "kubectl" in cmd
-        elif isinstance(cmd, list):
-            assert cmd[0] == "kubectl"
-        return DummyCompleted(returncode=0, stdout="ok", stderr="")
-
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(stdout="ok"))
 
     _, tool = make_handler_and_tool()
     
@@ -97,11 +92,7 @@ async def test_kubectl_tool_success(monkeypatch):
 
 @pytest.mark.asyncio
 async def test_kubectl_tool_error(monkeypatch):
-    def fake_run(*args, **kwargs):
-        cmd = args[0] if args else None
-        raise module_under_test.subprocess.CalledProcessError(1, cmd, output="", stderr="boom")
-
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(exit_code=1, stderr="boom"))
 
     _, tool = make_handler_and_tool()
     
@@ -182,15 +173,7 @@ async def test_kubectl_with_cluster_id_success(monkeypatch):
             self.providers = fake_providers
             self.config = {"region_id": "cn-hangzhou"}
     
-    def fake_run(*args, **kwargs):
-        cmd = args[0] if args else None
-        if isinstance(cmd, str):
-            assert "kubectl" in cmd
-        elif isinstance(cmd, list):
-            assert cmd[0] == "kubectl"
-        return DummyCompleted(returncode=0, stdout="pods found", stderr="")
-    
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(stdout="pods found"))
     
     _, tool = make_handler_and_tool()
     ctx = FakeContext(FakeLifespanContext())
@@ -253,15 +236,7 @@ async def test_kubectl_without_cluster_id(monkeypatch):
     # 清理全局缓存
     module_under_test._context_manager = None
     
-    def fake_run(*args, **kwargs):
-        cmd = args[0] if args else None
-        if isinstance(cmd, str):
-            assert "kubectl" in cmd
-        elif isinstance(cmd, list):
-            assert cmd[0] == "kubectl"
-        return DummyCompleted(returncode=0, stdout="cluster pods", stderr="")
-    
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(stdout="cluster pods"))
     
     # Mock CS 客户端
     class FakeCSClient:
@@ -361,9 +336,6 @@ async def test_kubectl_temp_file_cleanup(monkeypatch):
             self.providers = fake_providers
             self.config = {"region_id": "cn-hangzhou"}
     
-    def fake_run(*args, **kwargs):
-        return DummyCompleted(returncode=0, stdout="success", stderr="")
-    
     # Mock tempfile.NamedTemporaryFile
     def mock_named_temporary_file(*args, **kwargs):
         class MockFile:
@@ -380,7 +352,7 @@ async def test_kubectl_temp_file_cleanup(monkeypatch):
             def __exit__(self, *args):
                 pass
         
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(stdout="success"))
     
     _, tool = make_handler_and_tool()
     ctx = FakeContext(FakeLifespanContext())
@@ -494,6 +466,18 @@ def test_is_write_command_readonly_with_parameters():
         assert error is None, f"Command '{command}' should not have error"
 
 
+def test_get_command_timeout_per_command_defaults_and_override():
+    """测试按命令类型的默认超时及 timeout_seconds 覆盖（受 max_tool_timeout 限制）"""
+    handler = module_under_test.KubectlHandler(None, {"kubectl_timeout": 30, "max_tool_timeout": 200})
+
+    assert handler.get_command_timeout("get pods") == 30
+    assert handler.get_command_timeout("exec my-pod -- ls") == 120
+    # logs -f 默认 300 秒，被服务端上限截断为 200
+    assert handler.get_command_timeout("logs my-pod -f") == 200
+    assert handler.get_command_timeout("get pods", timeout_seconds=5) == 5
+    assert handler.get_command_timeout("get pods", timeout_seconds=1000) == 200
+
+
 @pytest.mark.asyncio
 async def test_write_command_blocked_in_readonly_mode(monkeypatch):
     """测试在只读模式下写命令被阻止"""
@@ -537,12 +521,50 @@ async def test_write_command_blocked_in_readonly_mode(monkeypatch):
     assert "not allowed in read-only mode" in result.stderr
     
     # 测试只读命令正常执行
-    def fake_run(*args, **kwargs):
-        return DummyCompleted(returncode=0, stdout="pods found", stderr="")
-    
-    monkeypatch.setattr(module_under_test.subprocess, "run", fake_run)
+    monkeypatch.setattr(KubectlRunner, "_exec", fake_exec(stdout="pods found"))
     
     result = await tool(ctx, command="get pods", cluster_id="test-cluster")
     assert result.exit_code == 0
     assert result.stdout == "pods found"
 
+
+class FakeContextManager:
+    _cs_client = object()
+
+    def get_kubeconfig_path(self, *args):
+        return "/tmp/kubeconfig"
+
+
+@pytest.mark.asyncio
+async def test_cancelled_call_kills_the_kubectl_process(monkeypatch):
+    """测试调用被取消（客户端断开、服务退出）时 kubectl 进程被终止，且执行期间不阻塞事件循环"""
+    monkeypatch.setattr(module_under_test, "get_context_manager", lambda: FakeContextManager())
+    handler, tool = make_handler_and_tool()
+    # 以长时间运行的进程模拟响应缓慢的 API Server 与持续输出的 logs -f
+    monkeypatch.setattr(handler.runner, "_command", lambda kubeconfig_path, args: [
+        sys.executable, "-c", "import time; time.sleep(30)",
+    ])
+    processes = []
+    create_subprocess_exec = asyncio.create_subprocess_exec
+
+    async def recording_create_subprocess_exec(*args, **kwargs):
+        processes.append(await create_subprocess_exec(*args, **kwargs))
+        return processes[-1]
+
+    monkeypatch.setattr(asyncio, "create_subprocess_exec", recording_create_subprocess_exec)
+
+    start = time.monotonic()
+    with pytest.raises(asyncio.TimeoutError):
+        await asyncio.wait_for(
+            tool(FakeContext(), command="get pods", cluster_id="c1", context=None, timeout_seconds=60), 0.5
+        )
+    with pytest.raises(asyncio.TimeoutError):
+        await asyncio.wait_for(
+            handler.run_streaming_command("logs -f web-1", "/tmp/kubeconfig", 60, module_under_test.ExecutionLog(
+                tool_call_id="t"
+            )),
+            0.5,
+        )
+
+    assert time.monotonic() - start < 5
+    assert len(processes) == 2 and all(process.returncode is not None for process in processes)
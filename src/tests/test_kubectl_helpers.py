@@ -0,0 +1,637 @@
+import base64
+import json
+import os
+import sys
+from datetime import datetime, timezone
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import kubectl_helpers as helpers
+
+
+# 自签名证书：CN=example.com，SAN=example.com,*.example.com,IP:10.0.0.1，有效期 2026-10-15 ~ 2027-10-15
+TEST_CERT_PEM = """-----BEGIN CERTIFICATE-----
+MIIBrjCCAVWgAwIBAgIUUCA6YtOTE1531Mm3X7NmQBOXIzIwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLZXhhbXBsZS5jb20wHhcNMjYxMDE1MDcxMDU4WhcNMjcxMDE1
+MDcxMDU4WjAWMRQwEgYDVQQDDAtleGFtcGxlLmNvbTBZMBMGByqGSM49AgEGCCqG
+SM49AwEHA0IABMa5t+RTqAPujxUmbo6E6sRKWL0np20KmhQPCLGgcnHbvfrjfy8T
+eoc4a66QStzhNzHNB9PzezjVlPuW/WLnK6+jgYAwfjAdBgNVHQ4EFgQUg8NI6IF+
+TCztuB7Vphor1g0iQNAwHwYDVR0jBBgwFoAUg8NI6IF+TCztuB7Vphor1g0iQNAw
+DwYDVR0TAQH/BAUwAwEB/zArBgNVHREEJDAiggtleGFtcGxlLmNvbYINKi5leGFt
+cGxlLmNvbYcECgAAATAKBggqhkjOPQQDAgNHADBEAiAlSwRF6rW6aozdg37rYPyb
+BH4xHwfNBpBBSv02EVkcBgIgHCNJC7cqAWS6ReFITLy7xHFxnv4iF9hGL9fPCORu
+ALs=
+-----END CERTIFICATE-----
+"""
+
+
+def _tls_secret(pem: str = TEST_CERT_PEM):
+    return {"data": {"tls.crt": base64.b64encode(pem.encode()).decode(), "tls.key": "c2VjcmV0"}}
+
+
+def test_parse_pem_certificates_extracts_subject_validity_and_san():
+    certs = helpers.parse_pem_certificates(TEST_CERT_PEM)
+    assert len(certs) == 1
+    cert = certs[0]
+    assert cert["subject_cn"] == "example.com"
+    assert cert["issuer_cn"] == "example.com"
+    assert cert["not_after"] == datetime(2027, 10, 15, 7, 10, 58, tzinfo=timezone.utc)
+    assert cert["dns_names"] == ["example.com", "*.example.com", "10.0.0.1"]
+
+
+def test_hostname_matches_wildcard_single_label():
+    patterns = ["example.com", "*.example.com"]
+    assert helpers.hostname_matches("www.example.com", patterns)
+    assert helpers.hostname_matches("EXAMPLE.com", patterns)
+    assert not helpers.hostname_matches("a.b.example.com", patterns)
+    assert not helpers.hostname_matches("example.org", patterns)
+
+
+def test_inspect_tls_secret_status_by_expiry():
+    ok = helpers.inspect_tls_secret(_tls_secret(), now=datetime(2027, 1, 1, tzinfo=timezone.utc))
+    assert ok["status"] == "OK"
+    assert ok["not_after"] == "2027-10-15T07:10:58Z"
+    assert ok["chain_length"] == 1
+    assert "tls.key" not in ok
+
+    expiring = helpers.inspect_tls_secret(_tls_secret(), now=datetime(2027, 10, 1, tzinfo=timezone.utc))
+    assert expiring["status"] == "Expiring"
+    assert expiring["days_until_expiry"] == 14
+
+    expired = helpers.inspect_tls_secret(_tls_secret(), now=datetime(2028, 1, 1, tzinfo=timezone.utc))
+    assert expired["status"] == "Expired"
+    assert expired["days_until_expiry"] < 0
+
+
+def test_inspect_tls_secret_invalid_inputs():
+    assert helpers.inspect_tls_secret({"data": {}})["status"] == "Invalid"
+    garbage = {"data": {"tls.crt": base64.b64encode(b"not a cert").decode()}}
+    assert helpers.inspect_tls_secret(garbage)["status"] == "Invalid"
+
+
+def test_parse_k8s_time():
+    assert helpers.parse_k8s_time("2024-01-01T00:00:00Z") == datetime(2024, 1, 1, tzinfo=timezone.utc)
+    assert helpers.parse_k8s_time(None) is None
+    assert helpers.parse_k8s_time("bogus") is None
+
+
+def test_kubectl_operation_classifies_long_running_commands():
+    assert helpers.kubectl_operation("logs my-pod -f") == "logs"
+    assert helpers.kubectl_operation("logs my-pod") == "logs-once"
+    assert helpers.kubectl_operation("get pods -w") == "watch"
+    assert helpers.kubectl_operation("exec my-pod -- ls") == "exec"
+    assert helpers.kubectl_operation("get pods") == "get"
+    assert helpers.kubectl_operation("") == ""
+
+
+def test_resolve_timeout_uses_default_and_caps_at_max():
+    assert helpers.resolve_timeout(None, 30, 600) == 30
+    assert helpers.resolve_timeout(0, 30, 600) == 30
+    assert helpers.resolve_timeout(120, 30, 600) == 120
+    assert helpers.resolve_timeout(3600, 30, 600) == 600
+    assert helpers.resolve_timeout(None, 900, 600) == 600
+    # 直接调用工具函数时未传参的 pydantic FieldInfo 按未指定处理
+    assert helpers.resolve_timeout(object(), 30, 600) == 30
+
+
+def test_parse_quantity_units():
+    assert helpers.parse_quantity("100m") == 0.1
+    assert helpers.parse_quantity("2") == 2.0
+    assert helpers.parse_quantity("1Gi") == 2 ** 30
+    assert helpers.parse_quantity("1G") == 1e9
+    assert helpers.parse_quantity(None) == 0.0
+    with pytest.raises(ValueError):
+        helpers.parse_quantity("12xyz")
+
+
+def test_pod_resource_requests_accounts_for_init_containers_and_overhead():
+    pod = {"spec": {
+        "containers": [
+            {"resources": {"requests": {"cpu": "100m", "memory": "64Mi"}}},
+            {"resources": {"requests": {"cpu": "200m"}}},
+        ],
+        "initContainers": [{"resources": {"requests": {"cpu": "500m", "memory": "32Mi"}}}],
+        "overhead": {"cpu": "10m"},
+    }}
+    requests = helpers.pod_resource_requests(pod)
+    assert requests["cpu"] == pytest.approx(0.51)
+    assert requests["memory"] == 64 * 2 ** 20
+
+
+def test_parse_duration_and_format_age():
+    from datetime import timedelta
+    assert helpers.parse_duration("10m") == timedelta(minutes=10)
+    assert helpers.parse_duration("1h30m") == timedelta(hours=1, minutes=30)
+    assert helpers.parse_duration("7d") == timedelta(days=7)
+    with pytest.raises(ValueError):
+        helpers.parse_duration("7 days")
+    assert helpers.format_age(timedelta(seconds=45)) == "45s"
+    assert helpers.format_age(timedelta(hours=3, minutes=20)) == "3h20m"
+    assert helpers.format_age(timedelta(days=40)) == "40d"
+
+
+def test_parse_server_date_from_verbose_output():
+    verbose = "I0131 round_trippers.go:560] Response Headers:\n    Date: Wed, 31 Jan 2024 12:00:00 GMT\n"
+    assert helpers.parse_server_date(verbose) == datetime(2024, 1, 31, 12, 0, tzinfo=timezone.utc)
+    assert helpers.parse_server_date("no headers") is None
+
+
+def test_json_diff_matches_named_lists_and_detects_defaults():
+    before = {"spec": {"containers": [{"name": "app", "image": "nginx"}]}}
+    after = {"spec": {
+        "containers": [
+            {"name": "app", "image": "nginx", "imagePullPolicy": "Always"},
+            {"name": "sidecar", "image": "proxy"},
+        ],
+        "tolerations": [{"key": "node.kubernetes.io/not-ready", "operator": "Exists"}],
+    }}
+    changes = {c["path"]: c for c in helpers.json_diff(before, after)}
+    assert changes["spec.containers[sidecar]"]["op"] == "added"
+    assert helpers.is_builtin_default_change(changes["spec.containers[app].imagePullPolicy"])
+    assert not helpers.is_builtin_default_change(changes["spec.containers[sidecar]"])
+    stripped = helpers.strip_server_fields({"metadata": {"name": "a", "uid": "x"}, "status": {}})
+    assert stripped == {"metadata": {"name": "a"}}
+
+
+def test_pod_ephemeral_storage_reports_missing_limits_and_empty_dirs():
+    pod = {"spec": {
+        "containers": [
+            {"name": "app", "resources": {"requests": {"ephemeral-storage": "1Gi"},
+                                          "limits": {"ephemeral-storage": "2Gi"}}},
+            {"name": "logger"},
+        ],
+        "volumes": [
+            {"name": "cache", "emptyDir": {}},
+            {"name": "shm", "emptyDir": {"medium": "Memory"}},
+            {"name": "tmp", "emptyDir": {"sizeLimit": "1Gi"}},
+        ],
+    }}
+    result = helpers.pod_ephemeral_storage(pod)
+    assert result["requests"] == 2 ** 30
+    assert result["limits"] is None
+    assert result["containers_without_limit"] == ["logger"]
+    assert result["unbounded_empty_dirs"] == ["cache"]
+
+
+def test_object_references_for_cronjob_skips_optional():
+    cronjob = {"kind": "CronJob", "spec": {"jobTemplate": {"spec": {"template": {"spec": {
+        "serviceAccountName": "runner",
+        "imagePullSecrets": [{"name": "registry"}],
+        "containers": [{"name": "job", "envFrom": [{"configMapRef": {"name": "opt", "optional": True}}]}],
+        "volumes": [{"name": "data", "persistentVolumeClaim": {"claimName": "job-data"}}],
+    }}}}}}
+    refs = {(r["kind"], r["name"]) for r in helpers.object_references(cronjob)}
+    assert refs == {("ServiceAccount", "runner"), ("Secret", "registry"), ("PersistentVolumeClaim", "job-data")}
+
+
+def test_parse_k8s_version_and_skew_policy():
+    assert helpers.parse_k8s_version("v1.30.1-aliyun.1") == (1, 30, 1)
+    assert helpers.parse_k8s_version("1.28") == (1, 28, 0)
+    assert helpers.parse_k8s_version("unknown") is None
+    assert helpers.max_kubelet_minor_skew(28) == 3
+    assert helpers.max_kubelet_minor_skew(27) == 2
+
+
+def test_label_selector_matches_expressions_and_pod_owner():
+    labels = {"app": "web", "tier": "front"}
+    assert helpers.label_selector_matches({}, labels)
+    assert not helpers.label_selector_matches(None, labels)
+    assert helpers.label_selector_matches({"matchExpressions": [
+        {"key": "app", "operator": "In", "values": ["web", "api"]},
+        {"key": "canary", "operator": "DoesNotExist"},
+    ]}, labels)
+    assert not helpers.label_selector_matches({"matchLabels": {"tier": "back"}}, labels)
+
+    pod = {"metadata": {"name": "web-5d8f-abc", "labels": {"pod-template-hash": "5d8f"},
+                        "ownerReferences": [{"kind": "ReplicaSet", "name": "web-5d8f", "controller": True}]}}
+    assert helpers.pod_owner(pod) == ("Deployment", "web")
+    assert helpers.pod_owner({"metadata": {"name": "bare"}}) == ("Pod", "bare")
+
+
+def test_parse_image_reference_defaults_and_registries():
+    assert helpers.parse_image_reference("nginx") == ("registry-1.docker.io", "library/nginx", "latest")
+    assert helpers.parse_image_reference("bitnami/redis:7.2") == ("registry-1.docker.io", "bitnami/redis", "7.2")
+    assert helpers.parse_image_reference("registry.cn-hangzhou.aliyuncs.com/ns/app@sha256:ab") == (
+        "registry.cn-hangzhou.aliyuncs.com", "ns/app", "sha256:ab")
+    assert helpers.parse_image_reference("localhost:5000/app:v1") == ("localhost:5000", "app", "v1")
+
+
+def test_registry_auth_parsing():
+    scheme, params = helpers.parse_www_authenticate(
+        'Bearer realm="https://dockerauth.cn-hangzhou.aliyuncs.com/auth",service="registry.aliyuncs.com:cn-hangzhou:26842"'
+    )
+    assert scheme == "bearer"
+    assert params["realm"] == "https://dockerauth.cn-hangzhou.aliyuncs.com/auth"
+
+    config = {"auths": {
+        "https://index.docker.io/v1/": {"auth": base64.b64encode(b"hub-user:hub-pass").decode()},
+        "registry.cn-hangzhou.aliyuncs.com": {"username": "acr-user", "password": "acr-pass"},
+    }}
+    secret = {"data": {".dockerconfigjson": base64.b64encode(json.dumps(config).encode()).decode()}}
+    assert helpers.docker_config_auths(secret) == {
+        "registry-1.docker.io": ("hub-user", "hub-pass"),
+        "registry.cn-hangzhou.aliyuncs.com": ("acr-user", "acr-pass"),
+    }
+    assert helpers.docker_config_auths({"data": {}}) == {}
+
+
+def test_classify_admission_denial_messages():
+    assert helpers.classify_admission_denial(
+        'Error creating: admission webhook "validation.gatekeeper.sh" denied the request: [no-latest] bad tag'
+    ) == {"category": "WebhookDenied", "policy": "validation.gatekeeper.sh"}
+    assert helpers.classify_admission_denial(
+        'Error creating: pods "web-1" is forbidden: exceeded quota: compute, requested: cpu=2, used: cpu=8, limited: cpu=8'
+    ) == {"category": "ResourceQuota", "policy": "compute"}
+    assert helpers.classify_admission_denial(
+        'Error creating: pods "web-1" is forbidden: violates PodSecurity "restricted:latest": privileged'
+    )["category"] == "PodSecurity"
+    assert helpers.classify_admission_denial("Back-off restarting failed container") is None
+
+
+def test_classify_kubectl_error():
+    forbidden = helpers.classify_kubectl_error(
+        'Error from server (Forbidden): pods is forbidden: User "system:serviceaccount:ops:mcp" cannot list '
+        'resource "pods" in API group "" in the namespace "prod"'
+    )
+    assert forbidden["error_code"] == "Forbidden"
+    assert "verbs=[list] resources=[pods]" in forbidden["error_message"]
+    assert 'in namespace "prod"' in forbidden["error_message"]
+    assert "RoleBinding" in forbidden["error_message"]
+
+    cluster = helpers.classify_kubectl_error(
+        'Error from server (Forbidden): nodes is forbidden: User "alice" cannot list resource "nodes" '
+        'in API group "" at the cluster scope'
+    )
+    assert "at the cluster scope" in cluster["error_message"]
+    assert "ClusterRoleBinding" in cluster["error_message"]
+
+    impersonate = helpers.classify_kubectl_error(
+        'Error from server (Forbidden): users "alice" is forbidden: User "system:serviceaccount:ops:mcp" cannot '
+        'impersonate resource "users" in API group "" at the cluster scope'
+    )
+    assert impersonate["error_code"] == "Forbidden"
+    assert 'server identity "system:serviceaccount:ops:mcp" is not allowed to impersonate users' in impersonate["error_message"]
+    assert "verbs=[impersonate]" in impersonate["error_message"]
+
+    not_found = helpers.classify_kubectl_error('Error from server (NotFound): pods "web-1" not found')
+    assert not_found == {"error_code": "NotFound", "error_message": 'pods "web-1" not found'}
+
+    unauthorized = helpers.classify_kubectl_error("error: You must be logged in to the server (Unauthorized)")
+    assert unauthorized["error_code"] == "Unauthorized"
+    assert helpers.classify_kubectl_error("connection refused") is None
+
+
+def test_trim_object_strips_noise_from_objects_and_lists():
+    pod = {
+        "kind": "Pod",
+        "metadata": {
+            "name": "web-1",
+            "generateName": "web-",
+            "managedFields": [{"manager": "kubectl"}],
+            "annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}", "team": "a"},
+        },
+        "spec": {},
+    }
+    trimmed = helpers.trim_object(pod)
+    assert trimmed["metadata"] == {"name": "web-1", "annotations": {"team": "a"}}
+    assert "managedFields" in pod["metadata"]
+
+    bare = {"metadata": {"name": "x", "annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}"}}}
+    listed = helpers.trim_object({"kind": "List", "items": [bare]})
+    assert listed["items"][0]["metadata"] == {"name": "x"}
+
+
+def _server_metadata(name, **extra):
+    return {
+        "name": name, "namespace": "prod", "uid": "1234", "resourceVersion": "42", "generation": 3,
+        "creationTimestamp": "2024-01-31T11:00:00Z", "selfLink": f"/apis/x/{name}",
+        "managedFields": [{"manager": "kube-controller-manager"}], **extra,
+    }
+
+
+def test_clean_for_export_pod():
+    pod = {
+        "apiVersion": "v1", "kind": "Pod",
+        "metadata": _server_metadata(
+            "web-7d9f-abcde", generateName="web-7d9f-", labels={"app": "web"},
+            ownerReferences=[{"kind": "ReplicaSet", "name": "web-7d9f"}],
+            annotations={"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+        ),
+        "spec": {"nodeName": "node-1", "containers": [{"name": "web", "image": "nginx:1.25"}]},
+        "status": {"phase": "Running", "podIP": "10.0.0.1"},
+    }
+
+    exported = helpers.clean_for_export(pod)
+
+    assert exported == {
+        "apiVersion": "v1", "kind": "Pod",
+        "metadata": {"name": "web-7d9f-abcde", "namespace": "prod", "labels": {"app": "web"}},
+        "spec": {"containers": [{"name": "web", "image": "nginx:1.25"}]},
+    }
+    assert pod["status"] and pod["metadata"]["uid"] == "1234" and pod["spec"]["nodeName"] == "node-1"
+
+
+def test_clean_for_export_deployment():
+    deployment = {
+        "apiVersion": "apps/v1", "kind": "Deployment",
+        "metadata": _server_metadata("web", annotations={
+            "deployment.kubernetes.io/revision": "7",
+            "kubectl.kubernetes.io/last-applied-configuration": "{}",
+            "team": "payments",
+        }),
+        "spec": {"replicas": 3, "selector": {"matchLabels": {"app": "web"}},
+                 "template": {"metadata": {"labels": {"app": "web"}}, "spec": {"containers": [{"name": "web"}]}}},
+        "status": {"readyReplicas": 3, "observedGeneration": 3},
+    }
+
+    exported = helpers.clean_for_export(deployment)
+
+    assert exported["metadata"] == {"name": "web", "namespace": "prod", "annotations": {"team": "payments"}}
+    assert exported["spec"] == deployment["spec"]
+    assert "status" not in exported
+
+
+def test_validate_label_selector():
+    for selector in ["app=nginx", "app==web,tier!=db", "env in (prod, staging),!canary",
+                     "app.kubernetes.io/name=web", "release", "team="]:
+        helpers.validate_label_selector(selector)
+    for selector in ["", "app=ngi nx", "app in ()", "=x", "app=web,", "app=-bad", "Example.COM/x=1"]:
+        with pytest.raises(ValueError):
+            helpers.validate_label_selector(selector)
+
+
+def test_parse_field_selector():
+    assert helpers.parse_field_selector("status.phase=Running,spec.nodeName!=node-1") == [
+        ("status.phase", "=", "Running"), ("spec.nodeName", "!=", "node-1"),
+    ]
+    assert helpers.parse_field_selector("involvedObject.name==web-1") == [("involvedObject.name", "=", "web-1")]
+    for selector in ["", "status.phase", "=Running", "status.phase=Running,"]:
+        with pytest.raises(ValueError):
+            helpers.parse_field_selector(selector)
+
+
+def test_parse_api_resources():
+    output = (
+        "NAME                SHORTNAMES     APIVERSION                  NAMESPACED   KIND\n"
+        "bindings                           v1                          true         Binding\n"
+        "applicationsets     appset,appsets argoproj.io/v1alpha1        true         ApplicationSet\n"
+        "nodes               no             v1                          false        Node\n"
+    )
+    resources = helpers.parse_api_resources(output)
+    assert resources[0] == {"name": "bindings", "short_names": [], "group": "", "version": "v1",
+                            "namespaced": True, "kind": "Binding"}
+    assert resources[1]["short_names"] == ["appset", "appsets"]
+    assert resources[1]["group"] == "argoproj.io"
+    assert resources[2]["namespaced"] is False
+    assert helpers.parse_api_resources("error: unknown") == []
+
+
+def test_parse_manifest_supports_json_multi_document_and_lists():
+    manifest = (
+        '{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}\n'
+        "---\n"
+        "apiVersion: v1\n"
+        "kind: List\n"
+        "items:\n"
+        "- apiVersion: v1\n"
+        "  kind: Service\n"
+        "  metadata: {name: b}\n"
+        "---\n"
+    )
+    objects = helpers.parse_manifest(manifest)
+    assert [(o["kind"], o["metadata"]["name"]) for o in objects] == [("ConfigMap", "a"), ("Service", "b")]
+
+    with pytest.raises(ValueError, match="metadata.name"):
+        helpers.parse_manifest("apiVersion: v1\nkind: ConfigMap\nmetadata: {}")
+    with pytest.raises(ValueError, match="no objects"):
+        helpers.parse_manifest("---\n")
+
+
+
+def test_manifest_diff_reports_create_update_and_unchanged():
+    desired = {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web", "labels": {"app": "web"}},
+               "data": {"mode": "prod"}}
+    status, diff = helpers.manifest_diff(None, desired)
+    assert status == "create"
+    assert "+++ desired/ConfigMap/web" in diff and "+  mode: prod" in diff
+
+    live = {
+        "apiVersion": "v1", "kind": "ConfigMap",
+        "metadata": {"name": "web", "namespace": "prod", "uid": "u1", "resourceVersion": "7",
+                     "labels": {"app": "web", "team": "a"}, "managedFields": [{"manager": "kubectl"}]},
+        "data": {"mode": "prod"},
+    }
+    # 仅存在于实际对象的字段（其他标签、服务端字段）不参与对比
+    assert helpers.manifest_diff(live, desired) == ("unchanged", "")
+
+    status, diff = helpers.manifest_diff(live, {**desired, "data": {"mode": "staging"}})
+    assert status == "update"
+    assert "-  mode: prod" in diff and "+  mode: staging" in diff
+    assert "resourceVersion" not in diff and "team" not in diff
+
+
+def test_manifest_diff_shows_secret_values_as_digests():
+    live = {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "db", "namespace": "prod"},
+            "data": {"password": "b2xk"}}
+    desired = {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "db"}, "stringData": {"password": "new"}}
+
+    status, diff = helpers.manifest_diff(live, desired)
+
+    assert status == "update"
+    assert "b2xk" not in diff and "new" not in diff and "sha256:" in diff
+    assert helpers.manifest_diff(live, {**desired, "stringData": {"password": "old"}})[0] == "unchanged"
+
+def test_deployment_rollout_status():
+    deployment = {
+        "metadata": {"name": "web", "generation": 5},
+        "spec": {"replicas": 2},
+        "status": {"observedGeneration": 5, "replicas": 2, "updatedReplicas": 2, "readyReplicas": 2,
+                   "availableReplicas": 2},
+    }
+    assert helpers.deployment_rollout_status(deployment)["state"] == "complete"
+
+    deployment["status"].update(updatedReplicas=1, conditions=[
+        {"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded",
+         "message": 'ReplicaSet "web-abc" has timed out progressing.'},
+        {"type": "ReplicaFailure", "status": "True", "reason": "FailedCreate"},
+    ])
+    status = helpers.deployment_rollout_status(deployment)
+    assert status["state"] == "stuck"
+    assert "has timed out progressing" in status["message"]
+    assert [c["type"] for c in status["conditions"]] == ["Progressing"]
+
+    deployment["status"]["conditions"] = []
+    deployment["spec"]["paused"] = True
+    status = helpers.deployment_rollout_status(deployment)
+    assert status["state"] == "progressing"
+    assert status["message"] == "1 out of 2 new replicas have been updated (rollout is paused)"
+
+
+def test_jsonpath_values_and_custom_columns():
+    pod = {
+        "metadata": {"name": "web", "labels": {"app.kubernetes.io/name": "web"}},
+        "spec": {"containers": [{"name": "app", "image": "nginx"}, {"name": "sidecar", "image": "envoy"}]},
+    }
+    assert helpers.jsonpath_values(pod, ".metadata.name") == ["web"]
+    assert helpers.jsonpath_values(pod, "{.spec.containers[*].image}") == ["nginx", "envoy"]
+    assert helpers.jsonpath_values(pod, ".spec.containers[-1].name") == ["sidecar"]
+    assert helpers.jsonpath_values(pod, r".metadata.labels.app\.kubernetes\.io/name") == ["web"]
+    assert helpers.jsonpath_values(pod, ".metadata.labels['app.kubernetes.io/name']") == ["web"]
+    assert helpers.jsonpath_values(pod, ".spec.nodeName") == []
+    with pytest.raises(ValueError):
+        helpers.jsonpath_values(pod, "..name")
+
+    assert helpers.parse_custom_columns("NAME:.metadata.name, NODE:.spec.nodeName") == [
+        ("NAME", ".metadata.name"), ("NODE", ".spec.nodeName"),
+    ]
+    for spec in ["", "NAME", "NAME:", ":.metadata.name", "NAME:.spec[?(@.x)]"]:
+        with pytest.raises(ValueError):
+            helpers.parse_custom_columns(spec)
+
+    assert helpers.format_cell(None) == "<none>"
+    assert helpers.format_cell(["a", "b"]) == "a,b"
+    assert helpers.format_cell({"b": 1, "a": "x"}) == '{"a":"x","b":1}'
+    assert helpers.format_table(["NAME", "AGE"], [["web-1", "5m"], ["w", ""]]) == "NAME    AGE\nweb-1   5m\nw"
+
+
+def test_controller_owner_and_ownership_status():
+    refs = [{"kind": "Node", "name": "node-1", "uid": "n1"},
+            {"kind": "ReplicaSet", "name": "web-abc", "uid": "rs1", "controller": True}]
+    pod = {"kind": "Pod", "metadata": {"ownerReferences": refs},
+           "spec": {"containers": [{"name": "app"}]}, "status": {"phase": "Running", "containerStatuses": [{"ready": True}]}}
+    assert helpers.controller_owner(pod)["name"] == "web-abc"
+    assert helpers.controller_owner({"metadata": {"ownerReferences": refs[:1]}})["kind"] == "Node"
+    assert helpers.controller_owner({"metadata": {}}) is None
+    assert helpers.is_owned_by(pod, "n1") and not helpers.is_owned_by(pod, "other")
+
+    assert helpers.ownership_status(pod) == "Running, 1/1 ready"
+    assert helpers.ownership_status({"kind": "Deployment", "spec": {"replicas": 3}, "status": {"readyReplicas": 2}}) == "2/3 ready"
+    assert helpers.ownership_status({"kind": "Job", "spec": {}, "status": {"failed": 2}}) == "0/1 succeeded, 2 failed"
+    assert helpers.ownership_status({"kind": "Widget"}) is None
+
+
+def test_parse_metadata_changes_and_merge_patch():
+    assert helpers.parse_metadata_changes(["env=prod", "example.com/tier-", "note="]) == {
+        "env": "prod", "example.com/tier": None, "note": "",
+    }
+    with pytest.raises(ValueError, match="invalid label value"):
+        helpers.parse_metadata_changes(["env=not valid"])
+    # 注解值不受标签语法限制，但键仍需合法
+    assert helpers.parse_metadata_changes(["reason=scaled by on-call, see INC-1"], "annotation") == {
+        "reason": "scaled by on-call, see INC-1",
+    }
+    with pytest.raises(ValueError, match="invalid annotation key 'Bad_Prefix/x'"):
+        helpers.parse_metadata_changes(["Bad_Prefix/x=1"], "annotation")
+    with pytest.raises(ValueError, match="key- to remove"):
+        helpers.parse_metadata_changes(["env"])
+    with pytest.raises(ValueError, match="more than once"):
+        helpers.parse_metadata_changes(["env=a", "env-"])
+
+    current = {"env": "dev", "team": "core"}
+    changes = {"env": "prod", "team": "core", "tier": None, "app": "web"}
+    with pytest.raises(ValueError, match=r"'env' already has a value \(dev\)"):
+        helpers.metadata_merge_patch(current, changes)
+    patch, warnings = helpers.metadata_merge_patch(current, changes, overwrite=True)
+    assert patch == {"env": "prod", "app": "web"}
+    assert warnings == ["label 'tier' not found, nothing to remove"]
+    with pytest.raises(ValueError, match="exceeding"):
+        helpers.metadata_merge_patch({}, {"big": "x" * helpers.MAX_ANNOTATIONS_BYTES}, kind="annotation")
+
+
+def test_parse_patch_validates_structure_for_patch_type():
+    assert helpers.parse_patch("merge", '{"spec": {"replicas": 3}}') == {"spec": {"replicas": 3}}
+    assert helpers.parse_patch("json", '[{"op": "remove", "path": "/metadata/labels/tier"}]') == [
+        {"op": "remove", "path": "/metadata/labels/tier"},
+    ]
+    with pytest.raises(ValueError, match="must be one of: json, merge, strategic"):
+        helpers.parse_patch("apply", "{}")
+    with pytest.raises(ValueError, match="not valid JSON"):
+        helpers.parse_patch("merge", "spec: {replicas: 3}")
+    with pytest.raises(ValueError, match="strategic patch must be a non-empty JSON object"):
+        helpers.parse_patch("strategic", '[{"op": "add"}]')
+    with pytest.raises(ValueError, match="non-empty array of operations"):
+        helpers.parse_patch("json", '{"spec": {}}')
+    with pytest.raises(ValueError, match="operation 1 \\(replace\\) is missing 'value'"):
+        helpers.parse_patch("json", '[{"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/a"}]')
+    with pytest.raises(ValueError, match="JSON Pointer"):
+        helpers.parse_patch("json", '[{"op": "add", "path": "spec/replicas", "value": 1}]')
+
+
+def test_unhealthy_containers_and_exit_code_hints():
+    pod = {"status": {"phase": "Running",
+                      "initContainerStatuses": [{"name": "init", "restartCount": 0,
+                                                 "state": {"terminated": {"reason": "Completed", "exitCode": 0}}}],
+                      "containerStatuses": [
+                          {"name": "app", "restartCount": 2, "state": {"waiting": {"reason": "ContainerCreating"}},
+                           "lastState": {"terminated": {"reason": "OOMKilled", "exitCode": 137}}},
+                          {"name": "proxy", "restartCount": 1,
+                           "state": {"terminated": {"reason": "Error", "exitCode": 127}}},
+                      ]}}
+    containers = helpers.unhealthy_containers(pod, restart_threshold=5)
+    assert [(c["name"], c["problem"], c["severity"]) for c in containers] == [
+        ("app", "OOMKilled", 4), ("proxy", "Error", 2),
+    ]
+    assert containers[0]["reason"] == "ContainerCreating" and containers[0]["last_exit_code"] == 137
+    assert containers[1]["exit_code_hint"] == "command not found in the image"
+    assert helpers.unhealthy_containers({"status": {"phase": "Succeeded", "containerStatuses": [
+        {"name": "job", "restartCount": 10, "state": {"terminated": {"exitCode": 1}}}]}}, 5) == []
+
+    assert helpers.exit_code_hint(None) is None
+    assert helpers.exit_code_hint(2) is None
+    assert helpers.exit_code_hint(130) == "killed by signal 2"
+
+
+def test_drain_skip_reason_follows_kubectl_drain_rules():
+    def pod(owner_kind=None, phase="Running", volumes=None, annotations=None):
+        metadata = {"name": "p", "annotations": annotations or {}}
+        if owner_kind:
+            metadata["ownerReferences"] = [{"kind": owner_kind, "name": "owner", "controller": True}]
+        return {"metadata": metadata, "spec": {"volumes": volumes or []}, "status": {"phase": phase}}
+
+    assert helpers.drain_skip_reason(pod("ReplicaSet")) is None
+    assert helpers.drain_skip_reason(pod("DaemonSet"), force=True) == "managed by DaemonSet owner"
+    assert helpers.drain_skip_reason(pod(annotations={helpers.MIRROR_POD_ANNOTATION: "x"}), force=True).startswith("mirror")
+    assert "force=true" in helpers.drain_skip_reason(pod())
+    assert helpers.drain_skip_reason(pod(), force=True) is None
+    # 已结束的 Pod 无论是否受控都直接驱逐
+    assert helpers.drain_skip_reason(pod(phase="Succeeded")) is None
+    empty_dir = [{"name": "cache", "emptyDir": {}}]
+    assert "delete_emptydir_data" in helpers.drain_skip_reason(pod("ReplicaSet", volumes=empty_dir))
+    assert helpers.drain_skip_reason(pod("ReplicaSet", volumes=empty_dir), delete_emptydir_data=True) is None
+
+
+def test_split_exec_exit_code():
+    assert helpers.split_exec_exit_code("ls: /nope: No such file or directory\ncommand terminated with exit code 2\n") == (
+        2, "ls: /nope: No such file or directory",
+    )
+    assert helpers.split_exec_exit_code('Error from server (NotFound): pods "web" not found') == (
+        None, 'Error from server (NotFound): pods "web" not found',
+    )
+    assert helpers.split_exec_exit_code("") == (None, "")
+
+
+def test_endpoint_slice_entries_treat_unset_ready_as_ready():
+    entries = helpers.endpoint_slice_entries({
+        "metadata": {"name": "web-abc12"},
+        "endpoints": [
+            {"addresses": ["10.0.0.1"], "conditions": {}, "targetRef": {"kind": "Pod", "name": "web-1"}},
+            {"addresses": ["10.0.0.2"], "conditions": {"ready": False, "serving": True, "terminating": True},
+             "nodeName": "node-2"},
+        ],
+    })
+    assert [(e["address"], e["ready"], e["pod"], e["terminating"]) for e in entries] == [
+        ("10.0.0.1", True, "web-1", None), ("10.0.0.2", False, None, True),
+    ]
+    assert entries[1]["source"] == "EndpointSlice/web-abc12"
+
+
+def test_validate_dns_query():
+    assert helpers.validate_dns_query("my-svc.my-ns", "aaaa") == "AAAA"
+    assert helpers.validate_dns_query("_http._tcp.web.default.svc.cluster.local.", "SRV") == "SRV"
+    for hostname in ("-f/etc/hosts", "-fpath", "web;id", "", "a..b", "a" * 64):
+        with pytest.raises(ValueError, match="invalid hostname"):
+            helpers.validate_dns_query(hostname, "A")
+    with pytest.raises(ValueError, match="unsupported record type"):
+        helpers.validate_dns_query("web", "-fpath")
@@ -0,0 +1,2316 @@
+import io
+import json
+import os
+import sys
+import tarfile
+from datetime import datetime, timezone
+
+import pytest
+import yaml
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import kubectl_resource_handler as module_under_test
+import discovery_cache
+from discovery_cache import DiscoveryCache
+from kubectl_runner import KubectlCommandError
+
+
+class FakeServer:
+    def __init__(self):
+        self.tools = {}
+        self.resources = {}
+
+    def tool(self, name: str = None, description: str = None):
+        def decorator(func):
+            key = name or getattr(func, "__name__", "unnamed")
+            self.tools[key] = func
+            return func
+        return decorator
+
+    def resource(self, uri: str, **kwargs):
+        def decorator(func):
+            self.resources[uri] = func
+            return func
+        return decorator
+
+
+class FakeRequestContext:
+    def __init__(self, lifespan_context=None):
+        self.lifespan_context = lifespan_context or {}
+
+
+class FakeContext:
+    def __init__(self, lifespan_context=None):
+        self.request_context = FakeRequestContext(lifespan_context)
+
+
+SERVER_NOW = datetime(2024, 1, 31, 12, 0, 0, tzinfo=timezone.utc)
+
+
+class FakeRunner:
+    """按 kubectl 参数返回预置结果的执行器"""
+
+    def __init__(self, responses=None):
+        self.responses = responses or {}
+        self.calls = []
+        self.contexts = []
+
+    def resolve_kubeconfig(self, ctx, cluster_id, execution_log, context=None):
+        self.contexts.append(context)
+        return "/tmp/fake-kubeconfig"
+
+    def resolve_timeout(self, requested=None, operation=None):
+        return requested or 30
+
+    async def run_json(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        response = self.responses.get(tuple(args))
+        if callable(response):
+            response = response(stdin)
+        if isinstance(response, Exception):
+            raise response
+        if response is None:
+            raise KubectlCommandError(f"unexpected command: {args}", stderr="unexpected")
+        return response
+
+    async def run(self, kubeconfig_path, args, execution_log, timeout=None, stdin=None):
+        self.calls.append(list(args))
+        response = self.responses.get(tuple(args))
+        if isinstance(response, list):
+            # 按顺序返回多次调用的结果
+            response = response.pop(0) if len(response) > 1 else response[0]
+        if isinstance(response, dict) and "exit_code" in response:
+            return response
+        return {"exit_code": 1, "stdout": "", "stderr": f"unexpected command: {args}"}
+
+    async def stream_lines(self, kubeconfig_path, args, execution_log, duration, on_line):
+        self.calls.append(list(args))
+        response = self.responses.get(tuple(args)) or {"exit_code": 1, "stderr": f"unexpected command: {args}"}
+        for line in response.get("lines", []):
+            if await on_line(line):
+                return {"exit_code": 0, "elapsed": 0.0, "stderr": "", "reason": "stopped"}
+        return {"exit_code": response.get("exit_code", 0), "elapsed": duration,
+                "stderr": response.get("stderr", ""), "reason": response.get("reason", "timeout")}
+
+    async def server_time(self, kubeconfig_path, execution_log, timeout=None):
+        self.calls.append(["server_time"])
+        return SERVER_NOW, "server"
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 100.0
+
+    def monotonic(self):
+        return self.now
+
+
+def make_handler(responses, settings=None):
+    server = FakeServer()
+    handler = module_under_test.KubectlResourceHandler(server, settings or {})
+    handler.runner = FakeRunner(responses)
+    # 发现缓存为进程内共享，各用例使用独立的缓存
+    handler.discovery_cache = DiscoveryCache()
+    return handler, server
+
+
+def _pod(name, created, namespace="default"):
+    return {
+        "metadata": {"name": name, "namespace": namespace, "creationTimestamp": created},
+        "spec": {"nodeName": "node-1", "containers": [{"name": "app"}]},
+        "status": {
+            "phase": "Running",
+            "podIP": "10.0.0.1",
+            "conditions": [{"type": "Ready", "status": "True"}],
+            "containerStatuses": [{"ready": True, "restartCount": 2, "state": {"running": {}}}],
+        },
+    }
+
+
+def _call_kwargs(**overrides):
+    kwargs = dict(cluster_id="c1", resource="pods", name=None, namespace=None, api_version=None,
+                  label_selector=None,
+                  field_selector=None, min_age=None, max_age=None, created_after=None, created_before=None, limit=0, continue_token=None, output="json", reveal_secrets=False, trim=True, export=False,
+                  per_namespace_fallback=True, namespaces=None, context=None, timeout_seconds=None)
+    kwargs.update(overrides)
+    return kwargs
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_summarizes_pods():
+    handler, server = make_handler({
+        ("get", "pods", "-n", "default", "-o", "json"): {
+            "kind": "List", "items": [_pod("web-1", "2024-01-31T11:55:00Z")]
+        },
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(resource="po", namespace="default"))
+
+    assert result.error is None
+    assert result.resource == "pods"
+    item = result.items[0]
+    assert item["name"] == "web-1"
+    assert item["ready"] == "1/1"
+    assert item["status"] == "Running"
+    assert item["restarts"] == 2
+    assert item["node"] == "node-1"
+
+
+
+@pytest.mark.asyncio
+async def test_pods_and_deployments_are_readable_as_resources():
+    deployment = {
+        "apiVersion": "apps/v1", "kind": "Deployment",
+        "metadata": {"name": "web", "namespace": "prod", "managedFields": [{"manager": "kubectl"}]},
+        "spec": {"replicas": 2},
+    }
+    handler, server = make_handler({
+        ("get", "deployments", "web", "-n", "prod", "-o", "json"): deployment,
+        ("get", "pods", "web-1", "-n", "prod", "-o", "json"): _pod("web-1", "2024-01-31T11:55:00Z", "prod"),
+    })
+    assert {"k8s://{cluster_id}/{namespace}/pods/{name}{?context}",
+            "k8s://{cluster_id}/{namespace}/deployments/{name}{?context}"} <= set(server.resources)
+
+    read = server.resources["k8s://{cluster_id}/{namespace}/deployments/{name}{?context}"]
+    content = json.loads(await read(cluster_id="c1", namespace="prod", name="web", ctx=FakeContext(), context="staging"))
+    assert content["spec"] == {"replicas": 2}
+    assert "managedFields" not in content["metadata"]
+    assert handler.runner.contexts == ["staging"]
+
+    read = server.resources["k8s://{cluster_id}/{namespace}/pods/{name}{?context}"]
+    assert json.loads(await read(cluster_id="c1", namespace="prod", name="web-1", ctx=FakeContext()))["metadata"]["name"] == "web-1"
+    with pytest.raises(KubectlCommandError):
+        await read(cluster_id="c1", namespace="prod", name="missing", ctx=FakeContext())
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_filters_by_age_using_server_time():
+    handler, server = make_handler({
+        ("get", "pods", "--all-namespaces", "-o", "json"): {"kind": "List", "items": [
+            _pod("new", "2024-01-31T11:55:00Z"),
+            _pod("hour-old", "2024-01-31T11:00:00Z"),
+            _pod("ancient", "2023-12-01T00:00:00Z"),
+        ]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    recent = await tool(FakeContext(), **_call_kwargs(max_age="10m"))
+    assert [i["name"] for i in recent.items] == ["new"]
+    assert recent.items[0]["age"] == "5m"
+    assert recent.reference_time == "2024-01-31T12:00:00Z"
+    assert recent.reference_time_source == "server"
+
+    old = await tool(FakeContext(), **_call_kwargs(min_age="30d"))
+    assert [i["name"] for i in old.items] == ["ancient"]
+
+    window = await tool(FakeContext(), **_call_kwargs(min_age="30m", max_age="2h"))
+    assert [i["name"] for i in window.items] == ["hour-old"]
+    assert window.filtered_out == 2
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_filters_by_creation_time_window():
+    handler, server = make_handler({
+        ("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json"): {"kind": "List", "items": [
+            _pod("new", "2024-01-31T11:55:00Z", "prod"),
+            _pod("hour-old", "2024-01-31T11:00:00Z", "prod"),
+            _pod("ancient", "2023-12-01T00:00:00Z", "prod"),
+        ]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(
+        namespace="prod", label_selector="app=web",
+        created_after="2024-01-31T19:00:00+08:00", created_before="2024-01-31T11:55:00Z",
+    ))
+    assert result.error is None
+    assert [i["name"] for i in result.items] == ["hour-old"]
+    assert result.count == 1 and result.filtered_out == 2
+
+    after = await tool(FakeContext(), **_call_kwargs(
+        namespace="prod", label_selector="app=web", created_after="2024-01-31T11:55:00Z",
+    ))
+    assert [i["name"] for i in after.items] == ["new"]
+
+    handler.runner.calls.clear()
+    for kwargs in [{"created_after": "2024-01-31"}, {"created_before": "yesterday"},
+                   {"created_after": "2024-01-31T12:00:00Z", "created_before": "2024-01-31T11:00:00Z"}]:
+        invalid = await tool(FakeContext(), **_call_kwargs(**kwargs))
+        assert invalid.error.error_code == "InvalidParameter", kwargs
+    assert handler.runner.calls == []
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_cluster_scoped_and_secret_redaction():
+    handler, server = make_handler({
+        ("get", "nodes", "-o", "json"): {"kind": "List", "items": [{
+            "metadata": {"name": "node-1", "creationTimestamp": "2024-01-01T00:00:00Z",
+                         "labels": {"node-role.kubernetes.io/worker": ""}},
+            "status": {"conditions": [{"type": "Ready", "status": "True"}],
+                       "nodeInfo": {"kubeletVersion": "v1.30.1"}},
+        }]},
+        ("get", "secrets", "db", "-n", "prod", "-o", "json"): {
+            "kind": "Secret", "type": "Opaque",
+            "metadata": {"name": "db", "namespace": "prod", "creationTimestamp": "2024-01-01T00:00:00Z"},
+            "data": {"password": "c2VjcmV0"},
+        },
+    })
+    tool = server.tools["kubectl_get"]
+
+    nodes = await tool(FakeContext(), **_call_kwargs(resource="nodes", namespace="ignored"))
+    assert nodes.namespace is None
+    assert nodes.warnings == ["nodes 是集群级资源，已忽略 namespace 'ignored'"]
+    assert nodes.items[0]["status"] == "Ready"
+    assert nodes.items[0]["roles"] == ["worker"]
+    assert "namespace" not in nodes.items[0]
+
+    secret = await tool(FakeContext(), **_call_kwargs(resource="secret", name="db", namespace="prod"))
+    assert secret.items[0]["data_keys"] == ["password"]
+    assert "c2VjcmV0" not in str(secret.items)
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_statefulsets_daemonsets_and_ingresses():
+    created = "2024-01-01T00:00:00Z"
+    handler, server = make_handler({
+        ("get", "statefulsets", "-n", "db", "-o", "json"): {"kind": "List", "items": [{
+            "metadata": {"name": "mysql", "namespace": "db", "creationTimestamp": created},
+            "spec": {"replicas": 3, "serviceName": "mysql-headless"},
+            "status": {"readyReplicas": 2},
+        }]},
+        ("get", "daemonsets", "logtail-ds", "-n", "kube-system", "-o", "json"): {
+            "kind": "DaemonSet",
+            "metadata": {"name": "logtail-ds", "namespace": "kube-system", "creationTimestamp": created},
+            "spec": {"template": {"spec": {"nodeSelector": {"kubernetes.io/os": "linux"}}}},
+            "status": {"desiredNumberScheduled": 5, "currentNumberScheduled": 5, "numberReady": 4,
+                       "updatedNumberScheduled": 5, "numberAvailable": 4},
+        },
+        ("get", "ingresses", "--all-namespaces", "-o", "json"): {"kind": "List", "items": [{
+            "metadata": {"name": "web", "namespace": "prod", "creationTimestamp": created},
+            "spec": {"ingressClassName": "alb", "rules": [{"host": "shop.example.com"}, {}],
+                     "tls": [{"hosts": ["shop.example.com"]}]},
+            "status": {"loadBalancer": {"ingress": [{"hostname": "alb-xxx.cn-hangzhou.alb.aliyuncs.com"}]}},
+        }]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    sts = await tool(FakeContext(), **_call_kwargs(resource="sts", namespace="db"))
+    assert sts.resource == "statefulsets"
+    assert sts.items[0]["ready"] == "2/3"
+    assert sts.items[0]["service_name"] == "mysql-headless"
+
+    ds = await tool(FakeContext(), **_call_kwargs(resource="DaemonSet", name="logtail-ds", namespace="kube-system"))
+    assert ds.items[0]["desired"] == 5
+    assert ds.items[0]["ready"] == 4
+    assert ds.items[0]["node_selector"] == {"kubernetes.io/os": "linux"}
+
+    ing = await tool(FakeContext(), **_call_kwargs(resource="ing"))
+    assert ing.items[0]["class"] == "alb"
+    assert ing.items[0]["hosts"] == ["shop.example.com", "*"]
+    assert ing.items[0]["address"] == ["alb-xxx.cn-hangzhou.alb.aliyuncs.com"]
+    assert ing.items[0]["tls"] is True
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_replicasets_jobs_and_cronjobs():
+    created = "2024-01-01T00:00:00Z"
+    replicaset = {
+        "metadata": {"name": "web-5d9f", "namespace": "prod", "creationTimestamp": created,
+                     "ownerReferences": [{"kind": "Deployment", "name": "web", "controller": True}]},
+        "spec": {"replicas": 3}, "status": {"replicas": 3, "readyReplicas": 2},
+    }
+    failed_job = {
+        "metadata": {"name": "migrate", "namespace": "prod", "creationTimestamp": created},
+        "spec": {"completions": 1, "backoffLimit": 2},
+        "status": {"failed": 3, "startTime": "2024-01-01T00:00:00Z",
+                   "conditions": [{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}]},
+    }
+    done_job = {
+        "metadata": {"name": "report-28410", "namespace": "batch", "creationTimestamp": created},
+        "spec": {"completions": 2},
+        "status": {"succeeded": 2, "startTime": "2024-01-01T00:00:00Z", "completionTime": "2024-01-01T00:01:30Z",
+                   "conditions": [{"type": "Complete", "status": "True"}]},
+    }
+    cronjob = {
+        "metadata": {"name": "report", "namespace": "batch", "creationTimestamp": created},
+        "spec": {"schedule": "*/30 * * * *", "suspend": False},
+        "status": {"active": [{"name": "report-28411"}], "lastScheduleTime": "2024-01-31T11:30:00Z"},
+    }
+    handler, server = make_handler({
+        ("get", "replicasets", "web-5d9f", "-n", "prod", "-o", "json"): replicaset,
+        ("get", "replicasets", "-n", "prod", "-o", "json"): {"kind": "List", "items": [replicaset]},
+        ("get", "jobs", "migrate", "-n", "prod", "-o", "json"): failed_job,
+        ("get", "jobs", "--all-namespaces", "-o", "json"): {"kind": "List", "items": [failed_job, done_job]},
+        ("get", "cronjobs", "-n", "batch", "-o", "json"): {"kind": "List", "items": [cronjob]},
+        ("get", "cronjobs", "--all-namespaces", "-o", "json"): {"kind": "List", "items": [cronjob]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    rs = await tool(FakeContext(), **_call_kwargs(resource="rs", name="web-5d9f", namespace="prod"))
+    assert rs.resource == "replicasets"
+    assert (rs.items[0]["desired"], rs.items[0]["ready"], rs.items[0]["owner"]) == (3, 2, "Deployment/web")
+    rs = await tool(FakeContext(), **_call_kwargs(resource="ReplicaSet", namespace="prod"))
+    assert rs.count == 1
+
+    job = await tool(FakeContext(), **_call_kwargs(resource="job", name="migrate", namespace="prod"))
+    assert job.items[0]["status"] == "Failed"
+    assert job.items[0]["completions"] == "0/1"
+    assert job.items[0]["reason"] == "BackoffLimitExceeded"
+    jobs = await tool(FakeContext(), **_call_kwargs(resource="jobs", namespace="all"))
+    assert [(j["namespace"], j["status"]) for j in jobs.items] == [("prod", "Failed"), ("batch", "Complete")]
+    assert jobs.items[1]["completions"] == "2/2"
+    assert jobs.items[1]["duration"] == "90s"
+
+    cj = await tool(FakeContext(), **_call_kwargs(resource="cj", namespace="batch"))
+    assert cj.resource == "cronjobs"
+    assert cj.items[0]["schedule"] == "*/30 * * * *"
+    assert cj.items[0]["active"] == 1 and cj.items[0]["suspend"] is False
+    assert cj.items[0]["last_schedule"] == "2024-01-31T11:30:00Z"
+    cj = await tool(FakeContext(), **_call_kwargs(resource="cronjob", namespace="ALL"))
+    assert cj.namespace is None and cj.count == 1
+
+
+API_RESOURCES = """\
+NAME                SHORTNAMES   APIVERSION                       NAMESPACED   KIND
+pods                po           v1                               true         Pod
+deployments         deploy       apps/v1                          true         Deployment
+virtualservices     vs           networking.istio.io/v1beta1      true         VirtualService
+gateways            gw           networking.istio.io/v1beta1      true         Gateway
+gateways                         gateway.networking.k8s.io/v1     true         Gateway
+clusterpolicies     cpol         kyverno.io/v1                    false        ClusterPolicy
+"""
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_persistent_volume_claims_and_volumes():
+    handler, server = make_handler({
+        ("get", "persistentvolumeclaims", "-n", "prod", "-o", "json"): {"items": [{
+            "metadata": {"name": "data-db-0", "namespace": "prod", "creationTimestamp": "2024-01-30T00:00:00Z"},
+            "spec": {"accessModes": ["ReadWriteOnce"], "storageClassName": "alicloud-disk-essd",
+                     "resources": {"requests": {"storage": "20Gi"}}},
+            "status": {"phase": "Pending"},
+        }]},
+        ("get", "persistentvolumes", "-o", "json"): {"items": [{
+            "metadata": {"name": "d-123", "creationTimestamp": "2024-01-30T00:00:00Z"},
+            "spec": {"capacity": {"storage": "20Gi"}, "accessModes": ["ReadWriteOnce"],
+                     "persistentVolumeReclaimPolicy": "Delete", "storageClassName": "alicloud-disk-essd",
+                     "claimRef": {"namespace": "prod", "name": "data-db-0"}},
+            "status": {"phase": "Bound"},
+        }]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(resource="pvc", namespace="prod"))
+    assert result.error is None
+    assert result.resource == "persistentvolumeclaims"
+    item = result.items[0]
+    assert item["status"] == "Pending"
+    assert item["volume"] is None
+    assert item["requested"] == "20Gi"
+    assert item["storage_class"] == "alicloud-disk-essd"
+
+    # PV 为集群级资源，namespace 参数被忽略
+    result = await tool(FakeContext(), **_call_kwargs(resource="pv", namespace="prod"))
+    assert result.error is None
+    assert result.resource == "persistentvolumes"
+    assert result.namespace is None
+    item = result.items[0]
+    assert "namespace" not in item
+    assert item["claim"] == "prod/data-db-0"
+    assert item["reclaim_policy"] == "Delete"
+    assert item["status"] == "Bound"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_falls_back_to_api_discovery_for_custom_resources():
+    created = "2024-01-01T00:00:00Z"
+    handler, server = make_handler({
+        ("api-resources",): {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+        ("get", "virtualservices.v1beta1.networking.istio.io", "-n", "prod", "-o", "json"): {"kind": "List", "items": [{
+            "apiVersion": "networking.istio.io/v1beta1", "kind": "VirtualService",
+            "metadata": {"name": "reviews", "namespace": "prod", "creationTimestamp": created},
+            "spec": {"hosts": ["reviews"]},
+        }]},
+        ("get", "clusterpolicies.v1.kyverno.io", "require-labels", "-o", "json"): {
+            "apiVersion": "kyverno.io/v1", "kind": "ClusterPolicy",
+            "metadata": {"name": "require-labels", "creationTimestamp": created},
+        },
+        ("get", "gateways.v1.gateway.networking.k8s.io", "--all-namespaces", "-o", "json"): {"kind": "List", "items": []},
+    })
+    tool = server.tools["kubectl_get"]
+
+    vs = await tool(FakeContext(), **_call_kwargs(resource="vs", namespace="prod", output="yaml"))
+    assert vs.error is None
+    assert vs.resource == "virtualservices"
+    assert vs.items[0]["name"] == "reviews"
+    assert yaml.safe_load(vs.yaml)["spec"]["hosts"] == ["reviews"]
+
+    policy = await tool(FakeContext(), **_call_kwargs(resource="ClusterPolicy", name="require-labels", namespace="x"))
+    assert policy.namespace is None
+    assert "namespace" not in policy.items[0]
+
+    ambiguous = await tool(FakeContext(), **_call_kwargs(resource="gateways"))
+    assert ambiguous.error.error_code == "InvalidParameter"
+    assert "networking.istio.io/v1beta1" in ambiguous.error.error_message
+
+    gateways = await tool(FakeContext(), **_call_kwargs(resource="gateways", api_version="gateway.networking.k8s.io/v1"))
+    assert gateways.error is None
+    assert gateways.count == 0
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_caches_api_discovery_and_refreshes_on_miss(monkeypatch):
+    clock = FakeClock()
+    monkeypatch.setattr(discovery_cache, "time", clock)
+    with_crd = API_RESOURCES + "widgets             wd           example.com/v1                   true         Widget\n"
+    handler, server = make_handler({
+        ("api-resources",): [
+            {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+            {"exit_code": 0, "stdout": with_crd, "stderr": ""},
+        ],
+        ("get", "virtualservices.v1beta1.networking.istio.io", "-n", "prod", "-o", "json"): {"kind": "List", "items": []},
+        ("get", "widgets.v1.example.com", "-n", "prod", "-o", "json"): {"kind": "List", "items": []},
+    })
+    handler.discovery_cache = DiscoveryCache()
+    tool = server.tools["kubectl_get"]
+
+    for _ in range(3):
+        assert (await tool(FakeContext(), **_call_kwargs(resource="vs", namespace="prod"))).error is None
+    assert handler.runner.calls.count(["api-resources"]) == 1
+
+    # 缓存足够新时未找到的资源不会重新发现
+    result = await tool(FakeContext(), **_call_kwargs(resource="widgets", namespace="prod"))
+    assert result.error.error_code == "UnsupportedResource"
+    assert handler.runner.calls.count(["api-resources"]) == 1
+
+    # 新安装的 CRD 在缓存超过最小刷新间隔后可被解析
+    clock.now += discovery_cache.MIN_REFRESH_SECONDS + 1
+    result = await tool(FakeContext(), **_call_kwargs(resource="widgets", namespace="prod"))
+    assert result.error is None
+    assert result.resource == "widgets"
+    assert handler.runner.calls.count(["api-resources"]) == 2
+
+
+@pytest.mark.asyncio
+async def test_resolve_namespaced_uses_registry_and_api_discovery():
+    handler, _ = make_handler({
+        ("api-resources",): {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+    })
+
+    assert await handler.resolve_namespaced(FakeContext(), "c1", "persistentvolumes") is False
+    assert handler.runner.calls == []
+    assert await handler.resolve_namespaced(FakeContext(), "c1", "ClusterPolicy", "kyverno.io/v1") is False
+    assert await handler.resolve_namespaced(FakeContext(), "c1", "vs") is True
+    assert await handler.resolve_namespaced(FakeContext(), "c1", "Widget", "example.com/v1") is None
+    # 存在多个候选时无法确定作用域
+    assert await handler.resolve_namespaced(FakeContext(), "c1", "gateways") is None
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_rejects_unsupported_resource_and_bad_duration():
+    handler, server = make_handler({
+        ("api-resources",): {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(resource="widgets"))
+    assert result.error.error_code == "UnsupportedResource"
+    assert handler.runner.calls == [["api-resources"]]
+    handler.runner.calls.clear()
+
+    result = await tool(FakeContext(), **_call_kwargs(max_age="ten minutes"))
+    assert result.error.error_code == "GetResourceFailed"
+
+    result = await tool(FakeContext(), **_call_kwargs(output="table"))
+    assert result.error.error_code == "InvalidParameter"
+    assert "json, yaml" in result.error.error_message
+    assert handler.runner.calls == []
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_label_selector():
+    handler, server = make_handler({
+        ("get", "pods", "-n", "default", "-l", "app=web,tier in (a, b)", "-o", "json"): {
+            "kind": "List", "items": [_pod("web-1", "2024-01-31T11:55:00Z")]
+        },
+        ("get", "pods", "web-2", "-n", "default", "-o", "json"): _pod("web-2", "2024-01-31T11:55:00Z"),
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(namespace="default", label_selector="app=web,tier in (a, b)"))
+    assert result.error is None
+    assert [i["name"] for i in result.items] == ["web-1"]
+
+    by_name = await tool(FakeContext(), **_call_kwargs(name="web-2", namespace="default", label_selector="app=web"))
+    assert [i["name"] for i in by_name.items] == ["web-2"]
+    assert "label_selector" in by_name.warnings[0]
+
+    calls = len(handler.runner.calls)
+    invalid = await tool(FakeContext(), **_call_kwargs(namespace="default", label_selector="app=we b"))
+    assert invalid.error.error_code == "InvalidParameter"
+    assert len(handler.runner.calls) == calls
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_field_selector_for_pods_and_events():
+    handler, server = make_handler({
+        ("get", "pods", "--all-namespaces", "--field-selector=status.phase=Running,spec.nodeName!=node-2",
+         "-o", "json"): {"kind": "List", "items": [_pod("web-1", "2024-01-31T11:55:00Z")]},
+        ("get", "events", "-n", "default", "--field-selector=involvedObject.name==web-1,type=Warning",
+         "-o", "json"): {"kind": "List", "items": [{
+            "metadata": {"name": "web-1.17a", "namespace": "default", "creationTimestamp": "2024-01-31T11:59:00Z"},
+            "type": "Warning", "reason": "BackOff", "message": "Back-off restarting failed container",
+            "involvedObject": {"kind": "Pod", "name": "web-1"}, "count": 4,
+            "lastTimestamp": "2024-01-31T11:59:30Z",
+        }]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    pods = await tool(FakeContext(), **_call_kwargs(field_selector="status.phase=Running,spec.nodeName!=node-2"))
+    assert pods.error is None
+    assert [i["name"] for i in pods.items] == ["web-1"]
+
+    events = await tool(FakeContext(), **_call_kwargs(
+        resource="ev", namespace="default", field_selector="involvedObject.name==web-1,type=Warning"
+    ))
+    assert events.error is None
+    assert events.items[0]["object"] == "Pod/web-1"
+    assert events.items[0]["reason"] == "BackOff"
+    assert events.items[0]["count"] == 4
+
+    calls = len(handler.runner.calls)
+    unsupported = await tool(FakeContext(), **_call_kwargs(resource="events", field_selector="status.phase=Running"))
+    assert unsupported.error.error_code == "InvalidParameter"
+    assert "involvedObject.name" in unsupported.error.error_message
+    malformed = await tool(FakeContext(), **_call_kwargs(field_selector="status.phase"))
+    assert malformed.error.error_code == "InvalidParameter"
+    assert len(handler.runner.calls) == calls
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_redacts_secret_data_by_default():
+    secret = {
+        "metadata": {
+            "name": "db", "namespace": "prod", "creationTimestamp": "2024-01-01T00:00:00Z",
+            "annotations": {"kubectl.kubernetes.io/last-applied-configuration": '{"data":{"password":"c2VjcmV0"}}'},
+        },
+        "type": "Opaque",
+        "data": {"password": "c2VjcmV0"},
+        "stringData": {"token": "plain-token"},
+    }
+    handler, server = make_handler({
+        # List 接口返回的条目不带 kind
+        ("get", "--raw", "/api/v1/namespaces/prod/secrets?limit=100"): {
+            "kind": "SecretList", "metadata": {}, "items": [secret],
+        },
+        ("get", "secrets", "db", "-n", "prod", "-o", "json"): {"kind": "Secret", **secret},
+    })
+    tool = server.tools["kubectl_get"]
+
+    for kwargs in (dict(limit=100), dict(name="db")):
+        for output in ("json", "yaml"):
+            result = await tool(FakeContext(), **_call_kwargs(resource="secrets", namespace="prod", output=output, **kwargs))
+            assert result.error is None
+            dumped = result.model_dump_json()
+            assert "c2VjcmV0" not in dumped
+            assert "plain-token" not in dumped
+            if output == "yaml":
+                document = yaml.safe_load(result.yaml)
+                assert document["data"] == {"password": "<redacted, 6 bytes>"}
+                assert document["stringData"] == {"token": "<redacted, 11 bytes>"}
+                assert "annotations" not in document["metadata"]
+
+    revealed = await tool(FakeContext(), **_call_kwargs(
+        resource="secrets", name="db", namespace="prod", output="yaml", reveal_secrets=True
+    ))
+    assert yaml.safe_load(revealed.yaml)["data"] == {"password": "c2VjcmV0"}
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_paginates_list_results():
+    handler, server = make_handler({
+        ("get", "--raw", "/api/v1/namespaces/default/pods?limit=2&labelSelector=app%3Dweb"): {
+            "kind": "PodList",
+            "metadata": {"continue": "token-1", "remainingItemCount": 1},
+            "items": [_pod("web-1", "2024-01-31T11:55:00Z"), _pod("web-2", "2024-01-31T11:55:00Z")],
+        },
+        ("get", "--raw", "/api/v1/namespaces/default/pods?limit=2&continue=token-1&labelSelector=app%3Dweb"): {
+            "kind": "PodList", "metadata": {}, "items": [_pod("web-3", "2024-01-31T11:55:00Z")],
+        },
+        ("get", "--raw", "/apis/apps/v1/deployments?limit=100"): {"kind": "DeploymentList", "metadata": {}, "items": []},
+    })
+    tool = server.tools["kubectl_get"]
+
+    first = await tool(FakeContext(), **_call_kwargs(namespace="default", label_selector="app=web", limit=2))
+    assert first.error is None
+    assert [i["name"] for i in first.items] == ["web-1", "web-2"]
+    assert first.has_more is True
+    assert first.continue_token == "token-1"
+    assert first.remaining_item_count == 1
+
+    second = await tool(FakeContext(), **_call_kwargs(
+        namespace="default", label_selector="app=web", limit=2, continue_token=first.continue_token
+    ))
+    assert [i["name"] for i in second.items] == ["web-3"]
+    assert second.has_more is False
+    assert second.continue_token is None
+
+    deployments = await tool(FakeContext(), **_call_kwargs(resource="deploy", limit=100))
+    assert deployments.error is None
+    assert deployments.count == 0
+
+    invalid = await tool(FakeContext(), **_call_kwargs(continue_token="token-1"))
+    assert invalid.error.error_code == "InvalidParameter"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_yaml_output_strips_managed_fields_and_secret_values():
+    pod = _pod("web-1", "2024-01-31T11:55:00Z")
+    pod["metadata"]["managedFields"] = [{"manager": "kubectl", "operation": "Apply"}]
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "default", "-o", "json"): pod,
+        ("get", "secrets", "db", "-n", "prod", "-o", "json"): {
+            "kind": "Secret", "type": "Opaque",
+            "metadata": {"name": "db", "namespace": "prod", "creationTimestamp": "2024-01-01T00:00:00Z"},
+            "data": {"password": "c2VjcmV0"},
+        },
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(name="web-1", namespace="default", output="YAML"))
+    assert result.error is None
+    assert result.items[0]["name"] == "web-1"
+    document = yaml.safe_load(result.yaml)
+    assert document["metadata"]["name"] == "web-1"
+    assert "managedFields" not in document["metadata"]
+    assert document["status"]["podIP"] == "10.0.0.1"
+
+    secret = await tool(FakeContext(), **_call_kwargs(resource="secrets", name="db", namespace="prod", output="yaml"))
+    assert yaml.safe_load(secret.yaml)["data"] == {"password": "<redacted, 6 bytes>"}
+
+    raw = await tool(FakeContext(), **_call_kwargs(name="web-1", namespace="default", output="yaml", trim=False))
+    assert yaml.safe_load(raw.yaml)["metadata"]["managedFields"] == [{"manager": "kubectl", "operation": "Apply"}]
+
+    default = await tool(FakeContext(), **_call_kwargs(name="web-1", namespace="default"))
+    assert default.yaml is None
+
+
+def _event(name, event_type, reason, last_seen, count=1):
+    return {
+        "metadata": {"name": name, "namespace": "prod"},
+        "type": event_type, "reason": reason, "message": f"{reason} happened\n", "count": count,
+        "involvedObject": {"kind": "Pod", "name": "web-1"}, "lastTimestamp": last_seen,
+    }
+
+
+def _events_kwargs(**overrides):
+    kwargs = dict(cluster_id="c1", namespace="prod", type=None, warnings_only=False, object_name=None,
+                  object_kind=None, limit=100, context=None, timeout_seconds=None)
+    kwargs.update(overrides)
+    return kwargs
+
+
+@pytest.mark.asyncio
+async def test_kubectl_events_sorts_by_time_and_filters_type():
+    handler, server = make_handler({
+        ("get", "events", "-n", "prod", "-o", "json"): {"items": [
+            _event("e1", "Normal", "Scheduled", "2024-01-31T11:00:00Z"),
+            _event("e2", "Warning", "BackOff", "2024-01-31T11:50:00Z", count=7),
+            _event("e3", "Normal", "Pulled", "2024-01-31T11:10:00Z"),
+        ]},
+        ("get", "events", "--all-namespaces", "--field-selector=type=Warning,involvedObject.name=web-1", "-o", "json"): {
+            "items": [_event("e2", "Warning", "BackOff", "2024-01-31T11:50:00Z", count=7)],
+        },
+    })
+    tool = server.tools["kubectl_events"]
+
+    result = await tool(FakeContext(), **_events_kwargs(limit=2))
+    assert result.error is None
+    assert [event["reason"] for event in result.events] == ["BackOff", "Pulled"]
+    assert result.total == 3
+    assert result.events[0] == {
+        "time": "2024-01-31T11:50:00Z", "type": "Warning", "reason": "BackOff", "object": "Pod/web-1",
+        "namespace": "prod", "message": "BackOff happened", "count": 7,
+    }
+
+    result = await tool(FakeContext(), **_events_kwargs(namespace="all", warnings_only=True, object_name="web-1"))
+    assert result.error is None
+    assert result.type == "Warning"
+    assert result.count == 1
+
+    result = await tool(FakeContext(), **_events_kwargs(type="Error"))
+    assert result.error.error_code == "InvalidParameter"
+
+
+def _namespace(name, phase="Active"):
+    return {
+        "metadata": {"name": name, "creationTimestamp": "2024-01-01T00:00:00Z", "labels": {"team": "a"}},
+        "status": {"phase": phase},
+    }
+
+
+@pytest.mark.asyncio
+async def test_namespaces_listing_and_namespace_all():
+    handler, server = make_handler({
+        ("get", "namespaces", "-o", "json"): {"items": [_namespace("default"), _namespace("old", "Terminating")]},
+        ("get", "namespaces", "old", "-o", "json"): _namespace("old", "Terminating"),
+        ("get", "pods", "--all-namespaces", "-o", "json"): {"items": [_pod("web-1", "2024-01-31T11:55:00Z")]},
+    })
+
+    result = await server.tools["list_namespaces"](FakeContext(), cluster_id="c1", label_selector=None, context=None,
+                                                   timeout_seconds=None)
+    assert result.error is None
+    assert result.count == 2
+    assert result.namespaces[1]["name"] == "old"
+    assert result.namespaces[1]["status"] == "Terminating"
+    assert result.namespaces[1]["labels"] == {"team": "a"}
+    assert "namespace" not in result.namespaces[0]
+
+    result = await server.tools["kubectl_get"](FakeContext(), **_call_kwargs(resource="ns", name="old"))
+    assert result.error is None
+    assert result.resource == "namespaces"
+    assert result.items[0]["status"] == "Terminating"
+
+    result = await server.tools["kubectl_get"](FakeContext(), **_call_kwargs(namespace="all"))
+    assert result.error is None
+    assert result.namespace is None
+    assert result.count == 1
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_namespace_all_lists_across_namespaces():
+    resources = ["pods", "services", "deployments", "configmaps", "secrets"]
+    handler, server = make_handler({
+        ("get", resource, "--all-namespaces", "-o", "json"): {"kind": "List", "items": [
+            {"metadata": {"name": "a", "namespace": "dev", "creationTimestamp": "2024-01-31T11:55:00Z"}},
+            {"metadata": {"name": "b", "namespace": "prod", "creationTimestamp": "2024-01-31T11:55:00Z"}},
+        ]}
+        for resource in resources
+    })
+
+    for resource in resources:
+        for namespace in ("all", "ALL", "", None):
+            result = await server.tools["kubectl_get"](
+                FakeContext(), **_call_kwargs(resource=resource, namespace=namespace)
+            )
+            assert result.error is None
+            assert result.namespace is None
+            assert [item["namespace"] for item in result.items] == ["dev", "prod"]
+            assert result.warnings == []
+
+
+def _watch_kwargs(**overrides):
+    kwargs = dict(cluster_id="c1", resource="pods", name=None, namespace="default", api_version=None,
+                  label_selector=None, context=None, timeout_seconds=None)
+    kwargs.update(overrides)
+    return kwargs
+
+
+@pytest.mark.asyncio
+async def test_kubectl_watch_collects_events_until_timeout():
+    pod = _pod("web-1", "2024-01-31T11:55:00Z")
+    pod["metadata"]["resourceVersion"] = "101"
+    handler, server = make_handler({
+        ("get", "--raw", "/api/v1/namespaces/default/pods?watch=true&timeoutSeconds=30&labelSelector=app%3Dweb"): {
+            "lines": [
+                json.dumps({"type": "ADDED", "object": pod}) + "\n",
+                json.dumps({"type": "BOOKMARK", "object": {"metadata": {"resourceVersion": "102"}}}) + "\n",
+                json.dumps({"type": "DELETED", "object": pod}) + "\n",
+            ],
+        },
+        ("get", "--raw", "/api/v1/namespaces/default/pods?watch=true&timeoutSeconds=5&fieldSelector=metadata.name%3Dweb-1"): {
+            "lines": [], "reason": "closed",
+        },
+    })
+    tool = server.tools["kubectl_watch"]
+
+    result = await tool(FakeContext(), **_watch_kwargs(label_selector="app=web"))
+    assert result.error is None
+    assert result.timeout_seconds == 30
+    assert [event["type"] for event in result.events] == ["ADDED", "DELETED"]
+    assert result.events[0]["name"] == "web-1"
+    assert result.events[0]["resource_version"] == "101"
+    assert result.event_count == 2
+    assert result.closed_reason == "timeout"
+
+    result = await tool(FakeContext(), **_watch_kwargs(name="web-1", label_selector="app=web", timeout_seconds=5))
+    assert result.error is None
+    assert result.closed_reason == "closed"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_watch_reports_unsupported_and_expired_watches():
+    handler, server = make_handler({
+        ("get", "--raw", "/api/v1/namespaces/default/events?watch=true&timeoutSeconds=30"): {
+            "exit_code": 1,
+            "stderr": "Error from server (MethodNotAllowed): the server does not allow this method on the requested resource",
+        },
+        ("get", "--raw", "/api/v1/namespaces/default/pods?watch=true&timeoutSeconds=30"): {
+            "lines": [json.dumps({"type": "ERROR", "object": {
+                "kind": "Status", "reason": "Expired", "message": "too old resource version: 1 (2)",
+            }})],
+        },
+    })
+    tool = server.tools["kubectl_watch"]
+
+    result = await tool(FakeContext(), **_watch_kwargs(resource="events"))
+    assert result.error.error_code == "WatchNotSupported"
+
+    result = await tool(FakeContext(), **_watch_kwargs())
+    assert result.error.error_code == "WatchFailed"
+    assert "too old resource version" in result.error.error_message
+
+
+def _logs_kwargs(**overrides):
+    kwargs = dict(cluster_id="c1", namespace="default", name="web-1", container=None, tail_lines=1000,
+                  previous=False, context=None, timeout_seconds=None)
+    kwargs.update(overrides)
+    return kwargs
+
+
+def _forbidden(namespace=None):
+    scope = f' in the namespace "{namespace}"' if namespace else " at the cluster scope"
+    return KubectlCommandError(
+        "forbidden",
+        stderr=f'Error from server (Forbidden): pods is forbidden: User "dev" cannot list resource "pods" '
+               f'in API group ""{scope}',
+    )
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_all_namespaces_returns_partial_results_when_forbidden():
+    namespaces = {"items": [{"metadata": {"name": n}} for n in ("team-b", "team-a", "kube-system")]}
+    handler, server = make_handler({
+        ("get", "--raw", "/api/v1/pods?limit=2"): _forbidden(),
+        ("get", "namespaces", "-o", "json"): namespaces,
+        ("get", "pods", "-n", "team-a", "-o", "json"): {"items": [
+            _pod("a-1", "2024-01-31T11:00:00Z", "team-a"), _pod("a-2", "2024-01-31T11:00:00Z", "team-a"),
+        ]},
+        ("get", "pods", "-n", "team-b", "-o", "json"): {"items": [_pod("b-1", "2024-01-31T11:00:00Z", "team-b")]},
+        ("get", "pods", "-n", "kube-system", "-o", "json"): _forbidden("kube-system"),
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(namespace="all", limit=2))
+    assert result.error is None
+    assert result.partial is True
+    assert [item["name"] for item in result.items] == ["a-1", "a-2"]
+    assert list(result.namespace_errors) == ["kube-system"]
+    assert 'in namespace "kube-system"' in result.namespace_errors["kube-system"]
+    assert result.warnings[0] == "无权跨命名空间列出 pods，已逐个命名空间查询：2 个成功，1 个失败（见 namespace_errors）"
+    assert "仅返回前 2 个" in result.warnings[1]
+
+    # 关闭回退或无法列出命名空间时返回原错误
+    result = await tool(FakeContext(), **_call_kwargs(namespace="all", limit=2, per_namespace_fallback=False))
+    assert result.error.error_code == "Forbidden" and not result.namespace_errors
+    handler.runner.responses[("get", "namespaces", "-o", "json")] = _forbidden()
+    result = await tool(FakeContext(), **_call_kwargs(namespace="all", limit=2))
+    assert result.error.error_code == "Forbidden"
+    assert "at the cluster scope" in result.error.error_message
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_per_namespace_fallback_uses_given_namespaces():
+    responses = {
+        ("get", "pods", "--all-namespaces", "-o", "json"): _forbidden(),
+        ("get", "pods", "-n", "team-b", "-o", "json"): {"items": [_pod("b-1", "2024-01-31T11:00:00Z", "team-b")]},
+    }
+    handler, server = make_handler(responses)
+    tool = server.tools["kubectl_get"]
+
+    # 显式指定的命名空间无需列出集群的命名空间
+    result = await tool(FakeContext(), **_call_kwargs(namespaces=["team-b", " team-b", ""]))
+    assert result.error is None
+    assert [item["name"] for item in result.items] == ["b-1"]
+    assert ("get", "namespaces", "-o", "json") not in [tuple(c) for c in handler.runner.calls]
+
+
+@pytest.mark.asyncio
+async def test_kubectl_multi_get_returns_per_item_errors_inline():
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "prod", "-o", "json"): _pod("web-1", "2024-01-31T11:00:00Z", "prod"),
+        ("get", "--raw", "/api/v1/namespaces/prod/services?limit=100&labelSelector=app%3Dweb"): {
+            "kind": "ServiceList", "metadata": {}, "items": [],
+        },
+        ("get", "deployments", "web", "-n", "prod", "-o", "json"): KubectlCommandError(
+            "not found", stderr='Error from server (NotFound): deployments.apps "web" not found',
+        ),
+    })
+    tool = server.tools["kubectl_multi_get"]
+
+    result = await tool(FakeContext(), cluster_id="c1", items=[
+        {"label": "pod", "resource": "po", "namespace": "prod", "name": "web-1"},
+        {"label": "svc", "resource": "services", "namespace": "prod", "label_selector": "app=web"},
+        {"label": "deploy", "resource": "deployments", "namespace": "prod", "name": "web"},
+    ], context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert list(result.results) == ["pod", "svc", "deploy"]
+    assert result.results["pod"].items[0]["name"] == "web-1"
+    assert result.results["svc"].error is None and result.results["svc"].count == 0
+    assert result.results["deploy"].error.error_code == "NotFound"
+    assert (result.succeeded, result.failed) == (2, 1)
+
+    # label 重复或为空时整体拒绝
+    result = await tool(FakeContext(), cluster_id="c1", items=[
+        {"label": "a", "resource": "pods"}, {"label": "a", "resource": "services"},
+    ], context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter" and "duplicate labels: a" in result.error.error_message
+    result = await tool(FakeContext(), cluster_id="c1", items=[], context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_maps_forbidden_and_not_found_errors():
+    handler, server = make_handler({
+        ("get", "pods", "-n", "prod", "-o", "json"): KubectlCommandError(
+            "forbidden",
+            stderr='Error from server (Forbidden): pods is forbidden: User "system:serviceaccount:ops:mcp" '
+                   'cannot list resource "pods" in API group "" in the namespace "prod"',
+        ),
+        ("get", "pods", "web-1", "-n", "prod", "-o", "json"): KubectlCommandError(
+            "not found", stderr='Error from server (NotFound): pods "web-1" not found',
+        ),
+        ("get", "pods", "web-2", "-n", "prod", "-o", "json"): KubectlCommandError(
+            "dial tcp: connection refused", stderr="dial tcp: connection refused",
+        ),
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(namespace="prod"))
+    assert result.error.error_code == "Forbidden"
+    assert "verbs=[list] resources=[pods]" in result.error.error_message
+    assert result.execution_log.tool_name == "kubectl_get"
+    assert result.execution_log.error
+    assert result.execution_log.metadata["failure_stage"] == "kubectl_get"
+    assert result.execution_log.duration_ms is not None
+
+    result = await tool(FakeContext(), **_call_kwargs(namespace="prod", name="web-1"))
+    assert result.error.error_code == "NotFound"
+    assert result.error.error_message == 'pods "web-1" not found'
+
+    result = await tool(FakeContext(), **_call_kwargs(namespace="prod", name="web-2"))
+    assert result.error.error_code == "GetResourceFailed"
+
+
+def _pod_metrics(name, cpu, memory, namespace="default"):
+    return {
+        "metadata": {"name": name, "namespace": namespace},
+        "timestamp": "2024-01-31T11:59:30Z",
+        "window": "30s",
+        "containers": [{"name": "app", "usage": {"cpu": cpu, "memory": memory}}],
+    }
+
+
+@pytest.mark.asyncio
+async def test_kubectl_top_sorts_pods_by_usage():
+    handler, server = make_handler({
+        ("get", "--raw", "/apis/metrics.k8s.io/v1beta1/namespaces/prod/pods"): {"items": [
+            _pod_metrics("small", "5000000n", "64Mi", "prod"),
+            _pod_metrics("big", "250m", "32Mi", "prod"),
+        ]},
+        ("get", "--raw", "/apis/metrics.k8s.io/v1beta1/nodes"): {"items": [
+            {"metadata": {"name": "node-1"}, "usage": {"cpu": "1500m", "memory": "2Gi"}},
+        ]},
+    })
+    tool = server.tools["kubectl_top"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", namespace="prod", sort_by="cpu",
+                        context=None, timeout_seconds=None)
+    assert result.error is None
+    assert [item["name"] for item in result.items] == ["big", "small"]
+    assert result.items[0]["cpu"] == "250m"
+    assert result.items[1]["cpu"] == "5m"
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", namespace="prod", sort_by="memory",
+                        context=None, timeout_seconds=None)
+    assert [item["name"] for item in result.items] == ["small", "big"]
+    assert result.items[0]["memory"] == "64.0Mi"
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="no", namespace="prod", sort_by=None,
+                        context=None, timeout_seconds=None)
+    assert result.resource == "nodes"
+    assert result.namespace is None
+    assert result.items[0] == {
+        "name": "node-1", "cpu": "1500m", "memory": "2.0Gi", "cpu_cores": 1.5, "memory_bytes": 2 ** 31,
+        "timestamp": None, "window": None,
+    }
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", namespace=None, sort_by="disk",
+                        context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_top_reports_missing_metrics_server():
+    handler, server = make_handler({
+        ("get", "--raw", "/apis/metrics.k8s.io/v1beta1/pods"): KubectlCommandError(
+            "not found", stderr="Error from server (NotFound): the server could not find the requested resource",
+        ),
+    })
+    tool = server.tools["kubectl_top"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", namespace=None, sort_by=None,
+                        context=None, timeout_seconds=None)
+    assert result.error.error_code == "MetricsServerUnavailable"
+    assert "metrics-server" in result.error.error_message
+
+
+@pytest.mark.asyncio
+async def test_kubectl_logs_single_container_and_previous():
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "default", "-o", "json"): _pod("web-1", "2024-01-31T11:55:00Z"),
+        ("logs", "web-1", "-n", "default", "-c", "app", "--tail=50"): {
+            "exit_code": 0, "stdout": "line 1\nline 2\n", "stderr": "",
+        },
+        ("logs", "web-1", "-n", "default", "-c", "app", "--tail=10000", "--previous"): {
+            "exit_code": 1, "stdout": "",
+            "stderr": 'previous terminated container "app" in pod "web-1" not found',
+        },
+    })
+    tool = server.tools["kubectl_logs"]
+
+    result = await tool(FakeContext(), **_logs_kwargs(tail_lines=50, context="staging"))
+    assert result.error is None
+    assert handler.runner.contexts == ["staging"]
+    assert result.container == "app"
+    assert result.logs == "line 1\nline 2\n"
+    assert result.line_count == 2
+
+    previous = await tool(FakeContext(), **_logs_kwargs(container="app", tail_lines=50000, previous=True))
+    assert previous.tail_lines == 10000
+    assert previous.error.error_code == "GetLogsFailed"
+    assert "previous terminated container" in previous.error.error_message
+
+
+@pytest.mark.asyncio
+async def test_kubectl_logs_requires_container_for_multi_container_pods(monkeypatch):
+    pod = _pod("web-1", "2024-01-31T11:55:00Z")
+    pod["spec"]["containers"].append({"name": "sidecar"})
+    pod["spec"]["initContainers"] = [{"name": "init"}]
+    big = "".join(f"line {i:06d}\n" for i in range(200))
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "default", "-o", "json"): pod,
+        ("logs", "web-1", "-n", "default", "-c", "init", "--tail=1000"): {"exit_code": 0, "stdout": big, "stderr": ""},
+    })
+    monkeypatch.setattr(module_under_test, "MAX_LOG_BYTES", 100)
+    tool = server.tools["kubectl_logs"]
+
+    missing = await tool(FakeContext(), **_logs_kwargs())
+    assert missing.error.error_code == "InvalidParameter"
+    assert "app, sidecar, init" in missing.error.error_message
+
+    unknown = await tool(FakeContext(), **_logs_kwargs(container="db"))
+    assert unknown.error.error_code == "ContainerNotFound"
+
+    truncated = await tool(FakeContext(), **_logs_kwargs(container="init"))
+    assert truncated.error is None
+    assert truncated.truncated is True
+    assert truncated.logs.endswith("line 000199\n")
+    assert truncated.logs.startswith("line ")
+    assert len(truncated.logs) <= 100
+
+
+@pytest.mark.asyncio
+async def test_logs_archive_bundles_pod_logs_as_resource():
+    pods = {"items": [
+        {"metadata": {"name": "web-1"}, "spec": {"initContainers": [{"name": "init"}], "containers": [{"name": "app"}]}},
+        {"metadata": {"name": "web-2"}, "spec": {"containers": [{"name": "app"}]}},
+    ]}
+    handler, server = make_handler({
+        ("get", "pods", "-n", "prod", "-l", "app=web", "-o", "json"): pods,
+        ("logs", "web-1", "-n", "prod", "-c", "init", "--timestamps", "--tail=100", "--since=1h"):
+            {"exit_code": 0, "stdout": "init done\n", "stderr": ""},
+        ("logs", "web-1", "-n", "prod", "-c", "app", "--timestamps", "--tail=100", "--since=1h"):
+            {"exit_code": 0, "stdout": "hello from web-1\n", "stderr": ""},
+        ("logs", "web-2", "-n", "prod", "-c", "app", "--timestamps", "--tail=100", "--since=1h"):
+            {"exit_code": 1, "stdout": "", "stderr": "container not started"},
+    })
+    tool = server.tools["kubectl_logs_archive"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", label_selector="app=web",
+                        since="1h", tail_lines=100, max_pods=20, context="staging", timeout_seconds=None)
+
+    assert result.error is None
+    assert handler.runner.contexts == ["staging"]
+    assert result.files == ["web-1/init.log", "web-1/app.log"]
+    assert result.failed == ["web-2/app: container not started"]
+    assert result.resource_uri == f"ack-logs://archives/{result.archive_id}"
+
+    read = server.resources["ack-logs://archives/{archive_id}"]
+    data = read(result.archive_id)
+    assert len(data) == result.size_bytes
+    with tarfile.open(fileobj=io.BytesIO(data), mode="r:gz") as tar:
+        assert tar.extractfile("web-1/app.log").read() == b"hello from web-1\n"
+        manifest = json.loads(tar.extractfile("manifest.json").read())
+    assert manifest["failed"] == result.failed
+
+    with pytest.raises(ValueError):
+        read("missing")
+
+
+@pytest.mark.asyncio
+async def test_logs_archive_rejects_out_of_range_limits():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_logs_archive"]
+
+    for max_pods, tail_lines in ((0, 100), (-1, 100), (module_under_test.MAX_LOG_ARCHIVE_PODS + 1, 100),
+                                 (20, 0), (20, module_under_test.MAX_LOG_TAIL_LINES + 1)):
+        result = await tool(FakeContext(), cluster_id="c1", namespace="prod", label_selector="app=web", since=None,
+                            tail_lines=tail_lines, max_pods=max_pods, context=None, timeout_seconds=None)
+        assert result.error.error_code == "InvalidParameter"
+    assert handler.runner.calls == []
+
+
+def _export_responses():
+    deployment = {
+        "apiVersion": "apps/v1", "kind": "Deployment",
+        "metadata": {"name": "web", "namespace": "prod", "uid": "u1", "resourceVersion": "7",
+                     "annotations": {"deployment.kubernetes.io/revision": "3", "team": "core"}},
+        "spec": {"template": {
+            "metadata": {"labels": {"app": "web"}},
+            "spec": {
+                "serviceAccountName": "web",
+                "containers": [{"name": "web", "image": "nginx",
+                                "envFrom": [{"configMapRef": {"name": "web-config"}}, {"secretRef": {"name": "web-db"}}]}],
+                "volumes": [{"name": "data", "persistentVolumeClaim": {"claimName": "web-data"}},
+                            {"name": "gone", "configMap": {"name": "deleted-config"}}],
+            },
+        }},
+        "status": {"replicas": 2},
+    }
+    not_found = KubectlCommandError('configmaps "deleted-config" not found', stderr="Error from server (NotFound)")
+    return {
+        ("get", "deployment", "web", "-n", "prod", "-o", "json"): deployment,
+        ("get", "serviceaccount", "web", "-n", "prod", "-o", "json"): {
+            "apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"name": "web", "namespace": "prod"},
+            "secrets": [{"name": "web-token-abc"}]},
+        ("get", "configmap", "web-config", "-n", "prod", "-o", "json"): {
+            "apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web-config"}, "data": {"LOG": "info"}},
+        ("get", "secret", "web-db", "-n", "prod", "-o", "json"): {
+            "apiVersion": "v1", "kind": "Secret", "type": "Opaque", "metadata": {"name": "web-db"},
+            "data": {"password": "c2VjcmV0"}},
+        ("get", "persistentvolumeclaim", "web-data", "-n", "prod", "-o", "json"): {
+            "apiVersion": "v1", "kind": "PersistentVolumeClaim",
+            "metadata": {"name": "web-data", "annotations": {"pv.kubernetes.io/bind-completed": "yes"}},
+            "spec": {"volumeName": "pv-123", "resources": {"requests": {"storage": "10Gi"}}}},
+        ("get", "configmap", "deleted-config", "-n", "prod", "-o", "json"): not_found,
+        ("get", "services", "-n", "prod", "-o", "json"): {"items": [
+            {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"},
+             "spec": {"selector": {"app": "web"}, "clusterIP": "10.0.0.5", "type": "NodePort",
+                      "ports": [{"port": 80, "nodePort": 30080}]}},
+            {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "api"}, "spec": {"selector": {"app": "api"}}},
+        ]},
+        ("get", "horizontalpodautoscalers", "-n", "prod", "-o", "json"): {"items": [
+            {"apiVersion": "autoscaling/v2", "kind": "HorizontalPodAutoscaler", "metadata": {"name": "web"},
+             "spec": {"scaleTargetRef": {"kind": "Deployment", "name": "web"}}},
+        ]},
+    }
+
+
+@pytest.mark.asyncio
+async def test_export_bundle_collects_dependencies_in_apply_order():
+    handler, server = make_handler(_export_responses())
+    tool = server.tools["kubectl_export_bundle"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", workload_name="web",
+                        workload_type="deployment", include_secret_data=False, context="staging",
+                        timeout_seconds=None)
+
+    assert result.error is None
+    assert handler.runner.contexts == ["staging"]
+    assert result.objects == [
+        "ServiceAccount/web", "ConfigMap/web-config", "Secret/web-db", "PersistentVolumeClaim/web-data",
+        "Deployment/web", "Service/web", "HorizontalPodAutoscaler/web",
+    ]
+    assert result.missing == ["ConfigMap/deleted-config"]
+    assert result.warnings and "web-db" in result.warnings[0]
+
+    documents = {d["kind"]: d for d in yaml.safe_load_all(result.manifest)}
+    assert documents["Secret"]["data"] == {"password": ""}
+    assert documents["Service"]["spec"]["ports"] == [{"port": 80}] and "clusterIP" not in documents["Service"]["spec"]
+    assert "volumeName" not in documents["PersistentVolumeClaim"]["spec"]
+    assert "secrets" not in documents["ServiceAccount"]
+    assert documents["Deployment"]["metadata"] == {"name": "web", "annotations": {"team": "core"}}
+    assert "status" not in documents["Deployment"]
+
+
+@pytest.mark.asyncio
+async def test_export_bundle_can_include_secret_data():
+    handler, server = make_handler(_export_responses())
+    tool = server.tools["kubectl_export_bundle"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", workload_name="web",
+                        workload_type="deployment", include_secret_data=True, context=None, timeout_seconds=None)
+
+    assert result.warnings == []
+    assert "c2VjcmV0" in result.manifest
+
+
+@pytest.mark.asyncio
+async def test_kubectl_describe_merges_events():
+    pod = _pod("web-1", "2024-01-31T11:55:00Z", namespace="prod")
+    pod["metadata"]["labels"] = {"app": "web"}
+    event = {"type": "Warning", "reason": "BackOff", "count": 4, "lastTimestamp": "2024-01-31T11:58:00Z",
+             "source": {"component": "kubelet"}, "message": "Back-off restarting failed container"}
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "prod", "-o", "json"): pod,
+        ("get", "events", "-n", "prod", "--field-selector=involvedObject.name=web-1,involvedObject.kind=Pod",
+         "-o", "json"): {"items": [event]},
+    })
+    tool = server.tools["kubectl_describe"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pod", name="web-1", namespace="prod",
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.event_count == 1
+    assert "Namespace:    prod" in result.text
+    assert "app=web" in result.text
+    assert "Conditions:" in result.text and "Ready=True" in result.text
+    assert "BackOff" in result.text and "(x4)" in result.text
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_export_returns_reappliable_manifest():
+    pod = _pod("web-1", "2024-01-31T11:55:00Z", namespace="prod")
+    pod["apiVersion"], pod["kind"] = "v1", "Pod"
+    pod["metadata"].update(uid="1234", resourceVersion="42", managedFields=[{"manager": "kubelet"}])
+    handler, server = make_handler({
+        ("get", "pods", "web-1", "-n", "prod", "-o", "json"): pod,
+    })
+    tool = server.tools["kubectl_get"]
+
+    result = await tool(FakeContext(), **_call_kwargs(name="web-1", namespace="prod", export=True))
+
+    assert result.error is None
+    manifest = yaml.safe_load(result.yaml)
+    assert manifest == {
+        "metadata": {"name": "web-1", "namespace": "prod"},
+        "spec": {"containers": [{"name": "app"}]},
+        "apiVersion": "v1", "kind": "Pod",
+    }
+
+    result = await tool(FakeContext(), **_call_kwargs(name="web-1", namespace="prod", export=True, output="wide"))
+    assert result.error.error_code == "InvalidParameter"
+
+
+def _hpa():
+    return {
+        "apiVersion": "autoscaling/v2", "kind": "HorizontalPodAutoscaler",
+        "metadata": {"name": "web", "namespace": "prod", "creationTimestamp": "2024-01-31T11:00:00Z"},
+        "spec": {
+            "scaleTargetRef": {"apiVersion": "apps/v1", "kind": "Deployment", "name": "web"},
+            "minReplicas": 2, "maxReplicas": 5,
+            "metrics": [
+                {"type": "Resource", "resource": {"name": "cpu", "target": {"type": "Utilization",
+                                                                            "averageUtilization": 80}}},
+                {"type": "Pods", "pods": {"metric": {"name": "http_requests"},
+                                          "target": {"type": "AverageValue", "averageValue": "100"}}},
+            ],
+        },
+        "status": {
+            "currentReplicas": 5, "desiredReplicas": 5, "lastScaleTime": "2024-01-31T11:50:00Z",
+            "currentMetrics": [
+                {"type": "Pods", "pods": {"metric": {"name": "http_requests"}, "current": {"averageValue": "42"}}},
+                {"type": "Resource", "resource": {"name": "cpu", "current": {"averageUtilization": 95,
+                                                                             "averageValue": "475m"}}},
+            ],
+            "conditions": [
+                {"type": "AbleToScale", "status": "True", "reason": "ReadyForNewScale"},
+                {"type": "ScalingLimited", "status": "True", "reason": "TooManyReplicas",
+                 "message": "the desired replica count is more than the maximum replica count"},
+            ],
+        },
+    }
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_summarizes_hpa_metrics_and_conditions():
+    handler, server = make_handler({
+        ("get", "horizontalpodautoscalers", "web", "-n", "prod", "-o", "json"): _hpa(),
+    })
+
+    result = await server.tools["kubectl_get"](
+        FakeContext(), **_call_kwargs(resource="hpa", name="web", namespace="prod", output="wide")
+    )
+
+    assert result.error is None
+    assert result.resource == "horizontalpodautoscalers"
+    item = result.items[0]
+    assert item["reference"] == "Deployment/web"
+    assert (item["min_replicas"], item["max_replicas"], item["current_replicas"], item["desired_replicas"]) == (2, 5, 5, 5)
+    assert item["metrics"] == [
+        {"type": "Resource", "name": "cpu", "target": "80%", "current": "95%"},
+        {"type": "Pods", "name": "http_requests", "target": "100", "current": "42"},
+    ]
+    assert item["scaling_conditions"][1]["reason"] == "TooManyReplicas"
+    assert "Deployment/web   cpu: 95%/80%, http_requests: 42/100   2         5         5" in result.table
+
+
+@pytest.mark.asyncio
+async def test_kubectl_describe_hpa_shows_metrics_and_scaling_events():
+    event = {"type": "Normal", "reason": "SuccessfulRescale", "lastTimestamp": "2024-01-31T11:50:00Z",
+             "source": {"component": "horizontal-pod-autoscaler"},
+             "message": "New size: 5; reason: cpu resource utilization (percentage of request) above target"}
+    handler, server = make_handler({
+        ("get", "horizontalpodautoscalers", "web", "-n", "prod", "-o", "json"): _hpa(),
+        ("get", "events", "-n", "prod",
+         "--field-selector=involvedObject.name=web,involvedObject.kind=HorizontalPodAutoscaler",
+         "-o", "json"): {"items": [event]},
+    })
+
+    result = await server.tools["kubectl_describe"](FakeContext(), cluster_id="c1", resource="hpa", name="web",
+                                                    namespace="prod", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert "  type=Resource, name=cpu, target=80%, current=95%" in result.text
+    assert ("ScalingLimited=True (TooManyReplicas): the desired replica count is more than the maximum replica count"
+            in result.text)
+    assert "SuccessfulRescale" in result.text
+    assert "Scaling Conditions" not in result.text
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_highlights_quota_dimensions_near_limit():
+    quota = {
+        "apiVersion": "v1", "kind": "ResourceQuota",
+        "metadata": {"name": "compute", "namespace": "prod", "creationTimestamp": "2024-01-31T11:00:00Z"},
+        "spec": {"hard": {"requests.cpu": "2", "requests.memory": "4Gi", "pods": "10", "services.loadbalancers": "0"}},
+        "status": {
+            "hard": {"requests.cpu": "2", "requests.memory": "4Gi", "pods": "10", "services.loadbalancers": "0"},
+            "used": {"requests.cpu": "1900m", "requests.memory": "1Gi", "pods": "10"},
+        },
+    }
+    handler, server = make_handler({
+        ("get", "resourcequotas", "compute", "-n", "prod", "-o", "json"): quota,
+    })
+
+    result = await server.tools["kubectl_get"](
+        FakeContext(), **_call_kwargs(resource="quota", name="compute", namespace="prod", output="wide")
+    )
+
+    assert result.error is None
+    assert result.resource == "resourcequotas"
+    item = result.items[0]
+    usage = {u["resource"]: u for u in item["usage"]}
+    assert usage["requests.cpu"] == {"resource": "requests.cpu", "used": "1900m", "hard": "2", "ratio": 0.95,
+                                     "state": "NearLimit"}
+    assert usage["requests.memory"]["ratio"] == 0.25 and "state" not in usage["requests.memory"]
+    assert item["at_limit"] == ["pods", "services.loadbalancers"]
+    assert item["near_limit"] == ["requests.cpu"]
+    assert "pods: 10/10, requests.cpu: 1900m/2" in result.table
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_summarizes_limit_range_defaults():
+    limit_range = {
+        "apiVersion": "v1", "kind": "LimitRange",
+        "metadata": {"name": "defaults", "namespace": "prod", "creationTimestamp": "2024-01-31T11:00:00Z"},
+        "spec": {"limits": [
+            {"type": "Container", "default": {"cpu": "500m", "memory": "512Mi"},
+             "defaultRequest": {"cpu": "100m", "memory": "128Mi"}, "max": {"cpu": "2"}},
+            {"type": "PersistentVolumeClaim", "min": {"storage": "1Gi"}, "max": {"storage": "50Gi"}},
+        ]},
+    }
+    handler, server = make_handler({
+        ("get", "limitranges", "-n", "prod", "-o", "json"): {"items": [limit_range]},
+    })
+
+    result = await server.tools["kubectl_get"](FakeContext(), **_call_kwargs(resource="limits", namespace="prod", output="wide"))
+
+    assert result.error is None
+    limits = result.items[0]["limits"]
+    assert limits[0] == {"type": "Container", "resource": "cpu", "default": "500m", "default_request": "100m",
+                         "min": None, "max": "2", "max_limit_request_ratio": None}
+    assert limits[2] == {"type": "PersistentVolumeClaim", "resource": "storage", "default": None,
+                         "default_request": None, "min": "1Gi", "max": "50Gi", "max_limit_request_ratio": None}
+    assert "Container,PersistentVolumeClaim" in result.table
+
+
+@pytest.mark.asyncio
+async def test_single_object_tools_use_configured_default_namespace():
+    deployment = {"kind": "Deployment", "metadata": {"name": "web", "namespace": "team-a",
+                                                     "creationTimestamp": "2024-01-31T11:00:00Z"}}
+    handler, server = make_handler({
+        ("get", "deployments", "web", "-n", "team-a", "-o", "json"): deployment,
+        ("get", "events", "-n", "team-a", "--field-selector=involvedObject.name=web,involvedObject.kind=Deployment",
+         "-o", "json"): {"items": []},
+    }, settings={"default_namespace": "team-a"})
+
+    result = await server.tools["kubectl_get"](FakeContext(), **_call_kwargs(resource="deploy", name="web"))
+    assert result.error is None
+    assert result.namespace == "team-a"
+
+    result = await server.tools["kubectl_describe"](FakeContext(), cluster_id="c1", resource="deploy", name="web",
+                                                    namespace=None, context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.namespace == "team-a"
+
+
+def _core_v1_openapi():
+    ref = "#/components/schemas/"
+    return {"components": {"schemas": {
+        "io.k8s.api.core.v1.Pod": {
+            "description": "Pod is a collection of containers that can run on a host.",
+            "type": "object",
+            "properties": {
+                "metadata": {"allOf": [{"$ref": ref + "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}],
+                             "description": "Standard object's metadata."},
+                "spec": {"allOf": [{"$ref": ref + "io.k8s.api.core.v1.PodSpec"}],
+                         "description": "Specification of the desired behavior of the pod."},
+            },
+            "x-kubernetes-group-version-kind": [{"group": "", "kind": "Pod", "version": "v1"}],
+        },
+        "io.k8s.api.core.v1.PodSpec": {
+            "type": "object",
+            "required": ["containers"],
+            "properties": {
+                "containers": {"type": "array", "description": "List of containers belonging to the pod.",
+                               "items": {"allOf": [{"$ref": ref + "io.k8s.api.core.v1.Container"}], "default": {}}},
+                "nodeSelector": {"type": "object", "description": "NodeSelector is a selector.",
+                                 "additionalProperties": {"type": "string", "default": ""}},
+            },
+        },
+        "io.k8s.api.core.v1.Container": {
+            "type": "object",
+            "required": ["name"],
+            "properties": {
+                "name": {"type": "string", "description": "Name of the container."},
+                "ports": {"type": "array", "items": {"allOf": [{"$ref": ref + "io.k8s.api.core.v1.ContainerPort"}]}},
+                "resources": {"allOf": [{"$ref": ref + "io.k8s.api.core.v1.ResourceRequirements"}],
+                              "description": "Compute Resources required by this container."},
+            },
+        },
+        "io.k8s.api.core.v1.ContainerPort": {
+            "type": "object",
+            "properties": {"containerPort": {"type": "integer", "format": "int32"}},
+        },
+        "io.k8s.api.core.v1.ResourceRequirements": {
+            "type": "object",
+            "properties": {"limits": {"type": "object", "additionalProperties": {
+                "allOf": [{"$ref": ref + "io.k8s.apimachinery.pkg.api.resource.Quantity"}]}}},
+        },
+        "io.k8s.apimachinery.pkg.api.resource.Quantity": {"oneOf": [{"type": "string"}, {"type": "number"}]},
+        "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"type": "object", "properties": {"name": {"type": "string"}}},
+    }}}
+
+
+@pytest.mark.asyncio
+async def test_kubectl_explain_walks_nested_list_and_map_fields():
+    handler, server = make_handler({
+        ("get", "--raw", "/openapi/v3/api/v1"): _core_v1_openapi(),
+    })
+    tool = server.tools["kubectl_explain"]
+    kwargs = dict(cluster_id="c1", resource="po", api_version=None, context=None, timeout_seconds=None)
+
+    result = await tool(FakeContext(), field_path=None, **kwargs)
+    assert result.error is None
+    assert (result.kind, result.api_version, result.field_path) == ("Pod", "v1", None)
+    assert [(f.name, f.type) for f in result.fields] == [("metadata", "ObjectMeta"), ("spec", "PodSpec")]
+
+    result = await tool(FakeContext(), field_path="pod.spec.containers", **kwargs)
+    assert result.error is None
+    assert result.field_path == "spec.containers"
+    assert (result.type, result.required) == ("[]Container", True)
+    assert result.description == "List of containers belonging to the pod."
+    assert [(f.name, f.type, f.required) for f in result.fields] == [
+        ("name", "string", True), ("ports", "[]ContainerPort", False), ("resources", "ResourceRequirements", False),
+    ]
+
+    result = await tool(FakeContext(), field_path="spec.containers.resources.limits", **kwargs)
+    assert result.type == "map[string]Quantity"
+    result = await tool(FakeContext(), field_path="spec.nodeSelector", **kwargs)
+    assert result.type == "map[string]string" and result.fields == []
+
+    result = await tool(FakeContext(), field_path="spec.containers.image", **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    assert "field 'image' does not exist in spec.containers; available fields: name, ports, resources" in (
+        result.error.error_message)
+
+
+def test_resource_aliases_are_case_insensitive():
+    expected = {
+        "po": "pods", "Pod": "pods", "SVC": "services", "deploy": "deployments", "no": "nodes",
+        "cm": "configmaps", "NS": "namespaces", " pvc ": "persistentvolumeclaims", "PersistentVolume": "persistentvolumes",
+        "sts": "statefulsets", "ds": "daemonsets", "ing": "ingresses", "ev": "events",
+        "rs": "replicasets", "Job": "jobs", "cj": "cronjobs", "CronJob": "cronjobs",
+    }
+    for alias, resource in expected.items():
+        assert module_under_test.find_resource_spec(alias).resource == resource
+
+
+@pytest.mark.asyncio
+async def test_kubectl_describe_resolves_discovered_short_names():
+    created = "2024-01-31T11:00:00Z"
+    handler, server = make_handler({
+        ("api-resources",): {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+        ("get", "virtualservices.v1beta1.networking.istio.io", "reviews", "-n", "prod", "-o", "json"): {
+            "apiVersion": "networking.istio.io/v1beta1", "kind": "VirtualService",
+            "metadata": {"name": "reviews", "namespace": "prod", "creationTimestamp": created},
+        },
+        ("get", "events", "-n", "prod",
+         "--field-selector=involvedObject.name=reviews,involvedObject.kind=VirtualService", "-o", "json"): {"items": []},
+    })
+    tool = server.tools["kubectl_describe"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="VS", name="reviews", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.resource == "virtualservices"
+    assert "Kind:         VirtualService" in result.text
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="widgets", name="w", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+    assert result.error.error_code == "UnsupportedResource"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_describe_requires_name_and_handles_no_events():
+    handler, server = make_handler({
+        ("get", "nodes", "node-1", "-o", "json"): {"metadata": {"name": "node-1"}, "status": {}},
+        ("get", "events", "--all-namespaces",
+         "--field-selector=involvedObject.name=node-1,involvedObject.kind=Node", "-o", "json"): {"items": []},
+    })
+    tool = server.tools["kubectl_describe"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", name="", namespace=None, context=None,
+                        timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+    assert handler.runner.calls == []
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="node", name="node-1", namespace=None,
+                        timeout_seconds=None)
+    assert result.error is None
+    assert result.namespace is None
+    assert "Events:       No events found" in result.text
+
+
+def _owned(kind, name, uid, owner=None, **fields):
+    metadata = {"name": name, "namespace": "prod", "uid": uid}
+    if owner:
+        metadata["ownerReferences"] = [{"apiVersion": owner[0], "kind": owner[1], "name": owner[2], "uid": owner[3],
+                                        "controller": True}]
+    return {"kind": kind, "metadata": metadata, **fields}
+
+
+@pytest.mark.asyncio
+async def test_kubectl_owner_tree_walks_up_and_expands_down():
+    deploy_ref = ("apps/v1", "Deployment", "web", "d1")
+    deployment = _owned("Deployment", "web", "d1", spec={"replicas": 2}, status={"readyReplicas": 1})
+    new_rs = _owned("ReplicaSet", "web-new", "rs2", deploy_ref, spec={"replicas": 2}, status={"readyReplicas": 1})
+    old_rs = _owned("ReplicaSet", "web-old", "rs1", deploy_ref, spec={"replicas": 0}, status={})
+    other_rs = _owned("ReplicaSet", "api-1", "rs9", ("apps/v1", "Deployment", "api", "d9"))
+    pod = _pod("web-new-x", "2024-01-31T11:55:00Z", namespace="prod")
+    pod.update(_owned("Pod", "web-new-x", "p1", ("apps/v1", "ReplicaSet", "web-new", "rs2")))
+    sibling = _pod("web-new-y", "2024-01-31T11:55:00Z", namespace="prod")
+    sibling.update(_owned("Pod", "web-new-y", "p2", ("apps/v1", "ReplicaSet", "web-new", "rs2")))
+    sibling["status"]["phase"] = "Pending"
+    handler, server = make_handler({
+        ("get", "pods", "web-new-x", "-n", "prod", "-o", "json"): pod,
+        ("get", "replicasets", "web-new", "-n", "prod", "--ignore-not-found", "-o", "json"): new_rs,
+        ("get", "deployments", "web", "-n", "prod", "--ignore-not-found", "-o", "json"): deployment,
+        ("get", "replicasets", "-n", "prod", "-o", "json"): {"items": [old_rs, new_rs, other_rs]},
+        ("get", "pods", "-n", "prod", "-o", "json"): {"items": [pod, sibling]},
+    })
+    tool = server.tools["kubectl_owner_tree"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="po", name="web-new-x", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [(o["kind"], o["name"]) for o in result.owners] == [("ReplicaSet", "web-new"), ("Deployment", "web")]
+    tree = result.tree
+    assert (tree["kind"], tree["name"], tree["status"]) == ("Deployment", "web", "1/2 ready")
+    # 属主链上的子对象排在最前，其余 Deployment 的 ReplicaSet 不计入
+    assert [c["name"] for c in tree["children"]] == ["web-new", "web-old"]
+    pods = tree["children"][0]["children"]
+    assert [(p["name"], p.get("target")) for p in pods] == [("web-new-x", True), ("web-new-y", None)]
+    assert pods[1]["status"] == "Pending, 1/1 ready"
+    assert "children" not in tree["children"][1]
+    # 同一命名空间的同类资源只列举一次
+    assert handler.runner.calls.count(["get", "pods", "-n", "prod", "-o", "json"]) == 1
+
+
+@pytest.mark.asyncio
+async def test_kubectl_owner_tree_reports_missing_and_unknown_owners():
+    orphan = _owned("Pod", "web-x", "p1", ("apps/v1", "ReplicaSet", "web-gone", "rs1"), spec={}, status={})
+    custom = _owned("Pod", "db-0", "p2", ("example.com/v1", "Database", "db", "db1"), spec={}, status={})
+    handler, server = make_handler({
+        ("get", "pods", "web-x", "-n", "prod", "-o", "json"): orphan,
+        ("get", "replicasets", "web-gone", "-n", "prod", "--ignore-not-found", "-o", "json"): {},
+        ("get", "pods", "db-0", "-n", "prod", "-o", "json"): custom,
+        ("api-resources",): {"exit_code": 0, "stdout": API_RESOURCES, "stderr": ""},
+    })
+    tool = server.tools["kubectl_owner_tree"]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", name="web-x", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.owners == []
+    assert result.warnings == ["owner ReplicaSet/web-gone no longer exists"]
+    assert result.tree["name"] == "web-x" and result.tree["target"] is True
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", name="db-0", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+    assert result.error is None
+    assert "owner Database/db (example.com/v1) is not a known resource type" in result.warnings[0]
+
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", name="missing", namespace="prod",
+                        api_version=None, context=None, timeout_seconds=None)
+    assert result.error is not None
+
+
+APPLY_ARGS = ("apply", "--server-side", "--field-manager=ack-mcp-server", "-f", "-", "-o", "json")
+
+MULTI_DOC_MANIFEST = """
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  LOG_LEVEL: info
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: other
+spec:
+  replicas: 2
+---
+"""
+
+
+@pytest.mark.asyncio
+async def test_kubectl_apply_rejected_in_read_only_mode():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_apply"]
+
+    result = await tool(FakeContext(), cluster_id="c1", manifest=MULTI_DOC_MANIFEST, namespace="prod",
+                        dry_run=False, force_conflicts=False, context=None, timeout_seconds=None)
+
+    assert result.error.error_code == "WriteNotAllowed"
+    assert "read-only" in result.error.error_message
+    assert handler.runner.calls == []
+
+
+@pytest.mark.asyncio
+async def test_kubectl_apply_applies_each_document():
+    applied = []
+
+    def apply(stdin):
+        obj = json.loads(stdin)
+        applied.append(obj)
+        if obj["kind"] == "Deployment":
+            raise KubectlCommandError(
+                "conflict",
+                stderr='Error from server (Forbidden): deployments.apps "web" is forbidden: User "dev" cannot '
+                       'patch resource "deployments" in API group "apps" in the namespace "other"',
+            )
+        return {**obj, "metadata": {**obj["metadata"], "namespace": "prod"}}
+
+    handler, server = make_handler({(*APPLY_ARGS, "-n", "prod", "--dry-run=server"): apply},
+                                   settings={"allow_write": True})
+    tool = server.tools["kubectl_apply"]
+
+    result = await tool(FakeContext(), cluster_id="c1", manifest=MULTI_DOC_MANIFEST, namespace="prod",
+                        dry_run=True, force_conflicts=False, context=None, timeout_seconds=None)
+
+    assert [obj["kind"] for obj in applied] == ["ConfigMap", "Deployment"]
+    assert result.dry_run is True
+    assert result.applied == 1 and result.failed == 1
+    assert result.results[0] == {"kind": "ConfigMap", "name": "web-config", "namespace": "prod", "status": "applied"}
+    assert result.results[1]["status"] == "failed"
+    assert result.results[1]["namespace"] == "other"
+    assert result.error.error_code == "Forbidden"
+    assert result.error.error_message.startswith("1 of 2 objects failed to apply")
+
+
+@pytest.mark.asyncio
+async def test_kubectl_apply_validates_manifest():
+    handler, server = make_handler({}, settings={"allow_write": True})
+    tool = server.tools["kubectl_apply"]
+
+    for manifest in ["", "kind: ConfigMap\nmetadata:\n  name: a", "- a\n- b", "{invalid"]:
+        result = await tool(FakeContext(), cluster_id="c1", manifest=manifest, namespace=None,
+                            dry_run=False, force_conflicts=False, context=None, timeout_seconds=None)
+        assert result.error.error_code == "InvalidParameter", manifest
+    assert handler.runner.calls == []
+
+
+
+@pytest.mark.asyncio
+async def test_kubectl_diff_compares_each_document():
+    def get(stdin):
+        obj = json.loads(stdin)
+        if obj["kind"] == "Deployment":
+            return {"apiVersion": "v1", "kind": "List", "items": []}
+        return {**obj, "metadata": {**obj["metadata"], "namespace": "prod", "uid": "u1"}, "data": {"a": "old"}}
+
+    handler, server = make_handler(
+        {("get", "-f", "-", "--ignore-not-found", "-o", "json", "-n", "prod"): get},
+    )
+    tool = server.tools["kubectl_diff"]
+
+    result = await tool(FakeContext(), cluster_id="c1", manifest=MULTI_DOC_MANIFEST, namespace="prod",
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [(r["kind"], r["status"]) for r in result.results] == [("ConfigMap", "update"), ("Deployment", "create")]
+    assert result.changed == 2
+    assert "--- live/ConfigMap/web-config" in result.diff and "+++ desired/Deployment/web" in result.diff
+
+
+def _deployment(generation=2, observed=2, replicas=3, updated=3, available=3, conditions=None):
+    return {
+        "metadata": {"name": "web", "namespace": "prod", "generation": generation},
+        "spec": {"replicas": 3},
+        "status": {
+            "observedGeneration": observed, "replicas": replicas, "updatedReplicas": updated,
+            "readyReplicas": available, "availableReplicas": available, "conditions": conditions or [],
+        },
+    }
+
+
+@pytest.mark.asyncio
+async def test_kubectl_rollout_status_reports_progress():
+    handler, server = make_handler({
+        ("get", "deployment", "web", "-n", "prod", "-o", "json"): _deployment(replicas=4, updated=3, available=2, conditions=[
+            {"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable",
+             "message": "Deployment has minimum availability."},
+            {"type": "Progressing", "status": "True", "reason": "ReplicaSetUpdated",
+             "message": 'ReplicaSet "web-7d4b9" is progressing.', "lastUpdateTime": "2024-01-31T11:59:00Z"},
+        ]),
+    })
+    tool = server.tools["kubectl_rollout"]
+
+    result = await tool(FakeContext(), cluster_id="c1", name="web", namespace="prod", action="status",
+                        context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.state == "progressing"
+    assert result.message == "1 old replicas are pending termination"
+    assert (result.desired_replicas, result.updated_replicas, result.available_replicas) == (3, 3, 2)
+    assert [c["type"] for c in result.conditions] == ["Available", "Progressing"]
+    assert result.conditions[1]["message"] == 'ReplicaSet "web-7d4b9" is progressing.'
+
+    result = await tool(FakeContext(), cluster_id="c1", name="web", namespace="prod", action="undo",
+                        context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_rollout_restart_patches_template_annotation(monkeypatch):
+    class FixedDatetime(datetime):
+        @classmethod
+        def now(cls, tz=None):
+            return SERVER_NOW
+
+    monkeypatch.setattr(module_under_test, "datetime", FixedDatetime)
+    patch = {"spec": {"template": {"metadata": {"annotations": {
+        "kubectl.kubernetes.io/restartedAt": "2024-01-31T12:00:00Z",
+    }}}}}
+    responses = {
+        ("patch", "deployment", "web", "-n", "prod", "--type=merge", "-p", json.dumps(patch), "-o", "json"):
+            _deployment(generation=3, observed=2),
+    }
+
+    handler, server = make_handler(responses)
+    result = await server.tools["kubectl_rollout"](FakeContext(), cluster_id="c1", name="web", namespace="prod",
+                                                   action="restart", context=None, timeout_seconds=None)
+    assert result.error.error_code == "WriteNotAllowed"
+    assert handler.runner.calls == []
+
+    handler, server = make_handler(responses, settings={"allow_write": True})
+    result = await server.tools["kubectl_rollout"](FakeContext(), cluster_id="c1", name="web", namespace="prod",
+                                                   action="restart", context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.restarted_at == "2024-01-31T12:00:00Z"
+    assert result.state == "progressing"
+    assert result.message == 'waiting for deployment "web" spec update to be observed'
+
+
+def _rollout_watch_args(resource_version, seconds):
+    return ("get", "--raw", "/apis/apps/v1/namespaces/prod/deployments?watch=true&fieldSelector=metadata.name%3Dweb"
+            f"&resourceVersion={resource_version}&timeoutSeconds={seconds}")
+
+
+@pytest.mark.asyncio
+async def test_kubectl_rollout_wait_returns_when_rollout_completes():
+    started = _deployment(generation=3, observed=2)
+    started["metadata"]["resourceVersion"] = "100"
+    halfway = _deployment(generation=3, observed=3, updated=2, available=2)
+    done = _deployment(generation=3, observed=3)
+    handler, server = make_handler({
+        ("get", "deployment", "web", "-n", "prod", "-o", "json"): started,
+        _rollout_watch_args("100", 120): {"lines": [
+            json.dumps({"type": "MODIFIED", "object": halfway}) + "\n",
+            json.dumps({"type": "MODIFIED", "object": done}) + "\n",
+            json.dumps({"type": "MODIFIED", "object": halfway}) + "\n",
+        ]},
+    })
+
+    result = await server.tools["kubectl_rollout"](FakeContext(), cluster_id="c1", name="web", namespace="prod",
+                                                   action="wait", context=None, timeout_seconds=120)
+
+    assert result.error is None
+    assert result.state == "complete"
+    assert result.timed_out is False
+    assert result.blocking_condition is None
+    assert handler.runner.calls[-1] == list(_rollout_watch_args("100", 120))
+
+
+@pytest.mark.asyncio
+async def test_kubectl_rollout_wait_timeout_reports_blocking_condition():
+    blocked = _deployment(updated=1, available=0, conditions=[
+        {"type": "Available", "status": "False", "reason": "MinimumReplicasUnavailable",
+         "message": "Deployment does not have minimum availability."},
+        {"type": "ReplicaFailure", "status": "True", "reason": "FailedCreate",
+         "message": 'pods "web-7d4b9-x" is forbidden: exceeded quota: compute'},
+    ])
+    blocked["metadata"]["resourceVersion"] = "7"
+    handler, server = make_handler({
+        ("get", "deployment", "web", "-n", "prod", "-o", "json"): blocked,
+        _rollout_watch_args("7", 5): {"lines": [], "reason": "timeout"},
+    })
+
+    result = await server.tools["kubectl_rollout"](FakeContext(), cluster_id="c1", name="web", namespace="prod",
+                                                   action="wait", context=None, timeout_seconds=5)
+
+    assert result.error is None
+    assert result.timed_out is True
+    assert result.state == "progressing"
+    assert result.message == "1 out of 3 new replicas have been updated"
+    assert result.blocking_condition == (
+        'ReplicaFailure=True (FailedCreate): pods "web-7d4b9-x" is forbidden: exceeded quota: compute'
+    )
+
+
+def _crd(group, kind, plural, scope="Namespaced", versions=("v1",), established=True):
+    return {
+        "metadata": {"name": f"{plural}.{group}"},
+        "spec": {
+            "group": group, "scope": scope,
+            "names": {"kind": kind, "plural": plural, "shortNames": [plural[:3]]},
+            "versions": [{"name": v, "served": True, "storage": i == 0} for i, v in enumerate(versions)],
+        },
+        "status": {"conditions": [{"type": "Established", "status": "True" if established else "False"}]},
+    }
+
+
+@pytest.mark.asyncio
+async def test_list_crds_filters_by_group_wildcard():
+    handler, server = make_handler({
+        ("get", "customresourcedefinitions", "-o", "json"): {"items": [
+            _crd("argoproj.io", "Rollout", "rollouts"),
+            _crd("alibabacloud.com", "ElasticQuotaTree", "elasticquotatrees", scope="Cluster"),
+            _crd("gateway.aliyun.com", "ManagedGateway", "managedgateways", versions=("v1", "v1beta1"),
+                 established=False),
+            _crd("cms.aliyun.com", "AlertRule", "alertrules"),
+        ]},
+    })
+    tool = server.tools["list_crds"]
+
+    result = await tool(FakeContext(), cluster_id="c1", group="*.aliyun.com", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert [crd["kind"] for crd in result.crds] == ["AlertRule", "ManagedGateway"]
+    gateway = result.crds[1]
+    assert gateway["versions"] == [{"name": "v1", "served": True, "storage": True},
+                                   {"name": "v1beta1", "served": True, "storage": False}]
+    assert gateway["storage_version"] == "v1"
+    assert gateway["established"] is False
+    assert gateway["scope"] == "Namespaced"
+
+    result = await tool(FakeContext(), cluster_id="c1", group=None, context=None, timeout_seconds=None)
+    assert result.count == 4
+    assert result.crds[0]["group"] == "alibabacloud.com"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_get_wide_and_custom_columns_tables():
+    handler, server = make_handler({
+        ("get", "pods", "--all-namespaces", "-o", "json"): {"kind": "List", "items": [
+            _pod("web-1", "2024-01-31T11:55:00Z"),
+            {**_pod("api-7d4b9c-x2k", "2024-01-31T11:00:00Z", namespace="prod"), "spec": {"containers": [{}, {}]}},
+        ]},
+    })
+    tool = server.tools["kubectl_get"]
+
+    # max_age 使参考时间取自 API Server，存活时间可预期
+    result = await tool(FakeContext(), **_call_kwargs(output="wide", max_age="1d"))
+    assert result.error is None
+    assert result.table.splitlines() == [
+        "NAMESPACE   NAME             READY   STATUS    RESTARTS   AGE   IP         NODE",
+        "default     web-1            1/1     Running   2          5m    10.0.0.1   node-1",
+        "prod        api-7d4b9c-x2k   1/2     Running   2          60m   10.0.0.1   <none>",
+    ]
+
+    result = await tool(FakeContext(), **_call_kwargs(
+        output="custom-columns=NAME:.metadata.name,NODE:.spec.nodeName,CONTAINERS:.spec.containers[*].name"))
+    assert result.error is None
+    assert result.table.splitlines() == [
+        "NAME             NODE     CONTAINERS",
+        "web-1            node-1   app",
+        "api-7d4b9c-x2k   <none>   <none>",
+    ]
+
+    result = await tool(FakeContext(), **_call_kwargs(output="custom-columns=NAME"))
+    assert result.error.error_code == "InvalidParameter"
+
+
+def _node_pod(name, namespace="default", owner=None, annotations=None, volumes=None):
+    metadata = {"name": name, "namespace": namespace, "annotations": annotations or {}}
+    if owner:
+        metadata["ownerReferences"] = [{"kind": owner[0], "name": owner[1], "controller": True}]
+    return {"metadata": metadata, "spec": {"nodeName": "node-1", "volumes": volumes or []},
+            "status": {"phase": "Running"}}
+
+
+def _eviction_args(namespace, name):
+    return ("create", "--raw", f"/api/v1/namespaces/{namespace}/pods/{name}/eviction", "-f", "-")
+
+
+PDB_BLOCKED = {"exit_code": 1, "stdout": "", "stderr": (
+    "Error from server (TooManyRequests): Cannot evict pod as it would violate the pod's disruption budget."
+)}
+EVICTED = {"exit_code": 0, "stdout": "", "stderr": ""}
+
+
+@pytest.mark.asyncio
+async def test_kubectl_node_drain_evicts_and_reports_skipped_pods(monkeypatch):
+    sleeps = []
+
+    async def fake_sleep(delay):
+        sleeps.append(delay)
+
+    monkeypatch.setattr(module_under_test.asyncio, "sleep", fake_sleep)
+    handler, server = make_handler({
+        ("patch", "node", "node-1", "--type=merge", "-p", '{"spec": {"unschedulable": true}}', "-o", "json"): {
+            "metadata": {"name": "node-1"}, "spec": {"unschedulable": True},
+        },
+        ("get", "pods", "--all-namespaces", "--field-selector=spec.nodeName=node-1", "-o", "json"): {"items": [
+            _node_pod("web-7d4b9-x2k", owner=("ReplicaSet", "web-7d4b9")),
+            _node_pod("db-0", owner=("StatefulSet", "db")),
+            _node_pod("fluentd-abc", "kube-system", owner=("DaemonSet", "fluentd")),
+            _node_pod("kube-proxy-node-1", "kube-system", annotations={"kubernetes.io/config.mirror": "x"}),
+            _node_pod("debug"),
+            _node_pod("cache-1", owner=("ReplicaSet", "cache-5f"), volumes=[{"name": "tmp", "emptyDir": {}}]),
+        ]},
+        _eviction_args("default", "web-7d4b9-x2k"): EVICTED,
+        _eviction_args("default", "db-0"): [PDB_BLOCKED, EVICTED],
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_node"]
+
+    result = await tool(FakeContext(), cluster_id="c1", node="node-1", action="drain", force=False,
+                        delete_emptydir_data=False, context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.unschedulable is True
+    assert result.evicted == [{"namespace": "default", "name": "web-7d4b9-x2k"}, {"namespace": "default", "name": "db-0"}]
+    assert sleeps == [module_under_test.DRAIN_RETRY_INTERVAL]
+    skipped = {s["name"]: s["reason"] for s in result.skipped}
+    assert skipped["fluentd-abc"] == "managed by DaemonSet fluentd"
+    assert skipped["kube-proxy-node-1"].startswith("mirror pod")
+    assert "force=true" in skipped["debug"]
+    assert "delete_emptydir_data=true" in skipped["cache-1"]
+    assert result.failed == []
+
+
+@pytest.mark.asyncio
+async def test_kubectl_node_requires_write_access_and_reports_pdb_timeout():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_node"]
+    result = await tool(FakeContext(), cluster_id="c1", node="node-1", action="cordon", force=False,
+                        delete_emptydir_data=False, context=None, timeout_seconds=None)
+    assert result.error.error_code == "WriteNotAllowed"
+    assert handler.runner.calls == []
+
+    handler, server = make_handler({
+        ("patch", "node", "node-1", "--type=merge", "-p", '{"spec": {"unschedulable": true}}', "-o", "json"): {
+            "spec": {"unschedulable": True},
+        },
+        ("get", "pods", "--all-namespaces", "--field-selector=spec.nodeName=node-1", "-o", "json"): {"items": [
+            _node_pod("db-0", owner=("StatefulSet", "db")),
+        ]},
+        _eviction_args("default", "db-0"): PDB_BLOCKED,
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_node"]
+    # 超时短于重试间隔时，被 PDB 阻止的 Pod 直接列入 failed
+    result = await tool(FakeContext(), cluster_id="c1", node="node-1", action="drain", force=False,
+                        delete_emptydir_data=False, context=None, timeout_seconds=2)
+    assert result.evicted == []
+    assert result.failed[0]["name"] == "db-0"
+    assert "disruption budget" in result.failed[0]["reason"]
+    assert result.error.error_code == "DrainIncomplete"
+
+    result = await tool(FakeContext(), cluster_id="c1", node="node-1", action="reboot", force=False,
+                        delete_emptydir_data=False, context=None, timeout_seconds=None)
+    assert result.error.error_code == "InvalidParameter"
+
+
+@pytest.mark.asyncio
+async def test_cluster_summary_counts_nodes_pods_and_deployments():
+    def node(name, ready, unschedulable=False):
+        return {"metadata": {"name": name}, "spec": {"unschedulable": unschedulable},
+                "status": {"conditions": [{"type": "Ready", "status": "True" if ready else "False"}]}}
+
+    def pod(phase):
+        return {"metadata": {"name": "p"}, "status": {"phase": phase}}
+
+    def deployment(replicas, available):
+        return {"metadata": {"name": "d"}, "spec": {"replicas": replicas}, "status": {"availableReplicas": available}}
+
+    handler, server = make_handler({
+        ("get", "--raw", "/version"): {"gitVersion": "v1.31.1-aliyun.1"},
+        ("get", "nodes", "-o", "json"): {"items": [node("n1", True), node("n2", True, unschedulable=True),
+                                                   node("n3", False)]},
+        ("get", "pods", "--all-namespaces", "-o", "json"): {"items": [
+            pod("Running"), pod("Running"), pod("Pending"), pod("Failed"), pod("Succeeded"),
+        ]},
+        ("get", "deployments", "--all-namespaces", "-o", "json"): {"items": [
+            deployment(2, 2), deployment(3, 1), deployment(0, None),
+        ]},
+        ("get", "namespaces", "-o", "json"): {"items": [{"metadata": {"name": n}} for n in ("default", "kube-system")]},
+    })
+    tool = server.tools["cluster_summary"]
+
+    result = await tool(FakeContext(), cluster_id="c1", context=None, timeout_seconds=None)
+
+    assert result.error is None
+    assert result.server_version == "v1.31.1-aliyun.1"
+    assert result.nodes == {"total": 3, "ready": 2, "not_ready": 1, "unschedulable": 1}
+    assert result.pods == {"total": 5, "Running": 2, "Pending": 1, "Succeeded": 1, "Failed": 1, "Unknown": 0}
+    assert result.deployments == {"total": 3, "available": 2, "unavailable": 1}
+    assert result.namespaces == 2
+
+
+@pytest.mark.asyncio
+async def test_kubectl_delete_requires_confirmation_and_reports_result(monkeypatch):
+    class FixedDatetime(datetime):
+        @classmethod
+        def now(cls, tz=None):
+            return SERVER_NOW
+
+    monkeypatch.setattr(module_under_test, "datetime", FixedDatetime)
+    delete_args = ("delete", "configmaps", "migrate", "-n", "prod", "--wait=false")
+    handler, server = make_handler({
+        delete_args + ("--dry-run=server",): {"exit_code": 0, "stdout": 'configmap "migrate" deleted (server dry run)\n',
+                                              "stderr": ""},
+        delete_args: {"exit_code": 0, "stdout": 'configmap "migrate" deleted\n', "stderr": ""},
+        ("get", "configmaps", "migrate", "-n", "prod", "--ignore-not-found", "-o", "json"): {},
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_delete"]
+    kwargs = dict(resource="cm", name="migrate", namespace="prod", api_version=None, context=None,
+                  timeout_seconds=None)
+
+    result = await tool(FakeContext(), cluster_id="c1", confirm="migrat", dry_run=False, **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    assert handler.runner.calls == []
+
+    result = await tool(FakeContext(), cluster_id="c1", confirm="migrate", dry_run=True, **kwargs)
+    assert result.error is None
+    assert result.status == "dry_run"
+    assert result.message == "would delete ConfigMap/migrate in namespace prod"
+    assert result.deletion_timestamp is None
+
+    result = await tool(FakeContext(), cluster_id="c1", confirm="migrate", dry_run=False, **kwargs)
+    assert result.status == "deleted"
+    assert result.deletion_timestamp == "2024-01-31T12:00:00Z"
+    assert handler.runner.calls[-2] == list(delete_args)
+
+
+@pytest.mark.asyncio
+async def test_kubectl_delete_reports_pending_finalizers_and_read_only():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_delete"]
+    result = await tool(FakeContext(), cluster_id="c1", resource="pods", name="web", confirm="web", namespace=None,
+                        dry_run=True, api_version=None, context=None, timeout_seconds=None)
+    assert result.error.error_code == "WriteNotAllowed"
+
+    handler, server = make_handler({
+        ("delete", "namespaces", "team-a", "--wait=false"): {"exit_code": 0, "stdout": "", "stderr": ""},
+        ("get", "namespaces", "team-a", "--ignore-not-found", "-o", "json"): {"metadata": {
+            "name": "team-a", "deletionTimestamp": "2024-05-01T11:59:00Z", "finalizers": ["example.com/cleanup"],
+        }},
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_delete"]
+    result = await tool(FakeContext(), cluster_id="c1", resource="ns", name="team-a", confirm="team-a",
+                        namespace="ignored", dry_run=False, api_version=None, context=None, timeout_seconds=None)
+    assert result.namespace is None
+    assert result.status == "terminating"
+    assert result.deletion_timestamp == "2024-05-01T11:59:00Z"
+    assert result.finalizers == ["example.com/cleanup"]
+
+
+def _exec_pod(state="running"):
+    return {
+        "metadata": {"name": "web-0", "namespace": "prod"},
+        "spec": {"containers": [{"name": "app"}, {"name": "sidecar"}]},
+        "status": {"phase": "Running", "containerStatuses": [
+            {"name": "app", "state": {state: {}}}, {"name": "sidecar", "state": {"running": {}}},
+        ]},
+    }
+
+
+@pytest.mark.asyncio
+async def test_kubectl_metadata_patches_labels_with_resource_version():
+    node = {"kind": "Node", "metadata": {"name": "node-1", "resourceVersion": "42",
+                                         "labels": {"disktype": "hdd", "zone": "a"}, "annotations": {"x": "1"}}}
+    patch = {"metadata": {"resourceVersion": "42", "labels": {"disktype": "ssd", "zone": None}}}
+    patched = {"kind": "Node", "metadata": {"name": "node-1", "labels": {"disktype": "ssd"}, "annotations": {"x": "1"}}}
+    handler, server = make_handler({
+        ("get", "nodes", "node-1", "-o", "json"): node,
+        ("patch", "nodes", "node-1", "--type=merge", "-p", json.dumps(patch), "-o", "json"): patched,
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_metadata"]
+    kwargs = dict(cluster_id="c1", resource="node", name="node-1", action="label", namespace=None, api_version=None,
+                  context=None, timeout_seconds=None)
+
+    result = await tool(FakeContext(), changes=["disktype=ssd", "zone-", "gpu-"], overwrite=False, **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    assert "set overwrite=true" in result.error.error_message
+    assert not any(call[0] == "patch" for call in handler.runner.calls)
+
+    result = await tool(FakeContext(), changes=["disktype=ssd", "zone-", "gpu-"], overwrite=True, **kwargs)
+    assert result.error is None
+    assert result.namespace is None
+    assert result.changed == ["disktype", "zone"]
+    assert result.labels == {"disktype": "ssd"} and result.annotations == {"x": "1"}
+    assert result.warnings == ["label 'gpu' not found, nothing to remove"]
+
+    # 值未变化时不提交 patch
+    handler.runner.calls.clear()
+    result = await tool(FakeContext(), changes=["disktype=hdd"], overwrite=False, **kwargs)
+    assert result.error is None and result.changed == []
+    assert handler.runner.calls == [["get", "nodes", "node-1", "-o", "json"]]
+
+
+@pytest.mark.asyncio
+async def test_kubectl_metadata_validates_before_patching_and_requires_write():
+    handler, server = make_handler({}, settings={"allow_write": True})
+    tool = server.tools["kubectl_metadata"]
+    kwargs = dict(cluster_id="c1", resource="deploy", name="web", namespace="prod", overwrite=False,
+                  api_version=None, context=None, timeout_seconds=None)
+
+    result = await tool(FakeContext(), action="label", changes=["app=web server"], **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    result = await tool(FakeContext(), action="taint", changes=["a=b"], **kwargs)
+    assert "label, annotate" in result.error.error_message
+    assert handler.runner.calls == []
+
+    handler, server = make_handler({})
+    result = await server.tools["kubectl_metadata"](FakeContext(), action="annotate", changes=["a=b"], **kwargs)
+    assert result.error.error_code == "WriteNotAllowed"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_patch_applies_patch_and_reports_change():
+    deployment = {"kind": "Deployment", "metadata": {"name": "web", "namespace": "prod", "resourceVersion": "7"},
+                  "spec": {"replicas": 2}}
+    patched = {"kind": "Deployment", "metadata": {"name": "web", "namespace": "prod", "resourceVersion": "8"},
+               "spec": {"replicas": 3}}
+    operations = [{"op": "replace", "path": "/spec/replicas", "value": 3}]
+    handler, server = make_handler({
+        ("get", "deployments", "web", "-n", "prod", "-o", "json"): deployment,
+        ("patch", "deployments", "web", "-n", "prod", "--type=json", "-p", json.dumps(operations), "-o", "json"): patched,
+        ("patch", "deployments", "web", "-n", "prod", "--type=strategic", "-p", json.dumps({"spec": {"replicas": 3}}),
+         "-o", "json", "--dry-run=server"): patched,
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_patch"]
+    kwargs = dict(cluster_id="c1", resource="deploy", name="web", namespace="prod", api_version=None, context=None,
+                  timeout_seconds=None)
+
+    result = await tool(FakeContext(), patch=json.dumps(operations), patch_type="json", dry_run=False, **kwargs)
+    assert result.error is None
+    assert result.resource == "deployments"
+    assert result.changed is True
+    assert result.object["spec"]["replicas"] == 3
+
+    # dry-run 不读取原对象，changed 为空
+    handler.runner.calls.clear()
+    result = await tool(FakeContext(), patch='{"spec": {"replicas": 3}}', patch_type="strategic", dry_run=True, **kwargs)
+    assert result.error is None and result.dry_run and result.changed is None
+    assert [call[0] for call in handler.runner.calls] == ["patch"]
+
+
+@pytest.mark.asyncio
+async def test_kubectl_patch_validates_patch_and_requires_write():
+    handler, server = make_handler({}, settings={"allow_write": True})
+    tool = server.tools["kubectl_patch"]
+    kwargs = dict(cluster_id="c1", resource="deploy", name="web", namespace="prod", dry_run=False, api_version=None,
+                  context=None, timeout_seconds=None)
+
+    result = await tool(FakeContext(), patch="{spec: {}}", patch_type="merge", **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    assert "not valid JSON" in result.error.error_message
+    result = await tool(FakeContext(), patch='{"spec": {}}', patch_type="json", **kwargs)
+    assert "array of operations" in result.error.error_message
+    assert handler.runner.calls == []
+
+    handler, server = make_handler({})
+    result = await server.tools["kubectl_patch"](FakeContext(), patch='{"a": 1}', patch_type="merge", **kwargs)
+    assert result.error.error_code == "WriteNotAllowed"
+
+
+@pytest.mark.asyncio
+async def test_kubectl_exec_returns_output_and_exit_code(monkeypatch):
+    monkeypatch.setattr(module_under_test, "MAX_EXEC_OUTPUT_BYTES", 16)
+    handler, server = make_handler({
+        ("get", "pods", "web-0", "-n", "prod", "-o", "json"): _exec_pod(),
+        ("exec", "web-0", "-n", "prod", "-c", "app", "--", "cat", "/etc/hostname"): {
+            "exit_code": 0, "stdout": "web-0\n", "stderr": "",
+        },
+        ("exec", "web-0", "-n", "prod", "-c", "app", "--", "ls", "/data", "/nope"): {
+            "exit_code": 2, "stdout": "a.txt\nb.txt\n",
+            "stderr": "ls: /nope: No such file or directory\ncommand terminated with exit code 2",
+        },
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_exec"]
+
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", name="web-0", command=["cat", "/etc/hostname"],
+                        container="app", context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.exit_code == 0
+    assert result.output == "web-0\n"
+
+    # 命令非零退出不视为工具错误；输出超过上限时截断
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", name="web-0", command=["ls", "/data", "/nope"],
+                        container="app", context=None, timeout_seconds=None)
+    assert result.error is None
+    assert result.exit_code == 2
+    assert result.output == "a.txt\nb.txt\nls: "
+    assert result.truncated is True
+
+
+@pytest.mark.asyncio
+async def test_kubectl_exec_reports_missing_container_pod_and_read_only():
+    handler, server = make_handler({})
+    tool = server.tools["kubectl_exec"]
+    result = await tool(FakeContext(), cluster_id="c1", namespace="prod", name="web-0", command=["id"],
+                        container=None, context=None, timeout_seconds=None)
+    assert result.error.error_code == "WriteNotAllowed"
+
+    handler, server = make_handler({
+        ("get", "pods", "web-0", "-n", "prod", "-o", "json"): _exec_pod(state="waiting"),
+        ("get", "pods", "gone", "-n", "prod", "-o", "json"): KubectlCommandError(
+            'Error from server (NotFound): pods "gone" not found',
+            stderr='Error from server (NotFound): pods "gone" not found',
+        ),
+    }, settings={"allow_write": True})
+    tool = server.tools["kubectl_exec"]
+    kwargs = dict(cluster_id="c1", namespace="prod", command=["id"], context=None, timeout_seconds=None)
+
+    result = await tool(FakeContext(), name="web-0", container=None, **kwargs)
+    assert result.error.error_code == "InvalidParameter"
+    assert "app, sidecar" in result.error.error_message
+    result = await tool(FakeContext(), name="web-0", container="db", **kwargs)
+    assert result.error.error_code == "ContainerNotFound"
+    result = await tool(FakeContext(), name="web-0", container="app", **kwargs)
+    assert result.error.error_code == "ContainerNotRunning"
+    result = await tool(FakeContext(), name="gone", container="app", **kwargs)
+    assert result.error.error_code == "NotFound"
+    assert not any(call[0] == "exec" for call in handler.runner.calls)
@@ -0,0 +1,119 @@
+import asyncio
+import os
+import sys
+import time
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import kubectl_runner as module_under_test
+from models import ExecutionLog
+
+
+class ScriptedExec:
+    """按顺序返回预置结果的 _exec 替身，记录实际执行的命令与超时"""
+
+    def __init__(self, results):
+        self.results = list(results)
+        self.calls = []
+
+    async def __call__(self, cmd, timeout, stdin):
+        self.calls.append((cmd, timeout))
+        return self.results.pop(0)
+
+
+def make_runner(monkeypatch, results, **settings):
+    runner = module_under_test.KubectlRunner(settings)
+    scripted = ScriptedExec(results)
+    monkeypatch.setattr(runner, "_exec", scripted)
+    sleeps = []
+
+    async def fake_sleep(delay):
+        sleeps.append(delay)
+
+    monkeypatch.setattr(module_under_test.asyncio, "sleep", fake_sleep)
+    return runner, scripted, sleeps
+
+
+THROTTLED = {"exit_code": 1, "stdout": "", "stderr": (
+    "Error from server (TooManyRequests): the server has received too many requests and has asked us to try "
+    "again later (get pods)"
+)}
+OK = {"exit_code": 0, "stdout": '{"items": []}', "stderr": ""}
+
+
+@pytest.mark.asyncio
+async def test_read_requests_retry_transient_errors(monkeypatch):
+    runner, scripted, sleeps = make_runner(monkeypatch, [THROTTLED, THROTTLED, OK])
+    execution_log = ExecutionLog(tool_call_id="t")
+
+    data = await runner.run_json("/tmp/kubeconfig", ["get", "pods", "-o", "json"], execution_log, timeout=60)
+
+    assert data == {"items": []}
+    assert len(scripted.calls) == 3
+    assert scripted.calls[0][0] == [
+        "kubectl", "--kubeconfig", "/tmp/kubeconfig", "--request-timeout=15s", "get", "pods", "-o", "json",
+    ]
+    assert sleeps == [0.5, 1.0]
+    assert [call["attempt"] for call in execution_log.api_calls] == [1, 2, 3]
+    assert execution_log.api_calls[0]["command"] == "get pods -o json"
+
+
+@pytest.mark.asyncio
+async def test_non_retriable_errors_and_writes_fail_fast(monkeypatch):
+    forbidden = {"exit_code": 1, "stdout": "", "stderr": (
+        'Error from server (Forbidden): pods is forbidden: User "dev" cannot list resource "pods" in API group "" '
+        'in the namespace "prod"'
+    )}
+    runner, scripted, sleeps = make_runner(monkeypatch, [forbidden])
+    with pytest.raises(module_under_test.KubectlCommandError):
+        await runner.run_json("/tmp/kubeconfig", ["get", "pods", "-n", "prod", "-o", "json"], ExecutionLog(tool_call_id="t"))
+    assert len(scripted.calls) == 1
+
+    # 写操作不重试，也不附加 --request-timeout
+    runner, scripted, sleeps = make_runner(monkeypatch, [THROTTLED])
+    result = await runner.run("/tmp/kubeconfig", ["apply", "-f", "-"], ExecutionLog(tool_call_id="t"), stdin="{}")
+    assert result["exit_code"] == 1
+    assert scripted.calls[0][0] == ["kubectl", "--kubeconfig", "/tmp/kubeconfig", "apply", "-f", "-"]
+    assert sleeps == []
+
+
+@pytest.mark.asyncio
+async def test_retries_are_bounded_by_max_retries_and_request_timeout_setting(monkeypatch):
+    runner, scripted, sleeps = make_runner(
+        monkeypatch, [THROTTLED, THROTTLED], kubectl_max_retries=1, request_timeout=0,
+    )
+    result = await runner.run("/tmp/kubeconfig", ["get", "nodes"], ExecutionLog(tool_call_id="t"), timeout=5)
+
+    assert result["exit_code"] == 1
+    assert len(scripted.calls) == 2
+    assert scripted.calls[0][0] == ["kubectl", "--kubeconfig", "/tmp/kubeconfig", "get", "nodes"]
+    assert sleeps == [0.5]
+
+
+@pytest.mark.asyncio
+async def test_cancelled_calls_kill_the_kubectl_process(monkeypatch):
+    runner = module_under_test.KubectlRunner({"request_timeout": 0})
+    # 以长时间运行的进程模拟响应缓慢的 API Server
+    monkeypatch.setattr(runner, "_command", lambda kubeconfig_path, args: [
+        sys.executable, "-c", "import time; time.sleep(30)",
+    ])
+    processes = []
+    create_subprocess_exec = asyncio.create_subprocess_exec
+
+    async def recording_create_subprocess_exec(*args, **kwargs):
+        processes.append(await create_subprocess_exec(*args, **kwargs))
+        return processes[-1]
+
+    monkeypatch.setattr(module_under_test.asyncio, "create_subprocess_exec", recording_create_subprocess_exec)
+    execution_log = ExecutionLog(tool_call_id="t")
+
+    start = time.monotonic()
+    with pytest.raises(asyncio.TimeoutError):
+        await asyncio.wait_for(runner.run("/tmp/kubeconfig", ["get", "pods"], execution_log, timeout=60), 0.5)
+
+    assert time.monotonic() - start < 5
+    assert len(processes) == 1 and processes[0].returncode is not None
+    # 取消后不再重试，也不记录未完成的调用
+    assert execution_log.api_calls == []
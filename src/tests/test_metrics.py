@@ -0,0 +1,90 @@
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import metrics as module_under_test
+
+
+class FakeToolResult:
+    def __init__(self, structured_content=None):
+        self.structured_content = structured_content
+
+
+class FakeMessage:
+    def __init__(self, name):
+        self.name = name
+
+
+class FakeMiddlewareContext:
+    def __init__(self, name):
+        self.message = FakeMessage(name)
+
+
+class FakeServer:
+    def __init__(self):
+        self.middleware = []
+        self.routes = {}
+
+    def add_middleware(self, middleware):
+        self.middleware.append(middleware)
+
+    def custom_route(self, path, methods):
+        def decorator(func):
+            self.routes[path] = func
+            return func
+        return decorator
+
+
+def _sample(name, **labels):
+    return module_under_test.REGISTRY.get_sample_value(name, labels) or 0.0
+
+
+@pytest.mark.asyncio
+async def test_metrics_endpoint_serves_prometheus_text_format():
+    server = FakeServer()
+    module_under_test.register_metrics(server)
+    assert isinstance(server.middleware[0], module_under_test.ToolMetricsMiddleware)
+
+    module_under_test.record_tool_call("metrics_endpoint_tool", True, 0.07)
+    module_under_test.record_tool_call("metrics_endpoint_tool", False, 0.5)
+
+    response = await server.routes["/metrics"](None)
+    text = response.body.decode()
+    assert response.media_type == module_under_test.CONTENT_TYPE_LATEST
+    assert "# TYPE ack_mcp_tool_calls_total counter" in text
+    assert 'ack_mcp_tool_calls_total{tool="metrics_endpoint_tool",result="success"} 1.0' in text
+    assert 'ack_mcp_tool_calls_total{tool="metrics_endpoint_tool",result="error"} 1.0' in text
+    assert "# TYPE ack_mcp_tool_duration_seconds histogram" in text
+    assert 'ack_mcp_tool_duration_seconds_bucket{tool="metrics_endpoint_tool",le="0.05"} 0.0' in text
+    assert 'ack_mcp_tool_duration_seconds_bucket{tool="metrics_endpoint_tool",le="0.1"} 1.0' in text
+    assert 'ack_mcp_tool_duration_seconds_bucket{tool="metrics_endpoint_tool",le="+Inf"} 2.0' in text
+    assert 'ack_mcp_tool_duration_seconds_count{tool="metrics_endpoint_tool"} 2.0' in text
+    assert _sample("ack_mcp_tool_duration_seconds_sum", tool="metrics_endpoint_tool") == pytest.approx(0.57)
+
+
+@pytest.mark.asyncio
+async def test_middleware_records_success_error_and_exceptions():
+    middleware = module_under_test.ToolMetricsMiddleware()
+    before_success = _sample("ack_mcp_tool_calls_total", tool="metrics_test_tool", result="success")
+    before_error = _sample("ack_mcp_tool_calls_total", tool="metrics_test_tool", result="error")
+
+    async def ok(context):
+        return FakeToolResult({"items": [], "error": None})
+
+    async def failed(context):
+        return FakeToolResult({"error": {"error_code": "Forbidden", "error_message": "denied"}})
+
+    async def raises(context):
+        raise RuntimeError("boom")
+
+    await middleware.on_call_tool(FakeMiddlewareContext("metrics_test_tool"), ok)
+    await middleware.on_call_tool(FakeMiddlewareContext("metrics_test_tool"), failed)
+    with pytest.raises(RuntimeError):
+        await middleware.on_call_tool(FakeMiddlewareContext("metrics_test_tool"), raises)
+
+    assert _sample("ack_mcp_tool_calls_total", tool="metrics_test_tool", result="success") == before_success + 1
+    assert _sample("ack_mcp_tool_calls_total", tool="metrics_test_tool", result="error") == before_error + 2
+    assert _sample("ack_mcp_tool_duration_seconds_count", tool="metrics_test_tool") >= 3
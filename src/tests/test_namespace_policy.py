@@ -0,0 +1,399 @@
+import asyncio
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import namespace_policy as module_under_test
+from fastmcp.exceptions import ResourceError, ToolError
+
+
+class FakeMessage:
+    def __init__(self, name, arguments):
+        self.name = name
+        self.arguments = arguments
+
+    def model_copy(self, update):
+        return FakeMessage(update.get("name", self.name), update.get("arguments", self.arguments))
+
+
+class FakeMiddlewareContext:
+    def __init__(self, message):
+        self.message = message
+
+    def copy(self, message):
+        return FakeMiddlewareContext(message)
+
+
+class FakeToolResult:
+    def __init__(self, structured_content):
+        self.structured_content = structured_content
+        self.content = []
+
+
+class RecordingCallNext:
+    """记录每次调用的参数，按命名空间返回预置结果"""
+
+    def __init__(self, results=None):
+        self.results = results or {}
+        self.calls = []
+
+    async def __call__(self, context):
+        self.calls.append(dict(context.message.arguments))
+        namespace = context.message.arguments.get("namespace")
+        return FakeToolResult(self.results.get(namespace, {"namespace": namespace, "items": [], "count": 0,
+                                                            "error": None}))
+
+
+class FakeResolver:
+    """按资源类型返回预置作用域的 API 发现替身，未预置的类型视为无法解析"""
+
+    def __init__(self, scopes):
+        self.scopes = scopes
+        self.calls = []
+
+    async def __call__(self, ctx, cluster_id, resource, api_version, context):
+        self.calls.append((cluster_id, resource, api_version, context))
+        return self.scopes.get(resource)
+
+
+def call(middleware, tool, arguments, call_next):
+    return middleware.on_call_tool(FakeMiddlewareContext(FakeMessage(tool, arguments)), call_next)
+
+
+def test_parse_allowed_namespaces_and_command_namespaces():
+    assert module_under_test.parse_allowed_namespaces(" team-a, team-b,,team-a ") == ["team-a", "team-b"]
+    assert module_under_test.parse_allowed_namespaces(None) == []
+
+    assert module_under_test.command_namespaces("get pods -n team-a") == (["team-a"], False)
+    assert module_under_test.command_namespaces("get pods --namespace=team-b -o wide") == (["team-b"], False)
+    assert module_under_test.command_namespaces("get pods -nteam-c") == (["team-c"], False)
+    assert module_under_test.command_namespaces("get pods -A") == ([], True)
+    assert module_under_test.command_namespaces("get nodes") == ([], False)
+
+
+@pytest.mark.asyncio
+async def test_rejects_namespaces_outside_allowlist():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a", "team-b"])
+    call_next = RecordingCallNext()
+
+    with pytest.raises(ToolError, match="namespace kube-system is not permitted; allowed namespaces: team-a, team-b"):
+        await call(middleware, "kubectl_logs", {"namespace": "kube-system", "pod": "coredns"}, call_next)
+    with pytest.raises(ToolError, match="namespace prod is not permitted"):
+        await call(middleware, "ack_kubectl", {"command": "delete pod web -n prod"}, call_next)
+    with pytest.raises(ToolError, match="--all-namespaces is not permitted"):
+        await call(middleware, "ack_kubectl", {"command": "get pods --all-namespaces"}, call_next)
+    assert call_next.calls == []
+
+    await call(middleware, "kubectl_logs", {"namespace": "team-a", "pod": "web"}, call_next)
+    await call(middleware, "ack_kubectl", {"command": "get pods -n team-b"}, call_next)
+    # 集群级资源与未使用命名空间的工具不受影响
+    await call(middleware, "kubectl_get", {"resource": "nodes"}, call_next)
+    await call(middleware, "list_clusters", {}, call_next)
+    assert len(call_next.calls) == 4
+
+
+@pytest.mark.asyncio
+async def test_expands_all_namespaces_to_allowlist():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a", "team-b"])
+    call_next = RecordingCallNext({
+        "team-a": {"namespace": "team-a", "items": [{"name": "a-1"}], "count": 1, "has_more": False, "error": None},
+        "team-b": {"namespace": "team-b", "items": [{"name": "b-1"}, {"name": "b-2"}], "count": 2,
+                   "has_more": True, "error": None},
+    })
+
+    result = await call(middleware, "kubectl_get", {"resource": "pods", "namespace": "all"}, call_next)
+
+    assert [c["namespace"] for c in call_next.calls] == ["team-a", "team-b"]
+    assert result.structured_content["items"] == [{"name": "a-1"}, {"name": "b-1"}, {"name": "b-2"}]
+    assert result.structured_content["count"] == 3
+    assert result.structured_content["has_more"] is True
+    assert result.structured_content["namespace"] == "team-a,team-b"
+    assert result.structured_content["error"] is None
+
+    # 仅允许一个命名空间时直接改写参数
+    single = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    call_next = RecordingCallNext()
+    result = await call(single, "kubectl_events", {"warnings_only": True}, call_next)
+    assert call_next.calls == [{"warnings_only": True, "namespace": "team-a"}]
+    assert result.structured_content["namespace"] == "team-a"
+
+    call_next = RecordingCallNext()
+    await call(single, "kubectl_unhealthy_pods", {"namespace": "all"}, call_next)
+    assert call_next.calls == [{"namespace": "team-a"}]
+
+
+@pytest.mark.asyncio
+async def test_multi_get_items_are_checked_individually():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    call_next = RecordingCallNext()
+    items = [{"label": "pods", "resource": "pods", "namespace": "team-a"}, {"label": "nodes", "resource": "nodes"}]
+    await call(middleware, "kubectl_multi_get", {"items": items}, call_next)
+    assert call_next.calls == [{"items": items}]
+
+    with pytest.raises(ToolError, match="team-b is not permitted"):
+        await call(middleware, "kubectl_multi_get", {"items": [{"label": "x", "resource": "pods", "namespace": "team-b"}]},
+                   call_next)
+    with pytest.raises(ToolError, match="'x' must specify a namespace"):
+        await call(middleware, "kubectl_multi_get", {"items": [{"label": "x", "resource": "pods", "namespace": "all"}]},
+                   call_next)
+    assert len(call_next.calls) == 1
+
+
+@pytest.mark.asyncio
+async def test_empty_namespace_resolves_to_the_namespace_the_tool_uses():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"], default_namespace="team-a")
+    call_next = RecordingCallNext()
+    await call(middleware, "kubectl_describe", {"resource": "pods", "name": "web"}, call_next)
+    await call(middleware, "kubectl_get", {"resource": "pods", "name": "web"}, call_next)
+    assert call_next.calls == [{"resource": "pods", "name": "web"}] * 2
+
+    # 默认命名空间不在白名单内时同样拒绝
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    for tool in ("kubectl_describe", "kubectl_owner_tree", "kubectl_delete", "kubectl_metadata", "kubectl_patch"):
+        with pytest.raises(ToolError, match="namespace default is not permitted"):
+            await call(middleware, tool, {"resource": "pods", "name": "web"}, call_next)
+    with pytest.raises(ToolError, match="namespace kube-system is not permitted"):
+        await call(middleware, "kubectl_addon_status", {}, call_next)
+    with pytest.raises(ToolError, match="namespace team-b is not permitted"):
+        await call(middleware, "kubectl_delete", {"resource": "namespaces", "name": "team-b"}, call_next)
+    with pytest.raises(ToolError, match="namespace kube-system is not permitted"):
+        await call(middleware, "kubectl_get", {"resource": "pods", "namespaces": ["team-a", "kube-system"]}, call_next)
+    with pytest.raises(ToolError, match="namespace kube-system is not permitted"):
+        await call(middleware, "diagnose_resource", {"resource_target": '{"namespace": "kube-system", "name": "web"}'},
+                   call_next)
+    assert len(call_next.calls) == 2
+
+
+@pytest.mark.asyncio
+async def test_rejects_all_namespace_calls_that_are_not_expanded():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    call_next = RecordingCallNext()
+    for tool, arguments in [
+        ("cluster_summary", {}),
+        ("kubectl_node_balance", {}),
+        ("kubectl_ephemeral_storage_risk", {}),
+        ("kubectl_unknown_tool", {"namespace": "all"}),
+        ("query_audit_log", {}),
+    ]:
+        with pytest.raises(ToolError, match="requires a namespace"):
+            await call(middleware, tool, arguments, call_next)
+    with pytest.raises(ToolError, match="draining a node"):
+        await call(middleware, "kubectl_node", {"name": "node-1", "action": "drain"}, call_next)
+    assert call_next.calls == []
+
+    await call(middleware, "kubectl_node", {"name": "node-1", "action": "cordon"}, call_next)
+    await call(middleware, "list_namespaces", {}, call_next)
+    await call(middleware, "kubectl_top", {"resource": "nodes"}, call_next)
+    assert len(call_next.calls) == 3
+
+
+@pytest.mark.asyncio
+async def test_ack_kubectl_requires_an_allowed_namespace():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    call_next = RecordingCallNext()
+    with pytest.raises(ToolError, match="must specify the namespace"):
+        await call(middleware, "ack_kubectl", {"command": "get pods"}, call_next)
+    with pytest.raises(ToolError, match="must specify the namespace"):
+        await call(middleware, "ack_kubectl", {"command": "get nodes"}, call_next)
+    with pytest.raises(ToolError, match="--raw is not permitted"):
+        await call(middleware, "ack_kubectl", {"command": "get --raw /api/v1/namespaces/prod/secrets -n team-a"},
+                   call_next)
+    with pytest.raises(ToolError, match="--raw is not permitted"):
+        await call(middleware, "ack_kubectl", {"command": "get --raw=/api/v1/pods -n team-a"}, call_next)
+    # 命令不经过 shell 执行，&& 之后的部分同样按命名空间校验
+    with pytest.raises(ToolError, match="namespace prod is not permitted"):
+        await call(middleware, "ack_kubectl", {"command": "get pods -n team-a && kubectl get secrets -n prod"},
+                   call_next)
+    assert call_next.calls == []
+
+    assert module_under_test.command_uses_raw("get --raw /healthz")
+    assert not module_under_test.command_uses_raw("get pods -o raw")
+
+
+@pytest.mark.asyncio
+async def test_manifest_documents_are_checked_individually():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    call_next = RecordingCallNext()
+    allowed = "kind: ConfigMap\nmetadata:\n  name: a\n  namespace: team-a\n"
+    in_argument = "kind: ConfigMap\nmetadata:\n  name: b\n"
+    await call(middleware, "kubectl_apply", {"manifest": allowed + "---\n" + in_argument, "namespace": "team-a"},
+               call_next)
+    assert len(call_next.calls) == 1
+
+    for tool in ("kubectl_apply", "kubectl_diff"):
+        with pytest.raises(ToolError, match="namespace prod is not permitted"):
+            await call(middleware, tool, {"manifest": allowed + "---\n" + allowed.replace("team-a", "prod")},
+                       call_next)
+        with pytest.raises(ToolError, match="ConfigMap/b must specify metadata.namespace"):
+            await call(middleware, tool, {"manifest": in_argument}, call_next)
+        with pytest.raises(ToolError, match="cluster-scoped object ClusterRoleBinding/admin is not permitted"):
+            await call(middleware, tool, {"manifest": "kind: ClusterRoleBinding\nmetadata:\n  name: admin\n",
+                                          "namespace": "team-a"}, call_next)
+    with pytest.raises(ToolError, match="namespace prod is not permitted"):
+        await call(middleware, "kubectl_apply", {
+            "manifest": '{"kind": "List", "items": [{"kind": "Secret", "metadata": {"name": "s", "namespace": "prod"}}]}',
+        }, call_next)
+    assert len(call_next.calls) == 1
+
+
+@pytest.mark.asyncio
+async def test_manifest_kinds_are_resolved_through_discovery():
+    resolver = FakeResolver({"ClusterIssuer": False, "Certificate": True})
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"], resolve_namespaced=resolver)
+    call_next = RecordingCallNext()
+    certificate = "apiVersion: cert-manager.io/v1\nkind: Certificate\nmetadata:\n  name: web\n"
+    await call(middleware, "kubectl_apply", {
+        "cluster_id": "c1", "manifest": certificate + "---\n" + certificate.replace("web", "api"), "namespace": "team-a",
+    }, call_next)
+    # 同一类型只解析一次，内置类型无需 API 发现
+    await call(middleware, "kubectl_diff", {
+        "cluster_id": "c1", "manifest": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n", "namespace": "team-a",
+    }, call_next)
+    assert resolver.calls == [("c1", "Certificate", "cert-manager.io/v1", None)]
+    assert len(call_next.calls) == 2
+
+    with pytest.raises(ToolError, match="cluster-scoped object ClusterIssuer/letsencrypt is not permitted"):
+        await call(middleware, "kubectl_apply", {
+            "cluster_id": "c1", "namespace": "team-a",
+            "manifest": "apiVersion: cert-manager.io/v1\nkind: ClusterIssuer\nmetadata:\n  name: letsencrypt\n",
+        }, call_next)
+    with pytest.raises(ToolError, match="cannot determine whether Widget/w1 .* is namespaced"):
+        await call(middleware, "kubectl_apply", {
+            "cluster_id": "c1", "namespace": "team-a",
+            "manifest": "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w1\n",
+        }, call_next)
+    # 未配置 API 发现时无法解析的类型同样拒绝
+    with pytest.raises(ToolError, match="cannot determine whether Certificate/web"):
+        await call(module_under_test.NamespaceAllowlistMiddleware(["team-a"]), "kubectl_apply", {
+            "cluster_id": "c1", "manifest": certificate, "namespace": "team-a",
+        }, call_next)
+    assert len(call_next.calls) == 2
+
+
+@pytest.mark.asyncio
+async def test_mutating_tools_reject_cluster_scoped_and_unresolved_resources():
+    resolver = FakeResolver({"clusterroles": False, "certificates": True})
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"], resolve_namespaced=resolver)
+    call_next = RecordingCallNext()
+
+    for tool, resource in [
+        ("kubectl_delete", "persistentvolumes"),
+        ("kubectl_metadata", "nodes"),
+        ("kubectl_patch", "clusterroles"),
+        ("kubectl_delete", "clusterroles"),
+    ]:
+        with pytest.raises(ToolError, match=f"cluster-scoped resource {resource} is not permitted"):
+            await call(middleware, tool, {"cluster_id": "c1", "resource": resource, "name": "x", "namespace": "team-a"},
+                       call_next)
+    with pytest.raises(ToolError, match="cannot determine whether widgets is namespaced"):
+        await call(middleware, "kubectl_delete", {"cluster_id": "c1", "resource": "widgets", "name": "w1",
+                                                  "namespace": "team-a"}, call_next)
+    with pytest.raises(ToolError, match="namespace team-b is not permitted"):
+        await call(middleware, "kubectl_patch", {"cluster_id": "c1", "resource": "certificates", "name": "web",
+                                                 "namespace": "team-b"}, call_next)
+    assert call_next.calls == []
+    # 注册表中的类型无需 API 发现
+    assert [c[1] for c in resolver.calls] == ["clusterroles", "clusterroles", "widgets", "certificates"]
+
+    await call(middleware, "kubectl_delete", {"cluster_id": "c1", "resource": "certificates", "name": "web",
+                                              "namespace": "team-a", "context": "prod"}, call_next)
+    await call(middleware, "kubectl_metadata", {"cluster_id": "c1", "resource": "namespaces", "name": "team-a"},
+               call_next)
+    assert resolver.calls[-1] == ("c1", "certificates", None, "prod")
+    assert len(call_next.calls) == 2
+
+    # 集群级资源的只读查询不受影响，且无需展开
+    await call(middleware, "kubectl_get", {"cluster_id": "c1", "resource": "clusterroles"}, call_next)
+    assert call_next.calls[-1] == {"cluster_id": "c1", "resource": "clusterroles"}
+
+
+@pytest.mark.asyncio
+async def test_expanded_calls_run_concurrently_and_restore_order_and_limits():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a", "team-b"])
+    # 两个命名空间的调用同时进行时才能通过，顺序执行时第一个调用会一直等待
+    barrier = asyncio.Barrier(2)
+    results = {
+        "kubectl_events": {
+            "team-a": {"events": [{"time": "2024-01-31T10:00:00Z"}, {"time": "2024-01-31T08:00:00Z"}], "count": 2,
+                       "total": 2},
+            "team-b": {"events": [{"time": "2024-01-31T09:00:00Z"}], "count": 1, "total": 1},
+        },
+        "kubectl_unhealthy_pods": {
+            "team-a": {"pods": [{"namespace": "team-a", "name": "a", "severity": 1, "restart_count": 9}],
+                       "restart_threshold": 5, "scanned": 3},
+            "team-b": {"pods": [{"namespace": "team-b", "name": "b", "severity": 3, "restart_count": 0}],
+                       "restart_threshold": 5, "scanned": 4},
+        },
+        "kubectl_get": {
+            "team-a": {"items": [{"namespace": "team-a", "name": "a-2"}, {"namespace": "team-a", "name": "a-1"}],
+                       "count": 2, "has_more": True, "continue_token": "token-a", "warnings": []},
+            "team-b": {"items": [{"namespace": "team-b", "name": "b-1"}], "count": 1, "has_more": False,
+                       "continue_token": None, "warnings": []},
+        },
+        "kubectl_watch": {
+            "team-a": {"events": [{"name": "a"}], "event_count": 1, "timeout_seconds": 30},
+            "team-b": {"events": [{"name": "b"}], "event_count": 1, "timeout_seconds": 30},
+        },
+    }
+
+    async def call_next(context):
+        await asyncio.wait_for(barrier.wait(), 1)
+        return FakeToolResult(results[context.message.name][context.message.arguments["namespace"]])
+
+    events = (await call(middleware, "kubectl_events", {"limit": 2}, call_next)).structured_content
+    assert [e["time"] for e in events["events"]] == ["2024-01-31T10:00:00Z", "2024-01-31T09:00:00Z"]
+    assert events["count"] == 2 and events["total"] == 3
+
+    pods = (await call(middleware, "kubectl_unhealthy_pods", {"namespace": "all"}, call_next)).structured_content
+    assert [p["name"] for p in pods["pods"]] == ["b", "a"]
+    assert pods["restart_threshold"] == 5 and pods["scanned"] == 7
+
+    listed = (await call(middleware, "kubectl_get", {"resource": "pods", "limit": 2}, call_next)).structured_content
+    assert [i["name"] for i in listed["items"]] == ["a-1", "a-2"]
+    assert listed["count"] == 2 and listed["has_more"] is True
+    assert listed["continue_token"] is None and "continue_token" in listed["warnings"][0]
+
+    watched = (await call(middleware, "kubectl_watch", {"resource": "pods"}, call_next)).structured_content
+    assert watched["timeout_seconds"] == 30 and watched["event_count"] == 2
+
+    with pytest.raises(ToolError, match="continue_token cannot be used"):
+        await call(middleware, "kubectl_get", {"resource": "pods", "continue_token": "token-a"}, call_next)
+
+
+def test_merge_keeps_error_only_when_every_namespace_failed():
+    not_found = {"error_code": "NotFound", "error_message": "not found"}
+    merged = module_under_test.merge_structured_results([
+        {"items": [], "error": not_found}, {"items": [{"name": "web"}], "error": None},
+    ])
+    assert merged == {"items": [{"name": "web"}], "error": None}
+
+    merged = module_under_test.merge_structured_results([{"items": [], "error": not_found}] * 2)
+    assert merged["error"] == not_found
+
+
+class FakeReadResource:
+    def __init__(self, uri):
+        self.uri = uri
+
+
+@pytest.mark.asyncio
+async def test_object_resource_reads_are_limited_to_allowed_namespaces():
+    middleware = module_under_test.NamespaceAllowlistMiddleware(["team-a"])
+    reads = []
+
+    async def call_next(context):
+        reads.append(context.message.uri)
+        return "{}"
+
+    assert await middleware.on_read_resource(FakeMiddlewareContext(FakeReadResource("k8s://c1/team-a/pods/web")), call_next) == "{}"
+    assert await middleware.on_read_resource(FakeMiddlewareContext(FakeReadResource("ack-logs://archives/a1")), call_next) == "{}"
+    with pytest.raises(ResourceError, match="team-b is not permitted"):
+        await middleware.on_read_resource(FakeMiddlewareContext(FakeReadResource("k8s://c1/team-b/pods/web")), call_next)
+    with pytest.raises(ResourceError, match="team-b is not permitted"):
+        await middleware.on_read_resource(
+            FakeMiddlewareContext(FakeReadResource("k8s://c1/team-b/pods/web?context=prod")), call_next
+        )
+    assert reads == ["k8s://c1/team-a/pods/web", "ack-logs://archives/a1"]
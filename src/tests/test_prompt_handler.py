@@ -0,0 +1,32 @@
+import os
+import sys
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import prompt_handler as module_under_test
+
+
+class FakeServer:
+    def __init__(self):
+        self.prompts = {}
+
+    def prompt(self, name: str = None, description: str = None):
+        def decorator(func):
+            self.prompts[name or func.__name__] = func
+            return func
+        return decorator
+
+
+def test_prompts_substitute_arguments():
+    server = FakeServer()
+    module_under_test.PromptHandler(server, {})
+    assert set(server.prompts) == {"diagnose-crashlooping-pod", "why-is-deployment-not-ready"}
+
+    message = server.prompts["diagnose-crashlooping-pod"](cluster_id="c1", namespace="prod", pod="web-1")
+    assert "kubectl_get（cluster_id=c1, resource=pods, name=web-1, namespace=prod, output=yaml）" in message
+    assert "kubectl_logs（cluster_id=c1, namespace=prod, name=web-1, previous=true）" in message
+    assert message.index("kubectl_get") < message.index("kubectl_logs") < message.index("kubectl_describe")
+
+    message = server.prompts["why-is-deployment-not-ready"](cluster_id="c1", namespace="prod", deployment="web")
+    assert "kubectl_rollout（cluster_id=c1, name=web, namespace=prod, action=status）" in message
+    assert "{" not in message
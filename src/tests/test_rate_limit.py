@@ -0,0 +1,75 @@
+import asyncio
+import os
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import rate_limit as module_under_test
+from fastmcp.exceptions import ToolError
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 100.0
+
+    def monotonic(self):
+        return self.now
+
+
+class FakeMessage:
+    def __init__(self, name):
+        self.name = name
+
+
+class FakeMiddlewareContext:
+    def __init__(self, name):
+        self.message = FakeMessage(name)
+
+
+def test_token_bucket_allows_burst_then_spaces_requests(monkeypatch):
+    clock = FakeClock()
+    monkeypatch.setattr(module_under_test.time, "monotonic", clock.monotonic)
+    bucket = module_under_test.TokenBucket(qps=2, burst=3)
+
+    assert [bucket.reserve() for _ in range(3)] == [0.0, 0.0, 0.0]
+    # 令牌耗尽后按预约顺序排队：第 4、5 个请求分别等待 0.5、1 秒
+    assert bucket.reserve() == pytest.approx(0.5)
+    assert bucket.reserve() == pytest.approx(1.0)
+    clock.now += 10
+    assert bucket.reserve() == 0.0
+
+
+def test_get_rate_limiter_shares_bucket_and_can_be_disabled():
+    settings = {"kubectl_qps": 7, "kubectl_burst": 9}
+    limiter = module_under_test.get_rate_limiter(settings)
+    assert limiter is module_under_test.get_rate_limiter(dict(settings))
+    assert (limiter.qps, limiter.burst) == (7.0, 9)
+    assert module_under_test.get_rate_limiter({"kubectl_qps": 0}) is None
+    assert module_under_test.get_rate_limiter(None).qps == module_under_test.DEFAULT_KUBECTL_QPS
+
+
+@pytest.mark.asyncio
+async def test_concurrency_limit_rejects_calls_when_saturated():
+    middleware = module_under_test.ConcurrencyLimitMiddleware(2)
+    release = asyncio.Event()
+    started = []
+
+    async def slow(context):
+        started.append(context.message.name)
+        await release.wait()
+        return "done"
+
+    running = [asyncio.ensure_future(middleware.on_call_tool(FakeMiddlewareContext(f"t{i}"), slow)) for i in range(2)]
+    await asyncio.sleep(0)
+    assert middleware.active == 2
+
+    with pytest.raises(ToolError, match="server busy: 2 tool calls are already in progress"):
+        await middleware.on_call_tool(FakeMiddlewareContext("t3"), slow)
+    assert started == ["t0", "t1"]
+
+    release.set()
+    assert await asyncio.gather(*running) == ["done", "done"]
+    assert middleware.active == 0
+    assert await middleware.on_call_tool(FakeMiddlewareContext("t4"), slow) == "done"
@@ -0,0 +1,109 @@
+import io
+import json
+import os
+import sys
+from datetime import datetime, timezone
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import request_logging as module_under_test
+
+
+class FakeMessage:
+    def __init__(self, name, arguments):
+        self.name = name
+        self.arguments = arguments
+
+
+class FakeMiddlewareContext:
+    def __init__(self, name, arguments=None):
+        self.message = FakeMessage(name, arguments or {})
+
+
+class FakeToolResult:
+    def __init__(self, structured_content=None, meta=None):
+        self.structured_content = structured_content
+        self.meta = meta
+
+
+class FakeLevel:
+    name = "INFO"
+
+
+class FakeLogMessage(str):
+    """loguru 传给函数 sink 的 Message：字符串内容附带 record"""
+
+    def __new__(cls, text, record):
+        message = super().__new__(cls, text)
+        message.record = record
+        return message
+
+
+def test_request_fields_extracts_cluster_context_and_namespace():
+    fields = module_under_test.request_fields(
+        "kubectl_get", {"cluster_id": "c1", "context": " prod ", "namespace": "", "resource": "pods"},
+    )
+    assert fields == {"tool": "kubectl_get", "cluster_id": "c1", "context": "prod"}
+    assert module_under_test.request_fields("list_clusters", None) == {"tool": "list_clusters"}
+
+
+@pytest.mark.asyncio
+async def test_middleware_exposes_request_id_and_echoes_it_in_result_meta():
+    middleware = module_under_test.RequestContextMiddleware()
+    seen = []
+
+    async def call_next(context):
+        seen.append(module_under_test.current_request_id())
+        return FakeToolResult({"items": [], "error": None}, meta={"trace": "x"})
+
+    first = await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get", {"cluster_id": "c1"}), call_next)
+    second = await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get", {"cluster_id": "c1"}), call_next)
+
+    assert len(seen[0]) == 16 and seen[0] != seen[1]
+    assert first.meta == {"trace": "x", "request_id": seen[0]}
+    assert second.meta["request_id"] == seen[1]
+    # 调用结束后不再关联请求 ID
+    assert module_under_test.current_request_id() is None
+
+    async def raises(context):
+        raise RuntimeError("boom")
+
+    with pytest.raises(RuntimeError):
+        await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get"), raises)
+    assert module_under_test.current_request_id() is None
+
+
+def test_json_sink_writes_one_line_with_context_fields(monkeypatch):
+    stream = io.StringIO()
+    monkeypatch.setattr(module_under_test.sys, "stderr", stream)
+    record = {
+        "time": datetime(2026, 10, 15, 8, 0, 0, tzinfo=timezone.utc),
+        "level": FakeLevel(),
+        "message": "Tool call kubectl_get finished with success in 12ms",
+        "name": "request_logging",
+        "function": "on_call_tool",
+        "line": 120,
+        "extra": {"request_id": "abc123", "tool": "kubectl_get", "cluster_id": "c1", "latency_ms": 12,
+                  "request_prefix": "[abc123] "},
+        "exception": None,
+    }
+
+    module_under_test._json_sink(FakeLogMessage(record["message"], record))
+
+    lines = stream.getvalue().splitlines()
+    assert len(lines) == 1
+    entry = json.loads(lines[0])
+    assert entry == {
+        "time": "2026-10-15T08:00:00+00:00",
+        "level": "INFO",
+        "message": "Tool call kubectl_get finished with success in 12ms",
+        "logger": "request_logging:on_call_tool:120",
+        "request_id": "abc123",
+        "tool": "kubectl_get",
+        "cluster_id": "c1",
+        "latency_ms": 12,
+    }
+    with pytest.raises(ValueError):
+        module_under_test.configure_logging("INFO", "xml")
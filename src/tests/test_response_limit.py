@@ -0,0 +1,81 @@
+import json
+import os
+import sys
+
+import pytest
+import yaml
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import response_limit as module_under_test
+from fastmcp.tools.tool import ToolResult
+
+
+class FakeMessage:
+    def __init__(self, name):
+        self.name = name
+        self.arguments = {}
+
+
+class FakeMiddlewareContext:
+    def __init__(self, name):
+        self.message = FakeMessage(name)
+
+
+def _items(count):
+    return [{"name": f"pod-{i:03d}", "namespace": "prod", "status": "Running", "node": "node-1"} for i in range(count)]
+
+
+def test_truncate_response_keeps_whole_items_and_reports_omitted():
+    data = {"cluster_id": "c1", "items": _items(200), "count": 200, "error": None}
+    assert module_under_test.truncate_response(data, 1024 * 1024) is None
+
+    result = module_under_test.truncate_response(data, 2000)
+
+    assert module_under_test.response_size(result) <= 2000
+    returned = result["items"]
+    assert returned == data["items"][:len(returned)] and 0 < len(returned) < 200
+    info = result["truncated"]
+    assert info["original_bytes"] == module_under_test.response_size(data)
+    assert info["fields"] == {"items": {"returned": len(returned), "omitted": 200 - len(returned), "unit": "items"}}
+    assert "label_selector" in info["hint"] and "limit" in info["hint"]
+    # 原始结果不被修改
+    assert len(data["items"]) == 200 and "truncated" not in data
+
+
+def test_truncate_response_cuts_yaml_at_document_boundaries():
+    documents = [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": f"cm-{i}"}, "data": {"k": "v" * 50}}
+                 for i in range(40)]
+    data = {"cluster_id": "c1", "yaml": yaml.safe_dump_all(documents, sort_keys=False)}
+
+    result = module_under_test.truncate_response(data, 1500)
+
+    parsed = list(yaml.safe_load_all(result["yaml"]))
+    assert parsed == documents[:len(parsed)] and 0 < len(parsed) < 40
+    assert result["truncated"]["fields"]["yaml"]["unit"] == "documents"
+    assert result["truncated"]["fields"]["yaml"]["omitted"] == 40 - len(parsed)
+
+    # 单个文档按行截断且仍可解析
+    single = {"yaml": yaml.safe_dump({"data": {f"key-{i}": "v" * 40 for i in range(100)}})}
+    result = module_under_test.truncate_response(single, 1200)
+    assert result["truncated"]["fields"]["yaml"]["unit"] == "lines"
+    assert isinstance(yaml.safe_load(result["yaml"]), dict)
+
+
+@pytest.mark.asyncio
+async def test_middleware_replaces_oversized_results_only():
+    middleware = module_under_test.ResponseSizeLimitMiddleware(1500)
+    small = ToolResult(content="{}", structured_content={"items": _items(2)}, meta={"trace": "x"})
+    large = ToolResult(content="{}", structured_content={"items": _items(100)}, meta={"trace": "x"})
+
+    async def call_next(context):
+        return responses.pop(0)
+
+    responses = [small, large]
+    assert await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get"), call_next) is small
+    result = await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get"), call_next)
+
+    assert result is not large
+    assert result.meta == {"trace": "x"}
+    assert json.loads(result.content) == result.structured_content
+    assert result.structured_content["truncated"]["fields"]["items"]["omitted"] > 0
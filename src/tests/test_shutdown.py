@@ -0,0 +1,147 @@
+import asyncio
+import os
+import signal
+import sys
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+import kubectl_handler
+import shutdown as module_under_test
+from fastmcp.exceptions import ToolError
+
+
+class FakeMessage:
+    def __init__(self, name, arguments=None):
+        self.name = name
+        self.arguments = arguments or {}
+
+
+class FakeMiddlewareContext:
+    def __init__(self, name, arguments=None):
+        self.message = FakeMessage(name, arguments)
+
+
+def test_is_streaming_call():
+    assert module_under_test.is_streaming_call("kubectl_watch", {})
+    assert module_under_test.is_streaming_call("ack_kubectl", {"command": "logs -f web-1 -n prod"})
+    assert not module_under_test.is_streaming_call("ack_kubectl", {"command": "logs web-1 -n prod"})
+    assert not module_under_test.is_streaming_call("kubectl_get", {"resource": "pods"})
+    # 与 ack_kubectl 按流式方式执行的命令一致
+    handler = kubectl_handler.KubectlHandler(None, {})
+    for command in ("logs -f web-1", "get pods -w", "get pods --watch=true", "attach web-1", "get pods", "logs web-1"):
+        assert module_under_test.is_streaming_call("ack_kubectl", {"command": command}) == \
+            handler.is_streaming_command(command)[0]
+
+
+@pytest.mark.asyncio
+async def test_drain_waits_for_calls_and_cancels_streaming_ones():
+    middleware = module_under_test.InFlightCallsMiddleware(timeout=5)
+    finished = []
+
+    async def slow_apply(context):
+        await asyncio.sleep(0.05)
+        finished.append(context.message.name)
+        return "applied"
+
+    async def watch(context):
+        try:
+            await asyncio.sleep(60)
+        finally:
+            finished.append("watch stopped")
+
+    apply_call = asyncio.ensure_future(middleware.on_call_tool(FakeMiddlewareContext("kubectl_apply"), slow_apply))
+    watch_call = asyncio.ensure_future(middleware.on_call_tool(FakeMiddlewareContext("kubectl_watch"), watch))
+    await asyncio.sleep(0.01)
+    assert middleware.in_flight == 2
+
+    assert await middleware.drain() == (1, 1)
+    assert await apply_call == "applied"
+    with pytest.raises(ToolError, match="kubectl_watch was cancelled because the server is shutting down"):
+        await watch_call
+    assert finished == ["watch stopped", "kubectl_apply"]
+    assert middleware.in_flight == 0
+
+    # 排空期间拒绝新的调用
+    with pytest.raises(ToolError, match="shutting down"):
+        await middleware.on_call_tool(FakeMiddlewareContext("kubectl_get"), slow_apply)
+
+
+@pytest.mark.asyncio
+async def test_drain_kills_streaming_ack_kubectl_process(monkeypatch):
+    middleware = module_under_test.InFlightCallsMiddleware(timeout=5)
+    handler = kubectl_handler.KubectlHandler(None, {})
+    # 以持续运行的进程模拟 kubectl logs -f
+    monkeypatch.setattr(handler.runner, "_command", lambda kubeconfig_path, args: [
+        sys.executable, "-c", "import time; time.sleep(30)",
+    ])
+    processes = []
+    create_subprocess_exec = asyncio.create_subprocess_exec
+
+    async def recording_create_subprocess_exec(*args, **kwargs):
+        processes.append(await create_subprocess_exec(*args, **kwargs))
+        return processes[-1]
+
+    monkeypatch.setattr(asyncio, "create_subprocess_exec", recording_create_subprocess_exec)
+
+    async def logs(context):
+        command = context.message.arguments["command"]
+        return await handler.run_streaming_command(
+            command, "/tmp/kubeconfig", 300, kubectl_handler.ExecutionLog(tool_call_id="t")
+        )
+
+    call = asyncio.ensure_future(middleware.on_call_tool(
+        FakeMiddlewareContext("ack_kubectl", {"command": "logs -f web-1"}), logs
+    ))
+    while not processes:
+        await asyncio.sleep(0.01)
+
+    assert await middleware.drain() == (0, 1)
+    with pytest.raises(ToolError, match="ack_kubectl was cancelled because the server is shutting down"):
+        await call
+    assert processes[0].returncode is not None
+
+
+@pytest.mark.asyncio
+async def test_drain_cancels_calls_exceeding_timeout_and_keeps_client_cancellation():
+    middleware = module_under_test.InFlightCallsMiddleware(timeout=0.05)
+
+    async def hangs(context):
+        await asyncio.sleep(60)
+
+    # 调用方取消时照常向上传播 CancelledError
+    call = asyncio.ensure_future(middleware.on_call_tool(FakeMiddlewareContext("kubectl_get"), hangs))
+    await asyncio.sleep(0)
+    call.cancel()
+    with pytest.raises(asyncio.CancelledError):
+        await call
+    assert middleware.in_flight == 0
+
+    call = asyncio.ensure_future(middleware.on_call_tool(FakeMiddlewareContext("diagnose_resource"), hangs))
+    await asyncio.sleep(0)
+    assert await middleware.drain() == (0, 1)
+    with pytest.raises(ToolError):
+        await call
+
+
+@pytest.mark.asyncio
+async def test_signal_drains_before_forwarding_to_previous_handler():
+    middleware = module_under_test.InFlightCallsMiddleware(timeout=1)
+    received = []
+    previous = signal.signal(signal.SIGTERM, lambda sig, frame: received.append(sig))
+    try:
+        middleware.install_signal_handlers()
+        os.kill(os.getpid(), signal.SIGTERM)
+        for _ in range(50):
+            if received:
+                break
+            await asyncio.sleep(0.01)
+        assert middleware.draining
+        assert received == [signal.SIGTERM]
+    finally:
+        loop = asyncio.get_running_loop()
+        for sig in module_under_test.SHUTDOWN_SIGNALS:
+            loop.remove_signal_handler(sig)
+        signal.signal(signal.SIGINT, signal.default_int_handler)
+        signal.signal(signal.SIGTERM, previous)